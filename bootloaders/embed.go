@@ -4,7 +4,6 @@ import (
 	"embed"
 	"fmt"
 	"io/fs"
-	"path"
 )
 
 //go:embed all:default all:secureboot
@@ -16,15 +15,13 @@ func Resolve(setName, filename string) (data []byte, resolvedSet string, err err
 	if setName == "" {
 		setName = DefaultSet
 	}
-	p := path.Join(setName, filename)
-	if data, err := Bootloaders.ReadFile(p); err == nil {
+	if data, _, err := readFile(setName, filename); err == nil {
 		return data, setName, nil
 	}
 	if setName == DefaultSet {
 		return nil, "", fmt.Errorf("bootloader file not found: %s", filename)
 	}
-	p = path.Join(DefaultSet, filename)
-	data, err = Bootloaders.ReadFile(p)
+	data, _, err = readFile(DefaultSet, filename)
 	if err != nil {
 		return nil, "", fmt.Errorf("bootloader file not found in %q or %q: %s", setName, DefaultSet, filename)
 	}
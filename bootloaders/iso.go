@@ -0,0 +1,143 @@
+package bootloaders
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// A bootstrap CD only has to carry one file: a FAT image with
+// EFI/BOOT/BOOTX64.EFI, which UEFI firmware boots through an El Torito
+// catalog entry for the EFI platform. That is little enough to lay out by
+// hand rather than stage a directory tree for a general ISO writer.
+
+const sector = 2048
+
+const (
+	pvdSector     = 16
+	bootRecSector = 17
+	termSector    = 18
+	lPathSector   = 19
+	mPathSector   = 20
+	rootSector    = 21
+	catalogSector = 22
+	imageSector   = 23
+)
+
+// efiISO returns a UEFI-bootable ISO 9660 image holding efiImage as
+// EFIBOOT.IMG, its El Torito boot image.
+func efiISO(volumeID string, efiImage []byte) []byte {
+	imageSectors := (len(efiImage) + sector - 1) / sector
+	total := imageSector + imageSectors
+	out := make([]byte, total*sector)
+	at := func(n int) []byte { return out[n*sector : (n+1)*sector] }
+
+	root := dirRecord(rootSector, sector, true, "\x00")
+
+	pvd := at(pvdSector)
+	pvd[0] = 1
+	copy(pvd[1:], "CD001")
+	pvd[6] = 1
+	padCopy(pvd[8:40], "")
+	padCopy(pvd[40:72], volumeID)
+	bothEndian32(pvd[80:], uint32(total))
+	bothEndian16(pvd[120:], 1)
+	bothEndian16(pvd[124:], 1)
+	bothEndian16(pvd[128:], sector)
+	bothEndian32(pvd[132:], 10)
+	binary.LittleEndian.PutUint32(pvd[140:], lPathSector)
+	binary.BigEndian.PutUint32(pvd[148:], mPathSector)
+	copy(pvd[156:], root)
+	padCopy(pvd[190:813], "")
+	copy(pvd[318:], "BOOTIMUS")
+	for _, off := range []int{813, 830, 847, 864} {
+		copy(pvd[off:], "0000000000000000")
+	}
+	pvd[881] = 1
+
+	boot := at(bootRecSector)
+	copy(boot[1:], "CD001")
+	boot[6] = 1
+	copy(boot[7:], "EL TORITO SPECIFICATION")
+	binary.LittleEndian.PutUint32(boot[71:], catalogSector)
+
+	term := at(termSector)
+	term[0] = 255
+	copy(term[1:], "CD001")
+	term[6] = 1
+
+	// One path table entry each, for the root.
+	lPath, mPath := at(lPathSector), at(mPathSector)
+	lPath[0], mPath[0] = 1, 1
+	binary.LittleEndian.PutUint32(lPath[2:], rootSector)
+	binary.BigEndian.PutUint32(mPath[2:], rootSector)
+	binary.LittleEndian.PutUint16(lPath[6:], 1)
+	binary.BigEndian.PutUint16(mPath[6:], 1)
+
+	var dir bytes.Buffer
+	dir.Write(root)
+	dir.Write(dirRecord(rootSector, sector, true, "\x01"))
+	dir.Write(dirRecord(catalogSector, sector, false, "BOOT.CAT;1"))
+	dir.Write(dirRecord(imageSector, len(efiImage), false, "EFIBOOT.IMG;1"))
+	copy(at(rootSector), dir.Bytes())
+
+	// The catalog's default entry is marked not bootable, so BIOS firmware
+	// passes the disc over; the one section is for EFI.
+	cat := at(catalogSector)
+	cat[0] = 1
+	copy(cat[4:], "BOOTIMUS")
+	cat[30], cat[31] = 0x55, 0xAA
+	var sum uint16
+	for i := 0; i < 32; i += 2 {
+		sum += binary.LittleEndian.Uint16(cat[i:])
+	}
+	binary.LittleEndian.PutUint16(cat[28:], -sum)
+	cat[32] = 0x00
+	cat[64] = 0x91 // final section header
+	cat[65] = 0xEF // EFI
+	binary.LittleEndian.PutUint16(cat[66:], 1)
+	cat[96] = 0x88 // bootable, no emulation
+	count := (len(efiImage) + 511) / 512
+	if count > 0xFFFF {
+		count = 0 // to the end of the image
+	}
+	binary.LittleEndian.PutUint16(cat[102:], uint16(count))
+	binary.LittleEndian.PutUint32(cat[104:], imageSector)
+
+	copy(out[imageSector*sector:], efiImage)
+	return out
+}
+
+func dirRecord(extent uint32, size int, isDir bool, name string) []byte {
+	n := 33 + len(name)
+	if n%2 == 1 {
+		n++
+	}
+	rec := make([]byte, n)
+	rec[0] = byte(n)
+	bothEndian32(rec[2:], extent)
+	bothEndian32(rec[10:], uint32(size))
+	if isDir {
+		rec[25] = 2
+	}
+	bothEndian16(rec[28:], 1)
+	rec[32] = byte(len(name))
+	copy(rec[33:], name)
+	return rec
+}
+
+func padCopy(dst []byte, s string) {
+	for i := range dst {
+		dst[i] = ' '
+	}
+	copy(dst, s)
+}
+
+func bothEndian16(b []byte, v uint16) {
+	binary.LittleEndian.PutUint16(b, v)
+	binary.BigEndian.PutUint16(b[2:], v)
+}
+
+func bothEndian32(b []byte, v uint32) {
+	binary.LittleEndian.PutUint32(b, v)
+	binary.BigEndian.PutUint32(b[4:], v)
+}
@@ -2,7 +2,6 @@ package bootloaders
 
 import (
 	"encoding/json"
-	"path"
 )
 
 type Manifest struct {
@@ -28,7 +27,7 @@ func LoadManifest(setName string) (*Manifest, error) {
 	if setName == "" {
 		setName = DefaultSet
 	}
-	data, err := Bootloaders.ReadFile(path.Join(setName, "manifest.json"))
+	data, _, err := readFile(setName, "manifest.json")
 	if err != nil {
 		return nil, err
 	}
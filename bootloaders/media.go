@@ -0,0 +1,42 @@
+package bootloaders
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Preconfigure returns a copy of a default-set bootloader with its embedded
+// script, embed.ipxe, replaced by script. iPXE keeps the script as plain
+// text, so it is swapped in place; script is padded with newlines to the
+// same length and must not be longer.
+func Preconfigure(filename, script string) ([]byte, error) {
+	data, _, err := Resolve(DefaultSet, filename)
+	if err != nil {
+		return nil, err
+	}
+	embedded, _, err := Resolve(DefaultSet, "embed.ipxe")
+	if err != nil {
+		return nil, err
+	}
+	if len(script) > len(embedded) {
+		return nil, fmt.Errorf("script is %d bytes; %s only has room for %d", len(script), filename, len(embedded))
+	}
+	at := bytes.Index(data, embedded)
+	if at < 0 || bytes.Index(data[at+1:], embedded) >= 0 {
+		return nil, fmt.Errorf("%s does not embed embed.ipxe exactly once", filename)
+	}
+	out := bytes.Clone(data)
+	copy(out[at:], script+strings.Repeat("\n", len(embedded)-len(script)))
+	return out, nil
+}
+
+// BootstrapISO is bootimus.usb with script embedded, wrapped in a CD image
+// that UEFI firmware boots.
+func BootstrapISO(script string) ([]byte, error) {
+	img, err := Preconfigure("bootimus.usb", script)
+	if err != nil {
+		return nil, err
+	}
+	return efiISO("BOOTIMUS", img), nil
+}
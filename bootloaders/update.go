@@ -0,0 +1,293 @@
+package bootloaders
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"bootimus/internal/securepath"
+)
+
+// Updates replace files of the embedded sets without rebuilding bootimus.
+// A release bundle is a .tar.gz of "<set>/<file>" entries plus a
+// bundle.json, signed with a detached Ed25519 signature. Installed files
+// live in the update directory and shadow the embedded ones file by file.
+
+const (
+	bundleInfoName    = "bundle.json"
+	installedInfoName = "installed.json"
+	maxBundleSize     = 256 << 20
+)
+
+type BundleInfo struct {
+	Version string `json:"version"`
+	Notes   string `json:"notes,omitempty"`
+}
+
+type UpdateInfo struct {
+	Version     string    `json:"version"`
+	Notes       string    `json:"notes,omitempty"`
+	SHA256      string    `json:"sha256"`
+	KeyID       string    `json:"key_id"`
+	InstalledAt time.Time `json:"installed_at"`
+	Files       []string  `json:"files"`
+}
+
+var (
+	updateMu  sync.RWMutex
+	updateDir string
+)
+
+// SetUpdateDir enables the update layer, stored in dir.
+func SetUpdateDir(dir string) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	updateDir = dir
+}
+
+// readFile returns a set's file from the update layer if one is installed,
+// otherwise from the embedded FS.
+func readFile(setName, filename string) ([]byte, bool, error) {
+	updateMu.RLock()
+	dir := updateDir
+	updateMu.RUnlock()
+	if dir != "" {
+		if p, err := securepath.Join(filepath.Join(dir, "current", setName), filename); err == nil {
+			if data, err := os.ReadFile(p); err == nil {
+				return data, true, nil
+			}
+		}
+	}
+	data, err := Bootloaders.ReadFile(path.Join(setName, filename))
+	return data, false, err
+}
+
+// FileSource reports where Resolve would take a file from: "update" or
+// "embedded". It returns "" when the file is in neither.
+func FileSource(setName, filename string) string {
+	_, updated, err := readFile(setName, filename)
+	switch {
+	case err != nil:
+		return ""
+	case updated:
+		return "update"
+	}
+	return "embedded"
+}
+
+// ParsePublicKey accepts an Ed25519 public key as PEM ("PUBLIC KEY") or as
+// the base64 of its 32 raw bytes.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("signing key must be Ed25519, got %T", key)
+		}
+		return edKey, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("signing key is neither PEM nor a base64 Ed25519 key")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// KeyID is a short fingerprint used to say which key signed a bundle.
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// VerifyBundle checks the detached signature (raw 64 bytes or base64)
+// against the trusted keys and returns the ID of the key that matched.
+func VerifyBundle(bundle, sig []byte, keys []ed25519.PublicKey) (string, error) {
+	if len(keys) == 0 {
+		return "", errors.New("no bootloader signing keys configured")
+	}
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil || len(decoded) != ed25519.SignatureSize {
+			return "", errors.New("signature is not a 64-byte Ed25519 signature")
+		}
+		sig = decoded
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, bundle, sig) {
+			return KeyID(key), nil
+		}
+	}
+	return "", errors.New("signature does not match any trusted key")
+}
+
+// InstallBundle unpacks a verified bundle and makes it the active update.
+// Only the embedded sets can be updated, and the switch is atomic: clients
+// see either the old files or the new ones.
+func InstallBundle(bundle []byte, keyID string) (*UpdateInfo, error) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	if updateDir == "" {
+		return nil, errors.New("bootloader updates are not enabled")
+	}
+	if err := os.MkdirAll(updateDir, 0755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(updateDir, "staging-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	info, files, err := unpackBundle(bundle, staging)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(bundle)
+	installed := &UpdateInfo{
+		Version:     info.Version,
+		Notes:       info.Notes,
+		SHA256:      hex.EncodeToString(sum[:]),
+		KeyID:       keyID,
+		InstalledAt: time.Now(),
+		Files:       files,
+	}
+	data, err := json.MarshalIndent(installed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(staging, installedInfoName), data, 0644); err != nil {
+		return nil, err
+	}
+
+	current := filepath.Join(updateDir, "current")
+	previous := filepath.Join(updateDir, "previous")
+	os.RemoveAll(previous)
+	if err := os.Rename(current, previous); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := os.Rename(staging, current); err != nil {
+		os.Rename(previous, current)
+		return nil, err
+	}
+	return installed, nil
+}
+
+func unpackBundle(bundle []byte, dest string) (*BundleInfo, []string, error) {
+	if len(bundle) > maxBundleSize {
+		return nil, nil, fmt.Errorf("bundle larger than %d MB", maxBundleSize>>20)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		return nil, nil, fmt.Errorf("bundle is not gzip: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var info *BundleInfo
+	var files []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, nil, fmt.Errorf("bundle entry %s: only regular files are allowed", hdr.Name)
+		}
+		if name == bundleInfoName {
+			info = &BundleInfo{}
+			if err := json.NewDecoder(io.LimitReader(tr, 1<<20)).Decode(info); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", bundleInfoName, err)
+			}
+			continue
+		}
+
+		set, _, ok := strings.Cut(name, "/")
+		if !ok || !IsBuiltIn(set) {
+			return nil, nil, fmt.Errorf("bundle entry %s is not inside an embedded set", hdr.Name)
+		}
+		target, err := securepath.Join(dest, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bundle entry %s: %w", hdr.Name, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, nil, err
+		}
+		f, err := os.Create(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, err = io.Copy(f, io.LimitReader(tr, maxBundleSize))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, name)
+	}
+	if info == nil || info.Version == "" {
+		return nil, nil, fmt.Errorf("bundle has no %s with a version", bundleInfoName)
+	}
+	if len(files) == 0 {
+		return nil, nil, errors.New("bundle contains no bootloader files")
+	}
+	return info, files, nil
+}
+
+// InstalledUpdate returns the active update, or nil when the embedded
+// files are served unmodified.
+func InstalledUpdate() (*UpdateInfo, error) {
+	updateMu.RLock()
+	defer updateMu.RUnlock()
+	if updateDir == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(updateDir, "current", installedInfoName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var info UpdateInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// RemoveUpdate drops the active update so the embedded files are served
+// again.
+func RemoveUpdate() error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	if updateDir == "" {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(updateDir, "current"))
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"bootimus/internal/bench"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Load test a running server with simulated PXE clients",
+	Long: `Simulate many clients booting at once against a running Bootimus server.
+Each client repeatedly fetches the boot menu, downloads a kernel and reads
+random ranges of an ISO, then throughput and latency percentiles are
+reported per request type. The kernel and ISO are taken from the menu
+unless --kernel and --iso are given.`,
+	RunE: runBench,
+}
+
+func init() {
+	benchCmd.Flags().String("url", "", "Server base URL (default http://<server-addr or localhost>:<http-port>)")
+	benchCmd.Flags().Int("clients", 50, "Concurrent simulated clients")
+	benchCmd.Flags().Duration("duration", 30*time.Second, "How long to run")
+	benchCmd.Flags().String("kernel", "", "Kernel URL or path to download (default: first kernel in the menu)")
+	benchCmd.Flags().String("iso", "", "ISO URL or path for range reads (default: first sanboot ISO in the menu)")
+	benchCmd.Flags().Int64("range-size", 1<<20, "Bytes per ISO range read")
+	benchCmd.Flags().Int("range-reads", 8, "ISO range reads per client per round")
+	rootCmd.AddCommand(benchCmd)
+}
+
+func runBench(cmd *cobra.Command, args []string) error {
+	f := cmd.Flags()
+	base, _ := f.GetString("url")
+	if base == "" {
+		host := viper.GetString("server_addr")
+		if host == "" {
+			host = "localhost"
+		}
+		base = fmt.Sprintf("http://%s:%d", host, viper.GetInt("http_port"))
+	}
+	cfg := bench.Config{BaseURL: base, Token: viper.GetString("boot_token")}
+	cfg.Clients, _ = f.GetInt("clients")
+	cfg.Duration, _ = f.GetDuration("duration")
+	cfg.Kernel, _ = f.GetString("kernel")
+	cfg.ISO, _ = f.GetString("iso")
+	cfg.RangeSize, _ = f.GetInt64("range-size")
+	cfg.RangeReads, _ = f.GetInt("range-reads")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Printf("Benchmarking %s with %d clients for %v...\n", base, cfg.Clients, cfg.Duration)
+	rep, err := bench.Run(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	rep.Write(os.Stdout)
+	return nil
+}
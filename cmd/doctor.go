@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"bootimus/internal/doctor"
+	"bootimus/internal/storage"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment before serving",
+	Long: `Check that the ports Bootimus listens on are free and may be bound, that the
+data directory is writable, that the advertised server address is on a LAN
+interface PXE clients can reach, that the database connects and that the
+embedded bootloaders are complete. Run it with the same flags, config file
+and environment as "serve". It exits non-zero if any check fails.`,
+	Run: runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	ports := []doctor.Port{
+		{Name: "TFTP", Network: "udp", Port: viper.GetInt("tftp_port"), Flag: "--tftp-port"},
+		{Name: "HTTP", Network: "tcp", Port: viper.GetInt("http_port"), Flag: "--http-port"},
+		{Name: "Admin", Network: "tcp", Port: viper.GetInt("admin_port"), Flag: "--admin-port"},
+	}
+	if viper.GetBool("proxy_dhcp.enabled") || viper.GetBool("dhcp.enabled") {
+		ports = append(ports,
+			doctor.Port{Name: "DHCP", Network: "udp", Port: 67},
+			doctor.Port{Name: "PXE", Network: "udp", Port: 4011},
+		)
+	}
+	if viper.GetBool("nbd_enabled") {
+		ports = append(ports, doctor.Port{Name: "NBD", Network: "tcp", Port: viper.GetInt("nbd_port"), Flag: "--nbd-port"})
+	}
+	if viper.GetBool("iscsi_enabled") {
+		ports = append(ports, doctor.Port{Name: "iSCSI", Network: "tcp", Port: viper.GetInt("iscsi_port"), Flag: "--iscsi-port"})
+	}
+	if viper.GetBool("nfs_enabled") {
+		ports = append(ports, doctor.Port{Name: "NFS", Network: "tcp", Port: viper.GetInt("nfs_port"), Flag: "--nfs-port"})
+	}
+
+	dataDir := viper.GetString("data_dir")
+	cfg := doctor.Config{
+		Ports:      ports,
+		DataDir:    dataDir,
+		ServerAddr: hostIPOptions(),
+	}
+	if pgHost := viper.GetString("db.host"); pgHost != "" {
+		cfg.DatabaseName = fmt.Sprintf("PostgreSQL at %s:%d", pgHost, viper.GetInt("db.port"))
+		cfg.OpenDatabase = func() error {
+			store, err := storage.NewPostgresStore(&storage.Config{
+				Host:     pgHost,
+				Port:     viper.GetInt("db.port"),
+				User:     viper.GetString("db.user"),
+				Password: dbPassword(),
+				DBName:   viper.GetString("db.name"),
+				SSLMode:  viper.GetString("db.sslmode"),
+			})
+			if err != nil {
+				return err
+			}
+			return store.Close()
+		}
+	} else {
+		cfg.DatabaseName = "SQLite at " + filepath.Join(dataDir, "bootimus.db")
+		cfg.OpenDatabase = func() error {
+			if err := os.MkdirAll(dataDir, 0755); err != nil {
+				return err
+			}
+			store, err := storage.NewSQLiteStore(dataDir)
+			if err != nil {
+				return err
+			}
+			return store.Close()
+		}
+	}
+
+	results := doctor.Run(cfg)
+	for _, r := range results {
+		fmt.Printf("[%s] %s: %s\n", r.Status, r.Check, r.Detail)
+		if r.Fix != "" {
+			fmt.Printf("       -> %s\n", r.Fix)
+		}
+	}
+	if doctor.Failed(results) {
+		fmt.Println("\nSome checks failed; fix them before running \"bootimus serve\".")
+		os.Exit(1)
+	}
+	fmt.Println("\nAll checks passed.")
+}
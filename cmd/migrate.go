@@ -25,7 +25,7 @@ func runMigrate(cmd *cobra.Command, args []string) {
 		Host:     viper.GetString("db.host"),
 		Port:     viper.GetInt("db.port"),
 		User:     viper.GetString("db.user"),
-		Password: viper.GetString("db.password"),
+		Password: dbPassword(),
 		DBName:   viper.GetString("db.name"),
 		SSLMode:  viper.GetString("db.sslmode"),
 	}
@@ -4,8 +4,14 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"bootimus/internal/accesslog"
+	"bootimus/internal/dns"
+	"bootimus/internal/hooks"
+	"bootimus/internal/ntp"
 	"bootimus/internal/proxydhcp"
+	"bootimus/internal/ratelimit"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -37,14 +43,32 @@ func init() {
 
 	rootCmd.PersistentFlags().Int("tftp-port", 69, "TFTP server port")
 	rootCmd.PersistentFlags().Bool("tftp-single-port", false, "Enable TFTP single port")
+	rootCmd.PersistentFlags().Duration("tftp-timeout", 5*time.Second, "Time to wait for a TFTP client to acknowledge a block before retransmitting")
+	rootCmd.PersistentFlags().Int("tftp-retries", 5, "Transmissions of a TFTP block before the transfer is aborted")
+	rootCmd.PersistentFlags().Duration("tftp-retry-backoff", 0, "Pause before retransmitting a TFTP block (default: random, up to 1s)")
+	rootCmd.PersistentFlags().Int64("tftp-large-file-size", 1<<20, "Files of at least this many bytes are sent over TFTP with --tftp-large-file-timeout (0 disables)")
+	rootCmd.PersistentFlags().Bool("auto-kernel-boot", false, "Extract and switch to kernel boot any sanboot image whose boots keep failing, when a distro profile covers it")
+	rootCmd.PersistentFlags().Duration("tftp-large-file-timeout", 15*time.Second, "Acknowledgement timeout for large TFTP files, for slow NICs loading big EFI binaries")
 	rootCmd.PersistentFlags().Int("http-port", 8080, "HTTP server port")
+	rootCmd.PersistentFlags().Bool("http2", true, "Accept cleartext HTTP/2 with prior knowledge on the HTTP port, alongside HTTP/1.1")
+	rootCmd.PersistentFlags().Int("http2-max-streams", 250, "Requests a client may have in flight on one HTTP/2 connection")
+	rootCmd.PersistentFlags().Duration("http-idle-timeout", 2*time.Minute, "How long an idle keep-alive connection to the HTTP port stays open for the next request")
+	rootCmd.PersistentFlags().String("access-log", accesslog.DestLog, "Where requests to the HTTP port are logged: \"log\" (the server log), \"off\", or a file that gets one JSON object per line")
+	rootCmd.PersistentFlags().Int("access-log-range-sample", accesslog.DefaultRangeSample, "Log one successful range request in this many (1 logs all, 0 none); failed requests are always logged")
+	rootCmd.PersistentFlags().Bool("access-log-sinks", false, "Also forward access log entries to the log_sinks")
 	rootCmd.PersistentFlags().Int("admin-port", 8081, "Admin interface port")
 	rootCmd.PersistentFlags().Bool("nbd-enabled", true, "Enable NBD server for network block device ISO mounting")
 	rootCmd.PersistentFlags().Int("nbd-port", 10809, "NBD server port")
+	rootCmd.PersistentFlags().Bool("iscsi-enabled", false, "Enable the iSCSI target, publishing images marked for iSCSI as LUNs for iPXE sanboot")
+	rootCmd.PersistentFlags().Int("iscsi-port", 3260, "iSCSI target port")
 	rootCmd.PersistentFlags().Bool("nfs-enabled", false, "Enable in-process NFS server for streamed live-distro roots (low memory)")
 	rootCmd.PersistentFlags().Int("nfs-port", 2049, "NFS server port (also used as mountport)")
 	rootCmd.PersistentFlags().String("data-dir", "./data", "Base data directory (subdirs: isos/, bootloaders/)")
 	rootCmd.PersistentFlags().String("server-addr", "", "Server IP address (auto-detected if not specified)")
+	rootCmd.PersistentFlags().String("server-iface", "", "Advertise the IPv4 address of this interface, e.g. eth1 (when --server-addr is not set)")
+	rootCmd.PersistentFlags().String("server-cidr", "", "Advertise the local address inside this network, e.g. 192.168.10.0/24 (when --server-addr is not set)")
+	rootCmd.PersistentFlags().Bool("container-network-check", true, "Warn when the server address is a container's bridge-network address that PXE clients cannot reach")
+	rootCmd.PersistentFlags().String("server-relay", "", "Advertise the local address that routes to this DHCP relay, for clients on another subnet")
 
 	rootCmd.PersistentFlags().String("db-host", "", "PostgreSQL host (if empty, uses SQLite)")
 	rootCmd.PersistentFlags().Int("db-port", 5432, "PostgreSQL port")
@@ -66,25 +90,85 @@ func init() {
 	rootCmd.PersistentFlags().String("ldap-group-base-dn", "", "LDAP base DN for group search")
 
 	rootCmd.PersistentFlags().Bool("disable-remote-profiles", false, "Disable remote distro profile updates")
+	rootCmd.PersistentFlags().Duration("catalog-refresh", 0, "How often the ISO catalog, its custom sources and release checksums are fetched (0 only on request from the admin UI)")
+	rootCmd.PersistentFlags().Bool("telemetry", false, "Send each month's anonymous usage report (boot counts by distro and method, features in use) to --telemetry-url; reports are written to <data-dir>/reports either way")
+	rootCmd.PersistentFlags().String("telemetry-url", "", "URL usage reports are POSTed to as JSON when --telemetry is set")
 
 	rootCmd.PersistentFlags().Bool("proxy-dhcp", false, "Enable in-process proxyDHCP server (answers PXE requests without handing out IPs; requires root or CAP_NET_BIND_SERVICE)")
 	rootCmd.PersistentFlags().String("proxy-dhcp-bootfile-bios", proxydhcp.DefaultBootfileBIOS, "Bootfile advertised to legacy BIOS PXE clients (default follows the active bootloader set's manifest)")
 	rootCmd.PersistentFlags().String("proxy-dhcp-bootfile-uefi", proxydhcp.DefaultBootfileUEFI, "Bootfile advertised to UEFI x64 PXE clients (default follows the active bootloader set's manifest)")
 	rootCmd.PersistentFlags().String("proxy-dhcp-bootfile-arm64", proxydhcp.DefaultBootfileARM64, "Bootfile advertised to UEFI ARM64 PXE clients (default follows the active bootloader set's manifest)")
+	rootCmd.PersistentFlags().Bool("dhcp", false, "Run a full DHCP server that hands out addresses from --dhcp-range, for networks with no DHCP server of their own (replaces proxyDHCP on UDP/67)")
+	rootCmd.PersistentFlags().String("dhcp-range", "", "Addresses the DHCP server leases, e.g. 192.168.1.100-192.168.1.200")
+	rootCmd.PersistentFlags().String("dhcp-netmask", "", "Subnet mask handed to DHCP clients (default: the range's classful mask)")
+	rootCmd.PersistentFlags().String("dhcp-router", "", "Default gateway handed to DHCP clients")
+	rootCmd.PersistentFlags().StringSlice("dhcp-dns", nil, "DNS servers handed to DHCP clients (default: the embedded DNS responder, when on)")
+	rootCmd.PersistentFlags().Duration("dhcp-lease-time", proxydhcp.DefaultLeaseTime, "DHCP lease duration")
+	rootCmd.PersistentFlags().Bool("mdns", false, "Advertise the boot menu and admin UI over mDNS/DNS-SD (_bootimus._tcp, _http._tcp)")
+	rootCmd.PersistentFlags().Bool("dns", false, "Enable the embedded DNS responder for provisioning networks without DNS (advertised through proxyDHCP when both are on)")
+	rootCmd.PersistentFlags().Int("dns-port", dns.DefaultPort, "UDP port for the embedded DNS responder")
+	rootCmd.PersistentFlags().String("dns-zone", "", "Local zone the DNS responder answers for; bootimus.<zone> resolves to this server")
+	rootCmd.PersistentFlags().String("dns-wildcard", "", "IPv4 address returned for every other name, e.g. the mirror proxy")
+	rootCmd.PersistentFlags().Bool("ntp", false, "Serve this host's clock over SNTP so installers on isolated networks get a sane time (advertised through proxyDHCP and iPXE)")
+	rootCmd.PersistentFlags().Int("ntp-port", ntp.DefaultPort, "UDP port for the NTP responder")
 
 	rootCmd.PersistentFlags().Bool("windows-smb", false, "Enable Samba share for unattended Windows PXE installs (requires smbd in PATH)")
 	rootCmd.PersistentFlags().Int("windows-smb-port", 445, "SMB port (Windows 'net use' always uses 445; override only for testing)")
 
+	rootCmd.PersistentFlags().StringSlice("bootloader-signing-key", nil, "Ed25519 public key (file path or PEM/base64) trusted to sign bootloader update bundles; repeatable")
+	rootCmd.PersistentFlags().Bool("require-attestation", false, "Only offer private images to clients that passed TPM attestation")
+	rootCmd.PersistentFlags().Duration("attestation-ttl", time.Hour, "How long a passing TPM attestation lets a client see and download private images")
+	rootCmd.PersistentFlags().String("hook-pre-menu", "", "Command run before a client's boot menu is rendered, with the event JSON on stdin (the menu waits for it)")
+	rootCmd.PersistentFlags().String("hook-post-boot-select", "", "Command run in the background once a client starts booting an image")
+	rootCmd.PersistentFlags().String("hook-post-install", "", "Command run in the background when an installed system calls /callback/boot-complete")
+	rootCmd.PersistentFlags().Duration("hook-timeout", hooks.DefaultTimeout, "Time a hook may run before it is killed")
+	rootCmd.PersistentFlags().String("menu-pin", "", "Numeric PIN the boot menu asks for before booting PIN-protected images and groups (protection is off when empty)")
+	rootCmd.PersistentFlags().String("boot-token", "", "Token clients must present (?token= or basic-auth password) to fetch the boot menu and auto-install data; bootloaders stay open")
+	rootCmd.PersistentFlags().StringSlice("failover-url", nil, "Base URL of another Bootimus serving the same images (e.g. http://10.0.0.3:8080); menus retry failed fetches from it. Repeatable, tried in order")
+	rootCmd.PersistentFlags().Bool("fix-orphans", false, "Repair boot logs, assignments and group memberships pointing at deleted records during the daily check, instead of only logging them")
+	rootCmd.PersistentFlags().Duration("verify-interval", 30*24*time.Hour, "How often each local ISO is rehashed and compared with its stored checksum to catch storage corruption (0 disables)")
+	rootCmd.PersistentFlags().Duration("wim-backup-max-age", 0, "Delete the pristine boot.wim kept by a driver rebuild once it is this old, making the injected one the new baseline (0 keeps it)")
+	rootCmd.PersistentFlags().Int("max-downloads", 2, "ISO downloads run at once; more wait in a queue")
+	rootCmd.PersistentFlags().String("download-rate-limit", "", "Bandwidth all ISO downloads share, e.g. 20MB (per second; empty for no cap)")
+	rootCmd.PersistentFlags().String("download-rate-limit-each", "", "Bandwidth each ISO download gets unless it asks for its own, e.g. 5MB (empty for no cap)")
+	rootCmd.PersistentFlags().Float64("admin-rate-limit", ratelimit.DefaultRate, "Requests per second each IP may make to the admin port (0 disables the limit)")
+	rootCmd.PersistentFlags().Int("admin-rate-burst", ratelimit.DefaultBurst, "Requests an IP may make to the admin port in a burst before the rate limit applies")
+	rootCmd.PersistentFlags().Int("admin-login-failures", ratelimit.DefaultMaxFailures, "Failed logins from an IP before it is locked out for exponentially longer each time (0 disables lockouts)")
+	rootCmd.PersistentFlags().Duration("admin-max-lockout", ratelimit.DefaultMaxBackoff, "Longest lockout after repeated failed logins")
+	rootCmd.PersistentFlags().String("secrets-key-file", "", "File holding the key that encrypts BMC passwords, registry credentials and other secrets in the database (created if missing; encryption is off when empty)")
+	rootCmd.PersistentFlags().String("secrets-kms-command", "", "Command that wraps (BOOTIMUS_KMS_OP=wrap) and unwraps data keys with a KMS, base64 on stdin and stdout; used instead of the key file for new secrets")
+	rootCmd.PersistentFlags().Bool("log-redaction", true, "Mask passwords, tokens and other secrets in logs")
+	rootCmd.PersistentFlags().StringSlice("redact-allow", nil, "Setting or environment key shown unmasked in logs and server info although its name looks secret (e.g. BOOTIMUS_LDAP_BIND_PASSWORD_FILE); repeatable")
+	rootCmd.PersistentFlags().String("policy-file", "", "Boot policy rules evaluated for every menu request (default: <data-dir>/policy.rules)")
+	rootCmd.PersistentFlags().String("matchbox-dir", "", "Matchbox data directory (profiles/, groups/, ignition/, ...) served on Matchbox's endpoints (default: <data-dir>/matchbox)")
+
 	viper.BindPFlag("tftp_port", rootCmd.PersistentFlags().Lookup("tftp-port"))
 	viper.BindPFlag("tftp_single_port", rootCmd.PersistentFlags().Lookup("tftp-single-port"))
+	viper.BindPFlag("tftp_timeout", rootCmd.PersistentFlags().Lookup("tftp-timeout"))
+	viper.BindPFlag("tftp_retries", rootCmd.PersistentFlags().Lookup("tftp-retries"))
+	viper.BindPFlag("tftp_retry_backoff", rootCmd.PersistentFlags().Lookup("tftp-retry-backoff"))
+	viper.BindPFlag("tftp_large_file_size", rootCmd.PersistentFlags().Lookup("tftp-large-file-size"))
+	viper.BindPFlag("tftp_large_file_timeout", rootCmd.PersistentFlags().Lookup("tftp-large-file-timeout"))
 	viper.BindPFlag("http_port", rootCmd.PersistentFlags().Lookup("http-port"))
+	viper.BindPFlag("http2", rootCmd.PersistentFlags().Lookup("http2"))
+	viper.BindPFlag("http2_max_streams", rootCmd.PersistentFlags().Lookup("http2-max-streams"))
+	viper.BindPFlag("http_idle_timeout", rootCmd.PersistentFlags().Lookup("http-idle-timeout"))
+	viper.BindPFlag("access_log.dest", rootCmd.PersistentFlags().Lookup("access-log"))
+	viper.BindPFlag("access_log.range_sample", rootCmd.PersistentFlags().Lookup("access-log-range-sample"))
+	viper.BindPFlag("access_log.sinks", rootCmd.PersistentFlags().Lookup("access-log-sinks"))
 	viper.BindPFlag("admin_port", rootCmd.PersistentFlags().Lookup("admin-port"))
 	viper.BindPFlag("nbd_enabled", rootCmd.PersistentFlags().Lookup("nbd-enabled"))
 	viper.BindPFlag("nbd_port", rootCmd.PersistentFlags().Lookup("nbd-port"))
+	viper.BindPFlag("iscsi_enabled", rootCmd.PersistentFlags().Lookup("iscsi-enabled"))
+	viper.BindPFlag("iscsi_port", rootCmd.PersistentFlags().Lookup("iscsi-port"))
 	viper.BindPFlag("nfs_enabled", rootCmd.PersistentFlags().Lookup("nfs-enabled"))
 	viper.BindPFlag("nfs_port", rootCmd.PersistentFlags().Lookup("nfs-port"))
 	viper.BindPFlag("data_dir", rootCmd.PersistentFlags().Lookup("data-dir"))
 	viper.BindPFlag("server_addr", rootCmd.PersistentFlags().Lookup("server-addr"))
+	viper.BindPFlag("server_iface", rootCmd.PersistentFlags().Lookup("server-iface"))
+	viper.BindPFlag("server_cidr", rootCmd.PersistentFlags().Lookup("server-cidr"))
+	viper.BindPFlag("server_relay", rootCmd.PersistentFlags().Lookup("server-relay"))
+	viper.BindPFlag("container_network_check", rootCmd.PersistentFlags().Lookup("container-network-check"))
 	viper.BindPFlag("db.host", rootCmd.PersistentFlags().Lookup("db-host"))
 	viper.BindPFlag("db.port", rootCmd.PersistentFlags().Lookup("db-port"))
 	viper.BindPFlag("db.user", rootCmd.PersistentFlags().Lookup("db-user"))
@@ -105,14 +189,58 @@ func init() {
 	viper.BindPFlag("ldap.group_base_dn", rootCmd.PersistentFlags().Lookup("ldap-group-base-dn"))
 
 	viper.BindPFlag("disable_remote_profiles", rootCmd.PersistentFlags().Lookup("disable-remote-profiles"))
+	viper.BindPFlag("catalog.refresh", rootCmd.PersistentFlags().Lookup("catalog-refresh"))
+	viper.BindPFlag("telemetry.enabled", rootCmd.PersistentFlags().Lookup("telemetry"))
+	viper.BindPFlag("telemetry.url", rootCmd.PersistentFlags().Lookup("telemetry-url"))
 
 	viper.BindPFlag("proxy_dhcp.enabled", rootCmd.PersistentFlags().Lookup("proxy-dhcp"))
 	viper.BindPFlag("proxy_dhcp.bootfile_bios", rootCmd.PersistentFlags().Lookup("proxy-dhcp-bootfile-bios"))
 	viper.BindPFlag("proxy_dhcp.bootfile_uefi", rootCmd.PersistentFlags().Lookup("proxy-dhcp-bootfile-uefi"))
 	viper.BindPFlag("proxy_dhcp.bootfile_arm64", rootCmd.PersistentFlags().Lookup("proxy-dhcp-bootfile-arm64"))
+	viper.BindPFlag("dhcp.enabled", rootCmd.PersistentFlags().Lookup("dhcp"))
+	viper.BindPFlag("dhcp.range", rootCmd.PersistentFlags().Lookup("dhcp-range"))
+	viper.BindPFlag("dhcp.netmask", rootCmd.PersistentFlags().Lookup("dhcp-netmask"))
+	viper.BindPFlag("dhcp.router", rootCmd.PersistentFlags().Lookup("dhcp-router"))
+	viper.BindPFlag("dhcp.dns", rootCmd.PersistentFlags().Lookup("dhcp-dns"))
+	viper.BindPFlag("dhcp.lease_time", rootCmd.PersistentFlags().Lookup("dhcp-lease-time"))
+	viper.BindPFlag("mdns.enabled", rootCmd.PersistentFlags().Lookup("mdns"))
+	viper.BindPFlag("dns.enabled", rootCmd.PersistentFlags().Lookup("dns"))
+	viper.BindPFlag("dns.port", rootCmd.PersistentFlags().Lookup("dns-port"))
+	viper.BindPFlag("dns.zone", rootCmd.PersistentFlags().Lookup("dns-zone"))
+	viper.BindPFlag("dns.wildcard", rootCmd.PersistentFlags().Lookup("dns-wildcard"))
+	viper.BindPFlag("ntp.enabled", rootCmd.PersistentFlags().Lookup("ntp"))
+	viper.BindPFlag("ntp.port", rootCmd.PersistentFlags().Lookup("ntp-port"))
 
 	viper.BindPFlag("windows_smb.enabled", rootCmd.PersistentFlags().Lookup("windows-smb"))
 	viper.BindPFlag("windows_smb.port", rootCmd.PersistentFlags().Lookup("windows-smb-port"))
+
+	viper.BindPFlag("bootloader_signing_keys", rootCmd.PersistentFlags().Lookup("bootloader-signing-key"))
+	viper.BindPFlag("require_attestation", rootCmd.PersistentFlags().Lookup("require-attestation"))
+	viper.BindPFlag("attestation_ttl", rootCmd.PersistentFlags().Lookup("attestation-ttl"))
+	viper.BindPFlag("boot_token", rootCmd.PersistentFlags().Lookup("boot-token"))
+	viper.BindPFlag("menu_pin", rootCmd.PersistentFlags().Lookup("menu-pin"))
+	viper.BindPFlag("failover_urls", rootCmd.PersistentFlags().Lookup("failover-url"))
+	viper.BindPFlag("maintenance.fix_orphans", rootCmd.PersistentFlags().Lookup("fix-orphans"))
+	viper.BindPFlag("maintenance.verify_interval", rootCmd.PersistentFlags().Lookup("verify-interval"))
+	viper.BindPFlag("maintenance.wim_backup_max_age", rootCmd.PersistentFlags().Lookup("wim-backup-max-age"))
+	viper.BindPFlag("max_downloads", rootCmd.PersistentFlags().Lookup("max-downloads"))
+	viper.BindPFlag("download_rate_limit", rootCmd.PersistentFlags().Lookup("download-rate-limit"))
+	viper.BindPFlag("download_rate_limit_each", rootCmd.PersistentFlags().Lookup("download-rate-limit-each"))
+	viper.BindPFlag("maintenance.auto_kernel_boot", rootCmd.PersistentFlags().Lookup("auto-kernel-boot"))
+	viper.BindPFlag("admin_rate_limit.rate", rootCmd.PersistentFlags().Lookup("admin-rate-limit"))
+	viper.BindPFlag("admin_rate_limit.burst", rootCmd.PersistentFlags().Lookup("admin-rate-burst"))
+	viper.BindPFlag("admin_rate_limit.max_failures", rootCmd.PersistentFlags().Lookup("admin-login-failures"))
+	viper.BindPFlag("admin_rate_limit.max_backoff", rootCmd.PersistentFlags().Lookup("admin-max-lockout"))
+	viper.BindPFlag("secrets.key_file", rootCmd.PersistentFlags().Lookup("secrets-key-file"))
+	viper.BindPFlag("secrets.kms_command", rootCmd.PersistentFlags().Lookup("secrets-kms-command"))
+	viper.BindPFlag("log_redaction", rootCmd.PersistentFlags().Lookup("log-redaction"))
+	viper.BindPFlag("redact_allow", rootCmd.PersistentFlags().Lookup("redact-allow"))
+	viper.BindPFlag("policy_file", rootCmd.PersistentFlags().Lookup("policy-file"))
+	viper.BindPFlag("matchbox_dir", rootCmd.PersistentFlags().Lookup("matchbox-dir"))
+	viper.BindPFlag("hooks.pre_menu", rootCmd.PersistentFlags().Lookup("hook-pre-menu"))
+	viper.BindPFlag("hooks.post_boot_select", rootCmd.PersistentFlags().Lookup("hook-post-boot-select"))
+	viper.BindPFlag("hooks.post_install", rootCmd.PersistentFlags().Lookup("hook-post-install"))
+	viper.BindPFlag("hooks.timeout", rootCmd.PersistentFlags().Lookup("hook-timeout"))
 }
 
 func initConfig() {
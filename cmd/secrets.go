@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"bootimus/internal/secrets"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var secretsKeepOld bool
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage encryption of secrets stored in the database",
+	Long: `BMC passwords, registry credentials, the installer password hash, webhook
+URLs, disk task tokens and revision snapshots are encrypted in the database
+once --secrets-key-file or --secrets-kms-command is set.`,
+}
+
+var secretsKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Print a new key in key file format",
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		fmt.Println(key)
+	},
+}
+
+var secretsEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt a value read from stdin, e.g. db.password for the config file",
+	Run: func(cmd *cobra.Command, args []string) {
+		setupSecrets()
+		if secrets.Default() == nil {
+			log.Fatal("No secrets key configured (--secrets-key-file or --secrets-kms-command)")
+		}
+		value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && value == "" {
+			log.Fatalf("Failed to read value: %v", err)
+		}
+		enc, err := secrets.Encrypt(strings.TrimRight(value, "\r\n"))
+		if err != nil {
+			log.Fatalf("Failed to encrypt: %v", err)
+		}
+		fmt.Println(enc)
+	},
+}
+
+var secretsRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Re-encrypt all secrets under a new key",
+	Long: `With a key file, adds a new key to the front of it, re-encrypts every secret
+under that key and then removes the old keys (unless --keep-old).
+
+With a KMS command, re-encrypts every secret under a new data key wrapped by
+the KMS. Rotate the key in the KMS first. If a key file is configured as well,
+its keys are only used to read existing values, so this also moves secrets
+from the key file to the KMS.
+
+Encrypted values in the config file (db.password) must be re-encrypted by hand
+with "bootimus secrets encrypt" before the old key is removed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rotateSecrets()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsKeygenCmd)
+	secretsCmd.AddCommand(secretsEncryptCmd)
+	secretsCmd.AddCommand(secretsRotateCmd)
+	secretsRotateCmd.Flags().BoolVar(&secretsKeepOld, "keep-old", false, "Keep the old keys in the key file")
+}
+
+var setupSecretsOnce sync.Once
+
+// setupSecrets installs the keyring from --secrets-kms-command and
+// --secrets-key-file. A key file that does not exist yet is created.
+func setupSecrets() {
+	setupSecretsOnce.Do(func() {
+		keks, err := loadKEKs(true)
+		if err != nil {
+			log.Fatalf("Secrets: %v", err)
+		}
+		if len(keks) == 0 {
+			return
+		}
+		keyring, err := secrets.NewKeyring(keks...)
+		if err != nil {
+			log.Fatalf("Secrets: %v", err)
+		}
+		secrets.SetDefault(keyring)
+		log.Printf("Secrets: Encrypting database secrets with key %s", keyring.Primary())
+	})
+}
+
+func loadKEKs(create bool) ([]secrets.KEK, error) {
+	var keks []secrets.KEK
+	if command := viper.GetString("secrets.kms_command"); command != "" {
+		keks = append(keks, secrets.CommandKEK{KeyID: "kms", Command: command})
+	}
+	path := viper.GetString("secrets.key_file")
+	if path == "" {
+		return keks, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && create && len(keks) == 0 {
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+			return nil, fmt.Errorf("creating key file: %w", err)
+		}
+		log.Printf("Secrets: Created key file %s. Back it up: database secrets cannot be read without it", path)
+		data = []byte(key)
+	} else if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0077 != 0 {
+		log.Printf("Secrets: Warning: %s is readable by other users", path)
+	}
+	fileKEKs, err := secrets.ParseKeyFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return append(keks, fileKEKs...), nil
+}
+
+// dbPassword is db.password, which may be encrypted with "bootimus secrets
+// encrypt".
+func dbPassword() string {
+	password := viper.GetString("db.password")
+	if !secrets.IsEncrypted(password) {
+		return password
+	}
+	setupSecrets()
+	plain, err := secrets.Decrypt(password)
+	if err != nil {
+		log.Fatalf("Failed to decrypt db.password: %v", err)
+	}
+	return plain
+}
+
+func rotateSecrets() {
+	setupSecrets()
+	keks, err := loadKEKs(false)
+	if err != nil {
+		log.Fatalf("Secrets: %v", err)
+	}
+	if len(keks) == 0 {
+		log.Fatal("No secrets key configured (--secrets-key-file or --secrets-kms-command)")
+	}
+
+	path := viper.GetString("secrets.key_file")
+	usingKMS := viper.GetString("secrets.kms_command") != ""
+	var newKey string
+	if !usingKMS {
+		if newKey, err = secrets.GenerateKey(); err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		newKEKs, err := secrets.ParseKeyFile([]byte(newKey))
+		if err != nil {
+			log.Fatalf("Secrets: %v", err)
+		}
+		keks = append(newKEKs, keks...)
+		// The new key is saved before anything is encrypted with it.
+		if err := writeKeyFile(path, keks, newKey); err != nil {
+			log.Fatalf("Failed to update key file: %v", err)
+		}
+	}
+	keyring, err := secrets.NewKeyring(keks...)
+	if err != nil {
+		log.Fatalf("Secrets: %v", err)
+	}
+	secrets.SetDefault(keyring)
+
+	store := openStore()
+	defer store.Close()
+	n, err := store.RewriteSecrets()
+	if err != nil {
+		log.Fatalf("Failed to re-encrypt secrets (the old keys are still in place): %v", err)
+	}
+	fmt.Printf("Re-encrypted %d secrets under key %s\n", n, keyring.Primary())
+
+	if !usingKMS && !secretsKeepOld {
+		if err := writeKeyFile(path, keks[:1], newKey); err != nil {
+			log.Fatalf("Failed to remove old keys from key file: %v", err)
+		}
+		fmt.Printf("Removed %d old keys from %s\n", len(keks)-1, path)
+	}
+}
+
+// writeKeyFile writes newKey followed by the rest of keks, which are
+// StaticKEKs read from the key file.
+func writeKeyFile(path string, keks []secrets.KEK, newKey string) error {
+	var sb strings.Builder
+	sb.WriteString(newKey + "\n")
+	for _, k := range keks[1:] {
+		if s, ok := k.(secrets.StaticKEK); ok {
+			fmt.Fprintf(&sb, "%s %s\n", s.KeyID, base64.StdEncoding.EncodeToString(s.Key))
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
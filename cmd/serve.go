@@ -9,9 +9,15 @@ import (
 	"time"
 
 	"bootimus/internal/auth"
+	"bootimus/internal/hooks"
+	"bootimus/internal/hostip"
 	"bootimus/internal/profiles"
+	"bootimus/internal/ratelimit"
+	"bootimus/internal/redact"
+	"bootimus/internal/secrets"
 	"bootimus/internal/server"
 	"bootimus/internal/storage"
+	"bootimus/internal/throttle"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -51,7 +57,9 @@ func printBanner() {
 }
 
 func runServe(cmd *cobra.Command, args []string) {
-	server.InitGlobalLogger()
+	redact.Allow(viper.GetStringSlice("redact_allow")...)
+	redact.AddSecret(viper.GetString("ldap.bind_password"), viper.GetString("boot_token"))
+	server.InitGlobalLogger(viper.GetBool("log_redaction"))
 
 	printBanner()
 
@@ -70,14 +78,28 @@ func runServe(cmd *cobra.Command, args []string) {
 	log.Printf("  - ISOs: %s", isoDir)
 	log.Printf("  - Bootloaders: %s", bootloadersDir)
 
-	serverAddr := viper.GetString("server_addr")
-	if serverAddr == "" {
-		serverAddr = server.GetOutboundIP()
-		log.Printf("Auto-detected server IP: %s", serverAddr)
+	addr, err := hostip.Detect(hostIPOptions())
+	if err != nil {
+		log.Fatalf("Failed to pick the server address: %v", err)
+	}
+	serverAddr := addr.IP
+	log.Printf("Server address: %s", addr)
+	var networkWarning string
+	if viper.GetBool("container_network_check") {
+		networkWarning = hostip.ContainerWarning(serverAddr)
+	}
+	switch {
+	case networkWarning != "":
+		log.Printf("================================================================")
+		log.Printf("WARNING: %s", networkWarning)
+		log.Printf("================================================================")
+	case addr.Strategy == hostip.StrategyRoute || addr.Strategy == hostip.StrategyFirstLAN:
+		log.Printf("If boot clients cannot reach %s, set --server-addr, --server-iface or --server-cidr", serverAddr)
 	}
 
+	setupSecrets()
+
 	var store storage.Storage
-	var err error
 
 	pgHost := viper.GetString("db.host")
 	if pgHost != "" {
@@ -85,10 +107,11 @@ func runServe(cmd *cobra.Command, args []string) {
 			Host:     pgHost,
 			Port:     viper.GetInt("db.port"),
 			User:     viper.GetString("db.user"),
-			Password: viper.GetString("db.password"),
+			Password: dbPassword(),
 			DBName:   viper.GetString("db.name"),
 			SSLMode:  viper.GetString("db.sslmode"),
 		}
+		redact.AddSecret(dbCfg.Password)
 
 		log.Printf("Connecting to PostgreSQL database at %s:%d...", pgHost, viper.GetInt("db.port"))
 
@@ -130,6 +153,14 @@ func runServe(cmd *cobra.Command, args []string) {
 		log.Printf("Local database initialized at %s/bootimus.db (SQLite)", dataDir)
 	}
 
+	if secrets.Default() != nil {
+		if n, err := store.RewriteSecrets(); err != nil {
+			log.Fatalf("Failed to encrypt database secrets: %v", err)
+		} else if n > 0 {
+			log.Printf("Secrets: Encrypted %d stored secrets under key %s", n, secrets.Default().Primary())
+		}
+	}
+
 	if resetAdminPassword {
 		password, err := store.ResetAdminPassword()
 		if err != nil {
@@ -183,7 +214,18 @@ func runServe(cmd *cobra.Command, args []string) {
 		TFTPPort:         viper.GetInt("tftp_port"),
 		TFTPSinglePort:   viper.GetBool("tftp_single_port"),
 		TFTPBlockSize:    viper.GetInt("tftp_block_size"),
+		TFTPTimeout:      viper.GetDuration("tftp_timeout"),
+		TFTPRetries:      viper.GetInt("tftp_retries"),
+		TFTPRetryBackoff: viper.GetDuration("tftp_retry_backoff"),
+		TFTPLargeFile:    viper.GetInt64("tftp_large_file_size"),
+		TFTPLargeTimeout: viper.GetDuration("tftp_large_file_timeout"),
+		BootToken:        viper.GetString("boot_token"),
+		MenuPIN:          viper.GetString("menu_pin"),
+		FailoverURLs:     viper.GetStringSlice("failover_urls"),
 		HTTPPort:         viper.GetInt("http_port"),
+		HTTP2:            viper.GetBool("http2"),
+		HTTP2MaxStreams:  viper.GetInt("http2_max_streams"),
+		HTTPIdleTimeout:  viper.GetDuration("http_idle_timeout"),
 		AdminPort:        viper.GetInt("admin_port"),
 		BootDir:          bootloadersDir,
 		DataDir:          dataDir,
@@ -193,6 +235,8 @@ func runServe(cmd *cobra.Command, args []string) {
 		Auth:             authMgr,
 		NBDEnabled:       viper.GetBool("nbd_enabled"),
 		NBDPort:          viper.GetInt("nbd_port"),
+		ISCSIEnabled:     viper.GetBool("iscsi_enabled"),
+		ISCSIPort:        viper.GetInt("iscsi_port"),
 		NFSEnabled:       viper.GetBool("nfs_enabled"),
 		NFSPort:          viper.GetInt("nfs_port"),
 		WOLBroadcastAddr: viper.GetString("wol_broadcast_addr"),
@@ -203,8 +247,81 @@ func runServe(cmd *cobra.Command, args []string) {
 		ProxyDHCPBootfileUEFI: viper.GetString("proxy_dhcp.bootfile_uefi"),
 		ProxyDHCPBootfileARM:  viper.GetString("proxy_dhcp.bootfile_arm64"),
 
+		DHCPEnabled:   viper.GetBool("dhcp.enabled"),
+		DHCPRange:     viper.GetString("dhcp.range"),
+		DHCPNetmask:   viper.GetString("dhcp.netmask"),
+		DHCPRouter:    viper.GetString("dhcp.router"),
+		DHCPDNS:       viper.GetStringSlice("dhcp.dns"),
+		DHCPLeaseTime: viper.GetDuration("dhcp.lease_time"),
+
 		WindowsSMBEnabled: viper.GetBool("windows_smb.enabled"),
 		WindowsSMBPort:    viper.GetInt("windows_smb.port"),
+
+		MDNSEnabled: viper.GetBool("mdns.enabled"),
+		DNSEnabled:  viper.GetBool("dns.enabled"),
+		DNSPort:     viper.GetInt("dns.port"),
+		DNSZone:     viper.GetString("dns.zone"),
+		DNSHosts:    viper.GetStringSlice("dns.hosts"),
+		DNSWildcard: viper.GetString("dns.wildcard"),
+		NTPEnabled:  viper.GetBool("ntp.enabled"),
+		NTPPort:     viper.GetInt("ntp.port"),
+
+		BootloaderSigningKeys: viper.GetStringSlice("bootloader_signing_keys"),
+		RequireAttestation:    viper.GetBool("require_attestation"),
+		AttestationTTL:        viper.GetDuration("attestation_ttl"),
+		MatchboxDir:           viper.GetString("matchbox_dir"),
+		PolicyFile:            viper.GetString("policy_file"),
+		Hooks: map[string]string{
+			hooks.PreMenu:        viper.GetString("hooks.pre_menu"),
+			hooks.PostBootSelect: viper.GetString("hooks.post_boot_select"),
+			hooks.PostInstall:    viper.GetString("hooks.post_install"),
+		},
+		HookTimeout:     viper.GetDuration("hooks.timeout"),
+		FixOrphans:      viper.GetBool("maintenance.fix_orphans"),
+		VerifyInterval:  viper.GetDuration("maintenance.verify_interval"),
+		AutoKernelBoot:  viper.GetBool("maintenance.auto_kernel_boot"),
+		WimBackupMaxAge: viper.GetDuration("maintenance.wim_backup_max_age"),
+		AdminRateLimit: ratelimit.Config{
+			Rate:        viper.GetFloat64("admin_rate_limit.rate"),
+			Burst:       viper.GetInt("admin_rate_limit.burst"),
+			MaxFailures: viper.GetInt("admin_rate_limit.max_failures"),
+			MaxBackoff:  viper.GetDuration("admin_rate_limit.max_backoff"),
+		},
+	}
+	cfg.MaxDownloads = viper.GetInt("max_downloads")
+	for key, rate := range map[string]*int64{"download_rate_limit": &cfg.DownloadRateLimit, "download_rate_limit_each": &cfg.DownloadRateLimitEach} {
+		var err error
+		if *rate, err = throttle.ParseRate(viper.GetString(key)); err != nil {
+			log.Printf("Warning: Ignoring %s: %v", key, err)
+		}
+	}
+	if err := viper.UnmarshalKey("switchport", &cfg.Switchport); err != nil {
+		log.Printf("Warning: Invalid switchport configuration: %v", err)
+	}
+	if err := viper.UnmarshalKey("alerts", &cfg.Alerts); err != nil {
+		log.Printf("Warning: Invalid alerts configuration: %v", err)
+	}
+	if err := viper.UnmarshalKey("libraries", &cfg.Libraries); err != nil {
+		log.Printf("Warning: Invalid libraries configuration: %v", err)
+	}
+	cfg.CatalogRefresh = viper.GetDuration("catalog.refresh")
+	cfg.Telemetry = viper.GetBool("telemetry.enabled")
+	cfg.TelemetryURL = viper.GetString("telemetry.url")
+	if err := viper.UnmarshalKey("catalog.sources", &cfg.CatalogSources); err != nil {
+		log.Printf("Warning: Invalid catalog.sources configuration: %v", err)
+	}
+	if err := viper.UnmarshalKey("install_proxy", &cfg.InstallProxy); err != nil {
+		log.Printf("Warning: Invalid install_proxy configuration: %v", err)
+	}
+	if err := viper.UnmarshalKey("log_sinks", &cfg.LogSinks); err != nil {
+		log.Printf("Warning: Invalid log_sinks configuration: %v", err)
+	}
+	cfg.AccessLog = viper.GetString("access_log.dest")
+	cfg.AccessLogRangeSample = viper.GetInt("access_log.range_sample")
+	cfg.AccessLogToSinks = viper.GetBool("access_log.sinks")
+	cfg.NetworkWarning = networkWarning
+	if err := viper.UnmarshalKey("admin_tls", &cfg.AdminTLS); err != nil {
+		log.Printf("Warning: Invalid admin_tls configuration: %v", err)
 	}
 
 	srv := server.New(cfg)
@@ -221,3 +338,14 @@ func runServe(cmd *cobra.Command, args []string) {
 		log.Printf("Error during shutdown: %v", err)
 	}
 }
+
+// hostIPOptions are the settings that choose the address advertised to
+// boot clients.
+func hostIPOptions() hostip.Options {
+	return hostip.Options{
+		Addr:      viper.GetString("server_addr"),
+		Interface: viper.GetString("server_iface"),
+		CIDR:      viper.GetString("server_cidr"),
+		Relay:     viper.GetString("server_relay"),
+	}
+}
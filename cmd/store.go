@@ -20,6 +20,8 @@ func openStore() storage.Storage {
 		log.Fatalf("Failed to create data directory %s: %v", dataDir, err)
 	}
 
+	setupSecrets()
+
 	var store storage.Storage
 	var err error
 
@@ -28,7 +30,7 @@ func openStore() storage.Storage {
 			Host:     pgHost,
 			Port:     viper.GetInt("db.port"),
 			User:     viper.GetString("db.user"),
-			Password: viper.GetString("db.password"),
+			Password: dbPassword(),
 			DBName:   viper.GetString("db.name"),
 			SSLMode:  viper.GetString("db.sslmode"),
 		}
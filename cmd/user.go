@@ -121,7 +121,7 @@ func openStoreOrExit() storage.Storage {
 			Host:     pgHost,
 			Port:     viper.GetInt("db.port"),
 			User:     viper.GetString("db.user"),
-			Password: viper.GetString("db.password"),
+			Password: dbPassword(),
 			DBName:   viper.GetString("db.name"),
 			SSLMode:  viper.GetString("db.sslmode"),
 		})
@@ -0,0 +1,214 @@
+// Package accesslog records a line for each request to the boot HTTP
+// server: method, path, status, bytes, duration and the client's MAC where
+// the request names one. Range requests, which a sanboot client makes by
+// the thousand, are sampled; failures are always recorded.
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRangeSample records one successful range request in this many.
+const DefaultRangeSample = 100
+
+const (
+	DestLog = "log" // the server log, as shown in the admin UI
+	DestOff = "off"
+)
+
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status"`
+	Bytes    int64     `json:"bytes"`
+	Duration float64   `json:"duration_ms"`
+	IP       string    `json:"ip"`
+	MAC      string    `json:"mac,omitempty"`
+	Range    bool      `json:"range,omitempty"`
+}
+
+type Config struct {
+	// Dest is DestLog, DestOff or a file that gets one JSON object per
+	// line.
+	Dest string
+	// RangeSample records one successful range request in this many; 1
+	// records them all, 0 none.
+	RangeSample int
+	// Publish, when set, also gets every recorded entry, e.g. for the log
+	// sinks.
+	Publish func(Entry)
+	// MAC finds the client's MAC in a request, "" when it has none.
+	MAC func(*http.Request) string
+}
+
+type Logger struct {
+	cfg    Config
+	mu     sync.Mutex
+	file   *os.File
+	ranges atomic.Uint64
+}
+
+// New opens the log. A nil Logger (from DestOff) passes requests through
+// untouched.
+func New(cfg Config) (*Logger, error) {
+	if cfg.Dest == "" {
+		cfg.Dest = DestLog
+	}
+	if cfg.Dest == DestOff && cfg.Publish == nil {
+		return nil, nil
+	}
+	l := &Logger{cfg: cfg}
+	if cfg.Dest != DestLog && cfg.Dest != DestOff {
+		f, err := os.OpenFile(cfg.Dest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+		if err != nil {
+			return nil, err
+		}
+		l.file = f
+	}
+	return l, nil
+}
+
+func (l *Logger) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Wrap records every request next serves.
+func (l *Logger) Wrap(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		e := Entry{
+			Time:     start.UTC(),
+			Method:   r.Method,
+			Path:     r.URL.Path,
+			Status:   rw.status,
+			Bytes:    rw.written,
+			Duration: float64(time.Since(start).Microseconds()) / 1000,
+			IP:       host(r.RemoteAddr),
+			Range:    r.Header.Get("Range") != "",
+		}
+		if e.Status == 0 {
+			e.Status = http.StatusOK
+		}
+		if l.cfg.MAC != nil {
+			e.MAC = l.cfg.MAC(r)
+		}
+		if l.sampled(e) {
+			l.record(e)
+		}
+	})
+}
+
+func (l *Logger) sampled(e Entry) bool {
+	if !e.Range || e.Status >= 400 {
+		return true
+	}
+	if l.cfg.RangeSample <= 0 {
+		return false
+	}
+	return (l.ranges.Add(1)-1)%uint64(l.cfg.RangeSample) == 0
+}
+
+func (l *Logger) record(e Entry) {
+	switch {
+	case l.file != nil:
+		line, err := json.Marshal(e)
+		if err != nil {
+			return
+		}
+		l.mu.Lock()
+		l.file.Write(append(line, '\n'))
+		l.mu.Unlock()
+	case l.cfg.Dest == DestLog:
+		log.Print(e.String())
+	}
+	if l.cfg.Publish != nil {
+		l.cfg.Publish(e)
+	}
+}
+
+// String is the entry as a line of the server log.
+func (e Entry) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "HTTP: %s %s %d %d bytes %.1fms ip=%s", e.Method, e.Path, e.Status, e.Bytes, e.Duration, e.IP)
+	if e.MAC != "" {
+		sb.WriteString(" mac=" + e.MAC)
+	}
+	if e.Range {
+		sb.WriteString(" range")
+	}
+	return sb.String()
+}
+
+func host(addr string) string {
+	if i := strings.LastIndex(addr, ":"); i > 0 {
+		return strings.Trim(addr[:i], "[]")
+	}
+	return addr
+}
+
+// responseWriter notes the status and counts the body bytes.
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps the server's sendfile path for handlers that copy files
+// straight to the response.
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(struct{ io.Writer }{w.ResponseWriter}, r)
+	}
+	w.written += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
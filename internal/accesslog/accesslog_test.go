@@ -0,0 +1,83 @@
+package accesslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	var published []Entry
+	l, err := New(Config{
+		Dest:        path,
+		RangeSample: 3,
+		Publish:     func(e Entry) { published = append(published, e) },
+		MAC:         func(r *http.Request) string { return r.URL.Query().Get("mac") },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := l.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+
+	serve := func(target string, ranged bool) {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if ranged {
+			r.Header.Set("Range", "bytes=100-199")
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	serve("/menu.ipxe?mac=aa:bb", false)
+	for i := 0; i < 6; i++ {
+		serve("/isos/a.iso", true)
+	}
+	serve("/missing", true)
+	l.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []Entry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, e)
+	}
+
+	// The menu, ranges 1 and 4 of 6, and the failure.
+	if len(lines) != 4 || len(published) != 4 {
+		t.Fatalf("recorded %d lines, published %d; want 4", len(lines), len(published))
+	}
+	if e := lines[0]; e.Path != "/menu.ipxe" || e.Status != 200 || e.Bytes != 5 || e.MAC != "aa:bb" || e.Range {
+		t.Errorf("menu entry = %+v", e)
+	}
+	if e := lines[3]; e.Path != "/missing" || e.Status != http.StatusNotFound || !e.Range {
+		t.Errorf("failure entry = %+v", e)
+	}
+}
+
+func TestOff(t *testing.T) {
+	l, err := New(Config{Dest: DestOff})
+	if err != nil || l != nil {
+		t.Fatalf("New(off) = %v, %v", l, err)
+	}
+	h := http.NotFoundHandler()
+	if got := l.Wrap(h); got == nil {
+		t.Fatal("nil Logger dropped the handler")
+	}
+}
@@ -0,0 +1,134 @@
+package admin
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"bootimus/internal/jobs"
+	"bootimus/internal/models"
+	"bootimus/internal/scheduler"
+)
+
+// maxRunsPerTask caps how many runs of one scheduled task the activity view
+// lists, so an every-minute task does not crowd out the rest.
+const maxRunsPerTask = 10
+
+// ActiveTransfer is a file a client is downloading right now.
+type ActiveTransfer struct {
+	IP         string    `json:"ip"`
+	Filename   string    `json:"filename"`
+	Activity   string    `json:"activity"`
+	StartedAt  time.Time `json:"started_at"`
+	BytesRead  int64     `json:"bytes_read"`
+	TotalBytes int64     `json:"total_bytes"`
+}
+
+type scheduledRun struct {
+	TaskID uint      `json:"task_id"`
+	Name   string    `json:"name"`
+	Action string    `json:"action"`
+	Param  string    `json:"param,omitempty"`
+	Group  string    `json:"group"`
+	At     time.Time `json:"at"`
+}
+
+type queuedBoot struct {
+	MAC   string `json:"mac"`
+	Name  string `json:"name,omitempty"`
+	Image string `json:"image"`
+	Once  bool   `json:"once,omitempty"` // then the local disk
+}
+
+type activityView struct {
+	At        time.Time          `json:"at"`
+	Until     time.Time          `json:"until"`
+	Transfers []ActiveTransfer   `json:"transfers"`
+	Jobs      []jobs.Info        `json:"jobs"`       // running
+	DiskTasks []*models.DiskTask `json:"disk_tasks"` // pending or running
+	NextBoots []queuedBoot       `json:"next_boots"` // clients with a one-off image queued
+	Scheduled []scheduledRun     `json:"scheduled"`  // runs due before Until, soonest first
+}
+
+// Activity is what the server is doing now and has lined up: transfers in
+// progress, running jobs, disk tasks and next-boot images waiting for their
+// clients, and scheduled task runs in the next ?hours= (24 by default).
+func (h *Handler) Activity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	hours := 24
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 24*31 {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "hours must be between 1 and 744"})
+			return
+		}
+		hours = n
+	}
+	store := h.storage.WithContext(r.Context())
+	now := time.Now()
+	view := activityView{
+		At:        now,
+		Until:     now.Add(time.Duration(hours) * time.Hour),
+		Transfers: []ActiveTransfer{},
+		Jobs:      []jobs.Info{},
+		DiskTasks: []*models.DiskTask{},
+		NextBoots: []queuedBoot{},
+		Scheduled: []scheduledRun{},
+	}
+
+	if h.Transfers != nil {
+		view.Transfers = append(view.Transfers, h.Transfers()...)
+		sort.Slice(view.Transfers, func(i, j int) bool { return view.Transfers[i].StartedAt.Before(view.Transfers[j].StartedAt) })
+	}
+	for _, j := range h.jobs.List() {
+		if j.Status == jobs.StatusRunning {
+			view.Jobs = append(view.Jobs, j)
+		}
+	}
+
+	if tasks, err := store.ListDiskTasks(100); err == nil {
+		for _, t := range tasks {
+			if t.Status == "pending" || t.Status == "running" {
+				view.DiskTasks = append(view.DiskTasks, t)
+			}
+		}
+	}
+	if clients, err := store.ListClients(); err == nil {
+		for _, c := range clients {
+			if c.NextBootImage != "" {
+				view.NextBoots = append(view.NextBoots, queuedBoot{MAC: c.MACAddress, Name: c.Name, Image: c.NextBootImage, Once: c.NextBootOnce})
+			}
+		}
+	}
+
+	if tasks, err := store.ListScheduledTasks(); err == nil {
+		groups := map[uint]string{}
+		if list, err := store.ListClientGroups(); err == nil {
+			for _, g := range list {
+				groups[g.ID] = g.Name
+			}
+		}
+		for _, t := range tasks {
+			if !t.Enabled {
+				continue
+			}
+			runs, err := scheduler.Upcoming(t.CronExpr, now, view.Until, maxRunsPerTask)
+			if err != nil {
+				continue
+			}
+			for _, at := range runs {
+				view.Scheduled = append(view.Scheduled, scheduledRun{
+					TaskID: t.ID, Name: t.Name, Action: t.ActionType, Param: t.ActionParam,
+					Group: groups[t.ClientGroupID], At: at,
+				})
+			}
+		}
+		sort.SliceStable(view.Scheduled, func(i, j int) bool { return view.Scheduled[i].At.Before(view.Scheduled[j].At) })
+	}
+
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: view})
+}
@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type banRequest struct {
+	IP       string `json:"ip"`
+	Duration string `json:"duration,omitempty"` // e.g. "24h"; empty bans for good
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Bans lists (GET), adds (POST) or lifts (DELETE ?ip=) bans on the admin
+// listener. Listed entries include IPs locked out after failed logins.
+func (h *Handler) Bans(w http.ResponseWriter, r *http.Request) {
+	if h.RateLimit == nil {
+		h.sendJSON(w, http.StatusServiceUnavailable, Response{Success: false, Error: "Rate limiting is not enabled"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: h.RateLimit.Bans()})
+
+	case http.MethodPost:
+		var req banRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+			return
+		}
+		var d time.Duration
+		if req.Duration != "" {
+			var err error
+			if d, err = time.ParseDuration(req.Duration); err != nil || d < 0 {
+				h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid duration"})
+				return
+			}
+		}
+		ban, err := h.RateLimit.Ban(req.IP, req.Reason, d)
+		if err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: fmt.Sprintf("Banned %s", ban.IP), Data: ban})
+
+	case http.MethodDelete:
+		ip := r.URL.Query().Get("ip")
+		lifted, err := h.RateLimit.Unban(ip)
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		if !lifted {
+			h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "No ban for that address"})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: fmt.Sprintf("Unbanned %s", ip)})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
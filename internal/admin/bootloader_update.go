@@ -0,0 +1,199 @@
+package admin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"bootimus/bootloaders"
+	"bootimus/internal/securepath"
+)
+
+type ServedBootloader struct {
+	Name   string `json:"name"`
+	Role   string `json:"role,omitempty"` // bios, uefi or arm64 when the manifest advertises it
+	Source string `json:"source"`         // custom, update or embedded
+	Set    string `json:"set"`            // set the file actually comes from
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// BootloaderUpdate serves /api/bootloaders/update. GET reports the installed
+// update, POST installs a signed bundle (multipart "bundle" and "signature"),
+// DELETE goes back to the embedded files.
+func (h *Handler) BootloaderUpdate(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		info, err := bootloaders.InstalledUpdate()
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		keyIDs := make([]string, len(h.BootloaderKeys))
+		for i, k := range h.BootloaderKeys {
+			keyIDs[i] = bootloaders.KeyID(k)
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+			"installed":    info,
+			"trusted_keys": keyIDs,
+		}})
+
+	case http.MethodPost:
+		if len(h.BootloaderKeys) == 0 {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "No bootloader signing keys configured; set bootloader_signing_keys to enable updates"})
+			return
+		}
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: fmt.Sprintf("Failed to parse form: %v", err)})
+			return
+		}
+		bundle, err := readFormFile(r, "bundle", 256<<20)
+		if err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+			return
+		}
+		sig, err := readFormFile(r, "signature", 4096)
+		if err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+			return
+		}
+
+		keyID, err := bootloaders.VerifyBundle(bundle, sig, h.BootloaderKeys)
+		if err != nil {
+			log.Printf("Bootloader update rejected: %v", err)
+			h.sendJSON(w, http.StatusForbidden, Response{Success: false, Error: err.Error()})
+			return
+		}
+		info, err := bootloaders.InstallBundle(bundle, keyID)
+		if err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+			return
+		}
+		log.Printf("Bootloader update %s installed (sha256 %s, key %s, %d files)", info.Version, info.SHA256, keyID, len(info.Files))
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Bootloader update installed", Data: info})
+
+	case http.MethodDelete:
+		if err := bootloaders.RemoveUpdate(); err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		log.Printf("Bootloader update removed; serving embedded bootloaders")
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Reverted to embedded bootloaders"})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
+
+func readFormFile(r *http.Request, field string, limit int64) ([]byte, error) {
+	f, _, err := r.FormFile(field)
+	if err != nil {
+		return nil, fmt.Errorf("missing %s file", field)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s is too large", field)
+	}
+	return data, nil
+}
+
+// ServedBootloaders lists the NBPs of the active set as clients receive
+// them, resolved the same way the TFTP and HTTP servers do, with hashes.
+func (h *Handler) ServedBootloaders(w http.ResponseWriter, r *http.Request) {
+	active := h.bootloaderSelector.GetActiveBootloaderSet()
+	setName := active
+	if setName == "" {
+		setName = bootloaders.DefaultSet
+	}
+
+	names := map[string]bool{}
+	var customDir string
+	if active != "" && h.bootDir != "" && !bootloaders.IsBuiltIn(active) {
+		if dir, err := securepath.Join(h.bootDir, active); err == nil {
+			customDir = dir
+			if entries, err := os.ReadDir(dir); err == nil {
+				for _, e := range entries {
+					if !e.IsDir() {
+						names[e.Name()] = true
+					}
+				}
+			}
+		}
+	}
+	for _, set := range []string{setName, bootloaders.DefaultSet} {
+		if entries, err := bootloaders.ListFiles(set); err == nil {
+			for _, e := range entries {
+				if !e.IsDir() {
+					names[e.Name()] = true
+				}
+			}
+		}
+	}
+
+	update, _ := bootloaders.InstalledUpdate()
+	if update != nil {
+		for _, f := range update.Files {
+			if set, name, ok := strings.Cut(f, "/"); ok && (set == setName || set == bootloaders.DefaultSet) {
+				names[name] = true
+			}
+		}
+	}
+
+	roles := map[string]string{}
+	m, err := bootloaders.LoadManifest(setName)
+	if customDir != "" {
+		if data, readErr := os.ReadFile(filepath.Join(customDir, "manifest.json")); readErr == nil {
+			m, err = bootloaders.ParseManifest(data)
+		}
+	}
+	if err == nil {
+		roles[m.Bootfiles.BIOS] = "bios"
+		roles[m.Bootfiles.UEFI] = "uefi"
+		roles[m.Bootfiles.ARM64] = "arm64"
+	}
+
+	var served []ServedBootloader
+	for name := range names {
+		if name == "manifest.json" {
+			continue
+		}
+		entry := ServedBootloader{Name: name, Role: roles[name]}
+		var data []byte
+		if customDir != "" {
+			if d, err := os.ReadFile(filepath.Join(customDir, name)); err == nil {
+				data, entry.Source, entry.Set = d, "custom", active
+			}
+		}
+		if data == nil {
+			d, resolvedSet, err := bootloaders.Resolve(setName, name)
+			if err != nil {
+				continue
+			}
+			data, entry.Set = d, resolvedSet
+			entry.Source = bootloaders.FileSource(resolvedSet, name)
+		}
+		sum := sha256.Sum256(data)
+		entry.SHA256 = hex.EncodeToString(sum[:])
+		entry.Size = int64(len(data))
+		served = append(served, entry)
+	}
+	sort.Slice(served, func(i, j int) bool { return served[i].Name < served[j].Name })
+
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"active_set":       setName,
+		"bootimus_version": h.version,
+		"update":           update,
+		"files":            served,
+	}})
+}
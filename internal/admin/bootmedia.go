@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"bootimus/bootloaders"
+)
+
+// bootstrapFormats are the boot media that can be made for this server:
+// the bootloader each is built from and the extension it downloads with.
+var bootstrapFormats = map[string]struct{ file, ext string }{
+	"usb":   {"bootimus.usb", ".usb"},
+	"iso":   {"bootimus.usb", ".iso"},
+	"efi":   {"bootimus.efi", ".efi"},
+	"arm64": {"ipxe-arm64.efi", "-arm64.efi"},
+}
+
+// BootstrapMedia streams a USB image, a CD image (?format=iso) or an iPXE
+// binary for an existing EFI partition (?format=efi or arm64) that boots
+// straight into this server's menu, with no DHCP boot options needed.
+func (h *Handler) BootstrapMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	if h.MediaScript == nil || h.serverAddr == "" {
+		h.sendJSON(w, http.StatusServiceUnavailable, Response{Success: false, Error: "Boot media needs the server address; set --server-addr"})
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "usb"
+	}
+	f, ok := bootstrapFormats[format]
+	if !ok {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "format must be usb, iso, efi or arm64"})
+		return
+	}
+
+	var data []byte
+	var err error
+	if format == "iso" {
+		data, err = bootloaders.BootstrapISO(h.MediaScript())
+	} else {
+		data, err = bootloaders.Preconfigure(f.file, h.MediaScript())
+	}
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	name := "bootimus-" + strings.ReplaceAll(h.serverAddr, ":", "_") + f.ext
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+	w.Write(data)
+}
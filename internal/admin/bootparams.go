@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bootimus/internal/models"
+)
+
+type bootParamRequest struct {
+	MACAddress    string `json:"mac_address"`
+	ImageFilename string `json:"image_filename"`
+	Params        string `json:"params"`
+	Replace       bool   `json:"replace"`
+}
+
+func (h *Handler) decodeBootParamRequest(w http.ResponseWriter, r *http.Request) (*models.BootParamOverride, bool) {
+	var req bootParamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return nil, false
+	}
+	o, err := h.bootParamOverride(req)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return nil, false
+	}
+	return o, true
+}
+
+// bootParamOverride validates an override; params end up on an iPXE kernel
+// line, so anything that could start a new script line is refused.
+func (h *Handler) bootParamOverride(req bootParamRequest) (*models.BootParamOverride, error) {
+	mac := strings.ToLower(strings.ReplaceAll(req.MACAddress, "-", ":"))
+	if _, err := net.ParseMAC(mac); err != nil {
+		return nil, errors.New("Invalid MAC address")
+	}
+	params := strings.TrimSpace(req.Params)
+	if strings.ContainsAny(params, "\r\n") || len(params) > 2048 {
+		return nil, errors.New("Params must be a single line of at most 2048 characters")
+	}
+	if params == "" && !req.Replace {
+		return nil, errors.New("Missing params")
+	}
+	if req.ImageFilename != "" {
+		if _, err := h.storage.GetImage(req.ImageFilename); err != nil {
+			return nil, errors.New("Image not found")
+		}
+	}
+	return &models.BootParamOverride{
+		MACAddress:    mac,
+		ImageFilename: req.ImageFilename,
+		Params:        params,
+		Replace:       req.Replace,
+	}, nil
+}
+
+func (h *Handler) ListBootParamOverrides(w http.ResponseWriter, r *http.Request) {
+	mac := strings.ToLower(strings.ReplaceAll(r.URL.Query().Get("mac"), "-", ":"))
+	overrides, err := h.storage.ListBootParamOverrides(mac)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: overrides})
+}
+
+func (h *Handler) SaveBootParamOverride(w http.ResponseWriter, r *http.Request) {
+	o, ok := h.decodeBootParamRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := h.storage.SaveBootParamOverride(o); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	log.Printf("Admin: boot params for %s (image: %q) set to %q (replace: %v)", o.MACAddress, o.ImageFilename, o.Params, o.Replace)
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Boot parameter override saved", Data: o})
+}
+
+func (h *Handler) DeleteBootParamOverride(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid override ID"})
+		return
+	}
+	if err := h.storage.DeleteBootParamOverride(uint(id)); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Boot parameter override deleted"})
+}
+
+// TryBootParams queues params for the client's next boot only. If an image
+// is given it is also pre-selected in that boot's menu.
+func (h *Handler) TryBootParams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	o, ok := h.decodeBootParamRequest(w, r)
+	if !ok {
+		return
+	}
+	o.Once = true
+	if err := h.storage.SaveBootParamOverride(o); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	log.Printf("Admin: boot params %q queued once for %s (image: %q)", o.Params, o.MACAddress, o.ImageFilename)
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Params apply to the client's next boot only", Data: o})
+}
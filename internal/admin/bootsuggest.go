@@ -0,0 +1,174 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"bootimus/internal/models"
+)
+
+const (
+	// suggestWindow is how far back boot logs count towards a suggestion.
+	suggestWindow = 30 * 24 * time.Hour
+
+	// A sanboot image is flagged once at least sanbootMinFailures of its
+	// boots, and sanbootFailureRate of them, failed within suggestWindow.
+	sanbootMinFailures = 3
+	sanbootFailureRate = 0.3
+
+	kernelBootPoll = time.Hour
+)
+
+type kernelBootSuggestion struct {
+	Filename    string   `json:"filename"`
+	Name        string   `json:"name"`
+	Extracted   bool     `json:"extracted"`
+	Profile     string   `json:"profile,omitempty"`
+	Boots       int64    `json:"sanboot_boots"`
+	Failures    int64    `json:"sanboot_failures"`
+	FailureRate float64  `json:"failure_rate"`
+	Flagged     bool     `json:"flagged"`          // sanboot fails often
+	Action      string   `json:"action,omitempty"` // "switch" or "extract"
+	Reasons     []string `json:"reasons"`
+}
+
+// kernelBootSuggestions lists sanboot images that could boot by kernel
+// instead: those already extracted, and those whose ISO matches a distro
+// profile with known kernel paths. Images that fail often under sanboot are
+// flagged, with or without a way to switch, and sorted first.
+func (h *Handler) kernelBootSuggestions() ([]kernelBootSuggestion, error) {
+	images, err := h.storage.ListImages()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := h.storage.BootMethodStats(time.Now().Add(-suggestWindow))
+	if err != nil {
+		return nil, err
+	}
+	sanboot := map[string]models.BootMethodStat{}
+	for _, st := range stats {
+		if st.BootMethod == "sanboot" {
+			sanboot[st.ImageName] = st
+		}
+	}
+
+	out := []kernelBootSuggestion{}
+	for _, image := range images {
+		if image.BootMethod != "sanboot" || !image.HasISOFile() || image.CloneOf != "" {
+			continue
+		}
+		s := kernelBootSuggestion{Filename: image.Filename, Name: image.Name, Extracted: image.Extracted, Reasons: []string{}}
+		// A boot is logged when it starts and again if it fails, so the
+		// successful entries count the boots.
+		st := sanboot[image.Name]
+		s.Boots, s.Failures = max(st.Boots-st.Failures, st.Failures), st.Failures
+		if s.Boots > 0 {
+			s.FailureRate = float64(s.Failures) / float64(s.Boots)
+		}
+		if s.Failures >= sanbootMinFailures && s.FailureRate >= sanbootFailureRate {
+			s.Flagged = true
+			s.Reasons = append(s.Reasons, fmt.Sprintf("%d of %d sanboot boots failed in the last %d days", s.Failures, s.Boots, int(suggestWindow.Hours()/24)))
+		}
+		if !image.SanbootCompatible && image.SanbootHint != "" {
+			s.Reasons = append(s.Reasons, image.SanbootHint)
+		}
+		if rep, ok := h.Ranges.Report(image.DiskFilename()); ok && rep.SuggestExtract {
+			s.Reasons = append(s.Reasons, fmt.Sprintf("sanboot clients read only %.0f%% of the ISO", rep.Fraction*100))
+		}
+
+		if image.Extracted {
+			s.Action = "switch"
+		} else if p := h.kernelProfile(image.Filename); p != nil {
+			s.Profile = p.ProfileID
+			s.Action = "extract"
+		}
+		if s.Action == "" && !s.Flagged {
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Flagged != out[j].Flagged {
+			return out[i].Flagged
+		}
+		return out[i].FailureRate > out[j].FailureRate
+	})
+	return out, nil
+}
+
+// kernelProfile returns the distro profile matching filename if it knows
+// where the kernel is and does not itself call for sanboot.
+func (h *Handler) kernelProfile(filename string) *models.DistroProfile {
+	if h.profileManager == nil {
+		return nil
+	}
+	p, err := h.profileManager.MatchProfile(filename)
+	if err != nil || p == nil || len(p.KernelPaths) == 0 || p.BootMethod == "sanboot" {
+		return nil
+	}
+	return p
+}
+
+// KernelBootSuggestions lists sanboot images worth converting to kernel
+// boot, flagging those whose sanboot boots keep failing.
+func (h *Handler) KernelBootSuggestions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	suggestions, err := h.kernelBootSuggestions()
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: suggestions})
+}
+
+// ConvertFailingSanboot moves flagged images to kernel boot every hour:
+// extracted ones are switched, the rest are extracted first. Images no
+// profile covers are left for an admin to look at.
+func (h *Handler) ConvertFailingSanboot() {
+	ticker := time.NewTicker(kernelBootPoll)
+	defer ticker.Stop()
+	for range ticker.C {
+		suggestions, err := h.kernelBootSuggestions()
+		if err != nil {
+			continue
+		}
+		for _, s := range suggestions {
+			if !s.Flagged || s.Action == "" || h.jobs.Running("extract") {
+				continue
+			}
+			image, err := h.storage.GetImage(s.Filename)
+			if err != nil || image.BootMethod != "sanboot" {
+				continue
+			}
+			h.convertToKernelBoot(image, s)
+		}
+	}
+}
+
+func (h *Handler) convertToKernelBoot(image *models.Image, s kernelBootSuggestion) {
+	if s.Action == "switch" {
+		image.BootMethod = "kernel"
+		if err := h.storage.UpdateImage(image.Filename, image); err != nil {
+			log.Printf("Kernel boot: failed to switch %s: %v", image.Name, err)
+			return
+		}
+		log.Printf("Kernel boot: switched %s from sanboot (%s)", image.Name, s.Reasons[0])
+		return
+	}
+	if h.Libraries.Fetch(image.Filename) {
+		return
+	}
+	job := h.jobs.Start("extract", image.Filename)
+	job.Logf("Extracting to move off sanboot: %s", s.Reasons[0])
+	err := h.extractImage(h.background(), image, job)
+	if err == nil && image.NetbootRequired && image.NetbootURL != "" {
+		go h.autoInstallNetboot(image.Filename)
+	}
+	job.Finish(err)
+}
@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"bootimus/internal/branding"
+)
+
+// BrandingAssets lists the custom logo, console banner and stylesheet (GET),
+// uploads them as logo/banner/css form files or a zip bundle (POST), or
+// removes one with ?asset= or all of them with ?asset=all (DELETE).
+func (h *Handler) BrandingAssets(w http.ResponseWriter, r *http.Request) {
+	if h.Branding == nil {
+		h.sendJSON(w, http.StatusServiceUnavailable, Response{Success: false, Error: "Branding is not available"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: h.Branding.List()})
+
+	case http.MethodPost:
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: fmt.Sprintf("Failed to parse form: %v", err)})
+			return
+		}
+		var set []string
+		if _, _, err := r.FormFile("bundle"); err == nil {
+			data, err := readFormFile(r, "bundle", 3*branding.MaxAssetSize)
+			if err == nil {
+				set, err = h.Branding.SaveBundle(data)
+			}
+			if err != nil {
+				h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+				return
+			}
+		}
+		for _, asset := range []string{branding.AssetLogo, branding.AssetBanner, branding.AssetCSS} {
+			_, header, err := r.FormFile(asset)
+			if err != nil {
+				continue
+			}
+			data, err := readFormFile(r, asset, branding.MaxAssetSize)
+			if err == nil {
+				err = h.Branding.Save(asset, header.Filename, data)
+			}
+			if err != nil {
+				h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+				return
+			}
+			set = append(set, asset)
+		}
+		if len(set) == 0 {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Upload a logo, banner, css or bundle file"})
+			return
+		}
+		log.Printf("Admin: Updated branding (%s)", strings.Join(set, ", "))
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Branding updated", Data: h.Branding.List()})
+
+	case http.MethodDelete:
+		asset := r.URL.Query().Get("asset")
+		assets := []string{asset}
+		if asset == "all" {
+			assets = []string{branding.AssetLogo, branding.AssetBanner, branding.AssetCSS}
+		}
+		for _, a := range assets {
+			err := h.Branding.Remove(a)
+			if errors.Is(err, branding.ErrUnknownAsset) {
+				h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "asset must be logo, banner, css or all"})
+				return
+			}
+			if err != nil && !errors.Is(err, branding.ErrNotFound) {
+				h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+				return
+			}
+		}
+		log.Printf("Admin: Removed branding (%s)", asset)
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Branding removed"})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
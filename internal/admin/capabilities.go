@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"net/http"
+	"os/exec"
+
+	"bootimus/internal/bootmethod"
+	"bootimus/internal/extractor"
+)
+
+// Capability is one thing this instance can or can't do, and why not.
+type Capability struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	UsedFor   string `json:"used_for,omitempty"`
+	Reason    string `json:"reason,omitempty"` // why it is unavailable
+}
+
+type Capabilities struct {
+	Distros     []string     `json:"distros"` // layouts extraction recognises
+	Filesystems []Capability `json:"filesystems"`
+	BootMethods []Capability `json:"boot_methods"`
+	Tools       []Capability `json:"tools"`
+}
+
+// capabilityTools are the external programs features shell out to, with
+// the package that usually provides them.
+var capabilityTools = []struct {
+	name, usedFor, pkg string
+}{
+	{"wimlib-imagex", "Windows driver injection and boot.wim patching for SMB installs", "wimtools"},
+	{"wimupdate", "Adding drivers to boot.wim", "wimtools"},
+	{"7z", "Unpacking .zip and .7z driver packs", "p7zip-full"},
+	{"bsdtar", "Extracting ISOs the built-in ISO9660 and UDF readers can't", "libarchive-tools"},
+	{"zstd", "Lite initrds", "zstd"},
+	{"smbd", "Windows installs from an SMB share", "samba"},
+	{"pg_dump", "Backups of a PostgreSQL database", "postgresql-client"},
+}
+
+func (h *Handler) capabilities() Capabilities {
+	c := Capabilities{
+		Distros: extractor.Families(),
+		Filesystems: []Capability{
+			{Name: "ISO9660", Available: true},
+			{Name: "UDF", Available: true},
+		},
+	}
+
+	for _, m := range []struct{ name, usedFor string }{
+		{"sanboot", "Booting the whole ISO over HTTP"},
+		{"kernel", "Booting the extracted kernel and initrd"},
+		{"nbd", "Live systems mounting the ISO over NBD"},
+		{"nfs", "Live systems mounting the extracted root over NFS"},
+	} {
+		flag, off := h.DisabledBootMethods[m.name]
+		bm := Capability{Name: m.name, Available: !off, UsedFor: m.usedFor}
+		if off {
+			bm.Reason = "The server for it is off; start Bootimus with " + flag
+		}
+		c.BootMethods = append(c.BootMethods, bm)
+	}
+	for _, p := range bootmethod.All() {
+		c.BootMethods = append(c.BootMethods, Capability{Name: p.Name(), Available: true, UsedFor: p.Description()})
+	}
+
+	for _, t := range capabilityTools {
+		tool := Capability{Name: t.name, UsedFor: t.usedFor}
+		if _, err := exec.LookPath(t.name); err == nil {
+			tool.Available = true
+		} else {
+			tool.Reason = "Not installed; install " + t.pkg
+		}
+		c.Tools = append(c.Tools, tool)
+	}
+	return c
+}
+
+// GetCapabilities reports the distros, filesystems, boot methods and
+// external tools this instance supports, so the UI can explain why an
+// action is unavailable.
+func (h *Handler) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: h.capabilities()})
+}
@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"bootimus/internal/autoinstall"
+	"bootimus/internal/models"
+)
+
+var variantSlugRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+type cloneImageRequest struct {
+	Source                string  `json:"source"`
+	Name                  string  `json:"name"`
+	Description           *string `json:"description"`
+	BootParams            *string `json:"boot_params"`
+	AutoInstallScript     *string `json:"auto_install_script"`
+	AutoInstallScriptType *string `json:"auto_install_script_type"`
+	AutoInstallFile       *string `json:"auto_install_file"`
+}
+
+// refuseVariant rejects operations that act on the ISO or its extraction,
+// which a variant shares with its source.
+func (h *Handler) refuseVariant(w http.ResponseWriter, image *models.Image, action string) bool {
+	if image.CloneOf == "" {
+		return false
+	}
+	h.sendJSON(w, http.StatusConflict, Response{
+		Success: false,
+		Error:   fmt.Sprintf("%s is a variant of %s; %s the source image instead", image.Filename, image.CloneOf, action),
+	})
+	return true
+}
+
+// variantFilename builds a unique filename for a variant next to its source,
+// e.g. ubuntu-24.04.iso -> ubuntu-24.04--wipe-install.iso. Nothing is ever
+// written under this name; it only keys the database row.
+func (h *Handler) variantFilename(source, name string) string {
+	ext := filepath.Ext(source)
+	base := strings.TrimSuffix(source, ext)
+	slug := strings.Trim(variantSlugRe.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if slug == "" {
+		slug = "variant"
+	}
+	candidate := fmt.Sprintf("%s--%s%s", base, slug, ext)
+	for i := 2; ; i++ {
+		if _, err := h.storage.GetImage(candidate); err != nil {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s--%s-%d%s", base, slug, i, ext)
+	}
+}
+
+// CloneImage creates a variant: a second menu entry that boots the same ISO
+// and extraction with its own name, boot params and auto-install script.
+func (h *Handler) CloneImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+
+	var req cloneImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Source == "" || req.Name == "" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "source and name are required"})
+		return
+	}
+	if req.BootParams != nil && strings.ContainsAny(*req.BootParams, "\r\n") {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "boot_params must be a single line"})
+		return
+	}
+
+	h.libraryMu.Lock()
+	defer h.libraryMu.Unlock()
+
+	source, err := h.storage.GetImage(req.Source)
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Source image not found"})
+		return
+	}
+	if source.CloneOf != "" {
+		if source, err = h.storage.GetImage(source.CloneOf); err != nil {
+			h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Source image not found"})
+			return
+		}
+	}
+
+	variant := *source
+	variant.ID = 0
+	variant.Version = 0
+	variant.BootCount = 0
+	variant.LastBooted = nil
+	variant.Clients = nil
+	variant.Group = nil
+	variant.Name = req.Name
+	variant.CloneOf = source.Filename
+	variant.Filename = h.variantFilename(source.Filename, req.Name)
+
+	if req.Description != nil {
+		variant.Description = *req.Description
+	}
+	if req.BootParams != nil {
+		variant.BootParams = strings.TrimSpace(*req.BootParams)
+	}
+	if req.AutoInstallScriptType != nil {
+		variant.AutoInstallScriptType = *req.AutoInstallScriptType
+	}
+	if req.AutoInstallScript != nil {
+		if *req.AutoInstallScript != "" && r.URL.Query().Get("force") != "true" {
+			if errs := autoinstall.ValidateScript(variant.AutoInstallScriptType, *req.AutoInstallScript); len(errs) > 0 {
+				h.sendJSON(w, http.StatusBadRequest, Response{
+					Success: false,
+					Error:   fmt.Sprintf("Script has %d problem(s); first: %s", len(errs), errs[0].Error()),
+					Data:    errs,
+				})
+				return
+			}
+		}
+		if *req.AutoInstallScript != variant.AutoInstallScript {
+			variant.AutoInstallGenerator = ""
+			variant.AutoInstallParams = ""
+		}
+		variant.AutoInstallScript = *req.AutoInstallScript
+	}
+	if req.AutoInstallFile != nil {
+		variant.AutoInstallFile = *req.AutoInstallFile
+	}
+	variant.AutoInstallEnabled = variant.AutoInstallScript != "" || variant.AutoInstallFile != ""
+
+	if err := h.storage.CreateImage(&variant); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	log.Printf("Admin: Image variant created - %s (%s) from %s", variant.Filename, variant.Name, source.Filename)
+	h.sendJSON(w, http.StatusCreated, Response{Success: true, Message: "Image variant created", Data: variant})
+}
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func (h *Handler) consoleFilePath(mac, filename string) string {
+	return filepath.Join(h.dataDir, "console", strings.ReplaceAll(mac, ":", ""), filepath.Base(filename))
+}
+
+func (h *Handler) ListConsoleCaptures(w http.ResponseWriter, r *http.Request) {
+	mac := strings.ToLower(strings.ReplaceAll(r.URL.Query().Get("mac"), "-", ":"))
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	captures, err := h.storage.ListConsoleCaptures(mac, limit)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: captures})
+}
+
+// GetConsoleCaptureFile serves a capture's content. For serial logs,
+// ?tail=<bytes> returns only the end of the log.
+func (h *Handler) GetConsoleCaptureFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid capture ID"})
+		return
+	}
+	c, err := h.storage.GetConsoleCapture(uint(id))
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Capture not found"})
+		return
+	}
+
+	f, err := os.Open(h.consoleFilePath(c.MACAddress, c.Filename))
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Capture file missing"})
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", c.ContentType)
+	if tail, err := strconv.ParseInt(r.URL.Query().Get("tail"), 10, 64); err == nil && tail > 0 && tail < info.Size() && c.Kind == "serial" {
+		http.ServeContent(w, r, "", info.ModTime(), io.NewSectionReader(f, info.Size()-tail, tail))
+		return
+	}
+	http.ServeContent(w, r, c.Filename, info.ModTime(), f)
+}
+
+func (h *Handler) DeleteConsoleCapture(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid capture ID"})
+		return
+	}
+	c, err := h.storage.GetConsoleCapture(uint(id))
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Capture not found"})
+		return
+	}
+	if err := h.storage.DeleteConsoleCapture(c.ID); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	if err := os.Remove(h.consoleFilePath(c.MACAddress, c.Filename)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Admin: failed to remove console capture %s: %v", c.Filename, err)
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Capture deleted"})
+}
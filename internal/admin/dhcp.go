@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type dhcpLeaseView struct {
+	MAC       string    `json:"mac_address"`
+	IP        string    `json:"ip_address"`
+	Hostname  string    `json:"hostname,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Active    bool      `json:"active"`
+}
+
+type dhcpReservation struct {
+	MAC    string `json:"mac_address"`
+	IP     string `json:"ip_address"`
+	Client string `json:"client,omitempty"`
+}
+
+// DHCPLeases lists the built-in DHCP server's leases and the addresses
+// reserved on clients (GET), or drops a client's lease (DELETE ?mac=) so
+// its address can be handed out again.
+func (h *Handler) DHCPLeases(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		mac := strings.ToLower(strings.ReplaceAll(r.URL.Query().Get("mac"), "-", ":"))
+		if mac == "" {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Missing mac parameter"})
+			return
+		}
+		if err := h.storage.DeleteDHCPLease(mac); err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Lease released"})
+		return
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+
+	leases, err := h.storage.ListDHCPLeases()
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	clients, err := h.storage.ListClients()
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	names := map[string]string{}
+	reservations := []dhcpReservation{}
+	for _, c := range clients {
+		names[c.MACAddress] = c.Name
+		if c.ReservedIP != "" {
+			reservations = append(reservations, dhcpReservation{MAC: c.MACAddress, IP: c.ReservedIP, Client: c.Name})
+		}
+	}
+	now := time.Now()
+	views := make([]dhcpLeaseView, 0, len(leases))
+	for _, l := range leases {
+		views = append(views, dhcpLeaseView{
+			MAC: l.MACAddress, IP: l.IPAddress, Hostname: l.Hostname, Client: names[l.MACAddress],
+			ExpiresAt: l.ExpiresAt, Active: l.ExpiresAt.After(now),
+		})
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"enabled":      h.DHCPRange != "",
+		"range":        h.DHCPRange,
+		"leases":       views,
+		"reservations": reservations,
+	}})
+}
+
+// checkReservedIP refuses an address that is not IPv4 or is already
+// reserved for another client.
+func (h *Handler) checkReservedIP(mac, ip string) error {
+	if ip == "" {
+		return nil
+	}
+	if net.ParseIP(ip).To4() == nil {
+		return fmt.Errorf("reserved IP %q is not an IPv4 address", ip)
+	}
+	clients, err := h.storage.ListClients()
+	if err != nil {
+		return err
+	}
+	for _, c := range clients {
+		if c.ReservedIP == ip && c.MACAddress != mac {
+			return fmt.Errorf("%s is already reserved for %s", ip, c.MACAddress)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package admin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+)
+
+var diskImageNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (h *Handler) capturesDir() string {
+	return filepath.Join(h.dataDir, "captures")
+}
+
+func newTaskToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (h *Handler) ListDiskTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	tasks, err := h.storage.ListDiskTasks(limit)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: tasks})
+}
+
+func (h *Handler) CreateDiskTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+
+	var req struct {
+		MACAddress  string `json:"mac_address"`
+		Kind        string `json:"kind"`
+		LiveImage   string `json:"live_image"`
+		Device      string `json:"device"`
+		Format      string `json:"format"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		DiskImageID uint   `json:"disk_image_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+
+	mac := strings.ToLower(strings.ReplaceAll(req.MACAddress, "-", ":"))
+	if _, err := net.ParseMAC(mac); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid MAC address"})
+		return
+	}
+	if req.Device != "" && !strings.HasPrefix(req.Device, "/dev/") {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Device must be a /dev path"})
+		return
+	}
+	if req.Format == "" {
+		req.Format = "raw"
+	}
+	if req.Format != "raw" {
+		// A partclone stream holds one filesystem, not a disk with its
+		// partition table, so captures are raw.
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Format must be raw"})
+		return
+	}
+
+	live, err := h.storage.GetImage(req.LiveImage)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Live image not found"})
+		return
+	}
+	if !live.Extracted || live.BootMethod != "kernel" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Live image must be extracted and use the kernel boot method"})
+		return
+	}
+
+	if pending, err := h.storage.GetPendingDiskTask(mac); err == nil {
+		h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: fmt.Sprintf("Task #%d is already pending for %s", pending.ID, mac)})
+		return
+	}
+
+	task := &models.DiskTask{
+		MACAddress: mac,
+		Kind:       req.Kind,
+		LiveImage:  live.Filename,
+		Device:     req.Device,
+		Format:     req.Format,
+		Token:      models.Secret(newTaskToken()),
+		Status:     "pending",
+	}
+
+	var captureImage *models.DiskImage
+	switch req.Kind {
+	case "capture":
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			name = "capture-" + strings.ReplaceAll(mac, ":", "")
+		}
+		captureImage = &models.DiskImage{
+			Name:        name,
+			Description: req.Description,
+			Format:      req.Format,
+			SourceMAC:   mac,
+			SourceDev:   req.Device,
+			Status:      "pending",
+			Filename:    fmt.Sprintf("%s-%s.img", diskImageNameRe.ReplaceAllString(name, "_"), newTaskToken()[:8]),
+		}
+	case "deploy":
+		img, err := h.storage.GetDiskImage(req.DiskImageID)
+		if err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Disk image not found"})
+			return
+		}
+		if img.Status != "complete" {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Disk image is not complete"})
+			return
+		}
+		if img.SHA256 == "" {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Disk image has no SHA-256 to verify the deploy against"})
+			return
+		}
+		if img.Format == "partclone" && req.Device == "" {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "A partclone image holds one filesystem; set device to the partition to restore it to"})
+			return
+		}
+		task.DiskImageID = &img.ID
+		task.Format = img.Format
+	default:
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Kind must be capture or deploy"})
+		return
+	}
+
+	// A capture's image record exists only alongside its task.
+	err = h.storage.Transaction(func(tx storage.Storage) error {
+		if captureImage != nil {
+			if err := tx.CreateDiskImage(captureImage); err != nil {
+				return err
+			}
+			task.DiskImageID = &captureImage.ID
+		}
+		return tx.CreateDiskTask(task)
+	})
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	log.Printf("Admin: %s task #%d queued for %s (live image %s)", task.Kind, task.ID, mac, live.Filename)
+	h.sendJSON(w, http.StatusCreated, Response{Success: true, Message: "Task queued; it runs on the client's next network boot", Data: task})
+}
+
+func (h *Handler) CancelDiskTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid task ID"})
+		return
+	}
+	task, err := h.storage.GetDiskTask(uint(id))
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Task not found"})
+		return
+	}
+	if task.Status == "pending" || task.Status == "running" {
+		task.Status = "cancelled"
+		err := h.storage.Transaction(func(tx storage.Storage) error {
+			if err := tx.UpdateDiskTask(task); err != nil {
+				return err
+			}
+			if task.Kind == "capture" && task.DiskImage != nil && task.DiskImage.Status != "complete" {
+				return tx.DeleteDiskImage(task.DiskImage.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		log.Printf("Admin: disk task #%d cancelled", task.ID)
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Task cancelled"})
+		return
+	}
+	if err := h.storage.DeleteDiskTask(task.ID); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Task deleted"})
+}
+
+func (h *Handler) ListDiskImages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	images, err := h.storage.ListDiskImages()
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: images})
+}
+
+func (h *Handler) UpdateDiskImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid disk image ID"})
+		return
+	}
+	img, err := h.storage.GetDiskImage(uint(id))
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Disk image not found"})
+		return
+	}
+	var updates map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+	if name, ok := updates["name"].(string); ok && name != "" {
+		img.Name = name
+	}
+	if desc, ok := updates["description"].(string); ok {
+		img.Description = desc
+	}
+	if err := h.storage.UpdateDiskImage(img); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Disk image updated", Data: img})
+}
+
+func (h *Handler) DeleteDiskImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid disk image ID"})
+		return
+	}
+	img, err := h.storage.GetDiskImage(uint(id))
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Disk image not found"})
+		return
+	}
+	if err := h.storage.DeleteDiskImage(img.ID); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	if img.Filename != "" {
+		if err := os.Remove(filepath.Join(h.capturesDir(), filepath.Base(img.Filename))); err != nil && !os.IsNotExist(err) {
+			log.Printf("Admin: failed to remove capture file %s: %v", img.Filename, err)
+		}
+	}
+	log.Printf("Admin: disk image deleted - %s", img.Name)
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Disk image deleted"})
+}
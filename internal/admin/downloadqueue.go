@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"sync"
+
+	"bootimus/internal/throttle"
+)
+
+const defaultMaxDownloads = 2
+
+// DownloadQueue runs ISO downloads on a pool of at most max workers, in the
+// order they were asked for, within a bandwidth cap shared by all of them
+// and one for each.
+type DownloadQueue struct {
+	mu      sync.Mutex
+	max     int
+	workers int
+	busy    int // workers running a download
+	pending []func()
+
+	global      *throttle.Limiter
+	perDownload int64 // bytes per second each download gets by default, 0 for no cap
+}
+
+// NewDownloadQueue runs up to workers downloads at once (at least one), capped
+// together at globalRate and each at perDownload bytes per second; 0 leaves
+// either uncapped.
+func NewDownloadQueue(workers int, globalRate, perDownload int64) *DownloadQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	return &DownloadQueue{max: workers, global: throttle.New(globalRate), perDownload: perDownload}
+}
+
+// submit queues job and reports how many downloads must finish before it
+// starts.
+func (q *DownloadQueue) submit(job func()) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, job)
+	if q.workers < q.max {
+		q.workers++
+		go q.work()
+	}
+	return max(0, len(q.pending)-(q.max-q.busy))
+}
+
+func (q *DownloadQueue) work() {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.workers--
+			q.mu.Unlock()
+			return
+		}
+		job := q.pending[0]
+		q.pending = q.pending[1:]
+		q.busy++
+		q.mu.Unlock()
+		job()
+		q.mu.Lock()
+		q.busy--
+		q.mu.Unlock()
+	}
+}
+
+// limits are the limiters a download reads through: the shared cap, and its
+// own of rate bytes per second, or the default when rate is 0.
+func (q *DownloadQueue) limits(rate int64) []*throttle.Limiter {
+	if rate <= 0 {
+		rate = q.perDownload
+	}
+	return []*throttle.Limiter{q.global, throttle.New(rate)}
+}
+
+type DownloadQueueStatus struct {
+	MaxConcurrent int   `json:"max_concurrent"`
+	Running       int   `json:"running"`
+	Queued        int   `json:"queued"`
+	RateLimit     int64 `json:"rate_limit"`              // bytes per second for all downloads, 0 for none
+	PerDownload   int64 `json:"per_download_rate_limit"` // default for each download, 0 for none
+}
+
+func (q *DownloadQueue) status() DownloadQueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return DownloadQueueStatus{
+		MaxConcurrent: q.max,
+		Running:       q.busy,
+		Queued:        len(q.pending),
+		RateLimit:     q.global.Rate(),
+		PerDownload:   q.perDownload,
+	}
+}
@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"bootimus/internal/models"
+)
+
+const externalImageExt = ".url"
+
+var externalCheckClient = &http.Client{Timeout: 15 * time.Second}
+
+type externalImageRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	CacheRemote bool   `json:"cache_remote"`
+	Public      *bool  `json:"public"`
+	GroupID     *uint  `json:"group_id"`
+}
+
+// headISO asks the server hosting an external ISO for its size. sanboot
+// reads the ISO in ranges, so a server that does not offer them is refused.
+func headISO(ctx context.Context, isoURL string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, isoURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := externalCheckClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HEAD returned %s", resp.Status)
+	}
+	if resp.ContentLength <= 0 {
+		return 0, fmt.Errorf("server did not report the ISO's size")
+	}
+	if !strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes") {
+		return 0, fmt.Errorf("server does not accept range requests, which sanboot needs")
+	}
+	return resp.ContentLength, nil
+}
+
+// checkExternalISO refreshes an external image's size and availability.
+func checkExternalISO(ctx context.Context, image *models.Image) {
+	now := time.Now()
+	image.ISOCheckedAt = &now
+	size, err := headISO(ctx, image.ISOURL)
+	if err != nil {
+		image.ISOCheckError = err.Error()
+		return
+	}
+	image.ISOCheckError = ""
+	image.Size = size
+}
+
+// CreateExternalImage registers an ISO that stays on another HTTP server.
+func (h *Handler) CreateExternalImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+
+	var req externalImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+	if err := checkRemoteURL("url", req.URL, true); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
+	u, _ := url.Parse(req.URL)
+	isoName := path.Base(u.Path)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		req.Name = strings.TrimSuffix(isoName, path.Ext(isoName))
+	}
+
+	image := &models.Image{
+		Name:        req.Name,
+		Description: req.Description,
+		Enabled:     true,
+		Public:      req.Public == nil || *req.Public,
+		GroupID:     req.GroupID,
+		BootMethod:  "sanboot",
+		ISOURL:      req.URL,
+		CacheRemote: req.CacheRemote,
+	}
+	checkExternalISO(r.Context(), image)
+	if image.ISOCheckError != "" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: fmt.Sprintf("%s: %s", req.URL, image.ISOCheckError)})
+		return
+	}
+
+	slug := strings.Trim(variantSlugRe.ReplaceAllString(strings.ToLower(req.Name), "-"), "-")
+	if slug == "" {
+		slug = "external"
+	}
+	image.Filename = slug + externalImageExt
+	for i := 2; ; i++ {
+		if _, err := h.storage.GetImage(image.Filename); err != nil {
+			break
+		}
+		image.Filename = fmt.Sprintf("%s-%d%s", slug, i, externalImageExt)
+	}
+	rel := models.ParseRelease(isoName)
+	image.ReleaseVersion, image.Variant, image.Arch = rel.Version, rel.Variant, rel.Arch
+
+	if err := h.storage.CreateImage(image); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	log.Printf("Admin: External image created - %s (%s, %d MB)", image.Filename, image.ISOURL, image.Size>>20)
+	h.sendJSON(w, http.StatusCreated, Response{Success: true, Message: "External image created", Data: image})
+}
+
+// checkExternalImages re-checks every external image during a scan and
+// returns the filenames of those that could not be reached.
+func (h *Handler) checkExternalImages(ctx context.Context, images []*models.Image) []string {
+	var unreachable []string
+	for _, image := range images {
+		if !image.IsExternalISO() {
+			continue
+		}
+		checkExternalISO(ctx, image)
+		if image.ISOCheckError != "" {
+			log.Printf("External image %s unavailable: %s", image.Filename, image.ISOCheckError)
+			unreachable = append(unreachable, image.Filename)
+		}
+		if err := h.storage.UpdateImage(image.Filename, image); err != nil {
+			log.Printf("Failed to save check of external image %s: %v", image.Filename, err)
+		}
+	}
+	return unreachable
+}
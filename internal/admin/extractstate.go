@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bootimus/internal/extractor"
+	"bootimus/internal/jobs"
+	"bootimus/internal/models"
+)
+
+// saveExtractionStep records that the extraction of filename has reached
+// step, along with the extractor's result once there is one.
+func (h *Handler) saveExtractionStep(filename, step string, files *extractor.BootFiles) {
+	st := &models.ExtractionState{Filename: filename, Step: step}
+	if files != nil {
+		if b, err := json.Marshal(files); err == nil {
+			st.BootFiles = string(b)
+		}
+	}
+	if err := h.storage.SaveExtractionState(st); err != nil {
+		log.Printf("Extraction: failed to record step %q of %s: %v", step, filename, err)
+	}
+}
+
+// ResumeExtractions finishes the extractions a restart cut short and
+// reconciles extracted images with their cache directories. It runs once at
+// startup.
+func (h *Handler) ResumeExtractions() {
+	states, err := h.storage.ListExtractionStates()
+	if err != nil {
+		log.Printf("Extraction: failed to list interrupted extractions: %v", err)
+		return
+	}
+	resumed := make(map[string]bool, len(states))
+	for _, st := range states {
+		resumed[st.Filename] = true
+		h.resumeExtraction(st)
+	}
+
+	images, err := h.storage.ListImages()
+	if err != nil {
+		log.Printf("Extraction: failed to list images: %v", err)
+		return
+	}
+	for _, img := range images {
+		if img.Extracted && img.HasISOFile() && img.CloneOf == "" && !resumed[img.Filename] {
+			h.reconcileExtraction(img)
+		}
+	}
+}
+
+func (h *Handler) resumeExtraction(st *models.ExtractionState) {
+	image, err := h.storage.GetImage(st.Filename)
+	if err != nil || !image.HasISOFile() || image.CloneOf != "" {
+		h.storage.DeleteExtractionState(st.Filename)
+		return
+	}
+
+	job := h.jobs.Start("extract", image.Filename)
+	job.Logf("Resuming an extraction interrupted at the %q step", st.Step)
+
+	var files extractor.BootFiles
+	if st.Step != models.ExtractStepFiles && json.Unmarshal([]byte(st.BootFiles), &files) == nil && bootFilesPresent(&files) {
+		ext, err := extractor.New(h.Libraries.Dir(image.Filename))
+		if err == nil {
+			err = h.finishExtraction(ext, image, &files, job)
+		}
+		job.Finish(err)
+		return
+	}
+
+	job.Finish(h.reextract(image, job))
+}
+
+// reconcileExtraction re-extracts an image marked extracted whose kernel or
+// initrd has gone from its cache directory, and rewrites a missing
+// metadata.txt from the image.
+func (h *Handler) reconcileExtraction(image *models.Image) {
+	dir := filepath.Join(h.Libraries.Dir(image.Filename), strings.TrimSuffix(image.Filename, filepath.Ext(image.Filename)))
+	for _, p := range []string{image.KernelPath, image.InitrdPath} {
+		if p == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.Base(p))); err != nil {
+			job := h.jobs.Start("extract", image.Filename)
+			job.Logf("%s is missing from the cache directory; extracting again", filepath.Base(p))
+			job.Finish(h.reextract(image, job))
+			return
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "metadata.txt")); !os.IsNotExist(err) {
+		return
+	}
+	ext, err := extractor.New(h.Libraries.Dir(image.Filename))
+	if err != nil {
+		return
+	}
+	if err := ext.SaveMetadata(image.Filename, &extractor.BootFiles{Distro: image.Distro, BootParams: image.BootParams}); err != nil {
+		log.Printf("Extraction: failed to rewrite the metadata of %s: %v", image.Filename, err)
+	}
+}
+
+// reextract runs a full extraction in place of one that can't be finished,
+// deferring it to the next start if the ISO isn't here yet.
+func (h *Handler) reextract(image *models.Image, job *jobs.Job) error {
+	if h.Libraries.Fetch(image.Filename) {
+		job.Logf("The ISO is being cached from its remote library; extraction will resume at the next start")
+		h.saveExtractionStep(image.Filename, models.ExtractStepFiles, nil)
+		return nil
+	}
+	return h.extractImage(h.background(), image, job)
+}
+
+func bootFilesPresent(files *extractor.BootFiles) bool {
+	for _, p := range []string{files.Kernel, files.Initrd} {
+		if p == "" {
+			return false
+		}
+		if _, err := os.Stat(p); err != nil {
+			return false
+		}
+	}
+	return true
+}
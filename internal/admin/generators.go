@@ -0,0 +1,184 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"bootimus/internal/autoinstall"
+	"bootimus/internal/models"
+)
+
+// generatorScriptTypes maps a generator to the script type its output is
+// stored and served as.
+var generatorScriptTypes = map[string]string{
+	"windows":        "autounattend",
+	"windows-server": "autounattend",
+	"preseed":        "preseed",
+	"kickstart":      "kickstart",
+}
+
+// renderGenerator renders a script. Secrets are placeholders in it and the
+// values they stand for, to be stored encrypted rather than in the script.
+func renderGenerator(generator string, params []byte) (script string, secrets map[string]string, err error) {
+	switch generator {
+	case "windows":
+		var a autoinstall.WindowsAnswer
+		if err := json.Unmarshal(params, &a); err != nil {
+			return "", nil, err
+		}
+		return autoinstall.RenderWindows(&a)
+	case "windows-server":
+		var a autoinstall.WindowsServerAnswer
+		if err := json.Unmarshal(params, &a); err != nil {
+			return "", nil, err
+		}
+		return autoinstall.RenderWindowsServer(&a)
+	case "preseed":
+		var a autoinstall.PreseedAnswer
+		if err := json.Unmarshal(params, &a); err != nil {
+			return "", nil, err
+		}
+		script, err = autoinstall.RenderPreseed(&a)
+		return script, nil, err
+	case "kickstart":
+		var a autoinstall.KickstartAnswer
+		if err := json.Unmarshal(params, &a); err != nil {
+			return "", nil, err
+		}
+		script, err = autoinstall.RenderKickstart(&a)
+		return script, nil, err
+	}
+	return "", nil, errors.New("unknown generator")
+}
+
+// secretParams are the generator fields stored as secrets, by the
+// placeholder that stands for them in the script.
+var secretParams = map[string]string{
+	autoinstall.AdminPasswordVar:  "admin_password",
+	autoinstall.DomainPasswordVar: "domain_password",
+}
+
+// withStoredSecrets fills secret fields left out of params from the image's
+// stored secrets, so a script can be regenerated without typing them again.
+func withStoredSecrets(params []byte, image *models.Image) []byte {
+	if image == nil || image.AutoInstallSecrets == "" {
+		return params
+	}
+	var stored map[string]string
+	if json.Unmarshal([]byte(image.AutoInstallSecrets), &stored) != nil {
+		return params
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal(params, &fields) != nil {
+		return params
+	}
+	for placeholder, field := range secretParams {
+		if v, _ := fields[field].(string); v == "" && stored[placeholder] != "" {
+			fields[field] = stored[placeholder]
+		}
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return params
+	}
+	return out
+}
+
+// withoutSecrets is params with the secret fields removed, for storing.
+func withoutSecrets(params []byte) []byte {
+	var fields map[string]interface{}
+	if json.Unmarshal(params, &fields) != nil {
+		return params
+	}
+	for _, field := range secretParams {
+		delete(fields, field)
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return params
+	}
+	return out
+}
+
+// GenerateAutoInstall renders an auto-install script from structured
+// parameters. With ?filename=<image>&save=true the structured form and the
+// rendered script are both stored on the image.
+func (h *Handler) GenerateAutoInstall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+
+	generator := r.URL.Query().Get("type")
+	scriptType, ok := generatorScriptTypes[generator]
+	if !ok {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Unknown generator type"})
+		return
+	}
+
+	params, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil || !json.Valid(params) {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+
+	filename := r.URL.Query().Get("filename")
+	save := filename != "" && r.URL.Query().Get("save") == "true"
+	var image *models.Image
+	if save {
+		if image, err = h.storage.GetImage(filename); err != nil {
+			h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
+			return
+		}
+		params = withStoredSecrets(params, image)
+	}
+
+	script, secrets, err := renderGenerator(generator, params)
+	if err != nil {
+		var verrs autoinstall.ValidationErrors
+		if errors.As(err, &verrs) {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Validation failed", Data: verrs})
+			return
+		}
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	result := map[string]interface{}{
+		"script":      script,
+		"script_type": scriptType,
+	}
+
+	if !save {
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: result})
+		return
+	}
+
+	image.AutoInstallScript = script
+	image.AutoInstallScriptType = scriptType
+	image.AutoInstallEnabled = true
+	image.AutoInstallGenerator = generator
+	image.AutoInstallParams = string(withoutSecrets(params))
+	image.AutoInstallSecrets = ""
+	if len(secrets) > 0 {
+		b, _ := json.Marshal(secrets)
+		image.AutoInstallSecrets = models.Secret(b)
+	}
+	if err := h.storage.UpdateImage(filename, image); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	log.Printf("Auto-install script generated for %s (generator: %s, %d bytes)", filename, generator, len(script))
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Auto-install script generated and saved", Data: result})
+}
+
+func orEmptyJSON(s string) string {
+	if s == "" {
+		return "null"
+	}
+	return s
+}
@@ -0,0 +1,729 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bootimus/internal/adminpb"
+	"bootimus/internal/jobs"
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// watchInterval is how often WatchJob looks at a job for changes.
+const watchInterval = 500 * time.Millisecond
+
+// GRPC returns the gRPC admin service. It is meant to be served through
+// its ServeHTTP on the admin listener, behind the same authentication as
+// the REST API.
+func (h *Handler) GRPC() *grpc.Server {
+	s := grpc.NewServer()
+	adminpb.RegisterAdminServer(s, &grpcAdmin{h: h})
+	reflection.Register(s)
+	return s
+}
+
+// Gateway returns the grpc-gateway translation of the gRPC service: its
+// unary RPCs as JSON over HTTP under /api/v1/, with the field names the
+// REST API uses. The RPCs run in process, so it needs the same
+// authentication in front of it as GRPC.
+func (h *Handler) Gateway(ctx context.Context) (http.Handler, error) {
+	mux := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+		MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+		UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+	}))
+	if err := adminpb.RegisterAdminHandlerServer(ctx, mux, &grpcAdmin{h: h}); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+type grpcAdmin struct {
+	adminpb.UnimplementedAdminServer
+	h *Handler
+}
+
+func (g *grpcAdmin) ListImages(ctx context.Context, req *adminpb.ListImagesRequest) (*adminpb.ListImagesResponse, error) {
+	images, err := g.h.storage.ListImages()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &adminpb.ListImagesResponse{}
+	for _, img := range images {
+		resp.Images = append(resp.Images, imageProto(img))
+	}
+	return resp, nil
+}
+
+func (g *grpcAdmin) GetImage(ctx context.Context, req *adminpb.ImageRequest) (*adminpb.Image, error) {
+	image, err := g.h.storage.GetImage(req.Filename)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Image not found")
+	}
+	return imageProto(image), nil
+}
+
+func (g *grpcAdmin) UpdateImage(ctx context.Context, req *adminpb.UpdateImageRequest) (*adminpb.Image, error) {
+	filename := req.GetImage().GetFilename()
+	if filename == "" {
+		return nil, status.Error(codes.InvalidArgument, "image.filename is required")
+	}
+	updates, err := maskedFields(req.Image, req.UpdateMask)
+	if err != nil {
+		return nil, err
+	}
+	clearZeroIDs(updates, "group_id")
+	version, versioned := maskedVersion(updates)
+	image, code, err := g.h.updateImage(ctx, filename, updates, version, versioned)
+	if err != nil {
+		return nil, grpcError(code, err)
+	}
+	return imageProto(image), nil
+}
+
+func (g *grpcAdmin) DeleteImage(ctx context.Context, req *adminpb.DeleteImageRequest) (*emptypb.Empty, error) {
+	if req.Filename == "" {
+		return nil, status.Error(codes.InvalidArgument, "filename is required")
+	}
+	_, variants, code, err := g.h.deleteImage(req.Filename, req.DeleteFile)
+	if errors.Is(err, errHasVariants) {
+		return nil, status.Errorf(codes.FailedPrecondition, "Image has %d variant(s) sharing its ISO; delete them first: %s", len(variants), strings.Join(variants, ", "))
+	}
+	if err != nil {
+		return nil, grpcError(code, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (g *grpcAdmin) ExtractImage(ctx context.Context, req *adminpb.ExtractImageRequest) (*adminpb.Job, error) {
+	if req.Filename == "" {
+		return nil, status.Error(codes.InvalidArgument, "filename is required")
+	}
+	image, err := g.h.storage.GetImage(req.Filename)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Image not found")
+	}
+	job, code, err := g.h.startExtraction(image)
+	if err != nil {
+		return nil, status.Error(grpcCode(code), err.Error())
+	}
+	return jobProto(job.Snapshot()), nil
+}
+
+func (g *grpcAdmin) ListClients(ctx context.Context, req *adminpb.ListClientsRequest) (*adminpb.ListClientsResponse, error) {
+	clients, err := g.h.storage.ListClients()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &adminpb.ListClientsResponse{}
+	for _, c := range clients {
+		resp.Clients = append(resp.Clients, clientProto(c))
+	}
+	return resp, nil
+}
+
+func (g *grpcAdmin) GetClient(ctx context.Context, req *adminpb.ClientRequest) (*adminpb.Client, error) {
+	client, err := g.h.storage.GetClient(normalMAC(req.MacAddress))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Client not found")
+	}
+	return clientProto(client), nil
+}
+
+func (g *grpcAdmin) CreateClient(ctx context.Context, req *adminpb.CreateClientRequest) (*adminpb.Client, error) {
+	c := req.GetClient()
+	if c.GetMacAddress() == "" {
+		return nil, status.Error(codes.InvalidArgument, "client.mac_address is required")
+	}
+	client := &models.Client{
+		MACAddress:      c.MacAddress,
+		Name:            c.Name,
+		Description:     c.Description,
+		Tags:            c.Tags,
+		BootloaderSet:   c.BootloaderSet,
+		AutoInstallFile: c.AutoInstallFile,
+	}
+	if err := g.h.createClient(client); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return clientProto(client), nil
+}
+
+func (g *grpcAdmin) UpdateClient(ctx context.Context, req *adminpb.UpdateClientRequest) (*adminpb.Client, error) {
+	mac := normalMAC(req.GetClient().GetMacAddress())
+	if mac == "" {
+		return nil, status.Error(codes.InvalidArgument, "client.mac_address is required")
+	}
+	updates, err := maskedFields(req.Client, req.UpdateMask)
+	if err != nil {
+		return nil, err
+	}
+	clearZeroIDs(updates, "client_group_id", "default_image_id")
+	// The REST API takes the assignments as image_filenames.
+	if v, ok := updates["allowed_images"]; ok {
+		updates["image_filenames"] = v
+	}
+	version, versioned := maskedVersion(updates)
+	client, code, err := g.h.updateClient(mac, updates, version, versioned)
+	if err != nil {
+		return nil, grpcError(code, err)
+	}
+	return clientProto(client), nil
+}
+
+func (g *grpcAdmin) DeleteClient(ctx context.Context, req *adminpb.ClientRequest) (*emptypb.Empty, error) {
+	if code, err := g.h.deleteClient(normalMAC(req.MacAddress)); err != nil {
+		return nil, grpcError(code, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (g *grpcAdmin) ListImageGroups(ctx context.Context, req *adminpb.ListImageGroupsRequest) (*adminpb.ListImageGroupsResponse, error) {
+	groups, err := g.h.storage.ListImageGroups()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &adminpb.ListImageGroupsResponse{}
+	for _, group := range groups {
+		resp.Groups = append(resp.Groups, imageGroupProto(group))
+	}
+	return resp, nil
+}
+
+func (g *grpcAdmin) CreateImageGroup(ctx context.Context, req *adminpb.ImageGroup) (*adminpb.ImageGroup, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "Group name is required")
+	}
+	var group models.ImageGroup
+	setImageGroup(&group, req)
+	if err := g.h.storage.CreateImageGroup(&group); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("Created image group: %s (ID: %d)", group.Name, group.ID)
+	return imageGroupProto(&group), nil
+}
+
+func (g *grpcAdmin) UpdateImageGroup(ctx context.Context, req *adminpb.ImageGroup) (*adminpb.ImageGroup, error) {
+	group, err := g.h.storage.GetImageGroup(uint(req.Id))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Group not found")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "Group name is required")
+	}
+	setImageGroup(group, req)
+	group.Parent = nil
+	if err := g.h.storage.UpdateImageGroup(group.ID, group); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("Updated image group: %s (ID: %d)", group.Name, group.ID)
+	return imageGroupProto(group), nil
+}
+
+func (g *grpcAdmin) DeleteImageGroup(ctx context.Context, req *adminpb.GroupRequest) (*emptypb.Empty, error) {
+	group, err := g.h.storage.GetImageGroup(uint(req.Id))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Group not found")
+	}
+	if err := g.h.storage.DeleteImageGroup(group.ID); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("Deleted image group: %s (ID: %d)", group.Name, group.ID)
+	return &emptypb.Empty{}, nil
+}
+
+func (g *grpcAdmin) ListClientGroups(ctx context.Context, req *adminpb.ListClientGroupsRequest) (*adminpb.ListClientGroupsResponse, error) {
+	groups, err := g.h.storage.ListClientGroups()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &adminpb.ListClientGroupsResponse{}
+	for _, group := range groups {
+		members, _ := g.h.storage.ListClientsInGroup(group.ID)
+		pb := clientGroupProto(group)
+		pb.MemberCount = int32(len(members))
+		resp.Groups = append(resp.Groups, pb)
+	}
+	return resp, nil
+}
+
+func (g *grpcAdmin) GetClientGroup(ctx context.Context, req *adminpb.GroupRequest) (*adminpb.ClientGroup, error) {
+	group, err := g.h.storage.GetClientGroup(uint(req.Id))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Group not found")
+	}
+	members, _ := g.h.storage.ListClientsInGroup(group.ID)
+	pb := clientGroupProto(group)
+	pb.MemberCount = int32(len(members))
+	for _, m := range members {
+		pb.Members = append(pb.Members, clientProto(m))
+	}
+	return pb, nil
+}
+
+func (g *grpcAdmin) CreateClientGroup(ctx context.Context, req *adminpb.ClientGroup) (*adminpb.ClientGroup, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "Group name is required")
+	}
+	var group models.ClientGroup
+	setClientGroup(&group, req)
+	if err := g.h.storage.CreateClientGroup(&group); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("Created client group: %s (ID: %d)", group.Name, group.ID)
+	return clientGroupProto(&group), nil
+}
+
+func (g *grpcAdmin) UpdateClientGroup(ctx context.Context, req *adminpb.ClientGroup) (*adminpb.ClientGroup, error) {
+	group, err := g.h.storage.GetClientGroup(uint(req.Id))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Group not found")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "Group name is required")
+	}
+	// The group as stored, with the fields the message carries replaced,
+	// so the IPMI credentials it doesn't carry are kept.
+	setClientGroup(group, req)
+	if err := g.h.storage.UpdateClientGroup(group.ID, group); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("Updated client group: %s (ID: %d)", group.Name, group.ID)
+	return clientGroupProto(group), nil
+}
+
+func (g *grpcAdmin) DeleteClientGroup(ctx context.Context, req *adminpb.GroupRequest) (*emptypb.Empty, error) {
+	group, err := g.h.storage.GetClientGroup(uint(req.Id))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "Group not found")
+	}
+	if err := g.h.storage.DeleteClientGroup(group.ID); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("Deleted client group: %s (ID: %d)", group.Name, group.ID)
+	return &emptypb.Empty{}, nil
+}
+
+func (g *grpcAdmin) ListBootParams(ctx context.Context, req *adminpb.ListBootParamsRequest) (*adminpb.ListBootParamsResponse, error) {
+	overrides, err := g.h.storage.ListBootParamOverrides(normalMAC(req.MacAddress))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &adminpb.ListBootParamsResponse{}
+	for _, o := range overrides {
+		resp.Overrides = append(resp.Overrides, bootParamsProto(o))
+	}
+	return resp, nil
+}
+
+func (g *grpcAdmin) SaveBootParams(ctx context.Context, req *adminpb.BootParamOverride) (*adminpb.BootParamOverride, error) {
+	o, err := g.h.bootParamOverride(bootParamRequest{
+		MACAddress:    req.MacAddress,
+		ImageFilename: req.ImageFilename,
+		Params:        req.Params,
+		Replace:       req.Replace,
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := g.h.storage.SaveBootParamOverride(o); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("Admin: boot params for %s (image: %q) set to %q (replace: %v)", o.MACAddress, o.ImageFilename, o.Params, o.Replace)
+	return bootParamsProto(o), nil
+}
+
+func (g *grpcAdmin) DeleteBootParams(ctx context.Context, req *adminpb.BootParamsRequest) (*emptypb.Empty, error) {
+	if err := g.h.storage.DeleteBootParamOverride(uint(req.Id)); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (g *grpcAdmin) GetMenuSettings(ctx context.Context, req *adminpb.MenuSettingsRequest) (*adminpb.MenuSettings, error) {
+	theme, err := g.h.storage.GetMenuTheme()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return menuSettingsProto(theme), nil
+}
+
+func (g *grpcAdmin) UpdateMenuSettings(ctx context.Context, req *adminpb.MenuSettings) (*adminpb.MenuSettings, error) {
+	theme := &models.MenuTheme{
+		Title:                    req.Title,
+		MenuTimeout:              int(req.MenuTimeout),
+		DefaultMenuItem:          req.DefaultMenuItem,
+		TextColour:               req.TextColour,
+		BackgroundColour:         req.BackgroundColour,
+		SelectedTextColour:       req.SelectedTextColour,
+		SelectedBackgroundColour: req.SelectedBackgroundColour,
+		MOTD:                     req.Motd,
+	}
+	if err := theme.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := g.h.storage.UpdateMenuTheme(theme); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("Admin: Updated menu theme settings")
+	return menuSettingsProto(theme), nil
+}
+
+func (g *grpcAdmin) ListJobs(ctx context.Context, req *adminpb.ListJobsRequest) (*adminpb.ListJobsResponse, error) {
+	resp := &adminpb.ListJobsResponse{}
+	for _, j := range g.h.jobs.List() {
+		if (req.Kind == "" || j.Kind == req.Kind) && (req.Target == "" || j.Target == req.Target) && (req.Status == "" || j.Status == req.Status) {
+			resp.Jobs = append(resp.Jobs, jobProto(j))
+		}
+	}
+	return resp, nil
+}
+
+func (g *grpcAdmin) job(id uint64) (*jobs.Job, error) {
+	job, ok := g.h.jobs.Get(id)
+	if !ok {
+		return nil, status.Error(codes.NotFound, "Job not found")
+	}
+	return job, nil
+}
+
+func (g *grpcAdmin) GetJob(ctx context.Context, req *adminpb.JobRequest) (*adminpb.Job, error) {
+	job, err := g.job(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return jobProto(job.Snapshot()), nil
+}
+
+func (g *grpcAdmin) CancelJob(ctx context.Context, req *adminpb.JobRequest) (*adminpb.Job, error) {
+	job, err := g.job(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !job.Cancel() {
+		return nil, status.Error(codes.FailedPrecondition, "The job has finished or can't be cancelled")
+	}
+	return jobProto(job.Snapshot()), nil
+}
+
+func (g *grpcAdmin) RetryJob(ctx context.Context, req *adminpb.JobRequest) (*adminpb.Job, error) {
+	retry, err := g.h.jobs.Retry(g.h.background(), req.Id)
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, jobs.ErrRunning):
+		return nil, status.Error(codes.AlreadyExists, "Another job for the same target is running")
+	case err != nil:
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return jobProto(retry.Snapshot()), nil
+}
+
+func (g *grpcAdmin) WatchJob(req *adminpb.WatchJobRequest, stream adminpb.Admin_WatchJobServer) error {
+	job, err := g.job(req.Id)
+	if err != nil {
+		return err
+	}
+	since := max(0, int(req.Since))
+	var last jobs.Info
+	first := true
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		info := job.Snapshot()
+		lines := job.Lines(since)
+		since += len(lines)
+		if first || len(lines) > 0 || changed(last, info) {
+			if err := stream.Send(&adminpb.JobEvent{Job: jobProto(info), Log: lines}); err != nil {
+				return err
+			}
+			first, last = false, info
+		}
+		if info.Status != jobs.StatusRunning {
+			return nil
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// changed reports whether a watcher should hear about b, having seen a.
+func changed(a, b jobs.Info) bool {
+	return a.Status != b.Status || a.Progress != b.Progress || a.Stage != b.Stage ||
+		a.Files != b.Files || a.Bytes != b.Bytes || a.TotalBytes != b.TotalBytes || a.Attempts != b.Attempts
+}
+
+func (g *grpcAdmin) StreamLogs(req *adminpb.StreamLogsRequest, stream adminpb.Admin_StreamLogsServer) error {
+	if g.h.SubscribeLogs == nil {
+		return status.Error(codes.Unavailable, "log streaming is not available")
+	}
+	lines, stop := g.h.SubscribeLogs()
+	defer stop()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case msg, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(&adminpb.LogLine{Message: msg}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func jobProto(info jobs.Info) *adminpb.Job {
+	j := &adminpb.Job{
+		Id:          info.ID,
+		Kind:        info.Kind,
+		Target:      info.Target,
+		Params:      info.Params,
+		Status:      info.Status,
+		Error:       info.Error,
+		StartedAt:   info.StartedAt.Unix(),
+		LogLines:    int32(info.LogLines),
+		Progress:    int32(info.Progress),
+		Attempts:    int32(info.Attempts),
+		RetryOf:     info.RetryOf,
+		Stage:       info.Stage,
+		Files:       info.Files,
+		Bytes:       info.Bytes,
+		TotalBytes:  info.TotalBytes,
+		Cancellable: info.Cancellable,
+		Retryable:   info.Retryable,
+	}
+	if info.FinishedAt != nil {
+		j.FinishedAt = info.FinishedAt.Unix()
+	}
+	return j
+}
+
+// maskedFields returns the fields of msg that mask names as the JSON
+// object the REST API's update handlers take.
+func maskedFields(msg proto.Message, mask *fieldmaskpb.FieldMask) (map[string]interface{}, error) {
+	if len(mask.GetPaths()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+	b, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(msg)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	updates := make(map[string]interface{}, len(mask.Paths))
+	for _, path := range mask.Paths {
+		v, ok := all[path]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "update_mask: no field %q", path)
+		}
+		updates[path] = v
+	}
+	return updates, nil
+}
+
+// clearZeroIDs turns the ID fields among updates that are 0, which is
+// how a message says "none", into the null the REST API takes.
+func clearZeroIDs(updates map[string]interface{}, fields ...string) {
+	for _, f := range fields {
+		if v, ok := updates[f]; ok && v == float64(0) {
+			updates[f] = nil
+		}
+	}
+}
+
+// maskedVersion is the version an update was read at, if its mask names
+// one.
+func maskedVersion(updates map[string]interface{}) (int, bool) {
+	v, ok := updates["version"].(float64)
+	return int(v), ok
+}
+
+func normalMAC(mac string) string {
+	return strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
+}
+
+func optionalID(id uint32) *uint {
+	if id == 0 {
+		return nil
+	}
+	v := uint(id)
+	return &v
+}
+
+func protoID(id *uint) uint32 {
+	if id == nil {
+		return 0
+	}
+	return uint32(*id)
+}
+
+func imageProto(img *models.Image) *adminpb.Image {
+	return &adminpb.Image{
+		Id:              uint32(img.ID),
+		Name:            img.Name,
+		Filename:        img.Filename,
+		Size:            img.Size,
+		Enabled:         img.Enabled,
+		Public:          img.Public,
+		Distro:          img.Distro,
+		BootMethod:      img.BootMethod,
+		Extracted:       img.Extracted,
+		ExtractionError: img.ExtractionError,
+		Version:         int32(img.Version),
+		Description:     img.Description,
+		GroupId:         protoID(img.GroupID),
+		Order:           int32(img.Order),
+		BootParams:      img.BootParams,
+		AutoInstallFile: img.AutoInstallFile,
+		RescueEnabled:   img.RescueEnabled,
+		RescueParams:    img.RescueParams,
+		PinProtected:    img.PINProtected,
+		Sha256:          img.SHA256,
+		Arch:            img.Arch,
+	}
+}
+
+func clientProto(c *models.Client) *adminpb.Client {
+	pb := &adminpb.Client{
+		Id:               uint32(c.ID),
+		MacAddress:       c.MACAddress,
+		Name:             c.Name,
+		Description:      c.Description,
+		Tags:             c.Tags,
+		Enabled:          c.Enabled,
+		ShowPublicImages: c.ShowPublicImages,
+		LiteInitrd:       c.LiteInitrd,
+		BootloaderSet:    c.BootloaderSet,
+		AllowedImages:    c.AllowedImages,
+		NextBootImage:    c.NextBootImage,
+		LocalBoot:        c.LocalBoot,
+		DefaultImageId:   protoID(c.DefaultImageID),
+		BootImmediately:  c.BootImmediately,
+		Static:           c.Static,
+		ClientGroupId:    protoID(c.ClientGroupID),
+		AutoInstallFile:  c.AutoInstallFile,
+		ReservedIp:       c.ReservedIP,
+		SwitchName:       c.SwitchName,
+		SwitchPort:       c.SwitchPort,
+		BootCount:        int32(c.BootCount),
+		Version:          int32(c.Version),
+	}
+	if c.LastBoot != nil {
+		pb.LastBoot = c.LastBoot.Unix()
+	}
+	return pb
+}
+
+func imageGroupProto(g *models.ImageGroup) *adminpb.ImageGroup {
+	return &adminpb.ImageGroup{
+		Id:           uint32(g.ID),
+		Name:         g.Name,
+		Description:  g.Description,
+		ParentId:     protoID(g.ParentID),
+		Order:        int32(g.Order),
+		Enabled:      g.Enabled,
+		PinProtected: g.PINProtected,
+	}
+}
+
+func setImageGroup(g *models.ImageGroup, pb *adminpb.ImageGroup) {
+	g.Name = pb.Name
+	g.Description = pb.Description
+	g.ParentID = optionalID(pb.ParentId)
+	g.Order = int(pb.Order)
+	g.Enabled = pb.Enabled
+	g.PINProtected = pb.PinProtected
+}
+
+func clientGroupProto(g *models.ClientGroup) *adminpb.ClientGroup {
+	return &adminpb.ClientGroup{
+		Id:                 uint32(g.ID),
+		Name:               g.Name,
+		Description:        g.Description,
+		Enabled:            g.Enabled,
+		AllowedImages:      g.AllowedImages,
+		BootloaderSet:      g.BootloaderSet,
+		WolBroadcastAddr:   g.WOLBroadcastAddr,
+		StaggerDelayMillis: int32(g.StaggerDelayMillis),
+	}
+}
+
+func setClientGroup(g *models.ClientGroup, pb *adminpb.ClientGroup) {
+	g.Name = pb.Name
+	g.Description = pb.Description
+	g.Enabled = pb.Enabled
+	g.AllowedImages = pb.AllowedImages
+	g.BootloaderSet = pb.BootloaderSet
+	g.WOLBroadcastAddr = pb.WolBroadcastAddr
+	g.StaggerDelayMillis = int(pb.StaggerDelayMillis)
+}
+
+func bootParamsProto(o *models.BootParamOverride) *adminpb.BootParamOverride {
+	return &adminpb.BootParamOverride{
+		Id:            uint32(o.ID),
+		MacAddress:    o.MACAddress,
+		ImageFilename: o.ImageFilename,
+		Params:        o.Params,
+		Replace:       o.Replace,
+		Once:          o.Once,
+	}
+}
+
+func menuSettingsProto(t *models.MenuTheme) *adminpb.MenuSettings {
+	return &adminpb.MenuSettings{
+		Title:                    t.Title,
+		MenuTimeout:              int32(t.MenuTimeout),
+		DefaultMenuItem:          t.DefaultMenuItem,
+		TextColour:               t.TextColour,
+		BackgroundColour:         t.BackgroundColour,
+		SelectedTextColour:       t.SelectedTextColour,
+		SelectedBackgroundColour: t.SelectedBackgroundColour,
+		Motd:                     t.MOTD,
+	}
+}
+
+// grpcError is grpcCode for the shared update and delete helpers, whose
+// version conflicts are ABORTED so a client knows to read again and retry.
+func grpcError(httpStatus int, err error) error {
+	if errors.Is(err, storage.ErrConflict) {
+		return status.Error(codes.Aborted, "Changed by someone else; read it again and retry")
+	}
+	return status.Error(grpcCode(httpStatus), err.Error())
+}
+
+// grpcCode maps the HTTP status a shared helper answered with to a gRPC
+// code.
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
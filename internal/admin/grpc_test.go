@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bootimus/internal/adminpb"
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGatewayCoversAdminResources(t *testing.T) {
+	store, err := storage.NewSQLiteStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{storage: store}
+	gateway, err := h.Gateway(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(method, path, body string) (int, map[string]interface{}) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		gateway.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var out map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &out)
+		return rec.Code, out
+	}
+
+	const mac = "52:54:00:12:34:56"
+	if code, _ := call(http.MethodPost, "/api/v1/clients", `{"mac_address": "52-54-00-12-34-56", "name": "web1"}`); code != http.StatusOK {
+		t.Fatalf("create client: got %d", code)
+	}
+	code, got := call(http.MethodGet, "/api/v1/clients/"+mac, "")
+	if code != http.StatusOK || got["name"] != "web1" || got["enabled"] != true {
+		t.Fatalf("get client: %d %v", code, got)
+	}
+	version := got["version"].(float64)
+
+	// Only the fields in the body change, including to false.
+	if code, got = call(http.MethodPatch, "/api/v1/clients/"+mac, `{"enabled": false, "allowed_images": ["a.iso"]}`); code != http.StatusOK {
+		t.Fatalf("patch client: got %d %v", code, got)
+	}
+	c, err := store.GetClient(mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "web1" || c.Enabled || len(c.AllowedImages) != 1 || c.AllowedImages[0] != "a.iso" {
+		t.Errorf("after patch: name %q, enabled %v, images %v", c.Name, c.Enabled, c.AllowedImages)
+	}
+
+	// An update made against an old version is refused.
+	if code, _ = call(http.MethodPatch, "/api/v1/clients/"+mac, fmt.Sprintf(`{"name": "stale", "version": %v}`, version)); code != http.StatusConflict {
+		t.Errorf("stale patch: got %d, want 409", code)
+	}
+
+	if code, got = call(http.MethodPost, "/api/v1/image-groups", `{"name": "Linux", "enabled": true}`); code != http.StatusOK {
+		t.Fatalf("create image group: got %d", code)
+	}
+	groupID := got["id"].(float64)
+	if code, got = call(http.MethodGet, "/api/v1/image-groups", ""); code != http.StatusOK || len(got["groups"].([]interface{})) != 1 {
+		t.Errorf("list image groups: %d %v", code, got)
+	}
+	if code, _ = call(http.MethodDelete, fmt.Sprintf("/api/v1/image-groups/%v", groupID), ""); code != http.StatusOK {
+		t.Errorf("delete image group: got %d", code)
+	}
+
+	if code, _ = call(http.MethodPost, "/api/v1/client-groups", `{"name": "rack4", "enabled": true}`); code != http.StatusOK {
+		t.Errorf("create client group: got %d", code)
+	}
+
+	if code, _ = call(http.MethodPost, "/api/v1/boot-params", `{"mac_address": "`+mac+`", "params": "console=ttyS0\nimgfetch"}`); code != http.StatusBadRequest {
+		t.Errorf("multi-line boot params: got %d, want 400", code)
+	}
+	if code, _ = call(http.MethodPost, "/api/v1/boot-params", `{"mac_address": "`+mac+`", "params": "console=ttyS0"}`); code != http.StatusOK {
+		t.Errorf("save boot params: got %d", code)
+	}
+	if code, got = call(http.MethodGet, "/api/v1/boot-params?mac_address="+mac, ""); code != http.StatusOK || len(got["overrides"].([]interface{})) != 1 {
+		t.Errorf("list boot params: %d %v", code, got)
+	}
+
+	if code, _ = call(http.MethodPut, "/api/v1/settings/menu", `{"title": "Lab", "menu_timeout": -1}`); code != http.StatusBadRequest {
+		t.Errorf("invalid menu settings: got %d, want 400", code)
+	}
+	if code, _ = call(http.MethodPut, "/api/v1/settings/menu", `{"title": "Lab", "menu_timeout": 10, "default_menu_item": "local"}`); code != http.StatusOK {
+		t.Errorf("menu settings: got %d", code)
+	}
+	if theme, err := store.GetMenuTheme(); err != nil || theme.Title != "Lab" {
+		t.Errorf("menu settings not saved: %v %v", theme, err)
+	}
+
+	if code, _ = call(http.MethodDelete, "/api/v1/clients/"+mac, ""); code != http.StatusOK {
+		t.Errorf("delete client: got %d", code)
+	}
+	if _, err := store.GetClient(mac); err == nil {
+		t.Error("client still there after delete")
+	}
+}
+
+func TestGRPCUpdateNeedsMask(t *testing.T) {
+	store, err := storage.NewSQLiteStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateClient(&models.Client{MACAddress: "52:54:00:12:34:56", Name: "web1"}); err != nil {
+		t.Fatal(err)
+	}
+	g := &grpcAdmin{h: &Handler{storage: store}}
+	_, err = g.UpdateClient(context.Background(), &adminpb.UpdateClientRequest{Client: &adminpb.Client{MacAddress: "52:54:00:12:34:56"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("update without a mask: got %v, want InvalidArgument", err)
+	}
+}
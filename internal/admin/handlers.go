@@ -5,13 +5,16 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/ed25519"
+	"crypto/md5"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -23,13 +26,30 @@ import (
 
 	"bootimus/bootloaders"
 	"bootimus/internal/autoinstall"
+	"bootimus/internal/bootmethod"
+	"bootimus/internal/branding"
+	"bootimus/internal/ctxio"
 	"bootimus/internal/extractor"
+	"bootimus/internal/hostip"
+	"bootimus/internal/jobs"
+	"bootimus/internal/library"
+	"bootimus/internal/matchbox"
 	"bootimus/internal/models"
+	"bootimus/internal/netboot"
+	"bootimus/internal/policy"
 	"bootimus/internal/profiles"
+	"bootimus/internal/rangestats"
+	"bootimus/internal/ratelimit"
+	"bootimus/internal/redact"
 	"bootimus/internal/redfish"
+	"bootimus/internal/securepath"
 	"bootimus/internal/smb"
+	"bootimus/internal/stats"
 	"bootimus/internal/storage"
+	"bootimus/internal/switchport"
 	"bootimus/internal/sysstats"
+	"bootimus/internal/telemetry"
+	"bootimus/internal/throttle"
 	"bootimus/internal/tools"
 	"bootimus/internal/wim"
 	"bootimus/internal/wol"
@@ -60,18 +80,53 @@ type Handler struct {
 	autoInstallLib     *autoinstall.Library
 	extractionMu       sync.RWMutex
 	extractionStates   map[string]*extractionState
+	libraryMu          sync.Mutex // serialises filesystem scans against image deletes
+	jobs               *jobs.Manager
 	SchedulerReload    func() error
 	SchedulerRunNow    func(id uint) error
+	BootloaderKeys     []ed25519.PublicKey // trusted signers of bootloader update bundles
+	Matchbox           *matchbox.Store
+	Switchport         *switchport.Manager
+	MenuPreview        func(mac string) (string, error)
+	SubscribeLogs      func() (<-chan string, func()) // server log lines, and a func to stop them
+	Branding           *branding.Store
+	Stats              *stats.Recorder
+	Usage              *telemetry.Reporter // monthly usage reports
+	Libraries          *library.Set        // ISO directories, isoDir first for writes
+	IntegrityAlert     func(filename, reason string)
+	RateLimit          *ratelimit.Limiter // nil when the admin listener is not rate limited
+	BootPolicy         *policy.File
+	Ranges             *rangestats.Tracker
+	PolicyInput        func(mac, ip string) policy.Input
+	MediaScript        func() string // iPXE script for boot media made for this server
+	Transfers          func() []ActiveTransfer
+	DHCPRange          string          // set when the built-in DHCP server is leasing addresses
+	Context            context.Context // cancelled at shutdown
+	NetworkWarning     string          // why clients may not reach the server address
+
+	// ISCSITarget is the IQN an image is published under, empty while the
+	// iSCSI target is off.
+	ISCSITarget func(filename string) string
+
+	Catalog *profiles.Catalog // nil falls back to the built-in ISO catalog
+
+	Downloads *DownloadQueue
+
+	// DisabledBootMethods maps the boot methods whose server is off to the
+	// flag that turns it on.
+	DisabledBootMethods map[string]string
 }
 
 type extractionState struct {
 	reporter *extractor.ProgressReporter
 	status   string
 	errMsg   string
+	jobID    uint64
 }
 
 func NewHandler(store storage.Storage, dataDir string, isoDir string, bootDir string, version string, blSelector BootloaderSelector, tm *tools.Manager, wolBroadcastAddr string, pm *profiles.Manager, proxyDHCPEnabled bool, httpPort int, serverAddr string, smbPort int, smbManager *smb.Manager, smbRequested bool, autoInstallLib *autoinstall.Library) *Handler {
-	return &Handler{
+	libs, _ := library.New(isoDir, nil)
+	h := &Handler{
 		storage:            store,
 		dataDir:            dataDir,
 		isoDir:             isoDir,
@@ -89,7 +144,21 @@ func NewHandler(store storage.Storage, dataDir string, isoDir string, bootDir st
 		smbRequested:       smbRequested,
 		autoInstallLib:     autoInstallLib,
 		extractionStates:   make(map[string]*extractionState),
+		jobs:               jobs.New(store),
+		Libraries:          libs,
+		Downloads:          NewDownloadQueue(defaultMaxDownloads, 0, 0),
 	}
+	h.registerJobs()
+	return h
+}
+
+// background is the context for work that outlives the request that
+// started it: downloads, async jobs and the like.
+func (h *Handler) background() context.Context {
+	if h.Context != nil {
+		return h.Context
+	}
+	return context.Background()
 }
 
 func (h *Handler) computeSMBPatchFingerprint(img *models.Image) string {
@@ -120,7 +189,7 @@ func (h *Handler) patchWindowsBootWim(isoFilename string) bool {
 	}
 
 	isoBase := strings.TrimSuffix(isoFilename, filepath.Ext(isoFilename))
-	sharePath := filepath.Join(h.isoDir, isoBase, "iso")
+	sharePath := filepath.Join(h.Libraries.Dir(isoFilename), isoBase, "iso")
 
 	bootWimPath := findExtractedBootWim(sharePath)
 	if bootWimPath == "" {
@@ -268,29 +337,6 @@ Z:\setup.exe /unattend:X:\AutoUnattend.xml
 	return base + launch
 }
 
-func isRunningInDocker() bool {
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		return true
-	}
-
-	data, err := os.ReadFile("/proc/1/cgroup")
-	if err == nil {
-		content := string(data)
-		if strings.Contains(content, "docker") || strings.Contains(content, "containerd") {
-			return true
-		}
-	}
-
-	if os.Getpid() == 1 {
-		entries, err := os.ReadDir("/proc")
-		if err == nil && len(entries) < 50 {
-			return true
-		}
-	}
-
-	return false
-}
-
 type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
@@ -298,6 +344,20 @@ type Response struct {
 	Error   string      `json:"error,omitempty"`
 }
 
+// requestVersion returns the record version the caller last read, taken
+// from an If-Match header or a "version" field in the body.
+func requestVersion(r *http.Request, updates map[string]interface{}) (int, bool) {
+	if v := strings.Trim(strings.TrimPrefix(r.Header.Get("If-Match"), "W/"), `"`); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n, true
+		}
+	}
+	if v, ok := updates["version"].(float64); ok {
+		return int(v), true
+	}
+	return 0, false
+}
+
 func (h *Handler) sendJSON(w http.ResponseWriter, status int, resp Response) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -321,10 +381,39 @@ func (h *Handler) ListClients(w http.ResponseWriter, r *http.Request) {
 		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
 		return
 	}
+	h.checkSecureBoot(clients...)
 
 	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: clients})
 }
 
+// checkSecureBoot fills in SecureBootWarnings for the images assigned to
+// each client that reported Secure Boot on.
+func (h *Handler) checkSecureBoot(clients ...*models.Client) {
+	var byFilename map[string]models.Image
+	for _, c := range clients {
+		if c.SecureBoot == nil || !*c.SecureBoot {
+			continue
+		}
+		if byFilename == nil {
+			images, err := h.storage.ListImages()
+			if err != nil {
+				return
+			}
+			byFilename = make(map[string]models.Image, len(images))
+			for _, img := range images {
+				byFilename[img.Filename] = *img
+			}
+		}
+		var assigned []models.Image
+		for _, f := range append([]string(c.AllowedImages), c.NextBootImage) {
+			if img, ok := byFilename[f]; ok {
+				assigned = append(assigned, img)
+			}
+		}
+		c.SecureBootWarnings = models.SecureBootWarnings(c, assigned)
+	}
+}
+
 func (h *Handler) GetClient(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
@@ -343,6 +432,7 @@ func (h *Handler) GetClient(w http.ResponseWriter, r *http.Request) {
 			clients, _ := h.storage.ListClients()
 			for _, c := range clients {
 				if c.ID == uint(id) {
+					h.checkSecureBoot(c)
 					h.sendJSON(w, http.StatusOK, Response{Success: true, Data: c})
 					return
 				}
@@ -359,6 +449,7 @@ func (h *Handler) GetClient(w http.ResponseWriter, r *http.Request) {
 		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Client not found"})
 		return
 	}
+	h.checkSecureBoot(client)
 
 	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: client})
 }
@@ -375,18 +466,27 @@ func (h *Handler) CreateClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.createClient(&client); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusCreated, Response{Success: true, Message: "Client created", Data: client})
+}
+
+// createClient adds a static client, enabled, as the REST and gRPC APIs
+// both do.
+func (h *Handler) createClient(client *models.Client) error {
 	client.MACAddress = strings.ToLower(strings.ReplaceAll(client.MACAddress, "-", ":"))
 
 	client.Enabled = true
 	client.Static = true
 
-	if err := h.storage.CreateClient(&client); err != nil {
-		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
-		return
+	if err := h.storage.CreateClient(client); err != nil {
+		return err
 	}
 
 	log.Printf("Admin: Client created - MAC: %s, Name: %s", client.MACAddress, client.Name)
-	h.sendJSON(w, http.StatusCreated, Response{Success: true, Message: "Client created", Data: client})
+	return nil
 }
 
 func (h *Handler) UpdateClient(w http.ResponseWriter, r *http.Request) {
@@ -407,11 +507,30 @@ func (h *Handler) UpdateClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client, err := h.storage.GetClient(mac)
+	version, versioned := requestVersion(r, updates)
+	client, code, err := h.updateClient(mac, updates, version, versioned)
+	if errors.Is(err, storage.ErrConflict) {
+		current, _ := h.storage.GetClient(mac)
+		h.sendJSON(w, code, Response{Success: false, Error: "Client was changed by someone else; reload and try again", Data: current})
+		return
+	}
 	if err != nil {
-		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Client not found"})
+		h.sendJSON(w, code, Response{Success: false, Error: err.Error()})
 		return
 	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Client updated", Data: client})
+}
+
+// updateClient applies updates, a JSON object's fields, to the client.
+// With versioned set the update is refused with storage.ErrConflict if the
+// client is no longer at version. The status is what the REST API answers
+// with on failure.
+func (h *Handler) updateClient(mac string, updates map[string]interface{}, version int, versioned bool) (*models.Client, int, error) {
+	client, err := h.storage.GetClient(mac)
+	if err != nil {
+		return nil, http.StatusNotFound, errors.New("Client not found")
+	}
+	before := *client
 
 	if name, ok := updates["name"].(string); ok {
 		client.Name = name
@@ -419,6 +538,14 @@ func (h *Handler) UpdateClient(w http.ResponseWriter, r *http.Request) {
 	if desc, ok := updates["description"].(string); ok {
 		client.Description = desc
 	}
+	if tags, ok := updates["tags"].([]interface{}); ok {
+		client.Tags = models.StringSlice{}
+		for _, t := range tags {
+			if tag, ok := t.(string); ok && strings.TrimSpace(tag) != "" {
+				client.Tags = append(client.Tags, strings.TrimSpace(tag))
+			}
+		}
+	}
 	if enabled, ok := updates["enabled"].(bool); ok {
 		client.Enabled = enabled
 	}
@@ -428,9 +555,32 @@ func (h *Handler) UpdateClient(w http.ResponseWriter, r *http.Request) {
 	if bls, ok := updates["bootloader_set"].(string); ok {
 		client.BootloaderSet = bls
 	}
+	if lite, ok := updates["lite_initrd"].(bool); ok {
+		client.LiteInitrd = lite
+	}
 	if aif, ok := updates["auto_install_file"].(string); ok {
 		client.AutoInstallFile = aif
 	}
+	if raw, ok := updates["auto_install_vars"].(map[string]interface{}); ok {
+		vars, err := autoInstallVarsFrom(raw)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		client.AutoInstallVars = vars
+	}
+	if rip, ok := updates["reserved_ip"].(string); ok {
+		rip = strings.TrimSpace(rip)
+		if err := h.checkReservedIP(mac, rip); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		client.ReservedIP = rip
+	}
+	if sw, ok := updates["switch_name"].(string); ok {
+		client.SwitchName = sw
+	}
+	if port, ok := updates["switch_port"].(string); ok {
+		client.SwitchPort = port
+	}
 	if groupID, ok := updates["client_group_id"]; ok {
 		if groupID == nil {
 			client.ClientGroupID = nil
@@ -439,14 +589,93 @@ func (h *Handler) UpdateClient(w http.ResponseWriter, r *http.Request) {
 			client.ClientGroupID = &groupIDUint
 		}
 	}
+	if imageID, ok := updates["default_image_id"]; ok {
+		if imageID == nil {
+			client.DefaultImageID = nil
+		} else if id, ok := imageID.(float64); ok {
+			if !h.imageIDExists(uint(id)) {
+				return nil, http.StatusBadRequest, fmt.Errorf("No image with ID %d", uint(id))
+			}
+			defaultID := uint(id)
+			client.DefaultImageID = &defaultID
+		}
+	}
+	if now, ok := updates["boot_immediately"].(bool); ok {
+		client.BootImmediately = now
+	}
+	if local, ok := updates["local_boot"].(bool); ok {
+		client.LocalBoot = local
+	}
 
-	if err := h.storage.UpdateClient(mac, client); err != nil {
-		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
-		return
+	var assign []string
+	if raw, ok := updates["image_filenames"].([]interface{}); ok {
+		assign = []string{}
+		for _, f := range raw {
+			if filename, ok := f.(string); ok {
+				assign = append(assign, filename)
+			}
+		}
+	}
+
+	// The client, its image assignments and the revision are saved
+	// together, or not at all.
+	err = h.storage.Transaction(func(tx storage.Storage) error {
+		var err error
+		if versioned {
+			err = tx.UpdateClientVersion(mac, client, version)
+		} else {
+			err = tx.UpdateClient(mac, client)
+		}
+		if err != nil {
+			return err
+		}
+		if assign != nil {
+			if err := tx.AssignImagesToClient(mac, assign); err != nil {
+				return err
+			}
+			client.AllowedImages = assign
+		}
+		return saveRevision(tx, revisionClient, mac, revisionUpdate, before)
+	})
+	if errors.Is(err, storage.ErrConflict) {
+		return nil, http.StatusConflict, err
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
 	}
 
 	log.Printf("Admin: Client updated - MAC: %s, Name: %s, Enabled: %v, ShowPublicImages: %v, BootloaderSet: %s", client.MACAddress, client.Name, client.Enabled, client.ShowPublicImages, client.BootloaderSet)
-	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Client updated", Data: client})
+	return client, http.StatusOK, nil
+}
+
+// autoInstallVarsFrom checks a client's auto-install variables from a JSON
+// object. Names must work as {{.Vars.name}} in a template.
+func autoInstallVarsFrom(raw map[string]interface{}) (models.StringMap, error) {
+	vars := models.StringMap{}
+	for k, v := range raw {
+		if !autoinstall.ValidVarName(k) {
+			return nil, fmt.Errorf("auto-install variable %q: use letters, digits and underscores", k)
+		}
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("auto-install variable %q must be a string", k)
+		}
+		vars[k] = str
+	}
+	return vars, nil
+}
+
+func (h *Handler) imageIDExists(id uint) bool {
+	images, err := h.storage.ListImages()
+	if err != nil {
+		return false
+	}
+	for _, img := range images {
+		if img.ID == id {
+			return true
+		}
+	}
+	return false
 }
 
 func (h *Handler) DeleteClient(w http.ResponseWriter, r *http.Request) {
@@ -461,13 +690,31 @@ func (h *Handler) DeleteClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.storage.DeleteClient(mac); err != nil {
-		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+	if code, err := h.deleteClient(mac); err != nil {
+		h.sendJSON(w, code, Response{Success: false, Error: err.Error()})
 		return
 	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Client deleted"})
+}
+
+func (h *Handler) deleteClient(mac string) (int, error) {
+	client, err := h.storage.GetClient(mac)
+	if err != nil {
+		return http.StatusNotFound, errors.New("Client not found")
+	}
+
+	err = h.storage.Transaction(func(tx storage.Storage) error {
+		if err := tx.DeleteClient(mac); err != nil {
+			return err
+		}
+		return saveRevision(tx, revisionClient, mac, revisionDelete, client)
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
 	log.Printf("Admin: Client deleted - MAC: %s", mac)
-	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Client deleted"})
+	return http.StatusOK, nil
 }
 
 func (h *Handler) WakeClient(w http.ResponseWriter, r *http.Request) {
@@ -516,6 +763,7 @@ func (h *Handler) SetNextBootImage(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		MACAddress    string `json:"mac_address"`
 		ImageFilename string `json:"image_filename"`
+		Once          bool   `json:"once"` // boot it without the menu, then the local disk
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
@@ -537,6 +785,20 @@ func (h *Handler) SetNextBootImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Once {
+		if _, err := h.storage.GetImage(req.ImageFilename); err != nil {
+			h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
+			return
+		}
+		if err := h.storage.SetOneShotBoot(req.MACAddress, req.ImageFilename); err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		log.Printf("Admin: Set one-shot boot of %s for %s", req.ImageFilename, req.MACAddress)
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: fmt.Sprintf("%s boots once, then from local disk", req.ImageFilename)})
+		return
+	}
+
 	if err := h.storage.SetNextBootImage(req.MACAddress, req.ImageFilename); err != nil {
 		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
 		return
@@ -574,6 +836,148 @@ func (h *Handler) PromoteClient(w http.ResponseWriter, r *http.Request) {
 	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Client promoted to static"})
 }
 
+// ClientAttestation shows a client's TPM enrollment (GET), approves the
+// pending EK/AK and PCR baseline (POST) or clears it so the machine has to
+// enrol again (DELETE).
+func (h *Handler) ClientAttestation(w http.ResponseWriter, r *http.Request) {
+	mac := r.URL.Query().Get("mac")
+	if mac == "" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Missing mac parameter"})
+		return
+	}
+	client, err := h.storage.GetClient(mac)
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Client not found"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var pcrs map[string]string
+		if client.AttestPCRs != "" {
+			json.Unmarshal([]byte(client.AttestPCRs), &pcrs)
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+			"trusted":      client.Trusted,
+			"status":       client.AttestStatus,
+			"approved":     client.AttestApproved,
+			"ek_hash":      client.AttestEKHash,
+			"ak_hash":      client.AttestAKHash,
+			"pcrs":         pcrs,
+			"error":        client.AttestError,
+			"attested_at":  client.AttestedAt,
+			"failures":     client.AttestFailures,
+			"last_failure": client.AttestLastFailure,
+			"failed_at":    client.AttestFailedAt,
+		}})
+		return
+
+	case http.MethodPost:
+		if client.AttestStatus != "pending" {
+			h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "No pending enrollment to approve; reset the client and let it attest again"})
+			return
+		}
+		client.AttestApproved = true
+		client.Trusted = true
+		client.AttestStatus = "trusted"
+
+	case http.MethodDelete:
+		client.Trusted = false
+		client.AttestStatus = ""
+		client.AttestApproved = false
+		client.AttestEKHash = ""
+		client.AttestAKHash = ""
+		client.AttestPCRs = ""
+		client.AttestError = ""
+		client.AttestedAt = nil
+		client.AttestFailures = 0
+		client.AttestLastFailure = ""
+		client.AttestFailedAt = nil
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+
+	if err := h.storage.UpdateClientAttestation(mac, client); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	if client.Trusted {
+		log.Printf("Admin: Approved TPM enrollment for %s (EK %s)", mac, client.AttestEKHash)
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Client is now trusted"})
+		return
+	}
+	log.Printf("Admin: Cleared TPM enrollment for %s", mac)
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Attestation reset"})
+}
+
+// ClientSwitchport shows a client's switch port state (GET) or moves the port
+// by hand (POST {"state": "provisioning"|"production"}).
+func (h *Handler) ClientSwitchport(w http.ResponseWriter, r *http.Request) {
+	mac := r.URL.Query().Get("mac")
+	if mac == "" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Missing mac parameter"})
+		return
+	}
+	client, err := h.storage.GetClient(mac)
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Client not found"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+			"enabled":  h.Switchport.Enabled(),
+			"switches": h.Switchport.Switches(),
+			"switch":   client.SwitchName,
+			"port":     client.SwitchPort,
+			"state":    client.SwitchState,
+			"vlan":     client.SwitchVLAN,
+			"error":    client.SwitchError,
+		}})
+		return
+	case http.MethodPost:
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+
+	var req struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+	if !h.Switchport.Enabled() {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Switch port automation is not configured"})
+		return
+	}
+	if req.State != switchport.StateProvisioning && req.State != switchport.StateProduction {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "state must be provisioning or production"})
+		return
+	}
+	if client.SwitchName == "" || client.SwitchPort == "" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Client has no switch port set"})
+		return
+	}
+
+	moveErr := h.Switchport.Apply(client, req.State, "manual")
+	if err := h.storage.UpdateClientSwitchState(mac, client); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	if moveErr != nil {
+		log.Printf("Admin: Moving switch port for %s failed: %v", mac, moveErr)
+		h.sendJSON(w, http.StatusBadGateway, Response{Success: false, Error: moveErr.Error()})
+		return
+	}
+	log.Printf("Admin: Moved %s %s (%s) to %s VLAN %d", client.SwitchName, client.SwitchPort, mac, req.State, client.SwitchVLAN)
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: fmt.Sprintf("Port moved to VLAN %d", client.SwitchVLAN), Data: client})
+}
+
 func (h *Handler) GetClientInventory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
@@ -624,44 +1028,15 @@ func (h *Handler) GetClientInventoryHistory(w http.ResponseWriter, r *http.Reque
 }
 
 func (h *Handler) syncFilesystemToDatabase() {
-	var isoFiles []models.SyncFile
-
-	err := filepath.WalkDir(h.isoDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		if !strings.HasSuffix(strings.ToLower(d.Name()), ".iso") {
-			return nil
-		}
+	h.libraryMu.Lock()
+	defer h.libraryMu.Unlock()
 
-		info, err := d.Info()
-		if err != nil {
-			log.Printf("Failed to get file info for %s: %v", path, err)
-			return nil
-		}
-
-		relPath, _ := filepath.Rel(h.isoDir, path)
-		groupPath := filepath.Dir(relPath)
-		if groupPath == "." {
-			groupPath = ""
-		}
-
-		isoFiles = append(isoFiles, models.SyncFile{
-			Name:      strings.TrimSuffix(d.Name(), filepath.Ext(d.Name())),
-			Filename:  relPath,
-			Size:      info.Size(),
-			GroupPath: groupPath,
-		})
-
-		return nil
-	})
+	isos, _, err := h.Libraries.Scan("")
 	if err != nil {
 		log.Printf("Failed to walk ISO directory for sync: %v", err)
 		return
 	}
+	isoFiles := syncFiles(isos)
 
 	if err := h.storage.SyncImages(isoFiles); err != nil {
 		log.Printf("Failed to sync images with database: %v", err)
@@ -677,12 +1052,12 @@ func (h *Handler) detectManualExtractions() {
 	}
 
 	for _, image := range images {
-		if image.Extracted {
+		if image.Extracted || image.CloneOf != "" || image.IsVirtual() {
 			continue
 		}
 
 		isoBase := strings.TrimSuffix(filepath.Base(image.Filename), filepath.Ext(image.Filename))
-		bootDir := filepath.Join(h.isoDir, filepath.Dir(image.Filename), isoBase)
+		bootDir := filepath.Join(h.Libraries.Dir(image.Filename), filepath.Dir(image.Filename), isoBase)
 
 		kernelPath := filepath.Join(bootDir, "vmlinuz")
 		initrdPath := filepath.Join(bootDir, "initrd")
@@ -746,6 +1121,67 @@ func (h *Handler) detectAndSetDistro(image *models.Image) {
 	}
 }
 
+// inspectISO records what the ISO says about itself without extracting
+// it: volume label, architecture and release, plus the distro and a
+// description when nothing else has set them.
+func (h *Handler) inspectISO(image *models.Image) {
+	if image == nil || !image.HasISOFile() {
+		return
+	}
+	path, err := h.Libraries.Join(image.Filename)
+	if err != nil {
+		return
+	}
+	info, err := extractor.Inspect(path)
+	if os.IsNotExist(err) {
+		return // on a remote library
+	}
+	if err != nil {
+		log.Printf("Admin: Could not inspect %s: %v", image.Filename, err)
+		return
+	}
+	image.VolumeLabel = info.VolumeLabel
+	image.Arch = info.Arch
+	image.ReleaseInfo = info.Release
+	if image.Distro == "" {
+		image.Distro = info.Distro
+	}
+	if image.Description == "" {
+		image.Description = info.Release
+	}
+	log.Printf("Admin: Inspected %s: label %q, distro %q, arch %q, release %q", image.Filename, info.VolumeLabel, info.Distro, info.Arch, info.Release)
+}
+
+// applyRelease stores the release fields parsed from the filename and, if
+// the image still has the filename as its name, gives it a readable one.
+func (h *Handler) applyRelease(image *models.Image) {
+	if image == nil || !image.HasISOFile() {
+		return
+	}
+	rel := models.ParseRelease(image.Filename)
+	image.ReleaseVersion = rel.Version
+	image.Variant = rel.Variant
+	if image.Arch == "" {
+		image.Arch = rel.Arch
+	}
+	base := filepath.Base(image.Filename)
+	if rel.Version == "" || image.Name != strings.TrimSuffix(base, filepath.Ext(base)) {
+		return
+	}
+	distroName := ""
+	if image.Distro != "" {
+		// Use the profile's spelling when the filename names that distro
+		// ("linuxmint" is "Linux Mint"), not a derivative of it.
+		if p, err := h.storage.GetDistroProfile(image.Distro); err == nil {
+			squashed := strings.ToLower(strings.ReplaceAll(p.DisplayName, " ", ""))
+			if rel.Distro == p.ProfileID || rel.Distro == squashed {
+				distroName = p.DisplayName
+			}
+		}
+	}
+	image.Name = rel.DisplayName(distroName)
+}
+
 func (h *Handler) ListImages(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
@@ -760,10 +1196,17 @@ func (h *Handler) ListImages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	catalog, _ := h.isoCatalog()
 	for _, img := range images {
 		if img.SMBInstallEnabled && img.SMBPatchFingerprint != "" {
 			img.SMBNeedsRepatch = h.computeSMBPatchFingerprint(img) != img.SMBPatchFingerprint
 		}
+		if catalog != nil {
+			if rel := catalog.NewerRelease(img); rel != nil {
+				img.NewerRelease = rel.Label
+			}
+		}
+		h.fillISCSITarget(img)
 	}
 
 	log.Printf("ListImages returning %d images", len(images))
@@ -794,10 +1237,17 @@ func (h *Handler) GetImage(w http.ResponseWriter, r *http.Request) {
 	if image.SMBInstallEnabled && image.SMBPatchFingerprint != "" {
 		image.SMBNeedsRepatch = h.computeSMBPatchFingerprint(image) != image.SMBPatchFingerprint
 	}
+	h.fillISCSITarget(image)
 
 	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: image})
 }
 
+func (h *Handler) fillISCSITarget(img *models.Image) {
+	if h.ISCSITarget != nil && img.ISCSIEnabled && !img.IsVirtual() {
+		img.ISCSITarget = h.ISCSITarget(img.Filename)
+	}
+}
+
 func (h *Handler) UpdateImage(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
 		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
@@ -816,11 +1266,28 @@ func (h *Handler) UpdateImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	image, err := h.storage.GetImage(filename)
+	version, versioned := requestVersion(r, updates)
+	image, code, err := h.updateImage(r.Context(), filename, updates, version, versioned)
+	if errors.Is(err, storage.ErrConflict) {
+		current, _ := h.storage.GetImage(filename)
+		h.sendJSON(w, code, Response{Success: false, Error: "Image was changed by someone else; reload and try again", Data: current})
+		return
+	}
 	if err != nil {
-		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
+		h.sendJSON(w, code, Response{Success: false, Error: err.Error()})
 		return
 	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Image updated", Data: image})
+}
+
+// updateImage applies updates to the image as updateClient does for
+// clients.
+func (h *Handler) updateImage(ctx context.Context, filename string, updates map[string]interface{}, version int, versioned bool) (*models.Image, int, error) {
+	image, err := h.storage.GetImage(filename)
+	if err != nil {
+		return nil, http.StatusNotFound, errors.New("Image not found")
+	}
+	before := *image
 
 	if name, ok := updates["name"].(string); ok && name != "" {
 		image.Name = name
@@ -858,16 +1325,110 @@ func (h *Handler) UpdateImage(w http.ResponseWriter, r *http.Request) {
 		image.AutoInstallFile = aiFile
 		image.AutoInstallEnabled = aiFile != "" || image.AutoInstallScript != ""
 	}
+	if rescue, ok := updates["rescue_enabled"].(bool); ok {
+		image.RescueEnabled = rescue
+	}
+	if rescueParams, ok := updates["rescue_params"].(string); ok {
+		image.RescueParams = rescueParams
+	}
+	if pin, ok := updates["pin_protected"].(bool); ok {
+		image.PINProtected = pin
+	}
+	if agent, ok := updates["first_boot_agent"].(bool); ok {
+		image.FirstBootAgent = agent
+	}
+	if published, ok := updates["iscsi_enabled"].(bool); ok {
+		image.ISCSIEnabled = published
+	}
+	for field, dst := range map[string]**time.Time{"visible_from": &image.VisibleFrom, "visible_until": &image.VisibleUntil} {
+		v, ok := updates[field]
+		if !ok {
+			continue
+		}
+		t, err := optionalTime(field, v)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		*dst = t
+	}
+	if image.VisibleFrom != nil && image.VisibleUntil != nil && !image.VisibleUntil.After(*image.VisibleFrom) {
+		return nil, http.StatusBadRequest, errors.New("visible_until must be after visible_from")
+	}
+	if windows, ok := updates["visible_windows"].(string); ok {
+		if _, err := models.ParseVisibleWindows(windows); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("visible_windows: %w", err)
+		}
+		image.VisibleWindows = strings.TrimSpace(windows)
+	}
+	if image.IsVirtual() {
+		if kernelURL, ok := updates["kernel_url"].(string); ok {
+			if err := checkRemoteURL("kernel_url", kernelURL, true); err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			image.KernelURL = kernelURL
+		}
+		if initrdURL, ok := updates["initrd_url"].(string); ok {
+			if err := checkRemoteURL("initrd_url", initrdURL, false); err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			image.InitrdURL = initrdURL
+		}
+		if cache, ok := updates["cache_remote"].(bool); ok {
+			image.CacheRemote = cache
+		}
+	}
+	if image.IsExternalISO() {
+		if isoURL, ok := updates["iso_url"].(string); ok && isoURL != image.ISOURL {
+			if err := checkRemoteURL("iso_url", isoURL, true); err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			image.ISOURL = isoURL
+			checkExternalISO(ctx, image)
+		}
+		if cache, ok := updates["cache_remote"].(bool); ok {
+			image.CacheRemote = cache
+		}
+		image.ISCSIEnabled = false
+	}
 
-	if err := h.storage.UpdateImage(filename, image); err != nil {
-		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
-		return
+	err = h.storage.Transaction(func(tx storage.Storage) error {
+		var err error
+		if versioned {
+			err = tx.UpdateImageVersion(filename, image, version)
+		} else {
+			err = tx.UpdateImage(filename, image)
+		}
+		if err != nil {
+			return err
+		}
+		return saveRevision(tx, revisionImage, filename, revisionUpdate, before)
+	})
+	if errors.Is(err, storage.ErrConflict) {
+		return nil, http.StatusConflict, err
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
 	}
 
 	log.Printf("Image updated: %s (enabled=%v, public=%v)", filename, image.Enabled, image.Public)
-	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Image updated", Data: image})
+	return image, http.StatusOK, nil
 }
 
+// optionalTime reads an RFC 3339 timestamp; null or "" clears it.
+func optionalTime(field string, v interface{}) (*time.Time, error) {
+	if v == nil || v == "" {
+		return nil, nil
+	}
+	str, _ := v.(string)
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", field)
+	}
+	return &t, nil
+}
+
+var errHasVariants = errors.New("image has variants")
+
 func (h *Handler) DeleteImage(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
@@ -882,16 +1443,84 @@ func (h *Handler) DeleteImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if deleteFile {
-		filePath := filepath.Join(h.isoDir, filename)
-		if err := os.Remove(filePath); err != nil {
-			log.Printf("Failed to delete file %s: %v", filePath, err)
-		} else {
-			log.Printf("Deleted ISO file: %s", filename)
+	image, variants, code, err := h.deleteImage(filename, deleteFile)
+	if errors.Is(err, errHasVariants) {
+		h.sendJSON(w, code, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Image has %d variant(s) sharing its ISO; delete them first", len(variants)),
+			Data:    variants,
+		})
+		return
+	}
+	if err != nil {
+		h.sendJSON(w, code, Response{Success: false, Error: err.Error()})
+		return
+	}
+	if image.CloneOf != "" {
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Image variant deleted"})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Image deleted"})
+}
+
+// deleteImage removes the image, and with deleteFile its ISO and extracted
+// files. An image with variants is refused with errHasVariants and the
+// variants' filenames.
+func (h *Handler) deleteImage(filename string, deleteFile bool) (*models.Image, []string, int, error) {
+	h.libraryMu.Lock()
+	defer h.libraryMu.Unlock()
+
+	image, err := h.storage.GetImage(filename)
+	if err != nil {
+		return nil, nil, http.StatusNotFound, errors.New("Image not found")
+	}
+
+	// The variant check, the delete and its revision happen together, so a
+	// variant added meanwhile can't be left pointing at a deleted source.
+	var variants []string
+	err = h.storage.Transaction(func(tx storage.Storage) error {
+		if image.CloneOf == "" {
+			vs, err := tx.ListImageVariants(filename)
+			if err != nil {
+				return err
+			}
+			for _, v := range vs {
+				variants = append(variants, v.Filename)
+			}
+			if len(variants) > 0 {
+				return errHasVariants
+			}
+		}
+		if err := tx.DeleteImage(filename); err != nil {
+			return err
+		}
+		return saveRevision(tx, revisionImage, filename, revisionDelete, image)
+	})
+	if errors.Is(err, errHasVariants) {
+		return nil, variants, http.StatusConflict, err
+	}
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+	if image.CloneOf != "" {
+		// A variant owns nothing on disk; only its menu entry goes.
+		log.Printf("Admin: Image variant deleted - %s (source %s)", filename, image.CloneOf)
+		return image, nil, http.StatusOK, nil
+	}
+
+	if deleteFile && !image.IsVirtual() {
+		root := h.Libraries.Dir(filename)
+		if image.HasISOFile() {
+			filePath := filepath.Join(root, filename)
+			if err := os.Remove(filePath); err != nil {
+				log.Printf("Failed to delete file %s: %v", filePath, err)
+			} else {
+				log.Printf("Deleted ISO file: %s", filename)
+			}
 		}
 
 		isoBase := strings.TrimSuffix(filename, filepath.Ext(filename))
-		extractedDir := filepath.Join(h.isoDir, isoBase)
+		extractedDir := filepath.Join(root, isoBase)
 		if _, err := os.Stat(extractedDir); err == nil {
 			if err := os.RemoveAll(extractedDir); err != nil {
 				log.Printf("Failed to delete extracted directory %s: %v", extractedDir, err)
@@ -899,11 +1528,12 @@ func (h *Handler) DeleteImage(w http.ResponseWriter, r *http.Request) {
 				log.Printf("Cleaned up extracted kernel directory: %s", extractedDir)
 			}
 		}
+		if h.Ranges != nil {
+			h.Ranges.Forget(filename)
+		}
 	}
-
-	if err := h.storage.DeleteImage(filename); err != nil {
-		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
-		return
+	if image.IsVirtual() || image.IsExternalISO() {
+		h.removeRemoteCache(filename)
 	}
 
 	if h.smbManager != nil {
@@ -918,7 +1548,7 @@ func (h *Handler) DeleteImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("Admin: Image deleted - %s", filename)
-	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Image deleted"})
+	return image, nil, http.StatusOK, nil
 }
 
 func (h *Handler) UploadImage(w http.ResponseWriter, r *http.Request) {
@@ -972,7 +1602,7 @@ func (h *Handler) UploadImage(w http.ResponseWriter, r *http.Request) {
 			}
 
 			filePath = filepath.Join(h.isoDir, filename)
-			if _, err := os.Stat(filePath); err == nil {
+			if h.Libraries.Exists(filename) {
 				part.Close()
 				log.Printf("Upload rejected: file already exists on filesystem: %s", filename)
 				h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "An image with this filename already exists"})
@@ -988,7 +1618,8 @@ func (h *Handler) UploadImage(w http.ResponseWriter, r *http.Request) {
 			}
 
 			log.Printf("Starting ISO upload: %s", filename)
-			size, err = io.Copy(dst, &progressReader{r: part, name: filename})
+			downloadMgr.Add(transferUpload, "", filename, uploadSize(r))
+			size, err = ctxio.Copy(r.Context(), dst, &progressReader{r: part, name: filename})
 			closeErr := dst.Close()
 			part.Close()
 			if err == nil {
@@ -996,11 +1627,13 @@ func (h *Handler) UploadImage(w http.ResponseWriter, r *http.Request) {
 			}
 			if err != nil {
 				os.Remove(filePath)
+				downloadMgr.Error(transferUpload, filename, err.Error())
 				log.Printf("Failed to save file %s: %v", filename, err)
 				h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: "Failed to save file"})
 				return
 			}
 			fileSaved = true
+			downloadMgr.Complete(transferUpload, filename)
 			log.Printf("Upload complete: %s (%d MB)", filename, size/(1024*1024))
 
 		case "public":
@@ -1034,7 +1667,10 @@ func (h *Handler) UploadImage(w http.ResponseWriter, r *http.Request) {
 			existingImage.Description = description
 		}
 
+		forgetChecksum(existingImage)
 		h.detectAndSetDistro(existingImage)
+		h.inspectISO(existingImage)
+		h.applyRelease(existingImage)
 
 		if err := h.storage.UpdateImage(filename, existingImage); err != nil {
 			cleanup()
@@ -1043,6 +1679,7 @@ func (h *Handler) UploadImage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		h.checksumInBackground(filename)
 		log.Printf("Admin: Image re-uploaded and database updated - %s (%d MB)", existingImage.Filename, existingImage.Size/1024/1024)
 		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Image re-uploaded successfully", Data: existingImage})
 		return
@@ -1061,6 +1698,8 @@ func (h *Handler) UploadImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.detectAndSetDistro(&image)
+	h.inspectISO(&image)
+	h.applyRelease(&image)
 
 	if err := h.storage.CreateImage(&image); err != nil {
 		cleanup()
@@ -1069,10 +1708,21 @@ func (h *Handler) UploadImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.checksumInBackground(filename)
 	log.Printf("Admin: Image uploaded successfully - %s (%d MB)", image.Filename, image.Size/1024/1024)
 	h.sendJSON(w, http.StatusCreated, Response{Success: true, Message: "Image uploaded", Data: image})
 }
 
+// uploadSize is the expected size of the uploaded file. Browsers know it
+// up front and send X-Upload-Size; Content-Length also counts the multipart
+// framing, so it is only a close upper bound.
+func uploadSize(r *http.Request) int64 {
+	if n, err := strconv.ParseInt(r.Header.Get("X-Upload-Size"), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return r.ContentLength
+}
+
 type progressReader struct {
 	r       io.Reader
 	name    string
@@ -1084,6 +1734,7 @@ func (p *progressReader) Read(b []byte) (int, error) {
 	n, err := p.r.Read(b)
 	if n > 0 {
 		p.read += int64(n)
+		downloadMgr.Update(transferUpload, p.name, p.read)
 		if p.read-p.lastLog >= 100*1024*1024 {
 			log.Printf("Upload progress: %s - %d MB read", p.name, p.read/(1024*1024))
 			p.lastLog = p.read
@@ -1121,7 +1772,15 @@ func (h *Handler) AssignImages(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("Images assigned to client: %s -> %v", req.MACAddress, req.ImageFilenames)
-	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Images assigned to client"})
+	msg := "Images assigned to client"
+	if client, err := h.storage.GetClient(req.MACAddress); err == nil {
+		h.checkSecureBoot(client)
+		if len(client.SecureBootWarnings) > 0 {
+			log.Printf("Secure Boot: %s: %s", req.MACAddress, strings.Join(client.SecureBootWarnings, "; "))
+			msg += ". Secure Boot is on for this client: " + strings.Join(client.SecureBootWarnings, "; ")
+		}
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: msg})
 }
 
 func (h *Handler) ExtractImage(w http.ResponseWriter, r *http.Request) {
@@ -1141,16 +1800,61 @@ func (h *Handler) ExtractImage(w http.ResponseWriter, r *http.Request) {
 		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
 		return
 	}
+	job, status, err := h.startExtraction(image)
+	if err != nil {
+		resp := Response{Success: false, Error: err.Error()}
+		if job != nil {
+			resp.Data = map[string]interface{}{"job_id": job.ID}
+		}
+		h.sendJSON(w, status, resp)
+		return
+	}
 
-	log.Printf("Admin: Starting kernel/initrd extraction - %s (re-extract: %v)", filename, image.Extracted)
+	// Extracting a whole ISO can take minutes, longer than clients and
+	// proxies wait for a response, so progress is followed through the job.
+	w.Header().Set("X-Job-ID", strconv.FormatUint(job.ID, 10))
+	h.sendJSON(w, http.StatusAccepted, Response{
+		Success: true,
+		Message: fmt.Sprintf("Extracting %s", filename),
+		Data:    map[string]interface{}{"job_id": job.ID},
+	})
+}
 
-	ext, err := extractor.New(h.isoDir)
+// startExtraction starts extracting image as a job. When it can't, it
+// returns the HTTP status to answer with, and the running job if the image
+// is already being extracted.
+func (h *Handler) startExtraction(image *models.Image) (*jobs.Job, int, error) {
+	if image.CloneOf != "" {
+		return nil, http.StatusConflict, fmt.Errorf("%s is a variant of %s; extract the source image instead", image.Filename, image.CloneOf)
+	}
+	if !image.HasISOFile() {
+		return nil, http.StatusBadRequest, errors.New("Virtual images and OCI bundles have no ISO to extract")
+	}
+	if h.Libraries.Fetch(image.Filename) {
+		return nil, http.StatusConflict, errors.New("The ISO is being cached from its remote library; extract again once it is done")
+	}
+	job, err := h.jobs.Go(h.background(), "extract", image.Filename, nil)
+	if errors.Is(err, jobs.ErrRunning) {
+		return job, http.StatusConflict, errors.New("This image is already being extracted")
+	}
 	if err != nil {
-		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: fmt.Sprintf("Failed to create extractor: %v", err)})
-		return
+		return nil, http.StatusInternalServerError, err
+	}
+	return job, http.StatusAccepted, nil
+}
+
+// extractImage pulls the kernel and initrd out of the image's ISO, switches
+// it to kernel boot and saves it, reporting progress as ExtractProgress
+// reads it.
+func (h *Handler) extractImage(ctx context.Context, image *models.Image, job *jobs.Job) error {
+	filename := image.Filename
+	root := h.Libraries.Dir(filename)
+	ext, err := extractor.New(root)
+	if err != nil {
+		return fmt.Errorf("Failed to create extractor: %v", err)
 	}
 
-	isoPath := filepath.Join(h.isoDir, filename)
+	isoPath := filepath.Join(root, filename)
 
 	reporter := extractor.NewProgressReporter()
 	reporter.SetStage("Scanning ISO...")
@@ -1159,7 +1863,8 @@ func (h *Handler) ExtractImage(w http.ResponseWriter, r *http.Request) {
 	}
 	ext.SetProgress(reporter)
 
-	state := &extractionState{reporter: reporter, status: "running"}
+	trackExtraction(job, reporter)
+	state := &extractionState{reporter: reporter, status: "running", jobID: job.ID}
 	h.extractionMu.Lock()
 	h.extractionStates[filename] = state
 	h.extractionMu.Unlock()
@@ -1173,8 +1878,20 @@ func (h *Handler) ExtractImage(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	reporter.SetStage("Extracting boot files...")
-	bootFiles, err := ext.Extract(isoPath)
+	h.saveExtractionStep(filename, models.ExtractStepFiles, nil)
+	bootFiles, err := ext.Extract(ctx, isoPath)
 	if err != nil {
+		// Cut short by shutdown, the extraction resumes at the next start;
+		// any other failure is recorded on the image instead.
+		if h.background().Err() == nil {
+			h.storage.DeleteExtractionState(filename)
+		}
+		if ctx.Err() != nil && h.background().Err() == nil {
+			h.extractionMu.Lock()
+			state.status = "cancelled"
+			h.extractionMu.Unlock()
+			return ctx.Err()
+		}
 		h.extractionMu.Lock()
 		state.status = "error"
 		state.errMsg = err.Error()
@@ -1182,18 +1899,40 @@ func (h *Handler) ExtractImage(w http.ResponseWriter, r *http.Request) {
 
 		image.ExtractionError = err.Error()
 		h.storage.UpdateImage(filename, image)
-
-		h.sendJSON(w, http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to extract boot files: %v", err),
-		})
-		return
+		return fmt.Errorf("Failed to extract boot files: %v", err)
+	}
+	job.Logf("Found %s boot files: kernel %s, initrd %s", bootFiles.Distro, bootFiles.Kernel, bootFiles.Initrd)
+	if bootFiles.SquashfsPath != "" {
+		job.Logf("Found squashfs %s", bootFiles.SquashfsPath)
+	}
+	if arches := bootFiles.KernelArches(); arches != nil {
+		job.Logf("Found kernels for %s", strings.Join(arches, ", "))
+	}
+	if n, size := ext.Reused(); n > 0 {
+		job.Logf("Reused %d unchanged file(s), %d MB, from the previous version of %s", n, size>>20, extractor.FamilyKey(filename))
 	}
 	reporter.SetStage("Saving metadata...")
+	if err := h.finishExtraction(ext, image, bootFiles, job); err != nil {
+		return err
+	}
+
+	reporter.SetStage("Complete")
+	h.extractionMu.Lock()
+	state.status = "done"
+	h.extractionMu.Unlock()
+	return nil
+}
 
+// finishExtraction takes an extraction whose files are out through its
+// remaining steps: metadata.txt, then the image row. Each step is recorded
+// before it runs, so a restart in between picks up where this left off.
+func (h *Handler) finishExtraction(ext *extractor.Extractor, image *models.Image, bootFiles *extractor.BootFiles, job *jobs.Job) error {
+	filename := image.Filename
+	h.saveExtractionStep(filename, models.ExtractStepMetadata, bootFiles)
 	if err := ext.SaveMetadata(filename, bootFiles); err != nil {
-		log.Printf("Failed to save extraction metadata: %v", err)
+		job.Logf("Warning: failed to save extraction metadata: %v", err)
 	}
+	h.saveExtractionStep(filename, models.ExtractStepImage, bootFiles)
 
 	sanbootCompatible, sanbootHint := checkSanbootCompatibility(bootFiles.Distro, image.Filename)
 
@@ -1203,7 +1942,9 @@ func (h *Handler) ExtractImage(w http.ResponseWriter, r *http.Request) {
 	image.BootMethod = "kernel"
 	image.KernelPath = bootFiles.Kernel
 	image.InitrdPath = bootFiles.Initrd
+	image.KernelArches = bootFiles.KernelArches()
 	image.SquashfsPath = bootFiles.SquashfsPath
+	h.dropLiteInitrd(image)
 
 	if h.profileManager != nil && bootFiles.Distro != "" {
 		hasSquashfs := bootFiles.SquashfsPath != ""
@@ -1222,36 +1963,33 @@ func (h *Handler) ExtractImage(w http.ResponseWriter, r *http.Request) {
 	image.SanbootHint = sanbootHint
 	image.NetbootRequired = bootFiles.NetbootRequired
 	image.NetbootURL = bootFiles.NetbootURL
+	if src, ok := netboot.Lookup(bootFiles.Distro, filename); ok && image.NetbootRequired {
+		image.NetbootURL = src.URL
+	}
 	image.NetbootAvailable = false
+	image.NetbootChecksum = ""
+	image.NetbootFetchedAt = nil
 	image.InstallWimPath = bootFiles.InstallWim
 
 	if bootFiles.Distro == "windows" {
+		job.Logf("Patching boot.wim for SMB install")
 		image.SMBInstallEnabled = h.patchWindowsBootWim(filename)
 		if image.SMBInstallEnabled {
 			image.SMBPatchFingerprint = h.computeSMBPatchFingerprint(image)
+		} else {
+			job.Logf("Warning: boot.wim was not patched; SMB install is disabled for this image")
 		}
 	}
 
-	log.Printf("Setting boot_method to 'kernel' for image ID=%d, filename=%s", image.ID, image.Filename)
+	job.Logf("Boot method set to kernel, boot params: %s", image.BootParams)
 
 	if err := h.storage.UpdateImage(filename, image); err != nil {
-		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
-		return
+		return err
 	}
-
-	log.Printf("Admin: Image extraction completed - %s (distro: %s, kernel: %s, initrd: %s)",
-		filename, bootFiles.Distro, bootFiles.Kernel, bootFiles.Initrd)
-
-	reporter.SetStage("Complete")
-	h.extractionMu.Lock()
-	state.status = "done"
-	h.extractionMu.Unlock()
-
-	h.sendJSON(w, http.StatusOK, Response{
-		Success: true,
-		Message: fmt.Sprintf("Successfully extracted %s boot files", bootFiles.Distro),
-		Data:    image,
-	})
+	if err := h.storage.DeleteExtractionState(filename); err != nil {
+		log.Printf("Extraction: failed to clear the state of %s: %v", filename, err)
+	}
+	return nil
 }
 
 func (h *Handler) ExtractProgress(w http.ResponseWriter, r *http.Request) {
@@ -1272,10 +2010,14 @@ func (h *Handler) ExtractProgress(w http.ResponseWriter, r *http.Request) {
 
 	snap := state.reporter.Snapshot()
 	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]any{
-		"status":  state.status,
-		"stage":   snap.Stage,
-		"percent": snap.Percent,
-		"error":   state.errMsg,
+		"status":      state.status,
+		"stage":       snap.Stage,
+		"percent":     snap.Percent,
+		"files":       snap.Files,
+		"bytes":       snap.Bytes,
+		"total_bytes": snap.TotalBytes,
+		"error":       state.errMsg,
+		"job_id":      state.jobID,
 	}})
 }
 
@@ -1305,6 +2047,9 @@ func (h *Handler) PatchImageSMB(w http.ResponseWriter, r *http.Request) {
 		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
 		return
 	}
+	if h.refuseVariant(w, image, "patch") {
+		return
+	}
 	if image.Distro != "windows" {
 		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Not a Windows image"})
 		return
@@ -1348,6 +2093,9 @@ func (h *Handler) RedetectImage(w http.ResponseWriter, r *http.Request) {
 		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
 		return
 	}
+	if h.refuseVariant(w, image, "re-detect") {
+		return
+	}
 
 	if !image.Extracted {
 		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Image must be extracted first"})
@@ -1368,14 +2116,15 @@ func (h *Handler) RedetectImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	isoBase := strings.TrimSuffix(filename, filepath.Ext(filename))
-	extractedDir := filepath.Join(h.isoDir, isoBase, "iso")
+	root := h.Libraries.Dir(filename)
+	extractedDir := filepath.Join(root, isoBase, "iso")
 	var squashfsPath string
 	filepath.Walk(extractedDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil || info.IsDir() {
 			return nil
 		}
 		if strings.HasSuffix(strings.ToLower(info.Name()), ".squashfs") {
-			rel, _ := filepath.Rel(filepath.Join(h.isoDir, isoBase), path)
+			rel, _ := filepath.Rel(filepath.Join(root, isoBase), path)
 			squashfsPath = rel
 			return filepath.SkipAll
 		}
@@ -1485,12 +2234,19 @@ func (h *Handler) DeleteDistroProfile(w http.ResponseWriter, r *http.Request) {
 	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Profile deleted"})
 }
 
+func (h *Handler) isoCatalog() (*profiles.ISOCatalog, error) {
+	if h.Catalog != nil {
+		return h.Catalog.Get(), nil
+	}
+	return profiles.LoadISOCatalog()
+}
+
 func (h *Handler) GetISOCatalog(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
 		return
 	}
-	catalog, err := profiles.LoadISOCatalog()
+	catalog, err := h.isoCatalog()
 	if err != nil {
 		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
 		return
@@ -1498,6 +2254,25 @@ func (h *Handler) GetISOCatalog(w http.ResponseWriter, r *http.Request) {
 	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: catalog})
 }
 
+// RefreshISOCatalog fetches the latest built-in catalog, the custom
+// catalog sources and release checksums now.
+func (h *Handler) RefreshISOCatalog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	if h.Catalog == nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: "ISO catalog not available"})
+		return
+	}
+	catalog := h.Catalog.Refresh(r.Context())
+	msg := fmt.Sprintf("Catalog refreshed: %d distros", len(catalog.Distros))
+	if len(catalog.Errors) > 0 {
+		msg += fmt.Sprintf(", %d source(s) failed", len(catalog.Errors))
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: msg, Data: catalog})
+}
+
 func (h *Handler) UpdateDistroProfiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
@@ -1605,8 +2380,9 @@ func (h *Handler) SetBootMethod(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.BootMethod != "sanboot" && req.BootMethod != "kernel" && req.BootMethod != "nbd" && req.BootMethod != "nfs" {
-		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid boot method (must be 'sanboot', 'kernel', 'nbd', or 'nfs')"})
+	provider, custom := bootmethod.Get(req.BootMethod)
+	if !custom && req.BootMethod != "sanboot" && req.BootMethod != "kernel" && req.BootMethod != "nbd" && req.BootMethod != "nfs" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid boot method (must be 'sanboot', 'kernel', 'nbd', 'nfs' or a registered provider)"})
 		return
 	}
 
@@ -1615,6 +2391,28 @@ func (h *Handler) SetBootMethod(w http.ResponseWriter, r *http.Request) {
 		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
 		return
 	}
+	if h.refuseVariant(w, image, "change the boot method of") {
+		return
+	}
+	if image.IsVirtual() {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Virtual images always boot their remote kernel"})
+		return
+	}
+	if image.IsExternalISO() && req.BootMethod != "sanboot" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "External images can only sanboot"})
+		return
+	}
+	if image.IsOCIBundle() && req.BootMethod != "kernel" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "OCI bundles can only boot their kernel"})
+		return
+	}
+
+	if custom {
+		if err := provider.Validate(image); err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: fmt.Sprintf("Cannot use %s: %v", req.BootMethod, err)})
+			return
+		}
+	}
 
 	if (req.BootMethod == "kernel" || req.BootMethod == "nfs") && !image.Extracted {
 		h.sendJSON(w, http.StatusBadRequest, Response{
@@ -1638,43 +2436,125 @@ func (h *Handler) SetBootMethod(w http.ResponseWriter, r *http.Request) {
 		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
 		return
 	}
-
-	log.Printf("Boot method changed for %s: %s", req.Filename, req.BootMethod)
-	h.sendJSON(w, http.StatusOK, Response{
-		Success: true,
-		Message: fmt.Sprintf("Boot method set to %s", req.BootMethod),
-		Data:    image,
-	})
+
+	log.Printf("Boot method changed for %s: %s", req.Filename, req.BootMethod)
+	h.sendJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: fmt.Sprintf("Boot method set to %s", req.BootMethod),
+		Data:    image,
+	})
+}
+
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+
+	statsMap, err := h.storage.WithContext(r.Context()).GetStats()
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	stats := struct {
+		TotalClients  int64 `json:"total_clients"`
+		ActiveClients int64 `json:"active_clients"`
+		TotalImages   int64 `json:"total_images"`
+		EnabledImages int64 `json:"enabled_images"`
+		TotalBoots    int64 `json:"total_boots"`
+	}{
+		TotalClients:  statsMap["total_clients"],
+		ActiveClients: statsMap["active_clients"],
+		TotalImages:   statsMap["total_images"],
+		EnabledImages: statsMap["enabled_images"],
+		TotalBoots:    statsMap["total_boots"],
+	}
+
+	log.Printf("Stats retrieved: %d clients, %d images, %d boots", stats.TotalClients, stats.TotalImages, stats.TotalBoots)
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: stats})
+}
+
+// StatsTimeseries returns boots, failures, active clients and bytes served
+// per bucket over ?range= (default 30d): hourly up to a week, daily beyond.
+func (h *Handler) StatsTimeseries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	if h.Stats == nil {
+		h.sendJSON(w, http.StatusServiceUnavailable, Response{Success: false, Error: "Statistics are not available"})
+		return
+	}
+
+	rng := r.URL.Query().Get("range")
+	if rng == "" {
+		rng = "30d"
+	}
+	d, err := stats.ParseRange(rng)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
+	period := stats.PeriodFor(d)
+	if p := r.URL.Query().Get("period"); p == stats.PeriodHour || p == stats.PeriodDay {
+		period = p
+	}
+	if period == stats.PeriodHour && d > stats.HourlyRetention {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Hourly buckets are only kept for 14 days"})
+		return
+	}
+
+	now := time.Now()
+	points, err := h.Stats.Series(period, now.Add(-d), now)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"range":  rng,
+		"period": period,
+		"points": points,
+	}})
 }
 
-func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+// UsageReport returns the usage report of ?month=YYYY-MM, the current month
+// so far by default, as JSON or, with ?format=md, Markdown. It is generated
+// afresh rather than read from the written report.
+func (h *Handler) UsageReport(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
 		return
 	}
-
-	statsMap, err := h.storage.GetStats()
+	if h.Usage == nil {
+		h.sendJSON(w, http.StatusServiceUnavailable, Response{Success: false, Error: "Usage reports are not available"})
+		return
+	}
+	now := time.Now()
+	month := now
+	if m := r.URL.Query().Get("month"); m != "" {
+		t, err := time.Parse("2006-01", m)
+		if err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "month must be YYYY-MM"})
+			return
+		}
+		month = t
+	}
+	rep, err := h.Usage.Generate(month, now)
 	if err != nil {
 		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
 		return
 	}
-
-	stats := struct {
-		TotalClients  int64 `json:"total_clients"`
-		ActiveClients int64 `json:"active_clients"`
-		TotalImages   int64 `json:"total_images"`
-		EnabledImages int64 `json:"enabled_images"`
-		TotalBoots    int64 `json:"total_boots"`
-	}{
-		TotalClients:  statsMap["total_clients"],
-		ActiveClients: statsMap["active_clients"],
-		TotalImages:   statsMap["total_images"],
-		EnabledImages: statsMap["enabled_images"],
-		TotalBoots:    statsMap["total_boots"],
+	if r.URL.Query().Get("format") == "md" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(rep.Markdown()))
+		return
 	}
-
-	log.Printf("Stats retrieved: %d clients, %d images, %d boots", stats.TotalClients, stats.TotalImages, stats.TotalBoots)
-	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: stats})
+	months, _ := h.Usage.Reports()
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"report":  rep,
+		"written": months,
+	}})
 }
 
 func (h *Handler) GetBootLogs(w http.ResponseWriter, r *http.Request) {
@@ -1696,9 +2576,9 @@ func (h *Handler) GetBootLogs(w http.ResponseWriter, r *http.Request) {
 	)
 	if mac := r.URL.Query().Get("mac"); mac != "" {
 		mac = strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
-		logs, err = h.storage.GetBootLogsByMAC(mac, limit)
+		logs, err = h.storage.WithContext(r.Context()).GetBootLogsByMAC(mac, limit)
 	} else {
-		logs, err = h.storage.GetBootLogs(limit)
+		logs, err = h.storage.WithContext(r.Context()).GetBootLogs(limit)
 	}
 	if err != nil {
 		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
@@ -1714,45 +2594,29 @@ func (h *Handler) ScanImages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	existingFiles := make(map[string]bool)
-	var isoFiles []models.SyncFile
-
-	err := filepath.WalkDir(h.isoDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		if !strings.HasSuffix(strings.ToLower(d.Name()), ".iso") {
-			return nil
-		}
-
-		info, err := d.Info()
-		if err != nil {
-			return nil
-		}
-
-		relPath, _ := filepath.Rel(h.isoDir, path)
-		groupPath := filepath.Dir(relPath)
-		if groupPath == "." {
-			groupPath = ""
-		}
-
-		existingFiles[relPath] = true
-		isoFiles = append(isoFiles, models.SyncFile{
-			Name:      strings.TrimSuffix(d.Name(), filepath.Ext(d.Name())),
-			Filename:  relPath,
-			Size:      info.Size(),
-			GroupPath: groupPath,
-		})
+	h.libraryMu.Lock()
+	defer h.libraryMu.Unlock()
 
-		return nil
-	})
+	// ?library=<name> scans just that library. Images are only dropped when
+	// their ISO is in none of the libraries, so other libraries are still
+	// checked before deleting anything.
+	only := r.URL.Query().Get("library")
+	isos, unavailable, err := h.Libraries.Scan(only)
 	if err != nil {
-		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
 		return
 	}
+	isoFiles := syncFiles(isos)
+	if only != "" {
+		if isos, unavailable, err = h.Libraries.Scan(""); err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+	}
+	existingFiles := make(map[string]bool)
+	for _, iso := range isos {
+		existingFiles[iso.Path] = true
+	}
 
 	var newImages []string
 	allImagesBefore, _ := h.storage.ListImages()
@@ -1769,6 +2633,13 @@ func (h *Handler) ScanImages(w http.ResponseWriter, r *http.Request) {
 		if !existingFilenames[iso.Filename] {
 			newImages = append(newImages, iso.Filename)
 			log.Printf("Admin: Image scan found new ISO - %s", iso.Filename)
+			if img, err := h.storage.GetImage(iso.Filename); err == nil {
+				h.inspectISO(img)
+				h.applyRelease(img)
+				if err := h.storage.UpdateImage(iso.Filename, img); err != nil {
+					log.Printf("Failed to save image metadata for %s: %v", iso.Filename, err)
+				}
+			}
 		}
 	}
 
@@ -1777,14 +2648,21 @@ func (h *Handler) ScanImages(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		log.Printf("Checking %d database images against %d filesystem ISOs", len(allImages), len(existingFiles))
 		for _, image := range allImages {
-			if !existingFiles[image.Filename] {
+			if !existingFiles[image.DiskFilename()] && image.HasISOFile() {
+				if len(unavailable) > 0 {
+					// The ISO may be on a library that is not mounted.
+					continue
+				}
 				log.Printf("Deleting missing image from database: %s (ID: %d)", image.Filename, image.ID)
 				if err := h.storage.DeleteImage(image.Filename); err == nil {
 					deletedImages = append(deletedImages, image.Filename)
 					log.Printf("Successfully removed missing image from database: %s", image.Filename)
+					if image.CloneOf != "" {
+						continue
+					}
 
 					isoBase := strings.TrimSuffix(image.Filename, filepath.Ext(image.Filename))
-					bootFilesDir := filepath.Join(h.isoDir, isoBase)
+					bootFilesDir := filepath.Join(h.Libraries.Dir(isoBase), isoBase)
 					if _, err := os.Stat(bootFilesDir); err == nil {
 						if err := os.RemoveAll(bootFilesDir); err == nil {
 							log.Printf("Cleaned up boot files directory: %s", bootFilesDir)
@@ -1797,14 +2675,27 @@ func (h *Handler) ScanImages(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var unreachable []string
+	if only == "" {
+		unreachable = h.checkExternalImages(r.Context(), allImages)
+	}
+
 	msg := fmt.Sprintf("Scan complete. Found %d new images, removed %d missing images.", len(newImages), len(deletedImages))
+	if len(unavailable) > 0 {
+		msg += fmt.Sprintf(" Missing images were kept because these libraries are unavailable: %s.", strings.Join(unavailable, ", "))
+	}
+	if len(unreachable) > 0 {
+		msg += fmt.Sprintf(" These external images could not be reached: %s.", strings.Join(unreachable, ", "))
+	}
 	log.Printf("Admin: ISO scan completed - %d new, %d removed", len(newImages), len(deletedImages))
 	h.sendJSON(w, http.StatusOK, Response{
 		Success: true,
 		Message: msg,
 		Data: map[string]interface{}{
-			"new":     newImages,
-			"deleted": deletedImages,
+			"new":         newImages,
+			"deleted":     deletedImages,
+			"unavailable": unavailable,
+			"unreachable": unreachable,
 		},
 	})
 }
@@ -1962,7 +2853,11 @@ func (h *Handler) UploadBootloader(w http.ResponseWriter, r *http.Request) {
 	}
 	setName = filepath.Base(setName)
 
-	setDir := filepath.Join(h.bootDir, setName)
+	setDir, err := h.bootloaderSetDir(setName)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid set name"})
+		return
+	}
 	if _, err := os.Stat(setDir); os.IsNotExist(err) {
 		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Set does not exist. Create it first."})
 		return
@@ -1990,7 +2885,12 @@ func (h *Handler) UploadBootloader(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		destPath := filepath.Join(setDir, filename)
+		destPath, err := securepath.Join(setDir, filename)
+		if err != nil {
+			file.Close()
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: fmt.Sprintf("Invalid file name %s: %v", filename, err)})
+			return
+		}
 		dest, err := os.Create(destPath)
 		if err != nil {
 			file.Close()
@@ -2019,6 +2919,15 @@ func (h *Handler) UploadBootloader(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// bootloaderSetDir confines a custom bootloader set to the boot directory,
+// refusing names such as "." that would resolve to the directory itself.
+func (h *Handler) bootloaderSetDir(setName string) (string, error) {
+	if setName == "." || setName == string(filepath.Separator) {
+		return "", securepath.ErrInvalid
+	}
+	return securepath.Join(h.bootDir, setName)
+}
+
 func (h *Handler) DeleteBootloader(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
@@ -2036,11 +2945,15 @@ func (h *Handler) DeleteBootloader(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	setName = filepath.Base(setName)
+	setPath, err := h.bootloaderSetDir(setName)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid set name"})
+		return
+	}
 
 	filename := r.URL.Query().Get("name")
 
 	if filename == "" {
-		setPath := filepath.Join(h.bootDir, setName)
 		if err := os.RemoveAll(setPath); err != nil {
 			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: fmt.Sprintf("Failed to delete set: %v", err)})
 			return
@@ -2057,7 +2970,11 @@ func (h *Handler) DeleteBootloader(w http.ResponseWriter, r *http.Request) {
 	}
 
 	filename = filepath.Base(filename)
-	filePath := filepath.Join(h.bootDir, setName, filename)
+	filePath, err := securepath.Join(setPath, filename)
+	if err != nil || filePath == setPath {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid file name"})
+		return
+	}
 	if err := os.Remove(filePath); err != nil {
 		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: fmt.Sprintf("Failed to delete file: %v", err)})
 		return
@@ -2161,6 +3078,10 @@ func (h *Handler) ToggleTool(w http.ResponseWriter, r *http.Request) {
 		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Tool must be downloaded before enabling"})
 		return
 	}
+	if req.Enabled && h.toolsManager.BootMethod(req.Name) == "memdisk" && !h.toolsManager.HasMemdisk() {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Install the memdisk loader before enabling memdisk tools"})
+		return
+	}
 
 	tool.Enabled = req.Enabled
 	if err := h.storage.SaveBootTool(tool); err != nil {
@@ -2283,6 +3204,10 @@ func (h *Handler) CreateCustomTool(w http.ResponseWriter, r *http.Request) {
 	if req.BootMethod == "" {
 		req.BootMethod = "kernel"
 	}
+	if req.BootMethod == "memdisk" && !h.toolsManager.HasMemdisk() {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Install the memdisk loader before adding memdisk tools"})
+		return
+	}
 	if req.ArchiveType == "" {
 		req.ArchiveType = "bin"
 	}
@@ -2380,14 +3305,14 @@ func (h *Handler) GetServerInfo(w http.ResponseWriter, r *http.Request) {
 				return "Disabled"
 			}(),
 			"runtime_mode": func() string {
-				if isRunningInDocker() {
+				if hostip.InContainer() {
 					return "Docker"
 				}
 				return "Native"
 			}(),
 			"ldap_enabled": func() string {
 				if os.Getenv("BOOTIMUS_LDAP_HOST") != "" {
-					return os.Getenv("BOOTIMUS_LDAP_HOST")
+					return redact.String(os.Getenv("BOOTIMUS_LDAP_HOST"))
 				}
 				return "Disabled"
 			}(),
@@ -2414,28 +3339,49 @@ func (h *Handler) GetServerInfo(w http.ResponseWriter, r *http.Request) {
 			}(),
 			"http_port": fmt.Sprintf("%d", h.httpPort),
 		},
-		"environment": map[string]string{
-			"BOOTIMUS_TFTP_PORT":        os.Getenv("BOOTIMUS_TFTP_PORT"),
-			"BOOTIMUS_TFTP_SINGLE_PORT": os.Getenv("BOOTIMUS_TFTP_SINGLE_PORT"),
-			"BOOTIMUS_HTTP_PORT":        os.Getenv("BOOTIMUS_HTTP_PORT"),
-			"BOOTIMUS_ADMIN_PORT":       os.Getenv("BOOTIMUS_ADMIN_PORT"),
-			"BOOTIMUS_DATA_DIR":         os.Getenv("BOOTIMUS_DATA_DIR"),
-			"BOOTIMUS_DB_HOST":          os.Getenv("BOOTIMUS_DB_HOST"),
-			"BOOTIMUS_DB_PORT":          os.Getenv("BOOTIMUS_DB_PORT"),
-			"BOOTIMUS_DB_USER":          os.Getenv("BOOTIMUS_DB_USER"),
-			"BOOTIMUS_DB_NAME":          os.Getenv("BOOTIMUS_DB_NAME"),
-			"BOOTIMUS_DB_SSLMODE":       os.Getenv("BOOTIMUS_DB_SSLMODE"),
-			"BOOTIMUS_DB_DISABLE":       os.Getenv("BOOTIMUS_DB_DISABLE"),
-			"BOOTIMUS_SERVER_ADDR":      os.Getenv("BOOTIMUS_SERVER_ADDR"),
-			"BOOTIMUS_LDAP_HOST":        os.Getenv("BOOTIMUS_LDAP_HOST"),
-			"BOOTIMUS_LDAP_BASE_DN":     os.Getenv("BOOTIMUS_LDAP_BASE_DN"),
-		},
+		"environment":  serverEnvironment(),
 		"system_stats": sysStats,
 	}
+	if h.NetworkWarning != "" {
+		info["warnings"] = []string{h.NetworkWarning}
+	}
 
 	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: info})
 }
 
+// serverInfoEnv are listed in the server info whether they are set or not;
+// other BOOTIMUS_ variables are listed when set.
+var serverInfoEnv = []string{
+	"BOOTIMUS_TFTP_PORT",
+	"BOOTIMUS_TFTP_SINGLE_PORT",
+	"BOOTIMUS_HTTP_PORT",
+	"BOOTIMUS_ADMIN_PORT",
+	"BOOTIMUS_DATA_DIR",
+	"BOOTIMUS_DB_HOST",
+	"BOOTIMUS_DB_PORT",
+	"BOOTIMUS_DB_USER",
+	"BOOTIMUS_DB_NAME",
+	"BOOTIMUS_DB_SSLMODE",
+	"BOOTIMUS_DB_DISABLE",
+	"BOOTIMUS_SERVER_ADDR",
+	"BOOTIMUS_LDAP_HOST",
+	"BOOTIMUS_LDAP_BASE_DN",
+}
+
+// serverEnvironment is the BOOTIMUS_ environment with secrets masked.
+func serverEnvironment() map[string]string {
+	env := make(map[string]string)
+	for _, k := range serverInfoEnv {
+		env[k] = redact.Value(k, os.Getenv(k))
+	}
+	for _, kv := range os.Environ() {
+		if k, v, _ := strings.Cut(kv, "="); strings.HasPrefix(k, "BOOTIMUS_") {
+			env[k] = redact.Value(k, v)
+		}
+	}
+	return env
+}
+
 func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	users, err := h.storage.ListUsers()
 	if err != nil {
@@ -2604,42 +3550,75 @@ func (h *Handler) ResetUserPassword(w http.ResponseWriter, r *http.Request) {
 }
 
 type DownloadProgress struct {
-	URL             string    `json:"url"`
+	URL             string    `json:"url,omitempty"`
 	Filename        string    `json:"filename"`
+	Direction       string    `json:"direction"`
 	TotalBytes      int64     `json:"total_bytes"`
 	DownloadedBytes int64     `json:"downloaded_bytes"`
 	Percentage      float64   `json:"percentage"`
 	Speed           string    `json:"speed"`
+	BytesPerSec     int64     `json:"bytes_per_sec"`
+	ETASeconds      int64     `json:"eta_seconds,omitempty"`
 	Status          string    `json:"status"`
 	Error           string    `json:"error,omitempty"`
 	StartTime       time.Time `json:"start_time"`
 }
 
+// Transfer directions tracked by the DownloadManager. Uploads report
+// DownloadedBytes as the bytes received so far.
+const (
+	transferDownload = "download"
+	transferUpload   = "upload"
+)
+
+type transferKey struct {
+	direction string
+	filename  string
+}
+
 type DownloadManager struct {
 	mu        sync.RWMutex
-	downloads map[string]*DownloadProgress
+	downloads map[transferKey]*DownloadProgress
 }
 
 var downloadMgr = &DownloadManager{
-	downloads: make(map[string]*DownloadProgress),
+	downloads: make(map[transferKey]*DownloadProgress),
 }
 
-func (dm *DownloadManager) Add(url, filename string, totalBytes int64) {
+func (dm *DownloadManager) Add(direction, url, filename string, totalBytes int64) {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
-	dm.downloads[filename] = &DownloadProgress{
+	status := "downloading"
+	if direction == transferUpload {
+		status = "uploading"
+	}
+	dm.downloads[transferKey{direction, filename}] = &DownloadProgress{
 		URL:        url,
 		Filename:   filename,
+		Direction:  direction,
 		TotalBytes: totalBytes,
-		Status:     "downloading",
+		Status:     status,
 		StartTime:  time.Now(),
 	}
 }
 
-func (dm *DownloadManager) Update(filename string, downloadedBytes int64) {
+// Queued records a download waiting for a worker.
+func (dm *DownloadManager) Queued(url, filename string) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.downloads[transferKey{transferDownload, filename}] = &DownloadProgress{
+		URL:       url,
+		Filename:  filename,
+		Direction: transferDownload,
+		Status:    "queued",
+		StartTime: time.Now(),
+	}
+}
+
+func (dm *DownloadManager) Update(direction, filename string, downloadedBytes int64) {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
-	if progress, ok := dm.downloads[filename]; ok {
+	if progress, ok := dm.downloads[transferKey{direction, filename}]; ok {
 		progress.DownloadedBytes = downloadedBytes
 		if progress.TotalBytes > 0 {
 			progress.Percentage = float64(downloadedBytes) / float64(progress.TotalBytes) * 100
@@ -2648,41 +3627,54 @@ func (dm *DownloadManager) Update(filename string, downloadedBytes int64) {
 		elapsed := time.Since(progress.StartTime).Seconds()
 		if elapsed > 0 {
 			bytesPerSec := float64(downloadedBytes) / elapsed
+			progress.BytesPerSec = int64(bytesPerSec)
 			progress.Speed = formatBytes(int64(bytesPerSec)) + "/s"
+			if progress.TotalBytes > downloadedBytes && bytesPerSec > 0 {
+				progress.ETASeconds = int64(float64(progress.TotalBytes-downloadedBytes) / bytesPerSec)
+			}
 		}
 	}
 }
 
-func (dm *DownloadManager) Complete(filename string) {
+func (dm *DownloadManager) Complete(direction, filename string) {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
-	if progress, ok := dm.downloads[filename]; ok {
+	if progress, ok := dm.downloads[transferKey{direction, filename}]; ok {
 		progress.Status = "completed"
 		progress.Percentage = 100
+		progress.ETASeconds = 0
 	}
 }
 
-func (dm *DownloadManager) Error(filename, errMsg string) {
+func (dm *DownloadManager) Error(direction, filename, errMsg string) {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
-	if progress, ok := dm.downloads[filename]; ok {
+	if progress, ok := dm.downloads[transferKey{direction, filename}]; ok {
 		progress.Status = "error"
 		progress.Error = errMsg
+		progress.ETASeconds = 0
 	}
 }
 
-func (dm *DownloadManager) Get(filename string) *DownloadProgress {
+func (dm *DownloadManager) Get(direction, filename string) *DownloadProgress {
 	dm.mu.RLock()
 	defer dm.mu.RUnlock()
-	return dm.downloads[filename]
+	if p, ok := dm.downloads[transferKey{direction, filename}]; ok {
+		copied := *p
+		return &copied
+	}
+	return nil
 }
 
-func (dm *DownloadManager) GetAll() []*DownloadProgress {
+func (dm *DownloadManager) GetAll(direction string) []*DownloadProgress {
 	dm.mu.RLock()
 	defer dm.mu.RUnlock()
 	result := make([]*DownloadProgress, 0, len(dm.downloads))
-	for _, p := range dm.downloads {
-		result = append(result, p)
+	for key, p := range dm.downloads {
+		if key.direction == direction {
+			copied := *p
+			result = append(result, &copied)
+		}
 	}
 	return result
 }
@@ -2705,12 +3697,32 @@ func (h *Handler) DownloadISO(w http.ResponseWriter, r *http.Request) {
 		URL         string `json:"url"`
 		Filename    string `json:"filename"`
 		Description string `json:"description"`
+		Connections int    `json:"connections"`
+		SHA256      string `json:"sha256"` // expected checksums, checked once the download finishes
+		MD5         string `json:"md5"`
+		RateLimit   string `json:"rate_limit"` // e.g. "5MB", per second
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request"})
 		return
 	}
+	req.SHA256 = strings.ToLower(strings.TrimSpace(req.SHA256))
+	req.MD5 = strings.ToLower(strings.TrimSpace(req.MD5))
+	if !validChecksum(req.SHA256, sha256.Size) || !validChecksum(req.MD5, md5.Size) {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "sha256 and md5 must be hex digests"})
+		return
+	}
+	rate, err := throttle.ParseRate(req.RateLimit)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
+	if req.Connections <= 0 {
+		req.Connections = defaultDownloadConnections
+	} else if req.Connections > maxDownloadConnections {
+		req.Connections = maxDownloadConnections
+	}
 
 	if req.URL == "" {
 		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "URL is required"})
@@ -2728,88 +3740,99 @@ func (h *Handler) DownloadISO(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	destPath := filepath.Join(h.isoDir, filename)
-	if _, err := os.Stat(destPath); err == nil {
+	if h.Libraries.Exists(filename) {
 		h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "File already exists"})
 		return
 	}
 
-	go h.downloadISO(req.URL, filename, destPath, req.Description)
+	if p := downloadMgr.Get(transferDownload, filename); p != nil && (p.Status == "queued" || p.Status == "downloading") {
+		h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "File is already being downloaded"})
+		return
+	}
+
+	q := h.Downloads.status()
+	ahead := max(0, q.Queued+q.Running+1-q.MaxConcurrent)
+	job, err := h.jobs.Go(h.background(), "download", filename, map[string]string{
+		"url":         req.URL,
+		"description": req.Description,
+		"connections": strconv.Itoa(req.Connections),
+		"sha256":      req.SHA256,
+		"md5":         req.MD5,
+		"rate_limit":  strconv.FormatInt(rate, 10),
+	})
+	if errors.Is(err, jobs.ErrRunning) {
+		h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "File is already being downloaded", Data: map[string]interface{}{"job_id": job.ID}})
+		return
+	}
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	message := "Download started"
+	if ahead > 0 {
+		message = fmt.Sprintf("Download queued behind %d other(s)", ahead)
+	}
 
+	w.Header().Set("X-Job-ID", strconv.FormatUint(job.ID, 10))
 	h.sendJSON(w, http.StatusAccepted, Response{
 		Success: true,
-		Message: "Download started",
-		Data: map[string]string{
+		Message: message,
+		Data: map[string]interface{}{
 			"filename": filename,
 			"url":      req.URL,
+			"job_id":   job.ID,
 		},
 	})
 }
 
-func (h *Handler) downloadISO(url, filename, destPath, description string) {
-	log.Printf("Starting ISO download: %s from %s", filename, url)
-
-	downloadMgr.Add(url, filename, 0)
-
-	client := &http.Client{
-		Timeout: 0,
+// runDownload queues the download DownloadISO recorded in job's params and
+// waits for a worker to finish it.
+func (h *Handler) runDownload(ctx context.Context, job *jobs.Job) error {
+	filename, url := job.Target, job.Params["url"]
+	if h.Libraries.Exists(filename) {
+		return fmt.Errorf("%s already exists", filename)
 	}
+	rate, _ := strconv.ParseInt(job.Params["rate_limit"], 10, 64)
+	limits := h.Downloads.limits(rate)
 
-	resp, err := client.Get(url)
-	if err != nil {
-		log.Printf("Failed to download ISO %s: %v", filename, err)
-		downloadMgr.Error(filename, err.Error())
-		return
-	}
-	defer resp.Body.Close()
+	downloadMgr.Queued(url, filename)
+	job.Track(func() jobs.Progress {
+		p := downloadMgr.Get(transferDownload, filename)
+		if p == nil {
+			return jobs.Progress{}
+		}
+		return jobs.Progress{Stage: p.Status, Percent: p.Percentage, Bytes: p.DownloadedBytes, TotalBytes: p.TotalBytes}
+	})
+	done := make(chan error, 1)
+	h.Downloads.submit(func() {
+		if err := ctx.Err(); err != nil {
+			// Cancelled while waiting for a worker.
+			downloadMgr.Error(transferDownload, filename, err.Error())
+			done <- err
+			return
+		}
+		done <- h.downloadISO(ctx, job, limits)
+	})
+	return <-done
+}
 
-	if resp.StatusCode != http.StatusOK {
-		errMsg := fmt.Sprintf("HTTP %d: %s", resp.StatusCode, resp.Status)
-		log.Printf("Failed to download ISO %s: %s", filename, errMsg)
-		downloadMgr.Error(filename, errMsg)
-		return
-	}
+func (h *Handler) downloadISO(ctx context.Context, job *jobs.Job, limits []*throttle.Limiter) error {
+	filename, url := job.Target, job.Params["url"]
+	destPath := filepath.Join(h.isoDir, filename)
+	connections, _ := strconv.Atoi(job.Params["connections"])
+	job.Logf("Starting ISO download: %s from %s", filename, url)
 
-	downloadMgr.Add(url, filename, resp.ContentLength)
+	downloadMgr.Add(transferDownload, url, filename, 0)
 
-	out, err := os.Create(destPath)
+	downloaded, err := fetchISO(ctx, url, filename, destPath, connections, limits...)
 	if err != nil {
-		log.Printf("Failed to create file %s: %v", destPath, err)
-		downloadMgr.Error(filename, err.Error())
-		return
-	}
-	defer out.Close()
-
-	buffer := make([]byte, 32*1024)
-	var downloaded int64
-
-	for {
-		n, err := resp.Body.Read(buffer)
-		if n > 0 {
-			_, writeErr := out.Write(buffer[:n])
-			if writeErr != nil {
-				log.Printf("Failed to write to file %s: %v", destPath, writeErr)
-				downloadMgr.Error(filename, writeErr.Error())
-				os.Remove(destPath)
-				return
-			}
-			downloaded += int64(n)
-			downloadMgr.Update(filename, downloaded)
-		}
-
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Printf("Failed to download ISO %s: %v", filename, err)
-			downloadMgr.Error(filename, err.Error())
-			os.Remove(destPath)
-			return
-		}
+		downloadMgr.Error(transferDownload, filename, err.Error())
+		os.Remove(destPath)
+		return fmt.Errorf("download %s: %w", filename, err)
 	}
 
-	downloadMgr.Complete(filename)
-	log.Printf("Completed ISO download: %s (%d bytes)", filename, downloaded)
+	downloadMgr.Complete(transferDownload, filename)
+	job.Logf("Completed ISO download: %s (%d bytes)", filename, downloaded)
 
 	if h.storage != nil {
 		isoFiles := []models.SyncFile{
@@ -2817,26 +3840,25 @@ func (h *Handler) downloadISO(url, filename, destPath, description string) {
 		}
 
 		if err := h.storage.SyncImages(isoFiles); err != nil {
-			log.Printf("Failed to sync downloaded ISO to database: %v", err)
+			job.Logf("Failed to sync downloaded ISO to database: %v", err)
 		}
 
 		if img, err := h.storage.GetImage(filename); err == nil {
-			changed := false
-			if description != "" {
+			if description := job.Params["description"]; description != "" {
 				img.Description = description
-				changed = true
 			}
+			forgetChecksum(img)
+			img.SHA256, img.MD5 = job.Params["sha256"], job.Params["md5"]
 			h.detectAndSetDistro(img)
-			if img.Distro != "" {
-				changed = true
-			}
-			if changed {
-				if err := h.storage.UpdateImage(filename, img); err != nil {
-					log.Printf("Failed to save image metadata for %s: %v", filename, err)
-				}
+			h.inspectISO(img)
+			h.applyRelease(img)
+			if err := h.storage.UpdateImage(filename, img); err != nil {
+				job.Logf("Failed to save image metadata for %s: %v", filename, err)
 			}
+			h.checksumInBackground(filename)
 		}
 	}
+	return nil
 }
 
 func (h *Handler) GetDownloadProgress(w http.ResponseWriter, r *http.Request) {
@@ -2846,7 +3868,7 @@ func (h *Handler) GetDownloadProgress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	progress := downloadMgr.Get(filename)
+	progress := downloadMgr.Get(transferDownload, filename)
 	if progress == nil {
 		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Download not found"})
 		return
@@ -2855,11 +3877,33 @@ func (h *Handler) GetDownloadProgress(w http.ResponseWriter, r *http.Request) {
 	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: progress})
 }
 
+// DownloadQueueStatus reports the download workers, the downloads waiting
+// for one, and the bandwidth caps.
+func (h *Handler) DownloadQueueStatus(w http.ResponseWriter, r *http.Request) {
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: h.Downloads.status()})
+}
+
 func (h *Handler) ListDownloads(w http.ResponseWriter, r *http.Request) {
-	downloads := downloadMgr.GetAll()
+	downloads := downloadMgr.GetAll(transferDownload)
 	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: downloads})
 }
 
+// ListUploads reports browser uploads as the server sees them, with ?filename=
+// narrowing to one. Progress is measured against X-Upload-Size when the
+// client sends it, otherwise against the request's Content-Length.
+func (h *Handler) ListUploads(w http.ResponseWriter, r *http.Request) {
+	if filename := r.URL.Query().Get("filename"); filename != "" {
+		progress := downloadMgr.Get(transferUpload, filename)
+		if progress == nil {
+			h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Upload not found"})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: progress})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: downloadMgr.GetAll(transferUpload)})
+}
+
 func (h *Handler) GetAutoInstallScript(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
@@ -2887,6 +3931,9 @@ func (h *Handler) GetAutoInstallScript(w http.ResponseWriter, r *http.Request) {
 			"script":      image.AutoInstallScript,
 			"enabled":     image.AutoInstallEnabled,
 			"script_type": image.AutoInstallScriptType,
+			"generator":   image.AutoInstallGenerator,
+			// Params saved before admin_password became a secret still hold it.
+			"params": json.RawMessage(orEmptyJSON(string(withoutSecrets([]byte(image.AutoInstallParams))))),
 		},
 	})
 }
@@ -2929,12 +3976,27 @@ func (h *Handler) UpdateAutoInstallScript(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if r.URL.Query().Get("force") != "true" && req.Script != "" {
+		if errs := autoinstall.ValidateScript(req.ScriptType, req.Script); len(errs) > 0 {
+			h.sendJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   fmt.Sprintf("Script has %d problem(s); first: %s", len(errs), errs[0].Error()),
+				Data:    errs,
+			})
+			return
+		}
+	}
+
 	image, err := h.storage.GetImage(filename)
 	if err != nil {
 		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
 		return
 	}
 
+	if req.Script != image.AutoInstallScript {
+		image.AutoInstallGenerator = ""
+		image.AutoInstallParams = ""
+	}
 	image.AutoInstallScript = req.Script
 	image.AutoInstallEnabled = req.Enabled
 	image.AutoInstallScriptType = req.ScriptType
@@ -3219,12 +4281,13 @@ func (h *Handler) UploadCustomFile(w http.ResponseWriter, r *http.Request) {
 	if isPublic {
 		destDir = filepath.Join(h.dataDir, "files")
 	} else if imageID != nil {
-		var imageName string
+		var imageName, imageRoot string
 		var images []*models.Image
 		images, _ = h.storage.ListImages()
 		for _, i := range images {
 			if i.ID == *imageID {
 				imageName = strings.TrimSuffix(i.Filename, filepath.Ext(i.Filename))
+				imageRoot = h.Libraries.Dir(i.Filename)
 				break
 			}
 		}
@@ -3237,7 +4300,7 @@ func (h *Handler) UploadCustomFile(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		destDir = filepath.Join(h.isoDir, imageName, "autoinstall")
+		destDir = filepath.Join(imageRoot, imageName, "autoinstall")
 	} else {
 		h.sendJSON(w, http.StatusBadRequest, Response{
 			Success: false,
@@ -3406,7 +4469,7 @@ func (h *Handler) DeleteCustomFile(w http.ResponseWriter, r *http.Request) {
 		filePath = filepath.Join(h.dataDir, "files", file.Filename)
 	} else if file.ImageID != nil && file.Image != nil {
 		imageName := strings.TrimSuffix(file.Image.Filename, filepath.Ext(file.Image.Filename))
-		filePath = filepath.Join(h.isoDir, imageName, "files", file.Filename)
+		filePath = filepath.Join(h.Libraries.Dir(file.Image.Filename), imageName, "files", file.Filename)
 	}
 
 	if err = h.storage.DeleteCustomFile(uint(id)); err != nil {
@@ -3499,10 +4562,11 @@ func (h *Handler) UploadDriverPack(w http.ResponseWriter, r *http.Request) {
 
 	var images []*models.Image
 	images, _ = h.storage.ListImages()
-	var imageName string
+	var imageName, imageRoot string
 	for _, img := range images {
 		if img.ID == uint(imageID) {
 			imageName = strings.TrimSuffix(img.Filename, filepath.Ext(img.Filename))
+			imageRoot = h.Libraries.Dir(img.Filename)
 			break
 		}
 	}
@@ -3533,7 +4597,7 @@ func (h *Handler) UploadDriverPack(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cleanFilename := filepath.Clean(originalFilename)
-	destDir := filepath.Join(h.isoDir, imageName, "drivers")
+	destDir := filepath.Join(imageRoot, imageName, "drivers")
 
 	if err := os.MkdirAll(destDir, 0755); err != nil {
 		h.sendJSON(w, http.StatusInternalServerError, Response{
@@ -3617,10 +4681,11 @@ func (h *Handler) DeleteDriverPack(w http.ResponseWriter, r *http.Request) {
 
 	var images []*models.Image
 	images, _ = h.storage.ListImages()
-	var imageName string
+	var imageName, imageRoot string
 	for _, img := range images {
 		if img.ID == pack.ImageID {
 			imageName = strings.TrimSuffix(img.Filename, filepath.Ext(img.Filename))
+			imageRoot = h.Libraries.Dir(img.Filename)
 			break
 		}
 	}
@@ -3631,7 +4696,7 @@ func (h *Handler) DeleteDriverPack(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if imageName != "" {
-		filePath := filepath.Join(h.isoDir, imageName, "drivers", pack.Filename)
+		filePath := filepath.Join(imageRoot, imageName, "drivers", pack.Filename)
 		if err := os.Remove(filePath); err != nil {
 			log.Printf("Warning: Failed to delete driver pack file %s: %v", filePath, err)
 		}
@@ -3659,19 +4724,20 @@ func (h *Handler) RebuildImageBootWim(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Rebuilding boot.wim for image ID %d...", imageID)
-
-	go func() {
-		if err := h.RebuildBootWim(uint(imageID)); err != nil {
-			log.Printf("ERROR: Failed to rebuild boot.wim for image %d: %v", imageID, err)
-		} else {
-			log.Printf("Successfully rebuilt boot.wim for image %d", imageID)
-		}
-	}()
+	job, err := h.jobs.Go(h.background(), "wim-rebuild", strconv.FormatUint(imageID, 10), nil)
+	if errors.Is(err, jobs.ErrRunning) {
+		h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "boot.wim is already being rebuilt", Data: map[string]interface{}{"job_id": job.ID}})
+		return
+	}
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
 
 	h.sendJSON(w, http.StatusOK, Response{
 		Success: true,
-		Message: "Boot.wim rebuild started in background. Check logs for progress.",
+		Message: fmt.Sprintf("Boot.wim rebuild started in background (job %d).", job.ID),
+		Data:    map[string]interface{}{"job_id": job.ID},
 	})
 }
 
@@ -3788,7 +4854,7 @@ func (h *Handler) resolveRedfish(c *models.Client) (string, int, string, string,
 	host := c.IPMIHost
 	port := c.IPMIPort
 	user := c.IPMIUsername
-	pass := c.IPMIPassword
+	pass := string(c.IPMIPassword)
 	insecure := c.IPMIInsecure
 
 	if c.ClientGroupID != nil {
@@ -3800,7 +4866,7 @@ func (h *Handler) resolveRedfish(c *models.Client) (string, int, string, string,
 				user = g.IPMIUsername
 			}
 			if pass == "" {
-				pass = g.IPMIPassword
+				pass = string(g.IPMIPassword)
 			}
 			if !insecure {
 				insecure = g.IPMIInsecure
@@ -4072,6 +5138,82 @@ func (h *Handler) UpdateWebhookConfig(w http.ResponseWriter, r *http.Request) {
 	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Webhook config saved", Data: cfg})
 }
 
+func (h *Handler) GetAccessConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	cfg, err := h.storage.GetAccessConfig()
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: cfg})
+}
+
+func (h *Handler) UpdateAccessConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	var cfg models.AccessConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid body"})
+		return
+	}
+	for i, k := range cfg.SSHAuthorizedKeys {
+		k = strings.TrimSpace(k)
+		cfg.SSHAuthorizedKeys[i] = k
+		if k == "" || strings.HasPrefix(k, "#") {
+			continue
+		}
+		if !validSSHPublicKey(k) {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: fmt.Sprintf("Invalid SSH public key on line %d", i+1)})
+			return
+		}
+	}
+	if cfg.DefaultUsername != "" && !validUnixUsername(cfg.DefaultUsername) {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid default username"})
+		return
+	}
+	if err := h.storage.UpdateAccessConfig(&cfg); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	log.Printf("Admin: Access config saved (%d SSH key(s), default user %q)", len(cfg.SSHAuthorizedKeys), cfg.DefaultUsername)
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Access config saved", Data: cfg})
+}
+
+func validSSHPublicKey(k string) bool {
+	fields := strings.Fields(k)
+	if len(fields) < 2 {
+		return false
+	}
+	if _, err := base64.StdEncoding.DecodeString(fields[1]); err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(fields[0], "ssh-"), strings.HasPrefix(fields[0], "ecdsa-"), strings.HasPrefix(fields[0], "sk-"):
+		return true
+	}
+	return false
+}
+
+func validUnixUsername(name string) bool {
+	if len(name) > 32 {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c == '_':
+		case i > 0 && (c >= '0' && c <= '9' || c == '-'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func (h *Handler) TestWebhook(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
@@ -4089,7 +5231,7 @@ func (h *Handler) TestWebhook(w http.ResponseWriter, r *http.Request) {
 	})
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
-	req, _ := http.NewRequestWithContext(ctx, "POST", cfg.URL, bytes.NewReader(body))
+	req, _ := http.NewRequestWithContext(ctx, "POST", string(cfg.URL), bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "bootimus-webhook/1 (test)")
 	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
@@ -4199,7 +5341,7 @@ func (h *Handler) ExportBackup(w http.ResponseWriter, r *http.Request) {
 			return openErr
 		}
 		defer f.Close()
-		_, copyErr := io.Copy(tw, f)
+		_, copyErr := ctxio.Copy(r.Context(), tw, f)
 		return copyErr
 	})
 	if err != nil {
@@ -4625,6 +5767,10 @@ func (h *Handler) UpdateMenuTheme(w http.ResponseWriter, r *http.Request) {
 		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
 		return
 	}
+	if err := theme.Validate(); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
 	if err := h.storage.UpdateMenuTheme(&theme); err != nil {
 		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
 		return
@@ -4709,7 +5855,7 @@ func (h *Handler) ListImageFiles(w http.ResponseWriter, r *http.Request) {
 	log.Printf("ListImageFiles: requested for image: %s", filename)
 
 	baseDir := strings.TrimSuffix(filename, filepath.Ext(filename))
-	bootDir := filepath.Join(h.isoDir, baseDir)
+	bootDir := filepath.Join(h.Libraries.Dir(filename), baseDir)
 
 	log.Printf("ListImageFiles: boot directory: %s", bootDir)
 
@@ -4790,7 +5936,7 @@ func (h *Handler) DeleteImageFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.IsIso {
-		isoPath := filepath.Join(h.isoDir, req.Filename)
+		isoPath := filepath.Join(h.Libraries.Dir(req.Filename), req.Filename)
 		if _, err := os.Stat(isoPath); err != nil {
 			h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "ISO file not found"})
 			return
@@ -4808,7 +5954,7 @@ func (h *Handler) DeleteImageFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.Path != "" && !req.IsDir {
-		bootDir := filepath.Join(h.isoDir, req.BaseDir)
+		bootDir := filepath.Join(h.Libraries.Dir(req.BaseDir), req.BaseDir)
 		filePath := filepath.Join(bootDir, req.Path)
 
 		cleanTarget, err := filepath.Abs(filePath)
@@ -4838,7 +5984,7 @@ func (h *Handler) DeleteImageFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bootDir := filepath.Join(h.isoDir, req.BaseDir)
+	bootDir := filepath.Join(h.Libraries.Dir(req.BaseDir), req.BaseDir)
 	if _, err := os.Stat(bootDir); err != nil {
 		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Boot directory not found"})
 		return
@@ -4873,7 +6019,9 @@ func (h *Handler) DeleteImageFile(w http.ResponseWriter, r *http.Request) {
 		image.BootMethod = "sanboot"
 		image.KernelPath = ""
 		image.InitrdPath = ""
+		image.KernelArches = nil
 		image.SquashfsPath = ""
+		image.LiteInitrdSize = 0
 		image.Distro = ""
 		image.NetbootAvailable = false
 		image.NetbootRequired = false
@@ -4889,3 +6037,28 @@ func (h *Handler) DeleteImageFile(w http.ResponseWriter, r *http.Request) {
 
 	h.sendJSON(w, http.StatusOK, Response{Success: true})
 }
+
+// ListMatchbox shows the Matchbox profiles and groups bootimus serves, with
+// any files that failed to load.
+func (h *Handler) ListMatchbox(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	if h.Matchbox == nil {
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{}})
+		return
+	}
+	profiles, profileErrs := h.Matchbox.Profiles()
+	groups, groupErrs := h.Matchbox.Groups()
+	var errs []string
+	for _, err := range append(profileErrs, groupErrs...) {
+		errs = append(errs, err.Error())
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"dir":      h.Matchbox.Dir(),
+		"profiles": profiles,
+		"groups":   groups,
+		"errors":   errs,
+	}})
+}
@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+)
+
+func TestUpdateClientIsAtomic(t *testing.T) {
+	store, err := storage.NewSQLiteStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{storage: store}
+	const mac = "52:54:00:12:34:56"
+	if err := store.CreateClient(&models.Client{MACAddress: mac, Name: "web1", AllowedImages: models.StringSlice{"a.iso"}}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.GetClient(mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(body string) int {
+		rec := httptest.NewRecorder()
+		h.UpdateClient(rec, httptest.NewRequest(http.MethodPut, "/api/clients?mac="+mac, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	// Someone else saves first.
+	if code := put(fmt.Sprintf(`{"name": "web1-renamed", "version": %d}`, loaded.Version)); code != http.StatusOK {
+		t.Fatalf("first update: got %d", code)
+	}
+	// The stale edit, with new assignments, is refused as a whole.
+	if code := put(fmt.Sprintf(`{"name": "stale", "image_filenames": ["b.iso"], "version": %d}`, loaded.Version)); code != http.StatusConflict {
+		t.Fatalf("stale update: got %d, want 409", code)
+	}
+	got, err := store.GetClient(mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "web1-renamed" || len(got.AllowedImages) != 1 || got.AllowedImages[0] != "a.iso" {
+		t.Errorf("stale update changed the client: name %q, images %v", got.Name, got.AllowedImages)
+	}
+
+	// A current edit saves the fields and the assignments together.
+	if code := put(fmt.Sprintf(`{"name": "web1", "image_filenames": ["b.iso"], "version": %d}`, got.Version)); code != http.StatusOK {
+		t.Fatalf("current update: got %d", code)
+	}
+	// Without a version the update is unchecked, as before.
+	if code := put(`{"description": "rack 4"}`); code != http.StatusOK {
+		t.Fatalf("unversioned update: got %d", code)
+	}
+	got, _ = store.GetClient(mac)
+	if got.Name != "web1" || got.Description != "rack 4" || len(got.AllowedImages) != 1 || got.AllowedImages[0] != "b.iso" {
+		t.Errorf("updates not saved: name %q, description %q, images %v", got.Name, got.Description, got.AllowedImages)
+	}
+}
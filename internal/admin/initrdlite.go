@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bootimus/internal/initrd"
+	"bootimus/internal/models"
+)
+
+type liteInitrdRequest struct {
+	Strip []string `json:"strip,omitempty"` // firmware classes; default initrd.DefaultStrip
+	Level int      `json:"level,omitempty"` // zstd level; default initrd.DefaultLevel
+}
+
+// liteInitrdPath is where the slimmed copy of an image's initrd goes, next
+// to the extracted initrd so /boot/ serves both.
+func (h *Handler) liteInitrdPath(image *models.Image) (string, string) {
+	filename := image.DiskFilename()
+	dir := filepath.Join(h.Libraries.Dir(filename), strings.TrimSuffix(filename, filepath.Ext(filename)))
+	return filepath.Join(dir, "initrd"), filepath.Join(dir, "initrd-lite")
+}
+
+// dropLiteInitrd removes the lite initrd of an image whose initrd was just
+// replaced; it would boot the old one. The caller saves the image.
+func (h *Handler) dropLiteInitrd(image *models.Image) {
+	if image.LiteInitrdSize == 0 {
+		return
+	}
+	_, dst := h.liteInitrdPath(image)
+	os.Remove(dst)
+	image.LiteInitrdSize = 0
+}
+
+// LiteInitrd builds (POST) or removes (DELETE) the "lite" initrd of an
+// extracted image. Clients with lite_initrd set boot it instead of the
+// full one.
+func (h *Handler) LiteInitrd(w http.ResponseWriter, r *http.Request) {
+	image, err := h.storage.GetImage(r.URL.Query().Get("filename"))
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
+		return
+	}
+	if h.refuseVariant(w, image, "repack") {
+		return
+	}
+	src, dst := h.liteInitrdPath(image)
+
+	switch r.Method {
+	case http.MethodPost:
+		var req liteInitrdRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+				return
+			}
+		}
+		if req.Strip == nil {
+			req.Strip = initrd.DefaultStrip
+		}
+		if req.Level == 0 {
+			req.Level = initrd.DefaultLevel
+		}
+		if !image.Extracted {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Extract the image first"})
+			return
+		}
+		if _, err := os.Stat(src); err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "This image has no extracted initrd"})
+			return
+		}
+		if !initrd.Available() {
+			h.sendJSON(w, http.StatusServiceUnavailable, Response{Success: false, Error: "zstd is not installed on the server"})
+			return
+		}
+		if h.jobs.Running("initrd-lite") {
+			h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "An initrd is already being repacked"})
+			return
+		}
+
+		job := h.jobs.Start("initrd-lite", image.Filename)
+		go func() {
+			job.Logf("Repacking %s without firmware for %s (zstd -%d)", src, strings.Join(req.Strip, ", "), req.Level)
+			res, err := initrd.Repack(src, dst, initrd.Options{Strip: req.Strip, Level: req.Level})
+			if err != nil {
+				job.Finish(err)
+				return
+			}
+			job.Logf("Dropped %d files (%d MB uncompressed); %d MB -> %d MB",
+				res.Removed, res.RemovedBytes/(1024*1024), res.Before/(1024*1024), res.After/(1024*1024))
+			image, err := h.storage.GetImage(image.Filename)
+			if err == nil {
+				image.LiteInitrdSize = res.After
+				err = h.storage.UpdateImage(image.Filename, image)
+			}
+			job.Finish(err)
+		}()
+		h.sendJSON(w, http.StatusAccepted, Response{
+			Success: true,
+			Message: fmt.Sprintf("Repacking the initrd of %s", image.Name),
+			Data:    map[string]uint64{"job_id": job.ID},
+		})
+
+	case http.MethodDelete:
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		image.LiteInitrdSize = 0
+		if err := h.storage.UpdateImage(image.Filename, image); err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Lite initrd removed"})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
@@ -0,0 +1,291 @@
+package admin
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"bootimus/internal/jobs"
+	"bootimus/internal/models"
+)
+
+// integrityPoll is how often VerifyISOs looks for ISOs due a rehash. Each
+// ISO is only hashed once per interval; polling more often than that just
+// spreads the work out, as ISOs come due at different times.
+const integrityPoll = time.Hour
+
+// VerifyISOs rehashes every local ISO once per interval and compares it
+// with the stored checksum, so bit-rot on the storage volume is caught
+// before a client reads a corrupt block halfway through an install. An ISO
+// without a stored checksum has its first hash recorded instead.
+func (h *Handler) VerifyISOs(interval time.Duration) {
+	ticker := time.NewTicker(integrityPoll)
+	defer ticker.Stop()
+	for range ticker.C {
+		if h.jobs.Running("integrity") {
+			continue
+		}
+		images, err := h.storage.ListImages()
+		if err != nil {
+			continue
+		}
+		for _, image := range images {
+			if !h.integrityCheckable(image) {
+				continue
+			}
+			if image.VerifiedAt != nil && time.Since(*image.VerifiedAt) < interval {
+				continue
+			}
+			job := h.jobs.Start("integrity", image.Filename)
+			job.Finish(h.verifyISO(image.Filename, job))
+		}
+	}
+}
+
+// integrityCheckable reports whether image has an ISO of its own on a
+// local library. Variants share their source's ISO and result, and remote
+// libraries would have to be downloaded in full to hash.
+func (h *Handler) integrityCheckable(image *models.Image) bool {
+	if !image.HasISOFile() || image.CloneOf != "" {
+		return false
+	}
+	if _, local := h.Libraries.Find(image.Filename); local {
+		return true
+	}
+	return !h.Libraries.Exists(image.Filename) // missing, which is worth flagging
+}
+
+// verifyISO hashes one ISO and records the outcome on its image.
+func (h *Handler) verifyISO(filename string, job *jobs.Job) error {
+	path, err := h.Libraries.Join(filename)
+	if err != nil {
+		return err
+	}
+	job.Logf("Hashing %s", path)
+	start := time.Now()
+	sum, md5sum, hashErr := fileChecksums(path, job.SetProgress)
+
+	// Hashing takes minutes, so the record is read again afterwards rather
+	// than overwriting changes made in the meantime.
+	image, err := h.storage.GetImage(filename)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	image.VerifiedAt = &now
+	image.IntegrityError = ""
+	switch {
+	case os.IsNotExist(hashErr):
+		image.IntegrityError = "ISO file is missing"
+	case hashErr != nil:
+		image.IntegrityError = fmt.Sprintf("ISO could not be read: %v", hashErr)
+	case image.SHA256 != "" && sum != image.SHA256:
+		image.IntegrityError = fmt.Sprintf("sha256 is %s, expected %s", sum, image.SHA256)
+	case image.MD5 != "" && md5sum != image.MD5:
+		image.IntegrityError = fmt.Sprintf("md5 is %s, expected %s", md5sum, image.MD5)
+	case image.SHA256 == "":
+		image.SHA256, image.MD5 = sum, md5sum
+		job.Logf("No sha256 stored; recorded sha256 %s and md5 %s", sum, md5sum)
+	default:
+		image.MD5 = md5sum
+		job.Logf("sha256 matches (%s)", time.Since(start).Round(time.Second))
+	}
+	if err := h.storage.UpdateImage(filename, image); err != nil {
+		return err
+	}
+	if image.IntegrityError == "" {
+		return nil
+	}
+	log.Printf("Integrity: %s failed its check: %s", filename, image.IntegrityError)
+	if h.IntegrityAlert != nil {
+		h.IntegrityAlert(filename, image.IntegrityError)
+	}
+	return errors.New(image.IntegrityError)
+}
+
+// fileChecksums hashes a file with SHA-256 and MD5 in a single read,
+// reporting the percentage read as it goes.
+func fileChecksums(path string, progress func(percent int)) (sha, md5sum string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", "", err
+	}
+	shaHash, md5Hash := sha256.New(), md5.New()
+	w := io.MultiWriter(shaHash, md5Hash)
+	buf := make([]byte, 4<<20)
+	var read int64
+	for {
+		n, err := f.Read(buf)
+		w.Write(buf[:n])
+		read += int64(n)
+		if info.Size() > 0 {
+			progress(int(read * 100 / info.Size()))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return hex.EncodeToString(shaHash.Sum(nil)), hex.EncodeToString(md5Hash.Sum(nil)), nil
+}
+
+// checksumInBackground hashes a freshly uploaded or downloaded ISO in an
+// "integrity" job, recording its checksums or checking the expected ones
+// stored with the download.
+func (h *Handler) checksumInBackground(filename string) {
+	job := h.jobs.Start("integrity", filename)
+	go func() {
+		job.Finish(h.verifyISO(filename, job))
+	}()
+}
+
+// validChecksum accepts an empty checksum or a hex digest of size bytes.
+func validChecksum(sum string, size int) bool {
+	if sum == "" {
+		return true
+	}
+	b, err := hex.DecodeString(sum)
+	return err == nil && len(b) == size
+}
+
+// forgetChecksum clears the integrity state of an image whose ISO was just
+// replaced, so the new file's hash is recorded rather than flagged.
+func forgetChecksum(image *models.Image) {
+	image.SHA256 = ""
+	image.MD5 = ""
+	image.VerifiedAt = nil
+	image.IntegrityError = ""
+}
+
+type integrityEntry struct {
+	Filename   string     `json:"filename"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"` // ok, failed, unverified or remote
+	SHA256     string     `json:"sha256,omitempty"`
+	MD5        string     `json:"md5,omitempty"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Integrity reports the last rehash of every ISO (GET) or starts one now
+// (POST, ?filename= for a single ISO) in an "integrity" job.
+func (h *Handler) Integrity(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		images, err := h.storage.ListImages()
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		summary := map[string]int{}
+		entries := []integrityEntry{}
+		for _, image := range images {
+			if !image.HasISOFile() || image.CloneOf != "" {
+				continue
+			}
+			e := integrityEntry{
+				Filename:   image.Filename,
+				Name:       image.Name,
+				SHA256:     image.SHA256,
+				MD5:        image.MD5,
+				VerifiedAt: image.VerifiedAt,
+				Error:      image.IntegrityError,
+			}
+			switch {
+			case !h.integrityCheckable(image):
+				e.Status = "remote"
+			case image.IntegrityError != "":
+				e.Status = "failed"
+			case image.VerifiedAt == nil:
+				e.Status = "unverified"
+			default:
+				e.Status = "ok"
+			}
+			summary[e.Status]++
+			entries = append(entries, e)
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+			"summary": summary,
+			"images":  entries,
+		}})
+
+	case http.MethodPost:
+		if h.jobs.Running("integrity") {
+			h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "An integrity check is already running"})
+			return
+		}
+		var filenames []string
+		if filename := r.URL.Query().Get("filename"); filename != "" {
+			image, err := h.storage.GetImage(filename)
+			if err != nil {
+				h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
+				return
+			}
+			if h.refuseVariant(w, image, "verify") {
+				return
+			}
+			if !h.integrityCheckable(image) {
+				h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Only ISOs on a local library can be verified"})
+				return
+			}
+			filenames = []string{filename}
+		} else {
+			images, err := h.storage.ListImages()
+			if err != nil {
+				h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+				return
+			}
+			for _, image := range images {
+				if h.integrityCheckable(image) {
+					filenames = append(filenames, image.Filename)
+				}
+			}
+		}
+		if len(filenames) == 0 {
+			h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "No ISOs to verify"})
+			return
+		}
+
+		target := filenames[0]
+		if len(filenames) > 1 {
+			target = fmt.Sprintf("%d ISOs", len(filenames))
+		}
+		job := h.jobs.Start("integrity", target)
+		go func() {
+			var failed int
+			for _, filename := range filenames {
+				if err := h.verifyISO(filename, job); err != nil {
+					job.Logf("%s: %v", filename, err)
+					failed++
+				}
+			}
+			if failed > 0 {
+				job.Finish(fmt.Errorf("%d of %d ISOs failed", failed, len(filenames)))
+				return
+			}
+			job.Finish(nil)
+		}()
+		h.sendJSON(w, http.StatusAccepted, Response{
+			Success: true,
+			Message: fmt.Sprintf("Verifying %d ISO(s)", len(filenames)),
+			Data:    map[string]uint64{"job_id": job.ID},
+		})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bootimus/internal/extractor"
+	"bootimus/internal/jobs"
+)
+
+// registerJobs sets how the kinds of job that can be retried run again from
+// their target and params.
+func (h *Handler) registerJobs() {
+	h.jobs.Handle("extract", jobs.Policy{}, func(ctx context.Context, job *jobs.Job) error {
+		image, err := h.storage.GetImage(job.Target)
+		if err != nil {
+			return fmt.Errorf("image %s: %w", job.Target, err)
+		}
+		job.Logf("Starting kernel/initrd extraction (re-extract: %v)", image.Extracted)
+		if err := h.extractImage(ctx, image, job); err != nil {
+			return err
+		}
+		h.autoInstallNetboot(job.Target)
+		return nil
+	})
+	// Mirrors have bad moments, so netboot fetches and ISO downloads get
+	// a few tries.
+	h.jobs.Handle("netboot", jobs.Policy{Attempts: 3, Backoff: 30 * time.Second}, func(ctx context.Context, job *jobs.Job) error {
+		image, err := h.storage.GetImage(job.Target)
+		if err != nil {
+			return fmt.Errorf("image %s: %w", job.Target, err)
+		}
+		_, err = h.installNetboot(ctx, job, image)
+		return err
+	})
+	h.jobs.Handle("download", jobs.Policy{Attempts: 3, Backoff: time.Minute}, h.runDownload)
+	h.jobs.Handle("wim-rebuild", jobs.Policy{}, func(ctx context.Context, job *jobs.Job) error {
+		id, err := strconv.ParseUint(job.Target, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid image ID %q", job.Target)
+		}
+		job.Logf("Rebuilding boot.wim for image ID %d", id)
+		return h.RebuildBootWim(job, uint(id))
+	})
+}
+
+// trackExtraction has job report the extractor's progress.
+func trackExtraction(job *jobs.Job, p *extractor.ProgressReporter) {
+	job.Track(func() jobs.Progress {
+		s := p.Snapshot()
+		return jobs.Progress{Stage: s.Stage, Percent: s.Percent, Files: s.Files, Bytes: s.Bytes, TotalBytes: s.TotalBytes}
+	})
+}
+
+// ListJobs returns recent jobs, newest first. ?kind=, ?target= and
+// ?status= filter.
+func (h *Handler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	kind, target, status := q.Get("kind"), q.Get("target"), q.Get("status")
+	var list []jobs.Info
+	for _, j := range h.jobs.List() {
+		if (kind == "" || j.Kind == kind) && (target == "" || j.Target == target) && (status == "" || j.Status == status) {
+			list = append(list, j)
+		}
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: list})
+}
+
+// GetJob serves /api/jobs/{id} and /api/jobs/{id}/log, and on POST
+// /api/jobs/{id}/cancel and /api/jobs/{id}/retry stops a job or runs a
+// failed one again. The log is plain text; ?since=<n> skips the first n
+// lines so clients can poll for more.
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/"), "/")
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "log" && parts[1] != "cancel" && parts[1] != "retry") {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Not found"})
+		return
+	}
+	job, ok := h.jobs.Get(id)
+	if !ok {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Job not found"})
+		return
+	}
+	if len(parts) == 1 {
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: job.Snapshot()})
+		return
+	}
+	if parts[1] != "log" && r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+
+	switch parts[1] {
+	case "cancel":
+		if !job.Cancel() {
+			h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "The job has finished or can't be cancelled"})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Job cancelled", Data: job.Snapshot()})
+	case "retry":
+		retry, err := h.jobs.Retry(h.background(), id)
+		switch {
+		case errors.Is(err, jobs.ErrRunning):
+			h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "Another job for the same target is running", Data: map[string]interface{}{"job_id": retry.ID}})
+		case errors.Is(err, jobs.ErrNotStopped), errors.Is(err, jobs.ErrSucceeded), errors.Is(err, jobs.ErrNoRunner):
+			h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: err.Error()})
+		case err != nil:
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		default:
+			h.sendJSON(w, http.StatusAccepted, Response{Success: true, Message: fmt.Sprintf("Retrying as job %d", retry.ID), Data: retry.Snapshot()})
+		}
+	default:
+		since, _ := strconv.Atoi(r.URL.Query().Get("since"))
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Job-Status", job.Snapshot().Status)
+		for _, l := range job.Lines(since) {
+			fmt.Fprintln(w, l)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"net/http"
+
+	"bootimus/internal/library"
+	"bootimus/internal/models"
+)
+
+// ListLibraries lists the ISO libraries in search order with their image count
+// and free space.
+func (h *Handler) ListLibraries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: h.Libraries.Usage()})
+}
+
+func syncFiles(isos []library.ISO) []models.SyncFile {
+	files := make([]models.SyncFile, 0, len(isos))
+	for _, iso := range isos {
+		files = append(files, models.SyncFile{
+			Name:      iso.Name(),
+			Filename:  iso.Path,
+			Size:      iso.Size,
+			GroupPath: iso.GroupPath(),
+		})
+	}
+	return files
+}
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"bootimus/internal/storage"
+)
+
+// Orphans reports references to records that no longer exist (GET) or
+// fixes them (POST, optionally {"kinds": [...]} to fix only some).
+func (h *Handler) Orphans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		orphans, err := h.storage.FindOrphans()
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: orphans})
+
+	case http.MethodPost:
+		var req struct {
+			Kinds []string `json:"kinds"`
+		}
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+				return
+			}
+		}
+		if len(req.Kinds) == 0 {
+			req.Kinds = storage.OrphanKinds
+		}
+		fixed, err := h.storage.FixOrphans(req.Kinds)
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		msg := "No orphaned records found"
+		if len(fixed) > 0 {
+			msg = "Fixed " + summarizeOrphans(fixed)
+			log.Printf("Admin: Fixed orphaned records: %s", summarizeOrphans(fixed))
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: msg, Data: fixed})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
+
+func summarizeOrphans(fixed map[string]int64) string {
+	parts := make([]string, 0, len(fixed))
+	for kind, n := range fixed {
+		parts = append(parts, fmt.Sprintf("%d %s", n, kind))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
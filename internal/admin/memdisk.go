@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// MemdiskLoader serves /api/loaders/memdisk. GET reports the installed
+// loader, POST installs one from an uploaded "file" (the memdisk binary or a
+// syslinux tarball) or downloads it from {"url": ...}, defaulting to the
+// syslinux release, and DELETE removes it.
+func (h *Handler) MemdiskLoader(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: h.toolsManager.MemdiskStatus()})
+
+	case http.MethodPost:
+		var err error
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			if err := r.ParseMultipartForm(32 << 20); err != nil {
+				h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: fmt.Sprintf("Failed to parse form: %v", err)})
+				return
+			}
+			data, ferr := readFormFile(r, "file", 64<<20)
+			if ferr != nil {
+				h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: ferr.Error()})
+				return
+			}
+			err = h.toolsManager.InstallMemdisk(bytes.NewReader(data))
+		} else {
+			var req struct {
+				URL string `json:"url"`
+			}
+			if r.ContentLength > 0 {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+					return
+				}
+			}
+			err = h.toolsManager.FetchMemdisk(req.URL)
+		}
+		if err != nil {
+			log.Printf("Admin: Failed to install memdisk loader: %v", err)
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+			return
+		}
+		status := h.toolsManager.MemdiskStatus()
+		log.Printf("Admin: Memdisk loader installed (sha256 %s)", status.SHA256)
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Memdisk loader installed", Data: status})
+
+	case http.MethodDelete:
+		if err := h.toolsManager.DeleteMemdisk(); err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		log.Printf("Admin: Memdisk loader removed")
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Memdisk loader removed; memdisk tools are hidden from the menu"})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
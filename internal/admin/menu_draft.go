@@ -0,0 +1,236 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"bootimus/internal/models"
+)
+
+// MenuDraft opens a draft (POST), shows it and what changed since it was
+// opened (GET) or discards it, putting the menu back (DELETE). While a draft
+// is open, clients get the menu as it was when the draft was opened; group,
+// image placement and theme edits only reach them on publish.
+func (h *Handler) MenuDraft(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		snap, err := h.storage.GetMenuSnapshot()
+		if err != nil {
+			h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{"active": false}})
+			return
+		}
+		changes, err := h.menuDraftChanges(snap)
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+			"active":     true,
+			"created_at": snap.CreatedAt,
+			"changes":    changes,
+		}})
+
+	case http.MethodPost:
+		if _, err := h.storage.GetMenuSnapshot(); err == nil {
+			h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "A menu draft is already open"})
+			return
+		}
+		groups, err := h.storage.ListImageGroups()
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		images, err := h.storage.ListImages()
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		theme, err := h.storage.GetMenuTheme()
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		snap, err := models.NewMenuSnapshot(groups, images, theme)
+		if err == nil {
+			err = h.storage.CreateMenuSnapshot(snap)
+		}
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		log.Printf("Admin: Opened menu draft")
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Menu draft opened; clients keep the current menu until you publish"})
+
+	case http.MethodDelete:
+		snap, err := h.storage.GetMenuSnapshot()
+		if err != nil {
+			h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "No menu draft is open"})
+			return
+		}
+		if err := h.storage.RestoreMenuSnapshot(snap); err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		log.Printf("Admin: Discarded menu draft")
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Draft discarded; the menu is back as published"})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
+
+// PublishMenuDraft makes the draft live for every client at once.
+func (h *Handler) PublishMenuDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	if _, err := h.storage.GetMenuSnapshot(); err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "No menu draft is open"})
+		return
+	}
+	if err := h.storage.DeleteMenuSnapshot(); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	log.Printf("Admin: Published menu draft")
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Menu draft published"})
+}
+
+// PreviewMenuDraft returns the iPXE menu a MAC would get from the draft.
+func (h *Handler) PreviewMenuDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	mac := r.URL.Query().Get("mac")
+	if mac == "" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Missing mac parameter"})
+		return
+	}
+	if h.MenuPreview == nil {
+		h.sendJSON(w, http.StatusServiceUnavailable, Response{Success: false, Error: "Menu preview is not available"})
+		return
+	}
+	script, err := h.MenuPreview(mac)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(script))
+}
+
+// menuDraftChanges lists, in words, how the tables differ from the snapshot.
+func (h *Handler) menuDraftChanges(snap *models.MenuSnapshot) ([]string, error) {
+	oldGroups, oldImages, oldTheme, err := snap.Layout()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := h.storage.ListImageGroups()
+	if err != nil {
+		return nil, err
+	}
+	images, err := h.storage.ListImages()
+	if err != nil {
+		return nil, err
+	}
+	theme, err := h.storage.GetMenuTheme()
+	if err != nil {
+		return nil, err
+	}
+
+	groupName := map[uint]string{}
+	for _, g := range oldGroups {
+		groupName[g.ID] = g.Name
+	}
+	for _, g := range groups {
+		groupName[g.ID] = g.Name
+	}
+	nameOf := func(id *uint) string {
+		if id == nil {
+			return "(no group)"
+		}
+		return fmt.Sprintf("%q", groupName[*id])
+	}
+
+	var changes []string
+	before := map[uint]*models.ImageGroup{}
+	for _, g := range oldGroups {
+		before[g.ID] = g
+	}
+	for _, g := range groups {
+		old, ok := before[g.ID]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("Group %q added", g.Name))
+			continue
+		}
+		delete(before, g.ID)
+		if old.Name != g.Name {
+			changes = append(changes, fmt.Sprintf("Group %q renamed to %q", old.Name, g.Name))
+		}
+		if !sameID(old.ParentID, g.ParentID) {
+			changes = append(changes, fmt.Sprintf("Group %q moved from %s to %s", g.Name, nameOf(old.ParentID), nameOf(g.ParentID)))
+		}
+		if old.Order != g.Order {
+			changes = append(changes, fmt.Sprintf("Group %q order %d → %d", g.Name, old.Order, g.Order))
+		}
+		if old.Enabled != g.Enabled {
+			changes = append(changes, fmt.Sprintf("Group %q %s", g.Name, enabledWord(g.Enabled)))
+		}
+	}
+	for _, g := range before {
+		changes = append(changes, fmt.Sprintf("Group %q removed", g.Name))
+	}
+
+	layout := map[string]models.MenuImageLayout{}
+	for _, l := range oldImages {
+		layout[l.Filename] = l
+	}
+	for _, img := range images {
+		old, ok := layout[img.Filename]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("Image %q added (hidden from clients until publish)", img.Name))
+			continue
+		}
+		if old.Name != img.Name {
+			changes = append(changes, fmt.Sprintf("Image %q renamed to %q", old.Name, img.Name))
+		}
+		if !sameID(old.GroupID, img.GroupID) {
+			changes = append(changes, fmt.Sprintf("Image %q moved from %s to %s", img.Name, nameOf(old.GroupID), nameOf(img.GroupID)))
+		}
+		if old.Order != img.Order {
+			changes = append(changes, fmt.Sprintf("Image %q order %d → %d", img.Name, old.Order, img.Order))
+		}
+	}
+
+	if oldTheme != nil && theme != nil {
+		if oldTheme.Title != theme.Title {
+			changes = append(changes, fmt.Sprintf("Menu title %q → %q", oldTheme.Title, theme.Title))
+		}
+		if oldTheme.MenuTimeout != theme.MenuTimeout {
+			changes = append(changes, fmt.Sprintf("Menu timeout %ds → %ds", oldTheme.MenuTimeout, theme.MenuTimeout))
+		}
+		if oldTheme.DefaultMenuItem != theme.DefaultMenuItem {
+			changes = append(changes, fmt.Sprintf("Default item %q → %q", oldTheme.DefaultMenuItem, theme.DefaultMenuItem))
+		}
+	}
+	sort.Strings(changes)
+	return changes, nil
+}
+
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func enabledWord(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
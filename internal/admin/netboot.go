@@ -3,13 +3,21 @@ package admin
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"bootimus/internal/jobs"
+	"bootimus/internal/models"
+	"bootimus/internal/netboot"
 )
 
 func (h *Handler) DownloadNetboot(w http.ResponseWriter, r *http.Request) {
@@ -29,6 +37,9 @@ func (h *Handler) DownloadNetboot(w http.ResponseWriter, r *http.Request) {
 		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
 		return
 	}
+	if h.refuseVariant(w, image, "download netboot files for") {
+		return
+	}
 
 	if !image.NetbootRequired {
 		h.sendJSON(w, http.StatusBadRequest, Response{
@@ -46,42 +57,105 @@ func (h *Handler) DownloadNetboot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	imageDir := filepath.Join(h.isoDir, strings.TrimSuffix(filename, filepath.Ext(filename))+"-netboot")
-	if err := os.MkdirAll(imageDir, 0755); err != nil {
-		h.sendJSON(w, http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to create netboot directory: %v", err),
+	if r.URL.Query().Get("async") == "true" {
+		job, err := h.jobs.Go(h.background(), "netboot", filename, nil)
+		if errors.Is(err, jobs.ErrRunning) {
+			h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "Netboot files are already being downloaded", Data: map[string]interface{}{"job_id": job.ID}})
+			return
+		}
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		h.sendJSON(w, http.StatusAccepted, Response{
+			Success: true,
+			Message: "Netboot download started",
+			Data:    map[string]interface{}{"job_id": job.ID},
 		})
 		return
 	}
 
-	log.Printf("Downloading netboot tarball from: %s", image.NetbootURL)
-
-	resp, err := http.Get(image.NetbootURL)
+	job := h.jobs.Start("netboot", filename)
+	filesExtracted, err := h.installNetboot(r.Context(), job, image)
+	job.Finish(err)
 	if err != nil {
-		h.sendJSON(w, http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to download netboot tarball: %v", err),
-		})
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error(), Data: map[string]interface{}{"job_id": job.ID}})
 		return
 	}
+
+	h.sendJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: fmt.Sprintf("Netboot files downloaded and extracted successfully (%d files)", filesExtracted),
+		Data: map[string]interface{}{
+			"files_extracted":   filesExtracted,
+			"netboot_available": true,
+			"netboot_checksum":  image.NetbootChecksum,
+			"job_id":            job.ID,
+		},
+	})
+}
+
+// installNetboot downloads image.NetbootURL, checks it against the published
+// checksum when the URL is one of the known official sources, unpacks it and
+// installs its kernel/initrd as the image's boot files.
+func (h *Handler) installNetboot(ctx context.Context, job *jobs.Job, image *models.Image) (int, error) {
+	filename := image.Filename
+	root := h.Libraries.Dir(filename)
+	imageDir := filepath.Join(root, strings.TrimSuffix(filename, filepath.Ext(filename))+"-netboot")
+	if err := os.MkdirAll(imageDir, 0755); err != nil {
+		return 0, fmt.Errorf("Failed to create netboot directory: %v", err)
+	}
+
+	job.Logf("Downloading netboot tarball from: %s", image.NetbootURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, image.NetbootURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid netboot URL: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to download netboot tarball: %v", err)
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		h.sendJSON(w, http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to download: HTTP %d", resp.StatusCode),
-		})
-		return
+		return 0, fmt.Errorf("Failed to download: HTTP %d", resp.StatusCode)
 	}
 
-	gzReader, err := gzip.NewReader(resp.Body)
+	tarball, err := os.CreateTemp(imageDir, "netboot-*.tar.gz")
 	if err != nil {
-		h.sendJSON(w, http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to create gzip reader: %v", err),
-		})
-		return
+		return 0, fmt.Errorf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tarball.Name())
+	defer tarball.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tarball, hasher), resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to download netboot tarball: %v", err)
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	job.Logf("Downloaded %d bytes (sha256 %s)", size, checksum)
+
+	if src, ok := netboot.ByURL(image.NetbootURL); ok && src.ChecksumURL != "" {
+		expected, err := src.ExpectedChecksum()
+		if err != nil {
+			return 0, fmt.Errorf("Failed to fetch netboot checksum: %v", err)
+		}
+		if expected != checksum {
+			return 0, fmt.Errorf("Netboot tarball checksum mismatch: got %s, want %s", checksum, expected)
+		}
+		job.Logf("Netboot tarball verified against %s", src.ChecksumURL)
+	} else {
+		job.Logf("Netboot URL %s is not a known source; installing without checksum verification", image.NetbootURL)
+	}
+
+	if _, err := tarball.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	gzReader, err := gzip.NewReader(tarball)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to create gzip reader: %v", err)
 	}
 	defer gzReader.Close()
 
@@ -94,53 +168,49 @@ func (h *Handler) DownloadNetboot(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		if err != nil {
-			h.sendJSON(w, http.StatusInternalServerError, Response{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to read tar: %v", err),
-			})
-			return
+			return filesExtracted, fmt.Errorf("Failed to read tar: %v", err)
 		}
 
 		targetPath := filepath.Join(imageDir, header.Name)
 
 		if !strings.HasPrefix(targetPath, filepath.Clean(imageDir)+string(os.PathSeparator)) {
-			log.Printf("Warning: Skipping file outside target directory: %s", header.Name)
+			job.Logf("Warning: Skipping file outside target directory: %s", header.Name)
 			continue
 		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
 			if err := os.MkdirAll(targetPath, 0755); err != nil {
-				log.Printf("Warning: Failed to create directory %s: %v", targetPath, err)
+				job.Logf("Warning: Failed to create directory %s: %v", targetPath, err)
 			}
 		case tar.TypeReg:
 			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-				log.Printf("Warning: Failed to create parent directory for %s: %v", targetPath, err)
+				job.Logf("Warning: Failed to create parent directory for %s: %v", targetPath, err)
 				continue
 			}
 
 			outFile, err := os.Create(targetPath)
 			if err != nil {
-				log.Printf("Warning: Failed to create file %s: %v", targetPath, err)
+				job.Logf("Warning: Failed to create file %s: %v", targetPath, err)
 				continue
 			}
 
 			if _, err := io.Copy(outFile, tarReader); err != nil {
 				outFile.Close()
-				log.Printf("Warning: Failed to write file %s: %v", targetPath, err)
+				job.Logf("Warning: Failed to write file %s: %v", targetPath, err)
 				continue
 			}
 			outFile.Close()
 
 			if err := os.Chmod(targetPath, os.FileMode(header.Mode)); err != nil {
-				log.Printf("Warning: Failed to set permissions on %s: %v", targetPath, err)
+				job.Logf("Warning: Failed to set permissions on %s: %v", targetPath, err)
 			}
 
 			filesExtracted++
 		}
 	}
 
-	log.Printf("Extracted %d files from netboot tarball to %s", filesExtracted, imageDir)
+	job.Logf("Extracted %d files from netboot tarball to %s", filesExtracted, imageDir)
 
 	var vmlinuzPath, initrdPath string
 	filepath.Walk(imageDir, func(path string, info os.FileInfo, err error) error {
@@ -161,34 +231,29 @@ func (h *Handler) DownloadNetboot(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if vmlinuzPath == "" || initrdPath == "" {
-		h.sendJSON(w, http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Netboot files downloaded but vmlinuz/initrd not found in tarball",
-		})
-		return
+		return filesExtracted, fmt.Errorf("Netboot files downloaded but vmlinuz/initrd not found in tarball")
 	}
 
-	imageRootDir := filepath.Join(h.isoDir, strings.TrimSuffix(filename, filepath.Ext(filename)))
+	imageRootDir := filepath.Join(root, strings.TrimSuffix(filename, filepath.Ext(filename)))
 	if err := copyFile(vmlinuzPath, filepath.Join(imageRootDir, "vmlinuz")); err != nil {
-		log.Printf("Warning: Failed to copy vmlinuz: %v", err)
+		job.Logf("Warning: Failed to copy vmlinuz: %v", err)
 	}
 	if err := copyFile(initrdPath, filepath.Join(imageRootDir, "initrd")); err != nil {
-		log.Printf("Warning: Failed to copy initrd: %v", err)
+		job.Logf("Warning: Failed to copy initrd: %v", err)
 	}
 
+	job.Logf("Installed %s and %s as boot files", filepath.Base(vmlinuzPath), filepath.Base(initrdPath))
+
+	now := time.Now()
 	image.NetbootAvailable = true
+	image.NetbootChecksum = checksum
+	image.NetbootFetchedAt = &now
+	h.dropLiteInitrd(image)
 	if err := h.storage.UpdateImage(filename, image); err != nil {
-		log.Printf("Warning: Failed to update image netboot status: %v", err)
+		job.Logf("Warning: Failed to update image netboot status: %v", err)
 	}
 
-	h.sendJSON(w, http.StatusOK, Response{
-		Success: true,
-		Message: fmt.Sprintf("Netboot files downloaded and extracted successfully (%d files)", filesExtracted),
-		Data: map[string]interface{}{
-			"files_extracted":   filesExtracted,
-			"netboot_available": true,
-		},
-	})
+	return filesExtracted, nil
 }
 
 func copyFile(src, dst string) error {
@@ -198,6 +263,9 @@ func copyFile(src, dst string) error {
 	}
 	defer sourceFile.Close()
 
+	// dst may be hard-linked to another version's extraction; replace it
+	// rather than writing through the link.
+	os.Remove(dst)
 	destFile, err := os.Create(dst)
 	if err != nil {
 		return err
@@ -210,3 +278,47 @@ func copyFile(src, dst string) error {
 
 	return destFile.Sync()
 }
+
+func (h *Handler) ListNetbootSources(w http.ResponseWriter, r *http.Request) {
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: netboot.Sources})
+}
+
+// NetbootStatus compares the installed netboot tarball with the checksum
+// currently published upstream; stale means a newer installer is out.
+func (h *Handler) NetbootStatus(w http.ResponseWriter, r *http.Request) {
+	image, err := h.storage.GetImage(r.URL.Query().Get("filename"))
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
+		return
+	}
+
+	status := map[string]interface{}{
+		"netboot_required":   image.NetbootRequired,
+		"netboot_available":  image.NetbootAvailable,
+		"netboot_url":        image.NetbootURL,
+		"netboot_checksum":   image.NetbootChecksum,
+		"netboot_fetched_at": image.NetbootFetchedAt,
+	}
+	if src, ok := netboot.ByURL(image.NetbootURL); ok {
+		status["source"] = src
+		if upstream, err := src.ExpectedChecksum(); err != nil {
+			status["upstream_error"] = err.Error()
+		} else {
+			status["upstream_checksum"] = upstream
+			status["stale"] = image.NetbootAvailable && upstream != image.NetbootChecksum
+		}
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: status})
+}
+
+// autoInstallNetboot fetches the official netboot files right after an
+// extraction that found only an ISO-bound installer kernel.
+func (h *Handler) autoInstallNetboot(filename string) {
+	image, err := h.storage.GetImage(filename)
+	if err != nil || !image.NetbootRequired || image.NetbootAvailable {
+		return
+	}
+	if job, err := h.jobs.Go(h.background(), "netboot", filename, nil); err == nil {
+		job.Logf("no usable network kernel in the ISO, fetching %s", image.NetbootURL)
+	}
+}
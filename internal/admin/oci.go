@@ -0,0 +1,418 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"bootimus/internal/jobs"
+	"bootimus/internal/models"
+	"bootimus/internal/oci"
+	"bootimus/internal/securepath"
+)
+
+const (
+	ociBundleExt = ".oci"
+
+	// Optional manifest annotations a publisher can set on a bundle.
+	ociAnnotationBootParams = "io.bootimus.boot-params"
+	ociAnnotationDistro     = "io.bootimus.distro"
+)
+
+type ociPullRequest struct {
+	Ref         string `json:"ref"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Distro      string `json:"distro"`
+	BootParams  string `json:"boot_params"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	CosignKey   string `json:"cosign_key"`
+	Track       bool   `json:"track"`
+	Public      *bool  `json:"public"`
+}
+
+// ociLayers sorts a manifest's layers into what bootimus knows how to boot.
+type ociLayers struct {
+	iso, kernel, initrd, squashfs *oci.Descriptor
+	extra                         []oci.Descriptor
+}
+
+type ociFile struct {
+	desc *oci.Descriptor
+	name string
+}
+
+func classifyOCILayers(m *oci.Manifest) ociLayers {
+	var l ociLayers
+	for i := range m.Layers {
+		d := &m.Layers[i]
+		name := strings.ToLower(path.Base(d.Title()))
+		switch {
+		case d.Title() == "":
+			continue
+		case strings.HasSuffix(name, ".iso"):
+			l.iso = d
+		case strings.HasPrefix(name, "vmlinuz") || strings.HasPrefix(name, "bzimage") || name == "kernel" || name == "linux":
+			l.kernel = d
+		case strings.HasPrefix(name, "initrd") || strings.HasPrefix(name, "initramfs"):
+			l.initrd = d
+		case strings.HasSuffix(name, ".squashfs") || strings.HasSuffix(name, ".sfs"):
+			l.squashfs = d
+		default:
+			l.extra = append(l.extra, *d)
+		}
+	}
+	return l
+}
+
+// PullOCIImage adds an image from a registry reference. The artifact is
+// either an ISO (a layer titled *.iso) or a bundle of kernel, initrd and
+// optional squashfs layers. The pull runs as a job; tracked images are
+// re-pulled whenever their tag moves.
+func (h *Handler) PullOCIImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+
+	var req ociPullRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+	ref, err := oci.ParseReference(req.Ref)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
+	if req.CosignKey != "" {
+		if _, err := oci.ParsePublicKey(req.CosignKey); err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "cosign_key: " + err.Error()})
+			return
+		}
+	}
+	if strings.ContainsAny(req.BootParams, "\r\n") {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "boot_params must be a single line"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = path.Base(ref.Repository)
+		if ref.Tag != "" && ref.Tag != "latest" {
+			name += "-" + ref.Tag
+		}
+	}
+	image := &models.Image{
+		Name:         name,
+		Description:  req.Description,
+		Enabled:      true,
+		Public:       req.Public == nil || *req.Public,
+		Distro:       req.Distro,
+		BootParams:   strings.TrimSpace(req.BootParams),
+		OCIRef:       strings.TrimSpace(req.Ref),
+		OCITrack:     req.Track,
+		OCIUsername:  req.Username,
+		OCIPassword:  models.Secret(req.Password),
+		OCICosignKey: strings.TrimSpace(req.CosignKey),
+	}
+
+	job := h.jobs.Start("oci-pull", image.OCIRef)
+	go func() {
+		job.Finish(h.pullOCI(job, image))
+	}()
+	h.sendJSON(w, http.StatusAccepted, Response{
+		Success: true,
+		Message: "Pull started",
+		Data:    map[string]interface{}{"job_id": job.ID, "ref": ref.String()},
+	})
+}
+
+// SyncOCIImage checks a registry-backed image's tag now and re-pulls it if
+// the tag has moved, or unconditionally with ?force=true.
+func (h *Handler) SyncOCIImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	image, err := h.storage.GetImage(r.URL.Query().Get("filename"))
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
+		return
+	}
+	if image.OCIRef == "" || image.CloneOf != "" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Image was not pulled from a registry"})
+		return
+	}
+
+	force := r.URL.Query().Get("force") == "true"
+	if !force {
+		moved, digest, err := h.ociTagMoved(image)
+		if err != nil {
+			h.sendJSON(w, http.StatusBadGateway, Response{Success: false, Error: err.Error()})
+			return
+		}
+		if !moved {
+			h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Already up to date", Data: map[string]string{"digest": digest}})
+			return
+		}
+	}
+
+	job := h.jobs.Start("oci-pull", image.Filename)
+	go func() {
+		job.Finish(h.pullOCI(job, image))
+	}()
+	h.sendJSON(w, http.StatusAccepted, Response{Success: true, Message: "Pull started", Data: map[string]interface{}{"job_id": job.ID}})
+}
+
+// ociTagMoved asks the registry where the image's tag points now and
+// records the check.
+func (h *Handler) ociTagMoved(image *models.Image) (bool, string, error) {
+	ref, err := oci.ParseReference(image.OCIRef)
+	if err != nil {
+		return false, "", err
+	}
+	digest := ref.Digest
+	if digest == "" {
+		digest, err = oci.NewClient(image.OCIUsername, string(image.OCIPassword)).Head(ref)
+		if err != nil {
+			return false, "", err
+		}
+	}
+	now := time.Now()
+	image.OCICheckedAt = &now
+	if err := h.storage.UpdateImage(image.Filename, image); err != nil {
+		log.Printf("OCI: failed to record check for %s: %v", image.Filename, err)
+	}
+	return digest != image.OCIDigest, digest, nil
+}
+
+// TrackOCITags re-pulls tracked images whose tag has moved, every interval.
+func (h *Handler) TrackOCITags(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		images, err := h.storage.ListImages()
+		if err != nil {
+			continue
+		}
+		for _, image := range images {
+			if !image.OCITrack || image.OCIRef == "" || image.CloneOf != "" {
+				continue
+			}
+			moved, digest, err := h.ociTagMoved(image)
+			if err != nil {
+				log.Printf("OCI: tag check for %s (%s) failed: %v", image.Filename, image.OCIRef, err)
+				continue
+			}
+			if !moved {
+				continue
+			}
+			job := h.jobs.Start("oci-pull", image.Filename)
+			job.Logf("%s moved to %s", image.OCIRef, digest)
+			job.Finish(h.pullOCI(job, image))
+		}
+	}
+}
+
+// pullOCI fetches image.OCIRef and creates or refreshes the image. A new
+// image has ID 0 and no filename yet; one is derived from the artifact.
+func (h *Handler) pullOCI(job *jobs.Job, image *models.Image) error {
+	ref, err := oci.ParseReference(image.OCIRef)
+	if err != nil {
+		return err
+	}
+	client := oci.NewClient(image.OCIUsername, string(image.OCIPassword))
+
+	digest := ref.Digest
+	if digest == "" {
+		if digest, err = client.Head(ref); err != nil {
+			return err
+		}
+	}
+	job.Logf("Resolved %s to %s", ref, digest)
+
+	if image.OCICosignKey != "" {
+		key, err := oci.ParsePublicKey(image.OCICosignKey)
+		if err != nil {
+			return err
+		}
+		if err := client.VerifyCosign(ref, digest, key); err != nil {
+			return err
+		}
+		job.Logf("Cosign signature verified")
+	}
+
+	pinned := ref
+	pinned.Digest = digest
+	manifest, _, err := client.Resolve(pinned)
+	if err != nil {
+		return err
+	}
+	layers := classifyOCILayers(manifest)
+
+	switch {
+	case layers.iso != nil:
+		err = h.pullOCIISO(job, client, pinned, layers.iso, image)
+	case layers.kernel != nil:
+		err = h.pullOCIBundle(job, client, pinned, manifest, layers, image)
+	default:
+		err = fmt.Errorf("artifact has neither an .iso layer nor a kernel layer (layers need an %s annotation)", oci.AnnotationTitle)
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	image.OCIDigest = digest
+	image.OCICheckedAt = &now
+	if image.ID == 0 {
+		if err := h.storage.CreateImage(image); err != nil {
+			return err
+		}
+	} else if err := h.storage.UpdateImage(image.Filename, image); err != nil {
+		return err
+	}
+	job.Logf("Image %s is at %s", image.Filename, digest)
+	return nil
+}
+
+func (h *Handler) fetchOCILayer(job *jobs.Job, client *oci.Client, ref oci.Reference, desc *oci.Descriptor, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp := dest + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(dest)
+	downloadMgr.Add(transferDownload, ref.String(), name, desc.Size)
+	job.Logf("Fetching %s (%s, %d MB)", desc.Title(), desc.Digest, desc.Size/(1024*1024))
+	err = client.FetchBlob(ref, *desc, f, func(n int64) {
+		downloadMgr.Update(transferDownload, name, n)
+	})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		downloadMgr.Error(transferDownload, name, err.Error())
+		return err
+	}
+	downloadMgr.Complete(transferDownload, name)
+	return os.Rename(tmp, dest)
+}
+
+func (h *Handler) pullOCIISO(job *jobs.Job, client *oci.Client, ref oci.Reference, layer *oci.Descriptor, image *models.Image) error {
+	if image.ID == 0 {
+		filename := filepath.Base(layer.Title())
+		if _, err := securepath.Clean(filename); err != nil {
+			return fmt.Errorf("layer title %q: %w", layer.Title(), err)
+		}
+		if _, err := h.storage.GetImage(filename); err == nil {
+			return fmt.Errorf("an image named %s already exists", filename)
+		}
+		image.Filename = filename
+	} else if image.OCIDigest != "" {
+		job.Logf("ISO changed; its kernel and initrd need extracting again")
+		image.Extracted = false
+		image.BootMethod = "sanboot"
+		image.KernelPath, image.InitrdPath, image.SquashfsPath = "", "", ""
+		image.KernelArches = nil
+		image.ExtractedAt = nil
+		image.ExtractionError = "ISO updated from registry; extract again to boot its kernel"
+	}
+
+	dest, err := h.Libraries.Join(image.Filename)
+	if err != nil {
+		return err
+	}
+	if err := h.fetchOCILayer(job, client, ref, layer, dest); err != nil {
+		return err
+	}
+	image.Size = layer.Size
+	if image.Distro == "" {
+		h.detectAndSetDistro(image)
+	}
+	return nil
+}
+
+func (h *Handler) pullOCIBundle(job *jobs.Job, client *oci.Client, ref oci.Reference, m *oci.Manifest, layers ociLayers, image *models.Image) error {
+	if image.ID == 0 {
+		slug := strings.Trim(variantSlugRe.ReplaceAllString(strings.ToLower(image.Name), "-"), "-")
+		if slug == "" {
+			slug = "oci"
+		}
+		image.Filename = slug + ociBundleExt
+		for i := 2; ; i++ {
+			if _, err := h.storage.GetImage(image.Filename); err != nil {
+				break
+			}
+			image.Filename = fmt.Sprintf("%s-%d%s", slug, i, ociBundleExt)
+		}
+	}
+	dir, err := h.Libraries.Join(strings.TrimSuffix(image.Filename, ociBundleExt))
+	if err != nil {
+		return err
+	}
+
+	// The menu boots /boot/<dir>/vmlinuz and /boot/<dir>/initrd, so those
+	// two are stored under fixed names; everything else keeps its title.
+	files := []ociFile{{layers.kernel, "vmlinuz"}}
+	if layers.initrd != nil {
+		files = append(files, ociFile{layers.initrd, "initrd"})
+	}
+	if layers.squashfs != nil {
+		files = append(files, ociFile{layers.squashfs, filepath.Base(layers.squashfs.Title())})
+	}
+	for i := range layers.extra {
+		files = append(files, ociFile{&layers.extra[i], layers.extra[i].Title()})
+	}
+
+	var total int64
+	for _, f := range files {
+		dest, err := securepath.Join(dir, f.name)
+		if err != nil {
+			return fmt.Errorf("layer title %q: %w", f.desc.Title(), err)
+		}
+		if err := h.fetchOCILayer(job, client, ref, f.desc, dest); err != nil {
+			return err
+		}
+		total += f.desc.Size
+	}
+
+	now := time.Now()
+	image.Size = total
+	image.Extracted = true
+	image.ExtractedAt = &now
+	image.ExtractionError = ""
+	image.BootMethod = "kernel"
+	image.KernelPath = "vmlinuz"
+	image.InitrdPath = ""
+	if layers.initrd != nil {
+		image.InitrdPath = "initrd"
+	}
+	image.SquashfsPath = ""
+	if layers.squashfs != nil {
+		image.SquashfsPath = filepath.Base(layers.squashfs.Title())
+	}
+	if d := m.Annotations[ociAnnotationDistro]; d != "" && image.Distro == "" {
+		image.Distro = d
+	}
+	if p := m.Annotations[ociAnnotationBootParams]; p != "" && image.BootParams == "" && !strings.ContainsAny(p, "\r\n") {
+		image.BootParams = p
+	}
+	if image.BootParams == "" && h.profileManager != nil && image.Distro != "" {
+		image.BootParams = h.profileManager.GetBootParams(image.Distro, image.SquashfsPath != "")
+	}
+	job.Logf("Bundle stored in %s (kernel, initrd: %v, squashfs: %q)", dir, layers.initrd != nil, image.SquashfsPath)
+	return nil
+}
@@ -0,0 +1,106 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"bootimus/internal/policy"
+)
+
+// Policy returns (GET) or replaces (PUT, the rules as the body) the boot
+// policy. A policy that does not parse is refused with the offending line.
+func (h *Handler) Policy(w http.ResponseWriter, r *http.Request) {
+	if h.BootPolicy == nil {
+		h.sendJSON(w, http.StatusServiceUnavailable, Response{Success: false, Error: "Boot policy is not available"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		src, err := h.BootPolicy.Source()
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		data := map[string]interface{}{"path": h.BootPolicy.Path(), "source": src}
+		if _, err := h.BootPolicy.Policy(); err != nil {
+			data["error"] = err.Error()
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: data})
+
+	case http.MethodPut:
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+			return
+		}
+		if err := h.BootPolicy.Save(string(body)); err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Boot policy saved"})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
+
+type policyTestRequest struct {
+	MAC    string   `json:"mac"`
+	IP     string   `json:"ip,omitempty"`
+	Time   string   `json:"time,omitempty"` // RFC 3339; default now
+	Arch   string   `json:"arch,omitempty"` // default: last reported by the client
+	Tags   []string `json:"tags,omitempty"` // default: the client's tags
+	Source string   `json:"source,omitempty"`
+}
+
+// TestPolicy evaluates the boot policy, or the rules in source, for a
+// client and returns the decision with a trace of every rule.
+func (h *Handler) TestPolicy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	if h.BootPolicy == nil || h.PolicyInput == nil {
+		h.sendJSON(w, http.StatusServiceUnavailable, Response{Success: false, Error: "Boot policy is not available"})
+		return
+	}
+	var req policyTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+
+	var p *policy.Policy
+	var err error
+	if req.Source != "" {
+		p, err = policy.Parse(req.Source)
+	} else {
+		p, err = h.BootPolicy.Policy()
+	}
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	in := h.PolicyInput(strings.ToLower(strings.ReplaceAll(req.MAC, "-", ":")), req.IP)
+	if req.Time != "" {
+		if in.Time, err = time.Parse(time.RFC3339, req.Time); err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "time must be RFC 3339"})
+			return
+		}
+	}
+	if req.Arch != "" {
+		in.Arch = req.Arch
+	}
+	if req.Tags != nil {
+		in.Tags = req.Tags
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"input":    in,
+		"decision": p.Evaluate(in),
+	}})
+}
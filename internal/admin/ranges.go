@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	"bootimus/internal/rangestats"
+)
+
+type imageRanges struct {
+	*rangestats.Report
+	Recommendation string `json:"recommendation,omitempty"`
+}
+
+// rangesFor adds advice to what sanboot clients read of an image's ISO.
+func (h *Handler) rangesFor(rep *rangestats.Report) imageRanges {
+	out := imageRanges{Report: rep}
+	if !rep.SuggestExtract {
+		return out
+	}
+	if image, err := h.storage.GetImage(rep.Filename); err == nil && !image.Extracted && image.BootMethod != "kernel" {
+		out.Recommendation = fmt.Sprintf("Sanboot clients read %.0f%% of this ISO. Extracting it and booting by kernel would serve them less.", rep.Fraction*100)
+	}
+	return out
+}
+
+// ImageRanges returns which byte ranges of each ISO sanboot clients have
+// read (GET, ?filename= for one), or forgets them for an ISO (DELETE).
+func (h *Handler) ImageRanges(w http.ResponseWriter, r *http.Request) {
+	if h.Ranges == nil {
+		h.sendJSON(w, http.StatusServiceUnavailable, Response{Success: false, Error: "Range statistics are not available"})
+		return
+	}
+	filename := r.URL.Query().Get("filename")
+	if image, err := h.storage.GetImage(filename); err == nil {
+		filename = image.DiskFilename()
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if filename == "" {
+			reports := []imageRanges{}
+			for _, rep := range h.Ranges.Reports() {
+				reports = append(reports, h.rangesFor(rep))
+			}
+			h.sendJSON(w, http.StatusOK, Response{Success: true, Data: reports})
+			return
+		}
+		rep, ok := h.Ranges.Report(filename)
+		if !ok {
+			h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "No sanboot reads recorded for this image"})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: h.rangesFor(rep)})
+
+	case http.MethodDelete:
+		h.Ranges.Forget(filename)
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Range statistics cleared"})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
+
+// PrefetchImageRanges reads the regions sanboot clients use of an ISO
+// into the page cache now, in a prefetch job, rather than when the next
+// client starts.
+func (h *Handler) PrefetchImageRanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	if h.Ranges == nil {
+		h.sendJSON(w, http.StatusServiceUnavailable, Response{Success: false, Error: "Range statistics are not available"})
+		return
+	}
+	image, err := h.storage.GetImage(r.URL.Query().Get("filename"))
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
+		return
+	}
+	filename := image.DiskFilename()
+	rep, ok := h.Ranges.Report(filename)
+	if !ok {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "No sanboot reads recorded for this image"})
+		return
+	}
+	path, err := h.Libraries.Join(filename)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	job := h.jobs.Start("prefetch", filename)
+	go func() {
+		job.Logf("Reading %d region(s), %d MB, of %s", len(rep.Regions), rep.Read>>20, filename)
+		n, err := rangestats.Prefetch(path, rep.Regions)
+		if err == nil {
+			job.Logf("Read %d MB", n>>20)
+		}
+		job.Finish(err)
+	}()
+	h.sendJSON(w, http.StatusAccepted, Response{
+		Success: true,
+		Message: fmt.Sprintf("Prefetching %s", image.Name),
+		Data:    map[string]uint64{"job_id": job.ID},
+	})
+}
@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bootimus/internal/models"
+	"bootimus/internal/remotemenu"
+)
+
+func (h *Handler) ListRemoteMenus(w http.ResponseWriter, r *http.Request) {
+	menus, err := h.storage.ListRemoteMenus()
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: menus})
+}
+
+// SaveRemoteMenu creates a remote menu, or updates the one with the given
+// id, and checks it can be reached.
+func (h *Handler) SaveRemoteMenu(w http.ResponseWriter, r *http.Request) {
+	req := models.RemoteMenu{Enabled: true} // unless the request says otherwise
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+	req.Name, req.URL = strings.TrimSpace(req.Name), strings.TrimSpace(req.URL)
+	if err := remotemenu.Validate(req.Name, req.URL); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
+	for i, mac := range req.ClientMACs {
+		mac = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(mac), "-", ":"))
+		if _, err := net.ParseMAC(mac); err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid MAC address: " + req.ClientMACs[i]})
+			return
+		}
+		req.ClientMACs[i] = mac
+	}
+	for _, name := range req.ClientGroups {
+		if _, err := h.storage.GetClientGroupByName(name); err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Client group not found: " + name})
+			return
+		}
+	}
+
+	m := &req
+	if id := r.URL.Query().Get("id"); id != "" {
+		n, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid remote menu ID"})
+			return
+		}
+		if m, err = h.storage.GetRemoteMenu(uint(n)); err != nil {
+			h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Remote menu not found"})
+			return
+		}
+		m.Name, m.URL, m.Enabled, m.Order = req.Name, req.URL, req.Enabled, req.Order
+		m.ClientGroups, m.ClientTags, m.ClientMACs = req.ClientGroups, req.ClientTags, req.ClientMACs
+		m.HideUnreachable = req.HideUnreachable
+	} else {
+		m.ID = 0
+	}
+
+	remotemenu.Record(m, remotemenu.Check(r.Context(), http.DefaultClient, m.URL))
+	if err := h.storage.SaveRemoteMenu(m); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	log.Printf("Admin: remote menu %q saved (%s)", m.Name, m.URL)
+	msg := "Remote menu saved"
+	if m.CheckError != "" {
+		msg += ", but the check failed: " + m.CheckError
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: msg, Data: m})
+}
+
+func (h *Handler) DeleteRemoteMenu(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid remote menu ID"})
+		return
+	}
+	if err := h.storage.DeleteRemoteMenu(uint(id)); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Remote menu deleted"})
+}
+
+// CheckRemoteMenu re-checks one remote menu now, rather than waiting for
+// the periodic check.
+func (h *Handler) CheckRemoteMenu(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid remote menu ID"})
+		return
+	}
+	m, err := h.storage.GetRemoteMenu(uint(id))
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Remote menu not found"})
+		return
+	}
+	remotemenu.Record(m, remotemenu.Check(r.Context(), http.DefaultClient, m.URL))
+	if err := h.storage.SaveRemoteMenu(m); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: m})
+}
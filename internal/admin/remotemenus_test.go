@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bootimus/internal/storage"
+)
+
+func TestSaveRemoteMenuKeepsEnabled(t *testing.T) {
+	store, err := storage.NewSQLiteStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{storage: store}
+
+	menu := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("#!ipxe\n"))
+	}))
+	defer menu.Close()
+
+	for _, tc := range []struct {
+		name, enabled string
+		want          bool
+	}{
+		{"disabled", `"enabled": false,`, false},
+		{"enabled", `"enabled": true,`, true},
+		{"unset", ``, true},
+	} {
+		body := `{"name": "` + tc.name + `", ` + tc.enabled + ` "url": "` + menu.URL + `/menu.ipxe"}`
+		rec := httptest.NewRecorder()
+		h.SaveRemoteMenu(rec, httptest.NewRequest(http.MethodPost, "/api/remote-menus", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", tc.name, rec.Code, rec.Body.String())
+		}
+	}
+
+	menus, err := store.ListRemoteMenus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"disabled": false, "enabled": true, "unset": true}
+	if len(menus) != len(want) {
+		t.Fatalf("got %d menus, want %d", len(menus), len(want))
+	}
+	for _, m := range menus {
+		if m.Enabled != want[m.Name] {
+			t.Errorf("%s: stored Enabled = %v, want %v", m.Name, m.Enabled, want[m.Name])
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"bootimus/internal/jobs"
+	"bootimus/internal/models"
+	"bootimus/internal/securepath"
+)
+
+// Replication pushes one image from this server to a peer over the peer's
+// admin API: the ISO, its extracted boot files, the image settings and its
+// auto-install file. Files go up in chunks into <path>.part on the peer,
+// which reports how much it already has so an interrupted push carries on
+// where it stopped, and only moves a file into place once its SHA-256
+// matches.
+
+const replicateChunk = 64 << 20
+
+type replicateRequest struct {
+	Peer     string `json:"peer"`  // admin URL of the other server, e.g. https://prod:8081
+	Token    string `json:"token"` // API token for the peer
+	Filename string `json:"filename"`
+}
+
+// replicaImage is what the peer receives after the files.
+type replicaImage struct {
+	Image           models.Image `json:"image"`
+	Group           string       `json:"group,omitempty"`
+	AutoInstallFile string       `json:"auto_install_file_content,omitempty"`
+	// The image leaves its generator parameters out of its JSON.
+	AutoInstallParams string `json:"auto_install_params,omitempty"`
+}
+
+// replicaFileState is the peer's answer to "how much of this file do you
+// have".
+type replicaFileState struct {
+	Complete bool  `json:"complete"`
+	Size     int64 `json:"size"` // bytes of the .part file
+}
+
+// Replicate starts a "replicate" job pushing an image to a peer.
+func (h *Handler) Replicate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	var req replicateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+	req.Peer = strings.TrimSuffix(strings.TrimSpace(req.Peer), "/")
+	if u, err := url.Parse(req.Peer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "peer must be the http(s) URL of the other server's admin interface"})
+		return
+	}
+	if req.Token == "" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "token is required"})
+		return
+	}
+	image, err := h.storage.GetImage(req.Filename)
+	if err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
+		return
+	}
+	if h.refuseVariant(w, image, "replicate") {
+		return
+	}
+	if !image.HasISOFile() {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Only ISO images can be replicated"})
+		return
+	}
+	if _, local := h.Libraries.Find(image.Filename); !local {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "The ISO is not on a local library"})
+		return
+	}
+
+	job := h.jobs.Start("replicate", image.Filename+" -> "+req.Peer)
+	go func() {
+		job.Finish(h.replicate(req, job))
+	}()
+	h.sendJSON(w, http.StatusAccepted, Response{
+		Success: true,
+		Message: fmt.Sprintf("Replicating %s to %s", image.Filename, req.Peer),
+		Data:    map[string]uint64{"job_id": job.ID},
+	})
+}
+
+func (h *Handler) replicate(req replicateRequest, job *jobs.Job) error {
+	image, err := h.storage.GetImage(req.Filename)
+	if err != nil {
+		return err
+	}
+	root := h.Libraries.Dir(image.Filename)
+	files := []string{image.Filename}
+	cacheDir := strings.TrimSuffix(image.Filename, filepath.Ext(image.Filename))
+	filepath.WalkDir(filepath.Join(root, cacheDir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if rel, err := filepath.Rel(root, p); err == nil {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+
+	peer := &replicaPeer{base: req.Peer, token: req.Token, filename: image.Filename}
+	var sent int64
+	for _, rel := range files {
+		local := filepath.Join(root, filepath.FromSlash(rel))
+		sum := ""
+		if rel == image.Filename && image.SHA256 != "" && image.IntegrityError == "" {
+			sum = image.SHA256
+		} else if sum, err = fileSHA256(local); err != nil {
+			return err
+		}
+		n, err := peer.push(local, rel, sum, job)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		sent += n
+	}
+	job.Logf("Sent %d files, %d MB", len(files), sent/(1024*1024))
+
+	replica := replicaImage{Image: *image, AutoInstallParams: image.AutoInstallParams}
+	if image.Group != nil {
+		replica.Group = image.Group.Name
+	}
+	if image.AutoInstallFile != "" && h.autoInstallLib != nil {
+		if content, err := h.autoInstallLib.ReadPath(image.AutoInstallFile); err == nil {
+			replica.AutoInstallFile = content
+		} else {
+			job.Logf("Warning: auto-install file %s not sent: %v", image.AutoInstallFile, err)
+		}
+	}
+	body, _ := json.Marshal(replica)
+	if _, err := peer.do(http.MethodPost, "/api/replicate/image", nil, bytes.NewReader(body)); err != nil {
+		return fmt.Errorf("image settings: %w", err)
+	}
+	job.Logf("Image settings applied on %s", req.Peer)
+	return nil
+}
+
+type replicaPeer struct {
+	base, token, filename string
+}
+
+func (p *replicaPeer) do(method, endpoint string, query url.Values, body io.Reader) ([]byte, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("filename", p.filename)
+	req, err := http.NewRequest(method, p.base+endpoint+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+	if method == http.MethodPut {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var res Response
+	raw, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, fmt.Errorf("peer answered %s", resp.Status)
+	}
+	if !res.Success {
+		return nil, fmt.Errorf("peer: %s", res.Error)
+	}
+	data, _ := json.Marshal(res.Data)
+	return data, nil
+}
+
+func (p *replicaPeer) state(rel, sum string) (replicaFileState, error) {
+	var st replicaFileState
+	data, err := p.do(http.MethodGet, "/api/replicate/file", url.Values{"path": {rel}, "sha256": {sum}}, nil)
+	if err == nil {
+		err = json.Unmarshal(data, &st)
+	}
+	return st, err
+}
+
+// push sends the part of local the peer does not have yet and commits it.
+// It returns the number of bytes sent.
+func (p *replicaPeer) push(local, rel, sum string, job *jobs.Job) (int64, error) {
+	st, err := p.state(rel, sum)
+	if err != nil {
+		return 0, err
+	}
+	if st.Complete {
+		job.Logf("%s already on the peer", rel)
+		return 0, nil
+	}
+	f, err := os.Open(local)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	offset := st.Size
+	if offset > info.Size() {
+		offset = 0 // a stale .part of some other file; the peer restarts it
+	}
+	if offset > 0 {
+		job.Logf("%s: resuming at %d MB", rel, offset/(1024*1024))
+	} else {
+		job.Logf("%s: sending %d MB", rel, info.Size()/(1024*1024))
+	}
+
+	var sent int64
+	retries := 0
+	// An empty file still needs one PUT so there is a .part to commit.
+	for first := true; first || offset < info.Size(); first = false {
+		n := min(int64(replicateChunk), info.Size()-offset)
+		q := url.Values{"path": {rel}, "offset": {strconv.FormatInt(offset, 10)}}
+		_, err := p.do(http.MethodPut, "/api/replicate/file", q, io.NewSectionReader(f, offset, n))
+		if err != nil {
+			if retries++; retries > 3 {
+				return sent, err
+			}
+			job.Logf("%s: %v; retrying", rel, err)
+			time.Sleep(time.Duration(retries) * 5 * time.Second)
+			if st, err = p.state(rel, sum); err != nil {
+				return sent, err
+			}
+			offset = st.Size
+			continue
+		}
+		retries = 0
+		offset += n
+		sent += n
+	}
+
+	if _, err := p.do(http.MethodPost, "/api/replicate/file", url.Values{"path": {rel}, "sha256": {sum}}, nil); err != nil {
+		return sent, err
+	}
+	return sent, nil
+}
+
+// replicaPath maps a path pushed for filename to where it goes on this
+// server: the ISO itself or a file in its extraction directory.
+func (h *Handler) replicaPath(filename, rel string) (string, error) {
+	rel = path.Clean(rel)
+	cacheDir := strings.TrimSuffix(filename, filepath.Ext(filename))
+	if filename == "" || (rel != filename && !strings.HasPrefix(rel, cacheDir+"/")) {
+		return "", fmt.Errorf("%s is not part of %s", rel, filename)
+	}
+	return securepath.Join(h.Libraries.Dir(filename), rel)
+}
+
+// ReplicateFile is the receiving end of a push. GET reports how much of a
+// file is here, PUT appends a chunk at ?offset= and POST checks the
+// finished file against ?sha256= and moves it into place.
+func (h *Handler) ReplicateFile(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	dest, err := h.replicaPath(q.Get("filename"), q.Get("path"))
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
+	part := dest + ".part"
+	partSize := int64(0)
+	if info, err := os.Stat(part); err == nil {
+		partSize = info.Size()
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		st := replicaFileState{Size: partSize}
+		if info, err := os.Stat(dest); err == nil {
+			sum := strings.ToLower(q.Get("sha256"))
+			if image, err := h.storage.GetImage(q.Get("filename")); err == nil && dest == filepath.Join(h.Libraries.Dir(image.Filename), image.Filename) &&
+				image.SHA256 != "" && image.SHA256 == sum && image.Size == info.Size() && image.IntegrityError == "" {
+				st.Complete = true
+			} else if got, err := fileSHA256(dest); err == nil && got == sum {
+				st.Complete = true
+			}
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: st})
+
+	case http.MethodPut:
+		offset, err := strconv.ParseInt(q.Get("offset"), 10, 64)
+		if err != nil || offset != partSize {
+			h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: fmt.Sprintf("expected offset %d", partSize), Data: replicaFileState{Size: partSize}})
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(part), 0755); err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		n, err := io.Copy(f, io.LimitReader(r.Body, replicateChunk))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			// Keep only what arrived whole, so the next offset is right.
+			os.Truncate(part, partSize)
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: replicaFileState{Size: partSize + n}})
+
+	case http.MethodPost:
+		want := strings.ToLower(q.Get("sha256"))
+		got, err := fileSHA256(part)
+		if err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+			return
+		}
+		if got != want {
+			os.Remove(part)
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: fmt.Sprintf("sha256 is %s, expected %s; the file will be sent again", got, want)})
+			return
+		}
+		if err := os.Rename(part, dest); err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Verified"})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
+
+// ReplicateImage creates or updates the image record for files pushed by
+// a peer. Settings that belong to this server (group ID, boot counts,
+// client assignments, OCI tracking) are kept or mapped.
+func (h *Handler) ReplicateImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	var req replicaImage
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+	src := req.Image
+	isoPath, err := h.replicaPath(src.Filename, src.Filename)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
+	if _, err := os.Stat(isoPath); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "The ISO has not been replicated yet"})
+		return
+	}
+
+	image, err := h.storage.GetImage(src.Filename)
+	if err != nil {
+		name := strings.TrimSuffix(src.Filename, filepath.Ext(src.Filename))
+		if err := h.storage.SyncImages([]models.SyncFile{{Name: name, Filename: src.Filename, Size: src.Size}}); err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		if image, err = h.storage.GetImage(src.Filename); err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+	}
+
+	image.Name = src.Name
+	image.Description = src.Description
+	image.Size = src.Size
+	image.SHA256, image.MD5 = src.SHA256, src.MD5
+	image.VerifiedAt = nil
+	image.IntegrityError = ""
+	image.VolumeLabel, image.Arch, image.ReleaseInfo = src.VolumeLabel, src.Arch, src.ReleaseInfo
+	image.ReleaseVersion, image.Variant = src.ReleaseVersion, src.Variant
+	image.Enabled, image.Public, image.Order = src.Enabled, src.Public, src.Order
+	image.Distro, image.BootMethod, image.BootParams = src.Distro, src.BootMethod, src.BootParams
+	image.Extracted, image.ExtractedAt, image.ExtractionError = src.Extracted, src.ExtractedAt, src.ExtractionError
+	image.KernelPath, image.InitrdPath, image.SquashfsPath = src.KernelPath, src.InitrdPath, src.SquashfsPath
+	image.KernelArches = src.KernelArches
+	image.LiteInitrdSize = src.LiteInitrdSize
+	image.InstallWimPath = src.InstallWimPath
+	image.SanbootCompatible, image.SanbootHint = src.SanbootCompatible, src.SanbootHint
+	image.NetbootRequired, image.NetbootAvailable, image.NetbootURL = src.NetbootRequired, src.NetbootAvailable, src.NetbootURL
+	image.NetbootChecksum, image.NetbootFetchedAt = src.NetbootChecksum, src.NetbootFetchedAt
+	image.AutoInstallScript, image.AutoInstallScriptType = src.AutoInstallScript, src.AutoInstallScriptType
+	image.AutoInstallEnabled, image.AutoInstallFile = src.AutoInstallEnabled, src.AutoInstallFile
+	image.AutoInstallGenerator, image.AutoInstallParams = src.AutoInstallGenerator, req.AutoInstallParams
+	image.RescueEnabled, image.RescueParams = src.RescueEnabled, src.RescueParams
+	image.PINProtected, image.FirstBootAgent = src.PINProtected, src.FirstBootAgent
+	image.VisibleFrom, image.VisibleUntil, image.VisibleWindows = src.VisibleFrom, src.VisibleUntil, src.VisibleWindows
+
+	image.GroupID, image.Group = nil, nil
+	if req.Group != "" {
+		group, err := h.storage.GetImageGroupByName(req.Group)
+		if err != nil {
+			group = &models.ImageGroup{Name: req.Group, Enabled: true}
+			if err := h.storage.CreateImageGroup(group); err != nil {
+				h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: fmt.Sprintf("Failed to create group %s: %v", req.Group, err)})
+				return
+			}
+		}
+		image.GroupID = &group.ID
+	}
+
+	if src.AutoInstallFile != "" && req.AutoInstallFile != "" && h.autoInstallLib != nil {
+		distro, name, _ := strings.Cut(src.AutoInstallFile, "/")
+		if err := h.autoInstallLib.Write(distro, name, req.AutoInstallFile); err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: fmt.Sprintf("auto-install file %s: %v", src.AutoInstallFile, err)})
+			return
+		}
+	}
+
+	before := *image
+	if err := h.storage.UpdateImage(image.Filename, image); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	h.recordRevision(revisionImage, image.Filename, revisionUpdate, before)
+	log.Printf("Replication: received %s from %s", image.Filename, r.RemoteAddr)
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Image replicated", Data: image})
+}
@@ -0,0 +1,262 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+const (
+	revisionImage  = "image"
+	revisionClient = "client"
+
+	revisionUpdate = "update"
+	revisionDelete = "delete"
+)
+
+// revisionFields are the fields an admin edits, by JSON name. Only these
+// are diffed and put back on undo; extraction results, boot counters and
+// attestation state move on their own and are left alone.
+var revisionFields = map[string][]string{
+	revisionImage: {"name", "description", "enabled", "public", "group_id", "order", "boot_method", "distro",
+		"boot_params", "auto_install_file", "auto_install_enabled", "rescue_enabled", "rescue_params",
+		"kernel_url", "initrd_url", "cache_remote"},
+	revisionClient: {"name", "description", "tags", "enabled", "show_public_images", "bootloader_set", "static",
+		"client_group_id", "ipmi_host", "ipmi_port", "ipmi_username", "ipmi_password", "ipmi_insecure",
+		"switch_name", "switch_port"},
+}
+
+type revisionChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type revisionEntry struct {
+	*models.Revision
+	Changes []revisionChange `json:"changes"`
+}
+
+// recordRevision keeps before as it was prior to an edit or delete. A
+// failure is logged rather than failing the edit.
+func (h *Handler) recordRevision(entityType, key, action string, before interface{}) {
+	if err := saveRevision(h.storage, entityType, key, action, before); err != nil {
+		log.Printf("Failed to record %s revision for %s: %v", entityType, key, err)
+	}
+}
+
+// saveRevision records before in store, for edits that save their revision
+// in the same transaction.
+func saveRevision(store storage.Storage, entityType, key, action string, before interface{}) error {
+	data, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	return store.CreateRevision(&models.Revision{EntityType: entityType, EntityKey: key, Action: action, Data: models.Secret(data)})
+}
+
+// Revisions lists the recorded changes to an image (?type=image&key=<filename>)
+// or client (?type=client&key=<mac>), newest first, each with the fields it
+// changed. Without a key it lists the most recent changes to anything.
+func (h *Handler) Revisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	entityType := r.URL.Query().Get("type")
+	key := r.URL.Query().Get("key")
+	if entityType != "" && entityType != revisionImage && entityType != revisionClient {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "type must be image or client"})
+		return
+	}
+
+	revs, err := h.storage.ListRevisions(entityType, key, 50)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	// Each revision is diffed against the state that replaced it: the next
+	// newer revision of the same record, or the record as it is now.
+	after := map[string]string{}
+	entries := make([]revisionEntry, 0, len(revs))
+	for _, rev := range revs {
+		id := rev.EntityType + "/" + rev.EntityKey
+		newer, seen := after[id]
+		if !seen {
+			newer = h.currentRevisionData(rev.EntityType, rev.EntityKey)
+		}
+		after[id] = string(rev.Data)
+		entries = append(entries, revisionEntry{Revision: rev, Changes: diffRevision(revisionFields[rev.EntityType], string(rev.Data), newer)})
+	}
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Data: entries})
+}
+
+// RevertRevision undoes the most recent change to an image or client and
+// drops its revision, so reverting again steps further back.
+func (h *Handler) RevertRevision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	entityType := r.URL.Query().Get("type")
+	key := r.URL.Query().Get("key")
+	if (entityType != revisionImage && entityType != revisionClient) || key == "" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "type (image or client) and key are required"})
+		return
+	}
+
+	revs, err := h.storage.ListRevisions(entityType, key, 1)
+	if err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+	if len(revs) == 0 {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "No changes to revert"})
+		return
+	}
+	rev := revs[0]
+
+	var status int
+	if entityType == revisionImage {
+		status, err = h.revertImage(rev)
+	} else {
+		status, err = h.revertClient(rev)
+	}
+	if err != nil {
+		h.sendJSON(w, status, Response{Success: false, Error: err.Error()})
+		return
+	}
+	if err := h.storage.DeleteRevision(rev.ID); err != nil {
+		log.Printf("Failed to drop reverted revision %d: %v", rev.ID, err)
+	}
+
+	log.Printf("Admin: Reverted %s of %s %s", rev.Action, entityType, key)
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: fmt.Sprintf("Reverted %s of %s %s", rev.Action, entityType, key)})
+}
+
+func (h *Handler) revertImage(rev *models.Revision) (int, error) {
+	var image models.Image
+	if err := json.Unmarshal([]byte(rev.Data), &image); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	image.Group = nil
+
+	h.libraryMu.Lock()
+	defer h.libraryMu.Unlock()
+
+	current, err := h.storage.GetImage(rev.EntityKey)
+	if rev.Action == revisionDelete {
+		if err == nil {
+			return http.StatusConflict, fmt.Errorf("an image named %s exists again", rev.EntityKey)
+		}
+		if image.HasISOFile() && !image.IsVirtual() {
+			if !h.Libraries.Exists(image.Filename) {
+				return http.StatusConflict, fmt.Errorf("the ISO file for %s is gone; upload it again instead", image.Filename)
+			}
+		}
+		image.ID = 0
+		image.DeletedAt = gorm.DeletedAt{}
+		if err := h.storage.CreateImage(&image); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return http.StatusOK, nil
+	}
+
+	if err != nil {
+		return http.StatusNotFound, errors.New("image no longer exists")
+	}
+	current.Name, current.Description = image.Name, image.Description
+	current.Enabled, current.Public = image.Enabled, image.Public
+	current.GroupID, current.Group, current.Order = image.GroupID, nil, image.Order
+	current.BootMethod, current.Distro, current.BootParams = image.BootMethod, image.Distro, image.BootParams
+	current.AutoInstallFile, current.AutoInstallEnabled = image.AutoInstallFile, image.AutoInstallEnabled
+	current.RescueEnabled, current.RescueParams = image.RescueEnabled, image.RescueParams
+	current.KernelURL, current.InitrdURL, current.CacheRemote = image.KernelURL, image.InitrdURL, image.CacheRemote
+	if err := h.storage.UpdateImage(rev.EntityKey, current); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (h *Handler) revertClient(rev *models.Revision) (int, error) {
+	var client models.Client
+	if err := json.Unmarshal([]byte(rev.Data), &client); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	client.ClientGroup = nil
+	client.Images = nil
+
+	_, err := h.storage.GetClient(rev.EntityKey)
+	if rev.Action == revisionDelete {
+		if err == nil {
+			return http.StatusConflict, fmt.Errorf("client %s has been added again", rev.EntityKey)
+		}
+		if err := h.storage.UndeleteClient(rev.EntityKey); errors.Is(err, gorm.ErrRecordNotFound) {
+			client.ID = 0
+			client.DeletedAt = gorm.DeletedAt{}
+			if err := h.storage.CreateClient(&client); err != nil {
+				return http.StatusInternalServerError, err
+			}
+			return http.StatusOK, nil
+		} else if err != nil {
+			return http.StatusInternalServerError, err
+		}
+	} else if err != nil {
+		return http.StatusNotFound, errors.New("client no longer exists")
+	}
+	if err := h.storage.UpdateClient(rev.EntityKey, &client); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// currentRevisionData is the record as it is now, in revision form, or ""
+// if it no longer exists.
+func (h *Handler) currentRevisionData(entityType, key string) string {
+	var current interface{}
+	var err error
+	if entityType == revisionImage {
+		current, err = h.storage.GetImage(key)
+	} else {
+		current, err = h.storage.GetClient(key)
+	}
+	if err != nil {
+		return ""
+	}
+	data, err := json.Marshal(current)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// diffRevision lists which of fields differ between two revision records.
+// An empty after means the record was deleted, so nothing is listed.
+func diffRevision(fields []string, before, after string) []revisionChange {
+	changes := []revisionChange{}
+	var from, to map[string]interface{}
+	if json.Unmarshal([]byte(before), &from) != nil || after == "" || json.Unmarshal([]byte(after), &to) != nil {
+		return changes
+	}
+	for _, k := range fields {
+		if reflect.DeepEqual(from[k], to[k]) {
+			continue
+		}
+		c := revisionChange{Field: k, From: from[k], To: to[k]}
+		if strings.Contains(k, "password") {
+			c.From, c.To = "(hidden)", "(hidden)"
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
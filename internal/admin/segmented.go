@@ -0,0 +1,224 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bootimus/internal/throttle"
+)
+
+const (
+	defaultDownloadConnections = 4
+	maxDownloadConnections     = 16
+	minSegmentSize             = 64 << 20
+	segmentRetries             = 3
+	downloadBufferSize         = 1 << 20
+)
+
+var isoDownloadClient = &http.Client{Timeout: 0}
+
+// fetchISO downloads url to destPath, splitting it into ranged segments
+// fetched in parallel when the server supports ranges and the file is large
+// enough to benefit. Otherwise it falls back to a single stream.
+func fetchISO(ctx context.Context, url, filename, destPath string, connections int, limits ...*throttle.Limiter) (int64, error) {
+	if connections > 1 {
+		if size, ok := probeRangeSupport(ctx, url); ok && size >= 2*minSegmentSize {
+			if n := int(size / minSegmentSize); n < connections {
+				connections = n
+			}
+			log.Printf("Downloading %s in %d segments (%d MB)", filename, connections, size/(1024*1024))
+			return size, downloadSegmented(ctx, url, filename, destPath, size, connections, limits)
+		}
+	}
+	return downloadSingleStream(ctx, url, filename, destPath, limits)
+}
+
+// probeRangeSupport asks for the first byte and reports the full size if the
+// server answered with a usable 206.
+func probeRangeSupport(ctx context.Context, url string) (int64, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, false
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := isoDownloadClient.Do(req)
+	if err != nil {
+		return 0, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, false
+	}
+	// Content-Range: bytes 0-0/<size>
+	cr := resp.Header.Get("Content-Range")
+	i := strings.LastIndex(cr, "/")
+	if !strings.HasPrefix(cr, "bytes ") || i < 0 {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(cr[i+1:], 10, 64)
+	if err != nil || size <= 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+func downloadSingleStream(ctx context.Context, url, filename, destPath string, limits []*throttle.Limiter) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := isoDownloadClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	downloadMgr.Add(transferDownload, url, filename, resp.ContentLength)
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	body := throttle.Reader(ctx, resp.Body, limits...)
+	buffer := make([]byte, downloadBufferSize)
+	var downloaded int64
+	for {
+		n, err := body.Read(buffer)
+		if n > 0 {
+			if _, err := out.Write(buffer[:n]); err != nil {
+				return downloaded, err
+			}
+			downloaded += int64(n)
+			downloadMgr.Update(transferDownload, filename, downloaded)
+		}
+		if err == io.EOF {
+			return downloaded, nil
+		}
+		if err != nil {
+			return downloaded, err
+		}
+	}
+}
+
+// downloadSegmented preallocates destPath and fills it from parallel range
+// requests. Each segment resumes from where it stopped on failure; the first
+// segment to exhaust its retries cancels the rest.
+func downloadSegmented(ctx context.Context, url, filename, destPath string, size int64, connections int, limits []*throttle.Limiter) error {
+	downloadMgr.Add(transferDownload, url, filename, size)
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := out.Truncate(size); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		total    atomic.Int64
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	segSize := (size + int64(connections) - 1) / int64(connections)
+	for start := int64(0); start < size; start += segSize {
+		end := start + segSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := fetchSegment(ctx, url, out, start, end, limits, func(n int64) {
+				downloadMgr.Update(transferDownload, filename, total.Add(n))
+			}); err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("segment %d-%d: %w", start, end, err)
+					cancel()
+				})
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func fetchSegment(ctx context.Context, url string, out *os.File, start, end int64, limits []*throttle.Limiter, progress func(int64)) error {
+	offset := start
+	var lastErr error
+	for attempt := 0; attempt <= segmentRetries; attempt++ {
+		if attempt > 0 {
+			log.Printf("Retrying segment %d-%d from %d (attempt %d): %v", start, end, offset, attempt+1, lastErr)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * 2 * time.Second):
+			}
+		}
+
+		lastErr = func() error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, end))
+			resp, err := isoDownloadClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusPartialContent {
+				return fmt.Errorf("expected 206, got HTTP %d", resp.StatusCode)
+			}
+
+			body := throttle.Reader(ctx, resp.Body, limits...)
+			buffer := make([]byte, downloadBufferSize)
+			for offset <= end {
+				n, err := body.Read(buffer)
+				if int64(n) > end-offset+1 {
+					n = int(end - offset + 1)
+				}
+				if n > 0 {
+					if _, werr := out.WriteAt(buffer[:n], offset); werr != nil {
+						return werr
+					}
+					offset += int64(n)
+					progress(int64(n))
+				}
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if offset <= end {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}()
+		if lastErr == nil || ctx.Err() != nil {
+			return lastErr
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,299 @@
+package admin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"bootimus/internal/bootmethod"
+	"bootimus/internal/imagespec"
+	"bootimus/internal/jobs"
+	"bootimus/internal/models"
+)
+
+// ImageSpecsDir holds spec files applied when ApplyImageSpecs gets no body.
+const ImageSpecsDir = "image-specs"
+
+// ApplyImageSpecs converges images on the posted specs (YAML or JSON), or on
+// the files in <data dir>/image-specs if the body is empty. Every spec runs
+// in its own "image-spec" job; the response lists them.
+func (h *Handler) ApplyImageSpecs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Failed to read request body"})
+		return
+	}
+
+	var specs []imagespec.Spec
+	if strings.TrimSpace(string(body)) != "" {
+		specs, err = imagespec.Parse(body)
+	} else {
+		specs, err = h.loadImageSpecs()
+	}
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+		return
+	}
+	if len(specs) == 0 {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "No image specs given"})
+		return
+	}
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		if seen[spec.Filename] {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: fmt.Sprintf("%s is specified more than once", spec.Filename)})
+			return
+		}
+		seen[spec.Filename] = true
+	}
+
+	type started struct {
+		Filename string `json:"filename"`
+		JobID    uint64 `json:"job_id"`
+	}
+	var list []started
+	for _, spec := range specs {
+		job := h.jobs.Start("image-spec", spec.Filename)
+		list = append(list, started{spec.Filename, job.ID})
+		go func(spec imagespec.Spec) {
+			job.Finish(h.applyImageSpec(spec, job))
+		}(spec)
+	}
+	h.sendJSON(w, http.StatusAccepted, Response{Success: true, Message: fmt.Sprintf("Applying %d image spec(s)", len(list)), Data: list})
+}
+
+func (h *Handler) loadImageSpecs() ([]imagespec.Spec, error) {
+	dir := filepath.Join(h.dataDir, ImageSpecsDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("no specs posted and %s cannot be read: %v", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	var specs []imagespec.Spec
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		s, err := imagespec.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		specs = append(specs, s...)
+	}
+	return specs, nil
+}
+
+// applyImageSpec runs download, verify, extract and configure for one spec,
+// skipping whatever already matches it.
+func (h *Handler) applyImageSpec(spec imagespec.Spec, job *jobs.Job) error {
+	filename := spec.Filename
+	image, err := h.storage.GetImage(filename)
+	if err != nil {
+		image = nil
+	}
+	if image != nil && !image.HasISOFile() {
+		return fmt.Errorf("%s is a virtual image or OCI bundle, not an ISO", filename)
+	}
+
+	fetch := !h.Libraries.Exists(filename)
+	if !fetch && spec.SHA256 != "" {
+		if _, local := h.Libraries.Find(filename); !local {
+			job.Logf("ISO is on a remote library; not verifying it")
+		} else if image == nil || image.SHA256 != spec.SHA256 {
+			path, err := h.Libraries.Join(filename)
+			if err != nil {
+				return err
+			}
+			job.Logf("Verifying %s", path)
+			sum, err := fileSHA256(path)
+			if err != nil {
+				return err
+			}
+			if sum != spec.SHA256 {
+				job.Logf("sha256 is %s, not %s; downloading again", sum, spec.SHA256)
+				fetch = true
+			}
+		}
+	}
+
+	var size int64
+	if fetch {
+		if size, err = h.fetchSpecISO(spec, job); err != nil {
+			return err
+		}
+	}
+
+	if image == nil {
+		if size == 0 {
+			if path, err := h.Libraries.Join(filename); err == nil {
+				if info, err := os.Stat(path); err == nil {
+					size = info.Size()
+				}
+			}
+		}
+		name := strings.TrimSuffix(filename, filepath.Ext(filename))
+		if err := h.storage.SyncImages([]models.SyncFile{{Name: name, Filename: filename, Size: size}}); err != nil {
+			return fmt.Errorf("Failed to add image: %v", err)
+		}
+		if image, err = h.storage.GetImage(filename); err != nil {
+			return fmt.Errorf("Failed to add image: %v", err)
+		}
+		job.Logf("Added image %s", filename)
+	}
+	if fetch {
+		image.Size = size
+		forgetChecksum(image)
+		h.detectAndSetDistro(image)
+		h.inspectISO(image)
+		h.applyRelease(image)
+	}
+	if spec.SHA256 != "" && image.SHA256 != spec.SHA256 {
+		now := time.Now()
+		image.SHA256, image.MD5 = spec.SHA256, ""
+		image.VerifiedAt = &now
+		image.IntegrityError = ""
+	}
+
+	if spec.Name != "" {
+		image.Name = spec.Name
+	}
+	if spec.Description != "" {
+		image.Description = spec.Description
+	}
+	if spec.Enabled != nil {
+		image.Enabled = *spec.Enabled
+	}
+	if spec.Public != nil {
+		image.Public = *spec.Public
+	}
+	if spec.Group != "" {
+		group, err := h.storage.GetImageGroupByName(spec.Group)
+		if err != nil {
+			group = &models.ImageGroup{Name: spec.Group, Enabled: true}
+			if err := h.storage.CreateImageGroup(group); err != nil {
+				return fmt.Errorf("Failed to create group %s: %v", spec.Group, err)
+			}
+			job.Logf("Created group %s", spec.Group)
+		}
+		image.GroupID = &group.ID
+		image.Group = nil
+	}
+	if spec.AutoInstallFile != "" {
+		if h.autoInstallLib == nil {
+			return fmt.Errorf("auto-install files are not available")
+		}
+		if _, err := h.autoInstallLib.ReadPath(spec.AutoInstallFile); err != nil {
+			return fmt.Errorf("auto-install file %s: %v", spec.AutoInstallFile, err)
+		}
+		image.AutoInstallFile = spec.AutoInstallFile
+		image.AutoInstallEnabled = true
+	}
+	if spec.AutoInstall != nil {
+		image.AutoInstallEnabled = *spec.AutoInstall
+	}
+	if err := h.storage.UpdateImage(filename, image); err != nil {
+		return err
+	}
+
+	if spec.Extract && (!image.Extracted || fetch) {
+		job.Logf("Extracting boot files")
+		if err := h.extractImage(h.background(), image, job); err != nil {
+			return err
+		}
+		if image.NetbootRequired && image.NetbootURL != "" {
+			job.Logf("ISO needs netboot files; fetching them in a separate job")
+			go h.autoInstallNetboot(filename)
+		}
+	}
+	changed := false
+	if p, ok := bootmethod.Get(spec.BootMethod); ok {
+		if err := p.Validate(image); err != nil {
+			return fmt.Errorf("boot method %s: %w", spec.BootMethod, err)
+		}
+	}
+	if spec.BootMethod != "" && image.BootMethod != spec.BootMethod {
+		image.BootMethod = spec.BootMethod
+		changed = true
+	}
+	if spec.BootParams != "" && image.BootParams != spec.BootParams {
+		image.BootParams = spec.BootParams
+		changed = true
+	}
+	if changed {
+		if err := h.storage.UpdateImage(filename, image); err != nil {
+			return err
+		}
+	}
+	job.Logf("%s matches its spec (boot method %s)", filename, image.BootMethod)
+	return nil
+}
+
+// fetchSpecISO downloads the spec's ISO beside the final path and moves it
+// into place only once its checksum is right, so a failed download never
+// replaces a working ISO.
+func (h *Handler) fetchSpecISO(spec imagespec.Spec, job *jobs.Job) (int64, error) {
+	dest, err := h.Libraries.Join(spec.Filename)
+	if err != nil {
+		return 0, err
+	}
+	tmp := dest + ".part"
+	job.Logf("Downloading %s", spec.URL)
+	downloadMgr.Add(transferDownload, spec.URL, spec.Filename, 0)
+	size, err := fetchISO(h.background(), spec.URL, spec.Filename, tmp, defaultDownloadConnections, h.Downloads.limits(0)...)
+	if err != nil {
+		os.Remove(tmp)
+		downloadMgr.Error(transferDownload, spec.Filename, err.Error())
+		return 0, fmt.Errorf("download failed: %v", err)
+	}
+	downloadMgr.Complete(transferDownload, spec.Filename)
+	if spec.SHA256 != "" {
+		sum, err := fileSHA256(tmp)
+		if err != nil {
+			os.Remove(tmp)
+			return 0, err
+		}
+		if sum != spec.SHA256 {
+			os.Remove(tmp)
+			return 0, fmt.Errorf("downloaded file has sha256 %s, spec expects %s", sum, spec.SHA256)
+		}
+		job.Logf("sha256 verified")
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	job.Logf("Downloaded %d MB", size/(1024*1024))
+	return size, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
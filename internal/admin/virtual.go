@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bootimus/internal/models"
+)
+
+const remoteImageExt = ".remote"
+
+type virtualImageRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Distro      string `json:"distro"`
+	KernelURL   string `json:"kernel_url"`
+	InitrdURL   string `json:"initrd_url"`
+	BootParams  string `json:"boot_params"`
+	CacheRemote bool   `json:"cache_remote"`
+	Public      *bool  `json:"public"`
+	GroupID     *uint  `json:"group_id"`
+}
+
+// checkRemoteURL accepts http(s) URLs that are safe to place on an iPXE
+// kernel or initrd line.
+func checkRemoteURL(field, raw string, required bool) error {
+	if raw == "" {
+		if required {
+			return fmt.Errorf("%s is required", field)
+		}
+		return nil
+	}
+	if strings.ContainsAny(raw, " \t\r\n") {
+		return fmt.Errorf("%s must not contain whitespace", field)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an http:// or https:// URL", field)
+	}
+	return nil
+}
+
+// CreateVirtualImage adds an image with no ISO behind it: the menu boots the
+// given kernel and initrd URLs directly, or through the server's cache.
+func (h *Handler) CreateVirtualImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+
+	var req virtualImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid request body"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "name is required"})
+		return
+	}
+	for _, err := range []error{
+		checkRemoteURL("kernel_url", req.KernelURL, true),
+		checkRemoteURL("initrd_url", req.InitrdURL, false),
+	} {
+		if err != nil {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: err.Error()})
+			return
+		}
+	}
+	if strings.ContainsAny(req.BootParams, "\r\n") {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "boot_params must be a single line"})
+		return
+	}
+
+	slug := strings.Trim(variantSlugRe.ReplaceAllString(strings.ToLower(req.Name), "-"), "-")
+	if slug == "" {
+		slug = "netboot"
+	}
+	filename := slug + remoteImageExt
+	for i := 2; ; i++ {
+		if _, err := h.storage.GetImage(filename); err != nil {
+			break
+		}
+		filename = fmt.Sprintf("%s-%d%s", slug, i, remoteImageExt)
+	}
+
+	image := &models.Image{
+		Name:        req.Name,
+		Filename:    filename,
+		Description: req.Description,
+		Enabled:     true,
+		Public:      req.Public == nil || *req.Public,
+		GroupID:     req.GroupID,
+		Distro:      req.Distro,
+		BootMethod:  "remote",
+		BootParams:  strings.TrimSpace(req.BootParams),
+		KernelURL:   req.KernelURL,
+		InitrdURL:   req.InitrdURL,
+		CacheRemote: req.CacheRemote,
+	}
+	if err := h.storage.CreateImage(image); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		return
+	}
+
+	log.Printf("Admin: Virtual image created - %s (%s)", image.Filename, image.KernelURL)
+	h.sendJSON(w, http.StatusCreated, Response{Success: true, Message: "Virtual image created", Data: image})
+}
+
+func (h *Handler) removeRemoteCache(filename string) {
+	dir := filepath.Join(h.dataDir, "remote-cache", strings.TrimSuffix(filename, filepath.Ext(filename)))
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("Failed to remove remote cache %s: %v", dir, err)
+	}
+}
@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"bootimus/internal/models"
+)
+
+// The first driver rebuild of an image keeps its pristine boot.wim beside
+// it as boot.wim.backup, and every later rebuild starts again from that.
+const wimBackupExt = ".backup"
+
+const wimBackupPurgeInterval = 24 * time.Hour
+
+type WimBackup struct {
+	ImageID   uint      `json:"image_id"`
+	Filename  string    `json:"filename"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (h *Handler) bootWimPath(image *models.Image) string {
+	imageName := strings.TrimSuffix(image.Filename, filepath.Ext(image.Filename))
+	return filepath.Join(h.Libraries.Dir(image.Filename), imageName, "iso", "sources", "boot.wim")
+}
+
+func (h *Handler) wimBackups() ([]WimBackup, error) {
+	images, err := h.storage.ListImages()
+	if err != nil {
+		return nil, err
+	}
+	backups := []WimBackup{}
+	for _, image := range images {
+		if !image.HasISOFile() || image.CloneOf != "" {
+			continue
+		}
+		path := h.bootWimPath(image) + wimBackupExt
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, WimBackup{
+			ImageID:   image.ID,
+			Filename:  image.Filename,
+			Path:      path,
+			Size:      info.Size(),
+			CreatedAt: info.ModTime(),
+		})
+	}
+	return backups, nil
+}
+
+// purgeWimBackups deletes the backups older than maxAge. The boot.wim with
+// drivers injected then becomes what later rebuilds start from.
+func (h *Handler) purgeWimBackups(maxAge time.Duration) ([]WimBackup, error) {
+	backups, err := h.wimBackups()
+	if err != nil {
+		return nil, err
+	}
+	purged := []WimBackup{}
+	for _, b := range backups {
+		if time.Since(b.CreatedAt) < maxAge {
+			continue
+		}
+		if err := os.Remove(b.Path); err != nil {
+			log.Printf("Failed to remove boot.wim backup %s: %v", b.Path, err)
+			continue
+		}
+		log.Printf("Removed boot.wim backup of %s (%d MB)", b.Filename, b.Size>>20)
+		purged = append(purged, b)
+	}
+	return purged, nil
+}
+
+// PurgeWimBackups removes boot.wim backups older than maxAge once a day.
+func (h *Handler) PurgeWimBackups(maxAge time.Duration) {
+	ticker := time.NewTicker(wimBackupPurgeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if h.jobs.Running("wim-rebuild") {
+			continue
+		}
+		if _, err := h.purgeWimBackups(maxAge); err != nil {
+			log.Printf("Failed to purge boot.wim backups: %v", err)
+		}
+	}
+}
+
+// WimBackups lists the boot.wim backups (GET) or deletes those older than
+// ?older_than_days= (DELETE).
+func (h *Handler) WimBackups(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		backups, err := h.wimBackups()
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Data: backups})
+
+	case http.MethodDelete:
+		days, err := strconv.Atoi(r.URL.Query().Get("older_than_days"))
+		if err != nil || days < 0 {
+			h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "older_than_days must be a number of days"})
+			return
+		}
+		if h.jobs.Running("wim-rebuild") {
+			h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "A boot.wim rebuild is running; try again when it finishes"})
+			return
+		}
+		purged, err := h.purgeWimBackups(time.Duration(days) * 24 * time.Hour)
+		if err != nil {
+			h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+			return
+		}
+		h.sendJSON(w, http.StatusOK, Response{Success: true, Message: fmt.Sprintf("Removed %d boot.wim backup(s)", len(purged)), Data: purged})
+
+	default:
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+	}
+}
+
+// RestoreWimBackup puts an image's pristine boot.wim back, undoing driver
+// injection, and removes the backup.
+func (h *Handler) RestoreWimBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendJSON(w, http.StatusMethodNotAllowed, Response{Success: false, Error: "Method not allowed"})
+		return
+	}
+	imageID, err := strconv.ParseUint(r.URL.Query().Get("imageId"), 10, 32)
+	if err != nil {
+		h.sendJSON(w, http.StatusBadRequest, Response{Success: false, Error: "Invalid image ID"})
+		return
+	}
+	if h.jobs.Running("wim-rebuild") {
+		h.sendJSON(w, http.StatusConflict, Response{Success: false, Error: "A boot.wim rebuild is running; try again when it finishes"})
+		return
+	}
+
+	var image *models.Image
+	if images, err := h.storage.ListImages(); err == nil {
+		for _, img := range images {
+			if img.ID == uint(imageID) {
+				image = img
+				break
+			}
+		}
+	}
+	if image == nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image not found"})
+		return
+	}
+
+	bootWim := h.bootWimPath(image)
+	backup := bootWim + wimBackupExt
+	if _, err := os.Stat(backup); err != nil {
+		h.sendJSON(w, http.StatusNotFound, Response{Success: false, Error: "Image has no boot.wim backup"})
+		return
+	}
+	if err := copyFile(backup, bootWim); err != nil {
+		h.sendJSON(w, http.StatusInternalServerError, Response{Success: false, Error: "Failed to restore boot.wim: " + err.Error()})
+		return
+	}
+	if err := os.Remove(backup); err != nil {
+		log.Printf("Failed to remove boot.wim backup %s: %v", backup, err)
+	}
+
+	if packs, err := h.storage.ListDriverPacksByImage(image.ID); err == nil {
+		for _, pack := range packs {
+			if pack.LastApplied == nil {
+				continue
+			}
+			pack.LastApplied = nil
+			if err := h.storage.UpdateDriverPack(pack.ID, pack); err != nil {
+				log.Printf("Failed to clear LastApplied of driver pack %d: %v", pack.ID, err)
+			}
+		}
+	}
+
+	log.Printf("Admin: Restored pristine boot.wim for %s", image.Filename)
+	h.sendJSON(w, http.StatusOK, Response{Success: true, Message: "Restored the original boot.wim"})
+}
@@ -4,18 +4,18 @@ import (
 	"archive/zip"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"bootimus/internal/jobs"
 	"bootimus/internal/models"
 	"bootimus/internal/wim"
 )
 
-func (h *Handler) RebuildBootWim(imageID uint) error {
+func (h *Handler) RebuildBootWim(job *jobs.Job, imageID uint) error {
 	var images []*models.Image
 	images, err := h.storage.ListImages()
 	if err != nil {
@@ -35,8 +35,8 @@ func (h *Handler) RebuildBootWim(imageID uint) error {
 	}
 
 	imageName := strings.TrimSuffix(image.Filename, filepath.Ext(image.Filename))
-	imageDir := filepath.Join(h.isoDir, imageName)
-	bootWimPath := filepath.Join(imageDir, "iso", "sources", "boot.wim")
+	imageDir := filepath.Join(h.Libraries.Dir(image.Filename), imageName)
+	bootWimPath := h.bootWimPath(image)
 
 	if _, err := os.Stat(bootWimPath); os.IsNotExist(err) {
 		return fmt.Errorf("boot.wim not found at %s", bootWimPath)
@@ -48,11 +48,11 @@ func (h *Handler) RebuildBootWim(imageID uint) error {
 	}
 
 	if len(driverPacks) == 0 {
-		log.Printf("No driver packs enabled for image %s, skipping rebuild", imageName)
+		job.Logf("No driver packs enabled for image %s, skipping rebuild", imageName)
 		return nil
 	}
 
-	log.Printf("Rebuilding boot.wim for %s with %d driver pack(s)", imageName, len(driverPacks))
+	job.Logf("Rebuilding boot.wim for %s with %d driver pack(s)", imageName, len(driverPacks))
 
 	tempDir, err := os.MkdirTemp("", "bootimus-wim-*")
 	if err != nil {
@@ -69,9 +69,9 @@ func (h *Handler) RebuildBootWim(imageID uint) error {
 		return fmt.Errorf("failed to create drivers directory: %w", err)
 	}
 
-	backupPath := bootWimPath + ".backup"
+	backupPath := bootWimPath + wimBackupExt
 	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
-		log.Printf("Creating backup of boot.wim at %s", backupPath)
+		job.Logf("Creating backup of boot.wim at %s", backupPath)
 		if err := copyFile(bootWimPath, backupPath); err != nil {
 			return fmt.Errorf("failed to backup boot.wim: %w", err)
 		}
@@ -81,16 +81,16 @@ func (h *Handler) RebuildBootWim(imageID uint) error {
 		}
 	}
 
-	log.Printf("Extracting driver packs...")
+	job.Logf("Extracting driver packs...")
 	for _, pack := range driverPacks {
 		zipPath := filepath.Join(imageDir, "drivers", pack.Filename)
-		log.Printf("  - Extracting %s", pack.Filename)
+		job.Logf("  - Extracting %s", pack.Filename)
 		if err := extractZipFile(zipPath, driversDir); err != nil {
 			return fmt.Errorf("failed to extract driver pack %s: %w", pack.Filename, err)
 		}
 	}
 
-	log.Printf("Listing WIM images...")
+	job.Logf("Listing WIM images...")
 
 	wimManager, err := wim.NewManager()
 	if err != nil {
@@ -102,16 +102,16 @@ func (h *Handler) RebuildBootWim(imageID uint) error {
 		return fmt.Errorf("failed to get WIM image count: %w", err)
 	}
 
-	log.Printf("Processing %d WIM image(s)", imageCount)
+	job.Logf("Processing %d WIM image(s)", imageCount)
 
 	for idx := 1; idx <= imageCount; idx++ {
-		log.Printf("Processing WIM image %d...", idx)
+		job.Logf("Processing WIM image %d...", idx)
 
-		log.Printf("  Updating image %d...", idx)
+		job.Logf("  Updating image %d...", idx)
 		extractCmd := exec.Command("wimupdate", bootWimPath, fmt.Sprintf("%d", idx))
 		extractCmd.Stdin = strings.NewReader(fmt.Sprintf("add \"%s\" \"/Windows/System32/DriverStore/FileRepository\"\n", driversDir))
 		if output, err := extractCmd.CombinedOutput(); err != nil {
-			log.Printf("wimupdate output: %s", string(output))
+			job.Logf("wimupdate output: %s", string(output))
 			return fmt.Errorf("failed to update WIM image %d: %w", idx, err)
 		}
 	}
@@ -120,11 +120,11 @@ func (h *Handler) RebuildBootWim(imageID uint) error {
 	for _, pack := range driverPacks {
 		pack.LastApplied = &now
 		if err := h.storage.UpdateDriverPack(pack.ID, pack); err != nil {
-			log.Printf("Warning: Failed to update driver pack %d LastApplied: %v", pack.ID, err)
+			job.Logf("Warning: Failed to update driver pack %d LastApplied: %v", pack.ID, err)
 		}
 	}
 
-	log.Printf("Successfully rebuilt boot.wim for %s", imageName)
+	job.Logf("Successfully rebuilt boot.wim for %s", imageName)
 	return nil
 }
 
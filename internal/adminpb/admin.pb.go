@@ -0,0 +1,2602 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v6.32.1
+// source: admin.proto
+
+package adminpb
+
+import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	fieldmaskpb "google.golang.org/protobuf/types/known/fieldmaskpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Image struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name            string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Filename        string                 `protobuf:"bytes,3,opt,name=filename,proto3" json:"filename,omitempty"`
+	Size            int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Enabled         bool                   `protobuf:"varint,5,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	Public          bool                   `protobuf:"varint,6,opt,name=public,proto3" json:"public,omitempty"`
+	Distro          string                 `protobuf:"bytes,7,opt,name=distro,proto3" json:"distro,omitempty"`
+	BootMethod      string                 `protobuf:"bytes,8,opt,name=boot_method,json=bootMethod,proto3" json:"boot_method,omitempty"`
+	Extracted       bool                   `protobuf:"varint,9,opt,name=extracted,proto3" json:"extracted,omitempty"`
+	ExtractionError string                 `protobuf:"bytes,10,opt,name=extraction_error,json=extractionError,proto3" json:"extraction_error,omitempty"`
+	Version         int32                  `protobuf:"varint,11,opt,name=version,proto3" json:"version,omitempty"`
+	Description     string                 `protobuf:"bytes,12,opt,name=description,proto3" json:"description,omitempty"`
+	GroupId         uint32                 `protobuf:"varint,13,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"` // 0 for none
+	Order           int32                  `protobuf:"varint,14,opt,name=order,proto3" json:"order,omitempty"`
+	BootParams      string                 `protobuf:"bytes,15,opt,name=boot_params,json=bootParams,proto3" json:"boot_params,omitempty"`
+	AutoInstallFile string                 `protobuf:"bytes,16,opt,name=auto_install_file,json=autoInstallFile,proto3" json:"auto_install_file,omitempty"`
+	RescueEnabled   bool                   `protobuf:"varint,17,opt,name=rescue_enabled,json=rescueEnabled,proto3" json:"rescue_enabled,omitempty"`
+	RescueParams    string                 `protobuf:"bytes,18,opt,name=rescue_params,json=rescueParams,proto3" json:"rescue_params,omitempty"`
+	PinProtected    bool                   `protobuf:"varint,19,opt,name=pin_protected,json=pinProtected,proto3" json:"pin_protected,omitempty"`
+	Sha256          string                 `protobuf:"bytes,20,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	Arch            string                 `protobuf:"bytes,21,opt,name=arch,proto3" json:"arch,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Image) Reset() {
+	*x = Image{}
+	mi := &file_admin_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Image) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Image) ProtoMessage() {}
+
+func (x *Image) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Image.ProtoReflect.Descriptor instead.
+func (*Image) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Image) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Image) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Image) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *Image) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Image) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *Image) GetPublic() bool {
+	if x != nil {
+		return x.Public
+	}
+	return false
+}
+
+func (x *Image) GetDistro() string {
+	if x != nil {
+		return x.Distro
+	}
+	return ""
+}
+
+func (x *Image) GetBootMethod() string {
+	if x != nil {
+		return x.BootMethod
+	}
+	return ""
+}
+
+func (x *Image) GetExtracted() bool {
+	if x != nil {
+		return x.Extracted
+	}
+	return false
+}
+
+func (x *Image) GetExtractionError() string {
+	if x != nil {
+		return x.ExtractionError
+	}
+	return ""
+}
+
+func (x *Image) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Image) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Image) GetGroupId() uint32 {
+	if x != nil {
+		return x.GroupId
+	}
+	return 0
+}
+
+func (x *Image) GetOrder() int32 {
+	if x != nil {
+		return x.Order
+	}
+	return 0
+}
+
+func (x *Image) GetBootParams() string {
+	if x != nil {
+		return x.BootParams
+	}
+	return ""
+}
+
+func (x *Image) GetAutoInstallFile() string {
+	if x != nil {
+		return x.AutoInstallFile
+	}
+	return ""
+}
+
+func (x *Image) GetRescueEnabled() bool {
+	if x != nil {
+		return x.RescueEnabled
+	}
+	return false
+}
+
+func (x *Image) GetRescueParams() string {
+	if x != nil {
+		return x.RescueParams
+	}
+	return ""
+}
+
+func (x *Image) GetPinProtected() bool {
+	if x != nil {
+		return x.PinProtected
+	}
+	return false
+}
+
+func (x *Image) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *Image) GetArch() string {
+	if x != nil {
+		return x.Arch
+	}
+	return ""
+}
+
+type ListImagesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListImagesRequest) Reset() {
+	*x = ListImagesRequest{}
+	mi := &file_admin_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListImagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListImagesRequest) ProtoMessage() {}
+
+func (x *ListImagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListImagesRequest.ProtoReflect.Descriptor instead.
+func (*ListImagesRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{1}
+}
+
+type ListImagesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Images        []*Image               `protobuf:"bytes,1,rep,name=images,proto3" json:"images,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListImagesResponse) Reset() {
+	*x = ListImagesResponse{}
+	mi := &file_admin_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListImagesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListImagesResponse) ProtoMessage() {}
+
+func (x *ListImagesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListImagesResponse.ProtoReflect.Descriptor instead.
+func (*ListImagesResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListImagesResponse) GetImages() []*Image {
+	if x != nil {
+		return x.Images
+	}
+	return nil
+}
+
+type ImageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImageRequest) Reset() {
+	*x = ImageRequest{}
+	mi := &file_admin_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageRequest) ProtoMessage() {}
+
+func (x *ImageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageRequest.ProtoReflect.Descriptor instead.
+func (*ImageRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ImageRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type UpdateImageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Image         *Image                 `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"` // image.filename names the image to update
+	UpdateMask    *fieldmaskpb.FieldMask `protobuf:"bytes,2,opt,name=update_mask,json=updateMask,proto3" json:"update_mask,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateImageRequest) Reset() {
+	*x = UpdateImageRequest{}
+	mi := &file_admin_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateImageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateImageRequest) ProtoMessage() {}
+
+func (x *UpdateImageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateImageRequest.ProtoReflect.Descriptor instead.
+func (*UpdateImageRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UpdateImageRequest) GetImage() *Image {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+func (x *UpdateImageRequest) GetUpdateMask() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.UpdateMask
+	}
+	return nil
+}
+
+type DeleteImageRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Filename string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	// Also remove the ISO and its extracted files, as ?delete_file=true does
+	// on the REST API.
+	DeleteFile    bool `protobuf:"varint,2,opt,name=delete_file,json=deleteFile,proto3" json:"delete_file,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteImageRequest) Reset() {
+	*x = DeleteImageRequest{}
+	mi := &file_admin_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteImageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteImageRequest) ProtoMessage() {}
+
+func (x *DeleteImageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteImageRequest.ProtoReflect.Descriptor instead.
+func (*DeleteImageRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteImageRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *DeleteImageRequest) GetDeleteFile() bool {
+	if x != nil {
+		return x.DeleteFile
+	}
+	return false
+}
+
+type ExtractImageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtractImageRequest) Reset() {
+	*x = ExtractImageRequest{}
+	mi := &file_admin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractImageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractImageRequest) ProtoMessage() {}
+
+func (x *ExtractImageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractImageRequest.ProtoReflect.Descriptor instead.
+func (*ExtractImageRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ExtractImageRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type Client struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Id               uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	MacAddress       string                 `protobuf:"bytes,2,opt,name=mac_address,json=macAddress,proto3" json:"mac_address,omitempty"`
+	Name             string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Description      string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	Tags             []string               `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty"`
+	Enabled          bool                   `protobuf:"varint,6,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	ShowPublicImages bool                   `protobuf:"varint,7,opt,name=show_public_images,json=showPublicImages,proto3" json:"show_public_images,omitempty"`
+	LiteInitrd       bool                   `protobuf:"varint,8,opt,name=lite_initrd,json=liteInitrd,proto3" json:"lite_initrd,omitempty"`
+	BootloaderSet    string                 `protobuf:"bytes,9,opt,name=bootloader_set,json=bootloaderSet,proto3" json:"bootloader_set,omitempty"`
+	AllowedImages    []string               `protobuf:"bytes,10,rep,name=allowed_images,json=allowedImages,proto3" json:"allowed_images,omitempty"`
+	NextBootImage    string                 `protobuf:"bytes,11,opt,name=next_boot_image,json=nextBootImage,proto3" json:"next_boot_image,omitempty"`
+	LocalBoot        bool                   `protobuf:"varint,12,opt,name=local_boot,json=localBoot,proto3" json:"local_boot,omitempty"`
+	DefaultImageId   uint32                 `protobuf:"varint,13,opt,name=default_image_id,json=defaultImageId,proto3" json:"default_image_id,omitempty"` // 0 for none
+	BootImmediately  bool                   `protobuf:"varint,14,opt,name=boot_immediately,json=bootImmediately,proto3" json:"boot_immediately,omitempty"`
+	Static           bool                   `protobuf:"varint,15,opt,name=static,proto3" json:"static,omitempty"`
+	ClientGroupId    uint32                 `protobuf:"varint,16,opt,name=client_group_id,json=clientGroupId,proto3" json:"client_group_id,omitempty"` // 0 for none
+	AutoInstallFile  string                 `protobuf:"bytes,17,opt,name=auto_install_file,json=autoInstallFile,proto3" json:"auto_install_file,omitempty"`
+	ReservedIp       string                 `protobuf:"bytes,18,opt,name=reserved_ip,json=reservedIp,proto3" json:"reserved_ip,omitempty"`
+	SwitchName       string                 `protobuf:"bytes,19,opt,name=switch_name,json=switchName,proto3" json:"switch_name,omitempty"`
+	SwitchPort       string                 `protobuf:"bytes,20,opt,name=switch_port,json=switchPort,proto3" json:"switch_port,omitempty"`
+	LastBoot         int64                  `protobuf:"varint,21,opt,name=last_boot,json=lastBoot,proto3" json:"last_boot,omitempty"` // Unix seconds, 0 if never
+	BootCount        int32                  `protobuf:"varint,22,opt,name=boot_count,json=bootCount,proto3" json:"boot_count,omitempty"`
+	Version          int32                  `protobuf:"varint,23,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Client) Reset() {
+	*x = Client{}
+	mi := &file_admin_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Client) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Client) ProtoMessage() {}
+
+func (x *Client) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Client.ProtoReflect.Descriptor instead.
+func (*Client) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Client) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Client) GetMacAddress() string {
+	if x != nil {
+		return x.MacAddress
+	}
+	return ""
+}
+
+func (x *Client) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Client) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Client) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Client) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *Client) GetShowPublicImages() bool {
+	if x != nil {
+		return x.ShowPublicImages
+	}
+	return false
+}
+
+func (x *Client) GetLiteInitrd() bool {
+	if x != nil {
+		return x.LiteInitrd
+	}
+	return false
+}
+
+func (x *Client) GetBootloaderSet() string {
+	if x != nil {
+		return x.BootloaderSet
+	}
+	return ""
+}
+
+func (x *Client) GetAllowedImages() []string {
+	if x != nil {
+		return x.AllowedImages
+	}
+	return nil
+}
+
+func (x *Client) GetNextBootImage() string {
+	if x != nil {
+		return x.NextBootImage
+	}
+	return ""
+}
+
+func (x *Client) GetLocalBoot() bool {
+	if x != nil {
+		return x.LocalBoot
+	}
+	return false
+}
+
+func (x *Client) GetDefaultImageId() uint32 {
+	if x != nil {
+		return x.DefaultImageId
+	}
+	return 0
+}
+
+func (x *Client) GetBootImmediately() bool {
+	if x != nil {
+		return x.BootImmediately
+	}
+	return false
+}
+
+func (x *Client) GetStatic() bool {
+	if x != nil {
+		return x.Static
+	}
+	return false
+}
+
+func (x *Client) GetClientGroupId() uint32 {
+	if x != nil {
+		return x.ClientGroupId
+	}
+	return 0
+}
+
+func (x *Client) GetAutoInstallFile() string {
+	if x != nil {
+		return x.AutoInstallFile
+	}
+	return ""
+}
+
+func (x *Client) GetReservedIp() string {
+	if x != nil {
+		return x.ReservedIp
+	}
+	return ""
+}
+
+func (x *Client) GetSwitchName() string {
+	if x != nil {
+		return x.SwitchName
+	}
+	return ""
+}
+
+func (x *Client) GetSwitchPort() string {
+	if x != nil {
+		return x.SwitchPort
+	}
+	return ""
+}
+
+func (x *Client) GetLastBoot() int64 {
+	if x != nil {
+		return x.LastBoot
+	}
+	return 0
+}
+
+func (x *Client) GetBootCount() int32 {
+	if x != nil {
+		return x.BootCount
+	}
+	return 0
+}
+
+func (x *Client) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type ListClientsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListClientsRequest) Reset() {
+	*x = ListClientsRequest{}
+	mi := &file_admin_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListClientsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListClientsRequest) ProtoMessage() {}
+
+func (x *ListClientsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListClientsRequest.ProtoReflect.Descriptor instead.
+func (*ListClientsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{8}
+}
+
+type ListClientsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Clients       []*Client              `protobuf:"bytes,1,rep,name=clients,proto3" json:"clients,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListClientsResponse) Reset() {
+	*x = ListClientsResponse{}
+	mi := &file_admin_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListClientsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListClientsResponse) ProtoMessage() {}
+
+func (x *ListClientsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListClientsResponse.ProtoReflect.Descriptor instead.
+func (*ListClientsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListClientsResponse) GetClients() []*Client {
+	if x != nil {
+		return x.Clients
+	}
+	return nil
+}
+
+type ClientRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MacAddress    string                 `protobuf:"bytes,1,opt,name=mac_address,json=macAddress,proto3" json:"mac_address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientRequest) Reset() {
+	*x = ClientRequest{}
+	mi := &file_admin_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientRequest) ProtoMessage() {}
+
+func (x *ClientRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientRequest.ProtoReflect.Descriptor instead.
+func (*ClientRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ClientRequest) GetMacAddress() string {
+	if x != nil {
+		return x.MacAddress
+	}
+	return ""
+}
+
+type CreateClientRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Client        *Client                `protobuf:"bytes,1,opt,name=client,proto3" json:"client,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateClientRequest) Reset() {
+	*x = CreateClientRequest{}
+	mi := &file_admin_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateClientRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateClientRequest) ProtoMessage() {}
+
+func (x *CreateClientRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateClientRequest.ProtoReflect.Descriptor instead.
+func (*CreateClientRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *CreateClientRequest) GetClient() *Client {
+	if x != nil {
+		return x.Client
+	}
+	return nil
+}
+
+type UpdateClientRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Client        *Client                `protobuf:"bytes,1,opt,name=client,proto3" json:"client,omitempty"` // client.mac_address names the client to update
+	UpdateMask    *fieldmaskpb.FieldMask `protobuf:"bytes,2,opt,name=update_mask,json=updateMask,proto3" json:"update_mask,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateClientRequest) Reset() {
+	*x = UpdateClientRequest{}
+	mi := &file_admin_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateClientRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateClientRequest) ProtoMessage() {}
+
+func (x *UpdateClientRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateClientRequest.ProtoReflect.Descriptor instead.
+func (*UpdateClientRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *UpdateClientRequest) GetClient() *Client {
+	if x != nil {
+		return x.Client
+	}
+	return nil
+}
+
+func (x *UpdateClientRequest) GetUpdateMask() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.UpdateMask
+	}
+	return nil
+}
+
+type ImageGroup struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	ParentId      uint32                 `protobuf:"varint,4,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"` // 0 for a top-level group
+	Order         int32                  `protobuf:"varint,5,opt,name=order,proto3" json:"order,omitempty"`
+	Enabled       bool                   `protobuf:"varint,6,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	PinProtected  bool                   `protobuf:"varint,7,opt,name=pin_protected,json=pinProtected,proto3" json:"pin_protected,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImageGroup) Reset() {
+	*x = ImageGroup{}
+	mi := &file_admin_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImageGroup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageGroup) ProtoMessage() {}
+
+func (x *ImageGroup) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageGroup.ProtoReflect.Descriptor instead.
+func (*ImageGroup) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ImageGroup) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ImageGroup) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ImageGroup) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *ImageGroup) GetParentId() uint32 {
+	if x != nil {
+		return x.ParentId
+	}
+	return 0
+}
+
+func (x *ImageGroup) GetOrder() int32 {
+	if x != nil {
+		return x.Order
+	}
+	return 0
+}
+
+func (x *ImageGroup) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *ImageGroup) GetPinProtected() bool {
+	if x != nil {
+		return x.PinProtected
+	}
+	return false
+}
+
+type ListImageGroupsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListImageGroupsRequest) Reset() {
+	*x = ListImageGroupsRequest{}
+	mi := &file_admin_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListImageGroupsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListImageGroupsRequest) ProtoMessage() {}
+
+func (x *ListImageGroupsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListImageGroupsRequest.ProtoReflect.Descriptor instead.
+func (*ListImageGroupsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{14}
+}
+
+type ListImageGroupsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Groups        []*ImageGroup          `protobuf:"bytes,1,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListImageGroupsResponse) Reset() {
+	*x = ListImageGroupsResponse{}
+	mi := &file_admin_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListImageGroupsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListImageGroupsResponse) ProtoMessage() {}
+
+func (x *ListImageGroupsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListImageGroupsResponse.ProtoReflect.Descriptor instead.
+func (*ListImageGroupsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ListImageGroupsResponse) GetGroups() []*ImageGroup {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+type GroupRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GroupRequest) Reset() {
+	*x = GroupRequest{}
+	mi := &file_admin_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupRequest) ProtoMessage() {}
+
+func (x *GroupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GroupRequest.ProtoReflect.Descriptor instead.
+func (*GroupRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *GroupRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type ClientGroup struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Id                 uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name               string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description        string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Enabled            bool                   `protobuf:"varint,4,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	AllowedImages      []string               `protobuf:"bytes,5,rep,name=allowed_images,json=allowedImages,proto3" json:"allowed_images,omitempty"`
+	BootloaderSet      string                 `protobuf:"bytes,6,opt,name=bootloader_set,json=bootloaderSet,proto3" json:"bootloader_set,omitempty"`
+	WolBroadcastAddr   string                 `protobuf:"bytes,7,opt,name=wol_broadcast_addr,json=wolBroadcastAddr,proto3" json:"wol_broadcast_addr,omitempty"`
+	StaggerDelayMillis int32                  `protobuf:"varint,8,opt,name=stagger_delay_millis,json=staggerDelayMillis,proto3" json:"stagger_delay_millis,omitempty"`
+	MemberCount        int32                  `protobuf:"varint,9,opt,name=member_count,json=memberCount,proto3" json:"member_count,omitempty"` // read only
+	Members            []*Client              `protobuf:"bytes,10,rep,name=members,proto3" json:"members,omitempty"`                            // read only, from GetClientGroup
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *ClientGroup) Reset() {
+	*x = ClientGroup{}
+	mi := &file_admin_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientGroup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientGroup) ProtoMessage() {}
+
+func (x *ClientGroup) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientGroup.ProtoReflect.Descriptor instead.
+func (*ClientGroup) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ClientGroup) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ClientGroup) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ClientGroup) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *ClientGroup) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *ClientGroup) GetAllowedImages() []string {
+	if x != nil {
+		return x.AllowedImages
+	}
+	return nil
+}
+
+func (x *ClientGroup) GetBootloaderSet() string {
+	if x != nil {
+		return x.BootloaderSet
+	}
+	return ""
+}
+
+func (x *ClientGroup) GetWolBroadcastAddr() string {
+	if x != nil {
+		return x.WolBroadcastAddr
+	}
+	return ""
+}
+
+func (x *ClientGroup) GetStaggerDelayMillis() int32 {
+	if x != nil {
+		return x.StaggerDelayMillis
+	}
+	return 0
+}
+
+func (x *ClientGroup) GetMemberCount() int32 {
+	if x != nil {
+		return x.MemberCount
+	}
+	return 0
+}
+
+func (x *ClientGroup) GetMembers() []*Client {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+type ListClientGroupsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListClientGroupsRequest) Reset() {
+	*x = ListClientGroupsRequest{}
+	mi := &file_admin_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListClientGroupsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListClientGroupsRequest) ProtoMessage() {}
+
+func (x *ListClientGroupsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListClientGroupsRequest.ProtoReflect.Descriptor instead.
+func (*ListClientGroupsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{18}
+}
+
+type ListClientGroupsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Groups        []*ClientGroup         `protobuf:"bytes,1,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListClientGroupsResponse) Reset() {
+	*x = ListClientGroupsResponse{}
+	mi := &file_admin_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListClientGroupsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListClientGroupsResponse) ProtoMessage() {}
+
+func (x *ListClientGroupsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListClientGroupsResponse.ProtoReflect.Descriptor instead.
+func (*ListClientGroupsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ListClientGroupsResponse) GetGroups() []*ClientGroup {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+type BootParamOverride struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	MacAddress    string                 `protobuf:"bytes,2,opt,name=mac_address,json=macAddress,proto3" json:"mac_address,omitempty"`
+	ImageFilename string                 `protobuf:"bytes,3,opt,name=image_filename,json=imageFilename,proto3" json:"image_filename,omitempty"` // empty for every image
+	Params        string                 `protobuf:"bytes,4,opt,name=params,proto3" json:"params,omitempty"`
+	Replace       bool                   `protobuf:"varint,5,opt,name=replace,proto3" json:"replace,omitempty"` // replace the image's params instead of appending
+	Once          bool                   `protobuf:"varint,6,opt,name=once,proto3" json:"once,omitempty"`       // read only; set by a one-off try
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BootParamOverride) Reset() {
+	*x = BootParamOverride{}
+	mi := &file_admin_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BootParamOverride) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BootParamOverride) ProtoMessage() {}
+
+func (x *BootParamOverride) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BootParamOverride.ProtoReflect.Descriptor instead.
+func (*BootParamOverride) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *BootParamOverride) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *BootParamOverride) GetMacAddress() string {
+	if x != nil {
+		return x.MacAddress
+	}
+	return ""
+}
+
+func (x *BootParamOverride) GetImageFilename() string {
+	if x != nil {
+		return x.ImageFilename
+	}
+	return ""
+}
+
+func (x *BootParamOverride) GetParams() string {
+	if x != nil {
+		return x.Params
+	}
+	return ""
+}
+
+func (x *BootParamOverride) GetReplace() bool {
+	if x != nil {
+		return x.Replace
+	}
+	return false
+}
+
+func (x *BootParamOverride) GetOnce() bool {
+	if x != nil {
+		return x.Once
+	}
+	return false
+}
+
+type ListBootParamsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MacAddress    string                 `protobuf:"bytes,1,opt,name=mac_address,json=macAddress,proto3" json:"mac_address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBootParamsRequest) Reset() {
+	*x = ListBootParamsRequest{}
+	mi := &file_admin_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBootParamsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBootParamsRequest) ProtoMessage() {}
+
+func (x *ListBootParamsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBootParamsRequest.ProtoReflect.Descriptor instead.
+func (*ListBootParamsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ListBootParamsRequest) GetMacAddress() string {
+	if x != nil {
+		return x.MacAddress
+	}
+	return ""
+}
+
+type ListBootParamsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Overrides     []*BootParamOverride   `protobuf:"bytes,1,rep,name=overrides,proto3" json:"overrides,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBootParamsResponse) Reset() {
+	*x = ListBootParamsResponse{}
+	mi := &file_admin_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBootParamsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBootParamsResponse) ProtoMessage() {}
+
+func (x *ListBootParamsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBootParamsResponse.ProtoReflect.Descriptor instead.
+func (*ListBootParamsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ListBootParamsResponse) GetOverrides() []*BootParamOverride {
+	if x != nil {
+		return x.Overrides
+	}
+	return nil
+}
+
+type BootParamsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BootParamsRequest) Reset() {
+	*x = BootParamsRequest{}
+	mi := &file_admin_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BootParamsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BootParamsRequest) ProtoMessage() {}
+
+func (x *BootParamsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BootParamsRequest.ProtoReflect.Descriptor instead.
+func (*BootParamsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *BootParamsRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type MenuSettingsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MenuSettingsRequest) Reset() {
+	*x = MenuSettingsRequest{}
+	mi := &file_admin_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MenuSettingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MenuSettingsRequest) ProtoMessage() {}
+
+func (x *MenuSettingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MenuSettingsRequest.ProtoReflect.Descriptor instead.
+func (*MenuSettingsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{24}
+}
+
+// MenuSettings is the boot menu's look, as /api/settings/menu.
+type MenuSettings struct {
+	state                    protoimpl.MessageState `protogen:"open.v1"`
+	Title                    string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	MenuTimeout              int32                  `protobuf:"varint,2,opt,name=menu_timeout,json=menuTimeout,proto3" json:"menu_timeout,omitempty"` // seconds, 0 waits forever
+	DefaultMenuItem          string                 `protobuf:"bytes,3,opt,name=default_menu_item,json=defaultMenuItem,proto3" json:"default_menu_item,omitempty"`
+	TextColour               string                 `protobuf:"bytes,4,opt,name=text_colour,json=textColour,proto3" json:"text_colour,omitempty"`
+	BackgroundColour         string                 `protobuf:"bytes,5,opt,name=background_colour,json=backgroundColour,proto3" json:"background_colour,omitempty"`
+	SelectedTextColour       string                 `protobuf:"bytes,6,opt,name=selected_text_colour,json=selectedTextColour,proto3" json:"selected_text_colour,omitempty"`
+	SelectedBackgroundColour string                 `protobuf:"bytes,7,opt,name=selected_background_colour,json=selectedBackgroundColour,proto3" json:"selected_background_colour,omitempty"`
+	Motd                     string                 `protobuf:"bytes,8,opt,name=motd,proto3" json:"motd,omitempty"`
+	unknownFields            protoimpl.UnknownFields
+	sizeCache                protoimpl.SizeCache
+}
+
+func (x *MenuSettings) Reset() {
+	*x = MenuSettings{}
+	mi := &file_admin_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MenuSettings) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MenuSettings) ProtoMessage() {}
+
+func (x *MenuSettings) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MenuSettings.ProtoReflect.Descriptor instead.
+func (*MenuSettings) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *MenuSettings) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *MenuSettings) GetMenuTimeout() int32 {
+	if x != nil {
+		return x.MenuTimeout
+	}
+	return 0
+}
+
+func (x *MenuSettings) GetDefaultMenuItem() string {
+	if x != nil {
+		return x.DefaultMenuItem
+	}
+	return ""
+}
+
+func (x *MenuSettings) GetTextColour() string {
+	if x != nil {
+		return x.TextColour
+	}
+	return ""
+}
+
+func (x *MenuSettings) GetBackgroundColour() string {
+	if x != nil {
+		return x.BackgroundColour
+	}
+	return ""
+}
+
+func (x *MenuSettings) GetSelectedTextColour() string {
+	if x != nil {
+		return x.SelectedTextColour
+	}
+	return ""
+}
+
+func (x *MenuSettings) GetSelectedBackgroundColour() string {
+	if x != nil {
+		return x.SelectedBackgroundColour
+	}
+	return ""
+}
+
+func (x *MenuSettings) GetMotd() string {
+	if x != nil {
+		return x.Motd
+	}
+	return ""
+}
+
+type Job struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Id     uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Kind   string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Target string                 `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"`
+	Params map[string]string      `protobuf:"bytes,4,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// running, done, failed, cancelled or interrupted.
+	Status        string `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	Error         string `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	StartedAt     int64  `protobuf:"varint,7,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`    // Unix seconds
+	FinishedAt    int64  `protobuf:"varint,8,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"` // Unix seconds, 0 while running
+	LogLines      int32  `protobuf:"varint,9,opt,name=log_lines,json=logLines,proto3" json:"log_lines,omitempty"`
+	Progress      int32  `protobuf:"varint,10,opt,name=progress,proto3" json:"progress,omitempty"` // percent, for jobs that can tell
+	Attempts      int32  `protobuf:"varint,11,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	RetryOf       uint64 `protobuf:"varint,12,opt,name=retry_of,json=retryOf,proto3" json:"retry_of,omitempty"`
+	Stage         string `protobuf:"bytes,13,opt,name=stage,proto3" json:"stage,omitempty"`
+	Files         int64  `protobuf:"varint,14,opt,name=files,proto3" json:"files,omitempty"`
+	Bytes         int64  `protobuf:"varint,15,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	TotalBytes    int64  `protobuf:"varint,16,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	Cancellable   bool   `protobuf:"varint,17,opt,name=cancellable,proto3" json:"cancellable,omitempty"`
+	Retryable     bool   `protobuf:"varint,18,opt,name=retryable,proto3" json:"retryable,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_admin_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *Job) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Job) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Job) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *Job) GetParams() map[string]string {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+func (x *Job) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *Job) GetFinishedAt() int64 {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return 0
+}
+
+func (x *Job) GetLogLines() int32 {
+	if x != nil {
+		return x.LogLines
+	}
+	return 0
+}
+
+func (x *Job) GetProgress() int32 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *Job) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *Job) GetRetryOf() uint64 {
+	if x != nil {
+		return x.RetryOf
+	}
+	return 0
+}
+
+func (x *Job) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *Job) GetFiles() int64 {
+	if x != nil {
+		return x.Files
+	}
+	return 0
+}
+
+func (x *Job) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *Job) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+func (x *Job) GetCancellable() bool {
+	if x != nil {
+		return x.Cancellable
+	}
+	return false
+}
+
+func (x *Job) GetRetryable() bool {
+	if x != nil {
+		return x.Retryable
+	}
+	return false
+}
+
+type ListJobsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Target        string                 `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsRequest) Reset() {
+	*x = ListJobsRequest{}
+	mi := &file_admin_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsRequest) ProtoMessage() {}
+
+func (x *ListJobsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsRequest.ProtoReflect.Descriptor instead.
+func (*ListJobsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *ListJobsRequest) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *ListJobsRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *ListJobsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type ListJobsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Jobs          []*Job                 `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsResponse) Reset() {
+	*x = ListJobsResponse{}
+	mi := &file_admin_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsResponse) ProtoMessage() {}
+
+func (x *ListJobsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsResponse.ProtoReflect.Descriptor instead.
+func (*ListJobsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *ListJobsResponse) GetJobs() []*Job {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+type JobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobRequest) Reset() {
+	*x = JobRequest{}
+	mi := &file_admin_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobRequest) ProtoMessage() {}
+
+func (x *JobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobRequest.ProtoReflect.Descriptor instead.
+func (*JobRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *JobRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type WatchJobRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Log lines already read, as ?since= on /api/jobs/{id}/log.
+	Since         int32 `protobuf:"varint,2,opt,name=since,proto3" json:"since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchJobRequest) Reset() {
+	*x = WatchJobRequest{}
+	mi := &file_admin_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchJobRequest) ProtoMessage() {}
+
+func (x *WatchJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchJobRequest.ProtoReflect.Descriptor instead.
+func (*WatchJobRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *WatchJobRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *WatchJobRequest) GetSince() int32 {
+	if x != nil {
+		return x.Since
+	}
+	return 0
+}
+
+type JobEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Job           *Job                   `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	Log           []string               `protobuf:"bytes,2,rep,name=log,proto3" json:"log,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobEvent) Reset() {
+	*x = JobEvent{}
+	mi := &file_admin_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobEvent) ProtoMessage() {}
+
+func (x *JobEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobEvent.ProtoReflect.Descriptor instead.
+func (*JobEvent) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *JobEvent) GetJob() *Job {
+	if x != nil {
+		return x.Job
+	}
+	return nil
+}
+
+func (x *JobEvent) GetLog() []string {
+	if x != nil {
+		return x.Log
+	}
+	return nil
+}
+
+type StreamLogsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamLogsRequest) Reset() {
+	*x = StreamLogsRequest{}
+	mi := &file_admin_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamLogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamLogsRequest) ProtoMessage() {}
+
+func (x *StreamLogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamLogsRequest.ProtoReflect.Descriptor instead.
+func (*StreamLogsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{32}
+}
+
+type LogLine struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogLine) Reset() {
+	*x = LogLine{}
+	mi := &file_admin_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogLine) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogLine) ProtoMessage() {}
+
+func (x *LogLine) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogLine.ProtoReflect.Descriptor instead.
+func (*LogLine) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *LogLine) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+var File_admin_proto protoreflect.FileDescriptor
+
+const file_admin_proto_rawDesc = "" +
+	"\n" +
+	"\vadmin.proto\x12\x11bootimus.admin.v1\x1a\x1cgoogle/api/annotations.proto\x1a\x1bgoogle/protobuf/empty.proto\x1a google/protobuf/field_mask.proto\"\xe6\x04\n" +
+	"\x05Image\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1a\n" +
+	"\bfilename\x18\x03 \x01(\tR\bfilename\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12\x18\n" +
+	"\aenabled\x18\x05 \x01(\bR\aenabled\x12\x16\n" +
+	"\x06public\x18\x06 \x01(\bR\x06public\x12\x16\n" +
+	"\x06distro\x18\a \x01(\tR\x06distro\x12\x1f\n" +
+	"\vboot_method\x18\b \x01(\tR\n" +
+	"bootMethod\x12\x1c\n" +
+	"\textracted\x18\t \x01(\bR\textracted\x12)\n" +
+	"\x10extraction_error\x18\n" +
+	" \x01(\tR\x0fextractionError\x12\x18\n" +
+	"\aversion\x18\v \x01(\x05R\aversion\x12 \n" +
+	"\vdescription\x18\f \x01(\tR\vdescription\x12\x19\n" +
+	"\bgroup_id\x18\r \x01(\rR\agroupId\x12\x14\n" +
+	"\x05order\x18\x0e \x01(\x05R\x05order\x12\x1f\n" +
+	"\vboot_params\x18\x0f \x01(\tR\n" +
+	"bootParams\x12*\n" +
+	"\x11auto_install_file\x18\x10 \x01(\tR\x0fautoInstallFile\x12%\n" +
+	"\x0erescue_enabled\x18\x11 \x01(\bR\rrescueEnabled\x12#\n" +
+	"\rrescue_params\x18\x12 \x01(\tR\frescueParams\x12#\n" +
+	"\rpin_protected\x18\x13 \x01(\bR\fpinProtected\x12\x16\n" +
+	"\x06sha256\x18\x14 \x01(\tR\x06sha256\x12\x12\n" +
+	"\x04arch\x18\x15 \x01(\tR\x04arch\"\x13\n" +
+	"\x11ListImagesRequest\"F\n" +
+	"\x12ListImagesResponse\x120\n" +
+	"\x06images\x18\x01 \x03(\v2\x18.bootimus.admin.v1.ImageR\x06images\"*\n" +
+	"\fImageRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\"\x81\x01\n" +
+	"\x12UpdateImageRequest\x12.\n" +
+	"\x05image\x18\x01 \x01(\v2\x18.bootimus.admin.v1.ImageR\x05image\x12;\n" +
+	"\vupdate_mask\x18\x02 \x01(\v2\x1a.google.protobuf.FieldMaskR\n" +
+	"updateMask\"Q\n" +
+	"\x12DeleteImageRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x1f\n" +
+	"\vdelete_file\x18\x02 \x01(\bR\n" +
+	"deleteFile\"1\n" +
+	"\x13ExtractImageRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\"\xfb\x05\n" +
+	"\x06Client\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12\x1f\n" +
+	"\vmac_address\x18\x02 \x01(\tR\n" +
+	"macAddress\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\x12\x12\n" +
+	"\x04tags\x18\x05 \x03(\tR\x04tags\x12\x18\n" +
+	"\aenabled\x18\x06 \x01(\bR\aenabled\x12,\n" +
+	"\x12show_public_images\x18\a \x01(\bR\x10showPublicImages\x12\x1f\n" +
+	"\vlite_initrd\x18\b \x01(\bR\n" +
+	"liteInitrd\x12%\n" +
+	"\x0ebootloader_set\x18\t \x01(\tR\rbootloaderSet\x12%\n" +
+	"\x0eallowed_images\x18\n" +
+	" \x03(\tR\rallowedImages\x12&\n" +
+	"\x0fnext_boot_image\x18\v \x01(\tR\rnextBootImage\x12\x1d\n" +
+	"\n" +
+	"local_boot\x18\f \x01(\bR\tlocalBoot\x12(\n" +
+	"\x10default_image_id\x18\r \x01(\rR\x0edefaultImageId\x12)\n" +
+	"\x10boot_immediately\x18\x0e \x01(\bR\x0fbootImmediately\x12\x16\n" +
+	"\x06static\x18\x0f \x01(\bR\x06static\x12&\n" +
+	"\x0fclient_group_id\x18\x10 \x01(\rR\rclientGroupId\x12*\n" +
+	"\x11auto_install_file\x18\x11 \x01(\tR\x0fautoInstallFile\x12\x1f\n" +
+	"\vreserved_ip\x18\x12 \x01(\tR\n" +
+	"reservedIp\x12\x1f\n" +
+	"\vswitch_name\x18\x13 \x01(\tR\n" +
+	"switchName\x12\x1f\n" +
+	"\vswitch_port\x18\x14 \x01(\tR\n" +
+	"switchPort\x12\x1b\n" +
+	"\tlast_boot\x18\x15 \x01(\x03R\blastBoot\x12\x1d\n" +
+	"\n" +
+	"boot_count\x18\x16 \x01(\x05R\tbootCount\x12\x18\n" +
+	"\aversion\x18\x17 \x01(\x05R\aversion\"\x14\n" +
+	"\x12ListClientsRequest\"J\n" +
+	"\x13ListClientsResponse\x123\n" +
+	"\aclients\x18\x01 \x03(\v2\x19.bootimus.admin.v1.ClientR\aclients\"0\n" +
+	"\rClientRequest\x12\x1f\n" +
+	"\vmac_address\x18\x01 \x01(\tR\n" +
+	"macAddress\"H\n" +
+	"\x13CreateClientRequest\x121\n" +
+	"\x06client\x18\x01 \x01(\v2\x19.bootimus.admin.v1.ClientR\x06client\"\x85\x01\n" +
+	"\x13UpdateClientRequest\x121\n" +
+	"\x06client\x18\x01 \x01(\v2\x19.bootimus.admin.v1.ClientR\x06client\x12;\n" +
+	"\vupdate_mask\x18\x02 \x01(\v2\x1a.google.protobuf.FieldMaskR\n" +
+	"updateMask\"\xc4\x01\n" +
+	"\n" +
+	"ImageGroup\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x1b\n" +
+	"\tparent_id\x18\x04 \x01(\rR\bparentId\x12\x14\n" +
+	"\x05order\x18\x05 \x01(\x05R\x05order\x12\x18\n" +
+	"\aenabled\x18\x06 \x01(\bR\aenabled\x12#\n" +
+	"\rpin_protected\x18\a \x01(\bR\fpinProtected\"\x18\n" +
+	"\x16ListImageGroupsRequest\"P\n" +
+	"\x17ListImageGroupsResponse\x125\n" +
+	"\x06groups\x18\x01 \x03(\v2\x1d.bootimus.admin.v1.ImageGroupR\x06groups\"\x1e\n" +
+	"\fGroupRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\"\xf3\x02\n" +
+	"\vClientGroup\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x18\n" +
+	"\aenabled\x18\x04 \x01(\bR\aenabled\x12%\n" +
+	"\x0eallowed_images\x18\x05 \x03(\tR\rallowedImages\x12%\n" +
+	"\x0ebootloader_set\x18\x06 \x01(\tR\rbootloaderSet\x12,\n" +
+	"\x12wol_broadcast_addr\x18\a \x01(\tR\x10wolBroadcastAddr\x120\n" +
+	"\x14stagger_delay_millis\x18\b \x01(\x05R\x12staggerDelayMillis\x12!\n" +
+	"\fmember_count\x18\t \x01(\x05R\vmemberCount\x123\n" +
+	"\amembers\x18\n" +
+	" \x03(\v2\x19.bootimus.admin.v1.ClientR\amembers\"\x19\n" +
+	"\x17ListClientGroupsRequest\"R\n" +
+	"\x18ListClientGroupsResponse\x126\n" +
+	"\x06groups\x18\x01 \x03(\v2\x1e.bootimus.admin.v1.ClientGroupR\x06groups\"\xb1\x01\n" +
+	"\x11BootParamOverride\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12\x1f\n" +
+	"\vmac_address\x18\x02 \x01(\tR\n" +
+	"macAddress\x12%\n" +
+	"\x0eimage_filename\x18\x03 \x01(\tR\rimageFilename\x12\x16\n" +
+	"\x06params\x18\x04 \x01(\tR\x06params\x12\x18\n" +
+	"\areplace\x18\x05 \x01(\bR\areplace\x12\x12\n" +
+	"\x04once\x18\x06 \x01(\bR\x04once\"8\n" +
+	"\x15ListBootParamsRequest\x12\x1f\n" +
+	"\vmac_address\x18\x01 \x01(\tR\n" +
+	"macAddress\"\\\n" +
+	"\x16ListBootParamsResponse\x12B\n" +
+	"\toverrides\x18\x01 \x03(\v2$.bootimus.admin.v1.BootParamOverrideR\toverrides\"#\n" +
+	"\x11BootParamsRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\"\x15\n" +
+	"\x13MenuSettingsRequest\"\xc5\x02\n" +
+	"\fMenuSettings\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\x12!\n" +
+	"\fmenu_timeout\x18\x02 \x01(\x05R\vmenuTimeout\x12*\n" +
+	"\x11default_menu_item\x18\x03 \x01(\tR\x0fdefaultMenuItem\x12\x1f\n" +
+	"\vtext_colour\x18\x04 \x01(\tR\n" +
+	"textColour\x12+\n" +
+	"\x11background_colour\x18\x05 \x01(\tR\x10backgroundColour\x120\n" +
+	"\x14selected_text_colour\x18\x06 \x01(\tR\x12selectedTextColour\x12<\n" +
+	"\x1aselected_background_colour\x18\a \x01(\tR\x18selectedBackgroundColour\x12\x12\n" +
+	"\x04motd\x18\b \x01(\tR\x04motd\"\xb9\x04\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\x16\n" +
+	"\x06target\x18\x03 \x01(\tR\x06target\x12:\n" +
+	"\x06params\x18\x04 \x03(\v2\".bootimus.admin.v1.Job.ParamsEntryR\x06params\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\a \x01(\x03R\tstartedAt\x12\x1f\n" +
+	"\vfinished_at\x18\b \x01(\x03R\n" +
+	"finishedAt\x12\x1b\n" +
+	"\tlog_lines\x18\t \x01(\x05R\blogLines\x12\x1a\n" +
+	"\bprogress\x18\n" +
+	" \x01(\x05R\bprogress\x12\x1a\n" +
+	"\battempts\x18\v \x01(\x05R\battempts\x12\x19\n" +
+	"\bretry_of\x18\f \x01(\x04R\aretryOf\x12\x14\n" +
+	"\x05stage\x18\r \x01(\tR\x05stage\x12\x14\n" +
+	"\x05files\x18\x0e \x01(\x03R\x05files\x12\x14\n" +
+	"\x05bytes\x18\x0f \x01(\x03R\x05bytes\x12\x1f\n" +
+	"\vtotal_bytes\x18\x10 \x01(\x03R\n" +
+	"totalBytes\x12 \n" +
+	"\vcancellable\x18\x11 \x01(\bR\vcancellable\x12\x1c\n" +
+	"\tretryable\x18\x12 \x01(\bR\tretryable\x1a9\n" +
+	"\vParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"U\n" +
+	"\x0fListJobsRequest\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x16\n" +
+	"\x06target\x18\x02 \x01(\tR\x06target\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\">\n" +
+	"\x10ListJobsResponse\x12*\n" +
+	"\x04jobs\x18\x01 \x03(\v2\x16.bootimus.admin.v1.JobR\x04jobs\"\x1c\n" +
+	"\n" +
+	"JobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\"7\n" +
+	"\x0fWatchJobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x14\n" +
+	"\x05since\x18\x02 \x01(\x05R\x05since\"F\n" +
+	"\bJobEvent\x12(\n" +
+	"\x03job\x18\x01 \x01(\v2\x16.bootimus.admin.v1.JobR\x03job\x12\x10\n" +
+	"\x03log\x18\x02 \x03(\tR\x03log\"\x13\n" +
+	"\x11StreamLogsRequest\"#\n" +
+	"\aLogLine\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage2\x9b\x1b\n" +
+	"\x05Admin\x12q\n" +
+	"\n" +
+	"ListImages\x12$.bootimus.admin.v1.ListImagesRequest\x1a%.bootimus.admin.v1.ListImagesResponse\"\x16\x82\xd3\xe4\x93\x02\x10\x12\x0e/api/v1/images\x12h\n" +
+	"\bGetImage\x12\x1f.bootimus.admin.v1.ImageRequest\x1a\x18.bootimus.admin.v1.Image\"!\x82\xd3\xe4\x93\x02\x1b\x12\x19/api/v1/images/{filename}\x12~\n" +
+	"\vUpdateImage\x12%.bootimus.admin.v1.UpdateImageRequest\x1a\x18.bootimus.admin.v1.Image\".\x82\xd3\xe4\x93\x02(:\x05image2\x1f/api/v1/images/{image.filename}\x12o\n" +
+	"\vDeleteImage\x12%.bootimus.admin.v1.DeleteImageRequest\x1a\x16.google.protobuf.Empty\"!\x82\xd3\xe4\x93\x02\x1b*\x19/api/v1/images/{filename}\x12|\n" +
+	"\fExtractImage\x12&.bootimus.admin.v1.ExtractImageRequest\x1a\x16.bootimus.admin.v1.Job\",\x82\xd3\xe4\x93\x02&:\x01*\"!/api/v1/images/{filename}/extract\x12u\n" +
+	"\vListClients\x12%.bootimus.admin.v1.ListClientsRequest\x1a&.bootimus.admin.v1.ListClientsResponse\"\x17\x82\xd3\xe4\x93\x02\x11\x12\x0f/api/v1/clients\x12o\n" +
+	"\tGetClient\x12 .bootimus.admin.v1.ClientRequest\x1a\x19.bootimus.admin.v1.Client\"%\x82\xd3\xe4\x93\x02\x1f\x12\x1d/api/v1/clients/{mac_address}\x12r\n" +
+	"\fCreateClient\x12&.bootimus.admin.v1.CreateClientRequest\x1a\x19.bootimus.admin.v1.Client\"\x1f\x82\xd3\xe4\x93\x02\x19:\x06client\"\x0f/api/v1/clients\x12\x87\x01\n" +
+	"\fUpdateClient\x12&.bootimus.admin.v1.UpdateClientRequest\x1a\x19.bootimus.admin.v1.Client\"4\x82\xd3\xe4\x93\x02.:\x06client2$/api/v1/clients/{client.mac_address}\x12o\n" +
+	"\fDeleteClient\x12 .bootimus.admin.v1.ClientRequest\x1a\x16.google.protobuf.Empty\"%\x82\xd3\xe4\x93\x02\x1f*\x1d/api/v1/clients/{mac_address}\x12\x86\x01\n" +
+	"\x0fListImageGroups\x12).bootimus.admin.v1.ListImageGroupsRequest\x1a*.bootimus.admin.v1.ListImageGroupsResponse\"\x1c\x82\xd3\xe4\x93\x02\x16\x12\x14/api/v1/image-groups\x12q\n" +
+	"\x10CreateImageGroup\x12\x1d.bootimus.admin.v1.ImageGroup\x1a\x1d.bootimus.admin.v1.ImageGroup\"\x1f\x82\xd3\xe4\x93\x02\x19:\x01*\"\x14/api/v1/image-groups\x12v\n" +
+	"\x10UpdateImageGroup\x12\x1d.bootimus.admin.v1.ImageGroup\x1a\x1d.bootimus.admin.v1.ImageGroup\"$\x82\xd3\xe4\x93\x02\x1e:\x01*\x1a\x19/api/v1/image-groups/{id}\x12n\n" +
+	"\x10DeleteImageGroup\x12\x1f.bootimus.admin.v1.GroupRequest\x1a\x16.google.protobuf.Empty\"!\x82\xd3\xe4\x93\x02\x1b*\x19/api/v1/image-groups/{id}\x12\x8a\x01\n" +
+	"\x10ListClientGroups\x12*.bootimus.admin.v1.ListClientGroupsRequest\x1a+.bootimus.admin.v1.ListClientGroupsResponse\"\x1d\x82\xd3\xe4\x93\x02\x17\x12\x15/api/v1/client-groups\x12u\n" +
+	"\x0eGetClientGroup\x12\x1f.bootimus.admin.v1.GroupRequest\x1a\x1e.bootimus.admin.v1.ClientGroup\"\"\x82\xd3\xe4\x93\x02\x1c\x12\x1a/api/v1/client-groups/{id}\x12u\n" +
+	"\x11CreateClientGroup\x12\x1e.bootimus.admin.v1.ClientGroup\x1a\x1e.bootimus.admin.v1.ClientGroup\" \x82\xd3\xe4\x93\x02\x1a:\x01*\"\x15/api/v1/client-groups\x12z\n" +
+	"\x11UpdateClientGroup\x12\x1e.bootimus.admin.v1.ClientGroup\x1a\x1e.bootimus.admin.v1.ClientGroup\"%\x82\xd3\xe4\x93\x02\x1f:\x01*\x1a\x1a/api/v1/client-groups/{id}\x12p\n" +
+	"\x11DeleteClientGroup\x12\x1f.bootimus.admin.v1.GroupRequest\x1a\x16.google.protobuf.Empty\"\"\x82\xd3\xe4\x93\x02\x1c*\x1a/api/v1/client-groups/{id}\x12\x82\x01\n" +
+	"\x0eListBootParams\x12(.bootimus.admin.v1.ListBootParamsRequest\x1a).bootimus.admin.v1.ListBootParamsResponse\"\x1b\x82\xd3\xe4\x93\x02\x15\x12\x13/api/v1/boot-params\x12|\n" +
+	"\x0eSaveBootParams\x12$.bootimus.admin.v1.BootParamOverride\x1a$.bootimus.admin.v1.BootParamOverride\"\x1e\x82\xd3\xe4\x93\x02\x18:\x01*\"\x13/api/v1/boot-params\x12r\n" +
+	"\x10DeleteBootParams\x12$.bootimus.admin.v1.BootParamsRequest\x1a\x16.google.protobuf.Empty\" \x82\xd3\xe4\x93\x02\x1a*\x18/api/v1/boot-params/{id}\x12y\n" +
+	"\x0fGetMenuSettings\x12&.bootimus.admin.v1.MenuSettingsRequest\x1a\x1f.bootimus.admin.v1.MenuSettings\"\x1d\x82\xd3\xe4\x93\x02\x17\x12\x15/api/v1/settings/menu\x12x\n" +
+	"\x12UpdateMenuSettings\x12\x1f.bootimus.admin.v1.MenuSettings\x1a\x1f.bootimus.admin.v1.MenuSettings\" \x82\xd3\xe4\x93\x02\x1a:\x01*\x1a\x15/api/v1/settings/menu\x12i\n" +
+	"\bListJobs\x12\".bootimus.admin.v1.ListJobsRequest\x1a#.bootimus.admin.v1.ListJobsResponse\"\x14\x82\xd3\xe4\x93\x02\x0e\x12\f/api/v1/jobs\x12Z\n" +
+	"\x06GetJob\x12\x1d.bootimus.admin.v1.JobRequest\x1a\x16.bootimus.admin.v1.Job\"\x19\x82\xd3\xe4\x93\x02\x13\x12\x11/api/v1/jobs/{id}\x12g\n" +
+	"\tCancelJob\x12\x1d.bootimus.admin.v1.JobRequest\x1a\x16.bootimus.admin.v1.Job\"#\x82\xd3\xe4\x93\x02\x1d:\x01*\"\x18/api/v1/jobs/{id}/cancel\x12e\n" +
+	"\bRetryJob\x12\x1d.bootimus.admin.v1.JobRequest\x1a\x16.bootimus.admin.v1.Job\"\"\x82\xd3\xe4\x93\x02\x1c:\x01*\"\x17/api/v1/jobs/{id}/retry\x12M\n" +
+	"\bWatchJob\x12\".bootimus.admin.v1.WatchJobRequest\x1a\x1b.bootimus.admin.v1.JobEvent0\x01\x12P\n" +
+	"\n" +
+	"StreamLogs\x12$.bootimus.admin.v1.StreamLogsRequest\x1a\x1a.bootimus.admin.v1.LogLine0\x01B\x1bZ\x19bootimus/internal/adminpbb\x06proto3"
+
+var (
+	file_admin_proto_rawDescOnce sync.Once
+	file_admin_proto_rawDescData []byte
+)
+
+func file_admin_proto_rawDescGZIP() []byte {
+	file_admin_proto_rawDescOnce.Do(func() {
+		file_admin_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_admin_proto_rawDesc), len(file_admin_proto_rawDesc)))
+	})
+	return file_admin_proto_rawDescData
+}
+
+var file_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 35)
+var file_admin_proto_goTypes = []any{
+	(*Image)(nil),                    // 0: bootimus.admin.v1.Image
+	(*ListImagesRequest)(nil),        // 1: bootimus.admin.v1.ListImagesRequest
+	(*ListImagesResponse)(nil),       // 2: bootimus.admin.v1.ListImagesResponse
+	(*ImageRequest)(nil),             // 3: bootimus.admin.v1.ImageRequest
+	(*UpdateImageRequest)(nil),       // 4: bootimus.admin.v1.UpdateImageRequest
+	(*DeleteImageRequest)(nil),       // 5: bootimus.admin.v1.DeleteImageRequest
+	(*ExtractImageRequest)(nil),      // 6: bootimus.admin.v1.ExtractImageRequest
+	(*Client)(nil),                   // 7: bootimus.admin.v1.Client
+	(*ListClientsRequest)(nil),       // 8: bootimus.admin.v1.ListClientsRequest
+	(*ListClientsResponse)(nil),      // 9: bootimus.admin.v1.ListClientsResponse
+	(*ClientRequest)(nil),            // 10: bootimus.admin.v1.ClientRequest
+	(*CreateClientRequest)(nil),      // 11: bootimus.admin.v1.CreateClientRequest
+	(*UpdateClientRequest)(nil),      // 12: bootimus.admin.v1.UpdateClientRequest
+	(*ImageGroup)(nil),               // 13: bootimus.admin.v1.ImageGroup
+	(*ListImageGroupsRequest)(nil),   // 14: bootimus.admin.v1.ListImageGroupsRequest
+	(*ListImageGroupsResponse)(nil),  // 15: bootimus.admin.v1.ListImageGroupsResponse
+	(*GroupRequest)(nil),             // 16: bootimus.admin.v1.GroupRequest
+	(*ClientGroup)(nil),              // 17: bootimus.admin.v1.ClientGroup
+	(*ListClientGroupsRequest)(nil),  // 18: bootimus.admin.v1.ListClientGroupsRequest
+	(*ListClientGroupsResponse)(nil), // 19: bootimus.admin.v1.ListClientGroupsResponse
+	(*BootParamOverride)(nil),        // 20: bootimus.admin.v1.BootParamOverride
+	(*ListBootParamsRequest)(nil),    // 21: bootimus.admin.v1.ListBootParamsRequest
+	(*ListBootParamsResponse)(nil),   // 22: bootimus.admin.v1.ListBootParamsResponse
+	(*BootParamsRequest)(nil),        // 23: bootimus.admin.v1.BootParamsRequest
+	(*MenuSettingsRequest)(nil),      // 24: bootimus.admin.v1.MenuSettingsRequest
+	(*MenuSettings)(nil),             // 25: bootimus.admin.v1.MenuSettings
+	(*Job)(nil),                      // 26: bootimus.admin.v1.Job
+	(*ListJobsRequest)(nil),          // 27: bootimus.admin.v1.ListJobsRequest
+	(*ListJobsResponse)(nil),         // 28: bootimus.admin.v1.ListJobsResponse
+	(*JobRequest)(nil),               // 29: bootimus.admin.v1.JobRequest
+	(*WatchJobRequest)(nil),          // 30: bootimus.admin.v1.WatchJobRequest
+	(*JobEvent)(nil),                 // 31: bootimus.admin.v1.JobEvent
+	(*StreamLogsRequest)(nil),        // 32: bootimus.admin.v1.StreamLogsRequest
+	(*LogLine)(nil),                  // 33: bootimus.admin.v1.LogLine
+	nil,                              // 34: bootimus.admin.v1.Job.ParamsEntry
+	(*fieldmaskpb.FieldMask)(nil),    // 35: google.protobuf.FieldMask
+	(*emptypb.Empty)(nil),            // 36: google.protobuf.Empty
+}
+var file_admin_proto_depIdxs = []int32{
+	0,  // 0: bootimus.admin.v1.ListImagesResponse.images:type_name -> bootimus.admin.v1.Image
+	0,  // 1: bootimus.admin.v1.UpdateImageRequest.image:type_name -> bootimus.admin.v1.Image
+	35, // 2: bootimus.admin.v1.UpdateImageRequest.update_mask:type_name -> google.protobuf.FieldMask
+	7,  // 3: bootimus.admin.v1.ListClientsResponse.clients:type_name -> bootimus.admin.v1.Client
+	7,  // 4: bootimus.admin.v1.CreateClientRequest.client:type_name -> bootimus.admin.v1.Client
+	7,  // 5: bootimus.admin.v1.UpdateClientRequest.client:type_name -> bootimus.admin.v1.Client
+	35, // 6: bootimus.admin.v1.UpdateClientRequest.update_mask:type_name -> google.protobuf.FieldMask
+	13, // 7: bootimus.admin.v1.ListImageGroupsResponse.groups:type_name -> bootimus.admin.v1.ImageGroup
+	7,  // 8: bootimus.admin.v1.ClientGroup.members:type_name -> bootimus.admin.v1.Client
+	17, // 9: bootimus.admin.v1.ListClientGroupsResponse.groups:type_name -> bootimus.admin.v1.ClientGroup
+	20, // 10: bootimus.admin.v1.ListBootParamsResponse.overrides:type_name -> bootimus.admin.v1.BootParamOverride
+	34, // 11: bootimus.admin.v1.Job.params:type_name -> bootimus.admin.v1.Job.ParamsEntry
+	26, // 12: bootimus.admin.v1.ListJobsResponse.jobs:type_name -> bootimus.admin.v1.Job
+	26, // 13: bootimus.admin.v1.JobEvent.job:type_name -> bootimus.admin.v1.Job
+	1,  // 14: bootimus.admin.v1.Admin.ListImages:input_type -> bootimus.admin.v1.ListImagesRequest
+	3,  // 15: bootimus.admin.v1.Admin.GetImage:input_type -> bootimus.admin.v1.ImageRequest
+	4,  // 16: bootimus.admin.v1.Admin.UpdateImage:input_type -> bootimus.admin.v1.UpdateImageRequest
+	5,  // 17: bootimus.admin.v1.Admin.DeleteImage:input_type -> bootimus.admin.v1.DeleteImageRequest
+	6,  // 18: bootimus.admin.v1.Admin.ExtractImage:input_type -> bootimus.admin.v1.ExtractImageRequest
+	8,  // 19: bootimus.admin.v1.Admin.ListClients:input_type -> bootimus.admin.v1.ListClientsRequest
+	10, // 20: bootimus.admin.v1.Admin.GetClient:input_type -> bootimus.admin.v1.ClientRequest
+	11, // 21: bootimus.admin.v1.Admin.CreateClient:input_type -> bootimus.admin.v1.CreateClientRequest
+	12, // 22: bootimus.admin.v1.Admin.UpdateClient:input_type -> bootimus.admin.v1.UpdateClientRequest
+	10, // 23: bootimus.admin.v1.Admin.DeleteClient:input_type -> bootimus.admin.v1.ClientRequest
+	14, // 24: bootimus.admin.v1.Admin.ListImageGroups:input_type -> bootimus.admin.v1.ListImageGroupsRequest
+	13, // 25: bootimus.admin.v1.Admin.CreateImageGroup:input_type -> bootimus.admin.v1.ImageGroup
+	13, // 26: bootimus.admin.v1.Admin.UpdateImageGroup:input_type -> bootimus.admin.v1.ImageGroup
+	16, // 27: bootimus.admin.v1.Admin.DeleteImageGroup:input_type -> bootimus.admin.v1.GroupRequest
+	18, // 28: bootimus.admin.v1.Admin.ListClientGroups:input_type -> bootimus.admin.v1.ListClientGroupsRequest
+	16, // 29: bootimus.admin.v1.Admin.GetClientGroup:input_type -> bootimus.admin.v1.GroupRequest
+	17, // 30: bootimus.admin.v1.Admin.CreateClientGroup:input_type -> bootimus.admin.v1.ClientGroup
+	17, // 31: bootimus.admin.v1.Admin.UpdateClientGroup:input_type -> bootimus.admin.v1.ClientGroup
+	16, // 32: bootimus.admin.v1.Admin.DeleteClientGroup:input_type -> bootimus.admin.v1.GroupRequest
+	21, // 33: bootimus.admin.v1.Admin.ListBootParams:input_type -> bootimus.admin.v1.ListBootParamsRequest
+	20, // 34: bootimus.admin.v1.Admin.SaveBootParams:input_type -> bootimus.admin.v1.BootParamOverride
+	23, // 35: bootimus.admin.v1.Admin.DeleteBootParams:input_type -> bootimus.admin.v1.BootParamsRequest
+	24, // 36: bootimus.admin.v1.Admin.GetMenuSettings:input_type -> bootimus.admin.v1.MenuSettingsRequest
+	25, // 37: bootimus.admin.v1.Admin.UpdateMenuSettings:input_type -> bootimus.admin.v1.MenuSettings
+	27, // 38: bootimus.admin.v1.Admin.ListJobs:input_type -> bootimus.admin.v1.ListJobsRequest
+	29, // 39: bootimus.admin.v1.Admin.GetJob:input_type -> bootimus.admin.v1.JobRequest
+	29, // 40: bootimus.admin.v1.Admin.CancelJob:input_type -> bootimus.admin.v1.JobRequest
+	29, // 41: bootimus.admin.v1.Admin.RetryJob:input_type -> bootimus.admin.v1.JobRequest
+	30, // 42: bootimus.admin.v1.Admin.WatchJob:input_type -> bootimus.admin.v1.WatchJobRequest
+	32, // 43: bootimus.admin.v1.Admin.StreamLogs:input_type -> bootimus.admin.v1.StreamLogsRequest
+	2,  // 44: bootimus.admin.v1.Admin.ListImages:output_type -> bootimus.admin.v1.ListImagesResponse
+	0,  // 45: bootimus.admin.v1.Admin.GetImage:output_type -> bootimus.admin.v1.Image
+	0,  // 46: bootimus.admin.v1.Admin.UpdateImage:output_type -> bootimus.admin.v1.Image
+	36, // 47: bootimus.admin.v1.Admin.DeleteImage:output_type -> google.protobuf.Empty
+	26, // 48: bootimus.admin.v1.Admin.ExtractImage:output_type -> bootimus.admin.v1.Job
+	9,  // 49: bootimus.admin.v1.Admin.ListClients:output_type -> bootimus.admin.v1.ListClientsResponse
+	7,  // 50: bootimus.admin.v1.Admin.GetClient:output_type -> bootimus.admin.v1.Client
+	7,  // 51: bootimus.admin.v1.Admin.CreateClient:output_type -> bootimus.admin.v1.Client
+	7,  // 52: bootimus.admin.v1.Admin.UpdateClient:output_type -> bootimus.admin.v1.Client
+	36, // 53: bootimus.admin.v1.Admin.DeleteClient:output_type -> google.protobuf.Empty
+	15, // 54: bootimus.admin.v1.Admin.ListImageGroups:output_type -> bootimus.admin.v1.ListImageGroupsResponse
+	13, // 55: bootimus.admin.v1.Admin.CreateImageGroup:output_type -> bootimus.admin.v1.ImageGroup
+	13, // 56: bootimus.admin.v1.Admin.UpdateImageGroup:output_type -> bootimus.admin.v1.ImageGroup
+	36, // 57: bootimus.admin.v1.Admin.DeleteImageGroup:output_type -> google.protobuf.Empty
+	19, // 58: bootimus.admin.v1.Admin.ListClientGroups:output_type -> bootimus.admin.v1.ListClientGroupsResponse
+	17, // 59: bootimus.admin.v1.Admin.GetClientGroup:output_type -> bootimus.admin.v1.ClientGroup
+	17, // 60: bootimus.admin.v1.Admin.CreateClientGroup:output_type -> bootimus.admin.v1.ClientGroup
+	17, // 61: bootimus.admin.v1.Admin.UpdateClientGroup:output_type -> bootimus.admin.v1.ClientGroup
+	36, // 62: bootimus.admin.v1.Admin.DeleteClientGroup:output_type -> google.protobuf.Empty
+	22, // 63: bootimus.admin.v1.Admin.ListBootParams:output_type -> bootimus.admin.v1.ListBootParamsResponse
+	20, // 64: bootimus.admin.v1.Admin.SaveBootParams:output_type -> bootimus.admin.v1.BootParamOverride
+	36, // 65: bootimus.admin.v1.Admin.DeleteBootParams:output_type -> google.protobuf.Empty
+	25, // 66: bootimus.admin.v1.Admin.GetMenuSettings:output_type -> bootimus.admin.v1.MenuSettings
+	25, // 67: bootimus.admin.v1.Admin.UpdateMenuSettings:output_type -> bootimus.admin.v1.MenuSettings
+	28, // 68: bootimus.admin.v1.Admin.ListJobs:output_type -> bootimus.admin.v1.ListJobsResponse
+	26, // 69: bootimus.admin.v1.Admin.GetJob:output_type -> bootimus.admin.v1.Job
+	26, // 70: bootimus.admin.v1.Admin.CancelJob:output_type -> bootimus.admin.v1.Job
+	26, // 71: bootimus.admin.v1.Admin.RetryJob:output_type -> bootimus.admin.v1.Job
+	31, // 72: bootimus.admin.v1.Admin.WatchJob:output_type -> bootimus.admin.v1.JobEvent
+	33, // 73: bootimus.admin.v1.Admin.StreamLogs:output_type -> bootimus.admin.v1.LogLine
+	44, // [44:74] is the sub-list for method output_type
+	14, // [14:44] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
+}
+
+func init() { file_admin_proto_init() }
+func file_admin_proto_init() {
+	if File_admin_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_admin_proto_rawDesc), len(file_admin_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   35,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_admin_proto_goTypes,
+		DependencyIndexes: file_admin_proto_depIdxs,
+		MessageInfos:      file_admin_proto_msgTypes,
+	}.Build()
+	File_admin_proto = out.File
+	file_admin_proto_goTypes = nil
+	file_admin_proto_depIdxs = nil
+}
@@ -0,0 +1,1273 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             v6.32.1
+// source: admin.proto
+
+package adminpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Admin_ListImages_FullMethodName         = "/bootimus.admin.v1.Admin/ListImages"
+	Admin_GetImage_FullMethodName           = "/bootimus.admin.v1.Admin/GetImage"
+	Admin_UpdateImage_FullMethodName        = "/bootimus.admin.v1.Admin/UpdateImage"
+	Admin_DeleteImage_FullMethodName        = "/bootimus.admin.v1.Admin/DeleteImage"
+	Admin_ExtractImage_FullMethodName       = "/bootimus.admin.v1.Admin/ExtractImage"
+	Admin_ListClients_FullMethodName        = "/bootimus.admin.v1.Admin/ListClients"
+	Admin_GetClient_FullMethodName          = "/bootimus.admin.v1.Admin/GetClient"
+	Admin_CreateClient_FullMethodName       = "/bootimus.admin.v1.Admin/CreateClient"
+	Admin_UpdateClient_FullMethodName       = "/bootimus.admin.v1.Admin/UpdateClient"
+	Admin_DeleteClient_FullMethodName       = "/bootimus.admin.v1.Admin/DeleteClient"
+	Admin_ListImageGroups_FullMethodName    = "/bootimus.admin.v1.Admin/ListImageGroups"
+	Admin_CreateImageGroup_FullMethodName   = "/bootimus.admin.v1.Admin/CreateImageGroup"
+	Admin_UpdateImageGroup_FullMethodName   = "/bootimus.admin.v1.Admin/UpdateImageGroup"
+	Admin_DeleteImageGroup_FullMethodName   = "/bootimus.admin.v1.Admin/DeleteImageGroup"
+	Admin_ListClientGroups_FullMethodName   = "/bootimus.admin.v1.Admin/ListClientGroups"
+	Admin_GetClientGroup_FullMethodName     = "/bootimus.admin.v1.Admin/GetClientGroup"
+	Admin_CreateClientGroup_FullMethodName  = "/bootimus.admin.v1.Admin/CreateClientGroup"
+	Admin_UpdateClientGroup_FullMethodName  = "/bootimus.admin.v1.Admin/UpdateClientGroup"
+	Admin_DeleteClientGroup_FullMethodName  = "/bootimus.admin.v1.Admin/DeleteClientGroup"
+	Admin_ListBootParams_FullMethodName     = "/bootimus.admin.v1.Admin/ListBootParams"
+	Admin_SaveBootParams_FullMethodName     = "/bootimus.admin.v1.Admin/SaveBootParams"
+	Admin_DeleteBootParams_FullMethodName   = "/bootimus.admin.v1.Admin/DeleteBootParams"
+	Admin_GetMenuSettings_FullMethodName    = "/bootimus.admin.v1.Admin/GetMenuSettings"
+	Admin_UpdateMenuSettings_FullMethodName = "/bootimus.admin.v1.Admin/UpdateMenuSettings"
+	Admin_ListJobs_FullMethodName           = "/bootimus.admin.v1.Admin/ListJobs"
+	Admin_GetJob_FullMethodName             = "/bootimus.admin.v1.Admin/GetJob"
+	Admin_CancelJob_FullMethodName          = "/bootimus.admin.v1.Admin/CancelJob"
+	Admin_RetryJob_FullMethodName           = "/bootimus.admin.v1.Admin/RetryJob"
+	Admin_WatchJob_FullMethodName           = "/bootimus.admin.v1.Admin/WatchJob"
+	Admin_StreamLogs_FullMethodName         = "/bootimus.admin.v1.Admin/StreamLogs"
+)
+
+// AdminClient is the client API for Admin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Admin is served on the admin port beside the REST API, with the same
+// authentication: a bearer token in the "authorization" metadata, or a
+// mapped client certificate. The unary RPCs are also served as JSON under
+// /api/v1/ by grpc-gateway, using the paths below.
+type AdminClient interface {
+	ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc.CallOption) (*ListImagesResponse, error)
+	GetImage(ctx context.Context, in *ImageRequest, opts ...grpc.CallOption) (*Image, error)
+	// UpdateImage changes the fields named in update_mask. Put version in the
+	// mask to have the update refused with ABORTED if the image changed since
+	// it was read.
+	UpdateImage(ctx context.Context, in *UpdateImageRequest, opts ...grpc.CallOption) (*Image, error)
+	DeleteImage(ctx context.Context, in *DeleteImageRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ExtractImage starts extracting an image's kernel and initrd; follow the
+	// returned job with WatchJob.
+	ExtractImage(ctx context.Context, in *ExtractImageRequest, opts ...grpc.CallOption) (*Job, error)
+	ListClients(ctx context.Context, in *ListClientsRequest, opts ...grpc.CallOption) (*ListClientsResponse, error)
+	GetClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*Client, error)
+	CreateClient(ctx context.Context, in *CreateClientRequest, opts ...grpc.CallOption) (*Client, error)
+	// UpdateClient changes the fields named in update_mask, as UpdateImage
+	// does. allowed_images replaces the client's image assignments.
+	UpdateClient(ctx context.Context, in *UpdateClientRequest, opts ...grpc.CallOption) (*Client, error)
+	DeleteClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListImageGroups(ctx context.Context, in *ListImageGroupsRequest, opts ...grpc.CallOption) (*ListImageGroupsResponse, error)
+	CreateImageGroup(ctx context.Context, in *ImageGroup, opts ...grpc.CallOption) (*ImageGroup, error)
+	UpdateImageGroup(ctx context.Context, in *ImageGroup, opts ...grpc.CallOption) (*ImageGroup, error)
+	DeleteImageGroup(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListClientGroups(ctx context.Context, in *ListClientGroupsRequest, opts ...grpc.CallOption) (*ListClientGroupsResponse, error)
+	// GetClientGroup includes the group's members.
+	GetClientGroup(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*ClientGroup, error)
+	CreateClientGroup(ctx context.Context, in *ClientGroup, opts ...grpc.CallOption) (*ClientGroup, error)
+	// UpdateClientGroup leaves the group's power-management credentials as
+	// they are.
+	UpdateClientGroup(ctx context.Context, in *ClientGroup, opts ...grpc.CallOption) (*ClientGroup, error)
+	DeleteClientGroup(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ListBootParams lists boot parameter overrides, for one client if
+	// mac_address is set.
+	ListBootParams(ctx context.Context, in *ListBootParamsRequest, opts ...grpc.CallOption) (*ListBootParamsResponse, error)
+	SaveBootParams(ctx context.Context, in *BootParamOverride, opts ...grpc.CallOption) (*BootParamOverride, error)
+	DeleteBootParams(ctx context.Context, in *BootParamsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetMenuSettings(ctx context.Context, in *MenuSettingsRequest, opts ...grpc.CallOption) (*MenuSettings, error)
+	UpdateMenuSettings(ctx context.Context, in *MenuSettings, opts ...grpc.CallOption) (*MenuSettings, error)
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	GetJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error)
+	CancelJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error)
+	RetryJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error)
+	// WatchJob sends the job whenever its status or progress changes, with the
+	// log lines added since, and ends once the job has finished.
+	WatchJob(ctx context.Context, in *WatchJobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JobEvent], error)
+	// StreamLogs sends server log lines as they are written, as
+	// /api/logs/stream does.
+	StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogLine], error)
+}
+
+type adminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminClient(cc grpc.ClientConnInterface) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc.CallOption) (*ListImagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListImagesResponse)
+	err := c.cc.Invoke(ctx, Admin_ListImages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetImage(ctx context.Context, in *ImageRequest, opts ...grpc.CallOption) (*Image, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Image)
+	err := c.cc.Invoke(ctx, Admin_GetImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) UpdateImage(ctx context.Context, in *UpdateImageRequest, opts ...grpc.CallOption) (*Image, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Image)
+	err := c.cc.Invoke(ctx, Admin_UpdateImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DeleteImage(ctx context.Context, in *DeleteImageRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Admin_DeleteImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ExtractImage(ctx context.Context, in *ExtractImageRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Admin_ExtractImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListClients(ctx context.Context, in *ListClientsRequest, opts ...grpc.CallOption) (*ListClientsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListClientsResponse)
+	err := c.cc.Invoke(ctx, Admin_ListClients_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*Client, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Client)
+	err := c.cc.Invoke(ctx, Admin_GetClient_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) CreateClient(ctx context.Context, in *CreateClientRequest, opts ...grpc.CallOption) (*Client, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Client)
+	err := c.cc.Invoke(ctx, Admin_CreateClient_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) UpdateClient(ctx context.Context, in *UpdateClientRequest, opts ...grpc.CallOption) (*Client, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Client)
+	err := c.cc.Invoke(ctx, Admin_UpdateClient_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DeleteClient(ctx context.Context, in *ClientRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Admin_DeleteClient_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListImageGroups(ctx context.Context, in *ListImageGroupsRequest, opts ...grpc.CallOption) (*ListImageGroupsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListImageGroupsResponse)
+	err := c.cc.Invoke(ctx, Admin_ListImageGroups_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) CreateImageGroup(ctx context.Context, in *ImageGroup, opts ...grpc.CallOption) (*ImageGroup, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImageGroup)
+	err := c.cc.Invoke(ctx, Admin_CreateImageGroup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) UpdateImageGroup(ctx context.Context, in *ImageGroup, opts ...grpc.CallOption) (*ImageGroup, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImageGroup)
+	err := c.cc.Invoke(ctx, Admin_UpdateImageGroup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DeleteImageGroup(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Admin_DeleteImageGroup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListClientGroups(ctx context.Context, in *ListClientGroupsRequest, opts ...grpc.CallOption) (*ListClientGroupsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListClientGroupsResponse)
+	err := c.cc.Invoke(ctx, Admin_ListClientGroups_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetClientGroup(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*ClientGroup, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClientGroup)
+	err := c.cc.Invoke(ctx, Admin_GetClientGroup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) CreateClientGroup(ctx context.Context, in *ClientGroup, opts ...grpc.CallOption) (*ClientGroup, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClientGroup)
+	err := c.cc.Invoke(ctx, Admin_CreateClientGroup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) UpdateClientGroup(ctx context.Context, in *ClientGroup, opts ...grpc.CallOption) (*ClientGroup, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClientGroup)
+	err := c.cc.Invoke(ctx, Admin_UpdateClientGroup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DeleteClientGroup(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Admin_DeleteClientGroup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListBootParams(ctx context.Context, in *ListBootParamsRequest, opts ...grpc.CallOption) (*ListBootParamsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListBootParamsResponse)
+	err := c.cc.Invoke(ctx, Admin_ListBootParams_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SaveBootParams(ctx context.Context, in *BootParamOverride, opts ...grpc.CallOption) (*BootParamOverride, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BootParamOverride)
+	err := c.cc.Invoke(ctx, Admin_SaveBootParams_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DeleteBootParams(ctx context.Context, in *BootParamsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Admin_DeleteBootParams_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetMenuSettings(ctx context.Context, in *MenuSettingsRequest, opts ...grpc.CallOption) (*MenuSettings, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MenuSettings)
+	err := c.cc.Invoke(ctx, Admin_GetMenuSettings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) UpdateMenuSettings(ctx context.Context, in *MenuSettings, opts ...grpc.CallOption) (*MenuSettings, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MenuSettings)
+	err := c.cc.Invoke(ctx, Admin_UpdateMenuSettings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, Admin_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Admin_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) CancelJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Admin_CancelJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RetryJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Admin_RetryJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) WatchJob(ctx context.Context, in *WatchJobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JobEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Admin_ServiceDesc.Streams[0], Admin_WatchJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchJobRequest, JobEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Admin_WatchJobClient = grpc.ServerStreamingClient[JobEvent]
+
+func (c *adminClient) StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogLine], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Admin_ServiceDesc.Streams[1], Admin_StreamLogs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamLogsRequest, LogLine]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Admin_StreamLogsClient = grpc.ServerStreamingClient[LogLine]
+
+// AdminServer is the server API for Admin service.
+// All implementations must embed UnimplementedAdminServer
+// for forward compatibility.
+//
+// Admin is served on the admin port beside the REST API, with the same
+// authentication: a bearer token in the "authorization" metadata, or a
+// mapped client certificate. The unary RPCs are also served as JSON under
+// /api/v1/ by grpc-gateway, using the paths below.
+type AdminServer interface {
+	ListImages(context.Context, *ListImagesRequest) (*ListImagesResponse, error)
+	GetImage(context.Context, *ImageRequest) (*Image, error)
+	// UpdateImage changes the fields named in update_mask. Put version in the
+	// mask to have the update refused with ABORTED if the image changed since
+	// it was read.
+	UpdateImage(context.Context, *UpdateImageRequest) (*Image, error)
+	DeleteImage(context.Context, *DeleteImageRequest) (*emptypb.Empty, error)
+	// ExtractImage starts extracting an image's kernel and initrd; follow the
+	// returned job with WatchJob.
+	ExtractImage(context.Context, *ExtractImageRequest) (*Job, error)
+	ListClients(context.Context, *ListClientsRequest) (*ListClientsResponse, error)
+	GetClient(context.Context, *ClientRequest) (*Client, error)
+	CreateClient(context.Context, *CreateClientRequest) (*Client, error)
+	// UpdateClient changes the fields named in update_mask, as UpdateImage
+	// does. allowed_images replaces the client's image assignments.
+	UpdateClient(context.Context, *UpdateClientRequest) (*Client, error)
+	DeleteClient(context.Context, *ClientRequest) (*emptypb.Empty, error)
+	ListImageGroups(context.Context, *ListImageGroupsRequest) (*ListImageGroupsResponse, error)
+	CreateImageGroup(context.Context, *ImageGroup) (*ImageGroup, error)
+	UpdateImageGroup(context.Context, *ImageGroup) (*ImageGroup, error)
+	DeleteImageGroup(context.Context, *GroupRequest) (*emptypb.Empty, error)
+	ListClientGroups(context.Context, *ListClientGroupsRequest) (*ListClientGroupsResponse, error)
+	// GetClientGroup includes the group's members.
+	GetClientGroup(context.Context, *GroupRequest) (*ClientGroup, error)
+	CreateClientGroup(context.Context, *ClientGroup) (*ClientGroup, error)
+	// UpdateClientGroup leaves the group's power-management credentials as
+	// they are.
+	UpdateClientGroup(context.Context, *ClientGroup) (*ClientGroup, error)
+	DeleteClientGroup(context.Context, *GroupRequest) (*emptypb.Empty, error)
+	// ListBootParams lists boot parameter overrides, for one client if
+	// mac_address is set.
+	ListBootParams(context.Context, *ListBootParamsRequest) (*ListBootParamsResponse, error)
+	SaveBootParams(context.Context, *BootParamOverride) (*BootParamOverride, error)
+	DeleteBootParams(context.Context, *BootParamsRequest) (*emptypb.Empty, error)
+	GetMenuSettings(context.Context, *MenuSettingsRequest) (*MenuSettings, error)
+	UpdateMenuSettings(context.Context, *MenuSettings) (*MenuSettings, error)
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
+	GetJob(context.Context, *JobRequest) (*Job, error)
+	CancelJob(context.Context, *JobRequest) (*Job, error)
+	RetryJob(context.Context, *JobRequest) (*Job, error)
+	// WatchJob sends the job whenever its status or progress changes, with the
+	// log lines added since, and ends once the job has finished.
+	WatchJob(*WatchJobRequest, grpc.ServerStreamingServer[JobEvent]) error
+	// StreamLogs sends server log lines as they are written, as
+	// /api/logs/stream does.
+	StreamLogs(*StreamLogsRequest, grpc.ServerStreamingServer[LogLine]) error
+	mustEmbedUnimplementedAdminServer()
+}
+
+// UnimplementedAdminServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAdminServer struct{}
+
+func (UnimplementedAdminServer) ListImages(context.Context, *ListImagesRequest) (*ListImagesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListImages not implemented")
+}
+func (UnimplementedAdminServer) GetImage(context.Context, *ImageRequest) (*Image, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetImage not implemented")
+}
+func (UnimplementedAdminServer) UpdateImage(context.Context, *UpdateImageRequest) (*Image, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdateImage not implemented")
+}
+func (UnimplementedAdminServer) DeleteImage(context.Context, *DeleteImageRequest) (*emptypb.Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteImage not implemented")
+}
+func (UnimplementedAdminServer) ExtractImage(context.Context, *ExtractImageRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method ExtractImage not implemented")
+}
+func (UnimplementedAdminServer) ListClients(context.Context, *ListClientsRequest) (*ListClientsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListClients not implemented")
+}
+func (UnimplementedAdminServer) GetClient(context.Context, *ClientRequest) (*Client, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetClient not implemented")
+}
+func (UnimplementedAdminServer) CreateClient(context.Context, *CreateClientRequest) (*Client, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateClient not implemented")
+}
+func (UnimplementedAdminServer) UpdateClient(context.Context, *UpdateClientRequest) (*Client, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdateClient not implemented")
+}
+func (UnimplementedAdminServer) DeleteClient(context.Context, *ClientRequest) (*emptypb.Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteClient not implemented")
+}
+func (UnimplementedAdminServer) ListImageGroups(context.Context, *ListImageGroupsRequest) (*ListImageGroupsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListImageGroups not implemented")
+}
+func (UnimplementedAdminServer) CreateImageGroup(context.Context, *ImageGroup) (*ImageGroup, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateImageGroup not implemented")
+}
+func (UnimplementedAdminServer) UpdateImageGroup(context.Context, *ImageGroup) (*ImageGroup, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdateImageGroup not implemented")
+}
+func (UnimplementedAdminServer) DeleteImageGroup(context.Context, *GroupRequest) (*emptypb.Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteImageGroup not implemented")
+}
+func (UnimplementedAdminServer) ListClientGroups(context.Context, *ListClientGroupsRequest) (*ListClientGroupsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListClientGroups not implemented")
+}
+func (UnimplementedAdminServer) GetClientGroup(context.Context, *GroupRequest) (*ClientGroup, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetClientGroup not implemented")
+}
+func (UnimplementedAdminServer) CreateClientGroup(context.Context, *ClientGroup) (*ClientGroup, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateClientGroup not implemented")
+}
+func (UnimplementedAdminServer) UpdateClientGroup(context.Context, *ClientGroup) (*ClientGroup, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdateClientGroup not implemented")
+}
+func (UnimplementedAdminServer) DeleteClientGroup(context.Context, *GroupRequest) (*emptypb.Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteClientGroup not implemented")
+}
+func (UnimplementedAdminServer) ListBootParams(context.Context, *ListBootParamsRequest) (*ListBootParamsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListBootParams not implemented")
+}
+func (UnimplementedAdminServer) SaveBootParams(context.Context, *BootParamOverride) (*BootParamOverride, error) {
+	return nil, status.Error(codes.Unimplemented, "method SaveBootParams not implemented")
+}
+func (UnimplementedAdminServer) DeleteBootParams(context.Context, *BootParamsRequest) (*emptypb.Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteBootParams not implemented")
+}
+func (UnimplementedAdminServer) GetMenuSettings(context.Context, *MenuSettingsRequest) (*MenuSettings, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetMenuSettings not implemented")
+}
+func (UnimplementedAdminServer) UpdateMenuSettings(context.Context, *MenuSettings) (*MenuSettings, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdateMenuSettings not implemented")
+}
+func (UnimplementedAdminServer) ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedAdminServer) GetJob(context.Context, *JobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedAdminServer) CancelJob(context.Context, *JobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method CancelJob not implemented")
+}
+func (UnimplementedAdminServer) RetryJob(context.Context, *JobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method RetryJob not implemented")
+}
+func (UnimplementedAdminServer) WatchJob(*WatchJobRequest, grpc.ServerStreamingServer[JobEvent]) error {
+	return status.Error(codes.Unimplemented, "method WatchJob not implemented")
+}
+func (UnimplementedAdminServer) StreamLogs(*StreamLogsRequest, grpc.ServerStreamingServer[LogLine]) error {
+	return status.Error(codes.Unimplemented, "method StreamLogs not implemented")
+}
+func (UnimplementedAdminServer) mustEmbedUnimplementedAdminServer() {}
+func (UnimplementedAdminServer) testEmbeddedByValue()               {}
+
+// UnsafeAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServer will
+// result in compilation errors.
+type UnsafeAdminServer interface {
+	mustEmbedUnimplementedAdminServer()
+}
+
+func RegisterAdminServer(s grpc.ServiceRegistrar, srv AdminServer) {
+	// If the following call panics, it indicates UnimplementedAdminServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Admin_ServiceDesc, srv)
+}
+
+func _Admin_ListImages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListImagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListImages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListImages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListImages(ctx, req.(*ListImagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_GetImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetImage(ctx, req.(*ImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_UpdateImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).UpdateImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_UpdateImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).UpdateImage(ctx, req.(*UpdateImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DeleteImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DeleteImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_DeleteImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DeleteImage(ctx, req.(*DeleteImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ExtractImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtractImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ExtractImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ExtractImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ExtractImage(ctx, req.(*ExtractImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListClients_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListClientsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListClients(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListClients_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListClients(ctx, req.(*ListClientsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_GetClient_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetClient(ctx, req.(*ClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_CreateClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).CreateClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_CreateClient_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).CreateClient(ctx, req.(*CreateClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_UpdateClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).UpdateClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_UpdateClient_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).UpdateClient(ctx, req.(*UpdateClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DeleteClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DeleteClient(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_DeleteClient_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DeleteClient(ctx, req.(*ClientRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListImageGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListImageGroupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListImageGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListImageGroups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListImageGroups(ctx, req.(*ListImageGroupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_CreateImageGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImageGroup)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).CreateImageGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_CreateImageGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).CreateImageGroup(ctx, req.(*ImageGroup))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_UpdateImageGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImageGroup)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).UpdateImageGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_UpdateImageGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).UpdateImageGroup(ctx, req.(*ImageGroup))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DeleteImageGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DeleteImageGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_DeleteImageGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DeleteImageGroup(ctx, req.(*GroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListClientGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListClientGroupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListClientGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListClientGroups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListClientGroups(ctx, req.(*ListClientGroupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetClientGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetClientGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_GetClientGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetClientGroup(ctx, req.(*GroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_CreateClientGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientGroup)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).CreateClientGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_CreateClientGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).CreateClientGroup(ctx, req.(*ClientGroup))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_UpdateClientGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientGroup)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).UpdateClientGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_UpdateClientGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).UpdateClientGroup(ctx, req.(*ClientGroup))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DeleteClientGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DeleteClientGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_DeleteClientGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DeleteClientGroup(ctx, req.(*GroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListBootParams_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBootParamsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListBootParams(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListBootParams_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListBootParams(ctx, req.(*ListBootParamsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SaveBootParams_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BootParamOverride)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SaveBootParams(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_SaveBootParams_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SaveBootParams(ctx, req.(*BootParamOverride))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DeleteBootParams_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BootParamsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DeleteBootParams(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_DeleteBootParams_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DeleteBootParams(ctx, req.(*BootParamsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetMenuSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MenuSettingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetMenuSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_GetMenuSettings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetMenuSettings(ctx, req.(*MenuSettingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_UpdateMenuSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MenuSettings)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).UpdateMenuSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_UpdateMenuSettings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).UpdateMenuSettings(ctx, req.(*MenuSettings))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetJob(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_CancelJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).CancelJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_CancelJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).CancelJob(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RetryJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RetryJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_RetryJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RetryJob(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_WatchJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchJobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AdminServer).WatchJob(m, &grpc.GenericServerStream[WatchJobRequest, JobEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Admin_WatchJobServer = grpc.ServerStreamingServer[JobEvent]
+
+func _Admin_StreamLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AdminServer).StreamLogs(m, &grpc.GenericServerStream[StreamLogsRequest, LogLine]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Admin_StreamLogsServer = grpc.ServerStreamingServer[LogLine]
+
+// Admin_ServiceDesc is the grpc.ServiceDesc for Admin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Admin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bootimus.admin.v1.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListImages",
+			Handler:    _Admin_ListImages_Handler,
+		},
+		{
+			MethodName: "GetImage",
+			Handler:    _Admin_GetImage_Handler,
+		},
+		{
+			MethodName: "UpdateImage",
+			Handler:    _Admin_UpdateImage_Handler,
+		},
+		{
+			MethodName: "DeleteImage",
+			Handler:    _Admin_DeleteImage_Handler,
+		},
+		{
+			MethodName: "ExtractImage",
+			Handler:    _Admin_ExtractImage_Handler,
+		},
+		{
+			MethodName: "ListClients",
+			Handler:    _Admin_ListClients_Handler,
+		},
+		{
+			MethodName: "GetClient",
+			Handler:    _Admin_GetClient_Handler,
+		},
+		{
+			MethodName: "CreateClient",
+			Handler:    _Admin_CreateClient_Handler,
+		},
+		{
+			MethodName: "UpdateClient",
+			Handler:    _Admin_UpdateClient_Handler,
+		},
+		{
+			MethodName: "DeleteClient",
+			Handler:    _Admin_DeleteClient_Handler,
+		},
+		{
+			MethodName: "ListImageGroups",
+			Handler:    _Admin_ListImageGroups_Handler,
+		},
+		{
+			MethodName: "CreateImageGroup",
+			Handler:    _Admin_CreateImageGroup_Handler,
+		},
+		{
+			MethodName: "UpdateImageGroup",
+			Handler:    _Admin_UpdateImageGroup_Handler,
+		},
+		{
+			MethodName: "DeleteImageGroup",
+			Handler:    _Admin_DeleteImageGroup_Handler,
+		},
+		{
+			MethodName: "ListClientGroups",
+			Handler:    _Admin_ListClientGroups_Handler,
+		},
+		{
+			MethodName: "GetClientGroup",
+			Handler:    _Admin_GetClientGroup_Handler,
+		},
+		{
+			MethodName: "CreateClientGroup",
+			Handler:    _Admin_CreateClientGroup_Handler,
+		},
+		{
+			MethodName: "UpdateClientGroup",
+			Handler:    _Admin_UpdateClientGroup_Handler,
+		},
+		{
+			MethodName: "DeleteClientGroup",
+			Handler:    _Admin_DeleteClientGroup_Handler,
+		},
+		{
+			MethodName: "ListBootParams",
+			Handler:    _Admin_ListBootParams_Handler,
+		},
+		{
+			MethodName: "SaveBootParams",
+			Handler:    _Admin_SaveBootParams_Handler,
+		},
+		{
+			MethodName: "DeleteBootParams",
+			Handler:    _Admin_DeleteBootParams_Handler,
+		},
+		{
+			MethodName: "GetMenuSettings",
+			Handler:    _Admin_GetMenuSettings_Handler,
+		},
+		{
+			MethodName: "UpdateMenuSettings",
+			Handler:    _Admin_UpdateMenuSettings_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _Admin_ListJobs_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _Admin_GetJob_Handler,
+		},
+		{
+			MethodName: "CancelJob",
+			Handler:    _Admin_CancelJob_Handler,
+		},
+		{
+			MethodName: "RetryJob",
+			Handler:    _Admin_RetryJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchJob",
+			Handler:       _Admin_WatchJob_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamLogs",
+			Handler:       _Admin_StreamLogs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "admin.proto",
+}
@@ -0,0 +1,6 @@
+// Package adminpb holds the gRPC admin API generated from admin.proto, and
+// its grpc-gateway translation to JSON over HTTP. google/api holds the
+// googleapis protos admin.proto's HTTP annotations need.
+package adminpb
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative --grpc-gateway_out=. --grpc-gateway_opt=paths=source_relative admin.proto
@@ -0,0 +1,203 @@
+// Package alerts watches boot activity for patterns worth a human's
+// attention: one machine failing over and over, a sudden wave of unknown
+// MACs (a guest VLAN accidentally scoped to PXE), or an image that fails
+// more often than it boots.
+package alerts
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	KindRepeatedFailures = "repeated_failures"
+	KindUnknownMACSpike  = "unknown_mac_spike"
+	KindImageFailureRate = "image_failure_rate"
+	KindISOIntegrity     = "iso_integrity" // raised by the admin ISO rehash, not the Detector
+)
+
+// Config holds the thresholds. Zero values take the defaults; a negative
+// count or rate turns that alert off.
+type Config struct {
+	FailedBoots       int           `mapstructure:"failed_boots"`
+	FailedBootsWindow time.Duration `mapstructure:"failed_boots_window"`
+
+	UnknownMACs       int           `mapstructure:"unknown_macs"`
+	UnknownMACsWindow time.Duration `mapstructure:"unknown_macs_window"`
+
+	ImageFailureRate float64       `mapstructure:"image_failure_rate"` // percent
+	ImageMinBoots    int           `mapstructure:"image_min_boots"`
+	ImageWindow      time.Duration `mapstructure:"image_window"`
+
+	// Cooldown stops the same alert firing again while it is still true.
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.FailedBoots == 0 {
+		c.FailedBoots = 5
+	}
+	if c.FailedBootsWindow <= 0 {
+		c.FailedBootsWindow = 10 * time.Minute
+	}
+	if c.UnknownMACs == 0 {
+		c.UnknownMACs = 20
+	}
+	if c.UnknownMACsWindow <= 0 {
+		c.UnknownMACsWindow = 5 * time.Minute
+	}
+	if c.ImageFailureRate == 0 {
+		c.ImageFailureRate = 50
+	}
+	if c.ImageMinBoots <= 0 {
+		c.ImageMinBoots = 10
+	}
+	if c.ImageWindow <= 0 {
+		c.ImageWindow = time.Hour
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = 30 * time.Minute
+	}
+	return c
+}
+
+type Alert struct {
+	Kind    string
+	MAC     string
+	Image   string
+	Count   int
+	Total   int
+	Window  time.Duration
+	Message string
+}
+
+type Detector struct {
+	cfg    Config
+	notify func(Alert)
+	now    func() time.Time
+
+	mu         sync.Mutex
+	failures   map[string][]time.Time // by MAC
+	unknown    []time.Time
+	imageBoots map[string][]time.Time
+	imageFails map[string][]time.Time
+	lastFired  map[string]time.Time
+}
+
+// New returns a Detector that calls notify for every alert it raises.
+func New(cfg Config, notify func(Alert)) *Detector {
+	return &Detector{
+		cfg:        cfg.withDefaults(),
+		notify:     notify,
+		now:        time.Now,
+		failures:   make(map[string][]time.Time),
+		imageBoots: make(map[string][]time.Time),
+		imageFails: make(map[string][]time.Time),
+		lastFired:  make(map[string]time.Time),
+	}
+}
+
+// BootStarted counts a boot of image towards its failure rate.
+func (d *Detector) BootStarted(image string) {
+	if d == nil || image == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	d.imageBoots[image] = append(prune(d.imageBoots[image], now, d.cfg.ImageWindow), now)
+}
+
+// BootFailed records a failed boot or install of image by mac.
+func (d *Detector) BootFailed(mac, image string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	now := d.now()
+	var fired []Alert
+
+	if d.cfg.FailedBoots > 0 && mac != "" {
+		times := append(prune(d.failures[mac], now, d.cfg.FailedBootsWindow), now)
+		d.failures[mac] = times
+		if len(times) >= d.cfg.FailedBoots && d.arm(KindRepeatedFailures+"|"+mac, now) {
+			fired = append(fired, Alert{
+				Kind:    KindRepeatedFailures,
+				MAC:     mac,
+				Image:   image,
+				Count:   len(times),
+				Window:  d.cfg.FailedBootsWindow,
+				Message: fmt.Sprintf("%s failed to boot %d times in %s", mac, len(times), d.cfg.FailedBootsWindow),
+			})
+		}
+	}
+
+	if d.cfg.ImageFailureRate > 0 && image != "" {
+		fails := append(prune(d.imageFails[image], now, d.cfg.ImageWindow), now)
+		d.imageFails[image] = fails
+		boots := prune(d.imageBoots[image], now, d.cfg.ImageWindow)
+		d.imageBoots[image] = boots
+		total := len(boots)
+		if total < len(fails) {
+			total = len(fails)
+		}
+		rate := float64(len(fails)) * 100 / float64(total)
+		if total >= d.cfg.ImageMinBoots && rate >= d.cfg.ImageFailureRate && d.arm(KindImageFailureRate+"|"+image, now) {
+			fired = append(fired, Alert{
+				Kind:    KindImageFailureRate,
+				Image:   image,
+				Count:   len(fails),
+				Total:   total,
+				Window:  d.cfg.ImageWindow,
+				Message: fmt.Sprintf("%s failed %d of %d boots (%.0f%%) in %s", image, len(fails), total, rate, d.cfg.ImageWindow),
+			})
+		}
+	}
+	d.mu.Unlock()
+
+	for _, a := range fired {
+		d.notify(a)
+	}
+}
+
+// UnknownClient records a MAC bootimus had never seen before.
+func (d *Detector) UnknownClient(mac string) {
+	if d == nil || d.cfg.UnknownMACs <= 0 {
+		return
+	}
+	d.mu.Lock()
+	now := d.now()
+	d.unknown = append(prune(d.unknown, now, d.cfg.UnknownMACsWindow), now)
+	n := len(d.unknown)
+	fire := n >= d.cfg.UnknownMACs && d.arm(KindUnknownMACSpike, now)
+	d.mu.Unlock()
+
+	if fire {
+		d.notify(Alert{
+			Kind:    KindUnknownMACSpike,
+			MAC:     mac,
+			Count:   n,
+			Window:  d.cfg.UnknownMACsWindow,
+			Message: fmt.Sprintf("%d unknown MACs started booting in %s (latest %s)", n, d.cfg.UnknownMACsWindow, mac),
+		})
+	}
+}
+
+// arm reports whether key may fire now, and starts its cooldown if so.
+func (d *Detector) arm(key string, now time.Time) bool {
+	if last, ok := d.lastFired[key]; ok && now.Sub(last) < d.cfg.Cooldown {
+		return false
+	}
+	d.lastFired[key] = now
+	return true
+}
+
+func prune(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
@@ -0,0 +1,53 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetector(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var got []Alert
+	d := New(Config{FailedBoots: 3, UnknownMACs: 2, ImageFailureRate: 50, ImageMinBoots: 4}, func(a Alert) { got = append(got, a) })
+	d.now = func() time.Time { return now }
+
+	d.BootFailed("aa", "")
+	d.BootFailed("aa", "")
+	now = now.Add(11 * time.Minute) // first two fall out of the window
+	d.BootFailed("aa", "")
+	if len(got) != 0 {
+		t.Fatalf("alerted on failures outside the window: %v", got)
+	}
+	d.BootFailed("aa", "")
+	d.BootFailed("aa", "")
+	if len(got) != 1 || got[0].Kind != KindRepeatedFailures || got[0].Count != 3 {
+		t.Fatalf("repeated failures: %v", got)
+	}
+	d.BootFailed("aa", "")
+	if len(got) != 1 {
+		t.Fatal("alert fired again during its cooldown")
+	}
+
+	got = nil
+	d.UnknownClient("m1")
+	d.UnknownClient("m2")
+	if len(got) != 1 || got[0].Kind != KindUnknownMACSpike || got[0].Count != 2 {
+		t.Fatalf("unknown MAC spike: %v", got)
+	}
+
+	got = nil
+	for i := 0; i < 4; i++ {
+		d.BootStarted("ubuntu")
+	}
+	d.BootFailed("", "ubuntu")
+	if len(got) != 0 {
+		t.Fatalf("alerted at 25%% failure rate: %v", got)
+	}
+	d.BootFailed("", "ubuntu")
+	if len(got) != 1 || got[0].Kind != KindImageFailureRate || got[0].Count != 2 || got[0].Total != 4 {
+		t.Fatalf("image failure rate: %v", got)
+	}
+
+	var nilDetector *Detector
+	nilDetector.BootFailed("aa", "ubuntu")
+}
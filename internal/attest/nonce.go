@@ -0,0 +1,57 @@
+package attest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const nonceTTL = 5 * time.Minute
+
+type nonceEntry struct {
+	value   string
+	expires time.Time
+}
+
+// Nonces hands out single-use challenges, one outstanding per MAC.
+type Nonces struct {
+	mu sync.Mutex
+	m  map[string]nonceEntry
+}
+
+func NewNonces() *Nonces {
+	return &Nonces{m: make(map[string]nonceEntry)}
+}
+
+// Issue returns a fresh hex nonce for mac, replacing any earlier one.
+func (n *Nonces) Issue(mac string) string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	value := hex.EncodeToString(b)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	for k, e := range n.m {
+		if now.After(e.expires) {
+			delete(n.m, k)
+		}
+	}
+	n.m[mac] = nonceEntry{value: value, expires: now.Add(nonceTTL)}
+	return value
+}
+
+// Consume returns the raw nonce for mac if value is the one outstanding and
+// still fresh. A nonce can only be consumed once.
+func (n *Nonces) Consume(mac, value string) ([]byte, bool) {
+	n.mu.Lock()
+	e, ok := n.m[mac]
+	delete(n.m, mac)
+	n.mu.Unlock()
+	if !ok || e.value != value || time.Now().After(e.expires) {
+		return nil, false
+	}
+	raw, err := hex.DecodeString(value)
+	return raw, err == nil
+}
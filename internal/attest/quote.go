@@ -0,0 +1,243 @@
+package attest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// TPM 2.0 structure constants (TPM 2.0 Part 2).
+const (
+	tpmGeneratedValue = 0xff544347
+	tpmSTAttestQuote  = 0x8018
+	tpmAlgSHA256      = 0x000b
+)
+
+// Evidence is what an enrollment image submits: the EK and AK public keys
+// (PEM), a TPM2_Quote over the SHA-256 PCR bank made with the AK and
+// qualified with the server's nonce, and the PCR values the quote covers,
+// concatenated in selection order. Byte fields are base64 in JSON.
+type Evidence struct {
+	MAC       string `json:"mac"`
+	Nonce     string `json:"nonce"` // hex, as issued by the server
+	EKPub     string `json:"ek_pub"`
+	AKPub     string `json:"ak_pub"`
+	Quote     []byte `json:"quote"`      // TPMS_ATTEST as returned by TPM2_Quote
+	Signature []byte `json:"signature"`  // plain signature over Quote: DER or r||s ECDSA, or RSA
+	PCRValues []byte `json:"pcr_values"` // 32 bytes per selected PCR
+}
+
+// Result is the verified content of a quote.
+type Result struct {
+	EKHash       string         // sha256 of the EK's SubjectPublicKeyInfo
+	AKHash       string         // same for the AK
+	PCRs         map[int]string // hex digests from the SHA-256 bank
+	ResetCount   uint32
+	RestartCount uint32
+}
+
+type quoteInfo struct {
+	extraData    []byte
+	resetCount   uint32
+	restartCount uint32
+	pcrs         []int
+	pcrDigest    []byte
+}
+
+// Verify checks that the quote is signed by the AK, carries nonce and
+// matches the PCR values sent alongside it. It does not decide whether the
+// machine is trusted; that is up to the caller's policy.
+//
+// Nothing here proves the AK lives in the TPM that owns the EK: there is no
+// credential activation and no EK certificate check. The keys are trusted
+// on first use, when an admin approves them, and a quote only shows that
+// its sender holds that AK.
+func Verify(ev *Evidence, nonce []byte) (*Result, error) {
+	ekDER, err := publicKeyDER(ev.EKPub)
+	if err != nil {
+		return nil, fmt.Errorf("ek_pub: %w", err)
+	}
+	akDER, err := publicKeyDER(ev.AKPub)
+	if err != nil {
+		return nil, fmt.Errorf("ak_pub: %w", err)
+	}
+	ak, err := x509.ParsePKIXPublicKey(akDER)
+	if err != nil {
+		return nil, fmt.Errorf("ak_pub: %w", err)
+	}
+	if err := verifySignature(ak, ev.Quote, ev.Signature); err != nil {
+		return nil, err
+	}
+
+	q, err := parseQuote(ev.Quote)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(q.extraData, nonce) {
+		return nil, errors.New("quote does not carry the issued nonce")
+	}
+	if len(ev.PCRValues) != len(q.pcrs)*sha256.Size {
+		return nil, fmt.Errorf("quote selects %d PCRs but %d bytes of values were sent", len(q.pcrs), len(ev.PCRValues))
+	}
+	if sum := sha256.Sum256(ev.PCRValues); !bytes.Equal(sum[:], q.pcrDigest) {
+		return nil, errors.New("PCR values do not match the quoted digest")
+	}
+
+	res := &Result{
+		EKHash:       hashHex(ekDER),
+		AKHash:       hashHex(akDER),
+		PCRs:         make(map[int]string, len(q.pcrs)),
+		ResetCount:   q.resetCount,
+		RestartCount: q.restartCount,
+	}
+	for i, pcr := range q.pcrs {
+		res.PCRs[pcr] = hex.EncodeToString(ev.PCRValues[i*sha256.Size : (i+1)*sha256.Size])
+	}
+	return res, nil
+}
+
+func publicKeyDER(pemData string) ([]byte, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(pemData)))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return nil, err
+	}
+	return block.Bytes, nil
+}
+
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func verifySignature(key crypto.PublicKey, msg, sig []byte) error {
+	digest := sha256.Sum256(msg)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(k, digest[:], sig) {
+			return nil
+		}
+		// tpm2-tools can also emit the raw r||s pair.
+		if len(sig)%2 == 0 && len(sig) > 0 {
+			r := new(big.Int).SetBytes(sig[:len(sig)/2])
+			s := new(big.Int).SetBytes(sig[len(sig)/2:])
+			if ecdsa.Verify(k, digest[:], r, s) {
+				return nil
+			}
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+		if rsa.VerifyPSS(k, crypto.SHA256, digest[:], sig, nil) == nil {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported AK type %T", key)
+	}
+	return errors.New("quote signature does not verify with the AK")
+}
+
+func parseQuote(b []byte) (*quoteInfo, error) {
+	r := &reader{b: b}
+	if r.u32() != tpmGeneratedValue {
+		return nil, errors.New("quote is not a TPM-generated structure")
+	}
+	if r.u16() != tpmSTAttestQuote {
+		return nil, errors.New("attestation is not a quote")
+	}
+	r.sized() // qualifiedSigner
+	q := &quoteInfo{extraData: r.sized()}
+	r.skip(8) // clock
+	q.resetCount = r.u32()
+	q.restartCount = r.u32()
+	r.skip(1 + 8) // safe, firmwareVersion
+
+	count := r.u32()
+	if count > 16 {
+		return nil, errors.New("malformed PCR selection")
+	}
+	for i := uint32(0); i < count; i++ {
+		alg := r.u16()
+		size := int(r.u8())
+		sel := r.bytes(size)
+		if r.err != nil {
+			break
+		}
+		if alg != tpmAlgSHA256 {
+			return nil, fmt.Errorf("quote selects hash algorithm 0x%04x; only the SHA-256 bank is supported", alg)
+		}
+		for byteIdx, bits := range sel {
+			for bit := 0; bit < 8; bit++ {
+				if bits&(1<<bit) != 0 {
+					q.pcrs = append(q.pcrs, byteIdx*8+bit)
+				}
+			}
+		}
+	}
+	q.pcrDigest = r.sized()
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed quote: %w", r.err)
+	}
+	if len(q.pcrs) == 0 {
+		return nil, errors.New("quote selects no PCRs")
+	}
+	return q, nil
+}
+
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.b) {
+		r.err = errors.New("truncated")
+		return nil
+	}
+	out := r.b[:n]
+	r.b = r.b[n:]
+	return out
+}
+
+func (r *reader) skip(n int) { r.bytes(n) }
+
+func (r *reader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) sized() []byte {
+	return r.bytes(int(r.u16()))
+}
@@ -0,0 +1,133 @@
+package attest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+func pemKey(t *testing.T, pub interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// buildQuote lays out a TPMS_ATTEST for a quote over the given SHA-256 PCRs.
+func buildQuote(nonce []byte, pcrs []int, values []byte) []byte {
+	var b bytes.Buffer
+	put := func(v interface{}) { binary.Write(&b, binary.BigEndian, v) }
+	sized := func(p []byte) { put(uint16(len(p))); b.Write(p) }
+
+	put(uint32(tpmGeneratedValue))
+	put(uint16(tpmSTAttestQuote))
+	sized([]byte("signer-name"))
+	sized(nonce)
+	put(uint64(12345)) // clock
+	put(uint32(7))     // resetCount
+	put(uint32(2))     // restartCount
+	b.WriteByte(1)     // safe
+	put(uint64(0x20240101))
+
+	sel := make([]byte, 3)
+	for _, p := range pcrs {
+		sel[p/8] |= 1 << (p % 8)
+	}
+	put(uint32(1))
+	put(uint16(tpmAlgSHA256))
+	b.WriteByte(byte(len(sel)))
+	b.Write(sel)
+	digest := sha256.Sum256(values)
+	sized(digest[:])
+	return b.Bytes()
+}
+
+func TestVerify(t *testing.T) {
+	ek, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ak, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	nonce := []byte("0123456789abcdefghij")
+	pcrs := []int{0, 2, 7}
+	values := bytes.Repeat([]byte{0xaa}, 32)
+	values = append(values, bytes.Repeat([]byte{0xbb}, 32)...)
+	values = append(values, bytes.Repeat([]byte{0xcc}, 32)...)
+	quote := buildQuote(nonce, pcrs, values)
+	digest := sha256.Sum256(quote)
+	sig, err := ecdsa.SignASN1(rand.Reader, ak, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev := func() *Evidence {
+		return &Evidence{
+			EKPub:     pemKey(t, &ek.PublicKey),
+			AKPub:     pemKey(t, &ak.PublicKey),
+			Quote:     append([]byte(nil), quote...),
+			Signature: sig,
+			PCRValues: append([]byte(nil), values...),
+		}
+	}
+
+	res, err := Verify(ev(), nonce)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(res.PCRs) != 3 || res.PCRs[7] != strings.Repeat("cc", 32) {
+		t.Errorf("PCRs = %v", res.PCRs)
+	}
+	if res.ResetCount != 7 || res.RestartCount != 2 {
+		t.Errorf("reset/restart = %d/%d", res.ResetCount, res.RestartCount)
+	}
+
+	if _, err := Verify(ev(), []byte("another nonce")); err == nil {
+		t.Error("wrong nonce accepted")
+	}
+
+	tampered := ev()
+	tampered.PCRValues[0] ^= 1
+	if _, err := Verify(tampered, nonce); err == nil {
+		t.Error("PCR values that don't match the digest accepted")
+	}
+
+	tampered = ev()
+	tampered.Quote[len(tampered.Quote)-1] ^= 1
+	if _, err := Verify(tampered, nonce); err == nil {
+		t.Error("modified quote accepted")
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tampered = ev()
+	tampered.AKPub = pemKey(t, &other.PublicKey)
+	if _, err := Verify(tampered, nonce); err == nil {
+		t.Error("quote accepted under a different AK")
+	}
+
+	if _, err := Verify(&Evidence{EKPub: ev().EKPub, AKPub: ev().AKPub, Quote: quote[:20], Signature: sig}, nonce); err == nil {
+		t.Error("truncated quote accepted")
+	}
+}
+
+func TestNonces(t *testing.T) {
+	n := NewNonces()
+	v := n.Issue("aa:bb:cc:dd:ee:ff")
+	if _, ok := n.Consume("aa:bb:cc:dd:ee:01", v); ok {
+		t.Error("nonce accepted for another MAC")
+	}
+	v = n.Issue("aa:bb:cc:dd:ee:ff")
+	if _, ok := n.Consume("aa:bb:cc:dd:ee:ff", v); !ok {
+		t.Fatal("fresh nonce rejected")
+	}
+	if _, ok := n.Consume("aa:bb:cc:dd:ee:ff", v); ok {
+		t.Error("nonce accepted twice")
+	}
+}
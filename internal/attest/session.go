@@ -0,0 +1,88 @@
+package attest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Session is what a passing attestation hands the client: a token that
+// stands for the attested machine until it expires.
+type Session struct {
+	Token   string
+	MAC     string
+	IP      string // the address the quote came from
+	Expires time.Time
+}
+
+// Sessions holds the live attestation sessions, one per MAC.
+type Sessions struct {
+	mu       sync.Mutex
+	byToken  map[string]*Session
+	byClient map[string]*Session
+}
+
+func NewSessions() *Sessions {
+	return &Sessions{byToken: make(map[string]*Session), byClient: make(map[string]*Session)}
+}
+
+// Issue starts a session for mac, replacing any earlier one.
+func (s *Sessions) Issue(mac, ip string, expires time.Time) Session {
+	b := make([]byte, 20)
+	rand.Read(b)
+	sess := &Session{Token: hex.EncodeToString(b), MAC: mac, IP: ip, Expires: expires}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+	if old, ok := s.byClient[mac]; ok {
+		delete(s.byToken, old.Token)
+	}
+	s.byToken[sess.Token] = sess
+	s.byClient[mac] = sess
+	return *sess
+}
+
+// Get returns the session a token belongs to, if it hasn't expired.
+func (s *Sessions) Get(token string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.byToken[token]
+	if !ok || time.Now().After(sess.Expires) {
+		return Session{}, false
+	}
+	return *sess, true
+}
+
+// ForClient returns mac's session if it was issued to ip, so a machine that
+// attested and then rebooted into iPXE gets its session back.
+func (s *Sessions) ForClient(mac, ip string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.byClient[mac]
+	if !ok || sess.IP != ip || time.Now().After(sess.Expires) {
+		return Session{}, false
+	}
+	return *sess, true
+}
+
+// Revoke ends mac's session.
+func (s *Sessions) Revoke(mac string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.byClient[mac]; ok {
+		delete(s.byToken, sess.Token)
+		delete(s.byClient, mac)
+	}
+}
+
+// expire drops ended sessions; s.mu must be held.
+func (s *Sessions) expire(now time.Time) {
+	for mac, sess := range s.byClient {
+		if now.After(sess.Expires) {
+			delete(s.byToken, sess.Token)
+			delete(s.byClient, mac)
+		}
+	}
+}
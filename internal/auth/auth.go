@@ -16,9 +16,10 @@ import (
 )
 
 type Manager struct {
-	userStore  database.UserStore
-	jwtSecret  []byte
-	ldapConfig *LDAPConfig
+	userStore   database.UserStore
+	jwtSecret   []byte
+	ldapConfig  *LDAPConfig
+	clientCerts *ClientCertConfig
 }
 
 type Claims struct {
@@ -151,6 +152,9 @@ func (m *Manager) HandleAuthInfo(w http.ResponseWriter, r *http.Request) {
 		}
 		backends = append(backends, map[string]string{"id": "ldap", "name": name})
 	}
+	if m.clientCerts != nil {
+		backends = append(backends, map[string]string{"id": "certificate", "name": "Client certificate"})
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": backends})
 }
@@ -177,7 +181,22 @@ func (m *Manager) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		method = "local"
 	}
 
+	if m.clientCerts != nil && m.clientCerts.Required && method != "certificate" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Sign in with a client certificate"})
+		return
+	}
+
 	switch method {
+	case "certificate":
+		if user := m.certUser(r); user != "" {
+			if u, err := m.userStore.GetUser(user); err == nil && u.Enabled {
+				req.Username = user
+				authenticated = true
+				isAdmin = u.IsAdmin
+			}
+		}
 	case "ldap":
 		if m.ldapConfig == nil {
 			w.Header().Set("Content-Type", "application/json")
@@ -201,9 +220,13 @@ func (m *Manager) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !authenticated {
+		msg := "Invalid username or password"
+		if method == "certificate" {
+			msg = "No client certificate for an enabled user"
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid username or password"})
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": msg})
 		return
 	}
 
@@ -229,6 +252,18 @@ func (m *Manager) HandleLogin(w http.ResponseWriter, r *http.Request) {
 }
 
 func (m *Manager) authenticate(w http.ResponseWriter, r *http.Request) (*Claims, bool) {
+	if m.clientCerts != nil {
+		if user := m.certUser(r); user != "" {
+			return m.userClaims(w, user)
+		}
+		if m.clientCerts.Required {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Client certificate not mapped to a user"})
+			return nil, false
+		}
+	}
+
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 		w.Header().Set("Content-Type", "application/json")
@@ -246,7 +281,11 @@ func (m *Manager) authenticate(w http.ResponseWriter, r *http.Request) (*Claims,
 		return nil, false
 	}
 
-	user, err := m.userStore.GetUser(claims.Username)
+	return m.userClaims(w, claims.Username)
+}
+
+func (m *Manager) userClaims(w http.ResponseWriter, username string) (*Claims, bool) {
+	user, err := m.userStore.GetUser(username)
 	if err != nil || !user.Enabled {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
@@ -254,8 +293,7 @@ func (m *Manager) authenticate(w http.ResponseWriter, r *http.Request) (*Claims,
 		return nil, false
 	}
 
-	claims.IsAdmin = user.IsAdmin
-	return claims, true
+	return &Claims{Username: username, IsAdmin: user.IsAdmin}, true
 }
 
 func (m *Manager) JWTMiddleware(next http.HandlerFunc) http.HandlerFunc {
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ClientCertConfig lets admin API users sign in with a TLS client
+// certificate instead of a password or token.
+type ClientCertConfig struct {
+	CAFile   string `mapstructure:"ca_file"`  // PEM bundle of the CAs that issue client certificates
+	Required bool   `mapstructure:"required"` // refuse connections without a certificate, and password logins
+	// Certificate common name, email address or "sha256:<fingerprint>" to
+	// username. When empty the common name is the username.
+	Users map[string]string `mapstructure:"users"`
+}
+
+// Pool loads the CA bundle for verifying client certificates.
+func (c ClientCertConfig) Pool() (*x509.CertPool, error) {
+	data, err := os.ReadFile(c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+	}
+	return pool, nil
+}
+
+// UseClientCerts makes verified client certificates authenticate requests.
+func (m *Manager) UseClientCerts(cfg ClientCertConfig) {
+	users := make(map[string]string, len(cfg.Users))
+	for k, v := range cfg.Users {
+		// Config keys arrive lowercased, so lookups are case-insensitive.
+		users[strings.ToLower(k)] = v
+	}
+	cfg.Users = users
+	m.clientCerts = &cfg
+	log.Printf("Client certificate authentication enabled (%d mapped identities, required: %t)", len(users), cfg.Required)
+}
+
+// certUser returns the user the request's client certificate maps to, or
+// "" if there is no verified certificate or it maps to nobody.
+func (m *Manager) certUser(r *http.Request) string {
+	if m.clientCerts == nil || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	if len(m.clientCerts.Users) == 0 {
+		return cert.Subject.CommonName
+	}
+	sum := sha256.Sum256(cert.Raw)
+	ids := append([]string{"sha256:" + hex.EncodeToString(sum[:]), cert.Subject.CommonName}, cert.EmailAddresses...)
+	for _, id := range ids {
+		if user, ok := m.clientCerts.Users[strings.ToLower(id)]; ok && id != "" {
+			return user
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bootimus/internal/models"
+)
+
+func TestClientCertAuthentication(t *testing.T) {
+	store := &fakeUserStore{users: map[string]*models.User{
+		"alice":  {Username: "alice", Enabled: true, IsAdmin: true},
+		"ci-bot": {Username: "ci-bot", Enabled: true, IsAdmin: true},
+		"bob":    {Username: "bob", Enabled: false, IsAdmin: true},
+	}}
+	botCert := &x509.Certificate{Raw: []byte("ci-bot certificate"), Subject: pkix.Name{CommonName: "runner-7"}}
+	sum := sha256.Sum256(botCert.Raw)
+
+	call := func(m *Manager, cert *x509.Certificate) int {
+		h := m.AdminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/api/images", nil)
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec.Code
+	}
+
+	m := &Manager{userStore: store, jwtSecret: []byte("test-secret-0123456789")}
+	m.UseClientCerts(ClientCertConfig{Users: map[string]string{
+		"Alice@Example.com":                    "alice",
+		"sha256:" + hex.EncodeToString(sum[:]): "ci-bot",
+		"bob":                                  "bob",
+	}})
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want int
+	}{
+		{"email", &x509.Certificate{Subject: pkix.Name{CommonName: "Alice Smith"}, EmailAddresses: []string{"alice@example.com"}}, http.StatusOK},
+		{"fingerprint", botCert, http.StatusOK},
+		{"disabled user", &x509.Certificate{Subject: pkix.Name{CommonName: "bob"}}, http.StatusUnauthorized},
+		{"unmapped", &x509.Certificate{Subject: pkix.Name{CommonName: "mallory"}}, http.StatusUnauthorized},
+		{"no certificate", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if got := call(m, tt.cert); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// Without a mapping the common name is the username.
+	m = &Manager{userStore: store, jwtSecret: []byte("test-secret-0123456789")}
+	m.UseClientCerts(ClientCertConfig{Required: true})
+	if got := call(m, &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}); got != http.StatusOK {
+		t.Errorf("common name: got %d, want 200", got)
+	}
+	tok, _ := m.GenerateToken("alice", true)
+	req := httptest.NewRequest(http.MethodGet, "/api/images", nil)
+	req.Header.Set("Authorization", "Bearer "+tok)
+	rec := httptest.NewRecorder()
+	m.AdminMiddleware(func(w http.ResponseWriter, r *http.Request) {})(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("token without certificate when required: got %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,188 @@
+package autoinstall
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// KickstartRepo is an additional package repository for kickstart.
+type KickstartRepo struct {
+	Name    string `json:"name"`
+	BaseURL string `json:"base_url"`
+}
+
+// KickstartAnswer is the structured form behind a generated RHEL-family
+// kickstart file.
+type KickstartAnswer struct {
+	Lang             string          `json:"lang"`     // e.g. "en_GB.UTF-8"
+	Keyboard         string          `json:"keyboard"` // e.g. "gb"
+	Hostname         string          `json:"hostname"`
+	Mirror           string          `json:"mirror"` // install tree URL; empty = use the boot media
+	Repos            []KickstartRepo `json:"repos"`
+	Proxy            string          `json:"proxy"`
+	TimeZone         string          `json:"time_zone"`
+	NTPServer        string          `json:"ntp_server"`
+	Disk             string          `json:"disk"`        // empty = all disks
+	DiskLayout       string          `json:"disk_layout"` // "lvm", "plain" or "btrfs"
+	RootPasswordHash string          `json:"root_password_hash"`
+	Username         string          `json:"username"`
+	UserPasswordHash string          `json:"user_password_hash"`
+	SELinux          string          `json:"selinux"` // "enforcing", "permissive" or "disabled"
+	Packages         []string        `json:"packages"`
+	PostScript       string          `json:"post_script"` // %post body
+	Reboot           bool            `json:"reboot"`
+}
+
+var repoNameRe = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+func (a *KickstartAnswer) normalise() {
+	if a.Lang == "" {
+		a.Lang = "en_US.UTF-8"
+	}
+	if a.Keyboard == "" {
+		a.Keyboard = "us"
+	}
+	if a.Hostname == "" {
+		a.Hostname = "{{HOSTNAME}}"
+	}
+	if a.TimeZone == "" {
+		a.TimeZone = "UTC"
+	}
+	if a.DiskLayout == "" {
+		a.DiskLayout = "lvm"
+	}
+	if a.SELinux == "" {
+		a.SELinux = "enforcing"
+	}
+	if len(a.Packages) == 0 {
+		a.Packages = []string{"@^minimal-environment"}
+	}
+	a.Disk = strings.TrimPrefix(a.Disk, "/dev/")
+}
+
+func (a *KickstartAnswer) Validate() error {
+	a.normalise()
+	var v validator
+
+	for _, f := range []struct{ field, value string }{
+		{"lang", a.Lang},
+		{"keyboard", a.Keyboard},
+		{"mirror", a.Mirror},
+		{"proxy", a.Proxy},
+		{"ntp_server", a.NTPServer},
+	} {
+		v.singleLine(f.field, f.value)
+	}
+	v.common(a.Hostname, a.TimeZone, a.Disk, a.Username)
+
+	if a.Mirror != "" {
+		if u, err := url.Parse(a.Mirror); err != nil || u.Host == "" {
+			v.add("mirror", "must be a URL")
+		}
+	}
+	if a.Proxy != "" {
+		if u, err := url.Parse(a.Proxy); err != nil || u.Host == "" {
+			v.add("proxy", "must be a URL")
+		}
+	}
+	for _, r := range a.Repos {
+		if !repoNameRe.MatchString(r.Name) {
+			v.add("repos", "repo names may only contain letters, digits, dots, underscores and hyphens")
+		}
+		if u, err := url.Parse(r.BaseURL); err != nil || u.Host == "" || strings.ContainsAny(r.BaseURL, " \r\n") {
+			v.add("repos", "repo "+r.Name+" needs a base_url")
+		}
+	}
+	switch a.DiskLayout {
+	case "lvm", "plain", "btrfs":
+	default:
+		v.add("disk_layout", "must be lvm, plain or btrfs")
+	}
+	switch a.SELinux {
+	case "enforcing", "permissive", "disabled":
+	default:
+		v.add("selinux", "must be enforcing, permissive or disabled")
+	}
+	v.passwordHash("root_password_hash", a.RootPasswordHash)
+	v.passwordHash("user_password_hash", a.UserPasswordHash)
+	if a.Username != "" && a.UserPasswordHash == "" {
+		v.add("user_password_hash", "is required when username is set")
+	}
+	if a.RootPasswordHash == "" && a.Username == "" {
+		v.add("root_password_hash", "set a root password or a user account")
+	}
+	a.Packages = v.packages("packages", a.Packages)
+	if strings.Contains(a.PostScript, "%end") {
+		v.add("post_script", "must not contain %end")
+	}
+	return v.err()
+}
+
+var kickstartTemplate = template.Must(template.New("kickstart").Parse(`# Generated by bootimus
+text
+lang {{.Lang}}
+keyboard {{.Keyboard}}
+network --bootproto=dhcp --hostname={{.Hostname}} --activate
+{{- if .Mirror}}
+url --url={{.Mirror}}{{if .Proxy}} --proxy={{.Proxy}}{{end}}
+{{- end}}
+{{- range .Repos}}
+repo --name={{.Name}} --baseurl={{.BaseURL}}{{if $.Proxy}} --proxy={{$.Proxy}}{{end}}
+{{- end}}
+
+timezone {{.TimeZone}} --utc
+{{- if .NTPServer}}
+timesource --ntp-server={{.NTPServer}}
+{{- end}}
+
+{{if .RootPasswordHash -}}
+rootpw --iscrypted {{.RootPasswordHash}}
+{{- else}}
+rootpw --lock
+{{- end}}
+{{- if .Username}}
+user --name={{.Username}} --groups=wheel --iscrypted --password={{.UserPasswordHash}}
+{{- end}}
+selinux --{{.SELinux}}
+firstboot --disable
+
+{{if .Disk -}}
+ignoredisk --only-use={{.Disk}}
+clearpart --all --initlabel --drives={{.Disk}}
+{{- else}}
+clearpart --all --initlabel
+{{- end}}
+zerombr
+bootloader{{if .Disk}} --boot-drive={{.Disk}}{{end}}
+autopart --type={{if eq .DiskLayout "lvm"}}lvm{{else if eq .DiskLayout "btrfs"}}btrfs{{else}}plain{{end}}
+
+%packages
+{{- range .Packages}}
+{{.}}
+{{- end}}
+%end
+{{- if .PostScript}}
+
+%post --log=/root/bootimus-post.log
+{{.PostScript}}
+%end
+{{- end}}
+
+{{if .Reboot}}reboot{{else}}poweroff{{end}}
+`))
+
+// RenderKickstart validates the answers and renders a kickstart file.
+func RenderKickstart(a *KickstartAnswer) (string, error) {
+	if err := a.Validate(); err != nil {
+		return "", err
+	}
+	a.PostScript = strings.TrimSpace(strings.ReplaceAll(a.PostScript, "\r\n", "\n"))
+	var buf bytes.Buffer
+	if err := kickstartTemplate.Execute(&buf, a); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,87 @@
+package autoinstall
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Fields shared by the preseed and kickstart generators.
+
+var (
+	hostnameRe    = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?|\{\{[A-Z_]+\}\})$`)
+	placeholderRe = regexp.MustCompile(`^\{\{[A-Z_]+\}\}$`)
+	packageRe     = regexp.MustCompile(`^-?(@\^?)?[A-Za-z0-9][A-Za-z0-9._+:*-]*$`)
+	diskRe        = regexp.MustCompile(`^(/dev/)?[A-Za-z0-9/_-]+$`)
+	timezoneRe    = regexp.MustCompile(`^[A-Za-z0-9_+-]+(/[A-Za-z0-9_+-]+)*$`)
+	usernameRe    = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+)
+
+// validator accumulates field errors in the order checks are made.
+type validator struct{ errs ValidationErrors }
+
+func (v *validator) add(field, msg string) { v.errs = append(v.errs, FieldError{field, msg}) }
+
+func (v *validator) err() error {
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// singleLine rejects values that would spill onto further lines of a
+// line-oriented answer file.
+func (v *validator) singleLine(field, value string) bool {
+	if strings.ContainsAny(value, "\r\n") {
+		v.add(field, "must not contain line breaks")
+		return false
+	}
+	return true
+}
+
+func (v *validator) passwordHash(field, value string) {
+	if value == "" || placeholderRe.MatchString(value) {
+		return
+	}
+	if !strings.HasPrefix(value, "$") || strings.ContainsAny(value, " \t\r\n") {
+		v.add(field, "must be a crypt(3) hash such as the output of mkpasswd -m sha-512")
+	}
+}
+
+func (v *validator) packages(field string, pkgs []string) []string {
+	out := make([]string, 0, len(pkgs))
+	for _, p := range pkgs {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !packageRe.MatchString(p) {
+			v.add(field, fmt.Sprintf("%q is not a valid package name", p))
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func (v *validator) common(hostname, timezone, disk, username string) {
+	if !hostnameRe.MatchString(hostname) {
+		v.add("hostname", "must be a placeholder or a hostname label")
+	}
+	if !timezoneRe.MatchString(timezone) {
+		v.add("time_zone", fmt.Sprintf("%q is not a zone like Europe/London", timezone))
+	}
+	if disk != "" && !diskRe.MatchString(disk) {
+		v.add("disk", "must be a device name such as sda or /dev/nvme0n1")
+	}
+	if username != "" && !usernameRe.MatchString(username) && !placeholderRe.MatchString(username) {
+		v.add("username", "must be a lowercase Unix username")
+	}
+}
+
+func devPath(disk string) string {
+	if disk == "" || strings.HasPrefix(disk, "/dev/") {
+		return disk
+	}
+	return "/dev/" + disk
+}
@@ -0,0 +1,74 @@
+package autoinstall
+
+import (
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"bootimus/internal/models"
+)
+
+type cloudUser struct {
+	Name              string   `yaml:"name"`
+	Shell             string   `yaml:"shell,omitempty"`
+	Sudo              string   `yaml:"sudo,omitempty"`
+	LockPasswd        bool     `yaml:"lock_passwd"`
+	Passwd            string   `yaml:"passwd,omitempty"`
+	SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys,omitempty"`
+}
+
+type cloudConfig struct {
+	Hostname          string        `yaml:"hostname,omitempty"`
+	Users             []interface{} `yaml:"users,omitempty"`
+	SSHAuthorizedKeys []string      `yaml:"ssh_authorized_keys,omitempty"`
+	SSHPwauth         *bool         `yaml:"ssh_pwauth,omitempty"`
+}
+
+// VendorData renders the deployment-wide access config as cloud-config, so
+// NoCloud guests get the same user and keys as installed machines. A
+// client's own user-data is merged over it by cloud-init.
+func VendorData(cfg *models.AccessConfig, hostname string) (string, error) {
+	cc := cloudConfig{Hostname: hostname}
+	if cfg != nil {
+		keys := AuthorizedKeys(cfg)
+		if cfg.DefaultUsername != "" {
+			u := cloudUser{
+				Name:              cfg.DefaultUsername,
+				Shell:             cfg.DefaultShell,
+				LockPasswd:        cfg.DefaultPasswordHash == "",
+				Passwd:            string(cfg.DefaultPasswordHash),
+				SSHAuthorizedKeys: keys,
+			}
+			if cfg.DefaultSudo {
+				u.Sudo = "ALL=(ALL) NOPASSWD:ALL"
+			}
+			cc.Users = []interface{}{"default", u}
+			if u.Passwd != "" {
+				pwauth := true
+				cc.SSHPwauth = &pwauth
+			}
+		} else {
+			cc.SSHAuthorizedKeys = keys
+		}
+	}
+	var b strings.Builder
+	b.WriteString("#cloud-config\n")
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(cc); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// IsCloudInitUserData reports whether content is something cloud-init
+// accepts as user-data, as opposed to an installer answer file.
+func IsCloudInitUserData(content string) bool {
+	head := strings.TrimLeft(content, " \t\r\n")
+	for _, prefix := range []string{"#cloud-config", "#!", "#include", "#cloud-boothook", "## template: jinja", "Content-Type: multipart/"} {
+		if strings.HasPrefix(head, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package autoinstall
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// PreseedAnswer is the structured form behind a generated Debian/Ubuntu
+// preseed file.
+type PreseedAnswer struct {
+	Locale           string   `json:"locale"` // e.g. "en_GB.UTF-8"
+	Keymap           string   `json:"keymap"` // e.g. "gb"
+	Hostname         string   `json:"hostname"`
+	Domain           string   `json:"domain"`
+	Mirror           string   `json:"mirror"` // e.g. "http://deb.debian.org/debian"
+	Proxy            string   `json:"proxy"`
+	TimeZone         string   `json:"time_zone"` // e.g. "Europe/London"
+	NTPServer        string   `json:"ntp_server"`
+	Disk             string   `json:"disk"`        // empty = first disk
+	DiskLayout       string   `json:"disk_layout"` // "atomic", "home" or "multi"
+	DiskMethod       string   `json:"disk_method"` // "regular" or "lvm"
+	RootPasswordHash string   `json:"root_password_hash"`
+	Username         string   `json:"username"`
+	UserPasswordHash string   `json:"user_password_hash"`
+	Tasks            []string `json:"tasks"` // tasksel tasks, e.g. "standard", "ssh-server"
+	Packages         []string `json:"packages"`
+	PostScript       string   `json:"post_script"` // run in the target via late_command
+	Reboot           bool     `json:"reboot"`
+}
+
+func (a *PreseedAnswer) normalise() {
+	if a.Locale == "" {
+		a.Locale = "en_US.UTF-8"
+	}
+	if a.Keymap == "" {
+		a.Keymap = "us"
+	}
+	if a.Hostname == "" {
+		a.Hostname = "{{HOSTNAME}}"
+	}
+	if a.Mirror == "" {
+		a.Mirror = "http://deb.debian.org/debian"
+	}
+	if a.TimeZone == "" {
+		a.TimeZone = "UTC"
+	}
+	if a.DiskLayout == "" {
+		a.DiskLayout = "atomic"
+	}
+	if a.DiskMethod == "" {
+		a.DiskMethod = "regular"
+	}
+	if len(a.Tasks) == 0 {
+		a.Tasks = []string{"standard", "ssh-server"}
+	}
+}
+
+func (a *PreseedAnswer) Validate() error {
+	a.normalise()
+	var v validator
+
+	for _, f := range []struct{ field, value string }{
+		{"locale", a.Locale},
+		{"keymap", a.Keymap},
+		{"domain", a.Domain},
+		{"mirror", a.Mirror},
+		{"proxy", a.Proxy},
+		{"ntp_server", a.NTPServer},
+	} {
+		v.singleLine(f.field, f.value)
+	}
+	v.common(a.Hostname, a.TimeZone, a.Disk, a.Username)
+
+	if u, err := url.Parse(a.Mirror); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add("mirror", "must be an http(s) URL")
+	}
+	if a.Proxy != "" {
+		if u, err := url.Parse(a.Proxy); err != nil || u.Host == "" {
+			v.add("proxy", "must be a URL")
+		}
+	}
+	switch a.DiskLayout {
+	case "atomic", "home", "multi":
+	default:
+		v.add("disk_layout", "must be atomic, home or multi")
+	}
+	if a.DiskMethod != "regular" && a.DiskMethod != "lvm" {
+		v.add("disk_method", "must be regular or lvm")
+	}
+	v.passwordHash("root_password_hash", a.RootPasswordHash)
+	v.passwordHash("user_password_hash", a.UserPasswordHash)
+	if a.Username != "" && a.UserPasswordHash == "" {
+		v.add("user_password_hash", "is required when username is set")
+	}
+	if a.RootPasswordHash == "" && a.Username == "" {
+		v.add("root_password_hash", "set a root password or a user account")
+	}
+	a.Tasks = v.packages("tasks", a.Tasks)
+	a.Packages = v.packages("packages", a.Packages)
+	return v.err()
+}
+
+// mirrorParts splits the mirror URL into the separate host and directory
+// questions mirror/http asks.
+func (a *PreseedAnswer) mirrorParts() (scheme, host, dir string) {
+	u, _ := url.Parse(a.Mirror)
+	dir = u.Path
+	if dir == "" {
+		dir = "/"
+	}
+	return u.Scheme, u.Host, dir
+}
+
+// lateCommand folds the post script into a single in-target invocation;
+// preseed values cannot span lines, so the script travels base64-encoded.
+func lateCommand(script string) string {
+	script = strings.TrimSpace(strings.ReplaceAll(script, "\r\n", "\n"))
+	if script == "" {
+		return ""
+	}
+	enc := base64.StdEncoding.EncodeToString([]byte(script + "\n"))
+	return fmt.Sprintf("echo %s | base64 -d > /target/tmp/bootimus-post.sh; in-target sh /tmp/bootimus-post.sh", enc)
+}
+
+var preseedTemplate = template.Must(template.New("preseed").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`# Generated by bootimus
+d-i debian-installer/locale string {{.Locale}}
+d-i keyboard-configuration/xkb-keymap select {{.Keymap}}
+
+d-i netcfg/choose_interface select auto
+d-i netcfg/get_hostname string {{.Hostname}}
+d-i netcfg/get_domain string {{.Domain}}
+d-i netcfg/hostname string {{.Hostname}}
+
+d-i mirror/country string manual
+d-i mirror/protocol string {{.MirrorScheme}}
+d-i mirror/{{.MirrorScheme}}/hostname string {{.MirrorHost}}
+d-i mirror/{{.MirrorScheme}}/directory string {{.MirrorDir}}
+d-i mirror/{{.MirrorScheme}}/proxy string {{.Proxy}}
+
+d-i clock-setup/utc boolean true
+d-i time/zone string {{.TimeZone}}
+{{- if .NTPServer}}
+d-i clock-setup/ntp boolean true
+d-i clock-setup/ntp-server string {{.NTPServer}}
+{{- end}}
+
+{{if .RootPasswordHash -}}
+d-i passwd/root-login boolean true
+d-i passwd/root-password-crypted password {{.RootPasswordHash}}
+{{- else}}
+d-i passwd/root-login boolean false
+{{- end}}
+{{- if .Username}}
+d-i passwd/make-user boolean true
+d-i passwd/user-fullname string {{.Username}}
+d-i passwd/username string {{.Username}}
+d-i passwd/user-password-crypted password {{.UserPasswordHash}}
+{{- else}}
+d-i passwd/make-user boolean false
+{{- end}}
+
+{{if .Disk -}}
+d-i partman-auto/disk string {{.Disk}}
+{{end -}}
+d-i partman-auto/method string {{.DiskMethod}}
+{{- if eq .DiskMethod "lvm"}}
+d-i partman-auto-lvm/guided_size string max
+d-i partman-lvm/device_remove_lvm boolean true
+d-i partman-lvm/confirm boolean true
+d-i partman-lvm/confirm_nooverwrite boolean true
+{{- end}}
+d-i partman-md/device_remove_md boolean true
+d-i partman-auto/choose_recipe select {{.DiskLayout}}
+d-i partman-efi/non_efi_system boolean true
+d-i partman-partitioning/confirm_write_new_label boolean true
+d-i partman/choose_partition select finish
+d-i partman/confirm boolean true
+d-i partman/confirm_nooverwrite boolean true
+
+tasksel tasksel/first multiselect {{join .Tasks ", "}}
+{{- if .Packages}}
+d-i pkgsel/include string {{join .Packages " "}}
+{{- end}}
+d-i pkgsel/upgrade select safe-upgrade
+popularity-contest popularity-contest/participate boolean false
+
+d-i grub-installer/only_debian boolean true
+d-i grub-installer/bootdev string {{if .Disk}}{{.Disk}}{{else}}default{{end}}
+{{- if .LateCommand}}
+
+d-i preseed/late_command string {{.LateCommand}}
+{{- end}}
+
+d-i finish-install/reboot_in_progress note
+{{- if not .Reboot}}
+d-i debian-installer/exit/poweroff boolean true
+{{- end}}
+`))
+
+// RenderPreseed validates the answers and renders a preseed file.
+func RenderPreseed(a *PreseedAnswer) (string, error) {
+	if err := a.Validate(); err != nil {
+		return "", err
+	}
+	scheme, host, dir := a.mirrorParts()
+	data := struct {
+		*PreseedAnswer
+		Disk         string
+		MirrorScheme string
+		MirrorHost   string
+		MirrorDir    string
+		LateCommand  string
+	}{a, devPath(a.Disk), scheme, host, dir, lateCommand(a.PostScript)}
+
+	var buf bytes.Buffer
+	if err := preseedTemplate.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,339 @@
+package autoinstall
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"bootimus/internal/models"
+)
+
+// SeedVars returns the template placeholders derived from the deployment-wide
+// access config so every render can make the booted system reachable over SSH.
+func SeedVars(cfg *models.AccessConfig) map[string]string {
+	vars := map[string]string{
+		"{{SSH_AUTHORIZED_KEYS}}":   "",
+		"{{SSH_KEYS_JSON}}":         "[]",
+		"{{DEFAULT_USER}}":          "",
+		"{{DEFAULT_PASSWORD_HASH}}": "",
+		"{{DEFAULT_SHELL}}":         "/bin/bash",
+		"{{DEFAULT_SUDO}}":          "",
+	}
+	if cfg == nil {
+		return vars
+	}
+
+	keys := AuthorizedKeys(cfg)
+	vars["{{SSH_AUTHORIZED_KEYS}}"] = strings.Join(keys, "\n")
+	// JSON arrays are also valid YAML flow sequences, so one form serves
+	// cloud-init, autoinstall and ignition alike.
+	if j, err := json.Marshal(keys); err == nil {
+		vars["{{SSH_KEYS_JSON}}"] = string(j)
+	}
+
+	vars["{{DEFAULT_USER}}"] = cfg.DefaultUsername
+	vars["{{DEFAULT_PASSWORD_HASH}}"] = string(cfg.DefaultPasswordHash)
+	if cfg.DefaultShell != "" {
+		vars["{{DEFAULT_SHELL}}"] = cfg.DefaultShell
+	}
+	if cfg.DefaultSudo {
+		vars["{{DEFAULT_SUDO}}"] = "ALL=(ALL) NOPASSWD:ALL"
+	}
+	return vars
+}
+
+// AuthorizedKeys returns the configured keys with blanks and comments dropped.
+func AuthorizedKeys(cfg *models.AccessConfig) []string {
+	if cfg == nil {
+		return nil
+	}
+	out := make([]string, 0, len(cfg.SSHAuthorizedKeys))
+	for _, k := range cfg.SSHAuthorizedKeys {
+		k = strings.TrimSpace(k)
+		if k == "" || strings.HasPrefix(k, "#") {
+			continue
+		}
+		out = append(out, k)
+	}
+	return out
+}
+
+// UsesSeedVars reports whether a script places the seeded keys or user
+// itself, in which case InjectAccess leaves it alone.
+func UsesSeedVars(script string) bool {
+	for _, v := range []string{"{{SSH_AUTHORIZED_KEYS}}", "{{SSH_KEYS_JSON}}", "{{DEFAULT_USER}}"} {
+		if strings.Contains(script, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// InjectAccess adds the seeded keys, and the default user where the format
+// has a place for one, to a rendered script of the given type: "autoinstall"
+// (subiquity or cloud-config YAML), "kickstart", "preseed" or "ignition".
+// Other types, and scripts it can't parse, come back unchanged.
+func InjectAccess(script, scriptType string, cfg *models.AccessConfig) (string, error) {
+	keys := AuthorizedKeys(cfg)
+	if len(keys) == 0 {
+		return script, nil
+	}
+	switch scriptType {
+	case "autoinstall":
+		return injectYAML(script, keys, cfg)
+	case "kickstart":
+		return injectKickstart(script, keys, cfg), nil
+	case "preseed":
+		return injectPreseed(script, keys, cfg), nil
+	case "ignition":
+		return injectIgnition(script, keys, cfg)
+	}
+	return script, nil
+}
+
+func injectYAML(script string, keys []string, cfg *models.AccessConfig) (string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(script), &doc); err != nil || doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return script, nil
+	}
+	root := doc.Content[0]
+	if ai := mappingValue(root, "autoinstall"); ai != nil && ai.Kind == yaml.MappingNode {
+		injectSubiquity(ai, keys)
+	} else if mappingValue(root, "version") != nil && mappingValue(root, "identity") != nil {
+		injectSubiquity(root, keys)
+	} else {
+		if cfg.DefaultUsername != "" && mappingValue(root, "users") == nil {
+			var user yaml.Node
+			u := cloudUser{
+				Name:              cfg.DefaultUsername,
+				Shell:             cfg.DefaultShell,
+				LockPasswd:        cfg.DefaultPasswordHash == "",
+				Passwd:            string(cfg.DefaultPasswordHash),
+				SSHAuthorizedKeys: keys,
+			}
+			if cfg.DefaultSudo {
+				u.Sudo = "ALL=(ALL) NOPASSWD:ALL"
+			}
+			if err := user.Encode(u); err != nil {
+				return "", err
+			}
+			setMapping(root, "users", &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Value: "default"}, &user,
+			}})
+		}
+		mergeKeys(root, "ssh_authorized_keys", keys)
+	}
+
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return "", err
+	}
+	out := b.String()
+	// cloud-init recognises user-data by its first line.
+	if strings.HasPrefix(strings.TrimLeft(script, " \t\r\n"), "#cloud-config") && !strings.HasPrefix(out, "#cloud-config") {
+		out = "#cloud-config\n" + out
+	}
+	return out, nil
+}
+
+// injectSubiquity adds keys to an autoinstall section's ssh block, and turns
+// on the SSH server unless the section says otherwise.
+func injectSubiquity(ai *yaml.Node, keys []string) {
+	ssh := mappingValue(ai, "ssh")
+	if ssh == nil || ssh.Kind != yaml.MappingNode {
+		ssh = &yaml.Node{Kind: yaml.MappingNode}
+		setMapping(ai, "ssh", ssh)
+	}
+	if mappingValue(ssh, "install-server") == nil {
+		setMapping(ssh, "install-server", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"})
+	}
+	mergeKeys(ssh, "authorized-keys", keys)
+}
+
+// mergeKeys appends the keys not already in the sequence under key,
+// creating it if needed.
+func mergeKeys(m *yaml.Node, key string, keys []string) {
+	seq := mappingValue(m, key)
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		seq = &yaml.Node{Kind: yaml.SequenceNode}
+		setMapping(m, key, seq)
+	}
+	have := map[string]bool{}
+	for _, n := range seq.Content {
+		have[strings.TrimSpace(n.Value)] = true
+	}
+	for _, k := range keys {
+		if !have[k] {
+			seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k})
+		}
+	}
+}
+
+func setMapping(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
+
+// injectKickstart adds the default user, if the file doesn't create one of
+// that name, and sshkey commands, ahead of the first %section.
+func injectKickstart(script string, keys []string, cfg *models.AccessConfig) string {
+	target := "root"
+	var add strings.Builder
+	if name := cfg.DefaultUsername; name != "" {
+		target = name
+		if !kickstartHasUser(script, name) {
+			add.WriteString("user --name=" + name)
+			if cfg.DefaultSudo {
+				add.WriteString(" --groups=wheel")
+			}
+			if cfg.DefaultShell != "" {
+				add.WriteString(" --shell=" + cfg.DefaultShell)
+			}
+			if cfg.DefaultPasswordHash != "" {
+				add.WriteString(" --iscrypted --password=" + string(cfg.DefaultPasswordHash))
+			}
+			add.WriteString("\n")
+		}
+	}
+	for _, k := range keys {
+		if !strings.Contains(script, k) {
+			fmt.Fprintf(&add, "sshkey --username=%s \"%s\"\n", target, k)
+		}
+	}
+	if add.Len() == 0 {
+		return script
+	}
+
+	lines := strings.SplitAfter(script, "\n")
+	for i, l := range lines {
+		if t := strings.TrimSpace(l); strings.HasPrefix(t, "%") && !strings.HasPrefix(t, "%include") {
+			return strings.Join(lines[:i], "") + add.String() + strings.Join(lines[i:], "")
+		}
+	}
+	if script != "" && !strings.HasSuffix(script, "\n") {
+		script += "\n"
+	}
+	return script + add.String()
+}
+
+func kickstartHasUser(script, name string) bool {
+	for _, l := range strings.Split(script, "\n") {
+		f := strings.Fields(l)
+		if len(f) == 0 || f[0] != "user" {
+			continue
+		}
+		for i, a := range f[1:] {
+			if a == "--name="+name || (a == "--name" && i+2 < len(f) && f[i+2] == name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// injectPreseed writes the keys for root, and the default user when there
+// is one, from late_command, chained after any late_command the file has.
+func injectPreseed(script string, keys []string, cfg *models.AccessConfig) string {
+	var quoted []string
+	for _, k := range keys {
+		if strings.ContainsAny(k, `'"\`) {
+			continue
+		}
+		quoted = append(quoted, `"`+k+`"`)
+	}
+	if len(quoted) == 0 {
+		return script
+	}
+	list := strings.Join(quoted, " ")
+	homes := []string{"/root"}
+	if cfg.DefaultUsername != "" {
+		homes = append(homes, "/home/"+cfg.DefaultUsername)
+	}
+	var cmds []string
+	for _, home := range homes {
+		cmds = append(cmds, fmt.Sprintf(`in-target sh -c 'd=%s/.ssh; [ -d %s ] || exit 0; mkdir -p $d && printf "%%s\n" %s >> $d/authorized_keys && chmod 700 $d && chmod 600 $d/authorized_keys && chown -R --reference=%s $d'`, home, home, list, home))
+	}
+	late := strings.Join(cmds, "; ")
+
+	lines := strings.Split(script, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), "d-i preseed/late_command string") {
+			if strings.HasSuffix(strings.TrimSpace(l), `\`) {
+				// A continued command is left as written.
+				return script
+			}
+			lines[i] = strings.TrimRight(l, " \t") + "; " + late
+			return strings.Join(lines, "\n")
+		}
+	}
+	if script != "" && !strings.HasSuffix(script, "\n") {
+		script += "\n"
+	}
+	if !strings.Contains(script, "pkgsel/include") {
+		script += "d-i pkgsel/include string openssh-server\n"
+	}
+	return script + "d-i preseed/late_command string " + late + "\n"
+}
+
+// injectIgnition adds the keys to the default user, or to core, Fedora
+// CoreOS's user, creating the user when the config has none of that name.
+func injectIgnition(script string, keys []string, cfg *models.AccessConfig) (string, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(script), &doc); err != nil {
+		return script, nil
+	}
+	name := cfg.DefaultUsername
+	if name == "" {
+		name = "core"
+	}
+	passwd, _ := doc["passwd"].(map[string]interface{})
+	if passwd == nil {
+		passwd = map[string]interface{}{}
+		doc["passwd"] = passwd
+	}
+	users, _ := passwd["users"].([]interface{})
+	var user map[string]interface{}
+	for _, u := range users {
+		if m, ok := u.(map[string]interface{}); ok && m["name"] == name {
+			user = m
+		}
+	}
+	if user == nil {
+		user = map[string]interface{}{"name": name}
+		if name == cfg.DefaultUsername && cfg.DefaultPasswordHash != "" {
+			user["passwordHash"] = string(cfg.DefaultPasswordHash)
+		}
+		if name == cfg.DefaultUsername && cfg.DefaultSudo {
+			user["groups"] = []interface{}{"wheel", "sudo"}
+		}
+		users = append(users, user)
+		passwd["users"] = users
+	}
+	existing, _ := user["sshAuthorizedKeys"].([]interface{})
+	have := map[string]bool{}
+	for _, k := range existing {
+		if s, ok := k.(string); ok {
+			have[s] = true
+		}
+	}
+	for _, k := range keys {
+		if !have[k] {
+			existing = append(existing, k)
+		}
+	}
+	user["sshAuthorizedKeys"] = existing
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
@@ -0,0 +1,144 @@
+package autoinstall
+
+import (
+	"bytes"
+	"encoding/xml"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"bootimus/internal/models"
+)
+
+// TemplateData is what a script written as a Go template sees, e.g.
+// {{.Hostname}} or {{.Vars.rack}}. Secrets are not here: they stay
+// placeholders such as {{DEFAULT_PASSWORD_HASH}} and are substituted after
+// the template runs.
+type TemplateData struct {
+	MAC           string
+	Hostname      string
+	ClientName    string
+	IP            string
+	ServerAddr    string
+	BaseURL       string
+	ImageName     string
+	ImageFilename string
+	NTPServer     string
+	HTTPProxy     string
+	HTTPSProxy    string
+	NoProxy       string
+	ProxyPACURL   string
+	FirstbootURL  string
+	CallbackURL   string
+	DefaultUser   string
+	Group         string            // the client's group name
+	Tags          []string          // the client's tags
+	Vars          map[string]string // the client's auto-install variables
+}
+
+// NewTemplateData fills TemplateData from the placeholder values rendered
+// for a request, and client, which may be nil.
+func NewTemplateData(vars map[string]string, client *models.Client, group string) *TemplateData {
+	d := &TemplateData{
+		MAC:           vars["{{MAC}}"],
+		Hostname:      vars["{{HOSTNAME}}"],
+		ClientName:    vars["{{CLIENT_NAME}}"],
+		IP:            vars["{{IP}}"],
+		ServerAddr:    vars["{{SERVER_ADDR}}"],
+		BaseURL:       vars["{{BASE_URL}}"],
+		ImageName:     vars["{{IMAGE_NAME}}"],
+		ImageFilename: vars["{{IMAGE_FILENAME}}"],
+		NTPServer:     vars["{{NTP_SERVER}}"],
+		HTTPProxy:     vars["{{HTTP_PROXY}}"],
+		HTTPSProxy:    vars["{{HTTPS_PROXY}}"],
+		NoProxy:       vars["{{NO_PROXY}}"],
+		ProxyPACURL:   vars["{{PROXY_PAC_URL}}"],
+		FirstbootURL:  vars["{{FIRSTBOOT_URL}}"],
+		CallbackURL:   vars["{{CALLBACK_URL}}"],
+		DefaultUser:   vars["{{DEFAULT_USER}}"],
+		Group:         group,
+		Vars:          map[string]string{},
+	}
+	if client != nil {
+		d.Tags = client.Tags
+		for k, v := range client.AutoInstallVars {
+			d.Vars[k] = v
+		}
+	}
+	return d
+}
+
+var (
+	// templateActionRe spots Go template actions: ones that start with a
+	// field ({{.MAC}}) or keyword ({{if ...}}), or pass a field to a
+	// function ({{upper .Hostname}}). Placeholders ({{MAC}}) and Jinja
+	// expressions in cloud-init templates ({{ v1.local_hostname }}) don't
+	// match, so those scripts are left alone.
+	templateActionRe = regexp.MustCompile(`\{\{-?\s*(\.|(if|range|with|else|end|define|template|block)\b|[^}]*[\s(|]\.[A-Z])`)
+	varNameRe        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// IsTemplate reports whether script uses Go template actions.
+func IsTemplate(script string) bool {
+	return templateActionRe.MatchString(script)
+}
+
+// ValidVarName reports whether name can be used as {{.Vars.name}}.
+func ValidVarName(name string) bool {
+	return varNameRe.MatchString(name)
+}
+
+var templateFuncs = template.FuncMap{
+	"default": func(def, v string) string {
+		if v == "" {
+			return def
+		}
+		return v
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"xml": func(v string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(v))
+		return b.String()
+	},
+}
+
+var templateLineRe = regexp.MustCompile(`^template: script:(\d+):(?:\d+:)? ?(.*)$`)
+
+func parseTemplate(script string) (*template.Template, error) {
+	protected := scriptPlaceholderRe.ReplaceAllStringFunc(script, func(p string) string {
+		return `{{"` + p + `"}}`
+	})
+	return template.New("script").Funcs(templateFuncs).Option("missingkey=error").Parse(protected)
+}
+
+// validateTemplate checks template syntax. The script's own format can only
+// be checked once it is rendered for a client.
+func validateTemplate(script string) []ScriptError {
+	if _, err := parseTemplate(script); err != nil {
+		if m := templateLineRe.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			return []ScriptError{{line, m[2]}}
+		}
+		return []ScriptError{{0, err.Error()}}
+	}
+	return nil
+}
+
+// RenderTemplate runs script as a Go template. Placeholders in it are
+// passed through untouched for the caller to substitute. A variable the
+// client doesn't have is an error rather than an empty value, so a
+// half-configured machine isn't installed.
+func RenderTemplate(script string, data *TemplateData) (string, error) {
+	t, err := parseTemplate(script)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,188 @@
+package autoinstall
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// unattendSchema is the part of the Windows unattend schema the generators
+// write, by pass/component/setting path. Leaves carry the type of their
+// value; elements that only hold others are added as "" from their leaves.
+var unattendSchema = func() map[string]string {
+	s := map[string]string{}
+	add := func(component string, leaves map[string]string) {
+		s[component] = ""
+		for path, kind := range leaves {
+			full := component + "/" + path
+			s[full] = kind
+			for i := strings.LastIndex(full, "/"); i > len(component); i = strings.LastIndex(full[:i], "/") {
+				if _, ok := s[full[:i]]; !ok {
+					s[full[:i]] = ""
+				}
+			}
+		}
+	}
+	locale := map[string]string{
+		"InputLocale":  "string",
+		"SystemLocale": "string",
+		"UILanguage":   "string",
+		"UserLocale":   "string",
+	}
+	add("windowsPE/Microsoft-Windows-International-Core-WinPE", locale)
+	add("windowsPE/Microsoft-Windows-International-Core-WinPE", map[string]string{
+		"SetupUILanguage/UILanguage": "string",
+	})
+	add("windowsPE/Microsoft-Windows-Setup", map[string]string{
+		"DiskConfiguration/Disk/DiskID":                                       "int",
+		"DiskConfiguration/Disk/WillWipeDisk":                                 "bool",
+		"DiskConfiguration/Disk/CreatePartitions/CreatePartition/Order":       "int",
+		"DiskConfiguration/Disk/CreatePartitions/CreatePartition/Type":        "enum:Primary,EFI,MSR,Extended,Logical",
+		"DiskConfiguration/Disk/CreatePartitions/CreatePartition/Size":        "int",
+		"DiskConfiguration/Disk/CreatePartitions/CreatePartition/Extend":      "bool",
+		"DiskConfiguration/Disk/ModifyPartitions/ModifyPartition/Order":       "int",
+		"DiskConfiguration/Disk/ModifyPartitions/ModifyPartition/PartitionID": "int",
+		"DiskConfiguration/Disk/ModifyPartitions/ModifyPartition/Label":       "string",
+		"DiskConfiguration/Disk/ModifyPartitions/ModifyPartition/Format":      "enum:NTFS,FAT32",
+		"DiskConfiguration/Disk/ModifyPartitions/ModifyPartition/TypeID":      "string",
+		"DiskConfiguration/Disk/ModifyPartitions/ModifyPartition/Letter":      "string",
+		"DiskConfiguration/Disk/ModifyPartitions/ModifyPartition/Active":      "bool",
+		"ImageInstall/OSImage/InstallFrom/MetaData/Key":                       "string",
+		"ImageInstall/OSImage/InstallFrom/MetaData/Value":                     "string",
+		"ImageInstall/OSImage/InstallTo/DiskID":                               "int",
+		"ImageInstall/OSImage/InstallTo/PartitionID":                          "int",
+		"UserData/AcceptEula":            "bool",
+		"UserData/Organization":          "string",
+		"UserData/ProductKey/Key":        "string",
+		"UserData/ProductKey/WillShowUI": "enum:Always,OnError,Never",
+	})
+	add("specialize/Microsoft-Windows-Shell-Setup", map[string]string{
+		"ComputerName": "string",
+		"TimeZone":     "string",
+	})
+	add("specialize/Microsoft-Windows-UnattendedJoin", map[string]string{
+		"Identification/Credentials/Domain":   "string",
+		"Identification/Credentials/Username": "string",
+		"Identification/Credentials/Password": "string",
+		"Identification/JoinDomain":           "string",
+		"Identification/MachineObjectOU":      "string",
+	})
+	add("oobeSystem/Microsoft-Windows-International-Core", locale)
+	add("oobeSystem/Microsoft-Windows-Shell-Setup", map[string]string{
+		"OOBE/HideEULAPage":                                          "bool",
+		"OOBE/HideOnlineAccountScreens":                              "bool",
+		"OOBE/HideWirelessSetupInOOBE":                               "bool",
+		"OOBE/ProtectYourPC":                                         "enum:1,2,3",
+		"UserAccounts/AdministratorPassword/Value":                   "string",
+		"UserAccounts/AdministratorPassword/PlainText":               "bool",
+		"UserAccounts/LocalAccounts/LocalAccount/Name":               "string",
+		"UserAccounts/LocalAccounts/LocalAccount/Group":              "string",
+		"UserAccounts/LocalAccounts/LocalAccount/Password/Value":     "string",
+		"UserAccounts/LocalAccounts/LocalAccount/Password/PlainText": "bool",
+		"AutoLogon/Enabled":                                          "bool",
+		"AutoLogon/LogonCount":                                       "int",
+		"AutoLogon/Username":                                         "string",
+		"AutoLogon/Password/Value":                                   "string",
+		"AutoLogon/Password/PlainText":                               "bool",
+		"FirstLogonCommands/SynchronousCommand/Order":                "int",
+		"FirstLogonCommands/SynchronousCommand/CommandLine":          "string",
+	})
+	return s
+}()
+
+// unattendComponentAttrs are the fixed attributes every component in a
+// generated answer file carries.
+var unattendComponentAttrs = map[string]string{
+	"publicKeyToken": "31bf3856ad364e35",
+	"language":       "neutral",
+	"versionScope":   "nonSxS",
+}
+
+// checkUnattendSchema checks a generated answer file against unattendSchema:
+// each component belongs in its pass, each setting belongs in its component,
+// and values have the setting's type. It is stricter than validateUnattend,
+// which also has to accept settings hand-written files may use.
+func checkUnattendSchema(doc string) []ScriptError {
+	dec := xml.NewDecoder(strings.NewReader(doc))
+	var errs []ScriptError
+	var path []string // pass, component, settings...
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return errs
+		}
+		if err != nil {
+			line, _ := dec.InputPos()
+			return append(errs, ScriptError{line, err.Error()})
+		}
+		line, _ := dec.InputPos()
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "unattend":
+				continue
+			case t.Name.Local == "settings" && len(path) == 0:
+				path = append(path, xmlAttr(t, "pass"))
+				continue
+			case t.Name.Local == "component" && len(path) == 1:
+				for attr, want := range unattendComponentAttrs {
+					if got := xmlAttr(t, attr); got != want {
+						errs = append(errs, ScriptError{line, fmt.Sprintf("component %s has %s %q, want %q", xmlAttr(t, "name"), attr, got, want)})
+					}
+				}
+				path = append(path, xmlAttr(t, "name"))
+			default:
+				path = append(path, t.Name.Local)
+			}
+			if _, ok := unattendSchema[strings.Join(path, "/")]; !ok {
+				parent := strings.Join(path[:len(path)-1], "/")
+				errs = append(errs, ScriptError{line, fmt.Sprintf("%s is not allowed in %s", path[len(path)-1], parent)})
+			}
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if t.Name.Local == "unattend" || len(path) == 0 {
+				continue
+			}
+			if kind := unattendSchema[strings.Join(path, "/")]; kind != "" {
+				if msg := checkUnattendValue(kind, strings.TrimSpace(text.String())); msg != "" {
+					errs = append(errs, ScriptError{line, t.Name.Local + " " + msg})
+				}
+			}
+			path = path[:len(path)-1]
+			text.Reset()
+		}
+	}
+}
+
+// checkUnattendValue describes what is wrong with a setting's value, or
+// returns "".
+func checkUnattendValue(kind, value string) string {
+	switch {
+	case kind == "bool":
+		if value != "true" && value != "false" {
+			return "must be true or false"
+		}
+	case kind == "int":
+		if _, err := strconv.Atoi(value); err != nil {
+			return "must be a number"
+		}
+	case strings.HasPrefix(kind, "enum:"):
+		allowed := strings.Split(strings.TrimPrefix(kind, "enum:"), ",")
+		for _, a := range allowed {
+			if value == a {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(allowed, ", ")
+	case kind == "string":
+		if value == "" {
+			return "must not be empty"
+		}
+	}
+	return ""
+}
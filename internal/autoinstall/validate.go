@@ -0,0 +1,346 @@
+package autoinstall
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ScriptError is a problem found in a stored auto-install script. Line is
+// 1-based; 0 means the problem is not tied to a particular line.
+type ScriptError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (e ScriptError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return e.Message
+}
+
+var (
+	scriptPlaceholderRe = regexp.MustCompile(`\{\{[A-Z_]+\}\}`)
+	yamlLineRe          = regexp.MustCompile(`line (\d+): (.*)`)
+)
+
+// ValidateScript checks a script against the syntax of its type. Template
+// placeholders are masked first so they never trip the parsers. A Go
+// template only has its template syntax checked.
+func ValidateScript(scriptType, script string) []ScriptError {
+	if IsTemplate(script) {
+		return validateTemplate(script)
+	}
+	script = scriptPlaceholderRe.ReplaceAllStringFunc(script, func(p string) string {
+		return strings.Trim(p, "{}")
+	})
+	switch scriptType {
+	case "autoinstall":
+		return validateCloudConfig(script)
+	case "kickstart":
+		return validateKickstart(script)
+	case "preseed":
+		return validatePreseed(script)
+	case "autounattend":
+		return validateUnattend(script)
+	}
+	return nil
+}
+
+func validateCloudConfig(script string) []ScriptError {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(script), &doc); err != nil {
+		msg := strings.TrimPrefix(err.Error(), "yaml: ")
+		if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			return []ScriptError{{line, m[2]}}
+		}
+		return []ScriptError{{0, msg}}
+	}
+	if len(doc.Content) == 0 {
+		return []ScriptError{{0, "document is empty"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return []ScriptError{{root.Line, "top level must be a mapping"}}
+	}
+
+	var errs []ScriptError
+	if ai := mappingValue(root, "autoinstall"); ai != nil {
+		errs = append(errs, checkAutoinstallSection(ai)...)
+	} else if mappingValue(root, "version") != nil && mappingValue(root, "identity") != nil {
+		// Bare autoinstall config without the wrapping key.
+		errs = append(errs, checkAutoinstallSection(root)...)
+	} else {
+		if !strings.HasPrefix(strings.TrimSpace(script), "#cloud-config") {
+			errs = append(errs, ScriptError{1, "cloud-init user-data must start with #cloud-config"})
+		}
+		errs = append(errs, checkKinds(root, map[string]yaml.Kind{
+			"users":               yaml.SequenceNode,
+			"packages":            yaml.SequenceNode,
+			"runcmd":              yaml.SequenceNode,
+			"bootcmd":             yaml.SequenceNode,
+			"write_files":         yaml.SequenceNode,
+			"ssh_authorized_keys": yaml.SequenceNode,
+			"chpasswd":            yaml.MappingNode,
+		})...)
+	}
+	return errs
+}
+
+var autoinstallKeys = map[string]yaml.Kind{
+	"version":              yaml.ScalarNode,
+	"interactive-sections": yaml.SequenceNode,
+	"early-commands":       yaml.SequenceNode,
+	"locale":               yaml.ScalarNode,
+	"refresh-installer":    yaml.MappingNode,
+	"keyboard":             yaml.MappingNode,
+	"source":               yaml.MappingNode,
+	"network":              yaml.MappingNode,
+	"proxy":                yaml.ScalarNode,
+	"apt":                  yaml.MappingNode,
+	"storage":              yaml.MappingNode,
+	"identity":             yaml.MappingNode,
+	"active-directory":     yaml.MappingNode,
+	"ubuntu-pro":           yaml.MappingNode,
+	"ssh":                  yaml.MappingNode,
+	"codecs":               yaml.MappingNode,
+	"drivers":              yaml.MappingNode,
+	"oem":                  yaml.MappingNode,
+	"timezone":             yaml.ScalarNode,
+	"updates":              yaml.ScalarNode,
+	"shutdown":             yaml.ScalarNode,
+	"snaps":                yaml.SequenceNode,
+	"debconf-selections":   yaml.ScalarNode,
+	"packages":             yaml.SequenceNode,
+	"kernel":               yaml.MappingNode,
+	"kernel-crash-dumps":   yaml.MappingNode,
+	"late-commands":        yaml.SequenceNode,
+	"error-commands":       yaml.SequenceNode,
+	"reporting":            yaml.MappingNode,
+	"user-data":            yaml.MappingNode,
+}
+
+func checkAutoinstallSection(ai *yaml.Node) []ScriptError {
+	if ai.Kind != yaml.MappingNode {
+		return []ScriptError{{ai.Line, "autoinstall must be a mapping"}}
+	}
+	var errs []ScriptError
+	for i := 0; i+1 < len(ai.Content); i += 2 {
+		key := ai.Content[i]
+		if _, ok := autoinstallKeys[key.Value]; !ok {
+			errs = append(errs, ScriptError{key.Line, fmt.Sprintf("unknown autoinstall key %q", key.Value)})
+		}
+	}
+	errs = append(errs, checkKinds(ai, autoinstallKeys)...)
+
+	if v := mappingValue(ai, "version"); v == nil {
+		errs = append(errs, ScriptError{ai.Line, "autoinstall.version is required"})
+	} else if v.Value != "1" {
+		errs = append(errs, ScriptError{v.Line, "autoinstall.version must be 1"})
+	}
+	if id := mappingValue(ai, "identity"); id != nil && id.Kind == yaml.MappingNode {
+		for _, k := range []string{"hostname", "username", "password"} {
+			if mappingValue(id, k) == nil {
+				errs = append(errs, ScriptError{id.Line, "identity." + k + " is required"})
+			}
+		}
+	} else if mappingValue(ai, "user-data") == nil && mappingValue(ai, "identity") == nil && mappingValue(ai, "interactive-sections") == nil {
+		errs = append(errs, ScriptError{ai.Line, "either identity or user-data is required"})
+	}
+	return errs
+}
+
+func checkKinds(m *yaml.Node, kinds map[string]yaml.Kind) []ScriptError {
+	var errs []ScriptError
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key, val := m.Content[i], m.Content[i+1]
+		want, ok := kinds[key.Value]
+		if !ok || val.Kind == want || val.Tag == "!!null" {
+			continue
+		}
+		errs = append(errs, ScriptError{val.Line, fmt.Sprintf("%s must be a %s", key.Value, kindName(want))})
+	}
+	return errs
+}
+
+func kindName(k yaml.Kind) string {
+	switch k {
+	case yaml.SequenceNode:
+		return "list"
+	case yaml.MappingNode:
+		return "mapping"
+	}
+	return "scalar"
+}
+
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+var kickstartCommands = map[string]bool{
+	"authselect": true, "autopart": true, "autostep": true, "bootloader": true, "btrfs": true,
+	"cdrom": true, "clearpart": true, "cmdline": true, "device": true, "driverdisk": true,
+	"eula": true, "fcoe": true, "firewall": true, "firstboot": true, "graphical": true,
+	"group": true, "halt": true, "harddrive": true, "ignoredisk": true, "install": true,
+	"iscsi": true, "iscsiname": true, "keyboard": true, "lang": true, "liveimg": true,
+	"logging": true, "logvol": true, "mediacheck": true, "module": true, "mount": true,
+	"network": true, "nfs": true, "ostreesetup": true, "ostreecontainer": true, "part": true,
+	"partition": true, "poweroff": true, "raid": true, "realm": true, "reboot": true,
+	"repo": true, "reqpart": true, "rescue": true, "rhsm": true, "rootpw": true,
+	"selinux": true, "services": true, "shutdown": true, "skipx": true, "snapshot": true,
+	"sshkey": true, "sshpw": true, "syspurpose": true, "text": true, "timesource": true,
+	"timezone": true, "updates": true, "url": true, "user": true, "vnc": true,
+	"volgroup": true, "xconfig": true, "zerombr": true, "zfcp": true, "zipl": true,
+	"%include": true, "%ksappend": true,
+}
+
+var kickstartSections = map[string]bool{
+	"%packages": true, "%pre": true, "%pre-install": true, "%post": true,
+	"%onerror": true, "%traceback": true, "%addon": true, "%anaconda": true,
+}
+
+func validateKickstart(script string) []ScriptError {
+	var errs []ScriptError
+	section, sectionLine := "", 0
+	for i, raw := range strings.Split(script, "\n") {
+		line := strings.TrimSpace(raw)
+		n := i + 1
+		if section != "" {
+			if line == "%end" {
+				section = ""
+				continue
+			}
+			if f := strings.Fields(line); len(f) > 0 && kickstartSections[f[0]] {
+				errs = append(errs, ScriptError{n, fmt.Sprintf("%s starts before %s (line %d) is closed with %%end", f[0], section, sectionLine)})
+				section, sectionLine = f[0], n
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmd := strings.Fields(line)[0]
+		switch {
+		case kickstartSections[cmd]:
+			section, sectionLine = cmd, n
+		case cmd == "%end":
+			errs = append(errs, ScriptError{n, "%end without an open section"})
+		case !kickstartCommands[cmd]:
+			errs = append(errs, ScriptError{n, fmt.Sprintf("unknown kickstart command %q", cmd)})
+		}
+	}
+	if section != "" {
+		errs = append(errs, ScriptError{sectionLine, section + " is never closed with %end"})
+	}
+	return errs
+}
+
+var preseedTypes = map[string]bool{
+	"string": true, "boolean": true, "select": true, "multiselect": true, "note": true,
+	"password": true, "text": true, "title": true, "error": true, "seen": true,
+}
+
+func validatePreseed(script string) []ScriptError {
+	var errs []ScriptError
+	lines := strings.Split(script, "\n")
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		line := strings.TrimSpace(lines[i])
+		// A trailing backslash continues the value onto the next line.
+		for strings.HasSuffix(line, `\`) && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, `\`) + " " + strings.TrimSpace(lines[i])
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) < 3 {
+			errs = append(errs, ScriptError{n, "expected <owner> <question> <type> <value>"})
+			continue
+		}
+		if !preseedTypes[f[2]] {
+			errs = append(errs, ScriptError{n, fmt.Sprintf("unknown question type %q", f[2])})
+			continue
+		}
+		if f[2] == "boolean" && (len(f) != 4 || (f[3] != "true" && f[3] != "false")) {
+			errs = append(errs, ScriptError{n, "boolean value must be true or false"})
+		}
+	}
+	return errs
+}
+
+var unattendPasses = map[string]bool{
+	"windowsPE": true, "offlineServicing": true, "generalize": true, "specialize": true,
+	"auditSystem": true, "auditUser": true, "oobeSystem": true,
+}
+
+func validateUnattend(script string) []ScriptError {
+	const ns = "urn:schemas-microsoft-com:unattend"
+	dec := xml.NewDecoder(strings.NewReader(script))
+	var errs []ScriptError
+	var stack []string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if serr, ok := err.(*xml.SyntaxError); ok {
+				errs = append(errs, ScriptError{serr.Line, serr.Msg})
+			} else if err != io.EOF {
+				line, _ := dec.InputPos()
+				errs = append(errs, ScriptError{line, err.Error()})
+			}
+			break
+		}
+		line, _ := dec.InputPos()
+		switch t := tok.(type) {
+		case xml.StartElement:
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			stack = append(stack, t.Name.Local)
+			switch {
+			case parent == "" && (t.Name.Local != "unattend" || t.Name.Space != ns):
+				errs = append(errs, ScriptError{line, "root element must be <unattend xmlns=\"" + ns + "\">"})
+			case parent == "unattend" && t.Name.Local == "settings":
+				if pass := xmlAttr(t, "pass"); !unattendPasses[pass] {
+					errs = append(errs, ScriptError{line, fmt.Sprintf("unknown configuration pass %q", pass)})
+				}
+			case parent == "settings" && t.Name.Local == "component":
+				if xmlAttr(t, "name") == "" {
+					errs = append(errs, ScriptError{line, "component is missing its name attribute"})
+				}
+				if xmlAttr(t, "processorArchitecture") == "" {
+					errs = append(errs, ScriptError{line, "component is missing its processorArchitecture attribute"})
+				}
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(errs) == 0 && len(stack) == 0 && !strings.Contains(script, "<unattend") {
+		errs = append(errs, ScriptError{0, "document has no <unattend> element"})
+	}
+	return errs
+}
+
+func xmlAttr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,365 @@
+package autoinstall
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode/utf16"
+)
+
+// WindowsAnswer is the structured form behind a generated autounattend.xml.
+type WindowsAnswer struct {
+	Edition         string `json:"edition"`          // e.g. "Windows 11 Pro"; matched against /IMAGE/NAME
+	ImageIndex      int    `json:"image_index"`      // alternative to Edition
+	UILanguage      string `json:"ui_language"`      // e.g. "en-GB"
+	InputLocale     string `json:"input_locale"`     // defaults to UILanguage
+	SystemLocale    string `json:"system_locale"`    // defaults to UILanguage
+	TimeZone        string `json:"time_zone"`        // Windows zone name, e.g. "GMT Standard Time"
+	ProductKey      string `json:"product_key"`      // empty = generic/KMS
+	PartitionLayout string `json:"partition_layout"` // "uefi" (GPT) or "bios" (MBR)
+	DiskID          int    `json:"disk_id"`
+	ComputerName    string `json:"computer_name"` // "*" = random; placeholders like {{HOSTNAME}} allowed
+	Organization    string `json:"organization"`
+	AdminUsername   string `json:"admin_username"` // local account created in OOBE
+	AdminPassword   string `json:"admin_password"`
+	AutoLogon       bool   `json:"auto_logon"`
+	DomainJoin      bool   `json:"domain_join"`
+	Domain          string `json:"domain"`
+	DomainUsername  string `json:"domain_username"`
+	DomainPassword  string `json:"domain_password"`
+	MachineOU       string `json:"machine_ou"`
+
+	// Run in order at the first logon; they need AutoLogon.
+	FirstLogonCommands []string `json:"-"`
+}
+
+var (
+	localeRe     = regexp.MustCompile(`^[a-z]{2,3}-[A-Z]{2}$`)
+	productKeyRe = regexp.MustCompile(`^([A-Z0-9]{5}-){4}[A-Z0-9]{5}$`)
+	computerRe   = regexp.MustCompile(`^(\*|[A-Za-z0-9-]{1,15}|\{\{[A-Z_]+\}\})$`)
+)
+
+// FieldError ties a validation failure to the structured field that caused it.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string { return e.Field + ": " + e.Message }
+
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (a *WindowsAnswer) normalise() {
+	if a.UILanguage == "" {
+		a.UILanguage = "en-US"
+	}
+	if a.InputLocale == "" {
+		a.InputLocale = a.UILanguage
+	}
+	if a.SystemLocale == "" {
+		a.SystemLocale = a.UILanguage
+	}
+	if a.TimeZone == "" {
+		a.TimeZone = "UTC"
+	}
+	if a.PartitionLayout == "" {
+		a.PartitionLayout = "uefi"
+	}
+	if a.ComputerName == "" {
+		a.ComputerName = "*"
+	}
+	if a.AdminUsername == "" {
+		a.AdminUsername = "Administrator"
+	}
+	a.ProductKey = strings.ToUpper(strings.TrimSpace(a.ProductKey))
+}
+
+func (a *WindowsAnswer) Validate() error {
+	a.normalise()
+	var errs ValidationErrors
+	add := func(field, msg string) { errs = append(errs, FieldError{field, msg}) }
+
+	if a.Edition == "" && a.ImageIndex <= 0 {
+		add("edition", "either edition or image_index is required")
+	}
+	for _, l := range []struct{ field, value string }{
+		{"ui_language", a.UILanguage},
+		{"input_locale", a.InputLocale},
+		{"system_locale", a.SystemLocale},
+	} {
+		if !localeRe.MatchString(l.value) {
+			add(l.field, fmt.Sprintf("%q is not a locale like en-US", l.value))
+		}
+	}
+	if a.ProductKey != "" && !productKeyRe.MatchString(a.ProductKey) {
+		add("product_key", "must be five groups of five characters")
+	}
+	if a.PartitionLayout != "uefi" && a.PartitionLayout != "bios" {
+		add("partition_layout", "must be uefi or bios")
+	}
+	if a.DiskID < 0 {
+		add("disk_id", "must not be negative")
+	}
+	if !computerRe.MatchString(a.ComputerName) {
+		add("computer_name", "must be *, a placeholder, or 1-15 letters, digits and hyphens")
+	}
+	if a.AdminPassword == "" {
+		add("admin_password", "is required")
+	}
+	if a.DomainJoin {
+		if a.Domain == "" {
+			add("domain", "is required for domain join")
+		}
+		if a.DomainUsername == "" || a.DomainPassword == "" {
+			add("domain_username", "credentials are required for domain join")
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// AdminPasswordVar and DomainPasswordVar stand in for the passwords in a
+// generated answer file. The passwords themselves are kept encrypted with
+// the image and substituted when the file is served.
+const (
+	AdminPasswordVar  = "{{ADMIN_PASSWORD}}"
+	DomainPasswordVar = "{{DOMAIN_PASSWORD}}"
+)
+
+// adminPasswordVars are the placeholders for the admin password in its
+// obfuscated form, by the suffix Windows Setup hashes it with.
+var adminPasswordVars = map[string]string{
+	"Password":              "{{ADMIN_PASSWORD_ENCODED}}",
+	"AdministratorPassword": "{{ADMINISTRATOR_PASSWORD_ENCODED}}",
+}
+
+// UnattendSecretVars turns the secrets stored with a generated answer file
+// into the values its placeholders are replaced with: XML-escaped, and the
+// admin password also in each obfuscated form.
+func UnattendSecretVars(secrets map[string]string) map[string]string {
+	vars := make(map[string]string, len(secrets)+len(adminPasswordVars))
+	for k, v := range secrets {
+		vars[k] = xmlEscape(v)
+	}
+	if pw, ok := secrets[AdminPasswordVar]; ok {
+		for suffix, placeholder := range adminPasswordVars {
+			vars[placeholder] = unattendPassword(pw, suffix)
+		}
+	}
+	return vars
+}
+
+// unattendPassword produces the obfuscated form Windows Setup expects when
+// PlainText is false: base64(UTF-16LE(password + suffix)). The admin
+// password placeholder becomes the placeholder for its obfuscated form.
+func unattendPassword(password, suffix string) string {
+	if password == AdminPasswordVar {
+		return adminPasswordVars[suffix]
+	}
+	units := utf16.Encode([]rune(password + suffix))
+	buf := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(buf[i*2:], u)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+var windowsTemplate = template.Must(template.New("autounattend").Funcs(template.FuncMap{
+	"x":   xmlEscape,
+	"pw":  unattendPassword,
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<?xml version="1.0" encoding="utf-8"?>
+<unattend xmlns="urn:schemas-microsoft-com:unattend" xmlns:wcm="http://schemas.microsoft.com/WMIConfig/2002/State">
+  <settings pass="windowsPE">
+    <component name="Microsoft-Windows-International-Core-WinPE" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <SetupUILanguage><UILanguage>{{x .UILanguage}}</UILanguage></SetupUILanguage>
+      <InputLocale>{{x .InputLocale}}</InputLocale>
+      <SystemLocale>{{x .SystemLocale}}</SystemLocale>
+      <UILanguage>{{x .UILanguage}}</UILanguage>
+      <UserLocale>{{x .SystemLocale}}</UserLocale>
+    </component>
+    <component name="Microsoft-Windows-Setup" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <DiskConfiguration>
+        <Disk wcm:action="add">
+          <DiskID>{{.DiskID}}</DiskID>
+          <WillWipeDisk>true</WillWipeDisk>
+          <CreatePartitions>
+{{- if eq .PartitionLayout "uefi"}}
+            <CreatePartition wcm:action="add"><Order>1</Order><Type>Primary</Type><Size>500</Size></CreatePartition>
+            <CreatePartition wcm:action="add"><Order>2</Order><Type>EFI</Type><Size>100</Size></CreatePartition>
+            <CreatePartition wcm:action="add"><Order>3</Order><Type>MSR</Type><Size>16</Size></CreatePartition>
+            <CreatePartition wcm:action="add"><Order>4</Order><Type>Primary</Type><Extend>true</Extend></CreatePartition>
+{{- else}}
+            <CreatePartition wcm:action="add"><Order>1</Order><Type>Primary</Type><Size>500</Size></CreatePartition>
+            <CreatePartition wcm:action="add"><Order>2</Order><Type>Primary</Type><Extend>true</Extend></CreatePartition>
+{{- end}}
+          </CreatePartitions>
+          <ModifyPartitions>
+{{- if eq .PartitionLayout "uefi"}}
+            <ModifyPartition wcm:action="add"><Order>1</Order><PartitionID>1</PartitionID><Label>WINRE</Label><Format>NTFS</Format><TypeID>DE94BBA4-06D1-4D40-A16A-BFD50179D6AC</TypeID></ModifyPartition>
+            <ModifyPartition wcm:action="add"><Order>2</Order><PartitionID>2</PartitionID><Label>System</Label><Format>FAT32</Format></ModifyPartition>
+            <ModifyPartition wcm:action="add"><Order>3</Order><PartitionID>3</PartitionID></ModifyPartition>
+            <ModifyPartition wcm:action="add"><Order>4</Order><PartitionID>4</PartitionID><Label>Windows</Label><Letter>C</Letter><Format>NTFS</Format></ModifyPartition>
+{{- else}}
+            <ModifyPartition wcm:action="add"><Order>1</Order><PartitionID>1</PartitionID><Label>System</Label><Format>NTFS</Format><Active>true</Active></ModifyPartition>
+            <ModifyPartition wcm:action="add"><Order>2</Order><PartitionID>2</PartitionID><Label>Windows</Label><Letter>C</Letter><Format>NTFS</Format></ModifyPartition>
+{{- end}}
+          </ModifyPartitions>
+        </Disk>
+      </DiskConfiguration>
+      <ImageInstall>
+        <OSImage>
+          <InstallFrom>
+            <MetaData wcm:action="add">
+{{- if .Edition}}
+              <Key>/IMAGE/NAME</Key>
+              <Value>{{x .Edition}}</Value>
+{{- else}}
+              <Key>/IMAGE/INDEX</Key>
+              <Value>{{.ImageIndex}}</Value>
+{{- end}}
+            </MetaData>
+          </InstallFrom>
+          <InstallTo>
+            <DiskID>{{.DiskID}}</DiskID>
+            <PartitionID>{{if eq .PartitionLayout "uefi"}}4{{else}}2{{end}}</PartitionID>
+          </InstallTo>
+        </OSImage>
+      </ImageInstall>
+      <UserData>
+        <AcceptEula>true</AcceptEula>
+{{- if .Organization}}
+        <Organization>{{x .Organization}}</Organization>
+{{- end}}
+{{- if .ProductKey}}
+        <ProductKey><Key>{{x .ProductKey}}</Key><WillShowUI>OnError</WillShowUI></ProductKey>
+{{- end}}
+      </UserData>
+    </component>
+  </settings>
+  <settings pass="specialize">
+    <component name="Microsoft-Windows-Shell-Setup" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <ComputerName>{{x .ComputerName}}</ComputerName>
+      <TimeZone>{{x .TimeZone}}</TimeZone>
+    </component>
+{{- if .DomainJoin}}
+    <component name="Microsoft-Windows-UnattendedJoin" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <Identification>
+        <Credentials>
+          <Domain>{{x .Domain}}</Domain>
+          <Username>{{x .DomainUsername}}</Username>
+          <Password>{{x .DomainPassword}}</Password>
+        </Credentials>
+        <JoinDomain>{{x .Domain}}</JoinDomain>
+{{- if .MachineOU}}
+        <MachineObjectOU>{{x .MachineOU}}</MachineObjectOU>
+{{- end}}
+      </Identification>
+    </component>
+{{- end}}
+  </settings>
+  <settings pass="oobeSystem">
+    <component name="Microsoft-Windows-International-Core" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <InputLocale>{{x .InputLocale}}</InputLocale>
+      <SystemLocale>{{x .SystemLocale}}</SystemLocale>
+      <UILanguage>{{x .UILanguage}}</UILanguage>
+      <UserLocale>{{x .SystemLocale}}</UserLocale>
+    </component>
+    <component name="Microsoft-Windows-Shell-Setup" processorArchitecture="amd64" publicKeyToken="31bf3856ad364e35" language="neutral" versionScope="nonSxS">
+      <OOBE>
+        <HideEULAPage>true</HideEULAPage>
+        <HideOnlineAccountScreens>true</HideOnlineAccountScreens>
+        <HideWirelessSetupInOOBE>true</HideWirelessSetupInOOBE>
+        <ProtectYourPC>3</ProtectYourPC>
+      </OOBE>
+      <UserAccounts>
+{{- if eq .AdminUsername "Administrator"}}
+        <AdministratorPassword>
+          <Value>{{pw .AdminPassword "AdministratorPassword"}}</Value>
+          <PlainText>false</PlainText>
+        </AdministratorPassword>
+{{- else}}
+        <LocalAccounts>
+          <LocalAccount wcm:action="add">
+            <Name>{{x .AdminUsername}}</Name>
+            <Group>Administrators</Group>
+            <Password>
+              <Value>{{pw .AdminPassword "Password"}}</Value>
+              <PlainText>false</PlainText>
+            </Password>
+          </LocalAccount>
+        </LocalAccounts>
+{{- end}}
+      </UserAccounts>
+{{- if .AutoLogon}}
+      <AutoLogon>
+        <Enabled>true</Enabled>
+        <LogonCount>1</LogonCount>
+        <Username>{{x .AdminUsername}}</Username>
+        <Password>
+          <Value>{{pw .AdminPassword "Password"}}</Value>
+          <PlainText>false</PlainText>
+        </Password>
+      </AutoLogon>
+{{- end}}
+{{- if .FirstLogonCommands}}
+      <FirstLogonCommands>
+{{- range $i, $cmd := .FirstLogonCommands}}
+        <SynchronousCommand wcm:action="add">
+          <Order>{{inc $i}}</Order>
+          <CommandLine>{{x $cmd}}</CommandLine>
+        </SynchronousCommand>
+{{- end}}
+      </FirstLogonCommands>
+{{- end}}
+    </component>
+  </settings>
+</unattend>
+`))
+
+// RenderWindows validates the answers and renders an autounattend.xml
+// whose passwords are AdminPasswordVar and DomainPasswordVar. The returned
+// secrets map those placeholders to the passwords.
+func RenderWindows(a *WindowsAnswer) (string, map[string]string, error) {
+	if err := a.Validate(); err != nil {
+		return "", nil, err
+	}
+
+	secrets := map[string]string{AdminPasswordVar: a.AdminPassword}
+	a.AdminPassword = AdminPasswordVar
+	if a.DomainJoin {
+		secrets[DomainPasswordVar] = a.DomainPassword
+		a.DomainPassword = DomainPasswordVar
+	}
+
+	var buf bytes.Buffer
+	if err := windowsTemplate.Execute(&buf, a); err != nil {
+		return "", nil, err
+	}
+	script := buf.String()
+	if errs := append(validateUnattend(script), checkUnattendSchema(script)...); len(errs) > 0 {
+		return "", nil, fmt.Errorf("generated XML is invalid: %w", errs[0])
+	}
+	return script, secrets, nil
+}
@@ -0,0 +1,172 @@
+package autoinstall
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// WindowsPayload is a custom file run at the first logon.
+type WindowsPayload struct {
+	File          string `json:"file"`          // custom file name, fetched from /files/
+	Kind          string `json:"kind"`          // "powershell" (default) or "dsc"
+	Configuration string `json:"configuration"` // dsc: the configuration to apply; defaults to the file's base name
+	Arguments     string `json:"arguments"`     // powershell: passed to the script
+}
+
+// WindowsServerAnswer is a WindowsAnswer that picks the Server edition
+// from its version and options, and adds roles and payloads run at the
+// first logon.
+type WindowsServerAnswer struct {
+	WindowsAnswer
+	ServerVersion     string           `json:"server_version"`     // "2016", "2019", "2022" or "2025"
+	ServerEdition     string           `json:"server_edition"`     // "standard" or "datacenter"
+	DesktopExperience bool             `json:"desktop_experience"` // false = Server Core
+	Roles             []string         `json:"roles"`              // Windows feature names, e.g. "Web-Server"
+	Payloads          []WindowsPayload `json:"payloads"`
+	ReportComplete    bool             `json:"report_complete"` // call /callback/boot-complete when done
+}
+
+var (
+	featureRe    = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	payloadRe    = regexp.MustCompile(`^[A-Za-z0-9._-]+(/[A-Za-z0-9._-]+)*\.ps1$`)
+	dscConfigRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	serverEdRe   = regexp.MustCompile(`^(standard|datacenter)$`)
+	serverVersRe = regexp.MustCompile(`^(2016|2019|2022|2025)$`)
+)
+
+// maxCommandLine is the longest CommandLine Windows Setup accepts in a
+// SynchronousCommand.
+const maxCommandLine = 1024
+
+// serverImageName is the install.wim image name of an edition. 2016 and 2019
+// name images after their edition ID; later releases use display names.
+func serverImageName(version, edition string, desktop bool) string {
+	switch version {
+	case "2016", "2019":
+		id := "SERVER" + strings.ToUpper(edition)
+		if !desktop {
+			id += "CORE"
+		}
+		return fmt.Sprintf("Windows Server %s %s", version, id)
+	}
+	name := fmt.Sprintf("Windows Server %s %s", version, strings.ToUpper(edition[:1])+edition[1:])
+	if desktop {
+		name += " (Desktop Experience)"
+	}
+	return name
+}
+
+func (a *WindowsServerAnswer) Validate() error {
+	var errs ValidationErrors
+	add := func(field, msg string) { errs = append(errs, FieldError{field, msg}) }
+
+	pickedEdition := true
+	a.ServerEdition = strings.ToLower(a.ServerEdition)
+	if a.ServerEdition == "" {
+		a.ServerEdition = "standard"
+	}
+	if a.Edition == "" && a.ImageIndex <= 0 {
+		switch {
+		case !serverVersRe.MatchString(a.ServerVersion):
+			pickedEdition = false
+			add("server_version", "must be 2016, 2019, 2022 or 2025 (or set edition or image_index)")
+		case !serverEdRe.MatchString(a.ServerEdition):
+			pickedEdition = false
+			add("server_edition", "must be standard or datacenter")
+		default:
+			a.Edition = serverImageName(a.ServerVersion, a.ServerEdition, a.DesktopExperience)
+		}
+	}
+	for _, r := range a.Roles {
+		if !featureRe.MatchString(r) {
+			add("roles", fmt.Sprintf("%q is not a Windows feature name", r))
+		}
+	}
+	for i, p := range a.Payloads {
+		field := fmt.Sprintf("payloads[%d]", i)
+		if p.Kind == "" {
+			a.Payloads[i].Kind = "powershell"
+		}
+		switch a.Payloads[i].Kind {
+		case "powershell":
+			if strings.ContainsAny(p.Arguments, "\"\r\n") {
+				add(field+".arguments", "must not contain double quotes or newlines")
+			}
+		case "dsc":
+			if p.Configuration == "" {
+				a.Payloads[i].Configuration = strings.TrimSuffix(path.Base(p.File), ".ps1")
+			}
+			if !dscConfigRe.MatchString(a.Payloads[i].Configuration) {
+				add(field+".configuration", "must be a PowerShell identifier")
+			}
+		default:
+			add(field+".kind", "must be powershell or dsc")
+		}
+		if !payloadRe.MatchString(p.File) {
+			add(field+".file", "must be the name of a .ps1 custom file")
+		}
+	}
+	if err := a.WindowsAnswer.Validate(); err != nil {
+		for _, e := range err.(ValidationErrors) {
+			// A missing edition is already reported against server_version.
+			if e.Field != "edition" || pickedEdition {
+				errs = append(errs, e)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ps runs a PowerShell command from a first-logon command line.
+func ps(command string) string {
+	return `powershell.exe -NoProfile -ExecutionPolicy Bypass -Command "` + command + `"`
+}
+
+func (a *WindowsServerAnswer) firstLogonCommands() []string {
+	var cmds []string
+	if len(a.Roles) > 0 {
+		cmds = append(cmds, ps("Install-WindowsFeature -Name "+strings.Join(a.Roles, ",")+" -IncludeManagementTools"))
+	}
+	for _, p := range a.Payloads {
+		local := `C:\Bootimus\` + strings.ReplaceAll(p.File, "/", `\`)
+		fetch := fmt.Sprintf(`New-Item -Force -ItemType Directory (Split-Path '%s') | Out-Null; Invoke-WebRequest -UseBasicParsing -Uri '{{BASE_URL}}/files/%s' -OutFile '%s'`, local, p.File, local)
+		switch p.Kind {
+		case "dsc":
+			out := `C:\Bootimus\dsc\` + p.Configuration
+			cmds = append(cmds, ps(fmt.Sprintf("%s; . '%s'; %s -OutputPath '%s'; Start-DscConfiguration -Path '%s' -Wait -Force -Verbose",
+				fetch, local, p.Configuration, out, out)))
+		default:
+			cmds = append(cmds, ps(fmt.Sprintf("%s; & '%s' %s", fetch, local, p.Arguments)))
+		}
+	}
+	if a.ReportComplete {
+		cmds = append(cmds, ps(`Invoke-WebRequest -UseBasicParsing -Uri ('{{CALLBACK_URL}}&hostname=' + $env:COMPUTERNAME + '&os=' + [uri]::EscapeDataString((Get-CimInstance Win32_OperatingSystem).Caption))`))
+	}
+	return cmds
+}
+
+// RenderWindowsServer validates the answers and renders an autounattend.xml,
+// with its passwords as secrets as RenderWindows does.
+func RenderWindowsServer(a *WindowsServerAnswer) (string, map[string]string, error) {
+	if err := a.Validate(); err != nil {
+		return "", nil, err
+	}
+
+	a.FirstLogonCommands = a.firstLogonCommands()
+	for i, cmd := range a.FirstLogonCommands {
+		if len(cmd) > maxCommandLine {
+			return "", nil, ValidationErrors{{"payloads", fmt.Sprintf("first-logon command %d is %d characters, over Windows' limit of %d; use shorter file names or arguments", i+1, len(cmd), maxCommandLine)}}
+		}
+	}
+	if len(a.FirstLogonCommands) > 0 {
+		// First-logon commands only run when someone logs on.
+		a.AutoLogon = true
+	}
+
+	return RenderWindows(&a.WindowsAnswer)
+}
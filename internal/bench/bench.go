@@ -0,0 +1,271 @@
+// Package bench drives a running Bootimus server the way a room of PXE
+// clients would: each simulated client fetches the boot menu, downloads a
+// kernel and reads random ranges of an ISO, over and over.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	BaseURL    string // e.g. http://192.168.1.10:8080
+	Token      string // boot token, if the server requires one
+	Clients    int
+	Duration   time.Duration
+	Kernel     string // URL or path on BaseURL; found in the menu if empty
+	ISO        string // likewise
+	RangeSize  int64
+	RangeReads int // per client iteration
+}
+
+// Op is the result of one kind of request.
+type Op struct {
+	Name      string
+	Count     int
+	Errors    int
+	Bytes     int64
+	latencies []time.Duration
+	FirstErr  string
+}
+
+func (o *Op) Percentile(p float64) time.Duration {
+	if len(o.latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(o.latencies)-1) * p / 100)
+	return o.latencies[i]
+}
+
+type Report struct {
+	Elapsed time.Duration
+	Kernel  string
+	ISO     string
+	Ops     []*Op
+}
+
+var (
+	kernelLine  = regexp.MustCompile(`(?m)^kernel\s+(https?://\S+)`)
+	sanbootLine = regexp.MustCompile(`(?m)^sanboot\s.*?(https?://\S+/isos/\S+)`)
+)
+
+type runner struct {
+	cfg    Config
+	client *http.Client
+	mu     sync.Mutex
+	ops    map[string]*Op
+}
+
+// Run benchmarks the server until cfg.Duration has passed or ctx is done.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.Clients < 1 {
+		cfg.Clients = 1
+	}
+	if cfg.RangeSize <= 0 {
+		cfg.RangeSize = 1 << 20
+	}
+	r := &runner{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout:   5 * time.Minute,
+			Transport: &http.Transport{MaxIdleConnsPerHost: cfg.Clients},
+		},
+		ops: map[string]*Op{},
+	}
+
+	menu, err := r.get(ctx, r.url("/menu.ipxe"), "")
+	if err != nil {
+		return nil, fmt.Errorf("fetching the menu: %w", err)
+	}
+	kernel, iso := r.resolve(cfg.Kernel), r.resolve(cfg.ISO)
+	if kernel == "" {
+		if m := kernelLine.FindSubmatch(menu); m != nil {
+			kernel = stripQuery(string(m[1]))
+		}
+	}
+	if iso == "" {
+		if m := sanbootLine.FindSubmatch(menu); m != nil {
+			iso = stripQuery(string(m[1]))
+		}
+	}
+	var isoSize int64
+	if iso != "" {
+		if isoSize, err = r.size(ctx, iso); err != nil {
+			return nil, fmt.Errorf("sizing %s: %w", iso, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Clients; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for ctx.Err() == nil {
+				r.timed(ctx, "menu", r.url("/menu.ipxe"), "")
+				if kernel != "" {
+					r.timed(ctx, "kernel", kernel, "")
+				}
+				if isoSize > cfg.RangeSize {
+					for j := 0; j < cfg.RangeReads && ctx.Err() == nil; j++ {
+						off := rng.Int63n(isoSize - cfg.RangeSize)
+						r.timed(ctx, "iso-range", iso, fmt.Sprintf("bytes=%d-%d", off, off+cfg.RangeSize-1))
+					}
+				}
+			}
+		}(time.Now().UnixNano() + int64(i))
+	}
+	wg.Wait()
+
+	rep := &Report{Elapsed: time.Since(start), Kernel: kernel, ISO: iso}
+	for _, name := range []string{"menu", "kernel", "iso-range"} {
+		if op, ok := r.ops[name]; ok {
+			sort.Slice(op.latencies, func(i, j int) bool { return op.latencies[i] < op.latencies[j] })
+			rep.Ops = append(rep.Ops, op)
+		}
+	}
+	return rep, nil
+}
+
+func stripQuery(u string) string {
+	u, _, _ = strings.Cut(u, "?")
+	return u
+}
+
+func (r *runner) url(path string) string {
+	u := r.cfg.BaseURL + path
+	if r.cfg.Token != "" {
+		u += "?token=" + url.QueryEscape(r.cfg.Token)
+	}
+	return u
+}
+
+func (r *runner) resolve(target string) string {
+	if target == "" || strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return target
+	}
+	return r.cfg.BaseURL + "/" + strings.TrimPrefix(target, "/")
+}
+
+func (r *runner) size(ctx context.Context, u string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s", resp.Status)
+	}
+	return resp.ContentLength, nil
+}
+
+func (r *runner) get(ctx context.Context, u, rng string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rng != "" {
+		req.Header.Set("Range", rng)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// timed fetches u, discarding the body, and records the outcome under name.
+// Requests cut off by the end of the run are not counted.
+func (r *runner) timed(ctx context.Context, name, u, rng string) {
+	start := time.Now()
+	var n int64
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err == nil {
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		var resp *http.Response
+		if resp, err = r.client.Do(req); err == nil {
+			n, err = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if err == nil && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+				err = fmt.Errorf("%s", resp.Status)
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	elapsed := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	op, ok := r.ops[name]
+	if !ok {
+		op = &Op{Name: name}
+		r.ops[name] = op
+	}
+	op.Count++
+	op.Bytes += n
+	op.latencies = append(op.latencies, elapsed)
+	if err != nil {
+		op.Errors++
+		if op.FirstErr == "" {
+			op.FirstErr = err.Error()
+		}
+	}
+}
+
+// Write prints the report as a table.
+func (rep *Report) Write(w io.Writer) {
+	secs := rep.Elapsed.Seconds()
+	fmt.Fprintf(w, "Ran for %v\n", rep.Elapsed.Round(time.Millisecond))
+	if rep.Kernel != "" {
+		fmt.Fprintf(w, "Kernel: %s\n", rep.Kernel)
+	}
+	if rep.ISO != "" {
+		fmt.Fprintf(w, "ISO:    %s\n", rep.ISO)
+	}
+	fmt.Fprintf(w, "\n%-10s %8s %7s %9s %10s %9s %9s %9s %9s\n", "op", "requests", "errors", "req/s", "MB/s", "p50", "p90", "p99", "max")
+	for _, op := range rep.Ops {
+		fmt.Fprintf(w, "%-10s %8d %7d %9.1f %10.1f %9s %9s %9s %9s\n",
+			op.Name, op.Count, op.Errors, float64(op.Count)/secs, float64(op.Bytes)/secs/(1<<20),
+			round(op.Percentile(50)), round(op.Percentile(90)), round(op.Percentile(99)), round(op.Percentile(100)))
+	}
+	for _, op := range rep.Ops {
+		if op.FirstErr != "" {
+			fmt.Fprintf(w, "%s: first error: %s\n", op.Name, op.FirstErr)
+		}
+	}
+}
+
+func round(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return strconv.FormatFloat(d.Seconds(), 'f', 2, 64) + "s"
+	default:
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 1, 64) + "ms"
+	}
+}
@@ -0,0 +1,54 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunFindsTargetsInMenu(t *testing.T) {
+	iso := bytes.Repeat([]byte("x"), 4<<20)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/menu.ipxe":
+			fmt.Fprintf(w, "#!ipxe\nkernel %s/boot/a/vmlinuz ip=dhcp\nsanboot --no-describe --drive 0x80 %s/isos/a.iso?mac=x\n", srv.URL, srv.URL)
+		case r.URL.Path == "/boot/a/vmlinuz":
+			w.Write(make([]byte, 1000))
+		case r.URL.Path == "/isos/a.iso":
+			http.ServeContent(w, r, "a.iso", time.Time{}, bytes.NewReader(iso))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	rep, err := Run(context.Background(), Config{BaseURL: srv.URL, Clients: 4, Duration: 200 * time.Millisecond, RangeSize: 4096, RangeReads: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Kernel != srv.URL+"/boot/a/vmlinuz" || rep.ISO != srv.URL+"/isos/a.iso" {
+		t.Errorf("targets = %q, %q", rep.Kernel, rep.ISO)
+	}
+	if len(rep.Ops) != 3 {
+		t.Fatalf("got %d ops, want menu, kernel and iso-range", len(rep.Ops))
+	}
+	for _, op := range rep.Ops {
+		if op.Count == 0 || op.Errors != 0 {
+			t.Errorf("%s: %d requests, %d errors (%s)", op.Name, op.Count, op.Errors, op.FirstErr)
+		}
+	}
+	if r := rep.Ops[2]; r.Bytes != int64(r.Count)*4096 {
+		t.Errorf("range reads returned %d bytes over %d requests", r.Bytes, r.Count)
+	}
+	var out strings.Builder
+	rep.Write(&out)
+	if !strings.Contains(out.String(), "iso-range") {
+		t.Errorf("report is missing iso-range:\n%s", out.String())
+	}
+}
@@ -0,0 +1,99 @@
+// Package bootmethod holds boot methods added beside the built-in ones
+// (sanboot, kernel, nbd, nfs and remote), so a niche method such as a Xen
+// or VMware auto-deploy chain doesn't mean another case in the menu
+// builder. A provider registers itself from an init function in its own
+// package; importing that package, e.g. from a file under cmd/, builds it
+// in:
+//
+//	func init() { bootmethod.Register(xenProvider{}) }
+//
+// Images whose BootMethod is the provider's name are then booted with the
+// stanza it renders.
+package bootmethod
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"bootimus/internal/models"
+)
+
+// Builtin are the methods the menu builder renders itself; providers can't
+// take their names.
+var Builtin = []string{"sanboot", "kernel", "nbd", "nfs", "remote"}
+
+type Provider interface {
+	// Name is what images store as their BootMethod: lowercase, no spaces.
+	Name() string
+	Description() string
+	// Validate reports why img can't boot this way, or nil if it can.
+	Validate(img *models.Image) error
+	// Script renders the iPXE commands booting the image, ending with
+	// "|| goto failed" on the command that boots.
+	Script(s Stanza) string
+}
+
+// Router is implemented by providers that serve files of their own, such as
+// a generated config. The handler is mounted on the boot HTTP port under
+// /bootmethod/<name>/, with that prefix stripped.
+type Router interface {
+	Handler() http.Handler
+}
+
+// Stanza is what a provider renders an image's boot commands from.
+type Stanza struct {
+	Image      *models.Image
+	BaseURL    string // http://server:port to fetch from, this server's or a failover's
+	ServerAddr string
+	MAC        string // the booting client's
+	Arch       string // the client's iPXE architecture, when known
+	ISOURL     string // the image's ISO
+	BootDir    string // URL of the image's extracted files
+	RoutesURL  string // URL of the provider's Handler, if it has one
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{}
+)
+
+// Register adds p. It panics if p's name is empty, built in or taken, as
+// that is a mistake in the program rather than in its configuration.
+func Register(p Provider) {
+	name := p.Name()
+	if name == "" {
+		panic("bootmethod: provider with no name")
+	}
+	for _, b := range Builtin {
+		if name == b {
+			panic(fmt.Sprintf("bootmethod: %q is a built-in boot method", name))
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := providers[name]; dup {
+		panic(fmt.Sprintf("bootmethod: %q registered twice", name))
+	}
+	providers[name] = p
+}
+
+func Get(name string) (Provider, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// All returns the registered providers by name.
+func All() []Provider {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out
+}
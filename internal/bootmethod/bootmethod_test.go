@@ -0,0 +1,43 @@
+package bootmethod
+
+import (
+	"testing"
+
+	"bootimus/internal/models"
+)
+
+type fake string
+
+func (f fake) Name() string                 { return string(f) }
+func (f fake) Description() string          { return "test" }
+func (f fake) Validate(*models.Image) error { return nil }
+func (f fake) Script(s Stanza) string       { return "chain " + s.RoutesURL + " || goto failed\n" }
+
+func TestRegister(t *testing.T) {
+	Register(fake("xen"))
+	Register(fake("esxi"))
+	defer func() {
+		mu.Lock()
+		delete(providers, "xen")
+		delete(providers, "esxi")
+		mu.Unlock()
+	}()
+
+	if p, ok := Get("xen"); !ok || p.Name() != "xen" {
+		t.Fatalf("Get(xen) = %v, %v", p, ok)
+	}
+	if all := All(); len(all) != 2 || all[0].Name() != "esxi" {
+		t.Errorf("All() = %v", all)
+	}
+
+	for _, name := range []string{"", "kernel", "xen"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", name)
+				}
+			}()
+			Register(fake(name))
+		}()
+	}
+}
@@ -0,0 +1,262 @@
+// Package branding keeps the operator's logo, iPXE console banner and web UI
+// stylesheet in the data directory and serves them to browsers and clients.
+package branding
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	AssetLogo   = "logo"
+	AssetBanner = "banner"
+	AssetCSS    = "css"
+
+	// MaxAssetSize caps a single asset; a bundle may hold one of each.
+	MaxAssetSize = 4 << 20
+)
+
+var (
+	ErrUnknownAsset = errors.New("unknown branding asset")
+	ErrNotFound     = errors.New("branding asset not set")
+)
+
+// logoTypes are the logo formats browsers show, by extension.
+var logoTypes = map[string]string{
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+}
+
+type Store struct {
+	root string
+}
+
+func New(dataDir string) (*Store, error) {
+	root := filepath.Join(dataDir, "branding")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("create branding dir: %w", err)
+	}
+	return &Store{root: root}, nil
+}
+
+type Info struct {
+	Asset     string    `json:"asset"`
+	URL       string    `json:"url"`
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// URL is where an asset is served, relative to the server root.
+func URL(asset string) string {
+	switch asset {
+	case AssetBanner:
+		return "/branding/banner.png"
+	case AssetCSS:
+		return "/branding/theme.css"
+	default:
+		return "/branding/logo"
+	}
+}
+
+// Path returns the file holding an asset, if one is set.
+func (s *Store) Path(asset string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	var candidates []string
+	switch asset {
+	case AssetLogo:
+		for ext := range logoTypes {
+			candidates = append(candidates, "logo"+ext)
+		}
+	case AssetBanner:
+		candidates = []string{"banner.png"}
+	case AssetCSS:
+		candidates = []string{"theme.css"}
+	}
+	for _, name := range candidates {
+		p := filepath.Join(s.root, name)
+		if _, err := os.Stat(p); err == nil {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+func (s *Store) List() []Info {
+	var out []Info
+	for _, asset := range []string{AssetLogo, AssetBanner, AssetCSS} {
+		p, ok := s.Path(asset)
+		if !ok {
+			continue
+		}
+		st, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		out = append(out, Info{Asset: asset, URL: URL(asset), Size: st.Size(), UpdatedAt: st.ModTime()})
+	}
+	return out
+}
+
+// Save validates data as the given asset and replaces the current one.
+// filename only matters for the logo, whose extension picks the format.
+func (s *Store) Save(asset, filename string, data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("%s is empty", asset)
+	}
+	if len(data) > MaxAssetSize {
+		return fmt.Errorf("%s is larger than %d MiB", asset, MaxAssetSize>>20)
+	}
+
+	var name string
+	switch asset {
+	case AssetLogo:
+		ext := strings.ToLower(filepath.Ext(filename))
+		if _, ok := logoTypes[ext]; !ok {
+			return fmt.Errorf("logo must be PNG, SVG, JPEG or WebP")
+		}
+		name = "logo" + ext
+	case AssetBanner:
+		// iPXE's console only decodes PNG.
+		if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("banner must be a PNG image: %w", err)
+		}
+		name = "banner.png"
+	case AssetCSS:
+		name = "theme.css"
+	default:
+		return ErrUnknownAsset
+	}
+
+	if err := s.Remove(asset); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	tmp := filepath.Join(s.root, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.root, name))
+}
+
+// SaveBundle unpacks a zip holding any of logo.<png|svg|jpg|jpeg|webp>,
+// banner.png and theme.css, at the top level or in one folder, and returns
+// the assets it set. Other files are ignored.
+func (s *Store) SaveBundle(data []byte) ([]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("bundle is not a zip file: %w", err)
+	}
+	var set []string
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		base := strings.ToLower(path.Base(f.Name))
+		var asset string
+		switch {
+		case base == "banner.png":
+			asset = AssetBanner
+		case base == "theme.css":
+			asset = AssetCSS
+		case strings.HasPrefix(base, "logo."):
+			asset = AssetLogo
+		default:
+			continue
+		}
+		if f.UncompressedSize64 > MaxAssetSize {
+			return set, fmt.Errorf("%s is larger than %d MiB", f.Name, MaxAssetSize>>20)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return set, err
+		}
+		body, err := io.ReadAll(io.LimitReader(rc, MaxAssetSize+1))
+		rc.Close()
+		if err != nil {
+			return set, err
+		}
+		if err := s.Save(asset, base, body); err != nil {
+			return set, err
+		}
+		set = append(set, asset)
+	}
+	if len(set) == 0 {
+		return nil, errors.New("bundle has no logo, banner.png or theme.css")
+	}
+	return set, nil
+}
+
+func (s *Store) Remove(asset string) error {
+	switch asset {
+	case AssetLogo, AssetBanner, AssetCSS:
+	default:
+		return ErrUnknownAsset
+	}
+	p, ok := s.Path(asset)
+	if !ok {
+		return ErrNotFound
+	}
+	return os.Remove(p)
+}
+
+// ServeHTTP serves /branding/logo, /branding/banner.png and
+// /branding/theme.css. A missing stylesheet is served empty so the web UI
+// can always link it.
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var asset string
+	switch strings.TrimPrefix(r.URL.Path, "/branding/") {
+	case "logo":
+		asset = AssetLogo
+	case "banner.png":
+		asset = AssetBanner
+	case "theme.css":
+		asset = AssetCSS
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	p, ok := s.Path(asset)
+	if !ok {
+		if asset == AssetCSS {
+			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if ct, ok := logoTypes[filepath.Ext(p)]; ok {
+		w.Header().Set("Content-Type", ct)
+	}
+	if asset == AssetLogo && filepath.Ext(p) == ".svg" {
+		// An uploaded SVG can carry script; never run it in our origin.
+		w.Header().Set("Content-Security-Policy", "script-src 'none'; sandbox")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, filepath.Base(p), st.ModTime(), f)
+}
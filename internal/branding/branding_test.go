@@ -0,0 +1,103 @@
+package branding
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSaveValidates(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(AssetBanner, "banner.jpg", []byte("not a png")); err == nil {
+		t.Error("banner that is not a PNG was accepted")
+	}
+	if err := s.Save(AssetLogo, "logo.exe", []byte("x")); err == nil {
+		t.Error("logo with unknown extension was accepted")
+	}
+	if err := s.Save("favicon", "f.png", testPNG(t)); err != ErrUnknownAsset {
+		t.Errorf("unknown asset: err = %v", err)
+	}
+
+	if err := s.Save(AssetLogo, "Logo.PNG", testPNG(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(AssetLogo, "logo.svg", []byte("<svg/>")); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := s.Path(AssetLogo); p[len(p)-4:] != ".svg" {
+		t.Errorf("logo path = %s, want the replacement svg", p)
+	}
+	if len(s.List()) != 1 {
+		t.Errorf("List = %+v, want only the logo", s.List())
+	}
+}
+
+func TestSaveBundle(t *testing.T) {
+	s, _ := New(t.TempDir())
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string][]byte{
+		"acme/banner.png": testPNG(t),
+		"acme/theme.css":  []byte(":root { --accent: red; }"),
+		"acme/README.txt": []byte("ignored"),
+	} {
+		w, _ := zw.Create(name)
+		w.Write(body)
+	}
+	zw.Close()
+
+	set, err := s.SaveBundle(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 2 {
+		t.Errorf("set = %v, want banner and css", set)
+	}
+	if _, ok := s.Path(AssetBanner); !ok {
+		t.Error("banner not saved")
+	}
+	if _, err := s.SaveBundle([]byte("nope")); err == nil {
+		t.Error("non-zip bundle was accepted")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	s, _ := New(t.TempDir())
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/branding/theme.css", nil))
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("missing css: %d %q, want empty 200", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/branding/logo", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing logo: %d, want 404", rec.Code)
+	}
+
+	s.Save(AssetLogo, "logo.svg", []byte("<svg/>"))
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/branding/logo", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Errorf("logo: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec.Header().Get("Content-Security-Policy") == "" {
+		t.Error("svg logo served without a CSP")
+	}
+}
@@ -0,0 +1,31 @@
+// Package ctxio makes long copies stop when their context ends: a client
+// hanging up or the server shutting down cancels the copy at the next read
+// instead of it running on to the end of a multi-gigabyte file.
+package ctxio
+
+import (
+	"context"
+	"io"
+)
+
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Reader returns r, failing with ctx's error once ctx is done.
+func Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &reader{ctx: ctx, r: r}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// Copy is io.Copy that stops with ctx's error once ctx is done.
+func Copy(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	return io.Copy(dst, Reader(ctx, src))
+}
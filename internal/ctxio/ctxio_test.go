@@ -0,0 +1,44 @@
+package ctxio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	var dst bytes.Buffer
+	n, err := Copy(context.Background(), &dst, bytes.NewReader([]byte("hello")))
+	if err != nil || n != 5 || dst.String() != "hello" {
+		t.Fatalf("Copy = %d, %v, %q", n, err, dst.String())
+	}
+}
+
+// endless cancels its context after a few reads, as a client disconnecting
+// part way through would.
+type endless struct {
+	reads  int
+	cancel context.CancelFunc
+}
+
+func (e *endless) Read(p []byte) (int, error) {
+	e.reads++
+	if e.reads == 3 {
+		e.cancel()
+	}
+	return len(p), nil
+}
+
+func TestCopyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := &endless{cancel: cancel}
+	_, err := Copy(ctx, io.Discard, src)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if src.reads != 3 {
+		t.Errorf("read %d times after cancel, want 3", src.reads)
+	}
+}
@@ -0,0 +1,218 @@
+// Package dns is a small authoritative DNS responder for isolated
+// provisioning networks, where installers still insist on resolving mirror
+// and repository names. It answers A queries for one zone plus an optional
+// wildcard (usually the mirror proxy) and nothing else; it never recurses.
+package dns
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	DefaultPort = 53
+	defaultTTL  = 60
+)
+
+type Config struct {
+	Port     int
+	ServerIP net.IP
+	// Zone is the local domain, e.g. "boot.lan". bootimus.<zone> always
+	// resolves to ServerIP.
+	Zone string
+	// Hosts are hosts-file style lines, "10.0.0.9 mirror *.repo
+	// archive.ubuntu.com.". Names are relative to Zone unless they end in
+	// "."; "@" is the zone apex and "*.name" matches any name below name.
+	Hosts []string
+	// Wildcard, when set, answers every other name with this address, so
+	// installers reach the mirror proxy whatever mirror they are set up for.
+	Wildcard string
+}
+
+type Server struct {
+	cfg      Config
+	exact    map[string][4]byte
+	suffix   map[string][4]byte
+	wildcard *[4]byte
+	conn     *net.UDPConn
+	wg       sync.WaitGroup
+	done     chan struct{}
+}
+
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.Port == 0 {
+		cfg.Port = DefaultPort
+	}
+	cfg.Zone = strings.ToLower(strings.Trim(cfg.Zone, "."))
+	s := &Server{
+		cfg:    cfg,
+		exact:  make(map[string][4]byte),
+		suffix: make(map[string][4]byte),
+		done:   make(chan struct{}),
+	}
+
+	if cfg.Zone != "" {
+		if ip := cfg.ServerIP.To4(); ip != nil {
+			s.exact["bootimus."+cfg.Zone] = [4]byte(ip)
+		}
+	}
+	for _, line := range cfg.Hosts {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		ip := net.ParseIP(fields[0]).To4()
+		if ip == nil || len(fields) < 2 {
+			return nil, fmt.Errorf("host entry %q: want an IPv4 address followed by names", line)
+		}
+		for _, name := range fields[1:] {
+			fqdn := s.qualify(name)
+			if rest, ok := strings.CutPrefix(fqdn, "*."); ok {
+				s.suffix[rest] = [4]byte(ip)
+			} else {
+				s.exact[fqdn] = [4]byte(ip)
+			}
+		}
+	}
+	if cfg.Wildcard != "" {
+		ip := net.ParseIP(cfg.Wildcard).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("wildcard %q is not an IPv4 address", cfg.Wildcard)
+		}
+		w := [4]byte(ip)
+		s.wildcard = &w
+	}
+	return s, nil
+}
+
+// qualify turns a host name from the config into a lower-case FQDN
+// without the trailing dot.
+func (s *Server) qualify(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case name == "@":
+		return s.cfg.Zone
+	case strings.HasSuffix(name, "."):
+		return strings.TrimSuffix(name, ".")
+	case s.cfg.Zone == "":
+		return name
+	}
+	return name + "." + s.cfg.Zone
+}
+
+// Lookup returns the address for name, if bootimus answers for it.
+func (s *Server) Lookup(name string) ([4]byte, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if ip, ok := s.exact[name]; ok {
+		return ip, true
+	}
+	for n := name; ; {
+		i := strings.IndexByte(n, '.')
+		if i < 0 {
+			break
+		}
+		n = n[i+1:]
+		if ip, ok := s.suffix[n]; ok {
+			return ip, true
+		}
+	}
+	if s.wildcard != nil {
+		return *s.wildcard, true
+	}
+	return [4]byte{}, false
+}
+
+func (s *Server) Start() error {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: s.cfg.Port})
+	if err != nil {
+		return fmt.Errorf("listen UDP/%d: %w (needs root or CAP_NET_BIND_SERVICE)", s.cfg.Port, err)
+	}
+	s.conn = conn
+
+	log.Printf("DNS: listening on UDP/%d (zone %q, %d records, wildcard %q)",
+		s.cfg.Port, s.cfg.Zone, len(s.exact)+len(s.suffix), s.cfg.Wildcard)
+	s.wg.Add(1)
+	go s.loop()
+	return nil
+}
+
+func (s *Server) Shutdown() error {
+	close(s.done)
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) loop() {
+	defer s.wg.Done()
+	buf := make([]byte, 1500)
+	for {
+		n, src, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			log.Printf("DNS: read error: %v", err)
+			continue
+		}
+		if reply := s.Reply(buf[:n]); reply != nil {
+			if _, err := s.conn.WriteToUDP(reply, src); err != nil {
+				log.Printf("DNS: send reply: %v", err)
+			}
+		}
+	}
+}
+
+// Reply builds the response to one query packet, or nil if it should be
+// dropped.
+func (s *Server) Reply(packet []byte) []byte {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(packet)
+	if err != nil || hdr.Response {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+
+	resp := dnsmessage.Header{ID: hdr.ID, Response: true, Authoritative: true, RecursionDesired: hdr.RecursionDesired}
+	var answer *dnsmessage.AResource
+	ip, known := s.Lookup(q.Name.String())
+	switch {
+	case hdr.OpCode != 0:
+		resp.RCode = dnsmessage.RCodeNotImplemented
+	case q.Class != dnsmessage.ClassINET || !known:
+		resp.RCode = dnsmessage.RCodeNameError
+	case q.Type == dnsmessage.TypeA || q.Type == dnsmessage.TypeALL:
+		answer = &dnsmessage.AResource{A: ip}
+	}
+	// Known names with other query types (AAAA mostly) get an empty
+	// NOERROR, so resolvers fall back to A without waiting.
+
+	b := dnsmessage.NewBuilder(nil, resp)
+	b.EnableCompression()
+	if b.StartQuestions() != nil || b.Question(q) != nil || b.StartAnswers() != nil {
+		return nil
+	}
+	if answer != nil {
+		h := dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: defaultTTL}
+		if b.AResource(h, *answer) != nil {
+			return nil
+		}
+	}
+	out, err := b.Finish()
+	if err != nil {
+		return nil
+	}
+	return out
+}
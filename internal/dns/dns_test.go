@@ -0,0 +1,63 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func query(t *testing.T, s *Server, name string, typ dnsmessage.Type) dnsmessage.Message {
+	t.Helper()
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 42, RecursionDesired: true})
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: typ, Class: dnsmessage.ClassINET})
+	packet, _ := b.Finish()
+	var m dnsmessage.Message
+	if err := m.Unpack(s.Reply(packet)); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if m.ID != 42 || !m.Response {
+		t.Fatalf("%s: bad header %+v", name, m.Header)
+	}
+	return m
+}
+
+func TestReply(t *testing.T) {
+	s, err := NewServer(Config{
+		ServerIP: net.ParseIP("10.0.0.5"),
+		Zone:     "boot.lan.",
+		Hosts:    []string{"10.0.0.9 mirror *.repo archive.ubuntu.com.", "# comment"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string][4]byte{
+		"bootimus.boot.lan.":  {10, 0, 0, 5},
+		"MIRROR.boot.lan.":    {10, 0, 0, 9},
+		"el9.repo.boot.lan.":  {10, 0, 0, 9},
+		"archive.ubuntu.com.": {10, 0, 0, 9},
+	} {
+		m := query(t, s, name, dnsmessage.TypeA)
+		if len(m.Answers) != 1 || m.Answers[0].Body.(*dnsmessage.AResource).A != want {
+			t.Errorf("%s = %v, want %v", name, m.Answers, want)
+		}
+	}
+
+	if m := query(t, s, "mirror.boot.lan.", dnsmessage.TypeAAAA); m.RCode != dnsmessage.RCodeSuccess || len(m.Answers) != 0 {
+		t.Errorf("AAAA for known name: rcode %v, %d answers", m.RCode, len(m.Answers))
+	}
+	if m := query(t, s, "deb.debian.org.", dnsmessage.TypeA); m.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("unknown name: rcode %v", m.RCode)
+	}
+
+	s.wildcard = &[4]byte{10, 0, 0, 7}
+	if m := query(t, s, "deb.debian.org.", dnsmessage.TypeA); len(m.Answers) != 1 || m.Answers[0].Body.(*dnsmessage.AResource).A != [4]byte{10, 0, 0, 7} {
+		t.Errorf("wildcard: %v", m.Answers)
+	}
+
+	if _, err := NewServer(Config{Hosts: []string{"mirror 10.0.0.9"}}); err == nil {
+		t.Error("accepted a malformed host entry")
+	}
+}
@@ -0,0 +1,341 @@
+// Package doctor checks the environment Bootimus runs in: the ports it
+// listens on, the privileges those need, the data directory, the address
+// advertised to clients, the database and the embedded bootloaders. Each
+// problem comes with what to do about it.
+package doctor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"bootimus/bootloaders"
+	"bootimus/internal/hostip"
+)
+
+type Status int
+
+const (
+	OK Status = iota
+	Warn
+	Fail
+)
+
+func (s Status) String() string {
+	switch s {
+	case Warn:
+		return "WARN"
+	case Fail:
+		return "FAIL"
+	}
+	return " OK "
+}
+
+type Result struct {
+	Check  string
+	Status Status
+	Detail string
+	Fix    string // what to do, when Status isn't OK
+}
+
+// Port is one listener the server would open.
+type Port struct {
+	Name    string // shown in results, e.g. "TFTP"
+	Network string // "udp" or "tcp"
+	Port    int
+	Flag    string // the flag that moves it, if any
+}
+
+type Config struct {
+	Ports      []Port
+	DataDir    string
+	ServerAddr hostip.Options
+	// OpenDatabase connects to the configured database and closes it
+	// again.
+	OpenDatabase func() error
+	DatabaseName string // e.g. "SQLite at data/bootimus.db"
+}
+
+// Run performs every check.
+func Run(cfg Config) []Result {
+	var results []Result
+	for _, p := range cfg.Ports {
+		results = append(results, checkPort(p))
+	}
+	results = append(results, checkBindCapability(cfg.Ports))
+	results = append(results, checkDataDir(cfg.DataDir))
+	results = append(results, checkServerAddr(cfg.ServerAddr))
+	if cfg.OpenDatabase != nil {
+		results = append(results, checkDatabase(cfg.DatabaseName, cfg.OpenDatabase))
+	}
+	results = append(results, checkBootloaders()...)
+	return results
+}
+
+// Failed reports whether any result is a failure.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+func checkPort(p Port) Result {
+	r := Result{Check: fmt.Sprintf("%s port %s/%d", p.Name, strings.ToUpper(p.Network), p.Port)}
+	var err error
+	addr := fmt.Sprintf(":%d", p.Port)
+	if p.Network == "udp" {
+		var c net.PacketConn
+		if c, err = net.ListenPacket("udp", addr); err == nil {
+			c.Close()
+		}
+	} else {
+		var l net.Listener
+		if l, err = net.Listen("tcp", addr); err == nil {
+			l.Close()
+		}
+	}
+	switch {
+	case err == nil:
+		r.Detail = "free"
+	case errors.Is(err, syscall.EADDRINUSE):
+		r.Status = Fail
+		r.Detail = "already in use"
+		r.Fix = "Stop whatever holds it (see `ss -lnp | grep :" + strconv.Itoa(p.Port) + "`; a Bootimus that is already running counts)"
+		if p.Flag != "" {
+			r.Fix += " or move Bootimus with " + p.Flag
+		}
+	case errors.Is(err, syscall.EACCES):
+		r.Status = Fail
+		r.Detail = "permission denied"
+		r.Fix = "Ports below 1024 need root or CAP_NET_BIND_SERVICE: sudo setcap 'cap_net_bind_service=+ep' $(which bootimus)"
+	default:
+		r.Status = Fail
+		r.Detail = err.Error()
+	}
+	return r
+}
+
+// checkBindCapability says up front whether the privileged ports can be
+// bound, even when another process holds them right now.
+func checkBindCapability(ports []Port) Result {
+	r := Result{Check: "Privileged ports"}
+	lowest := 0
+	for _, p := range ports {
+		if p.Port < 1024 && (lowest == 0 || p.Port < lowest) {
+			lowest = p.Port
+		}
+	}
+	switch {
+	case lowest == 0:
+		r.Detail = "none needed"
+	case os.Geteuid() == 0:
+		r.Detail = "running as root"
+	case hasNetBindCap():
+		r.Detail = "CAP_NET_BIND_SERVICE held"
+	case unprivilegedPortStart() <= lowest:
+		r.Detail = fmt.Sprintf("net.ipv4.ip_unprivileged_port_start allows port %d", lowest)
+	default:
+		r.Status = Fail
+		r.Detail = fmt.Sprintf("not root and no CAP_NET_BIND_SERVICE, so port %d cannot be bound", lowest)
+		r.Fix = "Run as root, grant the capability (sudo setcap 'cap_net_bind_service=+ep' $(which bootimus)), or in Docker add --cap-add NET_BIND_SERVICE"
+	}
+	return r
+}
+
+const capNetBindService = 10
+
+func hasNetBindCap() bool {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if v, ok := strings.CutPrefix(sc.Text(), "CapEff:"); ok {
+			caps, err := strconv.ParseUint(strings.TrimSpace(v), 16, 64)
+			return err == nil && caps&(1<<capNetBindService) != 0
+		}
+	}
+	return false
+}
+
+func unprivilegedPortStart() int {
+	data, err := os.ReadFile("/proc/sys/net/ipv4/ip_unprivileged_port_start")
+	if err != nil {
+		return 1024
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 1024
+	}
+	return n
+}
+
+func checkDataDir(dir string) Result {
+	r := Result{Check: "Data directory " + dir}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		r.Status = Fail
+		r.Detail = err.Error()
+		r.Fix = "Create it and give the user Bootimus runs as write access, or point --data-dir somewhere writable"
+		return r
+	}
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		r.Status = Fail
+		r.Detail = "not writable: " + err.Error()
+		r.Fix = fmt.Sprintf("sudo chown -R $(id -u):$(id -g) %s, or in Docker check the volume's ownership", dir)
+		return r
+	}
+	f.Close()
+	os.Remove(f.Name())
+	r.Detail = "writable"
+	return r
+}
+
+// checkServerAddr checks that the address clients are told to fetch from
+// belongs to an interface that is up and reaches the LAN's broadcast
+// domain, which is where PXE clients are.
+func checkServerAddr(opts hostip.Options) Result {
+	addr, err := hostip.Detect(opts)
+	if err != nil {
+		return Result{
+			Check:  "Advertised address",
+			Status: Fail,
+			Detail: err.Error(),
+			Fix:    "Set --server-addr to this host's LAN address, or --server-iface or --server-cidr to one that exists",
+		}
+	}
+	r := Result{Check: "Advertised address " + addr.String()}
+	ip := net.ParseIP(addr.IP)
+	if ip.IsLoopback() {
+		r.Status = Fail
+		r.Detail = "loopback; PXE clients cannot reach it"
+		r.Fix = "Set --server-addr to this host's LAN address (auto-detection needs a default route)"
+		return r
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		r.Status = Warn
+		r.Detail = err.Error()
+		return r
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok || !ipnet.IP.Equal(ip) {
+				continue
+			}
+			switch {
+			case iface.Flags&net.FlagUp == 0:
+				r.Status = Fail
+				r.Detail = fmt.Sprintf("on %s, which is down", iface.Name)
+				r.Fix = "Bring " + iface.Name + " up or advertise another address"
+			case iface.Flags&net.FlagBroadcast == 0:
+				r.Status = Warn
+				r.Detail = fmt.Sprintf("on %s, which has no broadcast; PXE and proxyDHCP need a broadcast LAN", iface.Name)
+				r.Fix = "Advertise the address of the interface on the clients' LAN"
+			case isContainerBridge(iface.Name, ip):
+				r.Status = Warn
+				r.Detail = fmt.Sprintf("on %s, which looks like a container bridge network clients cannot reach", iface.Name)
+				r.Fix = "Run the container with host or macvlan networking, or set --server-addr to the Docker host's LAN address"
+			default:
+				r.Detail = fmt.Sprintf("on %s (%s)", iface.Name, ipnet)
+			}
+			return r
+		}
+	}
+	r.Status = Fail
+	r.Detail = "not assigned to any interface on this host"
+	r.Fix = "Set --server-addr to one of this host's addresses; behind NAT, clients must still reach it on the HTTP and TFTP ports"
+	return r
+}
+
+// isContainerBridge reports whether ip is on a container runtime's bridge,
+// either seen from the host or from inside a container on a bridge network.
+func isContainerBridge(iface string, ip net.IP) bool {
+	if hostip.IsContainerInterface(iface) {
+		return true
+	}
+	_, ok := hostip.BridgeInterface(ip.String())
+	return ok && hostip.InContainer()
+}
+
+func checkDatabase(name string, open func() error) Result {
+	r := Result{Check: "Database " + name}
+	if err := open(); err != nil {
+		r.Status = Fail
+		r.Detail = err.Error()
+		r.Fix = "Check db.host, db.port, db.user and the password, and that PostgreSQL accepts connections from this host (pg_hba.conf)"
+		return r
+	}
+	r.Detail = "connected"
+	return r
+}
+
+// lfsPointer is how a bootloader looks when the repository was cloned
+// without Git LFS.
+var lfsPointer = []byte("version https://git-lfs")
+
+func bootloaderPresent(set, file string) bool {
+	data, _, err := bootloaders.Resolve(set, file)
+	return err == nil && !bytes.HasPrefix(data, lfsPointer)
+}
+
+func checkBootloaders() []Result {
+	sets, err := bootloaders.ListSets()
+	if err != nil || len(sets) == 0 {
+		return []Result{{Check: "Embedded bootloaders", Status: Fail, Detail: "none embedded", Fix: "Rebuild from a full checkout of the repository"}}
+	}
+	var results []Result
+	for _, set := range sets {
+		r := Result{Check: "Bootloader set " + set}
+		m, err := bootloaders.LoadManifest(set)
+		if err != nil {
+			r.Status = Warn
+			r.Detail = "no manifest.json: " + err.Error()
+			results = append(results, r)
+			continue
+		}
+		var bad, badARM []string
+		for _, f := range []string{m.Bootfiles.BIOS, m.Bootfiles.UEFI, m.Bootfiles.ARM64} {
+			if f == "" || bootloaderPresent(set, f) {
+				continue
+			}
+			if f == m.Bootfiles.ARM64 {
+				badARM = append(badARM, f)
+			} else {
+				bad = append(bad, f)
+			}
+		}
+		switch {
+		case len(bad) > 0:
+			r.Status = Fail
+			r.Detail = "missing or Git LFS pointers: " + strings.Join(append(bad, badARM...), ", ")
+			r.Fix = "Run `git lfs pull` and rebuild, or use a release binary or the container image"
+		case len(badARM) > 0:
+			// Only ARM64 clients need it.
+			r.Status = Warn
+			r.Detail = "no ARM64 bootloader (" + badARM[0] + "); ARM64 UEFI clients cannot boot"
+			r.Fix = "Build it with scripts/build-bootloaders.sh and rebuild Bootimus"
+		default:
+			r.Detail = "complete"
+		}
+		results = append(results, r)
+	}
+	return results
+}
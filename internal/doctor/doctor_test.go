@@ -0,0 +1,70 @@
+package doctor
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bootimus/internal/hostip"
+)
+
+func TestCheckPortInUse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	// A wildcard listen fails against the held loopback port on Linux.
+	r := checkPort(Port{Name: "HTTP", Network: "tcp", Port: port, Flag: "--http-port"})
+	if r.Status == OK {
+		t.Skipf("wildcard listen succeeded alongside 127.0.0.1:%d", port)
+	}
+	if r.Detail != "already in use" || r.Fix == "" {
+		t.Errorf("got %+v, want an in-use failure with a fix", r)
+	}
+}
+
+func TestCheckDataDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	if r := checkDataDir(dir); r.Status != OK {
+		t.Fatalf("fresh dir: %+v", r)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("probe file left behind: %v", entries)
+	}
+
+	if os.Geteuid() == 0 {
+		return // root writes regardless of mode
+	}
+	os.Chmod(dir, 0555)
+	defer os.Chmod(dir, 0755)
+	if r := checkDataDir(dir); r.Status != Fail || r.Fix == "" {
+		t.Errorf("read-only dir: %+v", r)
+	}
+}
+
+func TestCheckServerAddr(t *testing.T) {
+	if r := checkServerAddr(hostip.Options{Addr: "127.0.0.1"}); r.Status != Fail {
+		t.Errorf("loopback: %+v", r)
+	}
+	if r := checkServerAddr(hostip.Options{Interface: "no-such-iface0"}); r.Status != Fail || r.Fix == "" {
+		t.Errorf("missing interface: %+v", r)
+	}
+	// TEST-NET-3 is never assigned to a host.
+	if r := checkServerAddr(hostip.Options{Addr: "203.0.113.1"}); r.Status != Fail || r.Fix == "" {
+		t.Errorf("foreign address: %+v", r)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	if Failed([]Result{{Status: OK}, {Status: Warn}}) {
+		t.Error("warnings counted as failure")
+	}
+	if !Failed([]Result{{Status: OK}, {Status: Fail}}) {
+		t.Error("failure missed")
+	}
+}
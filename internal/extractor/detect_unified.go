@@ -500,6 +500,7 @@ func (e *Extractor) cacheBootFilesUnified(files *BootFiles, reader FileSystemRea
 		return nil
 	}
 
+	kernelSrc, initrdSrc := files.Kernel, files.Initrd
 	kernelDest := filepath.Join(bootFilesDir, "vmlinuz")
 	if err := reader.ExtractFile(files.Kernel, kernelDest); err != nil {
 		return fmt.Errorf("failed to extract kernel: %w", err)
@@ -512,6 +513,8 @@ func (e *Extractor) cacheBootFilesUnified(files *BootFiles, reader FileSystemRea
 	}
 	files.Initrd = initrdDest
 
+	e.extractArchVariants(files, reader, bootFilesDir, kernelSrc, initrdSrc)
+
 	extractedDir := filepath.Join(bootFilesDir, "iso")
 	if err := os.MkdirAll(extractedDir, 0755); err != nil {
 		return fmt.Errorf("failed to create extracted ISO directory: %w", err)
@@ -1,6 +1,7 @@
 package extractor
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -40,15 +41,23 @@ type BootFiles struct {
 	NetbootRequired bool
 	NetbootURL      string
 	InstallWim      string
+
+	// Arch is the architecture of Kernel and Initrd when their path names
+	// one; Arches has the other architectures' sets on a multi-arch ISO.
+	Arch   string
+	Arches map[string]ArchBootFiles
 }
 
 type Extractor struct {
+	ctx      context.Context // of the Extract call in progress
 	dataDir  string
 	progress *ProgressReporter
+	reuse    *reuse
 }
 
 func New(dataDir string) (*Extractor, error) {
 	return &Extractor{
+		ctx:     context.Background(),
 		dataDir: dataDir,
 	}, nil
 }
@@ -57,7 +66,28 @@ func (e *Extractor) SetProgress(p *ProgressReporter) {
 	e.progress = p
 }
 
-func (e *Extractor) Extract(isoPath string) (*BootFiles, error) {
+// malformed turns a panic raised while parsing an image into an error, so
+// a corrupt upload fails its extraction instead of taking the server down.
+// The ISO9660 parser is a dependency we cannot harden ourselves.
+func malformed(isoPath string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%s is malformed: %v", filepath.Base(isoPath), r)
+		log.Printf("Recovered from panic parsing %s: %v", isoPath, r)
+	}
+}
+
+// unsafeEntryName reports whether a name read from an image would not stay
+// a single path element once joined to the extraction directory.
+func unsafeEntryName(name string) bool {
+	return name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00")
+}
+
+// Extract finds the boot files in the ISO at isoPath and copies them out.
+// Copying stops with ctx's error once ctx is done.
+func (e *Extractor) Extract(ctx context.Context, isoPath string) (_ *BootFiles, err error) {
+	defer malformed(isoPath, &err)
+	e.ctx = ctx
+
 	isUDF, err := detectISOFormat(isoPath)
 	if err != nil {
 		log.Printf("Warning: failed to detect ISO format, will try both methods: %v", err)
@@ -69,6 +99,9 @@ func (e *Extractor) Extract(isoPath string) (*BootFiles, error) {
 		if err == nil {
 			return bootFiles, nil
 		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		log.Printf("UDF extraction failed (%v), trying ISO9660 as fallback", err)
 		bootFiles, err = e.extractViaISO9660(isoPath)
 		if err != nil {
@@ -82,6 +115,11 @@ func (e *Extractor) Extract(isoPath string) (*BootFiles, error) {
 		return bootFiles, nil
 	}
 
+	// A cancelled extraction fails every method alike, so there is no
+	// point in falling back.
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	log.Printf("ISO9660 extraction failed (%v), trying UDF method", err)
 
 	bootFiles, err = e.extractViaUDF(isoPath)
@@ -89,6 +127,9 @@ func (e *Extractor) Extract(isoPath string) (*BootFiles, error) {
 		return bootFiles, nil
 	}
 
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	log.Printf("Both ISO9660 and UDF failed, trying bsdtar fallback extraction")
 	bootFiles, bsdtarErr := e.extractViaBsdtar(isoPath)
 	if bsdtarErr != nil {
@@ -883,7 +924,10 @@ func (e *Extractor) extractDirectory(dir *iso9660.File, destPath, isoPath string
 
 	for _, child := range children {
 		name := child.Name()
-		if name == "" || name == "." || name == ".." {
+		if unsafeEntryName(name) {
+			if name != "." && name != ".." {
+				log.Printf("Warning: skipping entry %q in %s", name, isoPath)
+			}
 			continue
 		}
 
@@ -917,21 +961,10 @@ func (e *Extractor) extractDirectory(dir *iso9660.File, destPath, isoPath string
 }
 
 func (e *Extractor) extractFile(file *iso9660.File, destPath, isoPath string) error {
-	reader := file.Reader()
-
-	outFile, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer outFile.Close()
-
-	n, err := io.Copy(outFile, reader)
-	if err != nil {
-		os.Remove(destPath)
+	open := func() (io.Reader, error) { return file.Reader(), nil }
+	if _, err := e.writeFile(open, file.Size(), destPath); err != nil {
 		return fmt.Errorf("failed to copy file contents: %w", err)
 	}
-	e.progress.AddBytes(n)
-
 	return nil
 }
 
@@ -1109,20 +1142,9 @@ func (e *Extractor) extractNamedFile(img *iso9660.Image, isoPath, destPath strin
 		return fmt.Errorf("path is a directory, not a file: %s", isoPath)
 	}
 
-	reader := file.Reader()
-
-	dest, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
-	}
-	defer dest.Close()
-
-	n, err := io.Copy(dest, reader)
-	if err != nil {
-		return err
-	}
-	e.progress.AddBytes(n)
-	return nil
+	open := func() (io.Reader, error) { return file.Reader(), nil }
+	_, err = e.writeFile(open, file.Size(), destPath)
+	return err
 }
 
 func (e *Extractor) extractViaUDF(isoPath string) (*BootFiles, error) {
@@ -1152,13 +1174,15 @@ func (e *Extractor) extractViaUDF(isoPath string) (*BootFiles, error) {
 	return bootFiles, nil
 }
 
-func (e *Extractor) detectAndExtractUnified(reader FileSystemReader, isoPath string) (*BootFiles, error) {
-	distroName := detectDistroNameUnified(reader, isoPath)
+type unifiedDetector struct {
+	name     string
+	detector func(FileSystemReader) (*BootFiles, error)
+}
 
-	detectors := []struct {
-		name     string
-		detector func(FileSystemReader) (*BootFiles, error)
-	}{
+// unifiedDetectors are tried in order on an ISO's filesystem, before the
+// generic scanner.
+func (e *Extractor) unifiedDetectors() []unifiedDetector {
+	return []unifiedDetector{
 		{"Windows", e.detectWindowsUnified},
 		{"Ubuntu/Debian Family", e.detectUbuntuDebianUnified},
 		{"Arch Linux Family", e.detectArchUnified},
@@ -1176,6 +1200,34 @@ func (e *Extractor) detectAndExtractUnified(reader FileSystemReader, isoPath str
 		{"ClearLinux", e.detectClearLinuxUnified},
 		{"SystemRescue", e.detectSystemRescueUnified},
 	}
+}
+
+// Families names the distro layouts extraction recognises. ISOs of other
+// distros are extracted when the generic scanner finds a kernel and initrd.
+func Families() []string {
+	var names []string
+	for _, d := range (&Extractor{}).unifiedDetectors() {
+		names = append(names, d.name)
+	}
+	return names
+}
+
+func (e *Extractor) detectAndExtractUnified(reader FileSystemReader, isoPath string) (*BootFiles, error) {
+	distroName := detectDistroNameUnified(reader, isoPath)
+
+	e.startReuse(isoPath)
+	if files := e.reuse.cachedLayout(reader); files != nil {
+		log.Printf("Using the boot layout of the previous %s version: kernel=%s initrd=%s", e.reuse.family, files.Kernel, files.Initrd)
+		if distroName != "" {
+			files.Distro = distroName
+		}
+		if err := e.cacheAndRemember(files, reader, isoPath); err != nil {
+			return nil, err
+		}
+		return files, nil
+	}
+
+	detectors := e.unifiedDetectors()
 
 	var errors []string
 	for _, d := range detectors {
@@ -1183,7 +1235,7 @@ func (e *Extractor) detectAndExtractUnified(reader FileSystemReader, isoPath str
 			if distroName != "" {
 				files.Distro = distroName
 			}
-			if err := e.cacheBootFilesUnified(files, reader, isoPath); err != nil {
+			if err := e.cacheAndRemember(files, reader, isoPath); err != nil {
 				return nil, err
 			}
 			return files, nil
@@ -1197,7 +1249,7 @@ func (e *Extractor) detectAndExtractUnified(reader FileSystemReader, isoPath str
 		if distroName != "" {
 			files.Distro = distroName
 		}
-		if err := e.cacheBootFilesUnified(files, reader, isoPath); err != nil {
+		if err := e.cacheAndRemember(files, reader, isoPath); err != nil {
 			return nil, err
 		}
 		log.Printf("Generic scanner succeeded: kernel=%s initrd=%s", files.Kernel, files.Initrd)
@@ -1209,6 +1261,21 @@ func (e *Extractor) detectAndExtractUnified(reader FileSystemReader, isoPath str
 	return nil, fmt.Errorf("unsupported distribution or unable to find boot files (tried: %s)", strings.Join(errors, "; "))
 }
 
+// cacheAndRemember extracts files and records them as the newest of the
+// ISO's family. Windows is left out: boot.wim is patched in place later, so
+// it must not share a hard link with another version.
+func (e *Extractor) cacheAndRemember(files *BootFiles, reader FileSystemReader, isoPath string) error {
+	layout := *files
+	if files.Distro == "windows" {
+		e.reuse = nil
+	}
+	if err := e.cacheBootFilesUnified(files, reader, isoPath); err != nil {
+		return err
+	}
+	e.finishReuse(layout)
+	return nil
+}
+
 func findFileUDF(reader *udf.Reader, path string) (*udf.File, error) {
 	path = strings.TrimPrefix(path, "/")
 	if path == "" {
@@ -1277,23 +1344,8 @@ func (e *Extractor) extractNamedFileUDF(reader *udf.Reader, isoPath, destPath st
 		return fmt.Errorf("path is a directory, not a file: %s", isoPath)
 	}
 
-	fileReader, err := file.Open()
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
-	}
-
-	dest, err := os.Create(destPath)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
-	}
-	defer dest.Close()
-
-	n, err := io.Copy(dest, fileReader)
-	if err != nil {
-		return err
-	}
-	e.progress.AddBytes(n)
-	return nil
+	_, err = e.writeFile(file.Open, file.Size(), destPath)
+	return err
 }
 
 func (e *Extractor) extractUDFContents(reader *udf.Reader, destDir string) error {
@@ -1303,6 +1355,10 @@ func (e *Extractor) extractUDFContents(reader *udf.Reader, destDir string) error
 	}
 
 	for _, file := range root {
+		if unsafeEntryName(file.Name()) {
+			log.Printf("Warning: skipping UDF entry %q", file.Name())
+			continue
+		}
 		if err := e.extractUDFFile(reader, file, destDir, file.Name()); err != nil {
 			log.Printf("Warning: failed to extract %s: %v", file.Name(), err)
 		}
@@ -1325,29 +1381,19 @@ func (e *Extractor) extractUDFFile(reader *udf.Reader, file *udf.File, destDir,
 		}
 
 		for _, child := range children {
+			if unsafeEntryName(child.Name()) {
+				log.Printf("Warning: skipping UDF entry %q in %s", child.Name(), relativePath)
+				continue
+			}
 			childPath := filepath.Join(relativePath, child.Name())
 			if err := e.extractUDFFile(reader, child, destDir, childPath); err != nil {
 				log.Printf("Warning: failed to extract %s: %v", childPath, err)
 			}
 		}
 	} else {
-		fileReader, err := file.Open()
-		if err != nil {
-			return err
-		}
-
-		outFile, err := os.Create(destPath)
-		if err != nil {
-			return err
-		}
-		defer outFile.Close()
-
-		n, err := io.Copy(outFile, fileReader)
-		if err != nil {
-			os.Remove(destPath)
+		if _, err := e.writeFile(file.Open, file.Size(), destPath); err != nil {
 			return err
 		}
-		e.progress.AddBytes(n)
 	}
 
 	return nil
@@ -0,0 +1,230 @@
+package extractor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"bootimus/internal/ctxio"
+)
+
+// Successive versions of a distro (nightlies, point releases) mostly ship
+// the same files. The newest extraction of each family is remembered with
+// the boot layout its detector found and a manifest of file hashes, so the
+// next version tries that layout first and hard-links every file whose
+// size and hash are unchanged instead of writing it again.
+
+const (
+	familiesFile = ".extract-families.json"
+	manifestFile = "manifest.json"
+)
+
+var familiesMu sync.Mutex
+
+var versionRe = regexp.MustCompile(`\d+([._-]\d+)*`)
+
+// FamilyKey is filename with its version numbers and dates masked, so
+// ubuntu-24.04.1-live-server-amd64.iso and ubuntu-24.04.2-live-server-amd64.iso
+// share one key.
+func FamilyKey(filename string) string {
+	base := strings.ToLower(strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)))
+	// Architecture names carry digits that are not versions.
+	for i, arch := range familyArches {
+		base = strings.ReplaceAll(base, arch, fmt.Sprintf("\x00%c\x00", 'a'+i))
+	}
+	base = versionRe.ReplaceAllString(base, "#")
+	for i, arch := range familyArches {
+		base = strings.ReplaceAll(base, fmt.Sprintf("\x00%c\x00", 'a'+i), arch)
+	}
+	return base
+}
+
+var familyArches = []string{"x86_64", "amd64", "aarch64", "arm64", "i386", "i686", "ppc64le", "s390x"}
+
+// familyEntry is the newest extraction of a family.
+type familyEntry struct {
+	Base    string    `json:"base"` // boot files directory, relative to the data dir
+	Layout  BootFiles `json:"layout"`
+	Updated time.Time `json:"updated"`
+}
+
+type manifestEntry struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// reuse is the state of one extraction that may borrow files from the
+// previous version of its family.
+type reuse struct {
+	family  string
+	dir     string // this extraction's boot files directory
+	prevDir string
+	prev    map[string]manifestEntry
+	layout  *BootFiles // detection result of the previous version
+
+	mu     sync.Mutex
+	next   map[string]manifestEntry
+	linked int
+	bytes  int64
+}
+
+func (e *Extractor) loadFamilies() map[string]familyEntry {
+	families := map[string]familyEntry{}
+	if data, err := os.ReadFile(filepath.Join(e.dataDir, familiesFile)); err == nil {
+		json.Unmarshal(data, &families)
+	}
+	return families
+}
+
+// startReuse prepares the extraction of isoPath: it finds the previous
+// version of the family, if it still has its files and a manifest.
+func (e *Extractor) startReuse(isoPath string) {
+	isoBase := relativeISOBase(e.dataDir, isoPath)
+	r := &reuse{
+		family: FamilyKey(isoPath),
+		dir:    filepath.Join(e.dataDir, isoBase),
+		next:   map[string]manifestEntry{},
+	}
+	e.reuse = r
+
+	familiesMu.Lock()
+	prev, ok := e.loadFamilies()[r.family]
+	familiesMu.Unlock()
+	if !ok || prev.Base == isoBase {
+		return
+	}
+	prevDir := filepath.Join(e.dataDir, prev.Base)
+	data, err := os.ReadFile(filepath.Join(prevDir, manifestFile))
+	if err != nil || json.Unmarshal(data, &r.prev) != nil {
+		return
+	}
+	layout := prev.Layout
+	r.prevDir, r.layout = prevDir, &layout
+	log.Printf("Extraction: %s is in the %s family, reusing unchanged files from %s", filepath.Base(isoPath), r.family, prev.Base)
+}
+
+// cachedLayout returns the previous version's detection result if the same
+// kernel and initrd paths exist in this ISO.
+func (r *reuse) cachedLayout(reader FileSystemReader) *BootFiles {
+	if r == nil || r.layout == nil || r.layout.Distro == "windows" {
+		return nil
+	}
+	if !reader.FileExists(r.layout.Kernel) || !reader.FileExists(r.layout.Initrd) {
+		return nil
+	}
+	files := *r.layout
+	return &files
+}
+
+// finishReuse writes the manifest and makes this extraction the newest of its
+// family.
+func (e *Extractor) finishReuse(layout BootFiles) {
+	r := e.reuse
+	if r == nil {
+		return
+	}
+	data, _ := json.MarshalIndent(r.next, "", "  ")
+	if err := os.WriteFile(filepath.Join(r.dir, manifestFile), data, 0644); err != nil {
+		log.Printf("Extraction: failed to write manifest: %v", err)
+		return
+	}
+	if r.linked > 0 {
+		log.Printf("Extraction: reused %d unchanged file(s), %d MB, from %s", r.linked, r.bytes>>20, filepath.Base(r.prevDir))
+	}
+
+	rel, err := filepath.Rel(e.dataDir, r.dir)
+	if err != nil {
+		return
+	}
+	familiesMu.Lock()
+	defer familiesMu.Unlock()
+	families := e.loadFamilies()
+	families[r.family] = familyEntry{Base: rel, Layout: layout, Updated: time.Now()}
+	data, _ = json.MarshalIndent(families, "", "  ")
+	path := filepath.Join(e.dataDir, familiesFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err == nil {
+		os.Rename(path+".tmp", path)
+	}
+}
+
+// Reused reports how many files, and bytes, the last extraction linked from
+// the previous version of its family instead of writing them.
+func (e *Extractor) Reused() (int, int64) {
+	if e.reuse == nil {
+		return 0, 0
+	}
+	return e.reuse.linked, e.reuse.bytes
+}
+
+// writeFile puts an ISO file of size bytes at destPath. When the previous
+// version of the family has the same file, by size and hash, it is
+// hard-linked instead; open is called again to hash it first. An existing
+// destPath is removed rather than truncated, since it may be a link shared
+// with another version.
+func (e *Extractor) writeFile(open func() (io.Reader, error), size int64, destPath string) (int64, error) {
+	os.Remove(destPath)
+	r := e.reuse
+	key := ""
+	if r != nil {
+		if rel, err := filepath.Rel(r.dir, destPath); err == nil && !strings.HasPrefix(rel, "..") {
+			key = filepath.ToSlash(rel)
+		}
+	}
+
+	if key != "" && r.prevDir != "" {
+		if old, ok := r.prev[key]; ok && old.Size == size {
+			src, err := open()
+			if err != nil {
+				return 0, err
+			}
+			h := sha256.New()
+			if _, err := ctxio.Copy(e.ctx, h, src); err != nil {
+				return 0, err
+			}
+			sum := hex.EncodeToString(h.Sum(nil))
+			if sum == old.SHA256 && os.Link(filepath.Join(r.prevDir, filepath.FromSlash(key)), destPath) == nil {
+				r.mu.Lock()
+				r.next[key] = old
+				r.linked++
+				r.bytes += size
+				r.mu.Unlock()
+				e.progress.AddBytes(size)
+				e.progress.AddFile()
+				return size, nil
+			}
+		}
+	}
+
+	src, err := open()
+	if err != nil {
+		return 0, err
+	}
+	out, err := os.Create(destPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+	h := sha256.New()
+	n, err := ctxio.Copy(e.ctx, io.MultiWriter(out, h), src)
+	if err != nil {
+		os.Remove(destPath)
+		return n, err
+	}
+	e.progress.AddBytes(n)
+	e.progress.AddFile()
+	if key != "" {
+		r.mu.Lock()
+		r.next[key] = manifestEntry{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}
+		r.mu.Unlock()
+	}
+	return n, nil
+}
@@ -0,0 +1,152 @@
+package extractor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bootimus/internal/models"
+	"bootimus/internal/udf"
+
+	"github.com/kdomanski/iso9660"
+)
+
+// ISOInfo is what can be read from an ISO without extracting anything.
+type ISOInfo struct {
+	VolumeLabel string `json:"volume_label,omitempty"`
+	Distro      string `json:"distro,omitempty"`
+	Arch        string `json:"arch,omitempty"`
+	Release     string `json:"release,omitempty"` // from .disk/info or .treeinfo
+}
+
+// Inspect reads the volume label and release files of the ISO at isoPath
+// and guesses its distro and architecture.
+func Inspect(isoPath string) (_ *ISOInfo, err error) {
+	defer malformed(isoPath, &err)
+
+	f, err := os.Open(isoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Windows media is ISO9660 on the outside with the files only in UDF,
+	// so UDF is read first when present.
+	info := &ISOInfo{}
+	var reader FileSystemReader
+	u := udf.NewReader(f)
+	if _, err := u.Root(); err == nil {
+		info.VolumeLabel = u.VolumeIdentifier()
+		reader = &UDFReader{reader: u}
+	}
+	if img, err := iso9660.OpenImage(f); err == nil {
+		if info.VolumeLabel == "" {
+			info.VolumeLabel, _ = img.Label()
+		}
+		if reader == nil {
+			reader = &ISO9660Reader{img: img}
+		}
+	}
+	if reader == nil {
+		return nil, fmt.Errorf("%s is neither ISO9660 nor UDF", filepath.Base(isoPath))
+	}
+	info.VolumeLabel = strings.TrimSpace(info.VolumeLabel)
+
+	tree := parseTreeinfo(reader.ReadFileContent("/.treeinfo"))
+	if diskInfo := strings.TrimSpace(reader.ReadFileContent("/.disk/info")); diskInfo != "" {
+		info.Release, _, _ = strings.Cut(diskInfo, "\n")
+	} else if name := tree.release(); name != "" {
+		info.Release = name
+	}
+
+	info.Distro = detectDistroNameUnified(reader, isoPath)
+	if info.Distro == "" && info.Release != "" {
+		// The release name goes through the same filename patterns.
+		info.Distro = detectDistroNameUnified(reader, info.Release)
+	}
+	if info.Distro == "" && (reader.FileExists("/sources/boot.wim") || reader.FileExists("/SOURCES/BOOT.WIM")) {
+		info.Distro = "windows"
+	}
+	info.Arch = models.NormaliseArch(tree["general.arch"])
+	if info.Arch == "" {
+		info.Arch = models.NormaliseArch(tree["tree.arch"])
+	}
+	if info.Arch == "" {
+		info.Arch = archFromEFI(reader)
+	}
+	if info.Arch == "" {
+		info.Arch = archFromText(info.Release + " " + info.VolumeLabel + " " + filepath.Base(isoPath))
+	}
+	return info, nil
+}
+
+// treeinfo maps "section.key" to value.
+type treeinfo map[string]string
+
+func parseTreeinfo(content string) treeinfo {
+	t := treeinfo{}
+	section := ""
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			t[section+"."+strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	return t
+}
+
+// release is "<name> <version>" from the productmd [release] section, or
+// the older [general] family and version.
+func (t treeinfo) release() string {
+	for _, sec := range []string{"release", "general"} {
+		name := t[sec+".name"]
+		if sec == "general" && t["general.family"] != "" {
+			name = t["general.family"]
+		}
+		if name != "" {
+			return strings.TrimSpace(name + " " + t[sec+".version"])
+		}
+	}
+	return ""
+}
+
+var efiArches = []struct{ file, arch string }{
+	{"BOOTX64.EFI", "x86_64"},
+	{"BOOTAA64.EFI", "arm64"},
+	{"BOOTIA32.EFI", "i386"},
+	{"BOOTRISCV64.EFI", "riscv64"},
+}
+
+func archFromEFI(reader FileSystemReader) string {
+	entries, err := reader.ListDirectory("/EFI/BOOT")
+	if err != nil {
+		entries, _ = reader.ListDirectory("/efi/boot")
+	}
+	for _, ea := range efiArches {
+		for _, e := range entries {
+			if strings.EqualFold(e.Name, ea.file) {
+				return ea.arch
+			}
+		}
+	}
+	return ""
+}
+
+func archFromText(s string) string {
+	s = strings.ToLower(s)
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_')
+	}) {
+		if arch := models.NormaliseArch(word); arch != "" {
+			return arch
+		}
+	}
+	return ""
+}
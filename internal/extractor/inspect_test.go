@@ -0,0 +1,70 @@
+package extractor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kdomanski/iso9660"
+)
+
+// testISO is a small ISO9660 image of an Ubuntu-like release.
+func testISO(t testing.TB) []byte {
+	t.Helper()
+	w, err := iso9660.NewWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Cleanup()
+	files := map[string]string{
+		".disk/info":           "Ubuntu 24.04 LTS \"Noble Numbat\" - Release amd64\n",
+		"casper/vmlinuz":       "kernel",
+		"casper/initrd":        "initrd",
+		"EFI/BOOT/BOOTX64.EFI": "efi",
+	}
+	for name, content := range files {
+		if err := w.AddFile(strings.NewReader(content), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := w.WriteTo(&buf, "UBUNTU_24_04"); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestInspect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ubuntu.iso")
+	if err := os.WriteFile(path, testISO(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := Inspect(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.VolumeLabel != "UBUNTU_24_04" || info.Arch != "x86_64" || !strings.HasPrefix(info.Release, "Ubuntu 24.04") {
+		t.Errorf("Inspect = %+v", info)
+	}
+}
+
+// FuzzInspect overwrites part of the volume descriptors, path table and
+// directory records of testISO; Inspect may fail but must not panic.
+func FuzzInspect(f *testing.F) {
+	img := testISO(f)
+	f.Add(uint16(0), img[16*2048:16*2048+64])
+	f.Add(uint16(2048), []byte{0xff, 0xff, 0xff, 0xff})
+	f.Add(uint16(156), []byte{0x22, 0, 0xff, 0xff, 0xff, 0x7f})
+	path := filepath.Join(f.TempDir(), "fuzz.iso")
+	f.Fuzz(func(t *testing.T, off uint16, b []byte) {
+		data := append([]byte(nil), img...)
+		start := 16*2048 + int(off)%(len(data)-16*2048)
+		copy(data[start:], b)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		Inspect(path)
+	})
+}
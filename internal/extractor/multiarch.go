@@ -0,0 +1,129 @@
+package extractor
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Some ISOs carry a boot tree per architecture, such as Debian's
+// install.amd and install.a64 or openSUSE's boot/x86_64 and boot/aarch64.
+// The detectors find one kernel; the others are found by swapping the
+// architecture in its path.
+
+// archSpellings are the path tokens tried for each architecture, by the
+// name NormaliseArch gives it.
+var archSpellings = map[string][]string{
+	"x86_64":  {"x86_64", "amd64", "amd", "x64"},
+	"arm64":   {"aarch64", "arm64", "a64"},
+	"i386":    {"i386", "i686", "386"},
+	"riscv64": {"riscv64"},
+}
+
+type ArchBootFiles struct {
+	Kernel string
+	Initrd string
+}
+
+// pathArch finds the architecture named in an ISO path. It returns the
+// architecture and the index and text of the token naming it.
+func pathArch(path string) (string, int, string) {
+	for i, seg := range strings.Split(path, "/") {
+		for _, tok := range strings.FieldsFunc(seg, func(r rune) bool { return r == '.' || r == '-' }) {
+			for arch, spellings := range archSpellings {
+				for _, s := range spellings {
+					if strings.EqualFold(tok, s) {
+						return arch, i, tok
+					}
+				}
+			}
+		}
+	}
+	return "", -1, ""
+}
+
+// swapArch replaces the token at segment i of path with spelling.
+func swapArch(path string, i int, tok, spelling string) string {
+	segs := strings.Split(path, "/")
+	if i >= len(segs) {
+		return ""
+	}
+	segs[i] = strings.Replace(segs[i], tok, spelling, 1)
+	return strings.Join(segs, "/")
+}
+
+// findArchVariants returns the architecture of the kernel and initrd at
+// the given ISO paths, and the kernel and initrd of every other
+// architecture the ISO has at the same place.
+func findArchVariants(reader FileSystemReader, kernel, initrd string) (string, map[string]ArchBootFiles) {
+	arch, ki, ktok := pathArch(kernel)
+	if arch == "" {
+		return "", nil
+	}
+	_, ii, itok := pathArch(initrd)
+	variants := map[string]ArchBootFiles{}
+	for other, spellings := range archSpellings {
+		if other == arch {
+			continue
+		}
+		for _, s := range spellings {
+			k := swapArch(kernel, ki, ktok, s)
+			i := initrd
+			if itok != "" {
+				i = swapArch(initrd, ii, itok, s)
+			}
+			if i == initrd || !reader.FileExists(k) || !reader.FileExists(i) {
+				continue
+			}
+			variants[other] = ArchBootFiles{Kernel: k, Initrd: i}
+			break
+		}
+	}
+	return arch, variants
+}
+
+// extractArchVariants extracts the kernel and initrd of each other
+// architecture into a subdirectory named after it. A variant that fails is
+// left out.
+func (e *Extractor) extractArchVariants(files *BootFiles, reader FileSystemReader, bootFilesDir, kernel, initrd string) {
+	arch, variants := findArchVariants(reader, kernel, initrd)
+	files.Arch = arch
+	if len(variants) == 0 {
+		return
+	}
+	files.Arches = map[string]ArchBootFiles{}
+	for other, v := range variants {
+		dir := filepath.Join(bootFilesDir, other)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Printf("Extraction: skipping %s kernel: %v", other, err)
+			continue
+		}
+		k, i := filepath.Join(dir, "vmlinuz"), filepath.Join(dir, "initrd")
+		if err := reader.ExtractFile(v.Kernel, k); err != nil {
+			log.Printf("Extraction: skipping %s kernel %s: %v", other, v.Kernel, err)
+			continue
+		}
+		if err := reader.ExtractFile(v.Initrd, i); err != nil {
+			log.Printf("Extraction: skipping %s kernel, initrd %s: %v", other, v.Initrd, err)
+			continue
+		}
+		files.Arches[other] = ArchBootFiles{Kernel: k, Initrd: i}
+		log.Printf("Extraction: found %s kernel %s alongside the %s one", other, v.Kernel, arch)
+	}
+}
+
+// KernelArches lists the architectures files has kernels for, the primary
+// one first, or nil when it has only the one.
+func (files *BootFiles) KernelArches() []string {
+	if files.Arch == "" || len(files.Arches) == 0 {
+		return nil
+	}
+	others := make([]string, 0, len(files.Arches))
+	for arch := range files.Arches {
+		others = append(others, arch)
+	}
+	sort.Strings(others)
+	return append([]string{files.Arch}, others...)
+}
@@ -7,11 +7,15 @@ type ProgressReporter struct {
 	stage          string
 	totalBytes     int64
 	extractedBytes int64
+	files          int64
 }
 
 type ProgressSnapshot struct {
-	Stage   string  `json:"stage"`
-	Percent float64 `json:"percent"`
+	Stage      string  `json:"stage"`
+	Percent    float64 `json:"percent"`
+	Files      int64   `json:"files"` // extracted so far
+	Bytes      int64   `json:"bytes"`
+	TotalBytes int64   `json:"total_bytes"`
 }
 
 func NewProgressReporter() *ProgressReporter {
@@ -45,6 +49,15 @@ func (p *ProgressReporter) AddBytes(n int64) {
 	p.mu.Unlock()
 }
 
+func (p *ProgressReporter) AddFile() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.files++
+	p.mu.Unlock()
+}
+
 func (p *ProgressReporter) Snapshot() ProgressSnapshot {
 	if p == nil {
 		return ProgressSnapshot{}
@@ -58,5 +71,5 @@ func (p *ProgressReporter) Snapshot() ProgressSnapshot {
 			percent = 100
 		}
 	}
-	return ProgressSnapshot{Stage: p.stage, Percent: percent}
+	return ProgressSnapshot{Stage: p.stage, Percent: percent, Files: p.files, Bytes: p.extractedBytes, TotalBytes: p.totalBytes}
 }
@@ -0,0 +1,126 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"bootimus/internal/webhook"
+)
+
+// Hook points. The event JSON written to the hook's stdin carries the point
+// name in its "event" field.
+const (
+	PreMenu          = "pre-menu"
+	PostBootSelect   = "post-boot-select"
+	PostInstall      = "post-install-callback"
+	DefaultTimeout   = 10 * time.Second
+	maxCapturedBytes = 4096
+)
+
+// Runner runs the site's hook commands. Commands come only from the server
+// configuration, never from the API.
+type Runner struct {
+	commands map[string][]string
+	timeout  time.Duration
+}
+
+// New builds a Runner from hook point to command line. The command line is
+// split on whitespace and run without a shell.
+func New(commands map[string]string, timeout time.Duration) *Runner {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	r := &Runner{commands: make(map[string][]string), timeout: timeout}
+	for point, cmd := range commands {
+		if args := strings.Fields(cmd); len(args) > 0 {
+			r.commands[point] = args
+			log.Printf("Hooks: %s runs %s", point, args[0])
+		}
+	}
+	return r
+}
+
+func (r *Runner) Enabled(point string) bool {
+	return r != nil && len(r.commands[point]) > 0
+}
+
+// Run executes the hook for point and waits for it, up to the timeout. A
+// missing hook is not an error.
+func (r *Runner) Run(point string, ev webhook.Event) error {
+	if !r.Enabled(point) {
+		return nil
+	}
+	args := r.commands[point]
+	ev.Event = point
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now().UTC()
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		"BOOTIMUS_HOOK="+point,
+		"BOOTIMUS_MAC="+ev.MAC,
+		"BOOTIMUS_IP="+ev.IP,
+		"BOOTIMUS_IMAGE="+ev.Image,
+	)
+	var out limitedBuffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	start := time.Now()
+	err = cmd.Run()
+	output := strings.TrimSpace(out.String())
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		err = fmt.Errorf("%s hook timed out after %s", point, r.timeout)
+	case err != nil:
+		err = fmt.Errorf("%s hook: %w", point, err)
+	}
+	if err != nil {
+		log.Printf("Hooks: %v (mac %s): %s", err, ev.MAC, output)
+		return err
+	}
+	log.Printf("Hooks: %s for %s finished in %s", point, ev.MAC, time.Since(start).Round(time.Millisecond))
+	if output != "" {
+		log.Printf("Hooks: %s output: %s", point, output)
+	}
+	return nil
+}
+
+// Go runs the hook in the background, for points the boot doesn't wait on.
+func (r *Runner) Go(point string, ev webhook.Event) {
+	if !r.Enabled(point) {
+		return
+	}
+	go r.Run(point, ev)
+}
+
+// limitedBuffer keeps the first few KB of a hook's output for the log.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxCapturedBytes - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
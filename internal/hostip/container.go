@@ -0,0 +1,100 @@
+package hostip
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// InContainer reports whether Bootimus runs inside a container.
+func InContainer() bool {
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return true
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return true
+	}
+
+	data, err := os.ReadFile("/proc/1/cgroup")
+	if err == nil {
+		content := string(data)
+		if strings.Contains(content, "docker") || strings.Contains(content, "containerd") {
+			return true
+		}
+	}
+
+	if os.Getpid() == 1 {
+		entries, err := os.ReadDir("/proc")
+		if err == nil && len(entries) < 50 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bridgePools are the networks Docker and Podman hand out to bridge
+// networks by default.
+var bridgePools = []*net.IPNet{
+	{IP: net.IPv4(172, 16, 0, 0), Mask: net.CIDRMask(12, 32)},
+	{IP: net.IPv4(10, 88, 0, 0), Mask: net.CIDRMask(16, 32)},
+}
+
+// BridgeInterface returns the interface holding ip when it looks like a
+// container's end of a bridge network: one half of a veth pair with an
+// address from a container runtime's pool. Macvlan and host networking
+// don't match.
+func BridgeInterface(ip string) (string, bool) {
+	addr := net.ParseIP(ip)
+	if addr == nil || !inBridgePool(addr) {
+		return "", false
+	}
+	ifaces, err := interfaces()
+	if err != nil {
+		return "", false
+	}
+	for _, iface := range ifaces {
+		for _, local := range ipv4s(iface) {
+			if local.Equal(addr) && isVeth(iface.Name) {
+				return iface.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+func inBridgePool(ip net.IP) bool {
+	for _, n := range bridgePools {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVeth is a variable so tests can stand in for sysfs.
+var isVeth = func(name string) bool {
+	data, err := os.ReadFile("/sys/class/net/" + name + "/uevent")
+	if err != nil {
+		return false
+	}
+	// The kernel tags veth devices; macvlan and physical NICs have no such
+	// line.
+	return strings.Contains(string(data), "DEVTYPE=veth")
+}
+
+// ContainerWarning explains why clients probably can't reach ip, or is
+// empty when nothing looks wrong.
+func ContainerWarning(ip string) string {
+	if !InContainer() {
+		return ""
+	}
+	iface, ok := BridgeInterface(ip)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("Advertising %s, the container's address on its bridge network (%s). PXE clients on the LAN cannot reach it. "+
+		"Run the container with host or macvlan networking, or set --server-addr (BOOTIMUS_SERVER_ADDR) to the Docker host's LAN address "+
+		"and publish the TFTP and HTTP ports. Pass --container-network-check=false if this address is reachable after all.", ip, iface)
+}
@@ -0,0 +1,184 @@
+// Package hostip picks the address Bootimus advertises to boot clients.
+//
+// The strategies are tried in order: an explicit address, the address of a
+// named interface, a local address inside a CIDR, the address that routes
+// to a DHCP relay, the address of the default route, and finally the first
+// LAN interface. A strategy that is configured but finds nothing is an
+// error rather than a reason to fall through, since the fallbacks are what
+// the setting was meant to override.
+package hostip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	StrategyExplicit  = "explicit"
+	StrategyInterface = "interface"
+	StrategyCIDR      = "cidr"
+	StrategyRelay     = "dhcp-relay"
+	StrategyRoute     = "default-route"
+	StrategyFirstLAN  = "first-lan-interface"
+)
+
+type Options struct {
+	Addr      string // server_addr
+	Interface string // e.g. eth1
+	CIDR      string // e.g. 192.168.10.0/24
+	Relay     string // the DHCP relay (giaddr) clients' requests come through
+}
+
+type Result struct {
+	IP       string
+	Strategy string
+	Detail   string // what the strategy matched, for the log
+}
+
+func (r Result) String() string {
+	if r.Detail == "" {
+		return fmt.Sprintf("%s (%s)", r.IP, r.Strategy)
+	}
+	return fmt.Sprintf("%s (%s: %s)", r.IP, r.Strategy, r.Detail)
+}
+
+// These are variables so tests can stand in for the host's network.
+var (
+	interfaces = net.Interfaces
+	addrsOf    = func(iface net.Interface) ([]net.Addr, error) { return iface.Addrs() }
+	routeTo    = func(addr string) (net.IP, error) {
+		// Connecting a UDP socket only looks up the route; nothing is sent.
+		conn, err := net.Dial("udp4", addr)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).IP, nil
+	}
+)
+
+// Detect picks the address to advertise.
+func Detect(o Options) (Result, error) {
+	switch {
+	case o.Addr != "":
+		if net.ParseIP(o.Addr) == nil {
+			return Result{}, fmt.Errorf("server address %q is not an IP address", o.Addr)
+		}
+		return Result{IP: o.Addr, Strategy: StrategyExplicit}, nil
+	case o.Interface != "":
+		return byInterface(o.Interface)
+	case o.CIDR != "":
+		return byCIDR(o.CIDR)
+	case o.Relay != "":
+		return byRelay(o.Relay)
+	}
+
+	if ip, err := routeTo("8.8.8.8:80"); err == nil && !ip.IsLoopback() {
+		return Result{IP: ip.String(), Strategy: StrategyRoute}, nil
+	}
+	// No default route, as in an air-gapped lab.
+	return firstLAN()
+}
+
+func byInterface(name string) (Result, error) {
+	ifaces, err := interfaces()
+	if err != nil {
+		return Result{}, err
+	}
+	for _, iface := range ifaces {
+		if iface.Name != name {
+			continue
+		}
+		if ip := firstIPv4(iface); ip != nil {
+			return Result{IP: ip.String(), Strategy: StrategyInterface, Detail: name}, nil
+		}
+		return Result{}, fmt.Errorf("interface %s has no IPv4 address", name)
+	}
+	return Result{}, fmt.Errorf("interface %s not found", name)
+}
+
+func byCIDR(cidr string) (Result, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return Result{}, fmt.Errorf("server CIDR: %w", err)
+	}
+	ifaces, err := interfaces()
+	if err != nil {
+		return Result{}, err
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		for _, ip := range ipv4s(iface) {
+			if network.Contains(ip) {
+				return Result{IP: ip.String(), Strategy: StrategyCIDR, Detail: fmt.Sprintf("%s on %s", cidr, iface.Name)}, nil
+			}
+		}
+	}
+	return Result{}, fmt.Errorf("no local address in %s", cidr)
+}
+
+// byRelay takes the local address the kernel would use to reach the relay,
+// which is the one relayed clients can reach back.
+func byRelay(relay string) (Result, error) {
+	if net.ParseIP(relay) == nil {
+		return Result{}, fmt.Errorf("DHCP relay %q is not an IP address", relay)
+	}
+	ip, err := routeTo(net.JoinHostPort(relay, "67"))
+	if err != nil {
+		return Result{}, fmt.Errorf("no route to DHCP relay %s: %w", relay, err)
+	}
+	return Result{IP: ip.String(), Strategy: StrategyRelay, Detail: "route to " + relay}, nil
+}
+
+func firstLAN() (Result, error) {
+	ifaces, err := interfaces()
+	if err != nil {
+		return Result{}, err
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || IsContainerInterface(iface.Name) {
+			continue
+		}
+		if ip := firstIPv4(iface); ip != nil {
+			return Result{IP: ip.String(), Strategy: StrategyFirstLAN, Detail: iface.Name}, nil
+		}
+	}
+	return Result{}, fmt.Errorf("no default route and no LAN interface with an IPv4 address; set --server-addr")
+}
+
+// IsContainerInterface reports whether name is a bridge or veth a container
+// runtime made, whose addresses boot clients cannot reach.
+func IsContainerInterface(name string) bool {
+	for _, prefix := range []string{"docker", "br-", "veth", "cni", "flannel", "podman", "virbr"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstIPv4(iface net.Interface) net.IP {
+	if ips := ipv4s(iface); len(ips) > 0 {
+		return ips[0]
+	}
+	return nil
+}
+
+func ipv4s(iface net.Interface) []net.IP {
+	addrs, err := addrsOf(iface)
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok {
+			if ip4 := ipnet.IP.To4(); ip4 != nil && !ip4.IsLinkLocalUnicast() {
+				ips = append(ips, ip4)
+			}
+		}
+	}
+	return ips
+}
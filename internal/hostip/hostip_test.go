@@ -0,0 +1,109 @@
+package hostip
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func fakeHost(t *testing.T, route net.IP) {
+	t.Helper()
+	host := map[string][]string{
+		"lo":      {"127.0.0.1/8"},
+		"docker0": {"172.17.0.1/16"},
+		"eth0":    {"fe80::1/64", "10.0.0.5/24"},
+		"eth1":    {"192.168.10.2/24"},
+	}
+	ifaces := []net.Interface{
+		{Index: 1, Name: "lo", Flags: net.FlagUp | net.FlagLoopback},
+		{Index: 2, Name: "docker0", Flags: net.FlagUp | net.FlagBroadcast},
+		{Index: 3, Name: "eth0", Flags: net.FlagUp | net.FlagBroadcast},
+		{Index: 4, Name: "eth1", Flags: net.FlagUp | net.FlagBroadcast},
+	}
+	oldIfaces, oldAddrs, oldRoute := interfaces, addrsOf, routeTo
+	t.Cleanup(func() { interfaces, addrsOf, routeTo = oldIfaces, oldAddrs, oldRoute })
+
+	interfaces = func() ([]net.Interface, error) { return ifaces, nil }
+	addrsOf = func(iface net.Interface) ([]net.Addr, error) {
+		var addrs []net.Addr
+		for _, cidr := range host[iface.Name] {
+			ip, n, _ := net.ParseCIDR(cidr)
+			n.IP = ip
+			addrs = append(addrs, n)
+		}
+		return addrs, nil
+	}
+	routeTo = func(string) (net.IP, error) {
+		if route == nil {
+			return nil, errors.New("network is unreachable")
+		}
+		return route, nil
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		route    net.IP
+		ip       string
+		strategy string
+	}{
+		{"explicit wins", Options{Addr: "10.9.9.9", Interface: "eth1"}, net.IPv4(10, 0, 0, 5), "10.9.9.9", StrategyExplicit},
+		{"interface", Options{Interface: "eth1"}, net.IPv4(10, 0, 0, 5), "192.168.10.2", StrategyInterface},
+		{"cidr", Options{CIDR: "192.168.10.0/24"}, net.IPv4(10, 0, 0, 5), "192.168.10.2", StrategyCIDR},
+		{"relay", Options{Relay: "192.168.20.1"}, net.IPv4(192, 168, 10, 2), "192.168.10.2", StrategyRelay},
+		{"default route", Options{}, net.IPv4(10, 0, 0, 5), "10.0.0.5", StrategyRoute},
+		{"air-gapped skips container bridges", Options{}, nil, "10.0.0.5", StrategyFirstLAN},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeHost(t, tt.route)
+			got, err := Detect(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.IP != tt.ip || got.Strategy != tt.strategy {
+				t.Errorf("got %v, want %s (%s)", got, tt.ip, tt.strategy)
+			}
+		})
+	}
+}
+
+func TestDetectConfiguredStrategyDoesNotFallBack(t *testing.T) {
+	fakeHost(t, net.IPv4(10, 0, 0, 5))
+	for _, o := range []Options{
+		{Addr: "not-an-ip"},
+		{Interface: "eth9"},
+		{Interface: "lo0"},
+		{CIDR: "172.30.0.0/16"},
+		{CIDR: "bogus"},
+		{Relay: "relay.example"},
+	} {
+		if got, err := Detect(o); err == nil {
+			t.Errorf("Detect(%+v) = %v, want an error", o, got)
+		}
+	}
+}
+
+func TestBridgeInterface(t *testing.T) {
+	fakeHost(t, nil)
+	oldVeth := isVeth
+	t.Cleanup(func() { isVeth = oldVeth })
+	isVeth = func(name string) bool { return name == "docker0" }
+
+	if iface, ok := BridgeInterface("172.17.0.1"); !ok || iface != "docker0" {
+		t.Errorf("veth in a bridge pool: got %q, %v", iface, ok)
+	}
+	isVeth = func(string) bool { return false }
+	if _, ok := BridgeInterface("172.17.0.1"); ok {
+		t.Error("macvlan or host networking flagged as a bridge")
+	}
+	isVeth = func(string) bool { return true }
+	if _, ok := BridgeInterface("192.168.10.2"); ok {
+		t.Error("address outside the runtimes' pools flagged as a bridge")
+	}
+	if _, ok := BridgeInterface("172.18.0.9"); ok {
+		t.Error("address not on this host flagged as a bridge")
+	}
+}
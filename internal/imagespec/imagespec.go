@@ -0,0 +1,118 @@
+// Package imagespec reads declarative image definitions: where an ISO comes
+// from, what it should hash to and how the image should be set up once it
+// is there. Applying the same spec again converges on the same state, so
+// specs can live in version control and be re-applied after any change.
+package imagespec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"bootimus/internal/bootmethod"
+
+	"go.yaml.in/yaml/v3"
+)
+
+type Spec struct {
+	URL         string `yaml:"url" json:"url"`
+	Filename    string `yaml:"filename" json:"filename,omitempty"` // default: the last element of URL
+	SHA256      string `yaml:"sha256" json:"sha256,omitempty"`
+	Name        string `yaml:"name" json:"name,omitempty"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	Group       string `yaml:"group" json:"group,omitempty"` // created if missing
+	Enabled     *bool  `yaml:"enabled" json:"enabled,omitempty"`
+	Public      *bool  `yaml:"public" json:"public,omitempty"`
+
+	Extract    bool   `yaml:"extract" json:"extract,omitempty"`
+	BootMethod string `yaml:"boot_method" json:"boot_method,omitempty"`
+	BootParams string `yaml:"boot_params" json:"boot_params,omitempty"` // replaces the profile's after extraction
+
+	AutoInstallFile string `yaml:"auto_install_file" json:"auto_install_file,omitempty"` // a file in the auto-install library
+	AutoInstall     *bool  `yaml:"auto_install" json:"auto_install,omitempty"`
+}
+
+var bootMethods = map[string]bool{"sanboot": true, "kernel": true, "nbd": true, "nfs": true}
+
+// Parse reads one spec, a list of them, or a mapping with an "images" list,
+// in YAML or JSON. Each YAML document in data may be any of these.
+func Parse(data []byte) ([]Spec, error) {
+	var specs []Spec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		node := doc.Content[0]
+		if node.Kind == yaml.MappingNode {
+			var wrapper struct {
+				Images []Spec `yaml:"images"`
+			}
+			if err := node.Decode(&wrapper); err == nil && wrapper.Images != nil {
+				specs = append(specs, wrapper.Images...)
+				continue
+			}
+			var s Spec
+			if err := node.Decode(&s); err != nil {
+				return nil, err
+			}
+			specs = append(specs, s)
+			continue
+		}
+		var list []Spec
+		if err := node.Decode(&list); err != nil {
+			return nil, err
+		}
+		specs = append(specs, list...)
+	}
+	for i := range specs {
+		if err := specs[i].normalise(); err != nil {
+			return nil, fmt.Errorf("image %d: %w", i+1, err)
+		}
+	}
+	return specs, nil
+}
+
+func (s *Spec) normalise() error {
+	if s.URL == "" {
+		return errors.New("url is required")
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("url %q must be http or https", s.URL)
+	}
+	if s.Filename == "" {
+		s.Filename = path.Base(u.Path)
+	}
+	if strings.ContainsAny(s.Filename, `/\`) || strings.HasPrefix(s.Filename, ".") {
+		return fmt.Errorf("filename %q must be a plain file name", s.Filename)
+	}
+	if !strings.HasSuffix(strings.ToLower(s.Filename), ".iso") {
+		return fmt.Errorf("filename %q must end in .iso", s.Filename)
+	}
+	s.SHA256 = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s.SHA256)), "sha256:")
+	if s.SHA256 != "" {
+		if b, err := hex.DecodeString(s.SHA256); err != nil || len(b) != 32 {
+			return fmt.Errorf("sha256 %q is not a SHA-256 digest", s.SHA256)
+		}
+	}
+	if _, custom := bootmethod.Get(s.BootMethod); s.BootMethod != "" && !bootMethods[s.BootMethod] && !custom {
+		return fmt.Errorf("boot_method %q must be sanboot, kernel, nbd, nfs or a registered provider", s.BootMethod)
+	}
+	if (s.BootMethod == "kernel" || s.BootMethod == "nfs") && !s.Extract {
+		return fmt.Errorf("boot_method %s needs extract: true", s.BootMethod)
+	}
+	return nil
+}
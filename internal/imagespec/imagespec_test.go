@@ -0,0 +1,56 @@
+package imagespec
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	specs, err := Parse([]byte(`
+url: https://cdimage.debian.org/debian-cd/current/amd64/iso-cd/debian-13.2.0-amd64-netinst.iso
+sha256: SHA256:` + strings.Repeat("AB", 32) + `
+group: Debian
+extract: true
+boot_method: kernel
+public: false
+---
+images:
+  - url: https://example.com/a.iso
+  - url: https://example.com/b.iso?download=1
+    filename: b.iso
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 3 {
+		t.Fatalf("got %d specs, want 3", len(specs))
+	}
+	if specs[0].Filename != "debian-13.2.0-amd64-netinst.iso" || specs[0].SHA256 != strings.Repeat("ab", 32) {
+		t.Errorf("first spec %+v", specs[0])
+	}
+	if specs[0].Public == nil || *specs[0].Public || specs[1].Public != nil {
+		t.Error("public should be set only where given")
+	}
+
+	// JSON is YAML too.
+	specs, err = Parse([]byte(`[{"url": "https://example.com/c.iso", "name": "C"}]`))
+	if err != nil || len(specs) != 1 || specs[0].Name != "C" {
+		t.Errorf("JSON list: %+v, %v", specs, err)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, doc := range []string{
+		`filename: a.iso`,
+		`url: ftp://example.com/a.iso`,
+		`url: https://example.com/download`,
+		`{url: "https://example.com/a.iso", filename: "../a.iso"}`,
+		`{url: "https://example.com/a.iso", sha256: "abc"}`,
+		`{url: "https://example.com/a.iso", boot_method: kernel}`,
+		`{url: "https://example.com/a.iso", boot_method: pxe, extract: true}`,
+	} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("Parse(%s) succeeded", doc)
+		}
+	}
+}
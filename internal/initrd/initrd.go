@@ -0,0 +1,372 @@
+// Package initrd repacks Linux initramfs images into smaller "lite" copies
+// for clients short on RAM: firmware the client does not need is dropped
+// and the rest is recompressed with zstd.
+//
+// An initrd is one or more cpio (newc) archives, each optionally
+// compressed, laid end to end. Uncompressed archives at the front (CPU
+// microcode, which the kernel reads before anything else) are copied as
+// they are; the compressed remainder is filtered and recompressed.
+package initrd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultStrip are firmware classes installers rarely need at boot: GPU,
+// Wi-Fi and SoC blobs, which make up most of the firmware in a live
+// initrd. Wired NIC and storage firmware is kept.
+var DefaultStrip = []string{
+	"amdgpu", "radeon", "nvidia", "i915", "xe",
+	"iwlwifi", "ath10k", "ath11k", "ath12k", "brcm", "rtw88", "rtw89", "mediatek",
+	"qcom",
+}
+
+// DefaultLevel is the zstd level used when Options.Level is 0. Levels
+// above 19 need a window larger than the kernel's initramfs decompressor
+// accepts, so they are not allowed.
+const DefaultLevel = 19
+
+type Options struct {
+	Strip []string // firmware classes: directories (amdgpu) or file prefixes (iwlwifi-*)
+	Level int      // zstd level, 1-19
+}
+
+type Result struct {
+	Before       int64 `json:"before"` // bytes on disk
+	After        int64 `json:"after"`
+	Removed      int   `json:"removed"`       // archive entries dropped
+	RemovedBytes int64 `json:"removed_bytes"` // uncompressed size of what was dropped
+}
+
+// Available reports whether the zstd binary Repack compresses with is
+// installed.
+func Available() bool {
+	_, err := exec.LookPath("zstd")
+	return err == nil
+}
+
+// Repack writes a filtered, zstd-compressed copy of the initrd at src to
+// dst. dst only appears once it is complete.
+func Repack(src, dst string, opts Options) (*Result, error) {
+	if opts.Level == 0 {
+		opts.Level = DefaultLevel
+	}
+	if opts.Level < 1 || opts.Level > 19 {
+		return nil, fmt.Errorf("zstd level must be 1-19, got %d", opts.Level)
+	}
+	zstdPath, err := exec.LookPath("zstd")
+	if err != nil {
+		return nil, fmt.Errorf("zstd not found in PATH: %w", err)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	res := &Result{Before: info.Size()}
+	f := &filter{strip: opts.Strip, res: res}
+	if err := repack(bufio.NewReaderSize(in, 1<<20), out, f, zstdPath, opts.Level); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(tmp); err == nil {
+		res.After = info.Size()
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func repack(in *bufio.Reader, out io.Writer, f *filter, zstdPath string, level int) error {
+	for {
+		head, err := in.Peek(6)
+		if len(head) == 0 && err == io.EOF {
+			return errors.New("no compressed archive found; the initrd is not worth repacking")
+		}
+		switch {
+		case isCPIO(head):
+			// Early archive: copied verbatim, padding included.
+			if err := copyArchive(in, out); err != nil {
+				return err
+			}
+		case len(head) > 0 && head[0] == 0:
+			b, _ := in.ReadByte()
+			if _, err := out.Write([]byte{b}); err != nil {
+				return err
+			}
+		default:
+			dec, wait, err := decompress(in, head)
+			if err != nil {
+				return err
+			}
+			werr := compress(zstdPath, level, out, func(w io.Writer) error {
+				return f.copy(bufio.NewReaderSize(dec, 1<<20), w)
+			})
+			// Decompressors often complain about padding after the last
+			// stream, so their exit status only matters if the archive was
+			// cut short, which f.copy reports itself.
+			wait()
+			return werr
+		}
+	}
+}
+
+func isCPIO(b []byte) bool {
+	return bytes.HasPrefix(b, []byte("070701")) || bytes.HasPrefix(b, []byte("070702"))
+}
+
+// decompress returns a reader of the decompressed rest of in, whose first
+// bytes are head, and a function that cleans up afterwards.
+func decompress(in *bufio.Reader, head []byte) (io.Reader, func(), error) {
+	var args []string
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(in)
+		return zr, func() {}, err
+	case bytes.HasPrefix(head, []byte("BZh")):
+		return bzip2.NewReader(in), func() {}, nil
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		args = []string{"zstd", "-dc"}
+	case bytes.HasPrefix(head, []byte{0xfd, '7', 'z', 'X', 'Z', 0}):
+		args = []string{"xz", "-dc"}
+	case bytes.HasPrefix(head, []byte{0x5d, 0, 0}):
+		args = []string{"xz", "-dc", "--format=lzma"}
+	case bytes.HasPrefix(head, []byte{0x02, 0x21, 0x4c, 0x18}):
+		args = []string{"lz4", "-dc"}
+	default:
+		return nil, nil, fmt.Errorf("unrecognised initrd compression (starts %x)", head)
+	}
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s not found in PATH: %w", args[0], err)
+	}
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Stdin = in
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return stdout, func() {
+		io.Copy(io.Discard, stdout)
+		cmd.Wait()
+	}, nil
+}
+
+// compress runs write with a writer feeding zstd, whose output goes to out.
+func compress(zstdPath string, level int, out io.Writer, write func(io.Writer) error) error {
+	cmd := exec.Command(zstdPath, "-q", "-c", "-T0", "-"+strconv.Itoa(level))
+	cmd.Stdout = out
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	werr := write(stdin)
+	stdin.Close()
+	if err := cmd.Wait(); err != nil && werr == nil {
+		werr = fmt.Errorf("zstd failed: %w (%s)", err, strings.TrimSpace(stderr.String()))
+	}
+	return werr
+}
+
+// newc headers are 110 bytes: the magic and 13 eight-digit hex fields.
+const headerLen = 110
+
+type entry struct {
+	header   []byte
+	name     string
+	fileSize int64
+}
+
+func readEntry(r io.Reader) (*entry, error) {
+	e := &entry{header: make([]byte, headerLen)}
+	if _, err := io.ReadFull(r, e.header); err != nil {
+		return nil, err
+	}
+	if !isCPIO(e.header) {
+		return nil, fmt.Errorf("bad cpio header %q", e.header[:6])
+	}
+	field := func(i int) (int64, error) {
+		return strconv.ParseInt(string(e.header[6+8*i:14+8*i]), 16, 64)
+	}
+	var err error
+	if e.fileSize, err = field(6); err != nil {
+		return nil, err
+	}
+	nameSize, err := field(11)
+	if err != nil || nameSize < 1 || nameSize > 4096 {
+		return nil, fmt.Errorf("bad cpio name size")
+	}
+	name := make([]byte, nameSize+pad(headerLen+nameSize))
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, err
+	}
+	e.header = append(e.header, name...)
+	e.name = string(name[:nameSize-1])
+	return e, nil
+}
+
+func pad(n int64) int64 { return (4 - n%4) % 4 }
+
+// copyArchive copies one uncompressed archive, trailer included.
+func copyArchive(in io.Reader, out io.Writer) error {
+	for {
+		e, err := readEntry(in)
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(e.header); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(out, in, e.fileSize+pad(e.fileSize)); err != nil {
+			return err
+		}
+		if e.name == "TRAILER!!!" {
+			return nil
+		}
+	}
+}
+
+type filter struct {
+	strip []string
+	res   *Result
+}
+
+// copy copies the archives in in to out without the stripped firmware. It
+// stops at the end of the data or at padding after the last archive.
+func (f *filter) copy(in *bufio.Reader, out io.Writer) error {
+	trailers := 0
+	for {
+		head, err := in.Peek(6)
+		if !isCPIO(head) {
+			if trailers == 0 {
+				if err == nil {
+					err = fmt.Errorf("bad cpio header %q", head)
+				}
+				return fmt.Errorf("initrd is not a cpio archive: %w", err)
+			}
+			return nil
+		}
+		e, err := readEntry(in)
+		if err != nil {
+			return fmt.Errorf("initrd is truncated: %w", err)
+		}
+		data := e.fileSize + pad(e.fileSize)
+		if f.stripped(e.name) {
+			f.res.Removed++
+			f.res.RemovedBytes += e.fileSize
+			if _, err := io.CopyN(io.Discard, in, data); err != nil {
+				return fmt.Errorf("initrd is truncated: %w", err)
+			}
+			continue
+		}
+		if _, err := out.Write(e.header); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(out, in, data); err != nil {
+			return fmt.Errorf("initrd is truncated: %w", err)
+		}
+		if e.name == "TRAILER!!!" {
+			trailers++
+		}
+	}
+}
+
+// stripped reports whether name is firmware of one of the stripped classes.
+func (f *filter) stripped(name string) bool {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "./")
+	name = strings.TrimPrefix(name, "/")
+	rest, ok := strings.CutPrefix(name, "usr/lib/firmware/")
+	if !ok {
+		if rest, ok = strings.CutPrefix(name, "lib/firmware/"); !ok {
+			return false
+		}
+	}
+	first, _, _ := strings.Cut(rest, "/")
+	for _, class := range f.strip {
+		if first == class || strings.HasPrefix(first, class+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// File is one entry of an archive built by WriteArchive.
+type File struct {
+	Name string
+	Mode int64 // permission bits; 0644 if zero
+	Data []byte
+}
+
+// WriteArchive writes files as an uncompressed newc archive, with entries
+// for their parent directories. iPXE can load it as an extra initrd, which
+// the kernel unpacks over the main one.
+func WriteArchive(w io.Writer, files []File) error {
+	var buf bytes.Buffer
+	ino := 1
+	entry := func(name string, mode int64, data []byte) {
+		fmt.Fprintf(&buf, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+			ino, mode, 0, 0, 1, 0, len(data), 0, 0, 0, 0, len(name)+1, 0)
+		buf.WriteString(name + "\x00")
+		buf.Write(make([]byte, pad(int64(headerLen+len(name)+1))))
+		buf.Write(data)
+		buf.Write(make([]byte, pad(int64(len(data)))))
+		ino++
+	}
+	dirs := map[string]bool{}
+	for _, f := range files {
+		name := strings.TrimPrefix(f.Name, "/")
+		var parents []string
+		for d := path.Dir(name); d != "." && d != "/" && !dirs[d]; d = path.Dir(d) {
+			parents = append(parents, d)
+			dirs[d] = true
+		}
+		for i := len(parents) - 1; i >= 0; i-- {
+			entry(parents[i], 040755, nil)
+		}
+		mode := f.Mode
+		if mode == 0 {
+			mode = 0644
+		}
+		entry(name, 0100000|mode, f.Data)
+	}
+	entry("TRAILER!!!", 0, nil)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
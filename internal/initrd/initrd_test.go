@@ -0,0 +1,134 @@
+package initrd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeCPIO(buf *bytes.Buffer, files map[string]string, order []string) {
+	entry := func(name, data string) {
+		fmt.Fprintf(buf, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+			0, 0100644, 0, 0, 1, 0, len(data), 0, 0, 0, 0, len(name)+1, 0)
+		buf.WriteString(name + "\x00")
+		buf.Write(make([]byte, pad(int64(headerLen+len(name)+1))))
+		buf.WriteString(data)
+		buf.Write(make([]byte, pad(int64(len(data)))))
+	}
+	for _, name := range order {
+		entry(name, files[name])
+	}
+	entry("TRAILER!!!", "")
+}
+
+func listCPIO(t *testing.T, data []byte) []string {
+	t.Helper()
+	var names []string
+	r := bufio.NewReader(bytes.NewReader(data))
+	for {
+		head, _ := r.Peek(6)
+		if !isCPIO(head) {
+			return names
+		}
+		e, err := readEntry(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Discard(int(e.fileSize + pad(e.fileSize)))
+		names = append(names, e.name)
+	}
+}
+
+func TestRepack(t *testing.T) {
+	if !Available() {
+		t.Skip("zstd not installed")
+	}
+	dir := t.TempDir()
+
+	var early, main bytes.Buffer
+	writeCPIO(&early, map[string]string{"kernel/x86/microcode/GenuineIntel.bin": "ucode"},
+		[]string{"kernel/x86/microcode/GenuineIntel.bin"})
+	files := map[string]string{
+		"init":                                    "#!/bin/sh",
+		"usr/lib/firmware/amdgpu":                 "",
+		"usr/lib/firmware/amdgpu/navi10_ce.bin":   "gpu",
+		"usr/lib/firmware/iwlwifi-cc-a0-77.ucode": "wifi",
+		"usr/lib/firmware/bnx2/bnx2-mips.fw":      "nic",
+		"lib/firmware/nvidia/gsp.bin":             "gpu",
+	}
+	order := []string{"init", "usr/lib/firmware/amdgpu", "usr/lib/firmware/amdgpu/navi10_ce.bin",
+		"usr/lib/firmware/iwlwifi-cc-a0-77.ucode", "usr/lib/firmware/bnx2/bnx2-mips.fw", "lib/firmware/nvidia/gsp.bin"}
+	writeCPIO(&main, files, order)
+
+	var img bytes.Buffer
+	img.Write(early.Bytes())
+	img.Write(make([]byte, 512-img.Len()%512))
+	zw := gzip.NewWriter(&img)
+	zw.Write(main.Bytes())
+	zw.Close()
+	src := filepath.Join(dir, "initrd")
+	os.WriteFile(src, img.Bytes(), 0644)
+
+	dst := filepath.Join(dir, "initrd-lite")
+	res, err := Repack(src, dst, Options{Strip: DefaultStrip, Level: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 4 || res.RemovedBytes != 10 {
+		t.Errorf("removed %d entries, %d bytes; want 4, 10", res.Removed, res.RemovedBytes)
+	}
+
+	out, _ := os.ReadFile(dst)
+	if !bytes.HasPrefix(out, early.Bytes()) {
+		t.Fatal("early microcode archive not copied verbatim")
+	}
+	rest := bytes.TrimLeft(out[early.Len():], "\x00")
+	cmd := exec.Command("zstd", "-dc")
+	cmd.Stdin = bytes.NewReader(rest)
+	plain, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("output is not zstd: %v", err)
+	}
+	want := []string{"init", "usr/lib/firmware/bnx2/bnx2-mips.fw", "TRAILER!!!"}
+	if got := listCPIO(t, plain); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+}
+
+func TestRepackRejectsUncompressed(t *testing.T) {
+	if !Available() {
+		t.Skip("zstd not installed")
+	}
+	dir := t.TempDir()
+	var b bytes.Buffer
+	writeCPIO(&b, map[string]string{"init": "x"}, []string{"init"})
+	src := filepath.Join(dir, "initrd")
+	os.WriteFile(src, b.Bytes(), 0644)
+	if _, err := Repack(src, filepath.Join(dir, "lite"), Options{}); err == nil {
+		t.Error("expected an error for an initrd with nothing compressed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lite")); !os.IsNotExist(err) {
+		t.Error("output left behind after a failed repack")
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	var b bytes.Buffer
+	err := WriteArchive(&b, []File{
+		{Name: "/bootimus/firstboot.sh", Mode: 0755, Data: []byte("#!/bin/sh\n")},
+		{Name: "bootimus/unit/a.service", Data: []byte("[Unit]")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"bootimus", "bootimus/firstboot.sh", "bootimus/unit", "bootimus/unit/a.service", "TRAILER!!!"}
+	if got := listCPIO(t, b.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+}
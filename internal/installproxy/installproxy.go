@@ -0,0 +1,137 @@
+// Package installproxy holds the web proxy installers should use, which
+// can differ per client subnet, and writes it out as a proxy auto-config
+// (PAC) file for clients that discover their proxy through WPAD.
+package installproxy
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+type Setting struct {
+	// Subnet is the CIDR this setting applies to; empty applies to
+	// clients no other setting covers.
+	Subnet     string `mapstructure:"subnet" json:"subnet,omitempty"`
+	HTTPProxy  string `mapstructure:"http_proxy" json:"http_proxy,omitempty"`
+	HTTPSProxy string `mapstructure:"https_proxy" json:"https_proxy,omitempty"`
+	NoProxy    string `mapstructure:"no_proxy" json:"no_proxy,omitempty"` // comma-separated hosts and .domains
+	// PACURL points clients at an existing PAC file rather than the one
+	// generated from HTTPProxy and NoProxy.
+	PACURL string `mapstructure:"pac_url" json:"pac_url,omitempty"`
+
+	subnet *net.IPNet
+}
+
+// Set is the configured settings, matched most specific subnet first.
+type Set struct {
+	settings []Setting
+}
+
+// New checks the settings. A nil Set, as returned for no settings, matches
+// no client.
+func New(settings []Setting) (*Set, error) {
+	if len(settings) == 0 {
+		return nil, nil
+	}
+	s := &Set{}
+	defaults := 0
+	for _, st := range settings {
+		if st.Subnet == "" {
+			defaults++
+		} else {
+			_, n, err := net.ParseCIDR(st.Subnet)
+			if err != nil {
+				return nil, fmt.Errorf("install proxy subnet %q: %w", st.Subnet, err)
+			}
+			st.subnet = n
+		}
+		for field, v := range map[string]string{"http_proxy": st.HTTPProxy, "https_proxy": st.HTTPSProxy, "pac_url": st.PACURL} {
+			if v == "" {
+				continue
+			}
+			if u, err := url.Parse(v); err != nil || u.Host == "" || strings.ContainsAny(v, " \t\r\n\"'") {
+				return nil, fmt.Errorf("install proxy %s %q is not a URL", field, v)
+			}
+		}
+		if st.HTTPProxy == "" && st.HTTPSProxy == "" && st.PACURL == "" {
+			return nil, fmt.Errorf("install proxy for %q needs http_proxy, https_proxy or pac_url", st.Subnet)
+		}
+		s.settings = append(s.settings, st)
+	}
+	if defaults > 1 {
+		return nil, fmt.Errorf("only one install proxy setting may leave out its subnet")
+	}
+	return s, nil
+}
+
+// For returns the setting for a client at ip: the one with the narrowest
+// subnet holding it, or else the default. It is nil if none applies.
+func (s *Set) For(ip net.IP) *Setting {
+	if s == nil {
+		return nil
+	}
+	var best, fallback *Setting
+	bestBits := -1
+	for i := range s.settings {
+		st := &s.settings[i]
+		if st.subnet == nil {
+			fallback = st
+			continue
+		}
+		if ip == nil || !st.subnet.Contains(ip) {
+			continue
+		}
+		if bits, _ := st.subnet.Mask.Size(); bits > bestBits {
+			best, bestBits = st, bits
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return fallback
+}
+
+// PAC is a proxy auto-config script sending everything but the NoProxy
+// hosts through the proxy, falling back to a direct connection.
+func (st *Setting) PAC() string {
+	var b strings.Builder
+	b.WriteString("function FindProxyForURL(url, host) {\n")
+	b.WriteString("  if (isPlainHostName(host)) return \"DIRECT\";\n")
+	for _, h := range strings.Split(st.NoProxy, ",") {
+		h = strings.TrimSpace(h)
+		switch {
+		case h == "" || strings.ContainsAny(h, "\"\\"):
+		case strings.Contains(h, "/"):
+			if _, n, err := net.ParseCIDR(h); err == nil {
+				fmt.Fprintf(&b, "  if (isInNet(dnsResolve(host), %q, %q)) return \"DIRECT\";\n", n.IP.String(), net.IP(n.Mask).String())
+			}
+		case strings.HasPrefix(h, "."):
+			fmt.Fprintf(&b, "  if (dnsDomainIs(host, %q)) return \"DIRECT\";\n", h)
+		default:
+			fmt.Fprintf(&b, "  if (host == %q || dnsDomainIs(host, %q)) return \"DIRECT\";\n", h, "."+h)
+		}
+	}
+	if p := hostPort(st.HTTPSProxy); p != "" {
+		fmt.Fprintf(&b, "  if (url.substring(0, 6) == \"https:\") return \"PROXY %s; DIRECT\";\n", p)
+	}
+	if p := hostPort(st.HTTPProxy); p != "" {
+		fmt.Fprintf(&b, "  return \"PROXY %s; DIRECT\";\n", p)
+	} else {
+		b.WriteString("  return \"DIRECT\";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func hostPort(proxy string) string {
+	if proxy == "" {
+		return ""
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,66 @@
+package installproxy
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestFor(t *testing.T) {
+	set, err := New([]Setting{
+		{HTTPProxy: "http://proxy.corp:3128"},
+		{Subnet: "10.0.0.0/8", HTTPProxy: "http://proxy.lab:3128"},
+		{Subnet: "10.2.0.0/16", PACURL: "http://wpad.site-b/wpad.dat"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"10.2.3.4", "10.2.0.0/16"},
+		{"10.1.3.4", "10.0.0.0/8"},
+		{"192.168.1.5", ""},
+	}
+	for _, tt := range tests {
+		got := set.For(net.ParseIP(tt.ip))
+		if got == nil || got.Subnet != tt.want {
+			t.Errorf("For(%s) = %+v, want subnet %q", tt.ip, got, tt.want)
+		}
+	}
+
+	var none *Set
+	if none.For(net.ParseIP("10.0.0.1")) != nil {
+		t.Error("nil Set matched a client")
+	}
+}
+
+func TestNewRejects(t *testing.T) {
+	for _, settings := range [][]Setting{
+		{{Subnet: "10.0.0.0/33", HTTPProxy: "http://p:3128"}},
+		{{HTTPProxy: "not a url"}},
+		{{Subnet: "10.0.0.0/8"}},
+		{{HTTPProxy: "http://a:1"}, {HTTPProxy: "http://b:1"}},
+	} {
+		if _, err := New(settings); err == nil {
+			t.Errorf("New(%+v) accepted", settings)
+		}
+	}
+}
+
+func TestPAC(t *testing.T) {
+	st := Setting{HTTPProxy: "http://proxy.lab:3128", HTTPSProxy: "http://tls.lab:3129", NoProxy: "localhost, .lab.local,10.0.0.0/8"}
+	pac := st.PAC()
+	for _, want := range []string{
+		`if (host == "localhost" || dnsDomainIs(host, ".localhost")) return "DIRECT";`,
+		`if (dnsDomainIs(host, ".lab.local")) return "DIRECT";`,
+		`if (isInNet(dnsResolve(host), "10.0.0.0", "255.0.0.0")) return "DIRECT";`,
+		`return "PROXY tls.lab:3129; DIRECT";`,
+		`return "PROXY proxy.lab:3128; DIRECT";`,
+	} {
+		if !strings.Contains(pac, want) {
+			t.Errorf("PAC missing %s:\n%s", want, pac)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package iscsi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// initiator is just enough of an iSCSI initiator to drive one session.
+type initiator struct {
+	t      *testing.T
+	nc     net.Conn
+	itt    uint32
+	cmdSN  uint32
+	closed chan struct{}
+}
+
+func dial(t *testing.T, s *Server) *initiator {
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		s.handleConn(server)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		client.Close()
+		<-done
+	})
+	return &initiator{t: t, nc: client, closed: done}
+}
+
+func (in *initiator) send(p *pdu) *pdu {
+	in.t.Helper()
+	in.itt++
+	p.putU32(16, in.itt)
+	p.putU32(24, in.cmdSN)
+	if err := writePDU(in.nc, p); err != nil {
+		in.t.Fatal(err)
+	}
+	resp, err := readPDU(in.nc)
+	if err != nil {
+		in.t.Fatal(err)
+	}
+	return resp
+}
+
+func (in *initiator) login(keys ...[2]string) *pdu {
+	p := &pdu{data: encodeText(keys)}
+	p.bhs[0] = 0x40 | opLoginReq
+	p.bhs[1] = 0x80 | 1<<2 | stageFullFeature
+	return in.send(p)
+}
+
+// command runs cdb and collects its Data-In, returning the final status.
+func (in *initiator) command(cdb []byte, want uint32) ([]byte, byte) {
+	in.t.Helper()
+	p := &pdu{}
+	p.bhs[0] = opSCSICommand
+	p.bhs[1] = 0x80 | 0x40
+	p.putU32(20, want)
+	copy(p.bhs[32:], cdb)
+	resp := in.send(p)
+	in.cmdSN++
+	var data []byte
+	for {
+		switch resp.opcode() {
+		case opDataIn:
+			data = append(data, resp.data...)
+			if resp.flags()&0x01 != 0 {
+				return data, resp.bhs[3]
+			}
+		case opSCSIResponse:
+			return data, resp.bhs[3]
+		default:
+			in.t.Fatalf("unexpected opcode %#x", resp.opcode())
+		}
+		var err error
+		if resp, err = readPDU(in.nc); err != nil {
+			in.t.Fatal(err)
+		}
+	}
+}
+
+func testServer(t *testing.T) (*Server, []byte) {
+	image := make([]byte, 3*cdromBlockSize)
+	for i := range image {
+		image[i] = byte(i / cdromBlockSize)
+	}
+	path := filepath.Join(t.TempDir(), "Test Image.iso")
+	if err := os.WriteFile(path, image, 0644); err != nil {
+		t.Fatal(err)
+	}
+	name := TargetName(DefaultPrefix, "Test Image.iso")
+	return NewServer(0, func() []Target {
+		return []Target{{Name: name, Path: path, Image: "Test Image.iso"}}
+	}), image
+}
+
+func TestTargetName(t *testing.T) {
+	if got, want := TargetName(DefaultPrefix, "windows/Win_11 (x64).iso"), DefaultPrefix+":windows-win-11--x64-.iso"; got != want {
+		t.Errorf("TargetName = %q, want %q", got, want)
+	}
+}
+
+func TestSession(t *testing.T) {
+	s, image := testServer(t)
+	var reads [][2]int64
+	s.OnRead = func(_ string, _, offset, length int64) { reads = append(reads, [2]int64{offset, length}) }
+	in := dial(t, s)
+
+	resp := in.login(
+		[2]string{"InitiatorName", "iqn.2010-04.org.ipxe:test"},
+		[2]string{"TargetName", DefaultPrefix + ":test-image.iso"},
+		[2]string{"SessionType", "Normal"},
+		[2]string{"AuthMethod", "CHAP,None"},
+		[2]string{"MaxRecvDataSegmentLength", "1024"},
+	)
+	if status := binary.BigEndian.Uint16(resp.bhs[36:]); status != loginSuccess || resp.flags()&0x83 != 0x83 {
+		t.Fatalf("login: status %#04x flags %#x", status, resp.flags())
+	}
+	if !bytes.Contains(resp.data, []byte("AuthMethod=None\x00")) || !bytes.Contains(resp.data, []byte("TargetPortalGroupTag=1\x00")) {
+		t.Errorf("login keys = %q", resp.data)
+	}
+
+	data, status := in.command([]byte{scsiInquiry, 0, 0, 0, 36}, 36)
+	if status != statusGood || len(data) != 36 || data[0] != deviceTypeCDROM || string(data[8:16]) != "BOOTIMUS" {
+		t.Errorf("INQUIRY = %x, status %d", data, status)
+	}
+
+	data, status = in.command([]byte{scsiReadCapacity10}, 8)
+	if status != statusGood || binary.BigEndian.Uint32(data) != 2 || binary.BigEndian.Uint32(data[4:]) != cdromBlockSize {
+		t.Errorf("READ CAPACITY = %x, status %d", data, status)
+	}
+
+	// Two blocks from LBA 1, split across Data-In PDUs of at most 1024 bytes.
+	data, status = in.command([]byte{scsiRead10, 0, 0, 0, 0, 1, 0, 0, 2, 0}, 2*cdromBlockSize)
+	if status != statusGood || !bytes.Equal(data, image[cdromBlockSize:]) {
+		t.Errorf("READ(10) returned %d bytes, status %d", len(data), status)
+	}
+	if len(reads) != 1 || reads[0] != [2]int64{cdromBlockSize, 2 * cdromBlockSize} {
+		t.Errorf("OnRead saw %v", reads)
+	}
+
+	if _, status = in.command([]byte{scsiRead10, 0, 0, 0, 0, 2, 0, 0, 2, 0}, 2*cdromBlockSize); status != statusCheckCondition {
+		t.Errorf("READ past the end: status %d, want CHECK CONDITION", status)
+	}
+	if _, status = in.command([]byte{scsiWrite10, 0, 0, 0, 0, 0, 0, 0, 1, 0}, cdromBlockSize); status != statusCheckCondition {
+		t.Errorf("WRITE: status %d, want CHECK CONDITION", status)
+	}
+
+	logout := &pdu{}
+	logout.bhs[0] = 0x40 | opLogoutReq
+	logout.bhs[1] = 0x80
+	if resp := in.send(logout); resp.opcode() != opLogoutResp {
+		t.Errorf("logout: opcode %#x", resp.opcode())
+	}
+	<-in.closed
+}
+
+func TestLoginUnknownTarget(t *testing.T) {
+	s, _ := testServer(t)
+	in := dial(t, s)
+	resp := in.login(
+		[2]string{"InitiatorName", "iqn.2010-04.org.ipxe:test"},
+		[2]string{"TargetName", DefaultPrefix + ":missing.iso"},
+	)
+	if status := binary.BigEndian.Uint16(resp.bhs[36:]); status != loginNotFound {
+		t.Errorf("status = %#04x, want %#04x", status, loginNotFound)
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	s, _ := testServer(t)
+	in := dial(t, s)
+	resp := in.login(
+		[2]string{"InitiatorName", "iqn.2010-04.org.ipxe:test"},
+		[2]string{"SessionType", "Discovery"},
+	)
+	if status := binary.BigEndian.Uint16(resp.bhs[36:]); status != loginSuccess {
+		t.Fatalf("login status %#04x", status)
+	}
+	text := &pdu{data: encodeText([][2]string{{"SendTargets", "All"}})}
+	text.bhs[0] = opTextReq
+	text.bhs[1] = 0x80
+	resp = in.send(text)
+	if !bytes.HasPrefix(resp.data, []byte("TargetName="+DefaultPrefix+":test-image.iso\x00TargetAddress=")) {
+		t.Errorf("SendTargets = %q", resp.data)
+	}
+}
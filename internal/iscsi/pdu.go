@@ -0,0 +1,117 @@
+package iscsi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Opcodes (RFC 7143 section 11). Initiator opcodes have 0x00-0x1f, target
+// opcodes 0x20-0x3f.
+const (
+	opNOPOut      = 0x00
+	opSCSICommand = 0x01
+	opTaskMgmt    = 0x02
+	opLoginReq    = 0x03
+	opTextReq     = 0x04
+	opLogoutReq   = 0x06
+
+	opNOPIn        = 0x20
+	opSCSIResponse = 0x21
+	opTaskMgmtResp = 0x22
+	opLoginResp    = 0x23
+	opTextResp     = 0x24
+	opDataIn       = 0x25
+	opLogoutResp   = 0x26
+	opReject       = 0x3f
+)
+
+const (
+	bhsLen = 48
+
+	// maxDataSegment bounds what an initiator may send us in one PDU; it is
+	// also what we declare as our MaxRecvDataSegmentLength.
+	maxDataSegment = 64 << 10
+
+	reservedTag = 0xffffffff
+)
+
+// pdu is one iSCSI protocol data unit: the 48-byte basic header segment and
+// its data segment. Additional header segments are read and dropped.
+type pdu struct {
+	bhs  [bhsLen]byte
+	data []byte
+}
+
+func (p *pdu) opcode() byte    { return p.bhs[0] & 0x3f }
+func (p *pdu) immediate() bool { return p.bhs[0]&0x40 != 0 }
+func (p *pdu) flags() byte     { return p.bhs[1] }
+func (p *pdu) lun() uint64     { return binary.BigEndian.Uint64(p.bhs[8:]) }
+func (p *pdu) itt() uint32     { return binary.BigEndian.Uint32(p.bhs[16:]) }
+func (p *pdu) cmdSN() uint32   { return binary.BigEndian.Uint32(p.bhs[24:]) }
+func (p *pdu) u32(off int) uint32 {
+	return binary.BigEndian.Uint32(p.bhs[off:])
+}
+
+func (p *pdu) putU32(off int, v uint32) { binary.BigEndian.PutUint32(p.bhs[off:], v) }
+
+func readPDU(r io.Reader) (*pdu, error) {
+	p := &pdu{}
+	if _, err := io.ReadFull(r, p.bhs[:]); err != nil {
+		return nil, err
+	}
+	ahs := int(p.bhs[4]) * 4
+	n := int(p.bhs[5])<<16 | int(p.bhs[6])<<8 | int(p.bhs[7])
+	if n > maxDataSegment {
+		return nil, fmt.Errorf("data segment of %d bytes exceeds %d", n, maxDataSegment)
+	}
+	if ahs > 0 {
+		if _, err := io.CopyN(io.Discard, r, int64(ahs)); err != nil {
+			return nil, err
+		}
+	}
+	buf := make([]byte, (n+3)&^3)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	p.data = buf[:n]
+	return p, nil
+}
+
+func writePDU(w io.Writer, p *pdu) error {
+	n := len(p.data)
+	p.bhs[4] = 0
+	p.bhs[5], p.bhs[6], p.bhs[7] = byte(n>>16), byte(n>>8), byte(n)
+	buf := make([]byte, bhsLen+(n+3)&^3)
+	copy(buf, p.bhs[:])
+	copy(buf[bhsLen:], p.data)
+	_, err := w.Write(buf)
+	return err
+}
+
+// parseText splits a login or text data segment into its key=value pairs,
+// keeping their order.
+func parseText(b []byte) [][2]string {
+	var kv [][2]string
+	for _, field := range bytes.Split(b, []byte{0}) {
+		if len(field) == 0 {
+			continue
+		}
+		k, v, _ := strings.Cut(string(field), "=")
+		kv = append(kv, [2]string{k, v})
+	}
+	return kv
+}
+
+func encodeText(kv [][2]string) []byte {
+	var buf bytes.Buffer
+	for _, p := range kv {
+		buf.WriteString(p[0])
+		buf.WriteByte('=')
+		buf.WriteString(p[1])
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,380 @@
+package iscsi
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SCSI operation codes the target answers (SPC-4, SBC-3, MMC-6).
+const (
+	scsiTestUnitReady    = 0x00
+	scsiRequestSense     = 0x03
+	scsiRead6            = 0x08
+	scsiWrite6           = 0x0a
+	scsiInquiry          = 0x12
+	scsiModeSense6       = 0x1a
+	scsiStartStopUnit    = 0x1b
+	scsiPreventAllow     = 0x1e
+	scsiReadCapacity10   = 0x25
+	scsiRead10           = 0x28
+	scsiWrite10          = 0x2a
+	scsiVerify10         = 0x2f
+	scsiSyncCache10      = 0x35
+	scsiReadTOC          = 0x43
+	scsiGetConfiguration = 0x46
+	scsiGetEventStatus   = 0x4a
+	scsiModeSense10      = 0x5a
+	scsiRead16           = 0x88
+	scsiWrite16          = 0x8a
+	scsiServiceAction16  = 0x9e
+	scsiReportLUNs       = 0xa0
+	scsiRead12           = 0xa8
+	scsiWrite12          = 0xaa
+
+	saReadCapacity16 = 0x10
+)
+
+const (
+	statusGood           = 0x00
+	statusCheckCondition = 0x02
+
+	senseIllegalRequest = 0x05
+	senseDataProtect    = 0x07
+
+	ascInvalidOpcode   = 0x20
+	ascLBAOutOfRange   = 0x21
+	ascInvalidField    = 0x24
+	ascLUNNotSupported = 0x25
+	ascWriteProtected  = 0x27
+)
+
+const (
+	deviceTypeDisk  = 0x00
+	deviceTypeCDROM = 0x05
+	diskBlockSize   = 512
+	cdromBlockSize  = 2048
+
+	readTOCFormatTOC      = 0
+	readTOCFormatSessions = 1
+)
+
+// lun is the image behind a target's LUN 0.
+type lun struct {
+	f         *os.File
+	size      int64
+	blockSize int64
+	cdrom     bool
+	serial    string
+}
+
+func newLUN(f *os.File, name string, size int64) *lun {
+	l := &lun{f: f, size: size, blockSize: diskBlockSize}
+	if strings.EqualFold(filepath.Ext(f.Name()), ".iso") {
+		l.cdrom = true
+		l.blockSize = cdromBlockSize
+	}
+	sum := sha1.Sum([]byte(name))
+	l.serial = hex.EncodeToString(sum[:8])
+	return l
+}
+
+func (l *lun) blocks() int64 {
+	return (l.size + l.blockSize - 1) / l.blockSize
+}
+
+// ReadAt reads the image, padding a short final block with zeroes.
+func (l *lun) ReadAt(p []byte, off int64) (int, error) {
+	n, err := l.f.ReadAt(p, off)
+	if errors.Is(err, io.EOF) {
+		clear(p[n:])
+		return len(p), nil
+	}
+	return n, err
+}
+
+type senseError struct {
+	key, asc byte
+}
+
+func (c *conn) command(p *pdu) error {
+	cdb := p.bhs[32:48]
+	want := int64(p.u32(20))
+	if p.lun() != 0 {
+		return c.checkCondition(p, senseError{senseIllegalRequest, ascLUNNotSupported})
+	}
+	l := c.lun
+
+	switch cdb[0] {
+	case scsiRead6, scsiRead10, scsiRead12, scsiRead16:
+		lba, count := readRange(cdb)
+		if lba+count > uint64(l.blocks()) || lba+count < lba {
+			return c.checkCondition(p, senseError{senseIllegalRequest, ascLBAOutOfRange})
+		}
+		off, length := int64(lba)*l.blockSize, int64(count)*l.blockSize
+		if c.s.OnRead != nil && length > 0 {
+			c.s.OnRead(c.target.Image, l.size, off, length)
+		}
+		return c.dataIn(p, io.NewSectionReader(l, off, length), length, want)
+	case scsiWrite6, scsiWrite10, scsiWrite12, scsiWrite16:
+		return c.checkCondition(p, senseError{senseDataProtect, ascWriteProtected})
+	}
+
+	data, serr := l.execute(cdb)
+	if serr != nil {
+		return c.checkCondition(p, *serr)
+	}
+	return c.dataIn(p, bytes.NewReader(data), int64(len(data)), want)
+}
+
+// readRange decodes the LBA and block count of a READ(6/10/12/16).
+func readRange(cdb []byte) (lba, count uint64) {
+	be16, be32, be64 := binary.BigEndian.Uint16, binary.BigEndian.Uint32, binary.BigEndian.Uint64
+	switch cdb[0] {
+	case scsiRead6:
+		lba = uint64(cdb[1]&0x1f)<<16 | uint64(cdb[2])<<8 | uint64(cdb[3])
+		count = uint64(cdb[4])
+		if count == 0 {
+			count = 256
+		}
+	case scsiRead10:
+		lba, count = uint64(be32(cdb[2:])), uint64(be16(cdb[7:]))
+	case scsiRead12:
+		lba, count = uint64(be32(cdb[2:])), uint64(be32(cdb[6:]))
+	case scsiRead16:
+		lba, count = be64(cdb[2:]), uint64(be32(cdb[10:]))
+	}
+	return lba, count
+}
+
+// dataIn sends length bytes of r as Data-In PDUs, the last carrying GOOD
+// status, trimmed to the initiator's expected transfer length want.
+func (c *conn) dataIn(p *pdu, r io.Reader, length, want int64) error {
+	n := min(length, want)
+	var flags byte
+	var residual uint32
+	switch {
+	case length > want:
+		flags, residual = 0x04, uint32(length-want)
+	case length < want:
+		flags, residual = 0x02, uint32(want-length)
+	}
+
+	if n == 0 {
+		resp := &pdu{}
+		resp.bhs[0] = opSCSIResponse
+		resp.bhs[1] = 0x80 | flags
+		resp.bhs[3] = statusGood
+		resp.putU32(16, p.itt())
+		resp.putU32(44, residual)
+		return c.respond(resp)
+	}
+
+	buf := make([]byte, min(int64(c.maxSend), n))
+	var sn uint32
+	for off := int64(0); off < n; sn++ {
+		chunk := min(int64(len(buf)), n-off, int64(c.maxBurst)-off%int64(c.maxBurst))
+		if _, err := io.ReadFull(r, buf[:chunk]); err != nil {
+			return err
+		}
+		off += chunk
+
+		d := &pdu{data: buf[:chunk]}
+		d.bhs[0] = opDataIn
+		if off%int64(c.maxBurst) == 0 {
+			d.bhs[1] = 0x80
+		}
+		copy(d.bhs[8:16], p.bhs[8:16])
+		d.putU32(16, p.itt())
+		d.putU32(20, reservedTag)
+		d.putU32(36, sn)
+		d.putU32(40, uint32(off-chunk))
+		if off == n {
+			// Phase collapse: the final PDU carries the status.
+			d.bhs[1] = 0x81 | flags
+			d.bhs[3] = statusGood
+			d.putU32(44, residual)
+			if err := c.respond(d); err != nil {
+				return err
+			}
+			break
+		}
+		d.putU32(28, c.expCmdSN)
+		d.putU32(32, c.expCmdSN+cmdWindow)
+		if err := writePDU(c.nc, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) checkCondition(p *pdu, e senseError) error {
+	sense := make([]byte, 2+18)
+	binary.BigEndian.PutUint16(sense, 18)
+	sense[2] = 0x70 // current error, fixed format
+	sense[2+2] = e.key
+	sense[2+7] = 10
+	sense[2+12] = e.asc
+
+	resp := &pdu{data: sense}
+	resp.bhs[0] = opSCSIResponse
+	resp.bhs[1] = 0x80
+	resp.bhs[3] = statusCheckCondition
+	resp.putU32(16, p.itt())
+	if want := p.u32(20); want > 0 {
+		resp.bhs[1] |= 0x02
+		resp.putU32(44, want)
+	}
+	return c.respond(resp)
+}
+
+// execute answers every command other than READ and WRITE, returning its
+// data-in buffer already cut to the CDB's allocation length.
+func (l *lun) execute(cdb []byte) ([]byte, *senseError) {
+	be16, be32 := binary.BigEndian.Uint16, binary.BigEndian.Uint32
+	alloc := func(b []byte, n int) []byte { return b[:min(len(b), n)] }
+
+	switch cdb[0] {
+	case scsiTestUnitReady, scsiStartStopUnit, scsiPreventAllow, scsiSyncCache10, scsiVerify10:
+		return nil, nil
+
+	case scsiRequestSense:
+		b := make([]byte, 18)
+		b[0], b[7] = 0x70, 10
+		return alloc(b, int(cdb[4])), nil
+
+	case scsiInquiry:
+		b, ok := l.inquiry(cdb[1]&1 != 0, cdb[2])
+		if !ok {
+			return nil, &senseError{senseIllegalRequest, ascInvalidField}
+		}
+		return alloc(b, int(be16(cdb[3:]))), nil
+
+	case scsiReadCapacity10:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint32(b, uint32(min(l.blocks()-1, 0xffffffff)))
+		binary.BigEndian.PutUint32(b[4:], uint32(l.blockSize))
+		return b, nil
+
+	case scsiServiceAction16:
+		if cdb[1]&0x1f != saReadCapacity16 {
+			break
+		}
+		b := make([]byte, 32)
+		binary.BigEndian.PutUint64(b, uint64(l.blocks()-1))
+		binary.BigEndian.PutUint32(b[8:], uint32(l.blockSize))
+		return alloc(b, int(be32(cdb[10:]))), nil
+
+	case scsiModeSense6:
+		// No mode pages, just a header with the write-protect bit.
+		return alloc([]byte{3, 0, 0x80, 0}, int(cdb[4])), nil
+
+	case scsiModeSense10:
+		return alloc([]byte{0, 6, 0, 0x80, 0, 0, 0, 0}, int(be16(cdb[7:]))), nil
+
+	case scsiReportLUNs:
+		b := make([]byte, 16)
+		b[3] = 8 // one LUN, number 0
+		return alloc(b, int(be32(cdb[6:]))), nil
+
+	case scsiReadTOC:
+		if !l.cdrom {
+			break
+		}
+		b, ok := l.readTOC(cdb[1]&0x02 != 0, cdb[2]&0x0f)
+		if !ok {
+			return nil, &senseError{senseIllegalRequest, ascInvalidField}
+		}
+		return alloc(b, int(be16(cdb[7:]))), nil
+
+	case scsiGetConfiguration:
+		if !l.cdrom {
+			break
+		}
+		// Feature header only, current profile CD-ROM.
+		return alloc([]byte{0, 0, 0, 4, 0, 0, 0, 0x08}, int(be16(cdb[7:]))), nil
+
+	case scsiGetEventStatus:
+		if !l.cdrom {
+			break
+		}
+		// No event available.
+		return alloc([]byte{0, 2, 0x80, 0}, int(be16(cdb[7:]))), nil
+	}
+	return nil, &senseError{senseIllegalRequest, ascInvalidOpcode}
+}
+
+func (l *lun) inquiry(evpd bool, page byte) ([]byte, bool) {
+	devType := byte(deviceTypeDisk)
+	if l.cdrom {
+		devType = deviceTypeCDROM
+	}
+	if !evpd {
+		b := make([]byte, 36)
+		b[0] = devType
+		if l.cdrom {
+			b[1] = 0x80 // removable
+		}
+		b[2] = 0x05 // SPC-3
+		b[3] = 0x02
+		b[4] = byte(len(b) - 5)
+		copy(b[8:16], "BOOTIMUS")
+		copy(b[16:32], "Image           ")
+		copy(b[32:36], "1.0 ")
+		return b, true
+	}
+
+	var body []byte
+	switch page {
+	case 0x00:
+		body = []byte{0x00, 0x80, 0x83}
+	case 0x80:
+		body = []byte(l.serial)
+	case 0x83:
+		// One T10 vendor ID designator.
+		id := append([]byte("BOOTIMUS"), l.serial...)
+		body = append([]byte{0x02, 0x01, 0, byte(len(id))}, id...)
+	default:
+		return nil, false
+	}
+	b := []byte{devType, page, 0, byte(len(body))}
+	return append(b, body...), true
+}
+
+// readTOC describes a single data track starting at LBA 0, as an ISO is.
+func (l *lun) readTOC(msf bool, format byte) ([]byte, bool) {
+	addr := func(lba int64) []byte {
+		b := make([]byte, 4)
+		if msf {
+			lba += 150
+			b[1], b[2], b[3] = byte(lba/(60*75)), byte(lba/75%60), byte(lba%75)
+		} else {
+			binary.BigEndian.PutUint32(b, uint32(lba))
+		}
+		return b
+	}
+	track := func(n byte, lba int64) []byte {
+		return append([]byte{0, 0x14, n, 0}, addr(lba)...)
+	}
+
+	var b []byte
+	switch format {
+	case readTOCFormatTOC:
+		b = []byte{0, 0, 1, 1}
+		b = append(b, track(1, 0)...)
+		b = append(b, track(0xaa, l.blocks())...)
+	case readTOCFormatSessions:
+		b = []byte{0, 0, 1, 1}
+		b = append(b, track(1, 0)...)
+	default:
+		return nil, false
+	}
+	binary.BigEndian.PutUint16(b, uint16(len(b)-2))
+	return b, true
+}
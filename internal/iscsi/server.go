@@ -0,0 +1,430 @@
+// Package iscsi is a read-only iSCSI target (RFC 7143) that publishes image
+// files as single-LUN targets. An ISO appears as a CD-ROM with 2048-byte
+// blocks, anything else as a disk with 512-byte blocks, so iPXE can sanboot
+// either and Windows setup can keep reading its media after iPXE hands over.
+package iscsi
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultPrefix is the IQN naming authority targets are published under.
+const DefaultPrefix = "iqn.2024-01.io.bootimus"
+
+// Target is one published image.
+type Target struct {
+	Name  string // IQN
+	Path  string // file served as LUN 0
+	Image string // image filename, as passed to OnRead
+}
+
+type Server struct {
+	port     int
+	targets  func() []Target
+	listener net.Listener
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+
+	// OnRead, if set, is told about every READ an initiator issues.
+	OnRead func(image string, size, offset, length int64)
+}
+
+// NewServer publishes whatever targets returns; it is called at each login,
+// so images can be published and withdrawn without a restart.
+func NewServer(port int, targets func() []Target) *Server {
+	return &Server{
+		port:    port,
+		targets: targets,
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// TargetName is the IQN filename is published under. IQNs allow only
+// lower-case letters, digits, '-', '.' and ':', so anything else becomes '-'.
+func TargetName(prefix, filename string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, filename)
+	return prefix + ":" + name
+}
+
+func (s *Server) Start() error {
+	addr := fmt.Sprintf(":%d", s.port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start iSCSI target: %w", err)
+	}
+
+	s.listener = listener
+	log.Printf("iSCSI target listening on %s", addr)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			log.Printf("iSCSI: accept error: %v", err)
+			continue
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.handleConn(conn)
+	}
+}
+
+// Stop closes the listener and drops every session.
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+func (s *Server) lookup(name string) (Target, bool) {
+	for _, t := range s.targets() {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Target{}, false
+}
+
+func (s *Server) handleConn(nc net.Conn) {
+	c := &conn{
+		s:        s,
+		nc:       nc,
+		maxSend:  8192,
+		maxBurst: 262144,
+	}
+	defer func() {
+		if c.lun != nil {
+			c.lun.f.Close()
+		}
+		nc.Close()
+		s.mu.Lock()
+		delete(s.conns, nc)
+		s.mu.Unlock()
+	}()
+
+	if err := c.login(); err != nil {
+		log.Printf("iSCSI: login from %s failed: %v", nc.RemoteAddr(), err)
+		return
+	}
+	if c.lun != nil {
+		log.Printf("iSCSI: %s (%s) logged in to %s", nc.RemoteAddr(), c.initiator, c.target.Name)
+	}
+	if err := c.serve(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("iSCSI: session from %s ended: %v", nc.RemoteAddr(), err)
+	}
+}
+
+// conn is one iSCSI connection; sessions have exactly one (MaxConnections=1).
+type conn struct {
+	s  *Server
+	nc net.Conn
+
+	statSN   uint32
+	expCmdSN uint32
+
+	// maxSend is the initiator's MaxRecvDataSegmentLength, the largest data
+	// segment we may send it; maxBurst bounds each Data-In sequence.
+	maxSend  int
+	maxBurst int
+
+	initiator string
+	discovery bool
+	target    Target
+	lun       *lun
+}
+
+// cmdWindow is how far past ExpCmdSN the initiator may queue commands.
+const cmdWindow = 32
+
+func (c *conn) sequence(p *pdu) {
+	p.putU32(24, c.statSN)
+	p.putU32(28, c.expCmdSN)
+	p.putU32(32, c.expCmdSN+cmdWindow)
+}
+
+// Login status classes and details (RFC 7143 section 11.13.5).
+const (
+	loginSuccess        = 0x0000
+	loginAuthFailure    = 0x0201
+	loginNotFound       = 0x0203
+	loginMissingParam   = 0x0207
+	loginTargetError    = 0x0300
+	loginUnsupportedVer = 0x0205
+)
+
+const stageFullFeature = 3
+
+func (c *conn) login() error {
+	first := true
+	for {
+		p, err := readPDU(c.nc)
+		if err != nil {
+			return err
+		}
+		if p.opcode() != opLoginReq {
+			return fmt.Errorf("opcode %#x before login completed", p.opcode())
+		}
+		if first {
+			c.statSN = p.u32(28)
+		}
+		c.expCmdSN = p.cmdSN()
+
+		flags := p.flags()
+		transit := flags&0x80 != 0
+		csg := (flags >> 2) & 3
+		nsg := flags & 3
+
+		reply, status := c.negotiate(parseText(p.data), first)
+		if status == loginSuccess && p.bhs[3] > 0 {
+			// Version-min above the only version there is.
+			status = loginUnsupportedVer
+		}
+		first = false
+
+		resp := &pdu{data: encodeText(reply)}
+		resp.bhs[0] = opLoginResp
+		if status == loginSuccess {
+			resp.bhs[1] = flags & 0x8c
+			if transit {
+				resp.bhs[1] |= nsg
+			}
+		} else {
+			resp.bhs[1] = csg << 2
+		}
+		copy(resp.bhs[8:14], p.bhs[8:14]) // ISID
+		done := status == loginSuccess && transit && nsg == stageFullFeature
+		if done {
+			resp.bhs[15] = 1 // TSIH
+		}
+		resp.putU32(16, p.itt())
+		c.sequence(resp)
+		resp.bhs[36] = byte(status >> 8)
+		resp.bhs[37] = byte(status)
+		if err := writePDU(c.nc, resp); err != nil {
+			return err
+		}
+		if status != loginSuccess {
+			return fmt.Errorf("login rejected with status %#04x", status)
+		}
+		if done {
+			c.statSN++
+			return nil
+		}
+	}
+}
+
+// negotiate answers the keys of one login request. It accepts no
+// authentication and settles everything else on the simplest values: one
+// connection, no digests, in-order data and no error recovery.
+func (c *conn) negotiate(keys [][2]string, first bool) ([][2]string, int) {
+	var reply [][2]string
+	for _, kv := range keys {
+		k, v := kv[0], kv[1]
+		switch k {
+		case "InitiatorName":
+			c.initiator = v
+		case "InitiatorAlias":
+		case "SessionType":
+			c.discovery = v == "Discovery"
+		case "TargetName":
+			t, ok := c.s.lookup(v)
+			if !ok {
+				return nil, loginNotFound
+			}
+			f, err := os.Open(t.Path)
+			if err != nil {
+				log.Printf("iSCSI: failed to open %s: %v", t.Path, err)
+				return nil, loginTargetError
+			}
+			fi, err := f.Stat()
+			if err != nil {
+				f.Close()
+				return nil, loginTargetError
+			}
+			if c.lun != nil {
+				c.lun.f.Close()
+			}
+			c.target = t
+			c.lun = newLUN(f, t.Name, fi.Size())
+		case "AuthMethod":
+			if !listHas(v, "None") {
+				return nil, loginAuthFailure
+			}
+			reply = append(reply, [2]string{k, "None"})
+		case "HeaderDigest", "DataDigest":
+			reply = append(reply, [2]string{k, "None"})
+		case "MaxRecvDataSegmentLength":
+			if n, err := strconv.Atoi(v); err == nil && n >= 512 {
+				c.maxSend = min(n, 1<<24-1)
+			}
+			reply = append(reply, [2]string{k, strconv.Itoa(maxDataSegment)})
+		case "MaxBurstLength":
+			if n, err := strconv.Atoi(v); err == nil && n >= 512 {
+				c.maxBurst = min(n, c.maxBurst)
+			}
+			reply = append(reply, [2]string{k, strconv.Itoa(c.maxBurst)})
+		case "FirstBurstLength":
+			n, _ := strconv.Atoi(v)
+			reply = append(reply, [2]string{k, strconv.Itoa(max(512, min(n, 65536)))})
+		case "MaxConnections", "MaxOutstandingR2T":
+			reply = append(reply, [2]string{k, "1"})
+		case "InitialR2T", "DataPDUInOrder", "DataSequenceInOrder":
+			reply = append(reply, [2]string{k, "Yes"})
+		case "ImmediateData", "IFMarker", "OFMarker":
+			reply = append(reply, [2]string{k, "No"})
+		case "ErrorRecoveryLevel", "DefaultTime2Retain":
+			reply = append(reply, [2]string{k, "0"})
+		case "DefaultTime2Wait":
+			reply = append(reply, [2]string{k, v})
+		default:
+			reply = append(reply, [2]string{k, "NotUnderstood"})
+		}
+	}
+	if first && !c.discovery {
+		if c.lun == nil {
+			return nil, loginMissingParam
+		}
+		reply = append(reply, [2]string{"TargetPortalGroupTag", "1"})
+	}
+	return reply, loginSuccess
+}
+
+func listHas(list, want string) bool {
+	for _, v := range strings.Split(list, ",") {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// serve runs the full feature phase until the initiator logs out or the
+// connection drops.
+func (c *conn) serve() error {
+	for {
+		p, err := readPDU(c.nc)
+		if err != nil {
+			return err
+		}
+		if !p.immediate() {
+			c.expCmdSN = p.cmdSN() + 1
+		}
+
+		switch p.opcode() {
+		case opNOPOut:
+			if p.itt() == reservedTag {
+				continue
+			}
+			resp := &pdu{data: p.data}
+			resp.bhs[0] = opNOPIn
+			resp.bhs[1] = 0x80
+			copy(resp.bhs[8:16], p.bhs[8:16])
+			resp.putU32(16, p.itt())
+			resp.putU32(20, reservedTag)
+			err = c.respond(resp)
+		case opSCSICommand:
+			if c.lun == nil {
+				err = c.reject(p, 0x04)
+			} else {
+				err = c.command(p)
+			}
+		case opTextReq:
+			err = c.text(p)
+		case opTaskMgmt:
+			resp := &pdu{}
+			resp.bhs[0] = opTaskMgmtResp
+			resp.bhs[1] = 0x80
+			resp.putU32(16, p.itt())
+			err = c.respond(resp)
+		case opLogoutReq:
+			resp := &pdu{}
+			resp.bhs[0] = opLogoutResp
+			resp.bhs[1] = 0x80
+			resp.putU32(16, p.itt())
+			return c.respond(resp)
+		default:
+			err = c.reject(p, 0x04)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// respond sends a PDU that carries status, advancing StatSN.
+func (c *conn) respond(p *pdu) error {
+	c.sequence(p)
+	c.statSN++
+	return writePDU(c.nc, p)
+}
+
+func (c *conn) reject(p *pdu, reason byte) error {
+	resp := &pdu{data: append([]byte(nil), p.bhs[:]...)}
+	resp.bhs[0] = opReject
+	resp.bhs[1] = 0x80
+	resp.bhs[2] = reason
+	resp.putU32(16, reservedTag)
+	return c.respond(resp)
+}
+
+// text answers SendTargets, the only text request a target has to handle,
+// with each published target and the portal the initiator reached us on.
+func (c *conn) text(p *pdu) error {
+	var reply [][2]string
+	for _, kv := range parseText(p.data) {
+		if kv[0] != "SendTargets" {
+			continue
+		}
+		var targets []Target
+		switch kv[1] {
+		case "All":
+			if c.discovery {
+				targets = c.s.targets()
+			}
+		case "":
+			if c.lun != nil {
+				targets = []Target{c.target}
+			}
+		default:
+			if t, ok := c.s.lookup(kv[1]); ok {
+				targets = []Target{t}
+			}
+		}
+		for _, t := range targets {
+			reply = append(reply,
+				[2]string{"TargetName", t.Name},
+				[2]string{"TargetAddress", c.nc.LocalAddr().String() + ",1"})
+		}
+	}
+	resp := &pdu{data: encodeText(reply)}
+	resp.bhs[0] = opTextResp
+	resp.bhs[1] = 0x80
+	resp.putU32(16, p.itt())
+	resp.putU32(20, reservedTag)
+	return c.respond(resp)
+}
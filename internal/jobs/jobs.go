@@ -0,0 +1,481 @@
+// Package jobs tracks long-running admin operations such as extractions,
+// ISO and netboot downloads and boot.wim rebuilds: their state, a log of
+// their steps, cancellation and, for kinds with a registered runner,
+// retries. Jobs are recorded in the database, so the recent ones and their
+// logs outlive a restart; one still running when the server stopped comes
+// back as interrupted.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bootimus/internal/models"
+)
+
+const (
+	StatusRunning     = "running"
+	StatusDone        = "done"
+	StatusFailed      = "failed"
+	StatusCancelled   = "cancelled"
+	StatusInterrupted = "interrupted" // running when the server stopped
+)
+
+// maxTracked is how many jobs are kept, in memory and in the database.
+const maxTracked = 200
+
+var (
+	ErrRunning    = errors.New("a job for this is already running")
+	ErrNotFound   = errors.New("job not found")
+	ErrNoRunner   = errors.New("jobs of this kind can't be retried")
+	ErrNotStopped = errors.New("the job is still running")
+	ErrSucceeded  = errors.New("the job succeeded")
+)
+
+// Store is where jobs are recorded; storage.Storage is one.
+type Store interface {
+	SaveJob(job *models.Job) error
+	ListJobs(limit int) ([]*models.Job, error)
+	DeleteJobsBefore(id uint64) error
+}
+
+type Info struct {
+	ID         uint64            `json:"id"`
+	Kind       string            `json:"kind"`
+	Target     string            `json:"target"`
+	Params     map[string]string `json:"params,omitempty"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	LogLines   int               `json:"log_lines"`
+	Progress   int               `json:"progress,omitempty"` // percent done, for jobs that can tell
+	Attempts   int               `json:"attempts,omitempty"`
+	RetryOf    uint64            `json:"retry_of,omitempty"` // the job this one retries
+
+	// Extractions also report their stage and what has been copied out.
+	Stage      string `json:"stage,omitempty"`
+	Files      int64  `json:"files,omitempty"`
+	Bytes      int64  `json:"bytes,omitempty"`
+	TotalBytes int64  `json:"total_bytes,omitempty"`
+
+	Cancellable bool `json:"cancellable,omitempty"`
+	Retryable   bool `json:"retryable,omitempty"`
+}
+
+// Progress is what a job reports of its own progress while it runs.
+type Progress struct {
+	Stage      string
+	Percent    float64
+	Files      int64
+	Bytes      int64
+	TotalBytes int64
+}
+
+type Job struct {
+	Info
+
+	m         *Manager
+	mu        sync.Mutex
+	lines     []string
+	cancel    context.CancelFunc
+	cancelled bool
+	progress  func() Progress
+}
+
+// Logf writes a step to the server log and to the job's own log.
+func (j *Job) Logf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("Job %d (%s %s): %s", j.ID, j.Kind, j.Target, msg)
+	j.mu.Lock()
+	j.lines = append(j.lines, time.Now().Format("15:04:05")+" "+msg)
+	j.LogLines = len(j.lines)
+	j.mu.Unlock()
+}
+
+func (j *Job) SetProgress(percent int) {
+	j.mu.Lock()
+	j.Progress = percent
+	j.mu.Unlock()
+}
+
+// Track has the job report progress from p while it runs.
+func (j *Job) Track(p func() Progress) {
+	j.mu.Lock()
+	j.progress = p
+	j.mu.Unlock()
+}
+
+// CancelWith lets the job be cancelled over the API by calling cancel.
+func (j *Job) CancelWith(cancel context.CancelFunc) {
+	j.mu.Lock()
+	j.cancel = cancel
+	j.Cancellable = true
+	j.mu.Unlock()
+}
+
+// Cancel stops a running job that can be cancelled, reporting whether it
+// could.
+func (j *Job) Cancel() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.cancel == nil || j.Status != StatusRunning {
+		return false
+	}
+	j.cancelled = true
+	j.cancel()
+	return true
+}
+
+// Finish records how the job ended: done when err is nil, cancelled when it
+// was cancelled, failed otherwise.
+func (j *Job) Finish(err error) {
+	now := time.Now()
+	j.mu.Lock()
+	cancelled := j.cancelled && errors.Is(err, context.Canceled)
+	j.mu.Unlock()
+	switch {
+	case cancelled:
+		j.Logf("cancelled")
+	case err != nil:
+		j.Logf("failed: %v", err)
+	default:
+		j.Logf("finished")
+	}
+	j.mu.Lock()
+	j.FinishedAt = &now
+	j.Cancellable = false
+	switch {
+	case cancelled:
+		j.Status = StatusCancelled
+	case err != nil:
+		j.Status = StatusFailed
+		j.Error = err.Error()
+	default:
+		j.Status = StatusDone
+	}
+	j.mu.Unlock()
+	j.m.save(j)
+}
+
+// Snapshot returns the job's state as of now.
+func (j *Job) Snapshot() Info {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := j.Info
+	if j.progress != nil {
+		p := j.progress()
+		info.Stage, info.Files, info.Bytes, info.TotalBytes = p.Stage, p.Files, p.Bytes, p.TotalBytes
+		if info.Status == StatusRunning {
+			info.Progress = int(p.Percent)
+		}
+	}
+	info.Retryable = info.Status != StatusRunning && info.Status != StatusDone && j.m.runner(info.Kind) != nil
+	return info
+}
+
+// Lines returns the job's log, skipping the first since lines.
+func (j *Job) Lines(since int) []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if since < 0 {
+		since = 0
+	}
+	if since > len(j.lines) {
+		since = len(j.lines)
+	}
+	return j.lines[since:]
+}
+
+// Runner runs a job of one kind from its target and params. It is what a
+// job started with Go runs, and what a retry runs again.
+type Runner func(ctx context.Context, j *Job) error
+
+// Policy is how often a failed job is tried again on its own.
+type Policy struct {
+	Attempts int           // tries in all; 0 or 1 never retries by itself
+	Backoff  time.Duration // wait before the first retry, doubling after each
+}
+
+type kind struct {
+	policy Policy
+	run    Runner
+}
+
+type Manager struct {
+	store Store
+
+	mu     sync.RWMutex
+	nextID uint64
+	jobs   map[uint64]*Job
+
+	kindsMu sync.RWMutex // apart from mu, as snapshots taken under mu read kinds
+	kinds   map[string]kind
+}
+
+// New loads the recent jobs from store, marking those left running by a
+// restart as interrupted. store may be nil to keep jobs in memory only.
+func New(store Store) *Manager {
+	m := &Manager{store: store, jobs: make(map[uint64]*Job), kinds: make(map[string]kind)}
+	if store == nil {
+		return m
+	}
+	recs, err := store.ListJobs(maxTracked)
+	if err != nil {
+		log.Printf("Jobs: failed to load recent jobs: %v", err)
+		return m
+	}
+	for _, rec := range recs {
+		j := m.fromRecord(rec)
+		if j.Status == StatusRunning {
+			j.Status = StatusInterrupted
+			j.Error = "The server stopped while the job was running"
+			m.save(j)
+		}
+		m.jobs[j.ID] = j
+		m.nextID = max(m.nextID, j.ID)
+	}
+	if len(recs) == maxTracked {
+		if err := store.DeleteJobsBefore(recs[len(recs)-1].ID); err != nil {
+			log.Printf("Jobs: failed to prune old jobs: %v", err)
+		}
+	}
+	return m
+}
+
+// Handle registers how jobs of a kind run, so they can be started with Go
+// and retried.
+func (m *Manager) Handle(kindName string, policy Policy, run Runner) {
+	m.kindsMu.Lock()
+	m.kinds[kindName] = kind{policy: policy, run: run}
+	m.kindsMu.Unlock()
+}
+
+func (m *Manager) runner(kindName string) Runner {
+	m.kindsMu.RLock()
+	defer m.kindsMu.RUnlock()
+	return m.kinds[kindName].run
+}
+
+// Start starts tracking a job the caller runs itself and ends with Finish.
+func (m *Manager) Start(kindName, target string) *Job {
+	m.mu.Lock()
+	j := m.add(kindName, target, nil)
+	m.mu.Unlock()
+	m.save(j)
+	return j
+}
+
+// StartExclusive starts a job unless one of the same kind and target is
+// still running, which it returns instead.
+func (m *Manager) StartExclusive(kindName, target string) (*Job, bool) {
+	m.mu.Lock()
+	if j := m.runningFor(kindName, target); j != nil {
+		m.mu.Unlock()
+		return j, false
+	}
+	j := m.add(kindName, target, nil)
+	m.mu.Unlock()
+	m.save(j)
+	return j, true
+}
+
+// Go runs a job of a registered kind in the background, retrying it as its
+// policy allows until it succeeds or ctx is done. A job of the same kind
+// and target already running is returned with ErrRunning.
+func (m *Manager) Go(ctx context.Context, kindName, target string, params map[string]string) (*Job, error) {
+	return m.goJob(ctx, kindName, target, params, 0)
+}
+
+func (m *Manager) goJob(ctx context.Context, kindName, target string, params map[string]string, retryOf uint64) (*Job, error) {
+	m.kindsMu.RLock()
+	k, ok := m.kinds[kindName]
+	m.kindsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no runner for %s jobs", kindName)
+	}
+	m.mu.Lock()
+	if j := m.runningFor(kindName, target); j != nil {
+		m.mu.Unlock()
+		return j, ErrRunning
+	}
+	j := m.add(kindName, target, params)
+	j.RetryOf = retryOf
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	j.CancelWith(cancel)
+	m.save(j)
+	go func() {
+		defer cancel()
+		backoff := k.policy.Backoff
+		for {
+			j.mu.Lock()
+			j.Attempts++
+			attempt := j.Attempts
+			j.mu.Unlock()
+			err := k.run(ctx, j)
+			if err == nil || ctx.Err() != nil || attempt >= k.policy.Attempts {
+				j.Finish(err)
+				return
+			}
+			j.Logf("attempt %d of %d failed: %v; trying again in %v", attempt, k.policy.Attempts, err, backoff)
+			select {
+			case <-ctx.Done():
+				j.Finish(ctx.Err())
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}()
+	return j, nil
+}
+
+// Retry runs a finished job that didn't succeed again, as a new job.
+func (m *Manager) Retry(ctx context.Context, id uint64) (*Job, error) {
+	j, ok := m.Get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	info := j.Snapshot()
+	switch {
+	case info.Status == StatusRunning:
+		return nil, ErrNotStopped
+	case info.Status == StatusDone:
+		return nil, ErrSucceeded
+	case !info.Retryable:
+		return nil, ErrNoRunner
+	}
+	return m.goJob(ctx, info.Kind, info.Target, info.Params, info.ID)
+}
+
+// add creates a running job; m.mu must be held.
+func (m *Manager) add(kindName, target string, params map[string]string) *Job {
+	m.nextID++
+	j := &Job{m: m, Info: Info{ID: m.nextID, Kind: kindName, Target: target, Params: params, Status: StatusRunning, StartedAt: time.Now()}}
+	m.jobs[j.ID] = j
+	if len(m.jobs) > maxTracked {
+		cutoff := j.ID - maxTracked/2
+		var running []*Job
+		for id, old := range m.jobs {
+			if id >= cutoff {
+				continue
+			}
+			if old.Snapshot().Status == StatusRunning {
+				running = append(running, old)
+				continue
+			}
+			delete(m.jobs, id)
+		}
+		if m.store != nil {
+			if err := m.store.DeleteJobsBefore(cutoff); err != nil {
+				log.Printf("Jobs: failed to prune old jobs: %v", err)
+			}
+			// Old jobs still running keep their records.
+			for _, old := range running {
+				m.save(old)
+			}
+		}
+	}
+	return j
+}
+
+// runningFor returns the running job of a kind and target; m.mu must be
+// held.
+func (m *Manager) runningFor(kindName, target string) *Job {
+	for _, j := range m.jobs {
+		if info := j.Snapshot(); info.Kind == kindName && info.Target == target && info.Status == StatusRunning {
+			return j
+		}
+	}
+	return nil
+}
+
+func (m *Manager) Get(id uint64) (*Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	j, ok := m.jobs[id]
+	return j, ok
+}
+
+// Running reports whether a job of this kind has not finished yet.
+func (m *Manager) Running(kindName string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, j := range m.jobs {
+		if info := j.Snapshot(); info.Kind == kindName && info.Status == StatusRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the tracked jobs, newest first.
+func (m *Manager) List() []Info {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]Info, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		out = append(out, j.Snapshot())
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].ID > out[b].ID })
+	return out
+}
+
+func (m *Manager) save(j *Job) {
+	if m.store == nil {
+		return
+	}
+	j.mu.Lock()
+	rec := &models.Job{
+		ID:         j.ID,
+		Kind:       j.Kind,
+		Target:     j.Target,
+		Status:     j.Status,
+		Error:      j.Error,
+		Attempts:   j.Attempts,
+		RetryOf:    j.RetryOf,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+		Log:        strings.Join(j.lines, "\n"),
+	}
+	if len(j.Params) > 0 {
+		if b, err := json.Marshal(j.Params); err == nil {
+			rec.Params = string(b)
+		}
+	}
+	j.mu.Unlock()
+	if err := m.store.SaveJob(rec); err != nil {
+		log.Printf("Jobs: failed to record job %d: %v", rec.ID, err)
+	}
+}
+
+func (m *Manager) fromRecord(rec *models.Job) *Job {
+	j := &Job{m: m, Info: Info{
+		ID:         rec.ID,
+		Kind:       rec.Kind,
+		Target:     rec.Target,
+		Status:     rec.Status,
+		Error:      rec.Error,
+		StartedAt:  rec.StartedAt,
+		FinishedAt: rec.FinishedAt,
+		Attempts:   rec.Attempts,
+		RetryOf:    rec.RetryOf,
+	}}
+	if rec.Params != "" {
+		json.Unmarshal([]byte(rec.Params), &j.Params)
+	}
+	if rec.Log != "" {
+		j.lines = strings.Split(rec.Log, "\n")
+	}
+	j.LogLines = len(j.lines)
+	return j
+}
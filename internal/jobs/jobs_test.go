@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"bootimus/internal/models"
+)
+
+type memStore struct {
+	mu   sync.Mutex
+	jobs map[uint64]models.Job
+}
+
+func (s *memStore) SaveJob(j *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[j.ID] = *j
+	return nil
+}
+
+func (s *memStore) ListJobs(limit int) ([]*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*models.Job
+	for _, j := range s.jobs {
+		out = append(out, &j)
+	}
+	return out, nil
+}
+
+func (s *memStore) DeleteJobsBefore(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.jobs {
+		if k < id {
+			delete(s.jobs, k)
+		}
+	}
+	return nil
+}
+
+func wait(t *testing.T, j *Job) Info {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if info := j.Snapshot(); info.Status != StatusRunning {
+			return info
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %d still running", j.ID)
+	return Info{}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	m := New(&memStore{jobs: map[uint64]models.Job{}})
+	tries := 0
+	m.Handle("fetch", Policy{Attempts: 3, Backoff: time.Millisecond}, func(ctx context.Context, j *Job) error {
+		tries++
+		if tries < 3 {
+			return errors.New("mirror down")
+		}
+		return nil
+	})
+
+	j, err := m.Go(context.Background(), "fetch", "debian", map[string]string{"url": "http://x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := wait(t, j); info.Status != StatusDone || info.Attempts != 3 {
+		t.Errorf("got %s after %d attempts, want done after 3", info.Status, info.Attempts)
+	}
+}
+
+func TestCancelRetryAndRestart(t *testing.T) {
+	store := &memStore{jobs: map[uint64]models.Job{}}
+	m := New(store)
+	block := func(ctx context.Context, j *Job) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	m.Handle("extract", Policy{}, block)
+
+	j, err := m.Go(context.Background(), "extract", "a.iso", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Go(context.Background(), "extract", "a.iso", nil); !errors.Is(err, ErrRunning) {
+		t.Errorf("second run: %v, want ErrRunning", err)
+	}
+	if _, err := m.Retry(context.Background(), j.ID); !errors.Is(err, ErrNotStopped) {
+		t.Errorf("retry while running: %v", err)
+	}
+	if !j.Cancel() {
+		t.Fatal("not cancelled")
+	}
+	if info := wait(t, j); info.Status != StatusCancelled || !info.Retryable {
+		t.Fatalf("after cancel: %+v", info)
+	}
+
+	retry, err := m.Retry(context.Background(), j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry.RetryOf != j.ID {
+		t.Errorf("retry_of = %d", retry.RetryOf)
+	}
+
+	// The retry is still running when the "server" restarts.
+	m2 := New(store)
+	got, ok := m2.Get(retry.ID)
+	if !ok || got.Snapshot().Status != StatusInterrupted {
+		t.Fatalf("after restart: %v %+v", ok, got)
+	}
+	if old, _ := m2.Get(j.ID); old.Snapshot().Status != StatusCancelled || len(old.Lines(0)) == 0 {
+		t.Errorf("history lost: %+v", old.Snapshot())
+	}
+	if next := m2.Start("extract", "b.iso"); next.ID <= retry.ID {
+		t.Errorf("new job reused ID %d", next.ID)
+	}
+	retry.Cancel()
+}
+
+func TestPruneStore(t *testing.T) {
+	store := &memStore{jobs: map[uint64]models.Job{}}
+	m := New(store)
+	running := m.Start("long", "a")
+	for i := 0; i < 2*maxTracked; i++ {
+		m.Start("short", "b").Finish(nil)
+	}
+
+	store.mu.Lock()
+	n := len(store.jobs)
+	_, kept := store.jobs[running.ID]
+	store.mu.Unlock()
+	if n > maxTracked+1 {
+		t.Errorf("store holds %d jobs, want at most %d", n, maxTracked+1)
+	}
+	if !kept {
+		t.Error("running job's record was pruned")
+	}
+	if _, ok := m.Get(running.ID); !ok {
+		t.Error("running job was dropped from memory")
+	}
+}
@@ -0,0 +1,290 @@
+// Package library spreads ISO images over several directories, such as a
+// local SSD for images in use and a NAS mount for the archive, and finds
+// each image in whichever directory holds it.
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"bootimus/internal/securepath"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// DefaultName names the library in <data-dir>/isos.
+const DefaultName = "local"
+
+type Library struct {
+	Name string `mapstructure:"name" json:"name"`
+	Path string `mapstructure:"path" json:"path"`
+	// Libraries are searched lowest first; the default library is 0.
+	Priority int `mapstructure:"priority" json:"priority"`
+	// URL makes this a remote library cached in Path (by default
+	// <data-dir>/cache/<name>), keeping at most CacheGB of ISOs there.
+	URL     string `mapstructure:"url" json:"url,omitempty"`
+	CacheGB int    `mapstructure:"cache_gb" json:"cache_gb,omitempty"`
+}
+
+// Set is the default library plus any configured ones, in search order.
+type Set struct {
+	primary Library
+	libs    []Library
+
+	mu       sync.Mutex
+	listings map[string]map[string]int64 // remote library -> ISO -> size, from the last scan
+	fetching map[string]bool
+
+	ctx    context.Context // cancelled by Close to stop caching in progress
+	cancel context.CancelFunc
+}
+
+// New builds a Set around the default ISO directory, which uploads and
+// downloads always go to. On equal priority the default library comes
+// first, then the others in the order given.
+func New(isoDir string, extra []Library) (*Set, error) {
+	primary := Library{Name: DefaultName, Path: isoDir}
+	libs := []Library{primary}
+	seen := map[string]bool{DefaultName: true}
+	for _, l := range extra {
+		if l.Name == "" || (l.Path == "" && l.URL == "") {
+			return nil, errors.New("every library needs a name and a path or url")
+		}
+		if strings.ContainsAny(l.Name, `/\`) || l.Name == "." || l.Name == ".." {
+			return nil, fmt.Errorf("library name %q is not a plain name", l.Name)
+		}
+		if seen[l.Name] {
+			return nil, fmt.Errorf("library %q is defined twice", l.Name)
+		}
+		seen[l.Name] = true
+		if l.Remote() {
+			if u, err := url.Parse(l.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return nil, fmt.Errorf("library %q: url must be http or https", l.Name)
+			}
+			if l.Path == "" {
+				l.Path = remoteCacheDir(isoDir, l.Name)
+			}
+			if err := os.MkdirAll(l.Path, 0755); err != nil {
+				return nil, fmt.Errorf("library %q: %w", l.Name, err)
+			}
+		}
+		abs, err := filepath.Abs(l.Path)
+		if err != nil {
+			return nil, fmt.Errorf("library %q: %w", l.Name, err)
+		}
+		l.Path = abs
+		libs = append(libs, l)
+	}
+	sort.SliceStable(libs, func(i, j int) bool { return libs[i].Priority < libs[j].Priority })
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Set{
+		primary:  primary,
+		libs:     libs,
+		listings: map[string]map[string]int64{},
+		fetching: map[string]bool{},
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+// Close abandons any remote ISOs still being cached.
+func (s *Set) Close() {
+	s.cancel()
+}
+
+func (s *Set) Primary() Library {
+	return s.primary
+}
+
+func (s *Set) All() []Library {
+	return append([]Library(nil), s.libs...)
+}
+
+func (s *Set) Get(name string) (Library, bool) {
+	for _, l := range s.libs {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return Library{}, false
+}
+
+func (s *Set) Roots() []string {
+	roots := make([]string, len(s.libs))
+	for i, l := range s.libs {
+		roots[i] = l.Path
+	}
+	return roots
+}
+
+// Find returns the first library in which rel, a path relative to a
+// library root, exists.
+func (s *Set) Find(rel string) (Library, bool) {
+	for _, l := range s.libs {
+		if p, err := securepath.Join(l.Path, rel); err == nil {
+			if _, err := os.Stat(p); err == nil {
+				return l, true
+			}
+		}
+	}
+	return Library{}, false
+}
+
+// Dir returns the root of the library holding rel, or the default library's
+// if none does, so new files land next to an image's ISO.
+func (s *Set) Dir(rel string) string {
+	if l, ok := s.Find(rel); ok {
+		return l.Path
+	}
+	return s.primary.Path
+}
+
+// Join confines rel to the library holding it, as securepath.Join does for
+// a single root. A path found nowhere is joined to the default library.
+func (s *Set) Join(rel string) (string, error) {
+	if l, ok := s.Find(rel); ok {
+		return securepath.Join(l.Path, rel)
+	}
+	return securepath.Join(s.primary.Path, rel)
+}
+
+func available(l Library) bool {
+	st, err := os.Stat(l.Path)
+	return err == nil && st.IsDir()
+}
+
+// ISO is an ISO file found by Scan.
+type ISO struct {
+	Library string
+	Path    string // relative to the library root
+	Size    int64
+}
+
+// GroupPath is the directory the ISO sits in, which maps to its menu group.
+func (i ISO) GroupPath() string {
+	if dir := filepath.Dir(i.Path); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+func (i ISO) Name() string {
+	base := filepath.Base(i.Path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Scan lists the ISO files in the named library, or in all of them if name
+// is empty. A path present in several libraries is listed once, from the
+// first one searched. Remote libraries list their cache and the remote.
+// Libraries whose directory is missing, such as an unmounted share, or
+// whose remote cannot be listed are returned in unavailable so callers do
+// not mistake their images for deleted ones.
+func (s *Set) Scan(name string) (isos []ISO, unavailable []string, err error) {
+	libs := s.libs
+	if name != "" {
+		l, ok := s.Get(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown library %q", name)
+		}
+		libs = []Library{l}
+	}
+
+	seen := map[string]bool{}
+	for _, l := range libs {
+		if !available(l) {
+			log.Printf("Library %s (%s) is not available", l.Name, l.Path)
+			unavailable = append(unavailable, l.Name)
+			continue
+		}
+		err := filepath.WalkDir(l.Path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".iso") {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				log.Printf("Warning: Failed to get info for %s: %v", path, err)
+				return nil
+			}
+			rel, _ := filepath.Rel(l.Path, path)
+			if seen[rel] {
+				return nil
+			}
+			seen[rel] = true
+			isos = append(isos, ISO{Library: l.Name, Path: rel, Size: info.Size()})
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("library %s: %w", l.Name, err)
+		}
+		if !l.Remote() {
+			continue
+		}
+		listing, err := listRemote(l)
+		if err != nil {
+			log.Printf("Library %s: Failed to list %s: %v", l.Name, l.URL, err)
+			unavailable = append(unavailable, l.Name)
+			continue
+		}
+		s.mu.Lock()
+		s.listings[l.Name] = listing
+		s.mu.Unlock()
+		for rel, size := range listing {
+			if !seen[rel] {
+				seen[rel] = true
+				isos = append(isos, ISO{Library: l.Name, Path: rel, Size: size})
+			}
+		}
+	}
+	return isos, unavailable, nil
+}
+
+type Usage struct {
+	Library
+	Available  bool   `json:"available"`
+	Images     int    `json:"images"`
+	ImageBytes int64  `json:"image_bytes"`
+	Total      uint64 `json:"total"`
+	Free       uint64 `json:"free"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Usage reports each library's ISO count and the space left on its
+// filesystem, in search order.
+func (s *Set) Usage() []Usage {
+	out := make([]Usage, 0, len(s.libs))
+	for _, l := range s.libs {
+		u := Usage{Library: l}
+		isos, unavailable, err := s.Scan(l.Name)
+		switch {
+		case err != nil:
+			u.Error = err.Error()
+		case len(unavailable) > 0 && l.Remote():
+			u.Error = "remote not reachable"
+		case len(unavailable) > 0:
+			u.Error = "directory not found"
+		default:
+			u.Available = true
+			for _, iso := range isos {
+				u.Images++
+				u.ImageBytes += iso.Size
+			}
+			if du, err := disk.Usage(l.Path); err == nil {
+				u.Total, u.Free = du.Total, du.Free
+			}
+		}
+		out = append(out, u)
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, root, rel string, size int) {
+	t.Helper()
+	p := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveAcrossLibraries(t *testing.T) {
+	local, nas := t.TempDir(), t.TempDir()
+	writeFile(t, local, "hot.iso", 1)
+	writeFile(t, nas, "archive/old.iso", 2)
+	writeFile(t, nas, "archive/old/vmlinuz", 3)
+
+	s, err := New(local, []Library{{Name: "nas", Path: nas, Priority: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Dir("archive/old/vmlinuz"); got != nas {
+		t.Errorf("Dir(archive/old/vmlinuz) = %s, want %s", got, nas)
+	}
+	if got := s.Dir("hot.iso"); got != local {
+		t.Errorf("Dir(hot.iso) = %s, want %s", got, local)
+	}
+	if got := s.Dir("new.iso"); got != local {
+		t.Errorf("Dir(new.iso) = %s, want the default library", got)
+	}
+	if _, err := s.Join("../escape"); err == nil {
+		t.Error("Join accepted a path outside the libraries")
+	}
+	p, err := s.Join("archive/old.iso")
+	if err != nil || p != filepath.Join(nas, "archive/old.iso") {
+		t.Errorf("Join(archive/old.iso) = %s, %v", p, err)
+	}
+}
+
+func TestScanPrefersEarlierLibrary(t *testing.T) {
+	local, nas := t.TempDir(), t.TempDir()
+	writeFile(t, local, "dup.iso", 1)
+	writeFile(t, nas, "dup.iso", 2)
+	writeFile(t, nas, "group/only.iso", 3)
+
+	s, err := New(local, []Library{
+		{Name: "nas", Path: nas, Priority: 10},
+		{Name: "gone", Path: filepath.Join(nas, "missing")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	isos, unavailable, err := s.Scan("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unavailable) != 1 || unavailable[0] != "gone" {
+		t.Errorf("unavailable = %v, want [gone]", unavailable)
+	}
+	if len(isos) != 2 {
+		t.Fatalf("found %d ISOs, want 2: %+v", len(isos), isos)
+	}
+	for _, iso := range isos {
+		switch iso.Path {
+		case "dup.iso":
+			if iso.Library != DefaultName || iso.Size != 1 {
+				t.Errorf("dup.iso came from %s (%d bytes), want the default library", iso.Library, iso.Size)
+			}
+		case filepath.Join("group", "only.iso"):
+			if iso.GroupPath() != "group" || iso.Name() != "only" {
+				t.Errorf("group/only.iso: group %q, name %q", iso.GroupPath(), iso.Name())
+			}
+		default:
+			t.Errorf("unexpected ISO %s", iso.Path)
+		}
+	}
+
+	if _, err := New(local, []Library{{Name: "a", Path: nas}, {Name: "a", Path: nas}}); err == nil {
+		t.Error("duplicate library names were accepted")
+	}
+}
@@ -0,0 +1,284 @@
+package library
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"bootimus/internal/securepath"
+)
+
+// A remote library is an HTTP directory listing of ISOs, such as an nginx
+// or Apache autoindex at head office. Its Path is a local cache: an ISO is
+// proxied from the remote on its first boot while it is fetched in the
+// background, then served from the cache until evicted.
+
+var isoHref = regexp.MustCompile(`(?i)href="([^"?#]+\.iso)"`)
+
+var listClient = &http.Client{Timeout: 30 * time.Second}
+
+func (l Library) Remote() bool {
+	return l.URL != ""
+}
+
+func (l Library) remoteURL(rel string) string {
+	segs := strings.Split(filepath.ToSlash(rel), "/")
+	for i, seg := range segs {
+		segs[i] = url.PathEscape(seg)
+	}
+	return strings.TrimSuffix(l.URL, "/") + "/" + strings.Join(segs, "/")
+}
+
+// listRemote reads the ISO links in the library's index page, with their
+// sizes from HEAD requests.
+func listRemote(l Library) (map[string]int64, error) {
+	base, err := url.Parse(strings.TrimSuffix(l.URL, "/") + "/")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := listClient.Get(base.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", base, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	isos := map[string]int64{}
+	for _, m := range isoHref.FindAllSubmatch(body, -1) {
+		ref, err := base.Parse(string(m[1]))
+		if err != nil || ref.Host != base.Host || !strings.HasPrefix(ref.Path, base.Path) {
+			continue
+		}
+		rel, err := securepath.Clean(strings.TrimPrefix(ref.Path, base.Path))
+		if err != nil || rel == "." {
+			continue
+		}
+		var size int64
+		if head, err := listClient.Head(l.remoteURL(rel)); err == nil {
+			head.Body.Close()
+			if head.StatusCode == http.StatusOK {
+				size = head.ContentLength
+			}
+		}
+		isos[rel] = size
+	}
+	return isos, nil
+}
+
+// remoteFor returns the remote library that last listed rel.
+func (s *Set) remoteFor(rel string) (Library, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range s.libs {
+		if _, ok := s.listings[l.Name][rel]; ok && l.Remote() {
+			return l, true
+		}
+	}
+	return Library{}, false
+}
+
+// Exists reports whether rel is in any library, cached or not.
+func (s *Set) Exists(rel string) bool {
+	if _, ok := s.Find(rel); ok {
+		return true
+	}
+	_, ok := s.remoteFor(rel)
+	return ok
+}
+
+// Fetch starts caching rel from its remote library if it is not local yet.
+// It reports whether rel is on a remote library at all.
+func (s *Set) Fetch(rel string) bool {
+	if _, ok := s.Find(rel); ok {
+		return false
+	}
+	l, ok := s.remoteFor(rel)
+	if !ok {
+		return false
+	}
+	key := l.Name + "/" + rel
+	s.mu.Lock()
+	if s.fetching[key] {
+		s.mu.Unlock()
+		return true
+	}
+	s.fetching[key] = true
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.fetching, key)
+			s.mu.Unlock()
+		}()
+		if err := s.fetch(s.ctx, l, rel); err != nil {
+			log.Printf("Library %s: Failed to cache %s: %v", l.Name, rel, err)
+		}
+	}()
+	return true
+}
+
+func (s *Set) fetch(ctx context.Context, l Library, rel string) error {
+	dest, err := securepath.Join(l.Path, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	log.Printf("Library %s: Caching %s from %s", l.Name, rel, l.URL)
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.remoteURL(rel), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("remote returned %s", resp.Status)
+	}
+
+	tmp := dest + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && resp.ContentLength > 0 && n != resp.ContentLength {
+		err = fmt.Errorf("got %d of %d bytes", n, resp.ContentLength)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	log.Printf("Library %s: Cached %s (%d MB) in %v", l.Name, rel, n>>20, time.Since(start).Round(time.Second))
+	s.evict(l, rel)
+	return nil
+}
+
+// Touch marks a cached ISO as used so eviction keeps it longer.
+func (s *Set) Touch(rel string) {
+	if l, ok := s.Find(rel); ok && l.Remote() {
+		if p, err := securepath.Join(l.Path, rel); err == nil {
+			now := time.Now()
+			os.Chtimes(p, now, now)
+		}
+	}
+}
+
+// evict deletes the least recently used ISOs until the cache fits in
+// CacheGB. Extracted boot files are small and stay, so kernel boots keep
+// working for evicted images.
+func (s *Set) evict(l Library, keep string) {
+	if l.CacheGB <= 0 {
+		return
+	}
+	type cached struct {
+		rel  string
+		size int64
+		used time.Time
+	}
+	var files []cached
+	var total int64
+	filepath.WalkDir(l.Path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".iso") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(l.Path, p)
+		files = append(files, cached{rel, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].used.Before(files[j].used) })
+
+	limit := int64(l.CacheGB) << 30
+	for _, f := range files {
+		if total <= limit {
+			break
+		}
+		if f.rel == keep {
+			continue
+		}
+		if err := os.Remove(filepath.Join(l.Path, f.rel)); err != nil {
+			log.Printf("Library %s: Failed to evict %s: %v", l.Name, f.rel, err)
+			continue
+		}
+		total -= f.size
+		log.Printf("Library %s: Evicted %s from the cache", l.Name, f.rel)
+	}
+}
+
+// ServeRemote proxies a request for rel to its remote library, passing
+// Range through so sanboot works before the ISO is cached, and starts
+// caching it. It returns false if rel is on no remote library.
+func (s *Set) ServeRemote(w http.ResponseWriter, r *http.Request, rel string) bool {
+	l, ok := s.remoteFor(rel)
+	if !ok {
+		return false
+	}
+	s.Fetch(rel)
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, l.remoteURL(rel), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	for _, h := range []string{"Range", "If-Range"} {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("Library %s: Remote fetch of %s failed: %v", l.Name, rel, err)
+		http.Error(w, "Remote library unavailable", http.StatusBadGateway)
+		return true
+	}
+	defer resp.Body.Close()
+	for _, h := range []string{"Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+	return true
+}
+
+func remoteCacheDir(isoDir, name string) string {
+	// isoDir is <data-dir>/isos; caches sit beside it so the local library
+	// never scans them.
+	return filepath.Join(filepath.Dir(isoDir), "cache", name)
+}
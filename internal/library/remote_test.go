@@ -0,0 +1,94 @@
+package library
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemoteLibrary(t *testing.T) {
+	files := map[string][]byte{
+		"/isos/a.iso": bytes.Repeat([]byte("a"), 1000),
+		"/isos/b.iso": bytes.Repeat([]byte("b"), 1000),
+	}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/isos/" {
+			w.Write([]byte(`<a href="../">../</a><a href="a.iso">a.iso</a><a href="b.iso">b.iso</a><a href="/etc/x.iso">x</a>`))
+			return
+		}
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader(data))
+	}))
+	defer remote.Close()
+
+	data := t.TempDir()
+	s, err := New(filepath.Join(data, "isos"), []Library{{Name: "hq", URL: remote.URL + "/isos", Priority: 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	isos, unavailable, err := s.Scan("")
+	if err != nil || len(unavailable) != 1 || unavailable[0] != DefaultName {
+		// The default library's directory was never created.
+		t.Fatalf("Scan: unavailable %v, err %v", unavailable, err)
+	}
+	if len(isos) != 2 || isos[0].Size != 1000 {
+		t.Fatalf("Scan found %+v, want a.iso and b.iso of 1000 bytes", isos)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/isos/a.iso", nil)
+	req.Header.Set("Range", "bytes=10-19")
+	if !s.ServeRemote(rec, req, "a.iso") {
+		t.Fatal("ServeRemote did not know a.iso")
+	}
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "aaaaaaaaaa" {
+		t.Errorf("proxied range: %d %q", rec.Code, rec.Body.String())
+	}
+
+	cache := filepath.Join(data, "cache", "hq")
+	waitFor(t, func() bool { _, err := os.Stat(filepath.Join(cache, "a.iso")); return err == nil })
+	if l, ok := s.Find("a.iso"); !ok || l.Name != "hq" {
+		t.Errorf("a.iso not served from the cache after fetching")
+	}
+	if s.Fetch("a.iso") {
+		t.Error("Fetch started again for a cached ISO")
+	}
+
+	// Both fit in a 1 GiB cache; growing b.iso past it evicts the older a.iso.
+	os.Chtimes(filepath.Join(cache, "a.iso"), time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+	s.Fetch("b.iso")
+	waitFor(t, func() bool { _, err := os.Stat(filepath.Join(cache, "b.iso")); return err == nil })
+	s.evict(Library{Name: "hq", Path: cache, CacheGB: 1}, "b.iso")
+	if _, err := os.Stat(filepath.Join(cache, "a.iso")); err != nil {
+		t.Error("a.iso evicted although the cache is under its limit")
+	}
+	if err := os.Truncate(filepath.Join(cache, "b.iso"), 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	s.evict(Library{Name: "hq", Path: cache, CacheGB: 1}, "b.iso")
+	if _, err := os.Stat(filepath.Join(cache, "a.iso")); !os.IsNotExist(err) {
+		t.Error("least recently used a.iso was not evicted")
+	}
+	if !s.Exists("a.iso") {
+		t.Error("evicted a.iso should still exist on the remote")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out")
+}
@@ -0,0 +1,241 @@
+// Package logsink forwards boot log entries to systems built for keeping
+// them: syslog, Loki or Elasticsearch. The database still records every
+// boot; sinks are for long-term analytics. Each sink has its own bounded
+// queue, so one that is down or slow loses entries instead of holding up
+// boots or the other sinks.
+package logsink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KindAccess marks an HTTP access log entry; boot entries leave Kind empty.
+const KindAccess = "access"
+
+type Entry struct {
+	Time    time.Time `json:"@timestamp"`
+	Kind    string    `json:"kind,omitempty"`
+	MAC     string    `json:"mac"`
+	Image   string    `json:"image,omitempty"`
+	IP      string    `json:"ip,omitempty"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+
+	// Access entries only.
+	Method     string  `json:"method,omitempty"`
+	Path       string  `json:"path,omitempty"`
+	Status     int     `json:"status,omitempty"`
+	Bytes      int64   `json:"bytes,omitempty"`
+	DurationMs float64 `json:"duration_ms,omitempty"`
+}
+
+type Config struct {
+	Type string `mapstructure:"type"` // syslog, loki or elasticsearch
+	// udp://host:514 or tcp://host:514 for syslog, the base URL otherwise.
+	URL      string            `mapstructure:"url"`
+	Index    string            `mapstructure:"index"`  // elasticsearch; default bootimus-boots
+	Labels   map[string]string `mapstructure:"labels"` // loki stream labels; default job=bootimus
+	Username string            `mapstructure:"username"`
+	Password string            `mapstructure:"password"`
+	Headers  map[string]string `mapstructure:"headers"` // e.g. X-Scope-OrgID, Authorization
+
+	Buffer        int           `mapstructure:"buffer"`     // entries queued while the sink is behind
+	BatchSize     int           `mapstructure:"batch_size"` // entries per request
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.Buffer <= 0 {
+		c.Buffer = 10000
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 5 * time.Second
+	}
+	return c
+}
+
+// Sink delivers a batch of entries. An error means the whole batch should
+// be retried, unless it is a permanentError.
+type Sink interface {
+	Send(ctx context.Context, batch []Entry) error
+}
+
+func newSink(cfg Config) (Sink, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	switch cfg.Type {
+	case "syslog":
+		return newSyslog(cfg)
+	case "loki":
+		return &loki{cfg: cfg, client: client}, nil
+	case "elasticsearch":
+		return &elasticsearch{cfg: cfg, client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", cfg.Type)
+}
+
+const (
+	minRetry = time.Second
+	maxRetry = time.Minute
+)
+
+type queue struct {
+	name    string
+	cfg     Config
+	sink    Sink
+	entries chan Entry
+	dropped atomic.Int64
+}
+
+// Set fans entries out to every configured sink.
+type Set struct {
+	queues []*queue
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New starts a forwarder for each sink. Sinks with a bad configuration are
+// left out and reported in the error; the rest still run.
+func New(cfgs []Config) (*Set, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Set{cancel: cancel}
+	var errs []error
+	for i, cfg := range cfgs {
+		cfg = cfg.withDefaults()
+		sink, err := newSink(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("log sink %d (%s): %w", i+1, cfg.Type, err))
+			continue
+		}
+		q := &queue{name: cfg.Type + " " + cfg.URL, cfg: cfg, sink: sink, entries: make(chan Entry, cfg.Buffer)}
+		s.queues = append(s.queues, q)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			q.run(ctx)
+		}()
+	}
+	return s, errors.Join(errs...)
+}
+
+// Len is the number of sinks running.
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.queues)
+}
+
+// Publish queues e for every sink without blocking. A sink whose queue is
+// full drops it.
+func (s *Set) Publish(e Entry) {
+	if s == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	for _, q := range s.queues {
+		select {
+		case q.entries <- e:
+		default:
+			q.dropped.Add(1)
+		}
+	}
+}
+
+// Close stops the forwarders after a last attempt to deliver what is queued.
+func (s *Set) Close() {
+	if s == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (q *queue) run(ctx context.Context) {
+	ticker := time.NewTicker(q.cfg.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]Entry, 0, q.cfg.BatchSize)
+	retry := minRetry
+	var retryAt time.Time
+
+	for {
+		full := len(batch) >= q.cfg.BatchSize
+		if full {
+			// Stop reading so the channel fills up and Publish drops,
+			// rather than growing the batch without bound.
+			select {
+			case <-ctx.Done():
+				q.drain(batch)
+				return
+			case <-ticker.C:
+			}
+		} else {
+			select {
+			case <-ctx.Done():
+				q.drain(batch)
+				return
+			case e := <-q.entries:
+				batch = append(batch, e)
+				if len(batch) < q.cfg.BatchSize {
+					continue
+				}
+			case <-ticker.C:
+			}
+		}
+
+		if n := q.dropped.Swap(0); n > 0 {
+			log.Printf("Log sink %s: dropped %d entries, queue full", q.name, n)
+		}
+		if len(batch) == 0 || time.Now().Before(retryAt) {
+			continue
+		}
+		err := q.sink.Send(ctx, batch)
+		var perm permanentError
+		if errors.As(err, &perm) {
+			log.Printf("Log sink %s: dropped %d entries: %v", q.name, len(batch), err)
+		} else if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Log sink %s: %v (retrying in %v)", q.name, err, retry)
+			}
+			retryAt = time.Now().Add(retry)
+			retry = min(retry*2, maxRetry)
+			continue
+		}
+		batch = batch[:0]
+		retry, retryAt = minRetry, time.Time{}
+	}
+}
+
+// drain makes one attempt to send the batch and whatever is still queued.
+func (q *queue) drain(batch []Entry) {
+	for len(q.entries) > 0 {
+		batch = append(batch, <-q.entries)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for len(batch) > 0 {
+		n := min(len(batch), q.cfg.BatchSize)
+		if err := q.sink.Send(ctx, batch[:n]); err != nil {
+			log.Printf("Log sink %s: lost %d entries on shutdown: %v", q.name, len(batch), err)
+			return
+		}
+		batch = batch[n:]
+	}
+}
@@ -0,0 +1,179 @@
+package logsink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testEntries = []Entry{
+	{Time: time.Unix(1700000000, 0), MAC: "aa:bb:cc:dd:ee:ff", Image: "Ubuntu 24.04", IP: "10.0.0.5", Success: true},
+	{Time: time.Unix(1700000001, 0), MAC: "aa:bb:cc:dd:ee:01", Image: "Debian", IP: "10.0.0.6", Error: "iPXE error 0x2e008081"},
+}
+
+func TestLoki(t *testing.T) {
+	var got struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/push" || r.Header.Get("X-Scope-OrgID") != "lab" {
+			t.Errorf("push to %s with tenant %q", r.URL.Path, r.Header.Get("X-Scope-OrgID"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink, err := newSink(Config{Type: "loki", URL: srv.URL, Headers: map[string]string{"X-Scope-OrgID": "lab"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(context.Background(), testEntries); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Streams) != 2 {
+		t.Fatalf("got %d streams, want one per outcome", len(got.Streams))
+	}
+	for _, st := range got.Streams {
+		if st.Stream["job"] != "bootimus" || len(st.Values) != 1 {
+			t.Errorf("stream %v with %d values", st.Stream, len(st.Values))
+		}
+		if st.Stream["result"] == "failure" && !strings.Contains(st.Values[0][1], "0x2e008081") {
+			t.Errorf("failure line %q lacks the error", st.Values[0][1])
+		}
+	}
+}
+
+func TestElasticsearch(t *testing.T) {
+	var lines []string
+	reject := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+		if reject {
+			io.WriteString(w, `{"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`)
+			return
+		}
+		io.WriteString(w, `{"errors":false,"items":[]}`)
+	}))
+	defer srv.Close()
+
+	sink, _ := newSink(Config{Type: "elasticsearch", URL: srv.URL + "/", Index: "boots"})
+	if err := sink.Send(context.Background(), testEntries); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 4 || lines[0] != `{"index":{"_index":"boots"}}` || !strings.Contains(lines[1], `"mac":"aa:bb:cc:dd:ee:ff"`) {
+		t.Errorf("bulk body:\n%s", strings.Join(lines, "\n"))
+	}
+
+	reject = true
+	var perm permanentError
+	if err := sink.Send(context.Background(), testEntries); !errors.As(err, &perm) {
+		t.Errorf("rejected documents gave %v, want a permanent error", err)
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	sink, err := newSink(Config{Type: "syslog", URL: "tcp://" + ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(context.Background(), testEntries); err != nil {
+		t.Fatal(err)
+	}
+	data := <-received
+	first := (&syslog{hostname: "h"}).format(testEntries[0])
+	if !strings.HasPrefix(first, "<134>1 2023-11-14T22:13:20Z h bootimus - boot - boot mac=aa:bb:cc:dd:ee:ff") {
+		t.Errorf("message %q", first)
+	}
+	// Octet counting: "<len> <msg>" for each entry.
+	if n := strings.Count(data, "<13"); n != 2 || !strings.Contains(data, "<132>1") {
+		t.Errorf("framed stream %q", data)
+	}
+}
+
+type slowSink struct {
+	mu      sync.Mutex
+	got     []Entry
+	sending chan struct{}
+	release chan struct{}
+}
+
+func (s *slowSink) Send(ctx context.Context, batch []Entry) error {
+	select {
+	case s.sending <- struct{}{}:
+	default:
+	}
+	<-s.release
+	s.mu.Lock()
+	s.got = append(s.got, batch...)
+	s.mu.Unlock()
+	return nil
+}
+
+func TestQueueDropsWhenFull(t *testing.T) {
+	sink := &slowSink{sending: make(chan struct{}, 1), release: make(chan struct{})}
+	q := &queue{name: "test", cfg: Config{Buffer: 4, BatchSize: 2, FlushInterval: time.Hour}, sink: sink, entries: make(chan Entry, 4)}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Set{queues: []*queue{q}, cancel: cancel}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		q.run(ctx)
+	}()
+
+	s.Publish(Entry{MAC: "m"})
+	s.Publish(Entry{MAC: "m"})
+	<-sink.sending
+	// The sink is stuck on the first batch: four more fit in the queue and
+	// the rest are dropped without Publish blocking.
+	for i := 0; i < 18; i++ {
+		s.Publish(Entry{MAC: "m"})
+	}
+	if n := q.dropped.Load(); n != 14 {
+		t.Errorf("dropped %d, want 14", n)
+	}
+	close(sink.release)
+	s.Close()
+	if n := len(sink.got); n != 6 {
+		t.Errorf("delivered %d entries, want 6", n)
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	s, err := New([]Config{{Type: "loki", URL: "http://127.0.0.1:1"}, {Type: "splunk", URL: "x"}, {Type: "syslog", URL: "http://x"}})
+	defer s.Close()
+	if err == nil || s.Len() != 1 {
+		t.Errorf("New: %d sinks, err %v", s.Len(), err)
+	}
+}
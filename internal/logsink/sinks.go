@@ -0,0 +1,234 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// syslog writes RFC 5424 messages, one datagram each over UDP or
+// octet-counted (RFC 6587) over TCP.
+type syslog struct {
+	network, addr string
+	hostname      string
+}
+
+func newSyslog(cfg Config) (Sink, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("syslog url must be udp:// or tcp://, got %q", cfg.URL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "514")
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	return &syslog{network: u.Scheme, addr: addr, hostname: hostname}, nil
+}
+
+const (
+	facilityLocal0  = 16
+	severityWarning = 4
+	severityInfo    = 6
+)
+
+func (s *syslog) format(e Entry) string {
+	severity := severityInfo
+	msgID := "boot"
+	var msg string
+	if e.Kind == KindAccess {
+		msgID = KindAccess
+		msg = fmt.Sprintf("access method=%s path=%q status=%d bytes=%d duration_ms=%.1f ip=%s mac=%s", e.Method, e.Path, e.Status, e.Bytes, e.DurationMs, e.IP, e.MAC)
+		if !e.Success {
+			severity = severityWarning
+		}
+	} else {
+		msg = fmt.Sprintf("boot mac=%s image=%q ip=%s success=%t", e.MAC, e.Image, e.IP, e.Success)
+		if !e.Success {
+			severity = severityWarning
+			msg += fmt.Sprintf(" error=%q", e.Error)
+		}
+	}
+	return fmt.Sprintf("<%d>1 %s %s bootimus - %s - %s",
+		facilityLocal0*8+severity, e.Time.UTC().Format(time.RFC3339Nano), s.hostname, msgID, msg)
+}
+
+func (s *syslog) Send(ctx context.Context, batch []Entry) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.network, s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+	for _, e := range batch {
+		msg := s.format(e)
+		if s.network == "tcp" {
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		if _, err := io.WriteString(conn, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loki pushes to /loki/api/v1/push, one stream per outcome so failures can
+// be selected by label. Access entries get streams of their own, labelled
+// kind=access.
+type loki struct {
+	cfg    Config
+	client *http.Client
+}
+
+func (l *loki) Send(ctx context.Context, batch []Entry) error {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	type key struct {
+		kind    string
+		success bool
+	}
+	streams := map[key]*stream{}
+	for _, e := range batch {
+		k := key{e.Kind, e.Success}
+		st, ok := streams[k]
+		if !ok {
+			labels := map[string]string{"job": "bootimus"}
+			if len(l.cfg.Labels) > 0 {
+				labels = map[string]string{}
+				for k, v := range l.cfg.Labels {
+					labels[k] = v
+				}
+			}
+			labels["result"] = "failure"
+			if e.Success {
+				labels["result"] = "success"
+			}
+			if e.Kind != "" {
+				labels["kind"] = e.Kind
+			}
+			st = &stream{Stream: labels}
+			streams[k] = st
+		}
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), string(line)})
+	}
+	var body struct {
+		Streams []*stream `json:"streams"`
+	}
+	for _, st := range streams {
+		body.Streams = append(body.Streams, st)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	_, err = post(ctx, l.client, l.cfg, "/loki/api/v1/push", "application/json", data)
+	return err
+}
+
+// elasticsearch indexes through the _bulk API.
+type elasticsearch struct {
+	cfg    Config
+	client *http.Client
+}
+
+func (es *elasticsearch) Send(ctx context.Context, batch []Entry) error {
+	index := es.cfg.Index
+	if index == "" {
+		index = "bootimus-boots"
+	}
+	action, _ := json.Marshal(map[string]any{"index": map[string]string{"_index": index}})
+	var buf bytes.Buffer
+	for _, e := range batch {
+		doc, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(doc)
+		buf.WriteByte('\n')
+	}
+	resp, err := post(ctx, es.client, es.cfg, "/_bulk", "application/x-ndjson", buf.Bytes())
+	if err != nil {
+		return err
+	}
+	// Documents rejected individually (mapping conflicts and the like)
+	// would be rejected again, so they are not retried.
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if json.Unmarshal(resp, &result) == nil && result.Errors {
+		for _, item := range result.Items {
+			for _, r := range item {
+				if len(r.Error) > 0 {
+					return permanentError{fmt.Errorf("elasticsearch rejected documents: %s", r.Error)}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// permanentError is returned for batches that retrying cannot fix.
+type permanentError struct{ error }
+
+func post(ctx context.Context, client *http.Client, cfg Config, path, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(cfg.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if cfg.Username != "" {
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		err := fmt.Errorf("HTTP %d: %s", resp.StatusCode, msg)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return nil, permanentError{err}
+		}
+		return nil, err
+	}
+	return data, nil
+}
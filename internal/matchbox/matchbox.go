@@ -0,0 +1,212 @@
+// Package matchbox reads provisioning definitions laid out like a Matchbox
+// data directory (profiles/, groups/, ignition/, cloud/, generic/, assets/)
+// so existing Matchbox setups can be served by bootimus unchanged. Tinkerbell
+// hardware records in hardware/ are read as extra groups.
+package matchbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"bootimus/internal/securepath"
+)
+
+var ErrNoMatch = errors.New("no matching group")
+
+type Boot struct {
+	Kernel string   `json:"kernel"`
+	Initrd []string `json:"initrd"`
+	Args   []string `json:"args"`
+}
+
+type Profile struct {
+	ID         string `json:"id"`
+	Name       string `json:"name,omitempty"`
+	Boot       Boot   `json:"boot"`
+	IgnitionID string `json:"ignition_id,omitempty"`
+	CloudID    string `json:"cloud_id,omitempty"`
+	GenericID  string `json:"generic_id,omitempty"`
+}
+
+type Group struct {
+	ID       string                 `json:"id"`
+	Name     string                 `json:"name,omitempty"`
+	Profile  string                 `json:"profile"`
+	Selector map[string]string      `json:"selector,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+
+	// Set for groups read from Tinkerbell hardware records.
+	Source       string `json:"source,omitempty"`
+	IPXEURL      string `json:"ipxe_url,omitempty"`
+	IPXEContents string `json:"ipxe_contents,omitempty"`
+	DenyPXE      bool   `json:"deny_pxe,omitempty"`
+}
+
+type Store struct {
+	dir string
+}
+
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// AssetsDir is served at /assets/, as Matchbox does.
+func (s *Store) AssetsDir() string {
+	return filepath.Join(s.dir, "assets")
+}
+
+// readDir decodes every .json file in sub with decode. Broken files are
+// reported but don't stop the others from loading.
+func (s *Store) readDir(sub string, decode func(name string, data []byte) error) []error {
+	entries, err := os.ReadDir(filepath.Join(s.dir, sub))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, sub, e.Name()))
+		if err == nil {
+			err = decode(e.Name(), data)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", sub, e.Name(), err))
+		}
+	}
+	return errs
+}
+
+func (s *Store) Profiles() ([]*Profile, []error) {
+	var profiles []*Profile
+	errs := s.readDir("profiles", func(name string, data []byte) error {
+		var p Profile
+		if err := json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		if p.ID == "" {
+			p.ID = strings.TrimSuffix(name, ".json")
+		}
+		profiles = append(profiles, &p)
+		return nil
+	})
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].ID < profiles[j].ID })
+	return profiles, errs
+}
+
+func (s *Store) Profile(id string) (*Profile, error) {
+	profiles, _ := s.Profiles()
+	for _, p := range profiles {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("profile %q not found", id)
+}
+
+// Groups returns Matchbox groups and groups derived from Tinkerbell
+// hardware, most specific selector first, which is the order Match tries.
+func (s *Store) Groups() ([]*Group, []error) {
+	var groups []*Group
+	errs := s.readDir("groups", func(name string, data []byte) error {
+		var g Group
+		if err := json.Unmarshal(data, &g); err != nil {
+			return err
+		}
+		if g.ID == "" {
+			g.ID = strings.TrimSuffix(name, ".json")
+		}
+		g.Selector = normalizeLabels(g.Selector)
+		g.Source = "matchbox"
+		groups = append(groups, &g)
+		return nil
+	})
+	errs = append(errs, s.readDir("hardware", func(name string, data []byte) error {
+		hw, err := hardwareGroups(data)
+		groups = append(groups, hw...)
+		return err
+	})...)
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if len(groups[i].Selector) != len(groups[j].Selector) {
+			return len(groups[i].Selector) > len(groups[j].Selector)
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return groups, errs
+}
+
+// Match returns the most specific group whose selectors all match labels.
+// A group without selectors matches every machine.
+func (s *Store) Match(labels map[string]string) (*Group, error) {
+	groups, _ := s.Groups()
+	for _, g := range groups {
+		matched := true
+		for k, v := range g.Selector {
+			if labels[k] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return g, nil
+		}
+	}
+	return nil, ErrNoMatch
+}
+
+// Template reads a template referenced by a profile from ignition/, cloud/
+// or generic/.
+func (s *Store) Template(kind, id string) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("profile has no %s template", kind)
+	}
+	p, err := securepath.Join(filepath.Join(s.dir, kind), id)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Labels turns a request's query into selector labels the way Matchbox
+// does: keys lowercased, MACs in colon form.
+func Labels(q url.Values) map[string]string {
+	labels := make(map[string]string, len(q))
+	for k := range q {
+		labels[k] = q.Get(k)
+	}
+	return normalizeLabels(labels)
+}
+
+func normalizeLabels(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		k = strings.ToLower(k)
+		if k == "mac" {
+			if hw, err := net.ParseMAC(v); err == nil {
+				v = hw.String()
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
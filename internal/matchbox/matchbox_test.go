@@ -0,0 +1,89 @@
+package matchbox
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMatchAndRender(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"profiles/worker.json": `{"id":"worker","boot":{"kernel":"/assets/vmlinuz","initrd":["/assets/a.img","/assets/b.img"],"args":["console=ttyS0","ignition.config.url=http://x/ignition?mac=${mac:hexhyp}"]},"ignition_id":"worker.ign"}`,
+		"groups/default.json":  `{"id":"default","profile":"worker","metadata":{"role":"any"}}`,
+		"groups/node1.json":    `{"id":"node1","profile":"worker","selector":{"MAC":"52-54-00-AA-BB-CC"},"metadata":{"role":"node1","ssh":{"key":"k"}}}`,
+		"groups/broken.json":   `{`,
+		"hardware/hw1.json":    `{"id":"hw1","metadata":{"profile":"worker"},"network":{"interfaces":[{"dhcp":{"mac":"52:54:00:00:00:01","hostname":"tink1","ip":{"address":"10.0.0.5"}},"netboot":{"allow_pxe":false}}]}}`,
+		"ignition/worker.ign":  `{"ignition":{"version":"3.3.0"},"role":"{{.role}}","mac":"{{.mac}}"}`,
+	})
+	s := New(dir)
+
+	groups, errs := s.Groups()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "broken.json") {
+		t.Errorf("errors = %v, want one for broken.json", errs)
+	}
+	if len(groups) != 3 || groups[len(groups)-1].ID != "default" {
+		t.Errorf("catch-all group should sort last: %+v", groups)
+	}
+
+	q, _ := url.ParseQuery("mac=52-54-00-aa-bb-cc&uuid=u1")
+	g, err := s.Match(Labels(q))
+	if err != nil || g.ID != "node1" {
+		t.Fatalf("Match = %v, %v; want node1", g, err)
+	}
+	if g, _ := s.Match(Labels(url.Values{"mac": {"52:54:00:00:00:99"}})); g == nil || g.ID != "default" {
+		t.Errorf("unknown MAC should fall back to default, got %v", g)
+	}
+	if g, _ := s.Match(Labels(url.Values{"mac": {"52:54:00:00:00:01"}})); g == nil || !g.DenyPXE || g.Metadata["hostname"] != "tink1" {
+		t.Errorf("hardware group = %+v", g)
+	}
+
+	p, err := s.Profile(g.Profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := IPXEScript(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#!ipxe\nkernel /assets/vmlinuz console=ttyS0 ignition.config.url=http://x/ignition?mac=${mac:hexhyp}\ninitrd /assets/a.img\ninitrd /assets/b.img\nboot\n"
+	if script != want {
+		t.Errorf("IPXEScript =\n%s\nwant\n%s", script, want)
+	}
+
+	tmpl, err := s.Template("ignition", p.IgnitionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("/ignition?mac=52-54-00-aa-bb-cc")
+	out, err := Render(p.IgnitionID, tmpl, TemplateData(g, u))
+	if err != nil || !strings.Contains(out, `"role":"node1"`) || !strings.Contains(out, `"mac":"52:54:00:aa:bb:cc"`) {
+		t.Errorf("Render = %s, %v", out, err)
+	}
+
+	meta := Metadata(TemplateData(g, u))
+	for _, line := range []string{"ROLE=node1", "SSH_KEY=k", "MAC=52:54:00:aa:bb:cc", "REQUEST_QUERY_MAC=52-54-00-aa-bb-cc"} {
+		if !strings.Contains(meta, line+"\n") {
+			t.Errorf("metadata missing %q:\n%s", line, meta)
+		}
+	}
+
+	if _, err := s.Template("ignition", "../profiles/worker.json"); err == nil {
+		t.Error("template path escaped its directory")
+	}
+}
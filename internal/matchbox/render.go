@@ -0,0 +1,174 @@
+package matchbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// BootIPXE is what Matchbox serves at /boot.ipxe: it chains to /ipxe with
+// the labels iPXE knows about.
+const BootIPXE = `#!ipxe
+chain ipxe?uuid=${uuid}&mac=${mac:hexhyp}&domain=${domain}&hostname=${hostname}&serial=${serial}
+`
+
+var ipxeTemplate = template.Must(template.New("ipxe").Parse(`#!ipxe
+kernel {{.Kernel}}{{range .Args}} {{.}}{{end}}
+{{- range .Initrd}}
+initrd {{.}}
+{{- end}}
+boot
+`))
+
+// IPXEScript boots a profile's kernel and initrds.
+func IPXEScript(p *Profile) (string, error) {
+	if p.Boot.Kernel == "" {
+		return "", fmt.Errorf("profile %q has no kernel", p.ID)
+	}
+	var b strings.Builder
+	if err := ipxeTemplate.Execute(&b, p.Boot); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// TemplateData is what ignition, cloud and generic templates are rendered
+// with: the group's metadata, its selectors and the request query.
+func TemplateData(g *Group, u *url.URL) map[string]interface{} {
+	q := u.Query()
+	data := make(map[string]interface{}, len(g.Metadata)+len(g.Selector)+1)
+	for k, v := range g.Metadata {
+		data[k] = v
+	}
+	for k, v := range g.Selector {
+		data[k] = v
+	}
+	query := make(map[string]string, len(q))
+	for k := range q {
+		query[k] = q.Get(k)
+	}
+	data["request"] = map[string]interface{}{
+		"query":     query,
+		"raw_query": u.RawQuery,
+	}
+	return data
+}
+
+func Render(name, tmpl string, data map[string]interface{}) (string, error) {
+	t, err := template.New(name).Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Metadata flattens template data into the KEY=value lines Matchbox serves
+// at /metadata, with nested keys joined by underscores.
+func Metadata(data map[string]interface{}) string {
+	lines := map[string]string{}
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for k, sub := range t {
+				walk(prefix+"_"+k, sub)
+			}
+		case map[string]string:
+			for k, sub := range t {
+				walk(prefix+"_"+k, sub)
+			}
+		default:
+			lines[strings.ToUpper(strings.TrimPrefix(prefix, "_"))] = fmt.Sprint(v)
+		}
+	}
+	walk("", data)
+
+	keys := make([]string, 0, len(lines))
+	for k := range lines {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", k, lines[k])
+	}
+	return b.String()
+}
+
+// tinkHardware is the classic Tinkerbell hardware document.
+type tinkHardware struct {
+	ID       string                 `json:"id"`
+	Metadata map[string]interface{} `json:"metadata"`
+	Network  struct {
+		Interfaces []struct {
+			DHCP struct {
+				MAC      string `json:"mac"`
+				Hostname string `json:"hostname"`
+				Arch     string `json:"arch"`
+				UEFI     bool   `json:"uefi"`
+				IP       struct {
+					Address string `json:"address"`
+					Netmask string `json:"netmask"`
+					Gateway string `json:"gateway"`
+				} `json:"ip"`
+			} `json:"dhcp"`
+			Netboot struct {
+				AllowPXE *bool `json:"allow_pxe"`
+				IPXE     struct {
+					URL      string `json:"url"`
+					Contents string `json:"contents"`
+				} `json:"ipxe"`
+			} `json:"netboot"`
+		} `json:"interfaces"`
+	} `json:"network"`
+}
+
+// hardwareGroups turns each interface of a hardware record into a group
+// selecting its MAC. Its profile, if any, is taken from metadata "profile".
+func hardwareGroups(data []byte) ([]*Group, error) {
+	var hw tinkHardware
+	if err := json.Unmarshal(data, &hw); err != nil {
+		return nil, err
+	}
+	if hw.ID == "" {
+		return nil, fmt.Errorf("hardware record has no id")
+	}
+	var groups []*Group
+	for i, iface := range hw.Network.Interfaces {
+		if iface.DHCP.MAC == "" {
+			continue
+		}
+		g := &Group{
+			ID:           hw.ID,
+			Selector:     normalizeLabels(map[string]string{"mac": iface.DHCP.MAC}),
+			Metadata:     map[string]interface{}{},
+			Source:       "tinkerbell",
+			IPXEURL:      iface.Netboot.IPXE.URL,
+			IPXEContents: iface.Netboot.IPXE.Contents,
+			DenyPXE:      iface.Netboot.AllowPXE != nil && !*iface.Netboot.AllowPXE,
+		}
+		if len(hw.Network.Interfaces) > 1 {
+			g.ID = fmt.Sprintf("%s-%d", hw.ID, i)
+		}
+		for k, v := range hw.Metadata {
+			g.Metadata[k] = v
+		}
+		if p, ok := hw.Metadata["profile"].(string); ok {
+			g.Profile = p
+		}
+		g.Metadata["hostname"] = iface.DHCP.Hostname
+		g.Metadata["arch"] = iface.DHCP.Arch
+		g.Metadata["ip"] = iface.DHCP.IP.Address
+		g.Metadata["netmask"] = iface.DHCP.IP.Netmask
+		g.Metadata["gateway"] = iface.DHCP.IP.Gateway
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
@@ -0,0 +1,313 @@
+// Package mdns advertises bootimus over multicast DNS / DNS-SD, so tools on
+// the provisioning network can find the boot menu and admin UI without
+// knowing the server's address.
+package mdns
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	TypeBootimus = "_bootimus._tcp"
+	TypeHTTP     = "_http._tcp"
+
+	ttl = 120
+)
+
+var group = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+type Service struct {
+	Instance string // e.g. "Bootimus Boot Menu"
+	Type     string // e.g. "_http._tcp"
+	Port     int
+	TXT      []string
+}
+
+type Config struct {
+	Host     string // <Host>.local; defaults to the machine's hostname
+	IP       net.IP
+	Services []Service
+}
+
+type Server struct {
+	cfg  Config
+	conn *net.UDPConn
+	wg   sync.WaitGroup
+	done chan struct{}
+}
+
+func NewServer(cfg Config) (*Server, error) {
+	cfg.IP = cfg.IP.To4()
+	if cfg.IP == nil || cfg.IP.IsUnspecified() {
+		return nil, fmt.Errorf("an IPv4 address to advertise is required")
+	}
+	if cfg.Host == "" {
+		h, err := os.Hostname()
+		if err != nil || h == "" {
+			h = "bootimus"
+		}
+		cfg.Host = h
+	}
+	cfg.Host = label(strings.SplitN(cfg.Host, ".", 2)[0])
+	for i := range cfg.Services {
+		cfg.Services[i].Instance = label(cfg.Services[i].Instance)
+	}
+	return &Server{cfg: cfg, done: make(chan struct{})}, nil
+}
+
+func (s *Server) Start() error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return fmt.Errorf("listen UDP/5353: %w", err)
+	}
+	s.conn = conn
+
+	for _, svc := range s.cfg.Services {
+		log.Printf("mDNS: advertising %q (%s) on %s.local:%d", svc.Instance, svc.Type, s.cfg.Host, svc.Port)
+	}
+
+	s.wg.Add(2)
+	go s.loop()
+	go s.announce()
+	return nil
+}
+
+// Shutdown sends goodbye packets so browsers drop the records at once.
+func (s *Server) Shutdown() error {
+	close(s.done)
+	if s.conn != nil {
+		if msg, err := s.response(0, s.allAnswers(0), nil); err == nil {
+			s.conn.WriteToUDP(msg, group)
+		}
+		s.conn.Close()
+	}
+	s.wg.Wait()
+	return nil
+}
+
+// announce sends the records unsolicited, twice a second apart as RFC 6762
+// asks, so listeners pick bootimus up without querying.
+func (s *Server) announce() {
+	defer s.wg.Done()
+	for i := 0; i < 2; i++ {
+		if msg, err := s.response(0, s.allAnswers(ttl), nil); err == nil {
+			s.conn.WriteToUDP(msg, group)
+		}
+		select {
+		case <-s.done:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (s *Server) loop() {
+	defer s.wg.Done()
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			log.Printf("mDNS: read error: %v", err)
+			continue
+		}
+		s.handle(buf[:n], src)
+	}
+}
+
+func (s *Server) handle(packet []byte, src *net.UDPAddr) {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(packet)
+	if err != nil || hdr.Response {
+		return
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return
+	}
+
+	var answers []dnsmessage.Resource
+	var asked []dnsmessage.Question
+	unicast := src.Port != group.Port
+	for _, q := range questions {
+		if q.Class&0x8000 != 0 {
+			unicast = true
+		}
+		if a := s.answer(q); len(a) > 0 {
+			answers = append(answers, a...)
+			asked = append(asked, q)
+		}
+	}
+	if len(answers) == 0 {
+		return
+	}
+
+	// Legacy one-shot resolvers (source port other than 5353) expect a
+	// plain DNS reply with their ID and question echoed back.
+	id := uint16(0)
+	var echo []dnsmessage.Question
+	if src.Port != group.Port {
+		id = hdr.ID
+		echo = asked
+	}
+	msg, err := s.response(id, answers, echo)
+	if err != nil {
+		log.Printf("mDNS: build reply: %v", err)
+		return
+	}
+	dst := group
+	if unicast {
+		dst = src
+	}
+	s.conn.WriteToUDP(msg, dst)
+}
+
+func (s *Server) answer(q dnsmessage.Question) []dnsmessage.Resource {
+	name := strings.ToLower(q.Name.String())
+	want := func(t dnsmessage.Type) bool { return q.Type == t || q.Type == dnsmessage.TypeALL }
+	var out []dnsmessage.Resource
+
+	if name == strings.ToLower(s.hostName()) && want(dnsmessage.TypeA) {
+		out = append(out, s.aRecord(ttl))
+	}
+	if name == "_services._dns-sd._udp.local." && want(dnsmessage.TypePTR) {
+		seen := map[string]bool{}
+		for _, svc := range s.cfg.Services {
+			if !seen[svc.Type] {
+				seen[svc.Type] = true
+				out = append(out, ptr(name, svc.Type+".local.", ttl))
+			}
+		}
+	}
+	for _, svc := range s.cfg.Services {
+		switch name {
+		case strings.ToLower(svc.Type + ".local."):
+			if want(dnsmessage.TypePTR) {
+				out = append(out, ptr(svc.Type+".local.", instanceName(svc), ttl))
+				out = append(out, s.serviceRecords(svc, ttl)...)
+			}
+		case strings.ToLower(instanceName(svc)):
+			for _, rr := range s.serviceRecords(svc, ttl) {
+				if want(rr.Header.Type) {
+					out = append(out, rr)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func (s *Server) allAnswers(ttl uint32) []dnsmessage.Resource {
+	var out []dnsmessage.Resource
+	for _, svc := range s.cfg.Services {
+		out = append(out, ptr(svc.Type+".local.", instanceName(svc), ttl))
+		out = append(out, s.serviceRecords(svc, ttl)[:2]...)
+	}
+	return append(out, s.aRecord(ttl))
+}
+
+// serviceRecords returns the instance's SRV and TXT records and the host's
+// A record.
+func (s *Server) serviceRecords(svc Service, ttl uint32) []dnsmessage.Resource {
+	name := dnsmessage.MustNewName(instanceName(svc))
+	txt := svc.TXT
+	if len(txt) == 0 {
+		txt = []string{""}
+	}
+	return []dnsmessage.Resource{
+		{
+			Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeSRV, Class: dnsmessage.ClassINET | 0x8000, TTL: ttl},
+			Body:   &dnsmessage.SRVResource{Target: dnsmessage.MustNewName(s.hostName()), Port: uint16(svc.Port)},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET | 0x8000, TTL: ttl},
+			Body:   &dnsmessage.TXTResource{TXT: txt},
+		},
+		s.aRecord(ttl),
+	}
+}
+
+func (s *Server) aRecord(ttl uint32) dnsmessage.Resource {
+	var a [4]byte
+	copy(a[:], s.cfg.IP)
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(s.hostName()), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET | 0x8000, TTL: ttl},
+		Body:   &dnsmessage.AResource{A: a},
+	}
+}
+
+func (s *Server) hostName() string {
+	return s.cfg.Host + ".local."
+}
+
+func (s *Server) response(id uint16, answers []dnsmessage.Resource, questions []dnsmessage.Question) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, Response: true, Authoritative: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	for _, q := range questions {
+		q.Class &^= 0x8000
+		if err := b.Question(q); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, rr := range answers {
+		key := rr.Header.Name.String() + rr.Header.Type.String()
+		if rr.Header.Type != dnsmessage.TypePTR && seen[key] {
+			continue
+		}
+		seen[key] = true
+		var err error
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			err = b.AResource(rr.Header, *body)
+		case *dnsmessage.PTRResource:
+			err = b.PTRResource(rr.Header, *body)
+		case *dnsmessage.SRVResource:
+			err = b.SRVResource(rr.Header, *body)
+		case *dnsmessage.TXTResource:
+			err = b.TXTResource(rr.Header, *body)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+func ptr(name, target string, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(target)},
+	}
+}
+
+func instanceName(svc Service) string {
+	return svc.Instance + "." + svc.Type + ".local."
+}
+
+// label makes s usable as a single DNS label.
+func label(s string) string {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ".", "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return s
+}
@@ -0,0 +1,54 @@
+package mdns
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestAnswer(t *testing.T) {
+	s, err := NewServer(Config{
+		Host: "pxe01.lab",
+		IP:   net.ParseIP("10.0.0.5"),
+		Services: []Service{
+			{Instance: "Bootimus on pxe01", Type: TypeBootimus, Port: 8080, TXT: []string{"menu=http://10.0.0.5:8080/menu.ipxe"}},
+			{Instance: "Bootimus Admin", Type: TypeHTTP, Port: 8081},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := func(name string, typ dnsmessage.Type) []dnsmessage.Resource {
+		return s.answer(dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: typ, Class: dnsmessage.ClassINET})
+	}
+
+	rrs := q("_BOOTIMUS._tcp.local.", dnsmessage.TypePTR)
+	if len(rrs) != 4 {
+		t.Fatalf("PTR query returned %d records, want PTR+SRV+TXT+A", len(rrs))
+	}
+	if got := rrs[0].Body.(*dnsmessage.PTRResource).PTR.String(); got != "Bootimus on pxe01._bootimus._tcp.local." {
+		t.Errorf("PTR = %q", got)
+	}
+	if srv := rrs[1].Body.(*dnsmessage.SRVResource); srv.Port != 8080 || srv.Target.String() != "pxe01.local." {
+		t.Errorf("SRV = %+v", srv)
+	}
+
+	if rrs := q("pxe01.local.", dnsmessage.TypeA); len(rrs) != 1 || rrs[0].Body.(*dnsmessage.AResource).A != [4]byte{10, 0, 0, 5} {
+		t.Errorf("A query = %v", rrs)
+	}
+	if rrs := q("_services._dns-sd._udp.local.", dnsmessage.TypePTR); len(rrs) != 2 {
+		t.Errorf("service enumeration returned %d types, want 2", len(rrs))
+	}
+	if rrs := q("Bootimus Admin._http._tcp.local.", dnsmessage.TypeTXT); len(rrs) != 1 {
+		t.Errorf("TXT query returned %d records", len(rrs))
+	}
+	if rrs := q("other.local.", dnsmessage.TypeA); len(rrs) != 0 {
+		t.Errorf("answered for a name we don't own: %v", rrs)
+	}
+
+	if _, err := s.response(0, s.allAnswers(ttl), nil); err != nil {
+		t.Errorf("announcement: %v", err)
+	}
+}
@@ -3,8 +3,12 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
+	"bootimus/internal/secrets"
+
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -36,6 +40,53 @@ func (s *StringSlice) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, s)
 }
 
+// StringMap is a map column stored as a JSON object.
+type StringMap map[string]string
+
+func (m StringMap) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+func (m *StringMap) Scan(value interface{}) error {
+	*m = StringMap{}
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	}
+	return nil
+}
+
+// Secret is a string column that is encrypted at rest once a secrets key is
+// configured (see package secrets).
+type Secret string
+
+func (s Secret) Value() (driver.Value, error) {
+	return secrets.Encrypt(string(s))
+}
+
+func (s *Secret) Scan(value interface{}) error {
+	var str string
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		str = string(v)
+	case string:
+		str = v
+	}
+	plain, err := secrets.Decrypt(str)
+	if err != nil {
+		return err
+	}
+	*s = Secret(plain)
+	return nil
+}
+
 type User struct {
 	ID        uint       `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time  `json:"created_at"`
@@ -66,17 +117,24 @@ type Client struct {
 	CreatedAt        time.Time      `json:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at"`
 	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	Version          int            `gorm:"default:1;not null" json:"version"` // bumped on every update; see storage.ErrConflict
 	MACAddress       string         `gorm:"uniqueIndex:idx_mac_not_deleted;not null" json:"mac_address"`
 	Name             string         `json:"name"`
 	Description      string         `json:"description"`
+	Tags             StringSlice    `gorm:"type:text" json:"tags,omitempty"` // matched by boot policy rules
 	Enabled          bool           `gorm:"default:true" json:"enabled"`
 	ShowPublicImages bool           `gorm:"default:true" json:"show_public_images"`
+	LiteInitrd       bool           `gorm:"default:false" json:"lite_initrd"` // boot the slimmed initrd where an image has one
 	BootloaderSet    string         `json:"bootloader_set,omitempty"`
 	LastBoot         *time.Time     `json:"last_boot,omitempty"`
 	BootCount        int            `gorm:"default:0" json:"boot_count"`
 	Images           []Image        `gorm:"many2many:client_images;" json:"images,omitempty"`
 	AllowedImages    StringSlice    `gorm:"type:text" json:"allowed_images,omitempty"`
 	NextBootImage    string         `json:"next_boot_image,omitempty"`
+	NextBootOnce     bool           `gorm:"default:false" json:"next_boot_once,omitempty"` // NextBootImage boots without the menu, then LocalBoot is set
+	LocalBoot        bool           `gorm:"default:false" json:"local_boot"`               // menus boot the local disk until cleared
+	DefaultImageID   *uint          `json:"default_image_id,omitempty"`                    // the menu's default for this client
+	BootImmediately  bool           `gorm:"default:false" json:"boot_immediately"`         // boot DefaultImageID without the menu
 	Static           bool           `gorm:"default:false" json:"static"`
 	ClientGroupID    *uint          `gorm:"index" json:"client_group_id,omitempty"`
 	ClientGroup      *ClientGroup   `gorm:"foreignKey:ClientGroupID" json:"client_group,omitempty"`
@@ -84,10 +142,62 @@ type Client struct {
 	IPMIHost     string `json:"ipmi_host,omitempty"`
 	IPMIPort     int    `json:"ipmi_port,omitempty"`
 	IPMIUsername string `json:"ipmi_username,omitempty"`
-	IPMIPassword string `json:"ipmi_password,omitempty"`
+	IPMIPassword Secret `json:"ipmi_password,omitempty"`
 	IPMIInsecure bool   `gorm:"default:false" json:"ipmi_insecure,omitempty"`
 
-	AutoInstallFile string `json:"auto_install_file,omitempty"`
+	AutoInstallFile string    `json:"auto_install_file,omitempty"`
+	AutoInstallVars StringMap `gorm:"type:text" json:"auto_install_vars,omitempty"` // .Vars in auto-install templates
+
+	ReservedIP string `json:"reserved_ip,omitempty"` // always leased this address by the DHCP server
+
+	// Access port the client is cabled to, moved between the provisioning
+	// and production VLANs around installs.
+	SwitchName  string `json:"switch_name,omitempty"`
+	SwitchPort  string `json:"switch_port,omitempty"`
+	SwitchState string `json:"switch_state,omitempty"` // provisioning, production or failed
+	SwitchVLAN  int    `json:"switch_vlan,omitempty"`
+	SwitchError string `json:"switch_error,omitempty"`
+
+	// Bootloader (NBP) the client last fetched, to spot a BIOS client
+	// getting a UEFI binary or the other way round.
+	LastBootloader    string     `json:"last_bootloader,omitempty"`
+	LastBootloaderVia string     `json:"last_bootloader_via,omitempty"` // tftp or http
+	LastBootloaderAt  *time.Time `json:"last_bootloader_at,omitempty"`
+
+	// Firmware the client's iPXE last reported, to warn when a Secure Boot
+	// client is assigned an image it cannot boot.
+	Firmware   string     `json:"firmware,omitempty"`    // efi or pcbios
+	SecureBoot *bool      `json:"secure_boot,omitempty"` // nil when the firmware did not say
+	PlatformAt *time.Time `json:"platform_at,omitempty"`
+	// Filled in by the client list, not stored.
+	SecureBootWarnings []string `gorm:"-" json:"secure_boot_warnings,omitempty"`
+
+	// What the installed system reported when the first-boot agent ran,
+	// confirming the install came up.
+	InstalledHostname string      `json:"installed_hostname,omitempty"`
+	InstalledIPs      StringSlice `gorm:"type:text" json:"installed_ips,omitempty"`
+	InstalledOS       string      `json:"installed_os,omitempty"`
+	DiskSerials       StringSlice `gorm:"type:text" json:"disk_serials,omitempty"`
+	RegisteredAt      *time.Time  `json:"registered_at,omitempty"`
+
+	// TPM attestation, trust on first use. The first verified quote records
+	// the EK, AK and PCR baseline as pending; once an admin approves them,
+	// later quotes that match make the client trusted. Quotes that are not
+	// signed by the approved AK are only counted in AttestFailures; they say
+	// nothing about the machine and never change its trust. Trust counts
+	// only for the attestation TTL after AttestedAt.
+	Trusted        bool       `gorm:"default:false" json:"trusted"`
+	AttestStatus   string     `json:"attest_status,omitempty"` // pending, trusted or failed
+	AttestApproved bool       `gorm:"default:false" json:"attest_approved"`
+	AttestEKHash   string     `json:"attest_ek_hash,omitempty"`
+	AttestAKHash   string     `json:"attest_ak_hash,omitempty"`
+	AttestPCRs     string     `gorm:"type:text" json:"attest_pcrs,omitempty"` // JSON object of PCR index to hex digest
+	AttestError    string     `json:"attest_error,omitempty"`
+	AttestedAt     *time.Time `json:"attested_at,omitempty"`
+
+	AttestFailures    int        `gorm:"default:0" json:"attest_failures,omitempty"`
+	AttestLastFailure string     `json:"attest_last_failure,omitempty"`
+	AttestFailedAt    *time.Time `json:"attest_failed_at,omitempty"`
 }
 
 type ScheduledTask struct {
@@ -111,11 +221,25 @@ type ScheduledTask struct {
 type WebhookConfig struct {
 	ID                 uint      `gorm:"primarykey" json:"id"`
 	UpdatedAt          time.Time `json:"updated_at"`
-	URL                string    `json:"url"`
+	URL                Secret    `json:"url"` // may carry a token (Slack, Discord)
 	Enabled            bool      `gorm:"default:false" json:"enabled"`
 	OnBootStarted      bool      `gorm:"default:true" json:"on_boot_started"`
 	OnClientDiscovered bool      `gorm:"default:true" json:"on_client_discovered"`
 	OnInventoryUpdated bool      `gorm:"default:false" json:"on_inventory_updated"`
+	OnAlert            bool      `gorm:"default:true" json:"on_alert"`
+}
+
+// AccessConfig has no column defaults, as gorm would store them in place of
+// an explicit false or empty value on the first save; GetAccessConfig
+// supplies them while there is no row.
+type AccessConfig struct {
+	ID                  uint        `gorm:"primarykey" json:"id"`
+	UpdatedAt           time.Time   `json:"updated_at"`
+	SSHAuthorizedKeys   StringSlice `gorm:"type:text" json:"ssh_authorized_keys"`
+	DefaultUsername     string      `json:"default_username"`
+	DefaultPasswordHash Secret      `json:"default_password_hash,omitempty"` // crypt(3) hash, as consumed by installers
+	DefaultShell        string      `json:"default_shell"`
+	DefaultSudo         bool        `json:"default_sudo"`
 }
 
 type ClientGroup struct {
@@ -134,7 +258,7 @@ type ClientGroup struct {
 
 	IPMIPort     int    `json:"ipmi_port,omitempty"`
 	IPMIUsername string `json:"ipmi_username,omitempty"`
-	IPMIPassword string `json:"ipmi_password,omitempty"`
+	IPMIPassword Secret `json:"ipmi_password,omitempty"`
 	IPMIInsecure bool   `gorm:"default:false" json:"ipmi_insecure,omitempty"`
 
 	AutoInstallFile string `json:"auto_install_file,omitempty"`
@@ -158,6 +282,8 @@ type ImageGroup struct {
 	Parent      *ImageGroup    `gorm:"foreignKey:ParentID" json:"parent,omitempty"`
 	Order       int            `gorm:"default:0" json:"order"`
 	Enabled     bool           `gorm:"default:true" json:"enabled"`
+	// Images in the group, and its subgroups, ask for the menu PIN.
+	PINProtected bool `gorm:"default:false" json:"pin_protected"`
 }
 
 type Image struct {
@@ -165,10 +291,21 @@ type Image struct {
 	CreatedAt             time.Time      `json:"created_at"`
 	UpdatedAt             time.Time      `json:"updated_at"`
 	DeletedAt             gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	Version               int            `gorm:"default:1;not null" json:"version"`
 	Name                  string         `gorm:"not null" json:"name"`
 	Filename              string         `gorm:"uniqueIndex;not null" json:"filename"`
 	Description           string         `json:"description"`
 	Size                  int64          `json:"size"`
+	SHA256                string         `json:"sha256,omitempty"`          // of the ISO, from an image spec or its first integrity check
+	MD5                   string         `json:"md5,omitempty"`             // of the ISO, recorded alongside SHA256
+	VerifiedAt            *time.Time     `json:"verified_at,omitempty"`     // last time the ISO was rehashed
+	IntegrityError        string         `json:"integrity_error,omitempty"` // why the last rehash failed
+	VolumeLabel           string         `json:"volume_label,omitempty"`
+	Arch                  string         `json:"arch,omitempty"`
+	ReleaseInfo           string         `json:"release_info,omitempty"`    // the ISO's .disk/info or .treeinfo release
+	ReleaseVersion        string         `json:"release_version,omitempty"` // parsed from the filename, see ParseRelease
+	Variant               string         `json:"variant,omitempty"`
+	NewerRelease          string         `gorm:"-" json:"newer_release,omitempty"` // catalog label of a newer release of the same variant
 	Enabled               bool           `gorm:"default:true" json:"enabled"`
 	Public                bool           `gorm:"default:false" json:"public"`
 	BootCount             int            `gorm:"default:0" json:"boot_count"`
@@ -182,8 +319,10 @@ type Image struct {
 	BootMethod            string         `gorm:"default:sanboot" json:"boot_method"`
 	KernelPath            string         `json:"kernel_path,omitempty"`
 	InitrdPath            string         `json:"initrd_path,omitempty"`
+	KernelArches          StringSlice    `gorm:"type:text" json:"kernel_arches,omitempty"` // multi-arch ISOs: the primary kernel's arch, then those extracted to <boot dir>/<arch>/
 	BootParams            string         `json:"boot_params,omitempty"`
 	SquashfsPath          string         `json:"squashfs_path,omitempty"`
+	LiteInitrdSize        int64          `json:"lite_initrd_size,omitempty"` // size of initrd-lite, the slimmed initrd; 0 when there is none
 	ExtractionError       string         `json:"extraction_error,omitempty"`
 	ExtractedAt           *time.Time     `json:"extracted_at,omitempty"`
 	SanbootCompatible     bool           `gorm:"default:true" json:"sanboot_compatible"`
@@ -191,6 +330,8 @@ type Image struct {
 	NetbootRequired       bool           `gorm:"default:false" json:"netboot_required"`
 	NetbootAvailable      bool           `gorm:"default:false" json:"netboot_available"`
 	NetbootURL            string         `json:"netboot_url,omitempty"`
+	NetbootChecksum       string         `json:"netboot_checksum,omitempty"` // sha256 of the tarball last installed
+	NetbootFetchedAt      *time.Time     `json:"netboot_fetched_at,omitempty"`
 	AutoInstallScript     string         `gorm:"type:text" json:"auto_install_script,omitempty"`
 	AutoInstallEnabled    bool           `gorm:"default:false" json:"auto_install_enabled"`
 	AutoInstallScriptType string         `json:"auto_install_script_type,omitempty"`
@@ -200,11 +341,83 @@ type Image struct {
 	SMBNeedsRepatch       bool           `gorm:"-" json:"smb_needs_repatch"`
 
 	AutoInstallFile string `json:"auto_install_file,omitempty"`
+
+	AutoInstallGenerator string `json:"auto_install_generator,omitempty"` // "windows", "windows-server", "preseed", "kickstart"; empty = hand-written
+	AutoInstallParams    string `gorm:"type:text" json:"-"`               // structured generator input (JSON), without secrets; only GET /api/images/autoinstall returns it
+	// Placeholders in the generated script and their values (JSON), such as
+	// {{ADMIN_PASSWORD}} and {{DOMAIN_PASSWORD}}; kept out of AutoInstallParams.
+	AutoInstallSecrets Secret `gorm:"type:text" json:"-"`
+
+	RescueEnabled bool   `gorm:"default:false" json:"rescue_enabled"`
+	RescueParams  string `json:"rescue_params,omitempty"`
+
+	PINProtected   bool `gorm:"default:false" json:"pin_protected"`    // the menu asks for the PIN before booting it
+	FirstBootAgent bool `gorm:"default:false" json:"first_boot_agent"` // load the first-boot agent overlay with the initrd
+
+	ISCSIEnabled bool   `gorm:"default:false" json:"iscsi_enabled"` // publish the ISO as an iSCSI LUN and sanboot it from there
+	ISCSITarget  string `gorm:"-" json:"iscsi_target,omitempty"`    // the IQN it is published under, while the target runs
+
+	CloneOf string `gorm:"index" json:"clone_of,omitempty"` // source image filename for variants; the ISO and extraction are shared
+
+	// Virtual images (boot_method "remote") have no ISO; the menu boots these URLs.
+	KernelURL   string `json:"kernel_url,omitempty"`
+	InitrdURL   string `json:"initrd_url,omitempty"`
+	CacheRemote bool   `gorm:"default:false" json:"cache_remote"` // proxy the URLs through /remote/ and keep a local copy
+
+	// External images sanboot an ISO hosted on another HTTP server, straight
+	// from ISOURL or, with CacheRemote, through /isos/. Their filename ends
+	// in ".url".
+	ISOURL        string     `json:"iso_url,omitempty"`
+	ISOCheckError string     `json:"iso_check_error,omitempty"` // why the last HEAD request failed
+	ISOCheckedAt  *time.Time `json:"iso_checked_at,omitempty"`
+
+	// Images pulled from an OCI registry. A bundle of kernel/initrd/squashfs
+	// layers has no ISO: its filename ends in ".oci" and the files sit in
+	// the usual extraction directory.
+	OCIRef       string     `json:"oci_ref,omitempty"`
+	OCIDigest    string     `json:"oci_digest,omitempty"` // digest the tag pointed to when last pulled
+	OCITrack     bool       `gorm:"default:false" json:"oci_track"`
+	OCIUsername  string     `json:"oci_username,omitempty"`
+	OCIPassword  Secret     `json:"-"`
+	OCICosignKey string     `gorm:"type:text" json:"oci_cosign_key,omitempty"` // PEM; when set, pulls must be signed by it
+	OCICheckedAt *time.Time `json:"oci_checked_at,omitempty"`
+
+	// Menus leave the image out before VisibleFrom, after VisibleUntil and,
+	// when VisibleWindows is set, outside those windows (see VisibleAt).
+	VisibleFrom    *time.Time `json:"visible_from,omitempty"`
+	VisibleUntil   *time.Time `json:"visible_until,omitempty"`
+	VisibleWindows string     `json:"visible_windows,omitempty"`
+}
+
+// DiskFilename is the ISO this image boots from: the source's for a
+// variant, otherwise its own.
+func (i *Image) DiskFilename() string {
+	if i.CloneOf != "" {
+		return i.CloneOf
+	}
+	return i.Filename
+}
+
+func (i *Image) IsVirtual() bool {
+	return i.BootMethod == "remote"
+}
+
+func (i *Image) IsOCIBundle() bool {
+	return strings.HasSuffix(i.DiskFilename(), ".oci")
+}
+
+func (i *Image) IsExternalISO() bool {
+	return i.ISOURL != ""
+}
+
+// HasISOFile reports whether an ISO backs this image on disk.
+func (i *Image) HasISOFile() bool {
+	return !i.IsVirtual() && !i.IsOCIBundle() && !i.IsExternalISO()
 }
 
 type BootLog struct {
 	ID         uint      `gorm:"primarykey" json:"id"`
-	CreatedAt  time.Time `json:"created_at"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
 	ClientID   *uint     `json:"client_id,omitempty"`
 	Client     *Client   `gorm:"foreignKey:ClientID" json:"client,omitempty"`
 	ImageID    *uint     `json:"image_id,omitempty"`
@@ -214,6 +427,13 @@ type BootLog struct {
 	Success    bool      `json:"success"`
 	ErrorMsg   string    `json:"error_msg,omitempty"`
 	IPAddress  string    `json:"ip_address,omitempty"`
+	BootMethod string    `json:"boot_method,omitempty"` // the image's boot method at the time
+
+	// Set when the installed system called back to say the install
+	// finished, with what it reported about itself.
+	CompletedAt       *time.Time `json:"completed_at,omitempty"`
+	InstalledHostname string     `json:"installed_hostname,omitempty"`
+	InstalledOS       string     `json:"installed_os,omitempty"`
 }
 
 type HardwareInventory struct {
@@ -230,6 +450,7 @@ type HardwareInventory struct {
 	Memory       int64     `json:"memory,omitempty"`
 	Platform     string    `json:"platform,omitempty"`
 	BuildArch    string    `json:"buildarch,omitempty"`
+	SecureBoot   *bool     `json:"secure_boot,omitempty"`
 	Asset        string    `json:"asset,omitempty"`
 	NICChip      string    `json:"nic_chip,omitempty"`
 }
@@ -295,6 +516,145 @@ type MenuTheme struct {
 	Title           string `gorm:"default:Bootimus - Boot Menu" json:"title"`
 	MenuTimeout     int    `gorm:"default:30" json:"menu_timeout"` // seconds, 0 = no timeout (wait forever)
 	DefaultMenuItem string `gorm:"default:local" json:"default_menu_item"`
+
+	// Colours are names from MenuColours; empty leaves the console's own.
+	TextColour               string `json:"text_colour,omitempty"`
+	BackgroundColour         string `json:"background_colour,omitempty"`
+	SelectedTextColour       string `json:"selected_text_colour,omitempty"`
+	SelectedBackgroundColour string `json:"selected_background_colour,omitempty"`
+
+	MOTD string `gorm:"type:text" json:"motd,omitempty"` // shown above the menu entries, one line per line
+}
+
+// MenuColours are the colours a menu theme may use, indexed by their iPXE
+// colour number.
+var MenuColours = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// MenuColour is the iPXE colour number of name: 9, the console default, for
+// "", and false for a name not in MenuColours.
+func MenuColour(name string) (int, bool) {
+	if name == "" {
+		return 9, true
+	}
+	for i, c := range MenuColours {
+		if strings.EqualFold(c, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Validate rejects settings the menu can't render.
+func (t *MenuTheme) Validate() error {
+	if t.MenuTimeout < 0 {
+		return fmt.Errorf("menu_timeout must not be negative")
+	}
+	switch t.DefaultMenuItem {
+	case "", "local", "shell", "reboot":
+	default:
+		return fmt.Errorf("default_menu_item must be local, shell, reboot or empty")
+	}
+	for _, c := range [][2]string{
+		{"text_colour", t.TextColour},
+		{"background_colour", t.BackgroundColour},
+		{"selected_text_colour", t.SelectedTextColour},
+		{"selected_background_colour", t.SelectedBackgroundColour},
+	} {
+		if _, ok := MenuColour(c[1]); !ok {
+			return fmt.Errorf("%s must be one of %s", c[0], strings.Join(MenuColours, ", "))
+		}
+	}
+	return nil
+}
+
+// MenuSnapshot is the published menu layout while a draft is open: clients
+// keep seeing it while groups, image placement and the theme are edited,
+// until the draft is published or discarded. There is at most one.
+type MenuSnapshot struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Groups    string    `gorm:"type:text" json:"-"`
+	Images    string    `gorm:"type:text" json:"-"`
+	Theme     string    `gorm:"type:text" json:"-"`
+}
+
+// MenuImageLayout is the part of an image the menu layout covers.
+type MenuImageLayout struct {
+	Filename string `json:"filename"`
+	Name     string `json:"name"`
+	GroupID  *uint  `json:"group_id,omitempty"`
+	Order    int    `json:"order"`
+}
+
+func NewMenuSnapshot(groups []*ImageGroup, images []*Image, theme *MenuTheme) (*MenuSnapshot, error) {
+	layout := make([]MenuImageLayout, 0, len(images))
+	for _, img := range images {
+		layout = append(layout, MenuImageLayout{Filename: img.Filename, Name: img.Name, GroupID: img.GroupID, Order: img.Order})
+	}
+	g, err := json.Marshal(groups)
+	if err != nil {
+		return nil, err
+	}
+	i, err := json.Marshal(layout)
+	if err != nil {
+		return nil, err
+	}
+	t, err := json.Marshal(theme)
+	if err != nil {
+		return nil, err
+	}
+	return &MenuSnapshot{ID: 1, Groups: string(g), Images: string(i), Theme: string(t)}, nil
+}
+
+func (m *MenuSnapshot) Layout() (groups []*ImageGroup, images []MenuImageLayout, theme *MenuTheme, err error) {
+	if err = json.Unmarshal([]byte(m.Groups), &groups); err != nil {
+		return
+	}
+	if err = json.Unmarshal([]byte(m.Images), &images); err != nil {
+		return
+	}
+	err = json.Unmarshal([]byte(m.Theme), &theme)
+	return
+}
+
+// BootMethodStat counts the logged boots of an image by one boot method.
+type BootMethodStat struct {
+	ImageName  string `json:"image_name"`
+	BootMethod string `json:"boot_method"`
+	Boots      int64  `json:"boots"`
+	Failures   int64  `json:"failures"`
+}
+
+// BootDistroStat counts the logged boots of one distro by one boot method.
+// Distro is empty for boots of images with no distro detected.
+type BootDistroStat struct {
+	Distro     string `json:"distro"`
+	BootMethod string `json:"boot_method"`
+	Boots      int64  `json:"boots"`
+	Failures   int64  `json:"failures"`
+}
+
+// StatsRollup is one hour or day of boot activity, kept so graphs don't have
+// to scan BootLog. Start is UTC and the bucket covers [Start, Start+period).
+type StatsRollup struct {
+	ID            uint      `gorm:"primarykey" json:"-"`
+	Period        string    `gorm:"uniqueIndex:idx_stats_bucket;not null" json:"-"` // hour or day
+	Start         time.Time `gorm:"uniqueIndex:idx_stats_bucket;not null" json:"start"`
+	Boots         int64     `gorm:"not null;default:0" json:"boots"`
+	FailedBoots   int64     `gorm:"not null;default:0" json:"failed_boots"`
+	ActiveClients int64     `gorm:"not null;default:0" json:"active_clients"`
+	BytesServed   int64     `gorm:"not null;default:0" json:"bytes_served"`
+}
+
+// Revision is an image or client as it was before an admin edit or delete,
+// kept so the change can be reviewed and undone.
+type Revision struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	EntityType string    `gorm:"index:idx_revision_entity;not null" json:"entity_type"` // image or client
+	EntityKey  string    `gorm:"index:idx_revision_entity;not null" json:"entity_key"`  // filename or MAC
+	Action     string    `gorm:"not null" json:"action"`                                // update or delete
+	Data       Secret    `gorm:"type:text" json:"-"`                                    // snapshot JSON, which can include BMC credentials
 }
 
 type BootTool struct {
@@ -319,3 +679,138 @@ type BootTool struct {
 	DownloadURLBIOS string `json:"download_url_bios,omitempty"`      // optional BIOS variant URL (chain tools)
 	KernelPathBIOS  string `json:"kernel_path_bios,omitempty"`       // optional BIOS variant local path
 }
+
+type DiskImage struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Name        string    `gorm:"not null" json:"name"`
+	Filename    string    `gorm:"uniqueIndex;not null" json:"filename"` // relative to data/captures
+	Description string    `json:"description"`
+	Format      string    `gorm:"default:raw" json:"format"`       // "raw"; "partclone" for single-filesystem images from older releases
+	Compression string    `gorm:"default:zstd" json:"compression"` // "zstd" or "none"
+	FSType      string    `json:"fs_type,omitempty"`               // partclone images only
+	Size        int64     `json:"size"`                            // bytes on disk (compressed)
+	DiskSize    int64     `json:"disk_size"`                       // source device size
+	SHA256      string    `json:"sha256,omitempty"`
+	SourceMAC   string    `json:"source_mac,omitempty"`
+	SourceDev   string    `json:"source_device,omitempty"`
+	Status      string    `gorm:"default:uploading" json:"status"` // "uploading", "complete", "failed"
+}
+
+type DiskTask struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	MACAddress  string     `gorm:"index;not null" json:"mac_address"`
+	Kind        string     `gorm:"not null" json:"kind"`       // "capture" or "deploy"
+	LiveImage   string     `gorm:"not null" json:"live_image"` // image filename booted to run the task
+	DiskImageID *uint      `json:"disk_image_id,omitempty"`
+	DiskImage   *DiskImage `gorm:"foreignKey:DiskImageID" json:"disk_image,omitempty"`
+	Device      string     `json:"device,omitempty"` // empty = first non-removable disk; deploys need it to be the only one
+	Format      string     `gorm:"default:raw" json:"format"`
+	Token       Secret     `gorm:"not null" json:"-"`
+	Status      string     `gorm:"default:pending;index" json:"status"` // "pending", "running", "complete", "failed", "cancelled"
+	Message     string     `json:"message,omitempty"`
+	BytesDone   int64      `json:"bytes_done"`
+	BytesTotal  int64      `json:"bytes_total"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// BootParamOverride layers extra kernel parameters onto an image's defaults
+// for one client. Once overrides are consumed by the next menu render.
+type BootParamOverride struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	MACAddress    string    `gorm:"index;not null" json:"mac_address"`
+	ImageFilename string    `json:"image_filename"` // empty = every image
+	Params        string    `json:"params"`
+	Replace       bool      `gorm:"default:false" json:"replace"` // replace the image params instead of appending
+	Once          bool      `gorm:"default:false;index" json:"once"`
+}
+
+// DHCPLease is an address the built-in DHCP server has handed out, kept so
+// clients get the same one back across restarts.
+type DHCPLease struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	MACAddress string    `gorm:"uniqueIndex;not null" json:"mac_address"`
+	IPAddress  string    `gorm:"index;not null" json:"ip_address"`
+	Hostname   string    `json:"hostname,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// ExtractionState is an extraction that has started and not finished. One
+// left behind by a restart is resumed from Step at the next startup.
+type ExtractionState struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Filename  string    `gorm:"uniqueIndex;not null" json:"filename"`
+	Step      string    `json:"step"`
+	BootFiles string    `gorm:"type:text" json:"-"` // the extractor's result (JSON), once the files are out
+}
+
+// Job is a long-running admin operation (see package jobs) as last
+// recorded, so recent jobs and their logs outlive a restart.
+type Job struct {
+	ID         uint64     `gorm:"primarykey" json:"id"`
+	Kind       string     `gorm:"index" json:"kind"`
+	Target     string     `json:"target"`
+	Params     string     `gorm:"type:text" json:"-"` // JSON, what a retry runs with
+	Status     string     `json:"status"`
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+	Attempts   int        `json:"attempts"`
+	RetryOf    uint64     `json:"retry_of,omitempty"`
+	StartedAt  time.Time  `gorm:"index" json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Log        string     `gorm:"type:text" json:"-"`
+}
+
+// Extraction steps, in order.
+const (
+	ExtractStepFiles    = "files"    // copying the boot files out of the ISO
+	ExtractStepMetadata = "metadata" // writing metadata.txt beside them
+	ExtractStepImage    = "image"    // switching the image to kernel boot
+)
+
+// ConsoleCapture is a serial log or screenshot posted by an installer or live
+// environment, tied to the boot it came from.
+type ConsoleCapture struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	MACAddress  string    `gorm:"index;not null" json:"mac_address"`
+	BootLogID   *uint     `gorm:"index" json:"boot_log_id,omitempty"`
+	ImageName   string    `json:"image_name,omitempty"`
+	Kind        string    `gorm:"not null" json:"kind"` // "serial" or "screenshot"
+	Source      string    `json:"source,omitempty"`     // log path or stage reported by the client
+	Filename    string    `gorm:"not null" json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+}
+
+// RemoteMenu is a boot menu entry that chains to an iPXE script served
+// elsewhere: another Bootimus, a self-hosted netboot.xyz or a vendor's
+// tools. Empty client lists show it to every client.
+type RemoteMenu struct {
+	ID           uint        `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+	Name         string      `gorm:"uniqueIndex;not null" json:"name"` // the menu item's text
+	URL          string      `gorm:"not null" json:"url"`              // may use iPXE settings, e.g. ${net0/mac}
+	Enabled      bool        `json:"enabled"`                          // no DB default: gorm would turn a new disabled menu into an enabled one
+	Order        int         `gorm:"default:0" json:"order"`
+	ClientGroups StringSlice `gorm:"type:text" json:"client_groups,omitempty"` // group names
+	ClientTags   StringSlice `gorm:"type:text" json:"client_tags,omitempty"`
+	ClientMACs   StringSlice `gorm:"type:text" json:"client_macs,omitempty"`
+
+	// Result of the last reachability check.
+	HideUnreachable bool       `gorm:"default:false" json:"hide_unreachable"` // leave it out of menus while the check fails
+	Reachable       *bool      `json:"reachable,omitempty"`
+	CheckError      string     `json:"check_error,omitempty"`
+	CheckedAt       *time.Time `json:"checked_at,omitempty"`
+}
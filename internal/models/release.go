@@ -0,0 +1,140 @@
+package models
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Release is what an ISO filename says about its contents, e.g.
+// debian-13.2.0-amd64-netinst.iso is distro "debian", version "13.2.0",
+// arch "x86_64" and variant "netinst".
+type Release struct {
+	Distro  string `json:"distro,omitempty"`
+	Version string `json:"version,omitempty"`
+	Arch    string `json:"arch,omitempty"`
+	Variant string `json:"variant,omitempty"`
+}
+
+var (
+	releaseToken   = regexp.MustCompile(`x86_64|[a-z0-9.]+`)
+	versionToken   = regexp.MustCompile(`^v?\d+(\.\d+)*$|^\d{2}h\d$`)
+	variantToken   = regexp.MustCompile(`^[a-z]+$`)
+	releaseFillers = map[string]bool{"linux": true, "iso": true, "media": true, "latest": true, "current": true, "install": true, "os": true}
+)
+
+// ParseRelease splits a filename into its release fields. Words before the
+// version are the distro and its edition, words after it the variant; the
+// first number-like word is the version and later ones (build numbers,
+// dates) are ignored.
+func ParseRelease(filename string) Release {
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)))
+	var r Release
+	var variant []string
+	for i, tok := range releaseToken.FindAllString(name, -1) {
+		tok = strings.Trim(tok, ".")
+		switch {
+		case tok == "":
+		case i == 0:
+			r.Distro = tok
+		case r.Arch == "" && NormaliseArch(tok) != "":
+			r.Arch = NormaliseArch(tok)
+		case versionToken.MatchString(tok):
+			if r.Version == "" {
+				r.Version = strings.TrimPrefix(tok, "v")
+			}
+		case variantToken.MatchString(tok) && !releaseFillers[tok]:
+			variant = append(variant, tok)
+		}
+	}
+	r.Variant = strings.Join(variant, "-")
+	return r
+}
+
+// DisplayName is the release as a menu entry would show it, e.g.
+// "Debian 13.2.0 Netinst (x86_64)". distroName replaces the distro word
+// when given.
+func (r Release) DisplayName(distroName string) string {
+	if distroName == "" && r.Distro != "" {
+		distroName = strings.ToUpper(r.Distro[:1]) + r.Distro[1:]
+	}
+	parts := []string{distroName}
+	if r.Version != "" {
+		parts = append(parts, strings.ToUpper(r.Version))
+	}
+	if r.Variant != "" {
+		parts = append(parts, titleWords(strings.ReplaceAll(r.Variant, "-", " ")))
+	}
+	if r.Arch != "" {
+		parts = append(parts, "("+r.Arch+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// titleWords capitalises each word, and short ones (dvd, kde, net)
+// entirely since those are nearly always acronyms.
+func titleWords(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		if len(w) <= 3 {
+			words[i] = strings.ToUpper(w)
+		} else {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// NormaliseArch maps the spellings distros use in filenames and metadata
+// to one name per architecture, or "" if a is not one.
+func NormaliseArch(a string) string {
+	switch strings.ToLower(strings.TrimSpace(a)) {
+	case "x86_64", "amd64", "x64", "64bit":
+		return "x86_64"
+	case "aarch64", "arm64":
+		return "arm64"
+	case "i386", "i486", "i586", "i686", "x86", "32bit":
+		return "i386"
+	case "riscv64":
+		return "riscv64"
+	case "ppc64le":
+		return "ppc64le"
+	case "s390x":
+		return "s390x"
+	}
+	return ""
+}
+
+// CompareVersions orders dotted versions numerically, so 9.10 is newer
+// than 9.4. It returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(strings.ToLower(a), "."), strings.Split(strings.ToLower(b), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, xerr := strconv.Atoi(x)
+		yn, yerr := strconv.Atoi(y)
+		switch {
+		case xerr == nil && yerr == nil:
+			if xn != yn {
+				if xn < yn {
+					return -1
+				}
+				return 1
+			}
+		case x != y:
+			// A missing part counts as older: 13 < 13.1.
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,23 @@
+package models
+
+import "fmt"
+
+// SecureBootWarnings lists the images c is unlikely to boot given the
+// platform it last reported. With Secure Boot on, whatever iPXE loads
+// itself (kernel, NBD and NFS boots, and wimboot for Windows) must be
+// signed by a key the firmware trusts, which distro kernels and wimboot
+// are not. Sanboot hands the ISO to the firmware, and the ISO's own
+// loader normally is signed.
+func SecureBootWarnings(c *Client, images []Image) []string {
+	if c.SecureBoot == nil || !*c.SecureBoot {
+		return nil
+	}
+	var warnings []string
+	for _, img := range images {
+		switch img.BootMethod {
+		case "kernel", "nbd", "nfs":
+			warnings = append(warnings, fmt.Sprintf("%s uses %s boot, which Secure Boot blocks unless its kernel is signed by a key the firmware trusts", img.Name, img.BootMethod))
+		}
+	}
+	return warnings
+}
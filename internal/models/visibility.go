@@ -0,0 +1,176 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A visibility window is "<days> <HH:MM>-<HH:MM>", either part optional,
+// e.g. "mon-fri 08:00-17:00", "sat,sun" or "22:00-06:00". Several are
+// separated by ";". Times are server local; a window that ends before it
+// starts runs past midnight into the next day.
+type VisibleWindow struct {
+	Days       [7]bool // indexed by time.Weekday
+	Start, End int     // minutes since midnight; both 0 for all day
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func ParseVisibleWindows(spec string) ([]VisibleWindow, error) {
+	var windows []VisibleWindow
+	for _, part := range strings.Split(spec, ";") {
+		fields := strings.Fields(strings.ToLower(part))
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf(`window %q: want "<days> <HH:MM>-<HH:MM>"`, strings.TrimSpace(part))
+		}
+		var w VisibleWindow
+		days, hours := fields[0], ""
+		if len(fields) == 2 {
+			hours = fields[1]
+		} else if strings.Contains(days, ":") {
+			days, hours = "", days
+		}
+		if days == "" {
+			w.Days = [7]bool{true, true, true, true, true, true, true}
+		} else if err := parseDays(days, &w.Days); err != nil {
+			return nil, fmt.Errorf("window %q: %w", strings.TrimSpace(part), err)
+		}
+		if hours != "" {
+			from, to, ok := strings.Cut(hours, "-")
+			var err1, err2 error
+			w.Start, err1 = parseClock(from)
+			w.End, err2 = parseClock(to)
+			if !ok || err1 != nil || err2 != nil || w.Start == w.End {
+				return nil, fmt.Errorf("window %q: bad time range %q", strings.TrimSpace(part), hours)
+			}
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func parseDays(s string, days *[7]bool) error {
+	for _, item := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(item, "-")
+		a, ok1 := weekdays[from]
+		b, ok2 := weekdays[to]
+		if !isRange {
+			b, ok2 = a, ok1
+		}
+		if !ok1 || !ok2 {
+			return fmt.Errorf("unknown day %q", item)
+		}
+		for d := a; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == b {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hh, err1 := strconv.Atoi(h)
+	mm, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hh < 0 || mm < 0 || mm > 59 || hh > 24 || (hh == 24 && mm != 0) {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+	return hh*60 + mm, nil
+}
+
+func (w VisibleWindow) contains(t time.Time) bool {
+	if w.Start == w.End {
+		return w.Days[t.Weekday()]
+	}
+	m := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return w.Days[t.Weekday()] && m >= w.Start && m < w.End
+	}
+	// Past midnight: the evening part belongs to today, the morning part
+	// to the day before.
+	if m >= w.Start {
+		return w.Days[t.Weekday()]
+	}
+	return m < w.End && w.Days[(t.Weekday()+6)%7]
+}
+
+// VisibleAt reports whether the image belongs in menus at t. A schedule
+// that does not parse hides the image rather than exposing it.
+func (i *Image) VisibleAt(t time.Time) bool {
+	if i.VisibleFrom != nil && t.Before(*i.VisibleFrom) {
+		return false
+	}
+	if i.VisibleUntil != nil && !t.Before(*i.VisibleUntil) {
+		return false
+	}
+	if strings.TrimSpace(i.VisibleWindows) == "" {
+		return true
+	}
+	windows, err := ParseVisibleWindows(i.VisibleWindows)
+	if err != nil {
+		return false
+	}
+	t = t.Local()
+	for _, w := range windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return len(windows) == 0
+}
+
+// VisibleImages returns the images visible at t.
+func VisibleImages(images []Image, t time.Time) []Image {
+	visible := images[:0:0]
+	for i := range images {
+		if images[i].VisibleAt(t) {
+			visible = append(visible, images[i])
+		}
+	}
+	return visible
+}
+
+// VisibleTo reports whether a remote menu is offered to client, which is in
+// the group named group. Unknown clients (nil) only see unrestricted menus.
+func (m *RemoteMenu) VisibleTo(client *Client, group string) bool {
+	if len(m.ClientGroups) == 0 && len(m.ClientTags) == 0 && len(m.ClientMACs) == 0 {
+		return true
+	}
+	if client == nil {
+		return false
+	}
+	for _, mac := range m.ClientMACs {
+		if strings.EqualFold(mac, client.MACAddress) {
+			return true
+		}
+	}
+	for _, g := range m.ClientGroups {
+		if group != "" && g == group {
+			return true
+		}
+	}
+	for _, tag := range m.ClientTags {
+		for _, t := range client.Tags {
+			if tag == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// InMenus reports whether the menu is shown at all: enabled, and not hidden
+// by a failed reachability check.
+func (m *RemoteMenu) InMenus() bool {
+	return m.Enabled && !(m.HideUnreachable && m.Reachable != nil && !*m.Reachable)
+}
@@ -30,11 +30,14 @@ const (
 )
 
 type Server struct {
-	isoDir   string
+	isoDirs  []string
 	port     int
 	listener net.Listener
 	mu       sync.RWMutex
 	clients  map[string]*Client
+
+	// OnRead, if set, is told about every read a client makes.
+	OnRead func(isoName string, size, offset, length int64)
 }
 
 type Client struct {
@@ -44,9 +47,11 @@ type Client struct {
 	isoName  string
 }
 
-func NewServer(isoDir string, port int) *Server {
+// NewServer exports the ISOs under isoDirs; a name found in more than one
+// is served from the first.
+func NewServer(isoDirs []string, port int) *Server {
 	return &Server{
-		isoDir:  isoDir,
+		isoDirs: isoDirs,
 		port:    port,
 		clients: make(map[string]*Client),
 	}
@@ -78,7 +83,7 @@ func (s *Server) handleClient(conn net.Conn) {
 
 	log.Printf("NBD client connected: %s", conn.RemoteAddr())
 
-	isoPath, fileSize, err := s.negotiateConnection(conn)
+	isoPath, exportName, fileSize, err := s.negotiateConnection(conn)
 	if err != nil {
 		log.Printf("Negotiation failed: %v", err)
 		return
@@ -95,7 +100,7 @@ func (s *Server) handleClient(conn net.Conn) {
 		conn:     conn,
 		file:     file,
 		fileSize: fileSize,
-		isoName:  filepath.Base(isoPath),
+		isoName:  exportName,
 	}
 
 	if err := s.handleRequests(client); err != nil {
@@ -105,77 +110,84 @@ func (s *Server) handleClient(conn net.Conn) {
 	log.Printf("NBD client disconnected: %s", conn.RemoteAddr())
 }
 
-func (s *Server) negotiateConnection(conn net.Conn) (string, int64, error) {
+func (s *Server) negotiateConnection(conn net.Conn) (string, string, int64, error) {
 	if err := binary.Write(conn, binary.BigEndian, INIT_PASSWD); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 	if err := binary.Write(conn, binary.BigEndian, OPTS_MAGIC); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 
 	flags := uint16(NBD_FLAG_FIXED_NEWSTYLE | NBD_FLAG_NO_ZEROES)
 	if err := binary.Write(conn, binary.BigEndian, flags); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 
 	var clientFlags uint32
 	if err := binary.Read(conn, binary.BigEndian, &clientFlags); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 
 	var optMagic uint64
 	if err := binary.Read(conn, binary.BigEndian, &optMagic); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 	if optMagic != OPTS_MAGIC {
-		return "", 0, fmt.Errorf("invalid option magic")
+		return "", "", 0, fmt.Errorf("invalid option magic")
 	}
 
 	var optType uint32
 	if err := binary.Read(conn, binary.BigEndian, &optType); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 
 	var nameLen uint32
 	if err := binary.Read(conn, binary.BigEndian, &nameLen); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 
 	nameBuf := make([]byte, nameLen)
 	if _, err := io.ReadFull(conn, nameBuf); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 	exportName := string(nameBuf)
 
-	isoPath := filepath.Join(s.isoDir, exportName)
-	stat, err := os.Stat(isoPath)
-	if err != nil {
-		return "", 0, fmt.Errorf("ISO not found: %s", exportName)
+	var isoPath string
+	var stat os.FileInfo
+	for _, dir := range s.isoDirs {
+		p := filepath.Join(dir, exportName)
+		if st, err := os.Stat(p); err == nil {
+			isoPath, stat = p, st
+			break
+		}
+	}
+	if stat == nil {
+		return "", "", 0, fmt.Errorf("ISO not found: %s", exportName)
 	}
 
 	if err := binary.Write(conn, binary.BigEndian, uint64(NBD_REPLY_MAGIC)); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 	if err := binary.Write(conn, binary.BigEndian, uint32(NBD_OPT_EXPORT)); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 	if err := binary.Write(conn, binary.BigEndian, uint32(NBD_REP_ACK)); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 	if err := binary.Write(conn, binary.BigEndian, uint32(0)); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 
 	if err := binary.Write(conn, binary.BigEndian, uint64(stat.Size())); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 
 	transmissionFlags := uint16(1)
 	if err := binary.Write(conn, binary.BigEndian, transmissionFlags); err != nil {
-		return "", 0, err
+		return "", "", 0, err
 	}
 
-	return isoPath, stat.Size(), nil
+	return isoPath, exportName, stat.Size(), nil
 }
 
 func (s *Server) handleRequests(client *Client) error {
@@ -219,6 +231,10 @@ func (s *Server) handleRead(client *Client, handle uint64, offset uint64, length
 		return s.sendReply(client, handle, 1)
 	}
 
+	if s.OnRead != nil {
+		s.OnRead(client.isoName, client.fileSize, int64(offset), int64(n))
+	}
+
 	if err := s.sendReply(client, handle, 0); err != nil {
 		return err
 	}
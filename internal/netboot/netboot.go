@@ -0,0 +1,120 @@
+// Package netboot knows where each distro publishes its official netboot
+// installer, for ISOs whose own kernel can't install over the network.
+package netboot
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type Source struct {
+	Distro       string   `json:"distro"`
+	Release      string   `json:"release"`
+	Arch         string   `json:"arch"`
+	Versions     []string `json:"versions"` // version prefixes in ISO filenames that map to this release
+	URL          string   `json:"url"`
+	ChecksumURL  string   `json:"checksum_url,omitempty"`
+	ChecksumName string   `json:"checksum_name,omitempty"` // entry in the checksum file
+}
+
+// Sources lists the known tarballs; for each distro the first entry is the
+// default used when the ISO filename carries no recognisable version.
+var Sources = []Source{
+	{
+		Distro: "debian", Release: "trixie", Arch: "amd64", Versions: []string{"13"},
+		URL:          "http://ftp.debian.org/debian/dists/trixie/main/installer-amd64/current/images/netboot/netboot.tar.gz",
+		ChecksumURL:  "http://ftp.debian.org/debian/dists/trixie/main/installer-amd64/current/images/SHA256SUMS",
+		ChecksumName: "./netboot/netboot.tar.gz",
+	},
+	{
+		Distro: "debian", Release: "bookworm", Arch: "amd64", Versions: []string{"12"},
+		URL:          "http://ftp.debian.org/debian/dists/bookworm/main/installer-amd64/current/images/netboot/netboot.tar.gz",
+		ChecksumURL:  "http://ftp.debian.org/debian/dists/bookworm/main/installer-amd64/current/images/SHA256SUMS",
+		ChecksumName: "./netboot/netboot.tar.gz",
+	},
+	{
+		Distro: "ubuntu", Release: "noble", Arch: "amd64", Versions: []string{"24.04"},
+		URL:          "http://archive.ubuntu.com/ubuntu/dists/noble/main/installer-amd64/current/legacy-images/netboot/netboot.tar.gz",
+		ChecksumURL:  "http://archive.ubuntu.com/ubuntu/dists/noble/main/installer-amd64/current/legacy-images/SHA256SUMS",
+		ChecksumName: "./netboot/netboot.tar.gz",
+	},
+	{
+		Distro: "ubuntu", Release: "focal", Arch: "amd64", Versions: []string{"20.04"},
+		URL:          "http://archive.ubuntu.com/ubuntu/dists/focal-updates/main/installer-amd64/current/legacy-images/netboot/netboot.tar.gz",
+		ChecksumURL:  "http://archive.ubuntu.com/ubuntu/dists/focal-updates/main/installer-amd64/current/legacy-images/SHA256SUMS",
+		ChecksumName: "./netboot/netboot.tar.gz",
+	},
+}
+
+var versionRe = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// Lookup picks the source for an ISO, matching its filename's version
+// against each release and falling back to the distro's default.
+func Lookup(distro, isoFilename string) (Source, bool) {
+	var fallback *Source
+	versions := versionRe.FindAllString(strings.ToLower(isoFilename), -1)
+	for i := range Sources {
+		src := &Sources[i]
+		if src.Distro != distro {
+			continue
+		}
+		if fallback == nil {
+			fallback = src
+		}
+		for _, v := range versions {
+			for _, want := range src.Versions {
+				if v == want || strings.HasPrefix(v, want+".") {
+					return *src, true
+				}
+			}
+		}
+	}
+	if fallback == nil {
+		return Source{}, false
+	}
+	return *fallback, true
+}
+
+// ByURL finds the table entry for a tarball URL, so downloads started from
+// a stored NetbootURL can still be verified.
+func ByURL(url string) (Source, bool) {
+	for _, src := range Sources {
+		if src.URL == url {
+			return src, true
+		}
+	}
+	return Source{}, false
+}
+
+var checksumClient = &http.Client{Timeout: 30 * time.Second}
+
+// ExpectedChecksum fetches the published SHA-256 of the tarball.
+func (s Source) ExpectedChecksum() (string, error) {
+	if s.ChecksumURL == "" {
+		return "", fmt.Errorf("no checksum published for %s %s", s.Distro, s.Release)
+	}
+	resp, err := checksumClient.Get(s.ChecksumURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("checksum file: HTTP %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == s.ChecksumName {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s not listed in %s", s.ChecksumName, s.ChecksumURL)
+}
@@ -14,15 +14,17 @@ import (
 )
 
 type Server struct {
-	rootDir  string
+	rootDirs []string
 	port     int
 	listener net.Listener
 }
 
-func NewServer(rootDir string, port int) *Server {
+// NewServer exports rootDirs; a mount path is looked up in each in turn and
+// the bare root mounts the first.
+func NewServer(rootDirs []string, port int) *Server {
 	return &Server{
-		rootDir: rootDir,
-		port:    port,
+		rootDirs: rootDirs,
+		port:     port,
 	}
 }
 
@@ -34,12 +36,15 @@ func (s *Server) Start() error {
 	}
 
 	s.listener = listener
-	log.Printf("NFS server listening on %s (export root %s)", addr, s.rootDir)
+	log.Printf("NFS server listening on %s (export roots %s)", addr, strings.Join(s.rootDirs, ", "))
 
-	root := osfs.New(s.rootDir)
+	roots := make([]billy.Filesystem, len(s.rootDirs))
+	for i, dir := range s.rootDirs {
+		roots[i] = osfs.New(dir)
+	}
 	handler := &bootHandler{
-		Handler: nfshelper.NewNullAuthHandler(root),
-		root:    root,
+		Handler: nfshelper.NewNullAuthHandler(roots[0]),
+		roots:   roots,
 	}
 	cache := nfshelper.NewCachingHandler(handler, 1024)
 
@@ -55,16 +60,23 @@ func (s *Server) Stop() error {
 
 type bootHandler struct {
 	gonfs.Handler
-	root billy.Filesystem
+	roots []billy.Filesystem
 }
 
 func (h *bootHandler) Mount(ctx context.Context, conn net.Conn, req gonfs.MountRequest) (gonfs.MountStatus, billy.Filesystem, []gonfs.AuthFlavor) {
 	sub := strings.Trim(strings.ReplaceAll(string(req.Dirpath), "\\", "/"), "/")
 	if sub == "" {
-		return gonfs.MountStatusOk, h.root, []gonfs.AuthFlavor{gonfs.AuthFlavorNull}
+		return gonfs.MountStatusOk, h.roots[0], []gonfs.AuthFlavor{gonfs.AuthFlavorNull}
 	}
 
-	fs, err := h.root.Chroot(sub)
+	root := h.roots[0]
+	for _, r := range h.roots {
+		if _, err := r.Stat(sub); err == nil {
+			root = r
+			break
+		}
+	}
+	fs, err := root.Chroot(sub)
 	if err != nil {
 		log.Printf("NFS: mount rejected for %q: %v", sub, err)
 		return gonfs.MountStatusErrNoEnt, nil, nil
@@ -0,0 +1,114 @@
+// Package ntp is a minimal SNTP (RFC 4330) server handing out this host's
+// clock, so machines with a dead CMOS battery get a sane time before they
+// try TLS during an install. It is not a disciplined time source: it
+// reports stratum 10 with reference "LOCL", like an NTP daemon running on
+// its local clock.
+package ntp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPort = 123
+
+	packetLen = 48
+	stratum   = 10
+	// Seconds between the NTP era (1900) and the Unix epoch.
+	ntpEpochOffset = 2208988800
+)
+
+type Server struct {
+	port int
+	now  func() time.Time
+	conn *net.UDPConn
+	wg   sync.WaitGroup
+	done chan struct{}
+}
+
+func NewServer(port int) *Server {
+	if port == 0 {
+		port = DefaultPort
+	}
+	return &Server{port: port, now: time.Now, done: make(chan struct{})}
+}
+
+func (s *Server) Start() error {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: s.port})
+	if err != nil {
+		return fmt.Errorf("listen UDP/%d: %w (needs root or CAP_NET_BIND_SERVICE)", s.port, err)
+	}
+	s.conn = conn
+	log.Printf("NTP: listening on UDP/%d, serving the local clock at stratum %d", s.port, stratum)
+
+	s.wg.Add(1)
+	go s.loop()
+	return nil
+}
+
+func (s *Server) Shutdown() error {
+	close(s.done)
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) loop() {
+	defer s.wg.Done()
+	buf := make([]byte, 512)
+	for {
+		n, src, err := s.conn.ReadFromUDP(buf)
+		received := s.now()
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			log.Printf("NTP: read error: %v", err)
+			continue
+		}
+		if reply := s.Reply(buf[:n], received); reply != nil {
+			s.conn.WriteToUDP(reply, src)
+		}
+	}
+}
+
+// Reply answers a client (mode 3) request received at the given time. Other
+// packets get nil.
+func (s *Server) Reply(req []byte, received time.Time) []byte {
+	if len(req) < packetLen {
+		return nil
+	}
+	version := (req[0] >> 3) & 0x7
+	mode := req[0] & 0x7
+	if mode != 3 || version < 1 || version > 4 {
+		return nil
+	}
+
+	resp := make([]byte, packetLen)
+	resp[0] = version<<3 | 4 // LI 0, same version, mode 4 (server)
+	resp[1] = stratum
+	resp[2] = req[2] // poll
+	resp[3] = 0xec   // precision, about 60ns
+	copy(resp[12:16], "LOCL")
+	putTime(resp[16:24], received.Add(-time.Minute)) // reference time
+	copy(resp[24:32], req[40:48])                    // originate = client's transmit
+	putTime(resp[32:40], received)
+	putTime(resp[40:48], s.now())
+	return resp
+}
+
+func putTime(b []byte, t time.Time) {
+	secs := uint64(t.Unix()) + ntpEpochOffset
+	frac := (uint64(t.Nanosecond()) << 32) / 1e9
+	binary.BigEndian.PutUint32(b[0:4], uint32(secs))
+	binary.BigEndian.PutUint32(b[4:8], uint32(frac))
+}
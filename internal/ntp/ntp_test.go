@@ -0,0 +1,43 @@
+package ntp
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestReply(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 500_000_000, time.UTC)
+	s := NewServer(0)
+	s.now = func() time.Time { return now }
+
+	req := make([]byte, packetLen)
+	req[0] = 4<<3 | 3
+	copy(req[40:48], []byte{1, 2, 3, 4, 5, 6, 7, 8})
+
+	resp := s.Reply(req, now)
+	if len(resp) != packetLen {
+		t.Fatalf("reply length %d", len(resp))
+	}
+	if resp[0] != 4<<3|4 || resp[1] != stratum {
+		t.Errorf("header = %#x stratum %d", resp[0], resp[1])
+	}
+	if string(resp[24:32]) != string(req[40:48]) {
+		t.Error("originate timestamp is not the client's transmit timestamp")
+	}
+	secs := binary.BigEndian.Uint32(resp[40:44])
+	if got := int64(secs) - ntpEpochOffset; got != now.Unix() {
+		t.Errorf("transmit seconds = %d, want %d", got, now.Unix())
+	}
+	if frac := binary.BigEndian.Uint32(resp[44:48]); frac != 1<<31 {
+		t.Errorf("transmit fraction = %#x, want half a second", frac)
+	}
+
+	req[0] = 4<<3 | 4
+	if s.Reply(req, now) != nil {
+		t.Error("answered a server-mode packet")
+	}
+	if s.Reply(req[:20], now) != nil {
+		t.Error("answered a short packet")
+	}
+}
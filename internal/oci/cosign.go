@@ -0,0 +1,120 @@
+package oci
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// ParsePublicKey reads a PEM-encoded public key as written by
+// "cosign generate-key-pair" (ECDSA), or an RSA or Ed25519 key.
+func ParsePublicKey(pemData string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(pemData)))
+	if block == nil {
+		return nil, errors.New("no PEM block found in public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", key)
+}
+
+// VerifyCosign checks that digest carries at least one key-based cosign
+// signature made by key. It understands the classic layout where signatures
+// are stored under the "sha256-<hex>.sig" tag of the same repository.
+func (c *Client) VerifyCosign(ref Reference, digest string, key crypto.PublicKey) error {
+	if !digestRe.MatchString(digest) {
+		return fmt.Errorf("invalid digest %q", digest)
+	}
+	sigRef := ref
+	sigRef.Digest = ""
+	sigRef.Tag = strings.Replace(digest, ":", "-", 1) + ".sig"
+
+	m, _, err := c.Resolve(sigRef)
+	if err != nil {
+		return fmt.Errorf("no cosign signature found: %w", err)
+	}
+
+	var lastErr error = errors.New("signature manifest has no signatures")
+	for _, layer := range m.Layers {
+		sig64 := layer.Annotations[cosignSignatureAnnotation]
+		if sig64 == "" || layer.Size > 1<<20 {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(sig64)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var payload bytes.Buffer
+		if err := c.FetchBlob(sigRef, layer, &payload, nil); err != nil {
+			lastErr = err
+			continue
+		}
+		if err := verifySignature(key, payload.Bytes(), sig); err != nil {
+			lastErr = err
+			continue
+		}
+		if err := checkSimpleSigning(payload.Bytes(), digest); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("cosign verification failed: %w", lastErr)
+}
+
+func verifySignature(key crypto.PublicKey, payload, sig []byte) error {
+	sum := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(k, sum[:], sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(k, payload, sig) {
+			return nil
+		}
+	}
+	return errors.New("signature does not match key")
+}
+
+// checkSimpleSigning makes sure the signed payload is about this manifest,
+// so a valid signature for another image can't be replayed.
+func checkSimpleSigning(payload []byte, digest string) error {
+	var doc struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return fmt.Errorf("signature payload: %w", err)
+	}
+	if got := doc.Critical.Image.DockerManifestDigest; got != digest {
+		return fmt.Errorf("signature is for %s, not %s", got, digest)
+	}
+	return nil
+}
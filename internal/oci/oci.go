@@ -0,0 +1,370 @@
+// Package oci is a small, pull-only client for OCI distribution registries,
+// enough to fetch boot artifacts published as OCI images or artifacts.
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	// AnnotationTitle names a layer's file, as set by oras and similar tools.
+	AnnotationTitle = "org.opencontainers.image.title"
+)
+
+var acceptManifests = strings.Join([]string{MediaTypeOCIManifest, MediaTypeOCIIndex, MediaTypeDockerManifest, MediaTypeDockerList}, ", ")
+
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+var digestRe = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// ParseReference accepts the usual forms: "ubuntu:24.04",
+// "ghcr.io/org/boot/ubuntu:24.04" and "registry:5000/repo@sha256:...".
+// Docker Hub names get the same defaults the docker CLI applies.
+func ParseReference(ref string) (Reference, error) {
+	var r Reference
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.ContainsAny(ref, " \t\r\n") {
+		return r, fmt.Errorf("invalid reference %q", ref)
+	}
+	if i := strings.Index(ref, "@"); i >= 0 {
+		r.Digest = ref[i+1:]
+		ref = ref[:i]
+		if !digestRe.MatchString(r.Digest) {
+			return r, fmt.Errorf("invalid digest %q", r.Digest)
+		}
+	}
+	if i := strings.LastIndex(ref, ":"); i >= 0 && !strings.Contains(ref[i:], "/") {
+		r.Tag = ref[i+1:]
+		ref = ref[:i]
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+
+	if i := strings.Index(ref, "/"); i >= 0 && (strings.ContainsAny(ref[:i], ".:") || ref[:i] == "localhost") {
+		r.Registry, r.Repository = ref[:i], ref[i+1:]
+	} else {
+		r.Registry, r.Repository = "docker.io", ref
+	}
+	if r.Registry == "docker.io" {
+		r.Registry = "registry-1.docker.io"
+		if !strings.Contains(r.Repository, "/") {
+			r.Repository = "library/" + r.Repository
+		}
+	}
+	if r.Repository == "" {
+		return r, fmt.Errorf("invalid reference %q: missing repository", ref)
+	}
+	return r, nil
+}
+
+// Reference returns the tag or digest to ask the registry for.
+func (r Reference) Reference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+}
+
+// Title is the file name the publisher gave the layer, if any.
+func (d Descriptor) Title() string {
+	return d.Annotations[AnnotationTitle]
+}
+
+type Manifest struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Config       Descriptor        `json:"config"`
+	Layers       []Descriptor      `json:"layers"`
+	Manifests    []Descriptor      `json:"manifests,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// Client pulls from registries anonymously or with basic credentials,
+// exchanging them for bearer tokens when the registry asks for that.
+type Client struct {
+	HTTP     *http.Client
+	Username string
+	Password string
+	Arch     string // preferred architecture when a tag is multi-platform
+
+	mu     sync.Mutex
+	tokens map[string]string // "registry scope" -> bearer token
+}
+
+func NewClient(username, password string) *Client {
+	return &Client{
+		HTTP:     &http.Client{Timeout: 0},
+		Username: username,
+		Password: password,
+		Arch:     "amd64",
+		tokens:   make(map[string]string),
+	}
+}
+
+func (c *Client) do(ref Reference, method, path, accept string) (*http.Response, error) {
+	u := fmt.Sprintf("https://%s/v2/%s/%s", ref.Registry, ref.Repository, path)
+	if strings.HasPrefix(ref.Registry, "localhost") || strings.HasPrefix(ref.Registry, "127.0.0.1") {
+		u = "http" + strings.TrimPrefix(u, "https")
+	}
+	scope := "repository:" + ref.Repository + ":pull"
+
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequest(method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		c.mu.Lock()
+		token := c.tokens[ref.Registry+" "+scope]
+		c.mu.Unlock()
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else if c.Username != "" {
+			req.SetBasicAuth(c.Username, c.Password)
+		}
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+			return nil, fmt.Errorf("registry %s: unauthorized", ref.Registry)
+		}
+		token, err = c.fetchToken(challenge, scope)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.tokens[ref.Registry+" "+scope] = token
+		c.mu.Unlock()
+	}
+	return nil, errors.New("unreachable")
+}
+
+var challengeParamRe = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func (c *Client) fetchToken(challenge, scope string) (string, error) {
+	params := map[string]string{}
+	for _, m := range challengeParamRe.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || (realm.Scheme != "https" && realm.Scheme != "http") {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	q := realm.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		scope = params["scope"]
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	tokenClient := &http.Client{Timeout: 30 * time.Second}
+	resp, err := tokenClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint: HTTP %d", resp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", errors.New("token endpoint returned no token")
+}
+
+// Resolve fetches the manifest ref points at, stepping through an index to
+// the entry for c.Arch, and returns it with its digest.
+func (c *Client) Resolve(ref Reference) (*Manifest, string, error) {
+	m, digest, err := c.getManifest(ref, ref.Reference())
+	if err != nil {
+		return nil, "", err
+	}
+	if m.MediaType != MediaTypeOCIIndex && m.MediaType != MediaTypeDockerList {
+		return m, digest, nil
+	}
+
+	var pick *Descriptor
+	for i := range m.Manifests {
+		d := &m.Manifests[i]
+		if d.Platform == nil {
+			continue
+		}
+		if d.Platform.Architecture == c.Arch && (d.Platform.OS == "linux" || d.Platform.OS == "") {
+			pick = d
+			break
+		}
+	}
+	if pick == nil && len(m.Manifests) == 1 {
+		pick = &m.Manifests[0]
+	}
+	if pick == nil {
+		return nil, "", fmt.Errorf("%s has no %s manifest", ref, c.Arch)
+	}
+	return c.getManifest(ref, pick.Digest)
+}
+
+// Head returns the digest a tag currently points to without downloading
+// the manifest body, for cheap tag tracking.
+func (c *Client) Head(ref Reference) (string, error) {
+	resp, err := c.do(ref, http.MethodHead, "manifests/"+ref.Reference(), acceptManifests)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("manifest %s: HTTP %d", ref, resp.StatusCode)
+	}
+	if d := resp.Header.Get("Docker-Content-Digest"); d != "" {
+		return d, nil
+	}
+	_, digest, err := c.getManifest(ref, ref.Reference())
+	return digest, err
+}
+
+func (c *Client) getManifest(ref Reference, reference string) (*Manifest, string, error) {
+	resp, err := c.do(ref, http.MethodGet, "manifests/"+reference, acceptManifests)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("manifest %s: HTTP %d", ref, resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(raw)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if digestRe.MatchString(reference) && reference != digest {
+		return nil, "", fmt.Errorf("manifest digest mismatch: got %s, want %s", digest, reference)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, "", fmt.Errorf("manifest %s: %w", ref, err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+	return &m, digest, nil
+}
+
+// FetchBlob streams a blob into w, checking it against its digest. progress,
+// if set, is called with the running byte count.
+func (c *Client) FetchBlob(ref Reference, desc Descriptor, w io.Writer, progress func(int64)) error {
+	if !digestRe.MatchString(desc.Digest) {
+		return fmt.Errorf("unsupported digest %q", desc.Digest)
+	}
+	resp, err := c.do(ref, http.MethodGet, "blobs/"+desc.Digest, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("blob %s: HTTP %d", desc.Digest, resp.StatusCode)
+	}
+
+	h := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(w, h), progress: progress}
+	if _, err := io.Copy(cw, resp.Body); err != nil {
+		return err
+	}
+	if desc.Size > 0 && cw.n != desc.Size {
+		return fmt.Errorf("blob %s: got %d bytes, want %d", desc.Digest, cw.n, desc.Size)
+	}
+	if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != desc.Digest {
+		return fmt.Errorf("blob digest mismatch: got %s, want %s", got, desc.Digest)
+	}
+	return nil
+}
+
+type countingWriter struct {
+	w        io.Writer
+	n        int64
+	progress func(int64)
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	if cw.progress != nil {
+		cw.progress(cw.n)
+	}
+	return n, err
+}
@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// File is a policy kept in a file, reloaded when the file changes. A
+// missing file is an empty policy.
+type File struct {
+	path string
+
+	mu     sync.Mutex
+	mod    time.Time
+	size   int64
+	policy *Policy
+	err    error
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Path() string { return f.path }
+
+// Policy returns the current policy. If the file no longer parses, the
+// last good policy stays in force and the error is returned with it.
+func (f *File) Policy() (*Policy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		f.policy, f.err, f.mod, f.size = nil, nil, time.Time{}, 0
+		return nil, nil
+	}
+	if err != nil {
+		return f.policy, err
+	}
+	if info.ModTime().Equal(f.mod) && info.Size() == f.size {
+		return f.policy, f.err
+	}
+	f.mod, f.size = info.ModTime(), info.Size()
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		f.err = err
+		return f.policy, err
+	}
+	p, err := Parse(string(data))
+	if err == nil {
+		f.policy = p
+	}
+	f.err = err
+	return f.policy, err
+}
+
+// Source returns the file's contents, empty if there is no file.
+func (f *File) Source() (string, error) {
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// Save replaces the policy with src after checking that it parses.
+func (f *File) Save(src string) error {
+	if _, err := Parse(src); err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(src), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
@@ -0,0 +1,482 @@
+// Package policy evaluates boot policy: rules, one per line, that decide per
+// menu request which images a client is offered, whether it boots one
+// straight away and which kernel arguments it gets.
+//
+//	# Lab machines only see lab images, and only during working hours.
+//	when tag lab then allow lab-*
+//	when tag lab and not time 08:00-18:00 then deny *
+//	when ip 10.0.5.0/24 and arch arm64 then deny *x86_64*
+//	when mac 52:54:00:* then args "console=ttyS0,115200"
+//	when group kiosks and day mon-fri then force kiosk.iso, stop
+//
+// Conditions (each may be prefixed with "not"; values are comma-separated
+// alternatives):
+//
+//	mac <glob>          client MAC, e.g. 52:54:00:*
+//	ip <addr or cidr>   client IP
+//	time <HH:MM-HH:MM>  server local time, may wrap past midnight
+//	day <days>          mon..sun, or a range such as mon-fri
+//	tag <tag>           client tag
+//	group <name>        client group
+//	arch <arch>         iPXE build architecture: x86_64, i386, arm64, arm32
+//	firmware <fw>       efi or pcbios
+//	any                 always matches
+//
+// Actions:
+//
+//	allow <globs>       offer only images matching one of the allow globs
+//	deny <globs>        never offer matching images
+//	force <filename>    boot the image without showing the menu
+//	args "<text>"       append kernel arguments to every image
+//	stop                skip the rules after this one
+//
+// Globs match an image's filename or display name, ignoring case. Every
+// matching rule applies, in order; the first force wins.
+package policy
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Input struct {
+	MAC      string    `json:"mac"`
+	IP       string    `json:"ip,omitempty"`
+	Time     time.Time `json:"time"`
+	Tags     []string  `json:"tags,omitempty"`
+	Group    string    `json:"group,omitempty"`
+	Arch     string    `json:"arch,omitempty"`
+	Firmware string    `json:"firmware,omitempty"`
+}
+
+type Decision struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+	Force string   `json:"force,omitempty"`
+	Args  []string `json:"args,omitempty"`
+	Trace []string `json:"trace"`
+}
+
+// Permits reports whether the image may be offered. A nil Decision permits
+// everything.
+func (d *Decision) Permits(filename, name string) bool {
+	if d == nil {
+		return true
+	}
+	if len(d.Allow) > 0 && !matchAny(d.Allow, filename, name) {
+		return false
+	}
+	return !matchAny(d.Deny, filename, name)
+}
+
+func matchAny(globs []string, filename, name string) bool {
+	for _, g := range globs {
+		for _, s := range []string{filename, name} {
+			if ok, _ := path.Match(strings.ToLower(g), strings.ToLower(s)); ok && s != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type Policy struct {
+	Rules []Rule
+}
+
+type Rule struct {
+	Line    int
+	Text    string
+	Conds   []Cond
+	Actions []Action
+}
+
+type Cond struct {
+	Kind   string
+	Not    bool
+	Values []string
+}
+
+type Action struct {
+	Kind  string
+	Value []string
+}
+
+var condKinds = map[string]bool{"mac": true, "ip": true, "time": true, "day": true, "tag": true,
+	"group": true, "arch": true, "firmware": true, "any": true}
+
+var days = map[string]time.Weekday{"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday,
+	"wed": time.Wednesday, "thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday}
+
+// Parse reads a policy, reporting the first malformed rule by line.
+func Parse(src string) (*Policy, error) {
+	p := &Policy{}
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		r.Line = i + 1
+		p.Rules = append(p.Rules, r)
+	}
+	return p, nil
+}
+
+func parseRule(line string) (Rule, error) {
+	r := Rule{Text: line}
+	toks, err := tokenize(line)
+	if err != nil {
+		return r, err
+	}
+	if len(toks) == 0 || toks[0] != "when" {
+		return r, fmt.Errorf("rules start with \"when\"")
+	}
+	then := slices.Index(toks, "then")
+	if then < 0 {
+		return r, fmt.Errorf("missing \"then\"")
+	}
+
+	conds := toks[1:then]
+	if len(conds) == 0 {
+		return r, fmt.Errorf("no conditions (use \"any\" to match every client)")
+	}
+	for len(conds) > 0 {
+		c := Cond{}
+		if conds[0] == "not" {
+			c.Not = true
+			conds = conds[1:]
+		}
+		if len(conds) == 0 || !condKinds[conds[0]] {
+			return r, fmt.Errorf("unknown condition %q", strings.Join(conds, " "))
+		}
+		c.Kind = conds[0]
+		conds = conds[1:]
+		if c.Kind != "any" {
+			if len(conds) == 0 || conds[0] == "and" {
+				return r, fmt.Errorf("%s needs a value", c.Kind)
+			}
+			c.Values = splitList(conds[0])
+			if err := checkCond(c); err != nil {
+				return r, err
+			}
+			conds = conds[1:]
+		}
+		r.Conds = append(r.Conds, c)
+		if len(conds) > 0 {
+			if conds[0] != "and" || len(conds) == 1 {
+				return r, fmt.Errorf("conditions are joined with \"and\"")
+			}
+			conds = conds[1:]
+		}
+	}
+
+	var actions [][]string
+	cur := []string{}
+	for _, t := range toks[then+1:] {
+		if t == "," {
+			actions = append(actions, cur)
+			cur = []string{}
+			continue
+		}
+		cur = append(cur, t)
+	}
+	actions = append(actions, cur)
+	for _, a := range actions {
+		if len(a) == 0 {
+			return r, fmt.Errorf("empty action")
+		}
+		act := Action{Kind: a[0], Value: a[1:]}
+		switch act.Kind {
+		case "stop":
+			if len(act.Value) != 0 {
+				return r, fmt.Errorf("stop takes no value")
+			}
+		case "allow", "deny":
+			if len(act.Value) != 1 {
+				return r, fmt.Errorf("%s takes one comma-separated list of globs", act.Kind)
+			}
+			act.Value = splitList(act.Value[0])
+			for _, g := range act.Value {
+				if _, err := path.Match(g, ""); err != nil {
+					return r, fmt.Errorf("bad glob %q", g)
+				}
+			}
+		case "force", "args":
+			if len(act.Value) != 1 {
+				return r, fmt.Errorf("%s takes one value (quote arguments with spaces)", act.Kind)
+			}
+		default:
+			return r, fmt.Errorf("unknown action %q", act.Kind)
+		}
+		r.Actions = append(r.Actions, act)
+	}
+	return r, nil
+}
+
+// tokenize splits on spaces, keeping "quoted strings" whole and making
+// commas between actions separate tokens.
+func tokenize(line string) ([]string, error) {
+	var toks []string
+	var cur strings.Builder
+	inQuote, quoted := false, false
+	flush := func() {
+		if cur.Len() > 0 || quoted {
+			toks = append(toks, cur.String())
+		}
+		cur.Reset()
+		quoted = false
+	}
+	for _, ch := range line {
+		switch {
+		case ch == '"':
+			inQuote = !inQuote
+			quoted = true
+		case inQuote:
+			cur.WriteRune(ch)
+		case ch == ' ' || ch == '\t':
+			flush()
+		case ch == ',' && cur.Len() == 0 && !quoted:
+			toks = append(toks, ",")
+		case ch == ',':
+			cur.WriteRune(ch)
+		default:
+			cur.WriteRune(ch)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	flush()
+	// "deny a,b, stop": the comma ending a value list separates actions.
+	var out []string
+	for _, t := range toks {
+		if len(t) > 1 && strings.HasSuffix(t, ",") {
+			out = append(out, strings.TrimSuffix(t, ","), ",")
+			continue
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func checkCond(c Cond) error {
+	for _, v := range c.Values {
+		var err error
+		switch c.Kind {
+		case "ip":
+			_, err = parsePrefix(v)
+		case "time":
+			_, _, err = parseClock(v)
+		case "day":
+			_, err = parseDays(v)
+		case "mac":
+			_, err = path.Match(v, "")
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", c.Kind, v, err)
+		}
+	}
+	return nil
+}
+
+func parsePrefix(v string) (netip.Prefix, error) {
+	if strings.Contains(v, "/") {
+		return netip.ParsePrefix(v)
+	}
+	addr, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// parseClock reads HH:MM-HH:MM as minutes since midnight.
+func parseClock(v string) (int, int, error) {
+	from, to, ok := strings.Cut(v, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("want HH:MM-HH:MM")
+	}
+	minutes := func(s string) (int, error) {
+		t, err := time.Parse("15:04", s)
+		if err != nil {
+			return 0, fmt.Errorf("want HH:MM-HH:MM")
+		}
+		return t.Hour()*60 + t.Minute(), nil
+	}
+	a, err := minutes(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := minutes(to)
+	return a, b, err
+}
+
+func parseDays(v string) ([]time.Weekday, error) {
+	from, to, isRange := strings.Cut(strings.ToLower(v), "-")
+	a, ok := days[from]
+	if !ok {
+		return nil, fmt.Errorf("unknown day %q", from)
+	}
+	if !isRange {
+		return []time.Weekday{a}, nil
+	}
+	b, ok := days[to]
+	if !ok {
+		return nil, fmt.Errorf("unknown day %q", to)
+	}
+	var out []time.Weekday
+	for d := a; ; d = (d + 1) % 7 {
+		out = append(out, d)
+		if d == b {
+			return out, nil
+		}
+	}
+}
+
+// Evaluate applies the rules matching in, recording in the trace why each
+// rule did or did not apply. A nil Policy decides nothing.
+func (p *Policy) Evaluate(in Input) *Decision {
+	d := &Decision{Trace: []string{}}
+	if p == nil {
+		return d
+	}
+	for _, r := range p.Rules {
+		if why, ok := r.matches(in); !ok {
+			d.Trace = append(d.Trace, fmt.Sprintf("line %d: skipped, %s", r.Line, why))
+			continue
+		}
+		d.Trace = append(d.Trace, fmt.Sprintf("line %d: matched: %s", r.Line, r.Text))
+		stop := false
+		for _, a := range r.Actions {
+			switch a.Kind {
+			case "allow":
+				d.Allow = append(d.Allow, a.Value...)
+			case "deny":
+				d.Deny = append(d.Deny, a.Value...)
+			case "force":
+				if d.Force == "" {
+					d.Force = a.Value[0]
+				}
+			case "args":
+				d.Args = append(d.Args, a.Value[0])
+			case "stop":
+				stop = true
+			}
+		}
+		if stop {
+			d.Trace = append(d.Trace, fmt.Sprintf("line %d: stop", r.Line))
+			break
+		}
+	}
+	return d
+}
+
+// matches reports whether all of r's conditions hold, or the first that
+// does not.
+func (r Rule) matches(in Input) (string, bool) {
+	for _, c := range r.Conds {
+		hit, actual := c.eval(in)
+		if hit == c.Not {
+			neg := ""
+			if c.Not {
+				neg = "not "
+			}
+			return fmt.Sprintf("%s is %s, wanted %s%s %s", c.Kind, actual, neg, c.Kind, strings.Join(c.Values, ",")), false
+		}
+	}
+	return "", true
+}
+
+// eval reports whether c's condition (ignoring Not) holds, and the input
+// value it looked at for the trace.
+func (c Cond) eval(in Input) (bool, string) {
+	quote := func(s string) string {
+		if s == "" {
+			return "unknown"
+		}
+		return strconv.Quote(s)
+	}
+	switch c.Kind {
+	case "any":
+		return true, "any"
+	case "mac":
+		for _, v := range c.Values {
+			if ok, _ := path.Match(strings.ToLower(v), strings.ToLower(in.MAC)); ok {
+				return true, quote(in.MAC)
+			}
+		}
+		return false, quote(in.MAC)
+	case "ip":
+		addr, err := netip.ParseAddr(in.IP)
+		if err != nil {
+			return false, quote(in.IP)
+		}
+		for _, v := range c.Values {
+			if p, err := parsePrefix(v); err == nil && p.Contains(addr.Unmap()) {
+				return true, quote(in.IP)
+			}
+		}
+		return false, quote(in.IP)
+	case "time":
+		now := in.Time.Hour()*60 + in.Time.Minute()
+		for _, v := range c.Values {
+			a, b, _ := parseClock(v)
+			if (a <= b && now >= a && now < b) || (a > b && (now >= a || now < b)) {
+				return true, in.Time.Format("15:04")
+			}
+		}
+		return false, in.Time.Format("15:04")
+	case "day":
+		for _, v := range c.Values {
+			ds, _ := parseDays(v)
+			if slices.Contains(ds, in.Time.Weekday()) {
+				return true, in.Time.Weekday().String()
+			}
+		}
+		return false, in.Time.Weekday().String()
+	case "tag":
+		for _, v := range c.Values {
+			for _, t := range in.Tags {
+				if strings.EqualFold(v, t) {
+					return true, quote(strings.Join(in.Tags, ","))
+				}
+			}
+		}
+		return false, quote(strings.Join(in.Tags, ","))
+	case "group":
+		return containsFold(c.Values, in.Group), quote(in.Group)
+	case "arch":
+		return containsFold(c.Values, in.Arch), quote(in.Arch)
+	case "firmware":
+		return containsFold(c.Values, in.Firmware), quote(in.Firmware)
+	}
+	return false, "unknown"
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if s != "" && strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
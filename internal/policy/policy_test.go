@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const example = `
+# comment
+when tag lab then allow lab-*
+when tag lab and not time 08:00-18:00 then deny *
+when ip 10.0.5.0/24 and arch arm64 then deny *x86_64*, args "console=ttyS0,115200"
+when group kiosks and day mon-fri then force kiosk.iso, stop
+when any then args quiet
+`
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"allow *",
+		"when tag lab allow *",
+		"when then deny *",
+		"when tag then deny *",
+		"when colour blue then deny *",
+		"when tag lab or tag x then deny *",
+		"when time 8-18 then deny *",
+		"when day funday then deny *",
+		"when ip 10.0.0.0/33 then deny *",
+		"when any then explode",
+		"when any then force a b",
+		`when any then args "unterminated`,
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("%q parsed", src)
+		}
+	}
+	if _, err := Parse(example); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	p, err := Parse(example)
+	if err != nil {
+		t.Fatal(err)
+	}
+	monday9am := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+
+	d := p.Evaluate(Input{Tags: []string{"LAB"}, Time: monday9am})
+	if !reflect.DeepEqual(d.Allow, []string{"lab-*"}) || d.Deny != nil {
+		t.Errorf("lab by day: %+v", d)
+	}
+	if !d.Permits("lab-ubuntu.iso", "") || d.Permits("windows.iso", "Windows") {
+		t.Error("allow list not applied")
+	}
+	if d = p.Evaluate(Input{Tags: []string{"lab"}, Time: monday9am.Add(12 * time.Hour)}); d.Permits("lab-ubuntu.iso", "") {
+		t.Error("lab at night not denied")
+	}
+
+	d = p.Evaluate(Input{IP: "10.0.5.7", Arch: "arm64", Time: monday9am})
+	if d.Permits("ubuntu-24.04-x86_64.iso", "") || !d.Permits("ubuntu-24.04-aarch64.iso", "") {
+		t.Error("arch deny not applied")
+	}
+	if !reflect.DeepEqual(d.Args, []string{"console=ttyS0,115200", "quiet"}) {
+		t.Errorf("args = %q", d.Args)
+	}
+
+	d = p.Evaluate(Input{Group: "Kiosks", Time: monday9am})
+	if d.Force != "kiosk.iso" || len(d.Args) != 0 {
+		t.Errorf("force/stop: %+v", d)
+	}
+	if last := d.Trace[len(d.Trace)-1]; !strings.Contains(last, "stop") {
+		t.Errorf("trace ends %q", last)
+	}
+	if d := p.Evaluate(Input{Group: "kiosks", Time: monday9am.AddDate(0, 0, 5)}); d.Force != "" {
+		t.Error("forced on a Saturday")
+	}
+	if !strings.Contains(d.Trace[0], `tag is unknown, wanted tag lab`) {
+		t.Errorf("trace[0] = %q", d.Trace[0])
+	}
+}
+
+func TestNilPolicy(t *testing.T) {
+	var p *Policy
+	d := p.Evaluate(Input{})
+	if !d.Permits("a.iso", "A") || d.Force != "" {
+		t.Error("nil policy decided something")
+	}
+	var nd *Decision
+	if !nd.Permits("a.iso", "") {
+		t.Error("nil decision denied")
+	}
+}
+
+func TestFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.rules")
+	f := NewFile(path)
+	if p, err := f.Policy(); p != nil || err != nil {
+		t.Fatalf("missing file: %v %v", p, err)
+	}
+	if err := f.Save("when any then bogus"); err == nil {
+		t.Fatal("saved an invalid policy")
+	}
+	if err := f.Save("when any then deny *"); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := f.Policy(); p == nil || len(p.Rules) != 1 {
+		t.Fatalf("policy = %+v", p)
+	}
+
+	// A broken edit on disk keeps the last good policy.
+	os.WriteFile(path, []byte("when nonsense"), 0644)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Minute))
+	p, err := f.Policy()
+	if err == nil || p == nil || len(p.Rules) != 1 {
+		t.Errorf("broken file: %+v %v", p, err)
+	}
+}
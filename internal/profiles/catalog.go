@@ -0,0 +1,207 @@
+package profiles
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CatalogSource is a JSON file in the ISOCatalog format, listing further
+// distros alongside the built-in ones.
+type CatalogSource struct {
+	Name string `mapstructure:"name" json:"name"`
+	URL  string `mapstructure:"url" json:"url"`
+}
+
+// Catalog is the ISO catalog as of its last refresh. Until the first one it
+// is the catalog built into the binary.
+type Catalog struct {
+	sources []CatalogSource
+	// DisableRemoteCheck keeps refreshes from fetching the built-in
+	// catalog's latest version from RemoteProfilesURL.
+	DisableRemoteCheck bool
+
+	client *http.Client
+	mu     sync.RWMutex
+	cur    *ISOCatalog
+}
+
+func NewCatalog(sources []CatalogSource) (*Catalog, error) {
+	names := map[string]bool{}
+	for _, src := range sources {
+		if src.Name == "" || names[src.Name] {
+			return nil, fmt.Errorf("catalog source %q needs a unique name", src.URL)
+		}
+		names[src.Name] = true
+		if u, err := url.Parse(src.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("catalog source %s: %q is not an http(s) URL", src.Name, src.URL)
+		}
+	}
+	cur, err := LoadISOCatalog()
+	if err != nil {
+		return nil, err
+	}
+	return &Catalog{
+		sources: sources,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		cur:     cur,
+	}, nil
+}
+
+func (c *Catalog) Get() *ISOCatalog {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cur
+}
+
+// Refresh rebuilds the catalog from the latest built-in catalog, the custom
+// sources and the checksum files of every release. A source that can't be
+// fetched is left out and reported in Errors.
+func (c *Catalog) Refresh(ctx context.Context) *ISOCatalog {
+	var errs []string
+	var next *ISOCatalog
+	if !c.DisableRemoteCheck {
+		data, err := c.fetch(ctx, RemoteProfilesURL)
+		if err == nil {
+			next, err = parseISOCatalog(data)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("built-in catalog: %v", err))
+		}
+	}
+	if next == nil {
+		var err error
+		if next, err = LoadISOCatalog(); err != nil {
+			errs = append(errs, err.Error())
+			next = &ISOCatalog{}
+		}
+	}
+
+	for _, src := range c.sources {
+		var custom ISOCatalog
+		data, err := c.fetch(ctx, src.URL)
+		if err == nil {
+			err = json.Unmarshal(data, &custom)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", src.Name, err))
+			continue
+		}
+		custom.fillURLs()
+		for _, d := range custom.Distros {
+			d.Source = src.Name
+			next.Distros = append(next.Distros, d)
+		}
+	}
+
+	errs = append(errs, c.resolveChecksums(ctx, next)...)
+
+	now := time.Now()
+	next.RefreshedAt = &now
+	next.Errors = errs
+	c.mu.Lock()
+	c.cur = next
+	c.mu.Unlock()
+	log.Printf("Catalog: Refreshed %d distros (%d errors)", len(next.Distros), len(errs))
+	return next
+}
+
+// Run refreshes the catalog every interval until ctx is done.
+func (c *Catalog) Run(ctx context.Context, interval time.Duration) {
+	c.Refresh(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Refresh(ctx)
+		}
+	}
+}
+
+// resolveChecksums fills in the SHA-256 of each release that names a
+// checksum file, fetching each file once.
+func (c *Catalog) resolveChecksums(ctx context.Context, cat *ISOCatalog) []string {
+	var errs []string
+	files := map[string]map[string]string{}
+	for i := range cat.Distros {
+		d := &cat.Distros[i]
+		for j := range d.Releases {
+			rel := &d.Releases[j]
+			if rel.Checksums == "" || rel.SHA256 != "" {
+				continue
+			}
+			sumsURL := rel.Checksums
+			if !strings.Contains(sumsURL, "://") {
+				if len(d.Mirrors) == 0 {
+					continue
+				}
+				sumsURL = strings.TrimRight(d.Mirrors[0].Base, "/") + sumsURL
+			}
+			sums, ok := files[sumsURL]
+			if !ok {
+				data, err := c.fetch(ctx, sumsURL)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("%s checksums: %v", d.Name, err))
+				}
+				sums = parseChecksums(string(data))
+				files[sumsURL] = sums
+			}
+			rel.SHA256 = sums[path.Base(rel.Path)]
+		}
+	}
+	return errs
+}
+
+// parseChecksums reads the SHA-256 sums, by file name, from a sha256sum
+// style file ("<hex>  name" or "<hex> *name") or a BSD style one
+// ("SHA256 (name) = <hex>"). Other lines, such as PGP armour, are skipped.
+func parseChecksums(data string) map[string]string {
+	sums := map[string]string{}
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		var sum, name string
+		if rest, ok := strings.CutPrefix(line, "SHA256 ("); ok {
+			var found bool
+			if name, sum, found = strings.Cut(rest, ") = "); !found {
+				continue
+			}
+		} else if fields := strings.Fields(line); len(fields) == 2 {
+			sum, name = fields[0], strings.TrimPrefix(fields[1], "*")
+		}
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != 32 {
+			continue
+		}
+		sums[path.Base(name)] = strings.ToLower(sum)
+	}
+	return sums
+}
+
+func (c *Catalog) fetch(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned HTTP %d", u, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+}
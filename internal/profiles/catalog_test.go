@@ -0,0 +1,22 @@
+package profiles
+
+import "testing"
+
+func TestParseChecksums(t *testing.T) {
+	const sum = "3f1a5e7c2b9d8e6f4a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f"
+	data := "-----BEGIN PGP SIGNED MESSAGE-----\n" +
+		sum + "  debian-13.5.0-amd64-netinst.iso\n" +
+		sum + " *ubuntu-26.04-live-server-amd64.iso\n" +
+		"SHA256 (Rocky-9-latest-x86_64-dvd.iso) = " + sum + "\n" +
+		"# Rocky-9-latest-x86_64-boot.iso: 1234 bytes\n" +
+		"deadbeef  short.iso\n"
+	got := parseChecksums(data)
+	for _, name := range []string{"debian-13.5.0-amd64-netinst.iso", "ubuntu-26.04-live-server-amd64.iso", "Rocky-9-latest-x86_64-dvd.iso"} {
+		if got[name] != sum {
+			t.Errorf("sum of %s = %q, want %q", name, got[name], sum)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("parsed %d sums, want 3: %v", len(got), got)
+	}
+}
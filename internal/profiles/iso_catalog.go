@@ -3,11 +3,18 @@ package profiles
 import (
 	"encoding/json"
 	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"bootimus/internal/models"
 )
 
 type ISOCatalog struct {
-	Version string     `json:"version"`
-	Distros []ISOEntry `json:"distros"`
+	Version     string     `json:"version"`
+	Distros     []ISOEntry `json:"distros"`
+	RefreshedAt *time.Time `json:"refreshed_at,omitempty"`
+	Errors      []string   `json:"errors,omitempty"` // from the last refresh
 }
 
 type ISOEntry struct {
@@ -15,6 +22,7 @@ type ISOEntry struct {
 	Name     string       `json:"name"`
 	Mirrors  []ISOMirror  `json:"mirrors"`
 	Releases []ISORelease `json:"releases"`
+	Source   string       `json:"source,omitempty"` // custom catalog source, empty for built-in entries
 }
 
 type ISOMirror struct {
@@ -26,6 +34,13 @@ type ISORelease struct {
 	Label    string `json:"label"`
 	Path     string `json:"path"`
 	SizeHint string `json:"size_hint,omitempty"`
+	// Checksums is the checksum file listing this ISO: a URL, or a path
+	// relative to the first mirror like Path.
+	Checksums string `json:"checksums,omitempty"`
+	// URL is the ISO on the first mirror, or where it is for a release
+	// from a custom source that has no mirrors.
+	URL    string `json:"url,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
 }
 
 func LoadISOCatalog() (*ISOCatalog, error) {
@@ -33,6 +48,10 @@ func LoadISOCatalog() (*ISOCatalog, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read distro-profiles: %w", err)
 	}
+	return parseISOCatalog(data)
+}
+
+func parseISOCatalog(data []byte) (*ISOCatalog, error) {
 	var pf ProfileFile
 	if err := json.Unmarshal(data, &pf); err != nil {
 		return nil, fmt.Errorf("parse distro-profiles: %w", err)
@@ -49,5 +68,50 @@ func LoadISOCatalog() (*ISOCatalog, error) {
 			Releases: p.Releases,
 		})
 	}
-	return &ISOCatalog{Version: pf.Version, Distros: distros}, nil
+	c := &ISOCatalog{Version: pf.Version, Distros: distros}
+	c.fillURLs()
+	return c, nil
+}
+
+func (c *ISOCatalog) fillURLs() {
+	for i := range c.Distros {
+		d := &c.Distros[i]
+		if len(d.Mirrors) == 0 {
+			continue
+		}
+		for j := range d.Releases {
+			if d.Releases[j].URL == "" {
+				d.Releases[j].URL = strings.TrimRight(d.Mirrors[0].Base, "/") + d.Releases[j].Path
+			}
+		}
+	}
+}
+
+// NewerRelease finds a catalog release of the same distro, variant and
+// architecture as img with a higher version, or nil if img is current.
+func (c *ISOCatalog) NewerRelease(img *models.Image) *ISORelease {
+	have := models.ParseRelease(img.Filename)
+	if img.Distro == "" || have.Version == "" {
+		return nil
+	}
+	var newest *ISORelease
+	newestVersion := have.Version
+	for _, d := range c.Distros {
+		if d.ID != img.Distro {
+			continue
+		}
+		for i := range d.Releases {
+			rel := models.ParseRelease(path.Base(d.Releases[i].Path))
+			if rel.Distro != have.Distro || rel.Variant != have.Variant || rel.Version == "" {
+				continue
+			}
+			if rel.Arch != "" && have.Arch != "" && rel.Arch != have.Arch {
+				continue
+			}
+			if models.CompareVersions(rel.Version, newestVersion) > 0 {
+				newest, newestVersion = &d.Releases[i], rel.Version
+			}
+		}
+	}
+	return newest
 }
@@ -0,0 +1,38 @@
+package profiles
+
+import (
+	"testing"
+
+	"bootimus/internal/models"
+)
+
+func TestNewerRelease(t *testing.T) {
+	catalog := &ISOCatalog{Distros: []ISOEntry{{
+		ID: "debian",
+		Releases: []ISORelease{
+			{Label: "13 DVD-1 (amd64)", Path: "/iso-dvd/debian-13.5.0-amd64-DVD-1.iso"},
+			{Label: "13 Netinst (amd64)", Path: "/iso-cd/debian-13.5.0-amd64-netinst.iso"},
+			{Label: "13 Netinst (arm64)", Path: "/iso-cd/debian-13.6.0-arm64-netinst.iso"},
+		},
+	}}}
+
+	tests := []struct {
+		filename, distro, want string
+	}{
+		{"debian-13.2.0-amd64-netinst.iso", "debian", "13 Netinst (amd64)"},
+		{"debian-12.10.0-amd64-DVD-1.iso", "debian", "13 DVD-1 (amd64)"},
+		{"debian-13.5.0-amd64-netinst.iso", "debian", ""},
+		{"debian-13.2.0-amd64-xfce.iso", "debian", ""},
+		{"debian-13.2.0-amd64-netinst.iso", "", ""},
+		{"devuan-5.0.0-amd64-netinst.iso", "debian", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if rel := catalog.NewerRelease(&models.Image{Filename: tt.filename, Distro: tt.distro}); rel != nil {
+			got = rel.Label
+		}
+		if got != tt.want {
+			t.Errorf("NewerRelease(%s) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+}
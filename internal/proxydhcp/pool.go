@@ -0,0 +1,346 @@
+package proxydhcp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// With a Pool configured the server is authoritative: it answers every
+// client on UDP/67, PXE or not, with an address of its own, and still adds
+// the boot options for PXE clients. UDP/4011 is unchanged.
+
+const (
+	DefaultLeaseTime = 12 * time.Hour
+
+	// offerHold is how long an offered address is kept back for the client
+	// it was offered to before it is taken up with a REQUEST.
+	offerHold = time.Minute
+)
+
+var errPoolExhausted = errors.New("address pool exhausted")
+
+// Lease is an address handed to a client.
+type Lease struct {
+	MAC      string
+	IP       net.IP
+	Hostname string
+	Expires  time.Time
+}
+
+// LeaseStore keeps leases across restarts and supplies the addresses
+// reserved for particular clients.
+type LeaseStore interface {
+	Leases() ([]Lease, error)
+	SaveLease(l Lease) error
+	Reservations() (map[string]net.IP, error) // keyed by MAC
+}
+
+type Pool struct {
+	Start      net.IP
+	End        net.IP
+	Netmask    net.IPMask
+	Routers    []net.IP
+	DNSServers []net.IP // falls back to Config.DNSServers
+	LeaseTime  time.Duration
+	Store      LeaseStore
+}
+
+// ParseRange reads a pool range written "192.168.1.100-192.168.1.200".
+func ParseRange(s string) (start, end net.IP, err error) {
+	a, b, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, nil, fmt.Errorf("range %q must be written start-end", s)
+	}
+	start = net.ParseIP(strings.TrimSpace(a)).To4()
+	end = net.ParseIP(strings.TrimSpace(b)).To4()
+	if start == nil || end == nil {
+		return nil, nil, fmt.Errorf("range %q must be two IPv4 addresses", s)
+	}
+	return start, end, nil
+}
+
+func (p *Pool) validate() error {
+	if p.Store == nil {
+		return errors.New("pool has no lease store")
+	}
+	p.Start, p.End = p.Start.To4(), p.End.To4()
+	if p.Start == nil || p.End == nil {
+		return errors.New("pool start and end must be IPv4 addresses")
+	}
+	if bytes.Compare(p.Start, p.End) > 0 {
+		return fmt.Errorf("pool start %s is after its end %s", p.Start, p.End)
+	}
+	if p.Netmask == nil {
+		p.Netmask = p.Start.DefaultMask()
+	}
+	if !p.Start.Mask(p.Netmask).Equal(p.End.Mask(p.Netmask)) {
+		return fmt.Errorf("pool %s-%s spans more than one %s subnet", p.Start, p.End, net.IP(p.Netmask))
+	}
+	if p.LeaseTime <= 0 {
+		p.LeaseTime = DefaultLeaseTime
+	}
+	return nil
+}
+
+func (p *Pool) contains(ip net.IP) bool {
+	ip = ip.To4()
+	return ip != nil && bytes.Compare(ip, p.Start) >= 0 && bytes.Compare(ip, p.End) <= 0
+}
+
+func (p *Pool) inSubnet(ip net.IP) bool {
+	ip = ip.To4()
+	return ip != nil && ip.Mask(p.Netmask).Equal(p.Start.Mask(p.Netmask))
+}
+
+// allocate picks the address for mac: its reservation, else the address it
+// last had or asked for if still free, else the first free one in the
+// range. Callers hold s.mu.
+func (s *Server) allocate(mac string, requested net.IP, now time.Time) (net.IP, error) {
+	p := s.cfg.Pool
+	reservations, err := p.Store.Reservations()
+	if err != nil {
+		return nil, err
+	}
+	if ip := reservations[mac].To4(); ip != nil {
+		return ip, nil
+	}
+	leases, err := p.Store.Leases()
+	if err != nil {
+		return nil, err
+	}
+
+	taken := map[string]bool{s.cfg.ServerIP.String(): true}
+	for _, ip := range reservations {
+		taken[ip.String()] = true
+	}
+	var previous net.IP
+	for _, l := range leases {
+		switch {
+		case l.MAC == mac:
+			previous = l.IP
+		case l.Expires.After(now):
+			taken[l.IP.String()] = true
+		}
+	}
+	for ip, o := range s.offers {
+		if o.mac != mac && o.expires.After(now) {
+			taken[ip] = true
+		}
+	}
+
+	free := func(ip net.IP) bool { return ip != nil && p.contains(ip) && !taken[ip.String()] }
+	switch {
+	case free(previous):
+		return previous.To4(), nil
+	case free(requested):
+		return requested.To4(), nil
+	}
+	for ip := dup(p.Start); ; ip = next(ip) {
+		if free(ip) {
+			return ip, nil
+		}
+		if ip.Equal(p.End) {
+			return nil, errPoolExhausted
+		}
+	}
+}
+
+type offer struct {
+	mac     string
+	expires time.Time
+}
+
+// optionWPAD is the proxy auto-config URL (option 252), which dhcpv4 has
+// no constant for.
+const optionWPAD = dhcpv4.GenericOptionCode(252)
+
+// handleLease answers a client on UDP/67 in authoritative mode.
+func (s *Server) handleLease(conn *net.UDPConn, req *dhcpv4.DHCPv4) {
+	if resp := s.lease(req, time.Now()); resp != nil {
+		s.send(conn, req, resp)
+	}
+}
+
+// lease builds the reply to req, or returns nil when there is none to send.
+func (s *Server) lease(req *dhcpv4.DHCPv4, now time.Time) *dhcpv4.DHCPv4 {
+	p := s.cfg.Pool
+	mac := req.ClientHWAddr.String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ip net.IP
+	respType := dhcpv4.MessageTypeAck
+	switch req.MessageType() {
+	case dhcpv4.MessageTypeDiscover:
+		var err error
+		if ip, err = s.allocate(mac, req.RequestedIPAddress(), now); err != nil {
+			log.Printf("DHCP: no address for %s: %v", mac, err)
+			return nil
+		}
+		s.offers[ip.String()] = offer{mac: mac, expires: now.Add(offerHold)}
+		respType = dhcpv4.MessageTypeOffer
+
+	case dhcpv4.MessageTypeRequest:
+		if id := req.ServerIdentifier(); id != nil && !id.Equal(s.cfg.ServerIP) {
+			return nil // the client took another server's offer
+		}
+		requested := req.RequestedIPAddress()
+		if requested == nil || requested.IsUnspecified() {
+			requested = req.ClientIPAddr
+		}
+		allocated, err := s.allocate(mac, requested, now)
+		if err != nil || !allocated.Equal(requested) {
+			return s.nak(req, fmt.Sprintf("%s is not available", requested))
+		}
+		ip = allocated
+		delete(s.offers, ip.String())
+		if err := p.Store.SaveLease(Lease{MAC: mac, IP: ip, Hostname: req.HostName(), Expires: now.Add(p.LeaseTime)}); err != nil {
+			log.Printf("DHCP: failed to save lease for %s: %v", mac, err)
+		}
+
+	case dhcpv4.MessageTypeInform:
+		ip = nil
+
+	case dhcpv4.MessageTypeRelease:
+		s.expire(mac, req.ClientIPAddr, now)
+		log.Printf("DHCP: %s released %s", mac, req.ClientIPAddr)
+		return nil
+
+	case dhcpv4.MessageTypeDecline:
+		// Something else answers on the address; keep it out of the pool
+		// for a lease time.
+		declined := req.RequestedIPAddress()
+		if p.contains(declined) {
+			p.Store.SaveLease(Lease{MAC: "declined/" + declined.String(), IP: declined, Expires: now.Add(p.LeaseTime)})
+		}
+		log.Printf("DHCP: %s declined %s", mac, declined)
+		return nil
+
+	default:
+		return nil
+	}
+
+	dns := p.DNSServers
+	if len(dns) == 0 {
+		dns = s.cfg.DNSServers
+	}
+	mods := []dhcpv4.Modifier{
+		dhcpv4.WithMessageType(respType),
+		dhcpv4.WithServerIP(s.cfg.ServerIP),
+		dhcpv4.WithOption(dhcpv4.OptServerIdentifier(s.cfg.ServerIP)),
+		dhcpv4.WithOption(dhcpv4.OptSubnetMask(p.Netmask)),
+	}
+	if ip != nil {
+		mods = append(mods, dhcpv4.WithYourIP(ip), dhcpv4.WithOption(dhcpv4.OptIPAddressLeaseTime(p.LeaseTime)))
+	}
+	if len(p.Routers) > 0 {
+		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptRouter(p.Routers...)))
+	}
+	if len(dns) > 0 {
+		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptDNS(dns...)))
+	}
+	if s.cfg.DomainName != "" {
+		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptDomainName(s.cfg.DomainName)))
+	}
+	if len(s.cfg.NTPServers) > 0 {
+		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptNTPServers(s.cfg.NTPServers...)))
+	}
+	if s.cfg.WPAD != nil {
+		client := ip
+		if client == nil {
+			client = req.ClientIPAddr
+		}
+		if u := s.cfg.WPAD(client); u != "" {
+			mods = append(mods, dhcpv4.WithOption(dhcpv4.OptGeneric(optionWPAD, []byte(u))))
+		}
+	}
+	var bootfile string
+	if isPXE(req) {
+		bootfile = s.bootfileFor(req)
+		mods = append(mods,
+			dhcpv4.WithOption(dhcpv4.OptClassIdentifier("PXEClient")),
+			dhcpv4.WithOption(dhcpv4.OptTFTPServerName(s.cfg.ServerIP.String())),
+			dhcpv4.WithOption(dhcpv4.OptBootFileName(bootfile)),
+		)
+	}
+	resp, err := dhcpv4.NewReplyFromRequest(req, mods...)
+	if err != nil {
+		log.Printf("DHCP: build reply: %v", err)
+		return nil
+	}
+	resp.BootFileName = bootfile
+	log.Printf("DHCP: %s -> %s %s", req.MessageType(), mac, respType)
+	return resp
+}
+
+func (s *Server) nak(req *dhcpv4.DHCPv4, reason string) *dhcpv4.DHCPv4 {
+	resp, err := dhcpv4.NewReplyFromRequest(req,
+		dhcpv4.WithMessageType(dhcpv4.MessageTypeNak),
+		dhcpv4.WithOption(dhcpv4.OptServerIdentifier(s.cfg.ServerIP)),
+		dhcpv4.WithOption(dhcpv4.OptMessage(reason)),
+	)
+	if err != nil {
+		return nil
+	}
+	// A NAK always goes out broadcast, since the client may not have the
+	// address it asked about.
+	resp.ClientIPAddr = net.IPv4zero
+	log.Printf("DHCP: NAK %s: %s", req.ClientHWAddr, reason)
+	return resp
+}
+
+// send replies through the relay the request came through, to a client
+// that already has its address, or else by broadcast.
+func (s *Server) send(conn *net.UDPConn, req, resp *dhcpv4.DHCPv4) {
+	dst := &net.UDPAddr{IP: net.IPv4bcast, Port: 68}
+	switch {
+	case !req.GatewayIPAddr.IsUnspecified() && req.GatewayIPAddr != nil:
+		dst = &net.UDPAddr{IP: req.GatewayIPAddr, Port: 67}
+	case resp.MessageType() != dhcpv4.MessageTypeNak && !req.ClientIPAddr.IsUnspecified() && req.ClientIPAddr != nil:
+		dst = &net.UDPAddr{IP: req.ClientIPAddr, Port: 68}
+	}
+	if _, err := conn.WriteToUDP(resp.ToBytes(), dst); err != nil {
+		log.Printf("DHCP: send reply: %v", err)
+	}
+}
+
+func (s *Server) expire(mac string, ip net.IP, now time.Time) {
+	leases, err := s.cfg.Pool.Store.Leases()
+	if err != nil {
+		return
+	}
+	for _, l := range leases {
+		if l.MAC == mac && l.IP.Equal(ip) && l.Expires.After(now) {
+			l.Expires = now
+			s.cfg.Pool.Store.SaveLease(l)
+		}
+	}
+}
+
+func isPXE(req *dhcpv4.DHCPv4) bool {
+	vci := req.ClassIdentifier()
+	return len(vci) >= 9 && vci[:9] == "PXEClient"
+}
+
+func dup(ip net.IP) net.IP {
+	return append(net.IP(nil), ip.To4()...)
+}
+
+func next(ip net.IP) net.IP {
+	out := dup(ip)
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i]++
+		if out[i] != 0 {
+			break
+		}
+	}
+	return out
+}
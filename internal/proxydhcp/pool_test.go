@@ -0,0 +1,276 @@
+package proxydhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+type memLeases struct {
+	leases       map[string]Lease // by MAC
+	reservations map[string]net.IP
+}
+
+func (m *memLeases) Leases() ([]Lease, error) {
+	var out []Lease
+	for _, l := range m.leases {
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func (m *memLeases) SaveLease(l Lease) error {
+	m.leases[l.MAC] = l
+	return nil
+}
+
+func (m *memLeases) Reservations() (map[string]net.IP, error) {
+	return m.reservations, nil
+}
+
+const (
+	macA = "52:54:00:00:00:0a"
+	macB = "52:54:00:00:00:0b"
+)
+
+var serverIP = net.IPv4(10, 0, 0, 1).To4()
+
+func testServer(t *testing.T, start, end string, store *memLeases) *Server {
+	t.Helper()
+	p := &Pool{Start: net.ParseIP(start), End: net.ParseIP(end), Netmask: net.CIDRMask(24, 32), Store: store}
+	if err := p.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return &Server{cfg: Config{ServerIP: serverIP, Pool: p}, offers: map[string]offer{}}
+}
+
+func TestAllocate(t *testing.T) {
+	now := time.Now()
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+
+	for _, tc := range []struct {
+		name         string
+		start, end   string
+		leases       []Lease
+		reservations map[string]net.IP
+		offers       map[string]offer
+		requested    string
+		want         string // "" for pool exhausted
+	}{
+		{
+			name:  "first free address",
+			start: "10.0.0.100", end: "10.0.0.110",
+			want: "10.0.0.100",
+		},
+		{
+			name:  "reservation wins over previous lease and request",
+			start: "10.0.0.100", end: "10.0.0.110",
+			leases:       []Lease{{MAC: macA, IP: net.ParseIP("10.0.0.105"), Expires: later}},
+			reservations: map[string]net.IP{macA: net.ParseIP("10.0.0.50")},
+			requested:    "10.0.0.107",
+			want:         "10.0.0.50",
+		},
+		{
+			name:  "previous lease reused over request",
+			start: "10.0.0.100", end: "10.0.0.110",
+			leases:    []Lease{{MAC: macA, IP: net.ParseIP("10.0.0.105"), Expires: earlier}},
+			requested: "10.0.0.107",
+			want:      "10.0.0.105",
+		},
+		{
+			name:  "previous lease since taken by another client",
+			start: "10.0.0.100", end: "10.0.0.110",
+			leases: []Lease{
+				{MAC: macA, IP: net.ParseIP("10.0.0.105"), Expires: earlier},
+				{MAC: macB, IP: net.ParseIP("10.0.0.105"), Expires: later},
+			},
+			requested: "10.0.0.107",
+			want:      "10.0.0.107",
+		},
+		{
+			name:  "requested address outside the range",
+			start: "10.0.0.100", end: "10.0.0.110",
+			requested: "10.0.0.200",
+			want:      "10.0.0.100",
+		},
+		{
+			name:  "addresses leased, reserved or held for others skipped",
+			start: "10.0.0.100", end: "10.0.0.110",
+			leases:       []Lease{{MAC: macB, IP: net.ParseIP("10.0.0.100"), Expires: later}},
+			reservations: map[string]net.IP{macB: net.ParseIP("10.0.0.101")},
+			offers:       map[string]offer{"10.0.0.102": {mac: macB, expires: later}},
+			want:         "10.0.0.103",
+		},
+		{
+			name:  "expired leases and held offers reclaimed",
+			start: "10.0.0.100", end: "10.0.0.110",
+			leases: []Lease{{MAC: macB, IP: net.ParseIP("10.0.0.100"), Expires: earlier}},
+			offers: map[string]offer{"10.0.0.100": {mac: macB, expires: earlier}},
+			want:   "10.0.0.100",
+		},
+		{
+			name:  "offer held for the same client reused",
+			start: "10.0.0.100", end: "10.0.0.110",
+			offers:    map[string]offer{"10.0.0.104": {mac: macA, expires: later}},
+			requested: "10.0.0.104",
+			want:      "10.0.0.104",
+		},
+		{
+			name:  "server address skipped",
+			start: "10.0.0.1", end: "10.0.0.10",
+			want: "10.0.0.2",
+		},
+		{
+			name:  "pool exhausted",
+			start: "10.0.0.100", end: "10.0.0.101",
+			leases: []Lease{{MAC: macB, IP: net.ParseIP("10.0.0.100"), Expires: later}},
+			offers: map[string]offer{"10.0.0.101": {mac: "52:54:00:00:00:0c", expires: later}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &memLeases{leases: map[string]Lease{}, reservations: tc.reservations}
+			for _, l := range tc.leases {
+				store.leases[l.MAC] = l
+			}
+			s := testServer(t, tc.start, tc.end, store)
+			for ip, o := range tc.offers {
+				s.offers[ip] = o
+			}
+
+			got, err := s.allocate(macA, net.ParseIP(tc.requested), now)
+			if tc.want == "" {
+				if err != errPoolExhausted {
+					t.Errorf("got %v, %v; want pool exhausted", got, err)
+				}
+				return
+			}
+			if err != nil || !got.Equal(net.ParseIP(tc.want)) {
+				t.Errorf("got %v, %v; want %s", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestLease(t *testing.T) {
+	hw, _ := net.ParseMAC(macA)
+	request := func(typ dhcpv4.MessageType, mods ...dhcpv4.Modifier) *dhcpv4.DHCPv4 {
+		req, err := dhcpv4.New(append([]dhcpv4.Modifier{dhcpv4.WithHwAddr(hw), dhcpv4.WithMessageType(typ)}, mods...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	requestIP := func(ip string) dhcpv4.Modifier {
+		return dhcpv4.WithOption(dhcpv4.OptRequestedIPAddress(net.ParseIP(ip)))
+	}
+	serverID := func(ip net.IP) dhcpv4.Modifier {
+		return dhcpv4.WithOption(dhcpv4.OptServerIdentifier(ip))
+	}
+
+	for _, tc := range []struct {
+		name   string
+		leases []Lease
+		offers map[string]offer
+		req    *dhcpv4.DHCPv4
+		want   dhcpv4.MessageType // MessageTypeNone for no reply
+		yiaddr string
+		saved  bool // a lease for macA was stored
+	}{
+		{
+			name:   "discover is offered a free address",
+			req:    request(dhcpv4.MessageTypeDiscover),
+			want:   dhcpv4.MessageTypeOffer,
+			yiaddr: "10.0.0.100",
+		},
+		{
+			name:   "discover asking for an address gets it",
+			req:    request(dhcpv4.MessageTypeDiscover, requestIP("10.0.0.105")),
+			want:   dhcpv4.MessageTypeOffer,
+			yiaddr: "10.0.0.105",
+		},
+		{
+			name:   "request for the offered address is acked",
+			offers: map[string]offer{"10.0.0.105": {mac: macA, expires: time.Now().Add(time.Minute)}},
+			req:    request(dhcpv4.MessageTypeRequest, requestIP("10.0.0.105"), serverID(serverIP)),
+			want:   dhcpv4.MessageTypeAck,
+			yiaddr: "10.0.0.105",
+			saved:  true,
+		},
+		{
+			name:   "renewal from the client address is acked",
+			leases: []Lease{{MAC: macA, IP: net.ParseIP("10.0.0.105"), Expires: time.Now().Add(time.Hour)}},
+			req:    request(dhcpv4.MessageTypeRequest, dhcpv4.WithClientIP(net.ParseIP("10.0.0.105"))),
+			want:   dhcpv4.MessageTypeAck,
+			yiaddr: "10.0.0.105",
+			saved:  true,
+		},
+		{
+			name:   "request for another client's address is refused",
+			leases: []Lease{{MAC: macB, IP: net.ParseIP("10.0.0.105"), Expires: time.Now().Add(time.Hour)}},
+			req:    request(dhcpv4.MessageTypeRequest, requestIP("10.0.0.105")),
+			want:   dhcpv4.MessageTypeNak,
+		},
+		{
+			name: "request for an address outside the pool is refused",
+			req:  request(dhcpv4.MessageTypeRequest, requestIP("192.168.1.20")),
+			want: dhcpv4.MessageTypeNak,
+		},
+		{
+			name: "request to another server is ignored",
+			req:  request(dhcpv4.MessageTypeRequest, requestIP("10.0.0.105"), serverID(net.ParseIP("10.0.0.2"))),
+			want: dhcpv4.MessageTypeNone,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &memLeases{leases: map[string]Lease{}}
+			for _, l := range tc.leases {
+				store.leases[l.MAC] = l
+			}
+			s := testServer(t, "10.0.0.100", "10.0.0.110", store)
+			for ip, o := range tc.offers {
+				s.offers[ip] = o
+			}
+
+			resp := s.lease(tc.req, time.Now())
+			if tc.want == dhcpv4.MessageTypeNone {
+				if resp != nil {
+					t.Fatalf("got a %s, want no reply", resp.MessageType())
+				}
+				return
+			}
+			if resp == nil {
+				t.Fatalf("got no reply, want a %s", tc.want)
+			}
+			if resp.MessageType() != tc.want {
+				t.Fatalf("got a %s, want a %s", resp.MessageType(), tc.want)
+			}
+			if tc.yiaddr != "" && !resp.YourIPAddr.Equal(net.ParseIP(tc.yiaddr)) {
+				t.Errorf("yiaddr %s, want %s", resp.YourIPAddr, tc.yiaddr)
+			}
+
+			switch tc.want {
+			case dhcpv4.MessageTypeOffer:
+				if o, ok := s.offers[tc.yiaddr]; !ok || o.mac != macA {
+					t.Errorf("offer of %s not held for the client", tc.yiaddr)
+				}
+			case dhcpv4.MessageTypeAck:
+				if _, ok := s.offers[tc.yiaddr]; ok {
+					t.Errorf("offer of %s still held after the ack", tc.yiaddr)
+				}
+			case dhcpv4.MessageTypeNak:
+				if !resp.ClientIPAddr.Equal(net.IPv4zero) {
+					t.Errorf("NAK carries ciaddr %s", resp.ClientIPAddr)
+				}
+			}
+			l, ok := store.leases[macA]
+			if ok != tc.saved {
+				t.Errorf("lease saved: %v, want %v", ok, tc.saved)
+			}
+			if ok && (!l.IP.Equal(net.ParseIP(tc.yiaddr)) || !l.Expires.After(time.Now().Add(DefaultLeaseTime-time.Minute))) {
+				t.Errorf("saved lease %+v", l)
+			}
+		})
+	}
+}
@@ -28,6 +28,18 @@ type Config struct {
 	// it returns overrides the static Bootfile* fields. This lets the server
 	// switch bootloader sets at runtime without restarting proxyDHCP.
 	Bootfiles func() (bios, uefi, arm64 string)
+	// DNSServers, DomainName and NTPServers, when set, are offered as
+	// options 6, 15 and 42 for clients that take them from the proxy offer
+	// (iPXE does).
+	DNSServers []net.IP
+	DomainName string
+	NTPServers []net.IP
+	// Pool, when set, makes the server hand out addresses itself; see
+	// pool.go.
+	Pool *Pool
+	// WPAD, when set, returns the proxy auto-config URL for a client,
+	// offered as option 252 with leases; "" offers none.
+	WPAD func(ip net.IP) string
 }
 
 type Server struct {
@@ -36,6 +48,9 @@ type Server struct {
 	conn4011 *net.UDPConn
 	wg       sync.WaitGroup
 	done     chan struct{}
+
+	mu     sync.Mutex
+	offers map[string]offer // by IP
 }
 
 func NewServer(cfg Config) (*Server, error) {
@@ -55,7 +70,15 @@ func NewServer(cfg Config) (*Server, error) {
 	if cfg.BootfileARM64 == "" {
 		cfg.BootfileARM64 = DefaultBootfileARM64
 	}
-	return &Server{cfg: cfg, done: make(chan struct{})}, nil
+	if cfg.Pool != nil {
+		if err := cfg.Pool.validate(); err != nil {
+			return nil, err
+		}
+		if !cfg.Pool.inSubnet(cfg.ServerIP) {
+			log.Printf("DHCP: server address %s is outside the pool's subnet; clients must reach it through a router", cfg.ServerIP)
+		}
+	}
+	return &Server{cfg: cfg, done: make(chan struct{}), offers: map[string]offer{}}, nil
 }
 
 func (s *Server) Start() error {
@@ -77,6 +100,9 @@ func (s *Server) Start() error {
 	s.conn4011 = conn4011
 
 	bios, uefi, arm64 := s.effectiveBootfiles()
+	if p := s.cfg.Pool; p != nil {
+		log.Printf("DHCP: leasing %s-%s (mask %s, lease %s) on UDP/67", p.Start, p.End, net.IP(p.Netmask), p.LeaseTime)
+	}
 	log.Printf("proxyDHCP: listening on UDP/67 + UDP/4011, advertising next-server=%s (BIOS=%s, UEFI=%s, ARM64=%s)",
 		s.cfg.ServerIP, bios, uefi, arm64)
 
@@ -127,8 +153,11 @@ func (s *Server) loop(conn *net.UDPConn, bootp bool) {
 }
 
 func (s *Server) handle(conn *net.UDPConn, src *net.UDPAddr, req *dhcpv4.DHCPv4, bootp bool) {
-	vci := req.ClassIdentifier()
-	if len(vci) < 9 || vci[:9] != "PXEClient" {
+	if bootp && s.cfg.Pool != nil {
+		s.handleLease(conn, req)
+		return
+	}
+	if !isPXE(req) {
 		return
 	}
 
@@ -156,6 +185,15 @@ func (s *Server) handle(conn *net.UDPConn, src *net.UDPAddr, req *dhcpv4.DHCPv4,
 		log.Printf("proxyDHCP: build reply: %v", err)
 		return
 	}
+	if len(s.cfg.DNSServers) > 0 {
+		resp.UpdateOption(dhcpv4.OptDNS(s.cfg.DNSServers...))
+	}
+	if s.cfg.DomainName != "" {
+		resp.UpdateOption(dhcpv4.OptDomainName(s.cfg.DomainName))
+	}
+	if len(s.cfg.NTPServers) > 0 {
+		resp.UpdateOption(dhcpv4.OptNTPServers(s.cfg.NTPServers...))
+	}
 	resp.YourIPAddr = net.IPv4zero
 	if guid := req.GetOneOption(dhcpv4.OptionClientMachineIdentifier); guid != nil {
 		resp.UpdateOption(dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, guid))
@@ -0,0 +1,260 @@
+// Package rangestats records which parts of each ISO sanboot clients read.
+// A sanboot install typically touches a small fraction of the image, so the
+// recorded regions can be read ahead before the next client asks for them,
+// and an image that is mostly unread is better off extracted and booted by
+// kernel.
+package rangestats
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// BlockSize is the granularity reads are recorded at.
+	BlockSize = 1 << 20
+
+	// A sanboot image whose clients read less than this fraction of it is
+	// worth switching to kernel boot.
+	ExtractThreshold = 0.25
+
+	// minReads is how many reads an image needs before its coverage means
+	// anything.
+	minReads = 20
+
+	interval = time.Minute
+)
+
+type imageStats struct {
+	Size    int64     `json:"size"`
+	Blocks  []byte    `json:"blocks"` // one bit per block read
+	Served  int64     `json:"served"` // bytes, counting re-reads
+	Reads   int64     `json:"reads"`
+	Updated time.Time `json:"updated"`
+}
+
+type Region struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+type Report struct {
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`
+	BlockSize int       `json:"block_size"`
+	Read      int64     `json:"read"`     // distinct bytes read, to a block
+	Fraction  float64   `json:"fraction"` // Read / Size
+	Served    int64     `json:"served"`
+	Reads     int64     `json:"reads"`
+	Regions   []Region  `json:"regions"`
+	Updated   time.Time `json:"updated"`
+	// SuggestExtract is set once enough has been read to say that clients
+	// use little of the image.
+	SuggestExtract bool `json:"suggest_extract"`
+}
+
+type Tracker struct {
+	path string
+
+	mu     sync.Mutex
+	images map[string]*imageStats
+	dirty  bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New loads the recorded reads kept at path.
+func New(path string) *Tracker {
+	t := &Tracker{path: path, images: map[string]*imageStats{}, done: make(chan struct{})}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &t.images); err != nil {
+			log.Printf("Range stats: ignoring %s: %v", path, err)
+		}
+	}
+	return t
+}
+
+// Start saves the recorded reads every minute until Shutdown.
+func (t *Tracker) Start() {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-ticker.C:
+				if err := t.Save(); err != nil {
+					log.Printf("Range stats: Failed to save: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (t *Tracker) Shutdown() {
+	close(t.done)
+	t.wg.Wait()
+	if err := t.Save(); err != nil {
+		log.Printf("Range stats: Failed to save: %v", err)
+	}
+}
+
+// Record notes that length bytes at offset were read from filename, an
+// image of size bytes. A changed size means the ISO was replaced, and what
+// was recorded for the old one is dropped.
+func (t *Tracker) Record(filename string, size, offset, length int64) {
+	if t == nil || length <= 0 || size <= 0 || offset >= size {
+		return
+	}
+	if offset+length > size {
+		length = size - offset
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.images[filename]
+	if st == nil || st.Size != size {
+		st = &imageStats{Size: size, Blocks: make([]byte, (blocks(size)+7)/8)}
+		t.images[filename] = st
+	}
+	for b := offset / BlockSize; b <= (offset+length-1)/BlockSize; b++ {
+		st.Blocks[b/8] |= 1 << (b % 8)
+	}
+	st.Served += length
+	st.Reads++
+	st.Updated = time.Now()
+	t.dirty = true
+}
+
+// Forget drops what was recorded for filename.
+func (t *Tracker) Forget(filename string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.images[filename]; ok {
+		delete(t.images, filename)
+		t.dirty = true
+	}
+}
+
+// Report summarises the reads of filename, or returns false if it has
+// none.
+func (t *Tracker) Report(filename string) (*Report, bool) {
+	if t == nil {
+		return nil, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.images[filename]
+	if st == nil {
+		return nil, false
+	}
+	rep := &Report{
+		Filename:  filename,
+		Size:      st.Size,
+		BlockSize: BlockSize,
+		Served:    st.Served,
+		Reads:     st.Reads,
+		Updated:   st.Updated,
+		Regions:   regions(st),
+	}
+	for _, r := range rep.Regions {
+		rep.Read += r.Length
+	}
+	rep.Fraction = float64(rep.Read) / float64(st.Size)
+	rep.SuggestExtract = st.Reads >= minReads && rep.Fraction < ExtractThreshold
+	return rep, true
+}
+
+// Reports summarises every image with recorded reads, by filename.
+func (t *Tracker) Reports() []*Report {
+	t.mu.Lock()
+	names := make([]string, 0, len(t.images))
+	for name := range t.images {
+		names = append(names, name)
+	}
+	t.mu.Unlock()
+	sort.Strings(names)
+	var out []*Report
+	for _, name := range names {
+		if rep, ok := t.Report(name); ok {
+			out = append(out, rep)
+		}
+	}
+	return out
+}
+
+func (t *Tracker) Save() error {
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(t.images)
+	t.dirty = false
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// Prefetch reads regions of the file at path so they are in the page
+// cache before a client asks for them. It returns the bytes read.
+func Prefetch(path string, regions []Region) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var total int64
+	for _, r := range regions {
+		n, err := io.Copy(io.Discard, io.NewSectionReader(f, r.Offset, r.Length))
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func blocks(size int64) int64 {
+	return (size + BlockSize - 1) / BlockSize
+}
+
+// regions merges runs of read blocks, clipped to the image size.
+func regions(st *imageStats) []Region {
+	var out []Region
+	n := blocks(st.Size)
+	for b := int64(0); b < n; {
+		if st.Blocks[b/8] == 0 && b%8 == 0 {
+			b += 8
+			continue
+		}
+		if st.Blocks[b/8]&(1<<(b%8)) == 0 {
+			b++
+			continue
+		}
+		start := b
+		for b < n && st.Blocks[b/8]&(1<<(b%8)) != 0 {
+			b++
+		}
+		end := b * BlockSize
+		if end > st.Size {
+			end = st.Size
+		}
+		out = append(out, Region{Offset: start * BlockSize, Length: end - start*BlockSize})
+	}
+	return out
+}
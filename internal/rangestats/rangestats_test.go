@@ -0,0 +1,64 @@
+package rangestats
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRecordAndReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "range-stats.json")
+	tr := New(path)
+	size := int64(10*BlockSize + 100)
+	tr.Record("a.iso", size, 0, 2048)
+	tr.Record("a.iso", size, BlockSize+5, BlockSize) // spans blocks 1 and 2
+	tr.Record("a.iso", size, 10*BlockSize, 1<<30)    // clipped to the tail
+
+	rep, ok := tr.Report("a.iso")
+	if !ok {
+		t.Fatal("no report")
+	}
+	want := []Region{{0, 3 * BlockSize}, {10 * BlockSize, 100}}
+	if !reflect.DeepEqual(rep.Regions, want) {
+		t.Errorf("regions = %+v", rep.Regions)
+	}
+	if rep.Read != 3*BlockSize+100 || rep.Reads != 3 || rep.Served != 2048+BlockSize+100 {
+		t.Errorf("report = %+v", rep)
+	}
+	if rep.SuggestExtract {
+		t.Error("suggested extraction after three reads")
+	}
+
+	if err := tr.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if rep2, _ := New(path).Report("a.iso"); !reflect.DeepEqual(rep2.Regions, want) {
+		t.Errorf("reloaded regions = %+v", rep2.Regions)
+	}
+
+	// A replaced ISO starts over.
+	tr.Record("a.iso", size+1, 5*BlockSize, 1)
+	if rep, _ := tr.Report("a.iso"); len(rep.Regions) != 1 || rep.Regions[0].Offset != 5*BlockSize {
+		t.Errorf("after resize: %+v", rep.Regions)
+	}
+}
+
+func TestSuggestExtract(t *testing.T) {
+	tr := New(filepath.Join(t.TempDir(), "s.json"))
+	for i := 0; i < minReads; i++ {
+		tr.Record("big.iso", 100*BlockSize, 0, BlockSize)
+	}
+	if rep, _ := tr.Report("big.iso"); !rep.SuggestExtract {
+		t.Errorf("report = %+v", rep)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.iso")
+	os.WriteFile(path, make([]byte, 5000), 0644)
+	n, err := Prefetch(path, []Region{{0, 100}, {4900, 500}})
+	if err != nil || n != 200 {
+		t.Errorf("Prefetch = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,314 @@
+// Package ratelimit protects the admin listener from scanners and
+// password guessing: each client IP gets a request budget, failed logins
+// lock the IP out for exponentially longer, and IPs can be banned outright.
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRate        = 20
+	DefaultBurst       = 100
+	DefaultMaxFailures = 5
+	DefaultMaxBackoff  = 15 * time.Minute
+
+	// idleExpiry is how long an IP with nothing against it is remembered.
+	idleExpiry = time.Hour
+)
+
+type Config struct {
+	Rate        float64       // requests per second per IP; 0 disables the budget
+	Burst       int           // requests an IP may make at once
+	MaxFailures int           // failed logins allowed before the lockouts start; 0 disables them
+	MaxBackoff  time.Duration // longest lockout
+	LoginPath   string        // failed requests to this path count as failed logins
+	BansFile    string        // manual bans are kept here across restarts; empty keeps them in memory
+}
+
+// Ban is an IP refused outright (Manual) or locked out after failed logins.
+type Ban struct {
+	IP        string     `json:"ip"`
+	Reason    string     `json:"reason,omitempty"`
+	Manual    bool       `json:"manual"`
+	Failures  int        `json:"failures,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Until     *time.Time `json:"until,omitempty"` // nil for a permanent ban
+}
+
+type client struct {
+	tokens   float64
+	last     time.Time
+	failures int
+	blocked  time.Time // locked out until
+	since    time.Time // first failure of the current run
+}
+
+type Limiter struct {
+	cfg       Config
+	mu        sync.Mutex
+	clients   map[string]*client
+	bans      map[string]Ban
+	lastPrune time.Time
+}
+
+func New(cfg Config) *Limiter {
+	if cfg.Burst <= 0 {
+		cfg.Burst = int(math.Max(1, cfg.Rate))
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	l := &Limiter{cfg: cfg, clients: make(map[string]*client), bans: make(map[string]Ban)}
+	if cfg.BansFile != "" {
+		if data, err := os.ReadFile(cfg.BansFile); err == nil {
+			var bans []Ban
+			if err := json.Unmarshal(data, &bans); err != nil {
+				log.Printf("Rate limit: ignoring %s: %v", cfg.BansFile, err)
+			}
+			for _, b := range bans {
+				l.bans[b.IP] = b
+			}
+		}
+	}
+	return l
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusWriter records the response status for the failed-login count.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach Flush on the log stream.
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func refuse(w http.ResponseWriter, status int, retry time.Duration, msg string) {
+	if retry > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": msg})
+}
+
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		status, retry := l.admit(ip, time.Now())
+		switch status {
+		case http.StatusForbidden:
+			refuse(w, status, 0, "This address is banned")
+			return
+		case http.StatusTooManyRequests:
+			refuse(w, status, retry, "Too many requests; try again later")
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if l.cfg.LoginPath != "" && r.URL.Path == l.cfg.LoginPath && r.Method == http.MethodPost {
+			l.loginResult(ip, sw.status == http.StatusUnauthorized, time.Now())
+		}
+	})
+}
+
+// admit decides whether ip may make a request now. It returns 0 or the
+// status to refuse it with, and how long to wait.
+func (l *Limiter) admit(ip string, now time.Time) (int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > idleExpiry/4 {
+		l.prune(now)
+	}
+	if b, ok := l.bans[ip]; ok {
+		if b.Until == nil || now.Before(*b.Until) {
+			return http.StatusForbidden, 0
+		}
+		delete(l.bans, ip)
+		l.save()
+	}
+
+	c := l.clients[ip]
+	if c == nil {
+		c = &client{tokens: float64(l.cfg.Burst), last: now}
+		l.clients[ip] = c
+	}
+	if now.Before(c.blocked) {
+		return http.StatusTooManyRequests, c.blocked.Sub(now)
+	}
+	if l.cfg.Rate <= 0 {
+		c.last = now
+		return 0, 0
+	}
+	c.tokens = math.Min(float64(l.cfg.Burst), c.tokens+now.Sub(c.last).Seconds()*l.cfg.Rate)
+	c.last = now
+	if c.tokens < 1 {
+		return http.StatusTooManyRequests, time.Duration((1 - c.tokens) / l.cfg.Rate * float64(time.Second))
+	}
+	c.tokens--
+	return 0, 0
+}
+
+// loginResult counts a failed login against ip, or clears its count after
+// a successful one. From MaxFailures on, each failure locks the IP out for
+// twice as long as the last, up to MaxBackoff.
+func (l *Limiter) loginResult(ip string, failed bool, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c := l.clients[ip]
+	if c == nil {
+		return
+	}
+	if !failed {
+		c.failures = 0
+		return
+	}
+	if c.failures == 0 {
+		c.since = now
+	}
+	c.failures++
+	if l.cfg.MaxFailures <= 0 || c.failures < l.cfg.MaxFailures {
+		return
+	}
+	backoff := l.cfg.MaxBackoff
+	if shift := c.failures - l.cfg.MaxFailures; shift < 30 {
+		backoff = min(time.Second<<shift, l.cfg.MaxBackoff)
+	}
+	c.blocked = now.Add(backoff)
+	log.Printf("Rate limit: %d failed logins from %s; locked out for %s", c.failures, ip, backoff)
+}
+
+func (l *Limiter) prune(now time.Time) {
+	l.lastPrune = now
+	for ip, c := range l.clients {
+		if now.Sub(c.last) > idleExpiry && now.After(c.blocked) {
+			delete(l.clients, ip)
+		}
+	}
+}
+
+// Bans lists manual bans and IPs currently locked out.
+func (l *Limiter) Bans() []Ban {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	out := []Ban{}
+	for _, b := range l.bans {
+		if b.Until == nil || now.Before(*b.Until) {
+			out = append(out, b)
+		}
+	}
+	for ip, c := range l.clients {
+		if _, banned := l.bans[ip]; banned || !now.Before(c.blocked) {
+			continue
+		}
+		until := c.blocked
+		out = append(out, Ban{IP: ip, Reason: "failed logins", Failures: c.failures, CreatedAt: c.since, Until: &until})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// Ban refuses ip for d, or for good when d is 0. Loopback addresses cannot
+// be banned, so the server stays reachable from its own host.
+func (l *Limiter) Ban(ip, reason string, d time.Duration) (Ban, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return Ban{}, fmt.Errorf("%q is not an IP address", ip)
+	}
+	if parsed.IsLoopback() {
+		return Ban{}, errors.New("loopback addresses cannot be banned")
+	}
+	b := Ban{IP: parsed.String(), Reason: reason, Manual: true, CreatedAt: time.Now()}
+	if d > 0 {
+		until := b.CreatedAt.Add(d)
+		b.Until = &until
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bans[b.IP] = b
+	return b, l.save()
+}
+
+// Unban lifts a manual ban and any lockout of ip. It reports whether
+// there was anything to lift.
+func (l *Limiter) Unban(ip string) (bool, error) {
+	if parsed := net.ParseIP(ip); parsed != nil {
+		ip = parsed.String()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, banned := l.bans[ip]
+	delete(l.bans, ip)
+	c := l.clients[ip]
+	locked := c != nil && time.Now().Before(c.blocked)
+	if c != nil {
+		c.failures = 0
+		c.blocked = time.Time{}
+	}
+	if !banned {
+		return locked, nil
+	}
+	return true, l.save()
+}
+
+// save writes the manual bans to BansFile; l.mu must be held.
+func (l *Limiter) save() error {
+	if l.cfg.BansFile == "" {
+		return nil
+	}
+	bans := make([]Ban, 0, len(l.bans))
+	for _, b := range l.bans {
+		bans = append(bans, b)
+	}
+	data, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := l.cfg.BansFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.cfg.BansFile)
+}
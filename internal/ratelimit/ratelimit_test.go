@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRateBudget(t *testing.T) {
+	l := New(Config{Rate: 1, Burst: 3})
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if status, _ := l.admit("10.0.0.1", now); status != 0 {
+			t.Fatalf("request %d refused with %d", i, status)
+		}
+	}
+	status, retry := l.admit("10.0.0.1", now)
+	if status != http.StatusTooManyRequests || retry <= 0 {
+		t.Fatalf("fourth request: status %d retry %s, want 429", status, retry)
+	}
+	if status, _ := l.admit("10.0.0.2", now); status != 0 {
+		t.Error("another IP shares the budget")
+	}
+	if status, _ := l.admit("10.0.0.1", now.Add(time.Second)); status != 0 {
+		t.Error("budget not refilled after a second")
+	}
+}
+
+func TestLoginBackoff(t *testing.T) {
+	l := New(Config{MaxFailures: 3, MaxBackoff: 4 * time.Second})
+	now := time.Now()
+	fail := func() {
+		l.admit("10.0.0.1", now)
+		l.loginResult("10.0.0.1", true, now)
+	}
+	fail()
+	fail()
+	if status, _ := l.admit("10.0.0.1", now); status != 0 {
+		t.Fatal("locked out before MaxFailures")
+	}
+	l.loginResult("10.0.0.1", true, now)
+
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		status, retry := l.admit("10.0.0.1", now)
+		if status != http.StatusTooManyRequests || retry != want {
+			t.Fatalf("status %d retry %s, want 429 after %s", status, retry, want)
+		}
+		now = now.Add(want)
+		fail()
+	}
+
+	now = now.Add(time.Hour)
+	l.admit("10.0.0.1", now)
+	l.loginResult("10.0.0.1", false, now)
+	if l.clients["10.0.0.1"].failures != 0 {
+		t.Error("successful login did not clear the failures")
+	}
+}
+
+func TestMiddlewareCountsOnlyFailedLogins(t *testing.T) {
+	l := New(Config{MaxFailures: 1, LoginPath: "/api/login"})
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	do := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.0.2.7:5555"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	// Stale tokens after a restart are not guesses.
+	do(http.MethodGet, "/api/images")
+	do(http.MethodGet, "/api/images")
+	if code := do(http.MethodPost, "/api/login"); code != http.StatusUnauthorized {
+		t.Fatalf("login refused with %d before any failed login", code)
+	}
+	if code := do(http.MethodGet, "/api/images"); code != http.StatusTooManyRequests {
+		t.Errorf("got %d after a failed login, want 429", code)
+	}
+}
+
+func TestBansPersist(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bans.json")
+	l := New(Config{BansFile: file})
+	if _, err := l.Ban("127.0.0.1", "", 0); err == nil {
+		t.Error("loopback ban accepted")
+	}
+	if _, err := l.Ban("not-an-ip", "", 0); err == nil {
+		t.Error("invalid IP accepted")
+	}
+	if _, err := l.Ban("198.51.100.9", "scanner", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	l = New(Config{BansFile: file})
+	if status, _ := l.admit("198.51.100.9", time.Now()); status != http.StatusForbidden {
+		t.Fatalf("ban not reloaded: status %d", status)
+	}
+	if bans := l.Bans(); len(bans) != 1 || bans[0].Reason != "scanner" {
+		t.Errorf("bans = %+v", bans)
+	}
+	if ok, err := l.Unban("198.51.100.9"); !ok || err != nil {
+		t.Fatalf("unban: %v %v", ok, err)
+	}
+	if status, _ := New(Config{BansFile: file}).admit("198.51.100.9", time.Now()); status != 0 {
+		t.Error("unban not saved")
+	}
+}
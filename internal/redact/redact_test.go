@@ -0,0 +1,41 @@
+package redact
+
+import "testing"
+
+func TestString(t *testing.T) {
+	AddSecret("hunter22", "abc")
+	tests := []struct{ in, want string }{
+		{"connecting with hunter22", "connecting with ********"},
+		{"abc is too short to register", "abc is too short to register"},
+		{"postgres://bootimus:s3cret@db:5432/bootimus", "postgres://bootimus:********@db:5432/bootimus"},
+		{"GET /menu.ipxe?mac=aa&token=deadbeef", "GET /menu.ipxe?mac=aa&token=********"},
+		{`{"user":"admin","password":"pw1"}`, `{"user":"admin","password":"********"}`},
+		{"BOOTIMUS_DB_PASSWORD=pw2 BOOTIMUS_DB_USER=admin", "BOOTIMUS_DB_PASSWORD=******** BOOTIMUS_DB_USER=admin"},
+		{"secrets.key_file=/data/secrets.key", "secrets.key_file=/data/secrets.key"},
+		{"compass=north bypass=1", "compass=north bypass=1"},
+	}
+	for _, tt := range tests {
+		if got := String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	Allow("BOOTIMUS_LDAP_BIND_PASSWORD_FILE")
+	for key, want := range map[string]string{
+		"BOOTIMUS_DB_PASSWORD":         Mask,
+		"db.password":                  Mask,
+		"BOOTIMUS_BOOT_TOKEN":          Mask,
+		"BOOTIMUS_DB_HOST":             "value",
+		"ldap-bind-password-file":      "value",
+		"BOOTIMUS_SECRETS_KMS_COMMAND": "value",
+	} {
+		if got := Value(key, "value"); got != want {
+			t.Errorf("Value(%q) = %q, want %q", key, got, want)
+		}
+	}
+	if got := Value("BOOTIMUS_DB_PASSWORD", ""); got != "" {
+		t.Errorf("empty secret shown as %q", got)
+	}
+}
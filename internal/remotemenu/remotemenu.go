@@ -0,0 +1,85 @@
+// Package remotemenu checks menu entries that chain to iPXE scripts on
+// other servers.
+package remotemenu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"bootimus/internal/models"
+)
+
+// CheckTimeout bounds one reachability check.
+const CheckTimeout = 10 * time.Second
+
+// settingRe matches an iPXE setting such as ${net0/mac:hexhyp}, expanded by
+// the client when it chains.
+var settingRe = regexp.MustCompile(`\$\{[^}]*\}`)
+
+// Validate checks a menu's name and URL before they are written into iPXE
+// scripts, where a newline would start a new command.
+func Validate(name, rawURL string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 64 || strings.ContainsAny(name, "\r\n\t") {
+		return errors.New("name must be a single line of 1 to 64 characters")
+	}
+	if strings.ContainsAny(rawURL, " \r\n\t") {
+		return errors.New("URL must not contain whitespace")
+	}
+	u, err := url.Parse(settingRe.ReplaceAllString(rawURL, "x"))
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("URL must be http:// or https:// with a host")
+	}
+	return nil
+}
+
+// Check fetches the script the way a client would, with iPXE settings left
+// empty, and confirms it answers with an iPXE script.
+func Check(ctx context.Context, client *http.Client, rawURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, settingRe.ReplaceAllString(rawURL, ""), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "iPXE (bootimus remote menu check)")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+
+	head, err := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if err != nil {
+		return err
+	}
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if !bytes.HasPrefix(bytes.ToLower(head), []byte("#!ipxe")) {
+		return errors.New("response is not an iPXE script (no #!ipxe line)")
+	}
+	return nil
+}
+
+// Record stores the outcome of a check on m.
+func Record(m *models.RemoteMenu, err error) {
+	ok := err == nil
+	now := time.Now()
+	m.Reachable, m.CheckedAt, m.CheckError = &ok, &now, ""
+	if err != nil {
+		m.CheckError = err.Error()
+	}
+}
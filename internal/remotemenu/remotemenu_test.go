@@ -0,0 +1,56 @@
+package remotemenu
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name, url string
+		ok        bool
+	}{
+		{"Lab netboot.xyz", "http://netboot.lab/menu.ipxe", true},
+		{"Other site", "https://pxe.example.com/menu.ipxe?mac=${net0/mac}", true},
+		{"", "http://netboot.lab/menu.ipxe", false},
+		{"Two\nlines", "http://netboot.lab/menu.ipxe", false},
+		{"TFTP", "tftp://netboot.lab/menu.ipxe", false},
+		{"Injected", "http://netboot.lab/menu.ipxe\nshell", false},
+		{"No host", "http:///menu.ipxe", false},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.name, tt.url); (err == nil) != tt.ok {
+			t.Errorf("Validate(%q, %q) = %v, want ok=%v", tt.name, tt.url, err, tt.ok)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/menu.ipxe":
+			if r.URL.Query().Get("mac") != "" {
+				t.Errorf("iPXE setting was sent: %s", r.URL.RawQuery)
+			}
+			w.Write([]byte("\n#!IPXE\nmenu Remote\n"))
+		case "/page":
+			w.Write([]byte("<html>login</html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	if err := Check(context.Background(), srv.Client(), srv.URL+"/menu.ipxe?mac=${net0/mac}"); err != nil {
+		t.Errorf("script: %v", err)
+	}
+	if err := Check(context.Background(), srv.Client(), srv.URL+"/page"); err == nil || !strings.Contains(err.Error(), "not an iPXE script") {
+		t.Errorf("HTML page: %v", err)
+	}
+	if err := Check(context.Background(), srv.Client(), srv.URL+"/missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("missing: %v", err)
+	}
+}
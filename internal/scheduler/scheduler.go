@@ -115,3 +115,17 @@ func (s *Scheduler) runTask(t models.ScheduledTask) {
 		return ""
 	}())
 }
+
+// Upcoming returns when expr fires after from and no later than until, at
+// most max times.
+func Upcoming(expr string, from, until time.Time, max int) ([]time.Time, error) {
+	sched, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, err
+	}
+	var out []time.Time
+	for t := sched.Next(from); !t.IsZero() && !t.After(until) && len(out) < max; t = sched.Next(t) {
+		out = append(out, t)
+	}
+	return out, nil
+}
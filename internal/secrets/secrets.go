@@ -0,0 +1,296 @@
+// Package secrets encrypts sensitive database columns at rest with
+// envelope encryption: values are sealed with AES-256-GCM under a data key,
+// and the data key is stored next to each value wrapped by a key
+// encryption key (KEK) kept outside the database, in a key file or a KMS.
+//
+// An encrypted value looks like
+//
+//	enc:v1:<kek id>:<wrapped data key>:<nonce and ciphertext>
+//
+// with both binary parts in unpadded base64. Values without the prefix are
+// plaintext from before a key was configured and are returned as they are.
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const prefix = "enc:v1:"
+
+var ErrNoKey = errors.New("value is encrypted but no secrets key is configured (--secrets-key-file or --secrets-kms-command)")
+
+var b64 = base64.RawStdEncoding
+
+// A KEK wraps and unwraps data keys.
+type KEK interface {
+	ID() string
+	Wrap(dek []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+}
+
+// Keyring encrypts with its first KEK and decrypts with any of them.
+type Keyring struct {
+	keks []KEK
+
+	mu      sync.Mutex
+	dek     []byte // data key for new values, wrapped once per process
+	wrapped string
+	unwrap  map[string][]byte // wrapped data key -> data key
+}
+
+func NewKeyring(keks ...KEK) (*Keyring, error) {
+	if len(keks) == 0 {
+		return nil, errors.New("no keys")
+	}
+	for _, k := range keks {
+		if k.ID() == "" || strings.Contains(k.ID(), ":") {
+			return nil, fmt.Errorf("invalid key id %q", k.ID())
+		}
+	}
+	return &Keyring{keks: keks, unwrap: make(map[string][]byte)}, nil
+}
+
+// Primary is the ID of the key new values are encrypted with.
+func (k *Keyring) Primary() string { return k.keks[0].ID() }
+
+func IsEncrypted(s string) bool { return strings.HasPrefix(s, prefix) }
+
+func (k *Keyring) Encrypt(plain string) (string, error) {
+	k.mu.Lock()
+	if k.dek == nil {
+		dek := make([]byte, 32)
+		if _, err := rand.Read(dek); err != nil {
+			k.mu.Unlock()
+			return "", err
+		}
+		wrapped, err := k.keks[0].Wrap(dek)
+		if err != nil {
+			k.mu.Unlock()
+			return "", fmt.Errorf("wrapping data key with %s: %w", k.keks[0].ID(), err)
+		}
+		k.dek, k.wrapped = dek, b64.EncodeToString(wrapped)
+		k.unwrap[k.keks[0].ID()+":"+k.wrapped] = dek
+	}
+	dek, wrapped := k.dek, k.wrapped
+	k.mu.Unlock()
+
+	sealed, err := seal(dek, []byte(plain))
+	if err != nil {
+		return "", err
+	}
+	return prefix + k.keks[0].ID() + ":" + wrapped + ":" + b64.EncodeToString(sealed), nil
+}
+
+func (k *Keyring) Decrypt(s string) (string, error) {
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(s, prefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+	id, wrapped := parts[0], parts[1]
+
+	k.mu.Lock()
+	dek, ok := k.unwrap[id+":"+wrapped]
+	k.mu.Unlock()
+	if !ok {
+		var kek KEK
+		for _, c := range k.keks {
+			if c.ID() == id {
+				kek = c
+			}
+		}
+		if kek == nil {
+			return "", fmt.Errorf("value is encrypted with key %q, which is not configured", id)
+		}
+		raw, err := b64.DecodeString(wrapped)
+		if err != nil {
+			return "", errors.New("malformed encrypted value")
+		}
+		if dek, err = kek.Unwrap(raw); err != nil {
+			return "", fmt.Errorf("unwrapping data key with %s: %w", id, err)
+		}
+		k.mu.Lock()
+		k.unwrap[id+":"+wrapped] = dek
+		k.mu.Unlock()
+	}
+
+	sealed, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+	plain, err := open(dek, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func seal(key, plain []byte) ([]byte, error) {
+	aead, err := gcm(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	aead, err := gcm(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("decryption failed: wrong key or corrupted value")
+	}
+	return plain, nil
+}
+
+func gcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// StaticKEK is a 256-bit key held in memory, as read from a key file.
+type StaticKEK struct {
+	KeyID string
+	Key   []byte
+}
+
+func (s StaticKEK) ID() string                            { return s.KeyID }
+func (s StaticKEK) Wrap(dek []byte) ([]byte, error)       { return seal(s.Key, dek) }
+func (s StaticKEK) Unwrap(wrapped []byte) ([]byte, error) { return open(s.Key, wrapped) }
+
+// GenerateKey returns a new key in key file format.
+func GenerateKey() (string, error) {
+	id := make([]byte, 4)
+	key := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x %s", id, base64.StdEncoding.EncodeToString(key)), nil
+}
+
+// ParseKeyFile reads keys, one "<id> <base64 key>" per line, first one
+// primary. Blank lines and lines starting with # are skipped.
+func ParseKeyFile(data []byte) ([]KEK, error) {
+	var keks []KEK
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want \"<id> <base64 key>\"", n)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("line %d: key must be 32 bytes of base64", n)
+		}
+		keks = append(keks, StaticKEK{KeyID: fields[0], Key: key})
+	}
+	if len(keks) == 0 {
+		return nil, errors.New("no keys in key file")
+	}
+	return keks, sc.Err()
+}
+
+// CommandKEK hands data keys to an external command, typically a KMS
+// client. The command runs through sh with BOOTIMUS_KMS_OP set to "wrap"
+// or "unwrap", reads a base64 key on stdin and writes the base64 result
+// to stdout.
+type CommandKEK struct {
+	KeyID   string
+	Command string
+}
+
+func (c CommandKEK) ID() string { return c.KeyID }
+
+func (c CommandKEK) Wrap(dek []byte) ([]byte, error) { return c.run("wrap", dek) }
+
+func (c CommandKEK) Unwrap(wrapped []byte) ([]byte, error) { return c.run("unwrap", wrapped) }
+
+func (c CommandKEK) run(op string, in []byte) ([]byte, error) {
+	cmd := exec.Command("sh", "-c", c.Command)
+	cmd.Env = append(os.Environ(), "BOOTIMUS_KMS_OP="+op)
+	cmd.Stdin = strings.NewReader(base64.StdEncoding.EncodeToString(in) + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("kms command: %w (%s)", err, strings.TrimSpace(stderr.String()))
+	}
+	res, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, fmt.Errorf("kms command: output is not base64: %w", err)
+	}
+	return res, nil
+}
+
+var (
+	defaultMu sync.RWMutex
+	def       *Keyring
+)
+
+// SetDefault sets the keyring database columns are encrypted with; nil
+// stores new values in plaintext.
+func SetDefault(k *Keyring) {
+	defaultMu.Lock()
+	def = k
+	defaultMu.Unlock()
+}
+
+func Default() *Keyring {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return def
+}
+
+// Encrypt encrypts with the default keyring, or returns plain as it is when
+// there is none.
+func Encrypt(plain string) (string, error) {
+	k := Default()
+	if k == nil || plain == "" {
+		return plain, nil
+	}
+	return k.Encrypt(plain)
+}
+
+// Decrypt decrypts with the default keyring.
+func Decrypt(s string) (string, error) {
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+	k := Default()
+	if k == nil {
+		return "", ErrNoKey
+	}
+	return k.Decrypt(s)
+}
@@ -0,0 +1,110 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+)
+
+func testKeys(t *testing.T, n int) []KEK {
+	t.Helper()
+	var lines []string
+	for i := 0; i < n; i++ {
+		line, err := GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	keks, err := ParseKeyFile([]byte("# bootimus secrets\n" + strings.Join(lines, "\n") + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keks
+}
+
+func TestRoundTrip(t *testing.T) {
+	k, err := NewKeyring(testKeys(t, 1)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := k.Encrypt("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(enc) || strings.Contains(enc, "hunter2") {
+		t.Fatalf("not encrypted: %q", enc)
+	}
+	enc2, _ := k.Encrypt("hunter2")
+	if enc == enc2 {
+		t.Error("same ciphertext twice; nonce reused")
+	}
+	if got, err := k.Decrypt(enc); err != nil || got != "hunter2" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+	if got, _ := k.Decrypt("plain"); got != "plain" {
+		t.Errorf("plaintext not passed through: %q", got)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	keys := testKeys(t, 2)
+	old, _ := NewKeyring(keys[1])
+	enc, _ := old.Encrypt("secret")
+
+	rotated, _ := NewKeyring(keys...)
+	if got, err := rotated.Decrypt(enc); err != nil || got != "secret" {
+		t.Fatalf("old value under rotated keyring: %q, %v", got, err)
+	}
+	reenc, _ := rotated.Encrypt("secret")
+	if !strings.HasPrefix(reenc, prefix+keys[0].ID()+":") {
+		t.Errorf("new values not under the primary key: %q", reenc)
+	}
+
+	onlyNew, _ := NewKeyring(keys[0])
+	if _, err := onlyNew.Decrypt(enc); err == nil {
+		t.Error("decrypted with a key that was dropped")
+	}
+}
+
+func TestTampered(t *testing.T) {
+	k, _ := NewKeyring(testKeys(t, 1)...)
+	enc, _ := k.Encrypt("secret")
+	last := enc[len(enc)-1]
+	flipped := byte('A')
+	if last == 'A' {
+		flipped = 'B'
+	}
+	if _, err := k.Decrypt(enc[:len(enc)-1] + string(flipped)); err == nil {
+		t.Error("tampered value decrypted")
+	}
+}
+
+func TestCommandKEK(t *testing.T) {
+	// A stand-in KMS that "wraps" by passing the key through.
+	k, _ := NewKeyring(CommandKEK{KeyID: "kms", Command: "cat"})
+	enc, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, _ := NewKeyring(CommandKEK{KeyID: "kms", Command: `test "$BOOTIMUS_KMS_OP" = unwrap && cat`})
+	if got, err := fresh.Decrypt(enc); err != nil || got != "secret" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	SetDefault(nil)
+	if got, _ := Encrypt("x"); got != "x" {
+		t.Errorf("encrypted without a keyring: %q", got)
+	}
+	k, _ := NewKeyring(testKeys(t, 1)...)
+	enc, _ := k.Encrypt("x")
+	if _, err := Decrypt(enc); err != ErrNoKey {
+		t.Errorf("err = %v, want ErrNoKey", err)
+	}
+	SetDefault(k)
+	defer SetDefault(nil)
+	if got, err := Decrypt(enc); err != nil || got != "x" {
+		t.Errorf("Decrypt = %q, %v", got, err)
+	}
+}
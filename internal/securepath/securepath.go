@@ -0,0 +1,132 @@
+// Package securepath confines untrusted, client-supplied paths to a root
+// directory. Every handler that maps a URL or TFTP filename onto the
+// filesystem should go through Join rather than checking prefixes itself.
+package securepath
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrInvalid     = errors.New("invalid path")
+	ErrOutsideRoot = errors.New("path escapes root")
+)
+
+// Clean canonicalises an untrusted relative path. Backslashes count as
+// separators (some PXE firmware sends them), leading slashes are dropped,
+// and any ".." component or NUL byte is refused outright rather than being
+// resolved, so "a/../b" is rejected too. The result uses OS separators.
+func Clean(p string) (string, error) {
+	if strings.ContainsRune(p, 0) {
+		return "", ErrInvalid
+	}
+	p = strings.ReplaceAll(p, `\`, "/")
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return "", ErrOutsideRoot
+		}
+	}
+	p = strings.TrimLeft(path.Clean("/"+p), "/")
+	if p == "" {
+		return ".", nil
+	}
+	return filepath.FromSlash(p), nil
+}
+
+// Within reports whether target is root itself or lies below it. Both must
+// already be clean; the check is separator-aware, so /data/isos2 is not
+// within /data/isos.
+func Within(root, target string) bool {
+	if target == root {
+		return true
+	}
+	if !strings.HasSuffix(root, string(os.PathSeparator)) {
+		root += string(os.PathSeparator)
+	}
+	return strings.HasPrefix(target, root)
+}
+
+// Join cleans p and joins it to root, then resolves symlinks on the longest
+// existing part of the result and checks that it still lies within root.
+// The returned path is the joined (unresolved) path; the target need not
+// exist, so Join also works for files about to be created.
+func Join(root, p string) (string, error) {
+	rel, err := Clean(p)
+	if err != nil {
+		return "", err
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	full := filepath.Join(absRoot, rel)
+	if !Within(absRoot, full) {
+		return "", ErrOutsideRoot
+	}
+
+	realRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return full, nil
+		}
+		return "", err
+	}
+	existing, rest := full, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !Within(realRoot, filepath.Join(resolved, rest)) {
+				return "", ErrOutsideRoot
+			}
+			return full, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if existing == absRoot {
+			return full, nil
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+	}
+}
+
+// Serve is an http handler that receives an already-confined file path and
+// its cleaned, slash-separated form relative to the root.
+type Serve func(w http.ResponseWriter, r *http.Request, rel, full string)
+
+// Handler strips prefix from the (already percent-decoded) request path,
+// confines the remainder to root and hands it to next. Rejected requests get
+// 403; onReject, if set, is told about them first, e.g. to log the client.
+func Handler(prefix, root string, onReject func(r *http.Request, rel string, err error), next Serve) http.HandlerFunc {
+	return JoinHandler(prefix, func(rel string) (string, error) { return Join(root, rel) }, onReject, next)
+}
+
+// JoinHandler is Handler with the confinement left to join, for content
+// spread over several roots. join must refuse anything Join would.
+func JoinHandler(prefix string, join func(rel string) (string, error), onReject func(r *http.Request, rel string, err error), next Serve) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rel := strings.TrimPrefix(r.URL.Path, prefix)
+		full, err := join(rel)
+		if err == nil {
+			rel, err = Clean(rel)
+			rel = filepath.ToSlash(rel)
+		}
+		if err != nil {
+			if onReject != nil {
+				onReject(r, rel, err)
+			} else {
+				log.Printf("securepath: rejected %q from %s: %v", rel, r.RemoteAddr, err)
+			}
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r, rel, full)
+	}
+}
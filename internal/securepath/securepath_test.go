@@ -0,0 +1,188 @@
+package securepath
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"ubuntu.iso", "ubuntu.iso", nil},
+		{"/ubuntu/casper/vmlinuz", "ubuntu/casper/vmlinuz", nil},
+		{"//a///b/./c", "a/b/c", nil},
+		{"", ".", nil},
+		{"/", ".", nil},
+		{`win\boot\bcd`, "win/boot/bcd", nil},
+		{"..", "", ErrOutsideRoot},
+		{"../etc/passwd", "", ErrOutsideRoot},
+		{"a/../../b", "", ErrOutsideRoot},
+		{"a/../b", "", ErrOutsideRoot},
+		{`..\..\windows\win.ini`, "", ErrOutsideRoot},
+		{`a\..\..\b`, "", ErrOutsideRoot},
+		{"/../../etc/shadow", "", ErrOutsideRoot},
+		{"file.iso\x00.txt", "", ErrInvalid},
+		{"...", "...", nil},
+		{"..foo/bar..", "..foo/bar..", nil},
+	}
+	for _, tt := range tests {
+		got, err := Clean(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Clean(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && filepath.ToSlash(got) != tt.want {
+			t.Errorf("Clean(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		root, target string
+		want         bool
+	}{
+		{"/data/isos", "/data/isos", true},
+		{"/data/isos", "/data/isos/a.iso", true},
+		{"/data/isos", "/data/isos2", false},
+		{"/data/isos", "/data/isos2/a.iso", false},
+		{"/data/isos", "/data", false},
+		{"/", "/etc", true},
+	}
+	for _, tt := range tests {
+		if got := Within(tt.root, tt.target); got != tt.want {
+			t.Errorf("Within(%q, %q) = %v, want %v", tt.root, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestJoinSymlinks(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "isos")
+	outside := filepath.Join(base, "secret")
+	for _, d := range []string{filepath.Join(root, "ubuntu"), outside} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "key"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "key"), filepath.Join(root, "key")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "ubuntu"), filepath.Join(root, "latest")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		p  string
+		ok bool
+	}{
+		{"ubuntu", true},
+		{"ubuntu/not-yet-created.iso", true},
+		{"latest/casper/vmlinuz", true},
+		{"escape", false},
+		{"escape/key", false},
+		{"escape/missing/file", false},
+		{"key", false},
+		{"../secret/key", false},
+	}
+	for _, tt := range tests {
+		full, err := Join(root, tt.p)
+		if tt.ok && err != nil {
+			t.Errorf("Join(%q) unexpected error: %v", tt.p, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("Join(%q) = %q, want error", tt.p, full)
+		}
+	}
+}
+
+func TestJoinSymlinkedRoot(t *testing.T) {
+	base := t.TempDir()
+	real := filepath.Join(base, "real")
+	if err := os.MkdirAll(real, 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(base, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if _, err := Join(link, "a.iso"); err != nil {
+		t.Errorf("Join under symlinked root: %v", err)
+	}
+}
+
+func TestHandlerEncodings(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "ubuntu"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotRel string
+	var rejected bool
+	h := Handler("/isos/", root,
+		func(r *http.Request, rel string, err error) { rejected = true },
+		func(w http.ResponseWriter, r *http.Request, rel, full string) {
+			gotRel = rel
+			if !Within(root, full) {
+				t.Errorf("full path %q escapes %q", full, root)
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+
+	tests := []struct {
+		target  string
+		status  int
+		wantRel string
+	}{
+		{"/isos/ubuntu/vmlinuz", http.StatusOK, "ubuntu/vmlinuz"},
+		{"/isos/ubuntu%20server.iso", http.StatusOK, "ubuntu server.iso"},
+		{"/isos/%2e%2e/etc/passwd", http.StatusForbidden, ""},
+		{"/isos/..%2fetc/passwd", http.StatusForbidden, ""},
+		{"/isos/ubuntu/..%5c..%5cetc", http.StatusForbidden, ""},
+		{"/isos/a%00.iso", http.StatusForbidden, ""},
+		// Decoded exactly once: %252e%252e is the literal name "%2e%2e".
+		{"/isos/%252e%252e/etc/passwd", http.StatusOK, "%2e%2e/etc/passwd"},
+	}
+	for _, tt := range tests {
+		gotRel, rejected = "", false
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.RawPath = ""
+		req.URL.Path = mustUnescape(t, tt.target)
+		rec := httptest.NewRecorder()
+		h(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.target, rec.Code, tt.status)
+		}
+		if rejected != (tt.status == http.StatusForbidden) {
+			t.Errorf("%s: onReject called = %v", tt.target, rejected)
+		}
+		if gotRel != tt.wantRel {
+			t.Errorf("%s: rel %q, want %q", tt.target, gotRel, tt.wantRel)
+		}
+	}
+}
+
+// mustUnescape decodes a request target the way net/http does before it
+// reaches a handler.
+func mustUnescape(t *testing.T, target string) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "http://bootimus"+target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req.URL.Path
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"bootimus/internal/accesslog"
+	"bootimus/internal/logsink"
+)
+
+// newAccessLog opens the boot HTTP port's access log, falling back to the
+// server log when its file can't be opened.
+func (s *Server) newAccessLog() *accesslog.Logger {
+	cfg := accesslog.Config{
+		Dest:        s.config.AccessLog,
+		RangeSample: s.config.AccessLogRangeSample,
+		MAC: func(r *http.Request) string {
+			if mac := requestMAC(r); mac != "unknown" {
+				return mac
+			}
+			return ""
+		},
+	}
+	if s.config.AccessLogToSinks && s.logSinks.Len() > 0 {
+		cfg.Publish = func(e accesslog.Entry) {
+			s.logSinks.Publish(logsink.Entry{
+				Time: e.Time, Kind: logsink.KindAccess, MAC: e.MAC, IP: e.IP, Success: e.Status < 400,
+				Method: e.Method, Path: e.Path, Status: e.Status, Bytes: e.Bytes, DurationMs: e.Duration,
+			})
+		}
+	}
+	l, err := accesslog.New(cfg)
+	if err != nil {
+		log.Printf("Access log: %v; writing to the server log instead", err)
+		cfg.Dest = accesslog.DestLog
+		l, _ = accesslog.New(cfg)
+	}
+	return l
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"bootimus/internal/auth"
+)
+
+// AdminTLSConfig serves the admin UI and API over HTTPS, optionally
+// authenticating users by client certificate.
+type AdminTLSConfig struct {
+	CertFile    string                `mapstructure:"cert_file"`
+	KeyFile     string                `mapstructure:"key_file"`
+	ClientCerts auth.ClientCertConfig `mapstructure:"client_certs"`
+}
+
+func (c AdminTLSConfig) enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// serverConfig loads the certificate and, when client certificates are
+// configured, the CAs they must chain to.
+func (c AdminTLSConfig) serverConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("admin TLS certificate: %w", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if c.ClientCerts.CAFile != "" {
+		if cfg.ClientCAs, err = c.ClientCerts.Pool(); err != nil {
+			return nil, fmt.Errorf("admin client CA: %w", err)
+		}
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if c.ClientCerts.Required {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"log"
+	"strconv"
+
+	"bootimus/internal/alerts"
+	"bootimus/internal/logsink"
+	"bootimus/internal/webhook"
+)
+
+// logBootAttempt writes the boot log and forwards the entry to any
+// configured log sinks.
+func (s *Server) logBootAttempt(mac, image, ip string, success bool, errorMsg string) {
+	if s.config.Storage != nil {
+		if err := s.config.Storage.LogBootAttempt(mac, image, ip, success, errorMsg); err != nil {
+			log.Printf("Boot log: failed to write for %s: %v", mac, err)
+		}
+	}
+	s.logSinks.Publish(logsink.Entry{MAC: mac, Image: image, IP: ip, Success: success, Error: errorMsg})
+}
+
+// recordBootFailure logs a failed boot or install and counts it towards the
+// failure alerts.
+func (s *Server) recordBootFailure(mac, image, ip, reason string) {
+	s.logBootAttempt(mac, image, ip, false, reason)
+	s.alerts.BootFailed(mac, image)
+}
+
+func (s *Server) raiseAlert(a alerts.Alert) {
+	s.logAndBroadcast("Alert: %s", a.Message)
+	ev := webhook.Event{
+		Event: webhook.EventAlert,
+		MAC:   a.MAC,
+		Image: a.Image,
+		Metadata: map[string]string{
+			"kind":    a.Kind,
+			"count":   strconv.Itoa(a.Count),
+			"window":  a.Window.String(),
+			"message": a.Message,
+		},
+	}
+	if a.Total > 0 {
+		ev.Metadata["total"] = strconv.Itoa(a.Total)
+	}
+	s.webhookNotifier.Fire(ev)
+}
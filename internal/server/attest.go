@@ -0,0 +1,366 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"bootimus/internal/attest"
+	"bootimus/internal/models"
+)
+
+const maxEvidenceSize = 1 << 20
+
+// defaultAttestationTTL is how long a passing attestation counts when
+// Config.AttestationTTL is unset.
+const defaultAttestationTTL = time.Hour
+
+var pcrListRe = regexp.MustCompile(`^[0-9]+(,[0-9]+)*$`)
+
+const attestEnrollScript = `#!/bin/sh
+# Bootimus TPM enrollment: quotes the PCRs with an attestation key held
+# under the TPM's endorsement key and submits the quote. Needs tpm2-tools
+# and curl.
+set -e
+SERVER="@SERVER@"
+MAC="@MAC@"
+PCRS="@PCRS@"
+AK_HANDLE="${BOOTIMUS_AK_HANDLE:-0x81010002}"
+WORK=$(mktemp -d)
+cd "$WORK"
+
+tpm2_createek -c ek.ctx -G rsa -u ek.pub >/dev/null
+tpm2_readpublic -c ek.ctx -f pem -o ek.pem >/dev/null
+if ! tpm2_readpublic -c "$AK_HANDLE" -f pem -o ak.pem >/dev/null 2>&1; then
+	tpm2_createak -C ek.ctx -c ak.ctx -G ecc -g sha256 -s ecdsa >/dev/null
+	tpm2_evictcontrol -C o -c ak.ctx "$AK_HANDLE" >/dev/null
+	tpm2_readpublic -c "$AK_HANDLE" -f pem -o ak.pem >/dev/null
+fi
+
+NONCE=$(curl -fsS "$SERVER/api/attest/nonce?mac=$MAC")
+tpm2_quote -c "$AK_HANDLE" -l "sha256:$PCRS" -q "$NONCE" -g sha256 \
+	-m quote.msg -s quote.sig -f plain -o pcrs.bin -F values >/dev/null
+
+EK=$(awk '{printf "%s\\n", $0}' ek.pem)
+AK=$(awk '{printf "%s\\n", $0}' ak.pem)
+printf '{"mac":"%s","nonce":"%s","ek_pub":"%s","ak_pub":"%s","quote":"%s","signature":"%s","pcr_values":"%s"}' \
+	"$MAC" "$NONCE" "$EK" "$AK" "$(base64 -w0 quote.msg)" "$(base64 -w0 quote.sig)" "$(base64 -w0 pcrs.bin)" > evidence.json
+curl -fsS -H "Content-Type: application/json" --data-binary @evidence.json "$SERVER/api/attest"
+echo
+rm -rf "$WORK"
+`
+
+// handleAttestScript serves the enrollment script an enrollment image runs
+// to attest itself.
+func (s *Server) handleAttestScript(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	mac := strings.ToLower(strings.ReplaceAll(q.Get("mac"), "-", ":"))
+	if _, err := net.ParseMAC(mac); err != nil {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	pcrs := q.Get("pcrs")
+	if pcrs == "" {
+		pcrs = "0,1,2,3,4,5,6,7"
+	}
+	if !pcrListRe.MatchString(pcrs) {
+		http.Error(w, "Invalid PCR list", http.StatusBadRequest)
+		return
+	}
+
+	script := strings.NewReplacer(
+		"@SERVER@", fmt.Sprintf("http://%s:%d", s.config.ServerAddr, s.config.HTTPPort),
+		"@MAC@", mac,
+		"@PCRS@", pcrs,
+	).Replace(attestEnrollScript)
+
+	log.Printf("Attestation: enrollment script served to %s (%s)", mac, r.RemoteAddr)
+	w.Header().Set("Content-Type", "text/x-shellscript; charset=utf-8")
+	w.Write([]byte(script))
+}
+
+// handleAttestNonce issues the challenge the next quote must carry.
+func (s *Server) handleAttestNonce(w http.ResponseWriter, r *http.Request) {
+	mac := strings.ToLower(strings.ReplaceAll(r.URL.Query().Get("mac"), "-", ":"))
+	if _, err := net.ParseMAC(mac); err != nil {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(s.attestNonces.Issue(mac)))
+}
+
+// handleAttest verifies a TPM quote and updates the client's trust state.
+func (s *Server) handleAttest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config.Storage == nil {
+		http.Error(w, "Attestation requires database", http.StatusServiceUnavailable)
+		return
+	}
+
+	var ev attest.Evidence
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxEvidenceSize)).Decode(&ev); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid evidence: %v", err), http.StatusBadRequest)
+		return
+	}
+	mac := strings.ToLower(strings.ReplaceAll(ev.MAC, "-", ":"))
+	if _, err := net.ParseMAC(mac); err != nil {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	nonce, ok := s.attestNonces.Consume(mac, ev.Nonce)
+	if !ok {
+		http.Error(w, "Nonce unknown, used or expired", http.StatusForbidden)
+		return
+	}
+	client, err := s.config.Storage.GetClient(mac)
+	if err != nil {
+		http.Error(w, "Unknown client; boot it from Bootimus first", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	res, err := attest.Verify(&ev, nonce)
+	if err == nil {
+		err = applyAttestation(client, res, now)
+	}
+	if err != nil {
+		// Kept apart from the trust state, so a quote anyone could have sent
+		// can't take trust away from an approved machine.
+		client.AttestFailures++
+		client.AttestLastFailure = err.Error()
+		client.AttestFailedAt = &now
+	}
+	if saveErr := s.config.Storage.UpdateClientAttestation(mac, client); saveErr != nil {
+		http.Error(w, saveErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	resp := map[string]interface{}{
+		"status":  client.AttestStatus,
+		"trusted": client.Trusted,
+		"error":   errString(err),
+	}
+	switch {
+	case err != nil:
+		status = http.StatusForbidden
+		if !client.Trusted {
+			s.attestSessions.Revoke(mac)
+		}
+		s.logAndBroadcast("Attestation: %s failed: %v", mac, err)
+	case client.Trusted:
+		sess := s.attestSessions.Issue(mac, hostOnly(r.RemoteAddr), now.Add(s.attestationTTL()))
+		resp["session"] = sess.Token
+		resp["expires_at"] = sess.Expires
+		s.logAndBroadcast("Attestation: %s is %s (EK %s), session valid until %s", mac, client.AttestStatus, shortHash(client.AttestEKHash), sess.Expires.Format(time.RFC3339))
+	default:
+		s.logAndBroadcast("Attestation: %s is %s (EK %s)", mac, client.AttestStatus, shortHash(client.AttestEKHash))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// applyAttestation decides what a verified quote means for the client. Until
+// an admin approves, every quote (re)records the baseline as pending. After
+// approval, a quote from other keys is rejected without touching the client:
+// nothing ties it to the machine. A quote from the approved AK whose PCRs
+// differ from the baseline withdraws trust.
+func applyAttestation(c *models.Client, res *attest.Result, now time.Time) error {
+	if !c.AttestApproved || c.AttestEKHash == "" {
+		c.AttestedAt = &now
+		pcrs, err := json.Marshal(res.PCRs)
+		if err != nil {
+			return err
+		}
+		c.AttestEKHash = res.EKHash
+		c.AttestAKHash = res.AKHash
+		c.AttestPCRs = string(pcrs)
+		c.AttestApproved = false
+		c.Trusted = false
+		c.AttestStatus = "pending"
+		c.AttestError = ""
+		return nil
+	}
+
+	if res.EKHash != c.AttestEKHash {
+		return errors.New("endorsement key differs from the approved one")
+	}
+	if res.AKHash != c.AttestAKHash {
+		return errors.New("attestation key differs from the approved one")
+	}
+	c.AttestedAt = &now
+	err := matchBaseline(c, res)
+	if err != nil {
+		c.Trusted = false
+		c.AttestStatus = "failed"
+		c.AttestError = err.Error()
+		return err
+	}
+	c.Trusted = true
+	c.AttestStatus = "trusted"
+	c.AttestError = ""
+	return nil
+}
+
+func matchBaseline(c *models.Client, res *attest.Result) error {
+	var baseline map[int]string
+	if err := json.Unmarshal([]byte(c.AttestPCRs), &baseline); err != nil {
+		return fmt.Errorf("stored PCR baseline is unreadable: %w", err)
+	}
+	indices := make([]int, 0, len(baseline))
+	for pcr := range baseline {
+		indices = append(indices, pcr)
+	}
+	sort.Ints(indices)
+	for _, pcr := range indices {
+		got, ok := res.PCRs[pcr]
+		if !ok {
+			return fmt.Errorf("PCR %d was not quoted", pcr)
+		}
+		if got != baseline[pcr] {
+			return fmt.Errorf("PCR %d does not match the approved baseline", pcr)
+		}
+	}
+	return nil
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func shortHash(h string) string {
+	if len(h) > 16 {
+		return h[:16]
+	}
+	return h
+}
+
+// attestationTTL is how long a passing attestation counts.
+func (s *Server) attestationTTL() time.Duration {
+	if s.config.AttestationTTL > 0 {
+		return s.config.AttestationTTL
+	}
+	return defaultAttestationTTL
+}
+
+// clientTrusted reports whether mac is approved and passed attestation
+// within the TTL.
+func (s *Server) clientTrusted(mac string) bool {
+	if s.config.Storage == nil {
+		return false
+	}
+	c, err := s.config.Storage.GetClient(mac)
+	if err != nil || !c.Trusted || c.AttestedAt == nil {
+		return false
+	}
+	return time.Since(*c.AttestedAt) <= s.attestationTTL()
+}
+
+type attestSessionKey struct{}
+
+// trustedSession returns the token of the attestation session r belongs to:
+// the one its URL carries under /trusted/, or else the one issued to mac at
+// r's address. It is "" unless that session's client is still trusted.
+func (s *Server) trustedSession(r *http.Request, mac string) string {
+	sess, ok := r.Context().Value(attestSessionKey{}).(attest.Session)
+	if !ok {
+		sess, ok = s.attestSessions.ForClient(mac, hostOnly(r.RemoteAddr))
+	}
+	if !ok || sess.MAC != mac || !s.clientTrusted(sess.MAC) {
+		return ""
+	}
+	return sess.Token
+}
+
+// handleTrusted serves /trusted/<token>/<path> as <path>, on behalf of the
+// session the token belongs to. Menus put private images' URLs under it,
+// so downloading them needs the session rather than a claimed MAC.
+func (s *Server) handleTrusted(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/trusted/"), "/")
+		sess, ok := s.attestSessions.Get(token)
+		if !ok {
+			s.logAndBroadcast("Attestation: unknown or expired session for %s (IP: %s)", rest, r.RemoteAddr)
+			http.Error(w, "Attestation session unknown or expired", http.StatusForbidden)
+			return
+		}
+		r = r.Clone(context.WithValue(r.Context(), attestSessionKey{}, sess))
+		r.URL.Path = "/" + rest
+		r.URL.RawPath = ""
+		mux.ServeHTTP(w, r)
+	}
+}
+
+// allowImageFile reports whether r may download rel from /isos/ or /boot/.
+// With attestation required, files that only private images use need a
+// trusted session; anything else stays open for firmware.
+func (s *Server) allowImageFile(r *http.Request, kind, rel string) bool {
+	if !s.config.RequireAttestation || s.config.Storage == nil {
+		return true
+	}
+	images, err := s.config.Storage.ListImages()
+	if err != nil {
+		log.Printf("Attestation: can't check access to %s: %v", rel, err)
+		return false
+	}
+	private := false
+	for _, img := range images {
+		name := img.DiskFilename()
+		if kind == "boot" {
+			name = strings.TrimSuffix(name, filepath.Ext(name)) + "/"
+		}
+		if rel != name && !(kind == "boot" && strings.HasPrefix(rel, name)) {
+			continue
+		}
+		if img.Public {
+			return true
+		}
+		private = true
+	}
+	if !private {
+		return true
+	}
+	sess, ok := r.Context().Value(attestSessionKey{}).(attest.Session)
+	if ok && s.clientTrusted(sess.MAC) {
+		return true
+	}
+	s.logAndBroadcast("Attestation: refused private %s file %s to untrusted MAC %s (IP: %s)", kind, rel, requestMAC(r), r.RemoteAddr)
+	return false
+}
+
+// withholdUntrusted drops private images from the list when attestation is
+// required and the client is not trusted.
+func (s *Server) withholdUntrusted(mac string, trusted bool, images []models.Image) []models.Image {
+	if !s.config.RequireAttestation || trusted {
+		return images
+	}
+	kept := make([]models.Image, 0, len(images))
+	for _, img := range images {
+		if img.Public {
+			kept = append(kept, img)
+		}
+	}
+	if withheld := len(images) - len(kept); withheld > 0 {
+		log.Printf("Attestation: withholding %d private image(s) from untrusted client %s", withheld, mac)
+	}
+	return kept
+}
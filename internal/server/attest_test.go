@@ -0,0 +1,101 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bootimus/internal/attest"
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+)
+
+func TestPrivateImageFilesNeedTrustedSession(t *testing.T) {
+	store, err := storage.NewSQLiteStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, img := range []*models.Image{
+		{Name: "Private", Filename: "private.iso"},
+		{Name: "Public", Filename: "public.iso", Public: true},
+	} {
+		if err := store.CreateImage(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const mac = "52:54:00:12:34:56"
+	attested := time.Now()
+	if err := store.CreateClient(&models.Client{MACAddress: mac, Trusted: true, AttestApproved: true, AttestedAt: &attested}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		config:         &Config{Storage: store, RequireAttestation: true, AttestationTTL: time.Hour},
+		attestSessions: attest.NewSessions(),
+	}
+	mux := http.NewServeMux()
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		kind, rel := "boot", r.URL.Path[len("/boot/"):]
+		if r.URL.Path[:6] == "/isos/" {
+			kind, rel = "iso", r.URL.Path[len("/isos/"):]
+		}
+		if !s.allowImageFile(r, kind, rel) {
+			http.Error(w, "Attestation required", http.StatusForbidden)
+		}
+	}
+	mux.HandleFunc("/isos/", serve)
+	mux.HandleFunc("/boot/", serve)
+	mux.HandleFunc("/trusted/", s.handleTrusted(mux))
+	sess := s.attestSessions.Issue(mac, "192.0.2.10", attested.Add(time.Hour))
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path+"?mac="+mac, nil))
+		return rec.Code
+	}
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{"/isos/public.iso", http.StatusOK},
+		{"/boot/public/vmlinuz", http.StatusOK},
+		{"/isos/private.iso", http.StatusForbidden},
+		{"/boot/private/vmlinuz", http.StatusForbidden},
+		{"/trusted/" + sess.Token + "/isos/private.iso", http.StatusOK},
+		{"/trusted/" + sess.Token + "/boot/private/vmlinuz", http.StatusOK},
+		{"/trusted/wrong/isos/private.iso", http.StatusForbidden},
+	} {
+		if got := get(tc.path); got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.path, got, tc.want)
+		}
+	}
+
+	// The menu finds the session from the address it was issued to, not
+	// from the MAC alone.
+	menuReq := func(ip string) *http.Request {
+		r := httptest.NewRequest("GET", "/menu.ipxe?mac="+mac, nil)
+		r.RemoteAddr = ip + ":1234"
+		return r
+	}
+	if got := s.trustedSession(menuReq("192.0.2.10"), mac); got != sess.Token {
+		t.Errorf("menu from the attested address got session %q", got)
+	}
+	if got := s.trustedSession(menuReq("192.0.2.99"), mac); got != "" {
+		t.Errorf("menu claiming the MAC from another address got session %q", got)
+	}
+
+	// Trust lapses with the TTL, even while the session is live.
+	stale := time.Now().Add(-2 * time.Hour)
+	client, _ := store.GetClient(mac)
+	client.AttestedAt = &stale
+	if err := store.UpdateClientAttestation(mac, client); err != nil {
+		t.Fatal(err)
+	}
+	if got := get("/trusted/" + sess.Token + "/isos/private.iso"); got != http.StatusForbidden {
+		t.Errorf("stale attestation: got %d, want 403", got)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"bootimus/internal/models"
+)
+
+// Installers can also fetch their script by MAC, at the path their own
+// documentation expects. The image is the one named by ?image=, which the
+// menu always adds, or else the last one the client booted.
+var autoInstallPaths = []struct {
+	prefix, suffix, scriptType string
+}{
+	{"/preseed/", ".cfg", "preseed"},
+	{"/ks/", ".cfg", "kickstart"},
+	{"/unattend/", ".xml", "autounattend"},
+}
+
+func validMAC(s string) bool {
+	_, err := net.ParseMAC(s)
+	return err == nil
+}
+
+// autoInstallPathURL is where the installer fetches img's script, or ""
+// when its script type has no path of its own or the client's MAC isn't
+// known, as for an unregistered client.
+func (mb *MenuBuilder) autoInstallPathURL(img *models.Image, baseURL string) string {
+	if !validMAC(mb.macAddress) {
+		return ""
+	}
+	for _, p := range autoInstallPaths {
+		if p.scriptType == img.AutoInstallScriptType {
+			u := fmt.Sprintf("%s%s%s%s?image=%s", baseURL, p.prefix, mb.macAddress, p.suffix, url.QueryEscape(img.Filename))
+			if mb.bootToken != "" {
+				u += "&token=" + url.QueryEscape(mb.bootToken)
+			}
+			return u
+		}
+	}
+	return ""
+}
+
+func (s *Server) registerAutoInstallPaths(mux *http.ServeMux) {
+	for _, p := range autoInstallPaths {
+		mux.HandleFunc(p.prefix, s.requireBootToken(func(w http.ResponseWriter, r *http.Request) {
+			mac, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, p.prefix), p.suffix)
+			if !ok || (mac != "" && !validMAC(mac)) {
+				http.NotFound(w, r)
+				return
+			}
+			s.serveAutoInstallByMAC(w, r, mac, p.scriptType)
+		}))
+	}
+}
+
+// handleNoCloudByMAC serves /autoinstall/<mac>/ as a NoCloud seed for
+// Subiquity.
+func (s *Server) handleNoCloudByMAC(w http.ResponseWriter, r *http.Request, mac, part string) {
+	switch part {
+	case "meta-data":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "instance-id: bootimus-%s\n", strings.ReplaceAll(strings.ToLower(mac), ":", ""))
+	case "user-data":
+		s.serveAutoInstallByMAC(w, r, mac, "autoinstall")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveAutoInstallByMAC(w http.ResponseWriter, r *http.Request, mac, scriptType string) {
+	if s.config.Storage == nil {
+		http.Error(w, "Auto-install requires database", http.StatusInternalServerError)
+		return
+	}
+	image, err := s.autoInstallImageFor(r, mac)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s.serveAutoInstall(w, r, image, mac, scriptType)
+}
+
+func (s *Server) autoInstallImageFor(r *http.Request, mac string) (*models.Image, error) {
+	if filename := r.URL.Query().Get("image"); filename != "" {
+		image, err := s.config.Storage.GetImage(filename)
+		if err != nil || image == nil {
+			return nil, errors.New("Image not found")
+		}
+		return image, nil
+	}
+	if mac == "" {
+		return nil, errors.New("Missing MAC address or image")
+	}
+	logs, err := s.config.Storage.GetBootLogsByMAC(strings.ToLower(strings.ReplaceAll(mac, "-", ":")), 10)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range logs {
+		if l.Success && l.Image != nil && l.Image.AutoInstallEnabled {
+			return l.Image, nil
+		}
+	}
+	return nil, errors.New("Client has not booted an image with auto-install enabled")
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"bootimus/internal/autoinstall"
+	"bootimus/internal/models"
+)
+
+// secretAutoInstallVars are masked in previews; the real values are only
+// ever sent to the installing client.
+var secretAutoInstallVars = map[string]bool{
+	"{{DEFAULT_PASSWORD_HASH}}": true,
+}
+
+var (
+	unresolvedVarRe = regexp.MustCompile(`\{\{[A-Z_]+\}\}`)
+	cryptHashRe     = regexp.MustCompile(`\$(1|2[aby]?|5|6|y|gy)\$[^\s"'<]+`)
+)
+
+const maskedValue = "********"
+
+// handleAutoInstallPreview renders the script a client would receive, through
+// the same path as serveAutoInstall, then masks the secrets, so it can be
+// checked before a real install.
+func (s *Server) handleAutoInstallPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config.Storage == nil {
+		http.Error(w, "Auto-install requires database", http.StatusInternalServerError)
+		return
+	}
+
+	image, err := s.config.Storage.GetImage(r.URL.Query().Get("filename"))
+	if err != nil || image == nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	mac := strings.ToLower(strings.ReplaceAll(r.URL.Query().Get("mac"), "-", ":"))
+	var client *models.Client
+	if mac != "" {
+		if c, err := s.config.Storage.GetClient(mac); err == nil {
+			client = c
+		}
+	}
+
+	script, scriptType, source, err := s.resolveAutoInstallScript(image, client)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	clientIP := r.URL.Query().Get("ip")
+	if clientIP == "" {
+		clientIP = "<client-ip>"
+	}
+	vars := s.autoInstallVars(image, client, mac, clientIP)
+	var problems []autoinstall.ScriptError
+	if rendered, err := s.buildAutoInstall(script, scriptType, image, client, vars); err != nil {
+		problems = append(problems, autoinstall.ScriptError{Message: err.Error()})
+	} else {
+		script = rendered
+	}
+	script = maskAutoInstallSecrets(script, vars, imageSecretVars(image))
+
+	unresolved := unresolvedVarRe.FindAllString(script, -1)
+	sort.Strings(unresolved)
+	unresolved = dedupSorted(unresolved)
+
+	if problems == nil {
+		problems = autoinstall.ValidateScript(scriptType, script)
+	}
+
+	clientName := ""
+	if client != nil {
+		clientName = client.Name
+	}
+	log.Printf("Auto-install preview for %s (mac: %s, source: %s)", image.Filename, mac, source)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"script":      script,
+			"script_type": scriptType,
+			"source":      source,
+			"mac":         mac,
+			"client_name": clientName,
+			"known":       client != nil,
+			"variables":   vars,
+			"unresolved":  unresolved,
+			"problems":    problems,
+		},
+	})
+}
+
+// maskAutoInstallSecrets hides the secret values in a rendered script, and
+// in vars, along with any crypt(3) hash the script carries.
+func maskAutoInstallSecrets(script string, vars, imageSecrets map[string]string) string {
+	// Hashes keep their algorithm prefix, so they go first.
+	script = cryptHashRe.ReplaceAllStringFunc(script, func(h string) string {
+		return h[:strings.Index(h[1:], "$")+2] + maskedValue
+	})
+	var secrets []string
+	for k, v := range vars {
+		_, imageSecret := imageSecrets[k]
+		if (secretAutoInstallVars[k] || imageSecret) && v != "" {
+			secrets = append(secrets, v)
+			vars[k] = maskedValue
+		}
+	}
+	// Longest first, so a secret containing another is masked whole.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, v := range secrets {
+		script = strings.ReplaceAll(script, v, maskedValue)
+	}
+	return script
+}
+
+func dedupSorted(in []string) []string {
+	out := in[:0]
+	for i, v := range in {
+		if i == 0 || v != in[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
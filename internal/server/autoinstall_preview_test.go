@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+)
+
+func TestAutoInstallPreviewMatchesServed(t *testing.T) {
+	store, err := storage.NewSQLiteStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	image := &models.Image{
+		Name:                  "Cloud",
+		Filename:              "cloud.iso",
+		AutoInstallEnabled:    true,
+		AutoInstallScriptType: "autoinstall",
+		AutoInstallScript:     "#cloud-config\nhostname: {{HOSTNAME}}\npackages:\n  - curl\n",
+	}
+	if err := store.CreateImage(image); err != nil {
+		t.Fatal(err)
+	}
+	const mac = "52:54:00:12:34:56"
+	if err := store.CreateClient(&models.Client{MACAddress: mac, Name: "web1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateAccessConfig(&models.AccessConfig{
+		SSHAuthorizedKeys:   models.StringSlice{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAITest ops@example"},
+		DefaultUsername:     "ops",
+		DefaultPasswordHash: "$6$saltsalt$hashhashhash",
+		DefaultShell:        "/bin/bash",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{config: &Config{Storage: store, ServerAddr: "192.0.2.1", HTTPPort: 8080}}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/autoinstall/cloud.iso?mac="+mac, nil)
+	req.RemoteAddr = "192.0.2.50:40000"
+	s.serveAutoInstall(rec, req, image, mac, "")
+	served := rec.Body.String()
+	if !strings.Contains(served, "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAITest") || !strings.Contains(served, "name: ops") {
+		t.Fatalf("served script lacks the seeded access:\n%s", served)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAutoInstallPreview(rec, httptest.NewRequest("GET", "/api/autoinstall/preview?filename=cloud.iso&mac="+mac+"&ip=192.0.2.50", nil))
+	var resp struct {
+		Data struct {
+			Script string `json:"script"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.ReplaceAll(served, "$6$saltsalt$hashhashhash", "$6$"+maskedValue)
+	if resp.Data.Script != want {
+		t.Errorf("preview differs from the served script:\npreview:\n%s\nserved (masked):\n%s", resp.Data.Script, want)
+	}
+	if strings.Contains(resp.Data.Script, "hashhashhash") {
+		t.Error("preview shows the password hash")
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// With a boot token configured, the menu and the endpoints serving
+// auto-install data (which carry hostnames, password hashes and keys)
+// answer only requests that present it, as ?token= or as the password of
+// HTTP basic auth. Bootloaders, kernels and ISOs stay open so firmware can
+// fetch them. iPXE gets the token from its boot-token setting, set in an
+// embedded script; everything the menu links to carries it from there.
+
+const bootTokenUser = "bootimus"
+
+func (s *Server) requireBootToken(next http.HandlerFunc) http.HandlerFunc {
+	if s.config.BootToken == "" {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if _, pass, ok := r.BasicAuth(); ok {
+			token = pass
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.BootToken)) != 1 {
+			s.logAndBroadcast("Boot token: Rejected %s from MAC %s (IP: %s)", r.URL.Path, requestMAC(r), r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Basic realm="bootimus"`)
+			http.Error(w, "Boot token required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// bootTokenParam is appended to URLs of protected endpoints in generated
+// scripts: the literal token for scripts that are themselves protected,
+// or iPXE's boot-token setting for the open autoexec.ipxe.
+func (s *Server) bootTokenParam(literal bool) string {
+	switch {
+	case s.config.BootToken == "":
+		return ""
+	case literal:
+		return "&token=" + url.QueryEscape(s.config.BootToken)
+	default:
+		return "&token=${boot-token:uristring}"
+	}
+}
+
+// authURL is baseURL with the boot token as basic-auth credentials, for
+// kernel parameters such as cloud-init seed URLs that cannot take a query.
+func authURL(baseURL, token string) string {
+	if token == "" {
+		return baseURL
+	}
+	rest, ok := strings.CutPrefix(baseURL, "http://")
+	if !ok {
+		return baseURL
+	}
+	return fmt.Sprintf("http://%s@%s", url.UserPassword(bootTokenUser, token).String(), rest)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// handleBootError is fetched by the menu's :failed handler with the item
+// that failed and iPXE's ${errno}, so failures that only showed on the
+// client's console end up in the boot log.
+func (s *Server) handleBootError(w http.ResponseWriter, r *http.Request) {
+	mac := strings.ToLower(strings.ReplaceAll(r.FormValue("mac"), "-", ":"))
+	if _, err := net.ParseMAC(mac); err != nil {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	item := r.FormValue("item")
+	target := s.bootErrorTarget(item)
+
+	reason := "iPXE boot failed"
+	if code, err := strconv.ParseUint(r.FormValue("errno"), 0, 32); err == nil && code != 0 {
+		reason = fmt.Sprintf("iPXE error 0x%08x (https://ipxe.org/err/%08x)", code, code)
+	}
+	if item != "" {
+		reason += " booting " + item
+	}
+	s.logAndBroadcast("Boot error: MAC %s (IP: %s) %s: %s", mac, ip, target, reason)
+	s.recordBootFailure(mac, target, ip, reason)
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "#!ipxe")
+}
+
+// bootErrorTarget names the image or tool behind a menu item label.
+func (s *Server) bootErrorTarget(item string) string {
+	if name, ok := strings.CutPrefix(item, "tool-"); ok {
+		return "tool " + name
+	}
+	idStr, ok := strings.CutPrefix(item, "iso")
+	if !ok {
+		idStr, ok = strings.CutPrefix(item, "rescue")
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if !ok || err != nil || s.config.Storage == nil {
+		return item
+	}
+	images, err := s.config.Storage.ListImages()
+	if err != nil {
+		return item
+	}
+	for _, img := range images {
+		if uint64(img.ID) == id {
+			return img.Name
+		}
+	}
+	return item
+}
@@ -0,0 +1,28 @@
+package server
+
+import "fmt"
+
+// bootMediaScript is the script embedded in USB boot media made for this
+// server. Unlike the stock embed.ipxe it does not rely on DHCP naming a
+// boot server, so it works on networks without PXE options. The boot
+// token, if any, is written into it.
+func (s *Server) bootMediaScript() string {
+	addr := fmt.Sprintf("%s:%d", s.config.ServerAddr, s.config.HTTPPort)
+	return fmt.Sprintf(`#!ipxe
+
+echo Bootimus boot media for %s
+:retry
+dhcp || goto netfail
+chain http://%s/inventory?mac=${netX/mac}&cpu=${cpuid/0}&memsize=${memsize}&platform=${platform}&buildarch=${buildarch}&product=${product}&manufacturer=${manufacturer}&serial=${serial}&uuid=${uuid}%s || chain http://%s/menu.ipxe?mac=${netX/mac}%s%s || goto failed
+
+:netfail
+echo DHCP failed. Check the cable; retrying in 5 seconds...
+sleep 5
+goto retry
+
+:failed
+echo Could not load the menu from %s. Retrying in 5 seconds...
+sleep 5
+goto retry
+`, addr, addr, s.bootTokenParam(true), addr, s.bootTokenParam(true), s.menuFailoverChain("${netX/mac}", true), addr)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"bootimus/internal/hooks"
+	"bootimus/internal/models"
+	"bootimus/internal/switchport"
+	"bootimus/internal/webhook"
+)
+
+// handleBootComplete is hit by installed systems at the end of an install,
+// e.g. from a kickstart %post or a preseed late_command:
+//
+//	curl "http://server:8080/callback/boot-complete?mac=...&hostname=$(hostname)"
+//
+// A success marks the client's latest boot as a finished install.
+func (s *Server) handleBootComplete(w http.ResponseWriter, r *http.Request) {
+	mac := strings.ToLower(strings.ReplaceAll(r.FormValue("mac"), "-", ":"))
+	if _, err := net.ParseMAC(mac); err != nil {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	status := r.FormValue("status")
+	if status == "" {
+		status = "success"
+	}
+
+	ev := webhook.Event{
+		MAC:   mac,
+		IP:    ip,
+		Image: r.FormValue("image"),
+		Metadata: map[string]string{
+			"status":   status,
+			"hostname": r.FormValue("hostname"),
+			"os":       r.FormValue("os"),
+		},
+	}
+	var client *models.Client
+	if s.config.Storage != nil {
+		if c, err := s.config.Storage.GetClient(mac); err == nil {
+			client = c
+			ev.ClientName = c.Name
+		}
+	}
+	s.logAndBroadcast("Install complete: %s (%s) reported %s", mac, ip, status)
+	if status != "success" {
+		s.recordBootFailure(mac, r.FormValue("image"), ip, "install reported "+status)
+	} else if s.config.Storage != nil {
+		s.completeInstall(client, mac, r.FormValue("hostname"), r.FormValue("os"))
+	}
+	s.hooks.Go(hooks.PostInstall, ev)
+	if client != nil && status == "success" && s.switchport.Enabled() {
+		go s.moveSwitchport(client, switchport.StateProduction, "install complete")
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// completeInstall records a finished install against the boot that started
+// it and, for a known client, what the installed system is called.
+func (s *Server) completeInstall(client *models.Client, mac, hostname, installedOS string) {
+	if bootLog, err := s.config.Storage.CompleteBootLog(mac, hostname, installedOS); err != nil {
+		log.Printf("Install complete: no boot of %s to mark as installed: %v", mac, err)
+	} else {
+		log.Printf("Install complete: marked the %s boot of %s at %s as installed", bootLog.ImageName, mac, bootLog.CreatedAt.Format(time.RFC3339))
+	}
+	if client == nil || (hostname == "" && installedOS == "") {
+		return
+	}
+	if hostname != "" {
+		client.InstalledHostname = hostname
+	}
+	if installedOS != "" {
+		client.InstalledOS = installedOS
+	}
+	if err := s.config.Storage.UpdateClientRegistration(mac, client); err != nil {
+		log.Printf("Install complete: failed to update %s: %v", mac, err)
+	}
+}
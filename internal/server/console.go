@@ -0,0 +1,252 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"bootimus/internal/models"
+)
+
+const (
+	maxSerialLogSize  = 16 << 20
+	maxScreenshotSize = 8 << 20
+
+	// Each client keeps its newest captures up to these limits; older ones
+	// are dropped as new ones arrive, so an upload loop can't fill the disk.
+	maxClientScreenshots  = 20
+	maxClientConsoleBytes = 256 << 20
+)
+
+var consoleNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+var screenshotExts = map[string]string{
+	"image/png":                ".png",
+	"image/jpeg":               ".jpg",
+	"image/x-portable-pixmap":  ".ppm",
+	"image/x-portable-anymap":  ".pnm",
+	"image/bmp":                ".bmp",
+	"application/octet-stream": ".bin",
+}
+
+func (s *Server) consoleDir(mac string) string {
+	return filepath.Join(s.config.DataDir, "console", strings.ReplaceAll(mac, ":", ""))
+}
+
+// handleConsoleUpload accepts serial log chunks and screenshots from
+// installer environments. Serial chunks for the same boot and source are
+// appended to one file; each screenshot is stored separately. It is served
+// behind requireBootToken.
+func (s *Server) handleConsoleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config.Storage == nil {
+		http.Error(w, "Console capture requires database", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	mac := strings.ToLower(strings.ReplaceAll(q.Get("mac"), "-", ":"))
+	if _, err := net.ParseMAC(mac); err != nil {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.config.Storage.GetClient(mac); err != nil {
+		http.Error(w, "Unknown client", http.StatusNotFound)
+		return
+	}
+
+	source := q.Get("source")
+	if len(source) > 128 {
+		source = source[:128]
+	}
+
+	capture := &models.ConsoleCapture{MACAddress: mac, Kind: q.Get("kind"), Source: source}
+	if logs, err := s.config.Storage.GetBootLogsByMAC(mac, 1); err == nil && len(logs) > 0 {
+		capture.BootLogID = &logs[0].ID
+		capture.ImageName = logs[0].ImageName
+	}
+
+	dir := s.consoleDir(mac)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		http.Error(w, "Failed to create console directory", http.StatusInternalServerError)
+		return
+	}
+
+	var limit int64
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch capture.Kind {
+	case "serial":
+		if source == "" {
+			capture.Source = "console"
+		}
+		if existing, err := s.config.Storage.FindSerialCapture(mac, capture.BootLogID, capture.Source); err == nil {
+			capture = existing
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		} else {
+			run := "0"
+			if capture.BootLogID != nil {
+				run = strconv.FormatUint(uint64(*capture.BootLogID), 10)
+			}
+			capture.Filename = fmt.Sprintf("%s-%s.log", run, strings.Trim(consoleNameRe.ReplaceAllString(capture.Source, "_"), "_"))
+			capture.ContentType = "text/plain; charset=utf-8"
+		}
+		limit = maxSerialLogSize - capture.Size
+	case "screenshot":
+		ct := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+		ext, ok := screenshotExts[ct]
+		if !ok {
+			http.Error(w, "Unsupported screenshot type", http.StatusUnsupportedMediaType)
+			return
+		}
+		capture.Filename = fmt.Sprintf("%s%s", time.Now().UTC().Format("20060102T150405.000"), ext)
+		capture.ContentType = ct
+		limit = maxScreenshotSize
+	default:
+		http.Error(w, "kind must be serial or screenshot", http.StatusBadRequest)
+		return
+	}
+	if limit <= 0 {
+		http.Error(w, "Serial log size limit reached", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, capture.Filename), flags, 0644)
+	if err != nil {
+		http.Error(w, "Failed to open capture file", http.StatusInternalServerError)
+		return
+	}
+	n, err := io.Copy(f, io.LimitReader(r.Body, limit))
+	f.Close()
+	if err != nil {
+		http.Error(w, "Upload failed", http.StatusBadRequest)
+		return
+	}
+	capture.Size += n
+	if err := s.config.Storage.SaveConsoleCapture(capture); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.pruneConsoleCaptures(mac, capture.ID)
+
+	if capture.Kind == "screenshot" {
+		s.logAndBroadcast("Console: screenshot from %s (%d bytes)", mac, n)
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// pruneConsoleCaptures drops mac's oldest captures beyond
+// maxClientScreenshots screenshots or maxClientConsoleBytes in all. keep,
+// the capture just written, always stays.
+func (s *Server) pruneConsoleCaptures(mac string, keep uint) {
+	captures, err := s.config.Storage.ListConsoleCaptures(mac, -1)
+	if err != nil {
+		log.Printf("Console: failed to list captures for %s: %v", mac, err)
+		return
+	}
+	var screenshots int
+	var total int64
+	for _, c := range captures { // newest first
+		if c.Kind == "screenshot" {
+			screenshots++
+		}
+		total += c.Size
+		over := total > maxClientConsoleBytes || (c.Kind == "screenshot" && screenshots > maxClientScreenshots)
+		if !over || c.ID == keep {
+			continue
+		}
+		if err := s.config.Storage.DeleteConsoleCapture(c.ID); err != nil {
+			log.Printf("Console: failed to drop capture %d for %s: %v", c.ID, mac, err)
+			continue
+		}
+		os.Remove(filepath.Join(s.consoleDir(mac), filepath.Base(c.Filename)))
+		if c.Kind == "screenshot" {
+			screenshots--
+		}
+		total -= c.Size
+	}
+}
+
+const consoleAgentScript = `#!/bin/sh
+# Bootimus console agent: ships installer logs (and framebuffer screenshots
+# when fbgrab is available) to the server until the machine reboots.
+SERVER="@SERVER@"
+MAC="@MAC@"
+INTERVAL="@INTERVAL@"
+SCREENSHOTS="@SCREENSHOTS@"
+LOGS="${BOOTIMUS_LOGS:-/var/log/installer/syslog /var/log/installer/subiquity-server-debug.log /var/log/syslog /tmp/anaconda.log /tmp/syslog /var/log/messages}"
+STATE=/tmp/.bootimus-console
+mkdir -p "$STATE"
+
+post() {
+	url="$SERVER/console/upload?mac=$MAC&kind=$1&source=$2@TOKEN@"
+	if command -v curl >/dev/null 2>&1; then
+		curl -fsS -o /dev/null -X POST -H "Content-Type: $4" --data-binary @"$3" "$url"
+	else
+		wget -q -O /dev/null --header="Content-Type: $4" --post-file="$3" "$url"
+	fi
+}
+
+while true; do
+	for f in $LOGS; do
+		[ -f "$f" ] || continue
+		key=$(echo "$f" | tr '/' '_')
+		off=$(cat "$STATE/$key" 2>/dev/null || echo 0)
+		size=$(wc -c < "$f")
+		[ "$size" -lt "$off" ] && off=0
+		if [ "$size" -gt "$off" ]; then
+			tail -c +$((off + 1)) "$f" | head -c $((size - off)) > "$STATE/chunk"
+			post serial "$f" "$STATE/chunk" text/plain && echo "$size" > "$STATE/$key"
+		fi
+	done
+	if [ "$SCREENSHOTS" = "1" ] && command -v fbgrab >/dev/null 2>&1; then
+		fbgrab "$STATE/shot.png" >/dev/null 2>&1 && post screenshot fb0 "$STATE/shot.png" image/png
+	fi
+	sleep "$INTERVAL"
+done
+`
+
+// handleConsoleAgent serves a POSIX shell loop that installers can start
+// from an early-command to stream their logs to /console/upload. The
+// script carries the boot token, so it is served behind requireBootToken
+// too.
+func (s *Server) handleConsoleAgent(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	mac := strings.ToLower(strings.ReplaceAll(q.Get("mac"), "-", ":"))
+	if _, err := net.ParseMAC(mac); err != nil {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	interval := 30
+	if i, err := strconv.Atoi(q.Get("interval")); err == nil && i >= 5 {
+		interval = i
+	}
+	screenshots := "0"
+	if q.Get("screenshots") == "1" || q.Get("screenshots") == "true" {
+		screenshots = "1"
+	}
+
+	script := strings.NewReplacer(
+		"@SERVER@", fmt.Sprintf("http://%s:%d", s.config.ServerAddr, s.config.HTTPPort),
+		"@MAC@", mac,
+		"@INTERVAL@", strconv.Itoa(interval),
+		"@SCREENSHOTS@", screenshots,
+		"@TOKEN@", s.bootTokenParam(true),
+	).Replace(consoleAgentScript)
+
+	log.Printf("Console: agent script served to %s (%s)", mac, r.RemoteAddr)
+	w.Header().Set("Content-Type", "text/x-shellscript; charset=utf-8")
+	w.Write([]byte(script))
+}
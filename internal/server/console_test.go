@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+)
+
+func TestConsoleUploadsAreCapped(t *testing.T) {
+	store, err := storage.NewSQLiteStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	const mac = "52:54:00:12:34:56"
+	if err := store.CreateClient(&models.Client{MACAddress: mac}); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{config: &Config{Storage: store, DataDir: t.TempDir(), BootToken: "sesame"}}
+	upload := s.requireBootToken(s.handleConsoleUpload)
+
+	post := func(query string) int {
+		req := httptest.NewRequest(http.MethodPost, "/console/upload?mac="+mac+"&kind=screenshot"+query, strings.NewReader("shot"))
+		req.Header.Set("Content-Type", "image/png")
+		rec := httptest.NewRecorder()
+		upload(rec, req)
+		return rec.Code
+	}
+
+	if code := post(""); code != http.StatusUnauthorized {
+		t.Fatalf("upload without the boot token: got %d, want 401", code)
+	}
+	for i := 0; i < maxClientScreenshots+5; i++ {
+		if code := post("&token=sesame"); code != http.StatusOK {
+			t.Fatalf("upload %d: got %d", i, code)
+		}
+	}
+
+	captures, err := store.ListConsoleCaptures(mac, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(captures) != maxClientScreenshots {
+		t.Errorf("got %d captures, want %d", len(captures), maxClientScreenshots)
+	}
+	files, err := os.ReadDir(s.consoleDir(mac))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != maxClientScreenshots {
+		t.Errorf("got %d files, want %d", len(files), maxClientScreenshots)
+	}
+}
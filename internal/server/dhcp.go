@@ -0,0 +1,90 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"bootimus/internal/models"
+	"bootimus/internal/proxydhcp"
+	"bootimus/internal/storage"
+)
+
+// dhcpPool is the address pool for authoritative DHCP mode, with leases
+// kept in storage and reservations read from clients' reserved_ip.
+func (s *Server) dhcpPool() (*proxydhcp.Pool, error) {
+	if s.config.Storage == nil {
+		return nil, fmt.Errorf("DHCP mode requires a database for its leases")
+	}
+	start, end, err := proxydhcp.ParseRange(s.config.DHCPRange)
+	if err != nil {
+		return nil, err
+	}
+	pool := &proxydhcp.Pool{
+		Start:     start,
+		End:       end,
+		LeaseTime: s.config.DHCPLeaseTime,
+		Store:     leaseStore{s.config.Storage},
+	}
+	if s.config.DHCPNetmask != "" {
+		mask := net.ParseIP(s.config.DHCPNetmask).To4()
+		if mask == nil {
+			return nil, fmt.Errorf("invalid DHCP netmask %q", s.config.DHCPNetmask)
+		}
+		pool.Netmask = net.IPMask(mask)
+	}
+	if s.config.DHCPRouter != "" {
+		router := net.ParseIP(s.config.DHCPRouter).To4()
+		if router == nil {
+			return nil, fmt.Errorf("invalid DHCP router %q", s.config.DHCPRouter)
+		}
+		pool.Routers = []net.IP{router}
+	}
+	for _, d := range s.config.DHCPDNS {
+		ip := net.ParseIP(strings.TrimSpace(d)).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid DHCP DNS server %q", d)
+		}
+		pool.DNSServers = append(pool.DNSServers, ip)
+	}
+	return pool, nil
+}
+
+type leaseStore struct {
+	store storage.Storage
+}
+
+func (l leaseStore) Leases() ([]proxydhcp.Lease, error) {
+	rows, err := l.store.ListDHCPLeases()
+	if err != nil {
+		return nil, err
+	}
+	leases := make([]proxydhcp.Lease, 0, len(rows))
+	for _, r := range rows {
+		leases = append(leases, proxydhcp.Lease{MAC: r.MACAddress, IP: net.ParseIP(r.IPAddress), Hostname: r.Hostname, Expires: r.ExpiresAt})
+	}
+	return leases, nil
+}
+
+func (l leaseStore) SaveLease(lease proxydhcp.Lease) error {
+	return l.store.SaveDHCPLease(&models.DHCPLease{
+		MACAddress: lease.MAC,
+		IPAddress:  lease.IP.String(),
+		Hostname:   lease.Hostname,
+		ExpiresAt:  lease.Expires,
+	})
+}
+
+func (l leaseStore) Reservations() (map[string]net.IP, error) {
+	clients, err := l.store.ListClients()
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]net.IP{}
+	for _, c := range clients {
+		if ip := net.ParseIP(c.ReservedIP).To4(); ip != nil {
+			out[c.MACAddress] = ip
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,550 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"bootimus/internal/ctxio"
+	"bootimus/internal/models"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Disk tasks boot a live image (SystemRescue by default, via its autorun
+// hook) which pulls a generated shell script from /tasks/<id>/<token>/autorun
+// and streams the disk to or from the server.
+const defaultDiskTaskParams = "ip=dhcp ar_source={{BASE_URL}}/tasks/{{TASK_ID}}/{{TASK_TOKEN}}/ ar_nowait ar_nodel bootimus.task={{BASE_URL}}/tasks/{{TASK_ID}}/{{TASK_TOKEN}}/autorun"
+
+const diskTaskProgressInterval = 5 * time.Second
+
+var captureScript = template.Must(template.New("capture").Parse(`#!/bin/sh
+# Bootimus disk capture task {{.Task.ID}} for {{.Task.MACAddress}}
+set -u
+BASE='{{.BaseURL}}'
+TASK='{{.Task.ID}}'
+TOKEN='{{.Task.Token}}'
+DEV='{{.Task.Device}}'
+
+report() {
+	curl -fsS -X POST --data-urlencode "status=$1" --data-urlencode "message=$2" \
+		"$BASE/tasks/$TASK/$TOKEN/status" >/dev/null 2>&1 || true
+}
+
+if [ -z "$DEV" ]; then
+	DEV="/dev/$(lsblk -dno NAME,TYPE,RM | awk '$2=="disk" && $3=="0" {print $1; exit}')"
+fi
+if [ ! -b "$DEV" ]; then
+	report failed "no block device found to capture"
+	exit 1
+fi
+
+SIZE=$(blockdev --getsize64 "$DEV")
+COMP=zstd
+command -v zstd >/dev/null 2>&1 || COMP=none
+report running "capturing $DEV ($SIZE bytes, $COMP)"
+
+compress() {
+	if [ "$COMP" = "zstd" ]; then zstd -T0 -3 -q -c; else cat; fi
+}
+
+# The server checks it received all $SIZE bytes before it marks the
+# capture complete, so a read that stops early fails the task.
+set -o pipefail 2>/dev/null || true
+if dd if="$DEV" bs=4M status=none | compress | curl -fsS -T - \
+	"$BASE/api/capture?task=$TASK&token=$TOKEN&device=$DEV&disk_size=$SIZE&compression=$COMP"; then
+	echo "Capture of $DEV complete"
+else
+	report failed "upload of $DEV failed"
+	exit 1
+fi
+sleep 5
+reboot -f
+`))
+
+var deployScript = template.Must(template.New("deploy").Parse(`#!/bin/sh
+# Bootimus disk deploy task {{.Task.ID}} for {{.Task.MACAddress}}
+set -u
+BASE='{{.BaseURL}}'
+TASK='{{.Task.ID}}'
+TOKEN='{{.Task.Token}}'
+DEV='{{.Task.Device}}'
+FORMAT='{{.Image.Format}}'
+FSTYPE='{{.Image.FSType}}'
+COMP='{{.Image.Compression}}'
+SHA256='{{.Image.SHA256}}'
+
+report() {
+	curl -fsS -X POST --data-urlencode "status=$1" --data-urlencode "message=$2" \
+		"$BASE/tasks/$TASK/$TOKEN/status" >/dev/null 2>&1 || true
+}
+
+# Deploying overwrites the target, so a disk is only picked for the
+# operator when there is exactly one to pick.
+if [ -z "$DEV" ]; then
+	if [ "$FORMAT" = "partclone" ]; then
+		report failed "a partclone image needs the target partition set as the task's device"
+		exit 1
+	fi
+	DISKS=$(lsblk -dno NAME,TYPE,RM | awk '$2=="disk" && $3=="0" {print $1}')
+	if [ "$(echo "$DISKS" | grep -c .)" -ne 1 ]; then
+		report failed "found $(echo "$DISKS" | grep -c .) non-removable disks ($(echo $DISKS)); set the task's device"
+		exit 1
+	fi
+	DEV="/dev/$DISKS"
+fi
+if [ ! -b "$DEV" ]; then
+	report failed "$DEV is not a block device"
+	exit 1
+fi
+
+SIZE=$(blockdev --getsize64 "$DEV")
+if [ "$SIZE" -lt {{.Image.DiskSize}} ]; then
+	report failed "$DEV is $SIZE bytes, image needs {{.Image.DiskSize}}"
+	exit 1
+fi
+report running "writing {{.Image.Name}} to $DEV"
+
+decompress() {
+	if [ "$COMP" = "zstd" ]; then zstd -d -q -c; else cat; fi
+}
+
+write_disk() {
+	if [ "$FORMAT" = "partclone" ]; then
+		partclone."$FSTYPE" -r -q -s - -o "$DEV"
+	else
+		dd of="$DEV" bs=4M conv=fsync status=none
+	fi
+}
+
+# The download is hashed as it is written, and the task only reports
+# complete once the hash matches the one taken at capture.
+FIFO=/tmp/bootimus-deploy-$TASK
+rm -f "$FIFO" "$FIFO.sum"
+mkfifo "$FIFO"
+sha256sum <"$FIFO" >"$FIFO.sum" &
+set -o pipefail 2>/dev/null || true
+if curl -fsS "$BASE/tasks/$TASK/$TOKEN/image" | tee "$FIFO" | decompress | write_disk; then
+	wait
+	sync
+	GOT=$(cut -d' ' -f1 "$FIFO.sum")
+	if [ "$GOT" != "$SHA256" ]; then
+		report failed "{{.Image.Name}} on $DEV has SHA-256 $GOT, expected $SHA256"
+		exit 1
+	fi
+	report complete "deployed {{.Image.Name}} to $DEV (SHA-256 verified)"
+else
+	report failed "writing {{.Image.Name}} to $DEV failed"
+	exit 1
+fi
+sleep 5
+reboot -f
+`))
+
+func (s *Server) capturesDir() string {
+	return filepath.Join(s.config.DataDir, "captures")
+}
+
+// diskTaskBootScript replaces the menu for a client with a pending task. The
+// operator gets a short window to cancel before the live image boots.
+func (s *Server) diskTaskBootScript(task *models.DiskTask) (string, error) {
+	img, err := s.config.Storage.GetImage(task.LiveImage)
+	if err != nil {
+		return "", fmt.Errorf("live image %s not found", task.LiveImage)
+	}
+	if !img.Extracted || img.BootMethod != "kernel" {
+		return "", fmt.Errorf("live image %s must be extracted and use the kernel boot method", img.Filename)
+	}
+
+	baseURL := fmt.Sprintf("http://%s:%d", s.config.ServerAddr, s.config.HTTPPort)
+	mb := s.baseMenuBuilder(nil, task.MACAddress)
+	encodedFilename := encodePathSegments(img.DiskFilename())
+	cacheDir := encodePathSegments(strings.TrimSuffix(img.DiskFilename(), filepath.Ext(img.DiskFilename())))
+
+	taskParams := strings.NewReplacer(
+		"{{TASK_ID}}", strconv.FormatUint(uint64(task.ID), 10),
+		"{{TASK_TOKEN}}", string(task.Token),
+	).Replace(defaultDiskTaskParams)
+	params := mb.resolveBootParams(img, baseURL, encodedFilename, cacheDir) + " " +
+		mb.substituteBootVars(taskParams, img, baseURL, encodedFilename, cacheDir)
+
+	var sb strings.Builder
+	sb.WriteString("#!ipxe\n\n")
+	sb.WriteString(fmt.Sprintf("echo Bootimus: %s task #%d is pending for this machine\n", task.Kind, task.ID))
+	sb.WriteString("prompt --key c --timeout 10000 Press 'c' within 10 seconds to cancel and show the menu && goto cancel || echo Starting task...\n")
+	sb.WriteString(fmt.Sprintf("kernel %s/boot/%s/vmlinuz %s\n", baseURL, cacheDir, params))
+	sb.WriteString(fmt.Sprintf("initrd %s/boot/%s/initrd\n", baseURL, cacheDir))
+	sb.WriteString("boot || goto cancel\n\n")
+	sb.WriteString(":cancel\n")
+	sb.WriteString(fmt.Sprintf("chain %s/menu.ipxe?mac=%s&skip_task=1%s\n", baseURL, url.QueryEscape(task.MACAddress), s.bootTokenParam(true)))
+	return sb.String(), nil
+}
+
+func (s *Server) lookupDiskTask(idStr, token string) (*models.DiskTask, int, error) {
+	if s.config.Storage == nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("disk tasks require database")
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid task id")
+	}
+	task, err := s.config.Storage.GetDiskTask(uint(id))
+	if err != nil {
+		return nil, http.StatusNotFound, fmt.Errorf("task not found")
+	}
+	if task.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(task.Token)) != 1 {
+		return nil, http.StatusForbidden, fmt.Errorf("invalid task token")
+	}
+	return task, http.StatusOK, nil
+}
+
+// handleDiskTask serves /tasks/<id>/<token>/autorun and /tasks/<id>/<token>/status.
+func (s *Server) handleDiskTask(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/tasks/"), "/"), "/")
+	if len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	task, code, err := s.lookupDiskTask(parts[0], parts[1])
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	switch parts[2] {
+	case "autorun":
+		if task.Status != "pending" && task.Status != "running" {
+			http.NotFound(w, r)
+			return
+		}
+		script, err := s.renderDiskTaskScript(task)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if task.StartedAt == nil {
+			now := time.Now()
+			task.StartedAt = &now
+		}
+		task.Status = "running"
+		task.Message = "task script fetched"
+		if err := s.config.Storage.UpdateDiskTask(task); err != nil {
+			log.Printf("Disk task %d: failed to mark running: %v", task.ID, err)
+		}
+		s.logAndBroadcast("Disk task %d (%s): %s fetched its script from %s", task.ID, task.Kind, task.MACAddress, r.RemoteAddr)
+		w.Header().Set("Content-Type", "text/x-shellscript; charset=utf-8")
+		w.Write([]byte(script))
+
+	case "image":
+		s.serveDeployImage(w, r, task)
+
+	case "status":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		r.ParseForm()
+		status := r.PostForm.Get("status")
+		switch status {
+		case "running", "complete", "failed":
+		default:
+			http.Error(w, "invalid status", http.StatusBadRequest)
+			return
+		}
+		s.setDiskTaskStatus(task, status, r.PostForm.Get("message"))
+		if v, err := strconv.ParseInt(r.PostForm.Get("bytes_done"), 10, 64); err == nil {
+			task.BytesDone = v
+		}
+		if v, err := strconv.ParseInt(r.PostForm.Get("bytes_total"), 10, 64); err == nil {
+			task.BytesTotal = v
+		}
+		if err := s.config.Storage.UpdateDiskTask(task); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) setDiskTaskStatus(task *models.DiskTask, status, message string) {
+	if task.Status != status || message != "" {
+		s.logAndBroadcast("Disk task %d (%s, %s): %s %s", task.ID, task.Kind, task.MACAddress, status, message)
+	}
+	task.Status = status
+	if message != "" {
+		task.Message = message
+	}
+	if status == "complete" || status == "failed" {
+		now := time.Now()
+		task.FinishedAt = &now
+	}
+}
+
+func (s *Server) renderDiskTaskScript(task *models.DiskTask) (string, error) {
+	var tmpl *template.Template
+	switch task.Kind {
+	case "capture":
+		tmpl = captureScript
+	case "deploy":
+		if task.DiskImage == nil || task.DiskImage.Status != "complete" {
+			return "", fmt.Errorf("deploy task has no completed disk image")
+		}
+		if task.DiskImage.SHA256 == "" {
+			return "", fmt.Errorf("disk image %s has no SHA-256 to verify the deploy against", task.DiskImage.Name)
+		}
+		tmpl = deployScript
+	default:
+		return "", fmt.Errorf("unsupported task kind %q", task.Kind)
+	}
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, map[string]interface{}{
+		"Task":    task,
+		"Image":   task.DiskImage,
+		"BaseURL": fmt.Sprintf("http://%s:%d", s.config.ServerAddr, s.config.HTTPPort),
+	})
+	return buf.String(), err
+}
+
+// handleCaptureUpload receives the stream produced by the capture script.
+func (s *Server) handleCaptureUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	task, code, err := s.lookupDiskTask(q.Get("task"), q.Get("token"))
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if task.Kind != "capture" || task.DiskImageID == nil || (task.Status != "pending" && task.Status != "running") {
+		http.Error(w, "task is not accepting uploads", http.StatusConflict)
+		return
+	}
+	img, err := s.config.Storage.GetDiskImage(*task.DiskImageID)
+	if err != nil {
+		http.Error(w, "disk image record missing", http.StatusInternalServerError)
+		return
+	}
+
+	diskSize, err := strconv.ParseInt(q.Get("disk_size"), 10, 64)
+	if err != nil || diskSize <= 0 {
+		http.Error(w, "disk_size is required", http.StatusBadRequest)
+		return
+	}
+
+	img.SourceDev = q.Get("device")
+	img.Compression = "zstd"
+	if q.Get("compression") == "none" {
+		img.Compression = "none"
+	}
+	img.DiskSize = diskSize
+	img.Status = "uploading"
+	if img.Compression == "zstd" && !strings.HasSuffix(img.Filename, ".zst") {
+		img.Filename += ".zst"
+	}
+	s.config.Storage.UpdateDiskImage(img)
+
+	task.BytesTotal = img.DiskSize
+	s.setDiskTaskStatus(task, "running", fmt.Sprintf("receiving %s", img.SourceDev))
+	s.config.Storage.UpdateDiskTask(task)
+
+	if err := os.MkdirAll(s.capturesDir(), 0755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	finalPath := filepath.Join(s.capturesDir(), img.Filename)
+	tmpPath := finalPath + ".partial"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	counter := newDiskByteCounter(img.Compression)
+	fail := func(msg string) {
+		counter.Close()
+		out.Close()
+		os.Remove(tmpPath)
+		img.Status = "failed"
+		s.config.Storage.UpdateDiskImage(img)
+		s.setDiskTaskStatus(task, "failed", msg)
+		s.config.Storage.UpdateDiskTask(task)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+
+	hash := sha256.New()
+	buf := make([]byte, 1024*1024)
+	var written int64
+	lastReport := time.Now()
+	for {
+		n, rerr := r.Body.Read(buf)
+		if n > 0 {
+			if _, werr := out.Write(buf[:n]); werr != nil {
+				fail(fmt.Sprintf("write failed: %v", werr))
+				return
+			}
+			hash.Write(buf[:n])
+			if _, cerr := counter.Write(buf[:n]); cerr != nil {
+				fail(fmt.Sprintf("upload is not a valid %s stream: %v", img.Compression, cerr))
+				return
+			}
+			written += int64(n)
+			if time.Since(lastReport) > diskTaskProgressInterval {
+				task.BytesDone = written
+				s.config.Storage.UpdateDiskTask(task)
+				lastReport = time.Now()
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			fail(fmt.Sprintf("upload interrupted after %d bytes: %v", written, rerr))
+			return
+		}
+	}
+	diskBytes, err := counter.Close()
+	if err != nil {
+		fail(fmt.Sprintf("upload is not a valid %s stream: %v", img.Compression, err))
+		return
+	}
+	if diskBytes != img.DiskSize {
+		fail(fmt.Sprintf("received %s of the %s on %s; reading the disk stopped early", formatBytes(diskBytes), formatBytes(img.DiskSize), img.SourceDev))
+		return
+	}
+	if err := out.Close(); err != nil {
+		fail(err.Error())
+		return
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		fail(err.Error())
+		return
+	}
+
+	img.Size = written
+	img.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	img.Status = "complete"
+	if err := s.config.Storage.UpdateDiskImage(img); err != nil {
+		log.Printf("Capture: failed to save disk image %d: %v", img.ID, err)
+	}
+	task.BytesDone = written
+	s.setDiskTaskStatus(task, "complete", fmt.Sprintf("captured %s (%s stored)", img.SourceDev, formatBytes(written)))
+	s.config.Storage.UpdateDiskTask(task)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "ok %s\n", img.SHA256)
+}
+
+// diskByteCounter counts the bytes of disk a capture upload holds,
+// decompressing zstd uploads as they arrive.
+type diskByteCounter struct {
+	n      int64
+	pw     *io.PipeWriter
+	done   chan error
+	closed bool
+	err    error
+}
+
+func newDiskByteCounter(compression string) *diskByteCounter {
+	c := &diskByteCounter{}
+	if compression != "zstd" {
+		return c
+	}
+	pr, pw := io.Pipe()
+	c.pw, c.done = pw, make(chan error, 1)
+	go func() {
+		dec, err := zstd.NewReader(pr)
+		if err == nil {
+			c.n, err = io.Copy(io.Discard, dec)
+			dec.Close()
+		}
+		pr.CloseWithError(err)
+		c.done <- err
+	}()
+	return c
+}
+
+func (c *diskByteCounter) Write(b []byte) (int, error) {
+	if c.pw == nil {
+		c.n += int64(len(b))
+		return len(b), nil
+	}
+	return c.pw.Write(b)
+}
+
+// Close ends the upload and returns the disk bytes it held.
+func (c *diskByteCounter) Close() (int64, error) {
+	if c.pw != nil && !c.closed {
+		c.closed = true
+		c.pw.Close()
+		c.err = <-c.done
+	}
+	return c.n, c.err
+}
+
+type taskProgressWriter struct {
+	http.ResponseWriter
+	s       *Server
+	task    *models.DiskTask
+	written int64
+	last    time.Time
+}
+
+func (pw *taskProgressWriter) Write(b []byte) (int, error) {
+	n, err := pw.ResponseWriter.Write(b)
+	pw.written += int64(n)
+	if time.Since(pw.last) > diskTaskProgressInterval {
+		pw.task.BytesDone = pw.written
+		pw.s.config.Storage.UpdateDiskTask(pw.task)
+		pw.last = time.Now()
+	}
+	return n, err
+}
+
+// serveDeployImage streams the stored image to a deploy task. Progress is
+// measured server-side in stored (compressed) bytes.
+func (s *Server) serveDeployImage(w http.ResponseWriter, r *http.Request, task *models.DiskTask) {
+	if task.Kind != "deploy" || task.DiskImage == nil || (task.Status != "pending" && task.Status != "running") {
+		http.Error(w, "task is not deploying an image", http.StatusConflict)
+		return
+	}
+	path := filepath.Join(s.capturesDir(), filepath.Base(task.DiskImage.Filename))
+	f, err := os.Open(path)
+	if err != nil {
+		s.setDiskTaskStatus(task, "failed", "image file missing on server")
+		s.config.Storage.UpdateDiskTask(task)
+		http.Error(w, "image file missing", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	task.BytesTotal = task.DiskImage.Size
+	task.BytesDone = 0
+	s.setDiskTaskStatus(task, "running", "streaming "+task.DiskImage.Name)
+	s.config.Storage.UpdateDiskTask(task)
+
+	pw := &taskProgressWriter{ResponseWriter: w, s: s, task: task, last: time.Now()}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(task.DiskImage.Size, 10))
+	if _, err := ctxio.Copy(r.Context(), pw, f); err != nil {
+		log.Printf("Disk task %d: stream interrupted after %d bytes: %v", task.ID, pw.written, err)
+	}
+	task.BytesDone = pw.written
+	s.config.Storage.UpdateDiskTask(task)
+}
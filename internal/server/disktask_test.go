@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCaptureUploadChecksDiskSize(t *testing.T) {
+	disk := bytes.Repeat([]byte("bootimus"), 64*1024)
+	var compressed bytes.Buffer
+	enc, err := zstd.NewWriter(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc.Write(disk)
+	enc.Close()
+
+	for _, tc := range []struct {
+		name, compression string
+		body              []byte
+		diskSize          int
+		want              string
+	}{
+		{"zstd complete", "zstd", compressed.Bytes(), len(disk), "complete"},
+		{"zstd short", "zstd", compressed.Bytes(), len(disk) + 1, "failed"},
+		{"zstd truncated", "zstd", compressed.Bytes()[:compressed.Len()/2], len(disk), "failed"},
+		{"raw complete", "none", disk, len(disk), "complete"},
+		{"raw short", "none", disk[:len(disk)-1], len(disk), "failed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := storage.NewSQLiteStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.AutoMigrate(); err != nil {
+				t.Fatal(err)
+			}
+			img := &models.DiskImage{Name: "test", Filename: "test.img", Format: "raw", Status: "pending"}
+			if err := store.CreateDiskImage(img); err != nil {
+				t.Fatal(err)
+			}
+			task := &models.DiskTask{MACAddress: "52:54:00:12:34:56", Kind: "capture", LiveImage: "live.iso",
+				DiskImageID: &img.ID, Format: "raw", Token: "secret", Status: "running"}
+			if err := store.CreateDiskTask(task); err != nil {
+				t.Fatal(err)
+			}
+			s := &Server{config: &Config{Storage: store, DataDir: t.TempDir()}}
+
+			url := fmt.Sprintf("/api/capture?task=%d&token=secret&device=/dev/sda&disk_size=%d&compression=%s", task.ID, tc.diskSize, tc.compression)
+			rec := httptest.NewRecorder()
+			s.handleCaptureUpload(rec, httptest.NewRequest(http.MethodPut, url, bytes.NewReader(tc.body)))
+
+			got, err := store.GetDiskTask(task.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tc.want {
+				t.Errorf("task status %q (%s), want %q", got.Status, got.Message, tc.want)
+			}
+			if (rec.Code == http.StatusOK) != (tc.want == "complete") {
+				t.Errorf("upload answered %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeployScriptVerifiesImage(t *testing.T) {
+	s := &Server{config: &Config{ServerAddr: "10.0.0.1", HTTPPort: 8080}}
+	img := &models.DiskImage{Name: "golden", Filename: "golden.img.zst", Format: "raw", Compression: "zstd", Status: "complete", DiskSize: 1 << 30}
+	task := &models.DiskTask{ID: 7, MACAddress: "52:54:00:12:34:56", Kind: "deploy", DiskImage: img, Token: "secret"}
+
+	if _, err := s.renderDiskTaskScript(task); err == nil {
+		t.Error("rendered a deploy of an image with no SHA-256")
+	}
+
+	img.SHA256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	script, err := s.renderDiskTaskScript(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(script, "SHA256='"+img.SHA256+"'") {
+		t.Error("script does not carry the image's SHA-256")
+	}
+	if !strings.Contains(script, `set the task's device`) {
+		t.Error("script does not refuse an ambiguous target disk")
+	}
+	if out, err := exec.Command("sh", "-n", "-c", script).CombinedOutput(); err != nil {
+		t.Errorf("script does not parse: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bootimus/internal/models"
+)
+
+const externalImageExt = ".url"
+
+func (s *Server) externalISOCachePath(img *models.Image) string {
+	sum := sha256.Sum256([]byte(img.ISOURL))
+	base := strings.TrimSuffix(img.Filename, externalImageExt)
+	return filepath.Join(s.remoteCacheDir(base), "iso-"+hex.EncodeToString(sum[:6]))
+}
+
+// externalImage returns the external image served as /isos/<filename>
+// through the server, if there is one.
+func (s *Server) externalImage(filename string) (*models.Image, bool) {
+	if s.config.Storage == nil || !strings.HasSuffix(filename, externalImageExt) {
+		return nil, false
+	}
+	img, err := s.config.Storage.GetImage(filename)
+	if err != nil || !img.IsExternalISO() || !img.CacheRemote {
+		return nil, false
+	}
+	return img, true
+}
+
+// serveExternalISO answers sanboot's range requests for an external image
+// from the local copy once it has been downloaded, and until then by
+// proxying them upstream while the download runs.
+func (s *Server) serveExternalISO(w http.ResponseWriter, r *http.Request, img *models.Image, mac string) {
+	if strings.HasPrefix(r.Header.Get("Range"), "bytes=0-") {
+		s.recordBoot(mac, strings.TrimSuffix(img.Filename, externalImageExt), r.RemoteAddr)
+	}
+
+	cachePath := s.externalISOCachePath(img)
+	if _, err := os.Stat(cachePath); err == nil {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		cw := &countingWriter{ResponseWriter: w}
+		http.ServeFile(cw, r, cachePath)
+		s.stats.AddBytes(cw.written)
+		return
+	}
+
+	if _, busy := s.externalFetches.LoadOrStore(cachePath, true); !busy {
+		s.logAndBroadcast("ISO: Caching %s from %s", img.Name, img.ISOURL)
+		go func() {
+			defer s.externalFetches.Delete(cachePath)
+			if err := s.downloadRemote(img.ISOURL, cachePath); err != nil {
+				s.logAndBroadcast("ISO: Failed to cache %s from %s: %v", img.Name, img.ISOURL, err)
+			}
+		}()
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, img.ISOURL, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, h := range []string{"Range", "If-Range"} {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.logAndBroadcast("ISO: Upstream fetch of %s failed: %v", img.ISOURL, err)
+		http.Error(w, "Upstream unavailable", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for _, h := range []string{"Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(resp.StatusCode)
+	cw := &countingWriter{ResponseWriter: w}
+	io.Copy(cw, resp.Body)
+	s.stats.AddBytes(cw.written)
+}
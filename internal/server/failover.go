@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// Failover URLs point at other Bootimus servers holding the same images,
+// such as a replica kept up during maintenance of the primary. Menus try
+// each boot fetch against the primary first and then against each of
+// these in order; the autoexec scripts fall back to their menus the same
+// way. Tools and NBD/NFS roots still use the primary only.
+
+func normalizeFailoverURLs(raw []string) []string {
+	var urls []string
+	for _, r := range raw {
+		r = strings.TrimSuffix(strings.TrimSpace(r), "/")
+		if r == "" {
+			continue
+		}
+		u, err := url.Parse(r)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.ContainsAny(r, " \t") {
+			log.Printf("Failover: Ignoring %q, not an http(s) base URL", r)
+			continue
+		}
+		urls = append(urls, r)
+	}
+	return urls
+}
+
+// menuFailoverChain is appended to an autoexec chain to the primary's
+// menu so that the failover servers' menus are tried when it is down.
+func (s *Server) menuFailoverChain(mac string, literalToken bool) string {
+	var sb strings.Builder
+	for _, base := range s.config.FailoverURLs {
+		sb.WriteString(fmt.Sprintf(" || chain %s/menu.ipxe?mac=%s%s", base, mac, s.bootTokenParam(literalToken)))
+	}
+	return sb.String()
+}
+
+// withFailover repeats a boot section once per failover URL. Each fetch
+// that fails jumps to the next copy, which frees what was loaded and
+// starts again from that server; the last copy gives up to :failed.
+// Without failover URLs the section is unchanged.
+func (mb *MenuBuilder) withFailover(label string, body func(baseURL string) string) string {
+	primary := mb.baseURL()
+	if len(mb.failoverURLs) == 0 {
+		return body(primary)
+	}
+
+	var sb strings.Builder
+	urls := append([]string{primary}, mb.failoverURLs...)
+	for i, base := range urls {
+		next := "failed"
+		if i+1 < len(urls) {
+			next = fmt.Sprintf("%s-alt%d", label, i+1)
+		}
+		if i > 0 {
+			sb.WriteString(fmt.Sprintf(":%s-alt%d\n", label, i))
+			sb.WriteString("imgfree\n")
+			sb.WriteString(fmt.Sprintf("echo Retrying from %s...\n", base))
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(body(base), "\n"), "\n") {
+			cmd, _, _ := strings.Cut(line, " ")
+			switch {
+			case strings.HasSuffix(line, "|| goto failed"):
+				line = strings.TrimSuffix(line, "failed") + next
+			case cmd == "kernel" || cmd == "initrd" || cmd == "sanboot" || cmd == "chain":
+				line += " || goto " + next
+			}
+			sb.WriteString(line + "\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"bootimus/internal/autoinstall"
+	"bootimus/internal/initrd"
+	"bootimus/internal/models"
+)
+
+// The first-boot agent runs once on the installed system and reports its
+// hostname, addresses and disk serials to /firstboot/register, so an
+// install that went through is seen to come up. Installing it also adds the
+// seeded SSH keys. The installer puts it in
+// place, either from the overlay iPXE loads next to the initrd of images
+// with first_boot_agent set:
+//
+//	sh /bootimus/firstboot-install.sh /target
+//
+// or straight from the server, which works with any installer:
+//
+//	curl -fsS "http://server:8080/firstboot/install.sh?mac=..." | sh -s /target
+
+const firstBootAgentScript = `#!/bin/sh
+# bootimus first-boot agent: reports this machine to bootimus once, then
+# removes itself.
+SERVER="@SERVER@"
+MAC="@MAC@"
+
+enc() { printf %s "$1" | sed 's/%/%25/g; s/ /%20/g; s/&/%26/g; s/+/%2B/g; s/=/%3D/g'; }
+
+hostname=$(hostname -f 2>/dev/null || hostname)
+ips=$(ip -o addr show scope global 2>/dev/null | awk '{split($4, a, "/"); print a[1]}' | tr '\n' ',')
+[ -n "$ips" ] || ips=$(hostname -I 2>/dev/null | tr ' ' ',')
+os=$(. /etc/os-release 2>/dev/null && echo "$PRETTY_NAME")
+serials=$(lsblk -dn -o SERIAL -e 1,7,11 2>/dev/null | awk 'NF' | tr '\n' ',')
+
+body="hostname=$(enc "$hostname")&ips=$(enc "$ips")&os=$(enc "$os")&serials=$(enc "$serials")"
+url="$SERVER/firstboot/register?mac=$MAC"
+
+for i in $(seq 1 60); do
+	if command -v curl >/dev/null 2>&1; then
+		curl -fsS --max-time 20 --data "$body" "$url" >/dev/null && break
+	else
+		wget -q -T 20 -O /dev/null --post-data "$body" "$url" && break
+	fi
+	sleep 30
+done
+
+if command -v systemctl >/dev/null 2>&1; then
+	systemctl disable bootimus-firstboot.service >/dev/null 2>&1
+fi
+rm -f /etc/systemd/system/bootimus-firstboot.service /var/lib/cloud/scripts/per-once/bootimus-firstboot "$0"
+`
+
+const firstBootUnit = `[Unit]
+Description=Report this machine to bootimus
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+ExecStart=/usr/local/sbin/bootimus-firstboot
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// firstBootInstallScript installs the agent into the system mounted at its
+// first argument: as a systemd unit, or a cloud-init per-once script where
+// there is no systemd.
+func (s *Server) firstBootInstallScript(mac string) string {
+	agent := strings.NewReplacer(
+		"@SERVER@", fmt.Sprintf("http://%s:%d", s.config.ServerAddr, s.config.HTTPPort),
+		"@MAC@", mac,
+	).Replace(firstBootAgentScript)
+
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\n# Installs the bootimus first-boot agent into the system at $1 (default /).\n")
+	sb.WriteString("ROOT=\"${1:-/}\"\n")
+	sb.WriteString("mkdir -p \"$ROOT/usr/local/sbin\"\n")
+	sb.WriteString("cat > \"$ROOT/usr/local/sbin/bootimus-firstboot\" <<'BOOTIMUS_AGENT'\n" + agent + "BOOTIMUS_AGENT\n")
+	sb.WriteString("chmod 755 \"$ROOT/usr/local/sbin/bootimus-firstboot\"\n")
+	sb.WriteString("if [ -d \"$ROOT/etc/systemd/system\" ]; then\n")
+	sb.WriteString("\tcat > \"$ROOT/etc/systemd/system/bootimus-firstboot.service\" <<'BOOTIMUS_UNIT'\n" + firstBootUnit + "BOOTIMUS_UNIT\n")
+	sb.WriteString("\tmkdir -p \"$ROOT/etc/systemd/system/multi-user.target.wants\"\n")
+	sb.WriteString("\tln -sf /etc/systemd/system/bootimus-firstboot.service \"$ROOT/etc/systemd/system/multi-user.target.wants/bootimus-firstboot.service\"\n")
+	sb.WriteString("elif [ -d \"$ROOT/etc/cloud\" ]; then\n")
+	sb.WriteString("\tmkdir -p \"$ROOT/var/lib/cloud/scripts/per-once\"\n")
+	sb.WriteString("\tln -sf /usr/local/sbin/bootimus-firstboot \"$ROOT/var/lib/cloud/scripts/per-once/bootimus-firstboot\"\n")
+	sb.WriteString("else\n")
+	sb.WriteString("\techo \"bootimus: no systemd or cloud-init in $ROOT; run /usr/local/sbin/bootimus-firstboot at boot\" >&2\n")
+	sb.WriteString("fi\n")
+	sb.WriteString(s.seedKeysScript())
+	return sb.String()
+}
+
+// seedKeysScript appends the seeded SSH keys to root's authorized_keys in
+// the system at $ROOT, and the default user's when that user exists there.
+func (s *Server) seedKeysScript() string {
+	if s.config.Storage == nil {
+		return ""
+	}
+	access, err := s.config.Storage.GetAccessConfig()
+	if err != nil {
+		return ""
+	}
+	keys := autoinstall.AuthorizedKeys(access)
+	if len(keys) == 0 {
+		return ""
+	}
+	homes := "\"$ROOT/root\""
+	if access.DefaultUsername != "" {
+		homes += " \"$ROOT/home/" + access.DefaultUsername + "\""
+	}
+	var sb strings.Builder
+	sb.WriteString("for home in " + homes + "; do\n")
+	sb.WriteString("\t[ -d \"$home\" ] || continue\n")
+	sb.WriteString("\tmkdir -p \"$home/.ssh\"\n")
+	sb.WriteString("\tcat >> \"$home/.ssh/authorized_keys\" <<'BOOTIMUS_KEYS'\n" + strings.Join(keys, "\n") + "\nBOOTIMUS_KEYS\n")
+	sb.WriteString("\tchmod 700 \"$home/.ssh\"\n\tchmod 600 \"$home/.ssh/authorized_keys\"\n")
+	sb.WriteString("\tchown -R --reference=\"$home\" \"$home/.ssh\" 2>/dev/null\n")
+	sb.WriteString("done\n")
+	return sb.String()
+}
+
+func firstBootMAC(r *http.Request) (string, bool) {
+	mac := strings.ToLower(strings.ReplaceAll(r.URL.Query().Get("mac"), "-", ":"))
+	_, err := net.ParseMAC(mac)
+	return mac, err == nil
+}
+
+// handleFirstBootInstall serves the install script on its own.
+func (s *Server) handleFirstBootInstall(w http.ResponseWriter, r *http.Request) {
+	mac, ok := firstBootMAC(r)
+	if !ok {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	log.Printf("First boot: install script served to %s (%s)", mac, r.RemoteAddr)
+	w.Header().Set("Content-Type", "text/x-shellscript; charset=utf-8")
+	fmt.Fprint(w, s.firstBootInstallScript(mac))
+}
+
+// handleFirstBootOverlay serves the install script as a cpio archive for
+// iPXE to load as an extra initrd; it lands at /bootimus/ in the installer.
+func (s *Server) handleFirstBootOverlay(w http.ResponseWriter, r *http.Request) {
+	mac, ok := firstBootMAC(r)
+	if !ok {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	var buf bytes.Buffer
+	err := initrd.WriteArchive(&buf, []initrd.File{
+		{Name: "bootimus/firstboot-install.sh", Mode: 0755, Data: []byte(s.firstBootInstallScript(mac))},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(buf.Bytes())
+}
+
+// handleFirstBootRegister records what the agent reports on the client.
+func (s *Server) handleFirstBootRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	mac, ok := firstBootMAC(r)
+	if !ok {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	if s.config.Storage == nil {
+		http.Error(w, "Registration requires database", http.StatusInternalServerError)
+		return
+	}
+	client, err := s.config.Storage.GetClient(mac)
+	if err != nil {
+		http.Error(w, "Unknown client", http.StatusNotFound)
+		return
+	}
+
+	list := func(v string) models.StringSlice {
+		var out models.StringSlice
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+		return out
+	}
+	now := time.Now()
+	client.InstalledHostname = r.FormValue("hostname")
+	client.InstalledIPs = list(r.FormValue("ips"))
+	client.InstalledOS = r.FormValue("os")
+	client.DiskSerials = list(r.FormValue("serials"))
+	client.RegisteredAt = &now
+	if err := s.config.Storage.UpdateClientRegistration(mac, client); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.logAndBroadcast("First boot: %s came up as %s (%s, %s)", mac, client.InstalledHostname,
+		strings.Join(client.InstalledIPs, ", "), client.InstalledOS)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
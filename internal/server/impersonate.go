@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// asClient lets an admin fetch /menu.ipxe as any client, named by the
+// X-Bootimus-As-MAC header or ?as_mac=, and see the policy trace behind it.
+// The admin's own credentials stand in for the boot token.
+func (s *Server) asClient(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mac := r.Header.Get("X-Bootimus-As-MAC")
+		if mac == "" {
+			mac = r.URL.Query().Get("as_mac")
+		}
+		if mac == "" {
+			next(w, r)
+			return
+		}
+		serve := func(w http.ResponseWriter, r *http.Request) {
+			s.serveMenuAs(w, r, strings.ToLower(strings.ReplaceAll(mac, "-", ":")))
+		}
+		if s.config.Auth != nil {
+			s.config.Auth.AdminMiddleware(serve)(w, r)
+			return
+		}
+		serve(w, r)
+	}
+}
+
+// serveMenuAs renders the script mac would get from /menu.ipxe right now,
+// without the side effects of a real request: nothing is logged as a boot,
+// no hooks run, and next-boot actions and try-once params stay pending.
+// The policy trace is prepended as comments.
+func (s *Server) serveMenuAs(w http.ResponseWriter, r *http.Request, mac string) {
+	if s.config.Storage == nil {
+		http.Error(w, "Viewing the menu as a client requires a database", http.StatusServiceUnavailable)
+		return
+	}
+	ip := r.Header.Get("X-Bootimus-As-IP")
+	if ip == "" {
+		ip = r.URL.Query().Get("as_ip")
+	}
+	log.Printf("Menu: %s viewing the boot menu as %s", r.RemoteAddr, mac)
+
+	var notes []string
+	script := ""
+	if r.URL.Query().Get("skip_task") == "" {
+		if task, err := s.config.Storage.GetPendingDiskTask(mac); err == nil {
+			if script, err = s.diskTaskBootScript(task); err == nil {
+				notes = append(notes, fmt.Sprintf("pending %s task #%d boots instead of the menu", task.Kind, task.ID))
+			}
+		}
+	}
+	if script == "" {
+		if local, ok := s.localBootScript(mac); ok {
+			script = local
+			notes = append(notes, "boots from local disk after its one-shot boot")
+		}
+	}
+	if script == "" {
+		next, overrides := s.pendingBoot(mac, false)
+		images, err := s.config.Storage.GetImagesForClient(mac)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		images = s.withholdUntrusted(mac, s.clientTrusted(mac), images)
+		mb, err := s.newMenuBuilder(images, mac, ip, next, overrides, false)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if mb.policy == nil {
+			notes = append(notes, "no boot policy")
+		} else {
+			notes = append(notes, mb.policy.Trace...)
+		}
+		if next.once {
+			notes = append(notes, fmt.Sprintf("one-shot boot of image #%d, then the local disk", next.imageID))
+		} else if next.imageID != 0 {
+			notes = append(notes, fmt.Sprintf("next boot pre-selects image #%d", next.imageID))
+		}
+		script = mb.Build()
+	}
+
+	if s.config.NetworkWarning != "" {
+		notes = append(notes, "WARNING: "+s.config.NetworkWarning)
+	}
+
+	var sb strings.Builder
+	header, rest, _ := strings.Cut(script, "\n")
+	sb.WriteString(header + "\n")
+	sb.WriteString(fmt.Sprintf("# Viewed as %s", mac))
+	if ip != "" {
+		sb.WriteString(" from " + ip)
+	}
+	sb.WriteString("\n")
+	for _, n := range notes {
+		sb.WriteString("# " + n + "\n")
+	}
+	sb.WriteString(rest)
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(sb.String()))
+}
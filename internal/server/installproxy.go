@@ -0,0 +1,63 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// installProxyVars are the proxy placeholders for a client at ip; all empty
+// when no install proxy setting covers it.
+func (s *Server) installProxyVars(ip net.IP) map[string]string {
+	vars := map[string]string{
+		"{{HTTP_PROXY}}":    "",
+		"{{HTTPS_PROXY}}":   "",
+		"{{NO_PROXY}}":      "",
+		"{{PROXY_PAC_URL}}": "",
+	}
+	st := s.installProxy.For(ip)
+	if st == nil {
+		return vars
+	}
+	vars["{{HTTP_PROXY}}"] = st.HTTPProxy
+	vars["{{HTTPS_PROXY}}"] = st.HTTPSProxy
+	if vars["{{HTTPS_PROXY}}"] == "" {
+		vars["{{HTTPS_PROXY}}"] = st.HTTPProxy
+	}
+	vars["{{NO_PROXY}}"] = st.NoProxy
+	vars["{{PROXY_PAC_URL}}"] = s.wpadURL(ip)
+	return vars
+}
+
+// wpadURL is the PAC file offered to a client at ip as DHCP option 252, or
+// "" when it has no install proxy.
+func (s *Server) wpadURL(ip net.IP) string {
+	st := s.installProxy.For(ip)
+	if st == nil {
+		return ""
+	}
+	if st.PACURL != "" {
+		return st.PACURL
+	}
+	return fmt.Sprintf("http://%s:%d/wpad.dat", s.config.ServerAddr, s.config.HTTPPort)
+}
+
+// handleProxyPAC serves /wpad.dat and /proxy.pac, generated from the
+// install proxy setting for the requesting client.
+func (s *Server) handleProxyPAC(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	st := s.installProxy.For(net.ParseIP(host))
+	if st == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if st.PACURL != "" {
+		http.Redirect(w, r, st.PACURL, http.StatusFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+	w.Write([]byte(st.PAC()))
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"log"
+
+	"bootimus/internal/iscsi"
+)
+
+// iscsiPort is the port menus point iSCSI sanboots at, 0 when the target
+// isn't running.
+func (s *Server) iscsiPort() int {
+	if !s.config.ISCSIEnabled {
+		return 0
+	}
+	return s.config.ISCSIPort
+}
+
+// iscsiTargetName is the IQN the admin API shows for an image, empty when
+// the target isn't running.
+func (s *Server) iscsiTargetName(filename string) string {
+	if !s.config.ISCSIEnabled {
+		return ""
+	}
+	return iscsi.TargetName(iscsi.DefaultPrefix, filename)
+}
+
+// iscsiTargets lists the enabled images published over iSCSI whose ISO is
+// in a library. A variant is published under its own name, backed by its
+// source's ISO.
+func (s *Server) iscsiTargets() []iscsi.Target {
+	images, err := s.config.Storage.ListImages()
+	if err != nil {
+		log.Printf("iSCSI: failed to list images: %v", err)
+		return nil
+	}
+	var targets []iscsi.Target
+	for _, img := range images {
+		if !img.Enabled || !img.ISCSIEnabled || img.IsVirtual() {
+			continue
+		}
+		file := img.DiskFilename()
+		if _, ok := s.libraries.Find(file); !ok {
+			continue
+		}
+		path, err := s.libraries.Join(file)
+		if err != nil {
+			continue
+		}
+		targets = append(targets, iscsi.Target{
+			Name:  iscsi.TargetName(iscsi.DefaultPrefix, img.Filename),
+			Path:  path,
+			Image: file,
+		})
+	}
+	return targets
+}
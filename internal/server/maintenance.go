@@ -0,0 +1,38 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"bootimus/internal/storage"
+)
+
+const orphanCheckInterval = 24 * time.Hour
+
+// checkOrphans logs references to deleted records and, with FixOrphans set,
+// repairs them.
+func (s *Server) checkOrphans() {
+	orphans, err := s.config.Storage.FindOrphans()
+	if err != nil {
+		log.Printf("Maintenance: Orphan check failed: %v", err)
+		return
+	}
+	found := false
+	for _, o := range orphans {
+		if o.Count > 0 {
+			found = true
+			log.Printf("Maintenance: %d orphaned %s (%s)", o.Count, o.Kind, o.Fix)
+		}
+	}
+	if !found || !s.config.FixOrphans {
+		return
+	}
+	fixed, err := s.config.Storage.FixOrphans(storage.OrphanKinds)
+	if err != nil {
+		log.Printf("Maintenance: Fixing orphans failed: %v", err)
+		return
+	}
+	for kind, n := range fixed {
+		log.Printf("Maintenance: Fixed %d orphaned %s", n, kind)
+	}
+}
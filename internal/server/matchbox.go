@@ -0,0 +1,151 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"bootimus/internal/autoinstall"
+	"bootimus/internal/matchbox"
+	"bootimus/internal/securepath"
+)
+
+// registerMatchbox adds Matchbox's HTTP endpoints to the boot server, so
+// machines and tooling pointed at a Matchbox URL work against bootimus.
+func (s *Server) registerMatchbox(mux *http.ServeMux) {
+	mux.HandleFunc("/boot.ipxe", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		script := matchbox.BootIPXE
+		if tok := s.bootTokenParam(false); tok != "" {
+			script = strings.TrimSuffix(script, "\n") + tok + "\n"
+		}
+		w.Write([]byte(script))
+	})
+	// Profiles and templates carry provisioning data such as keys and
+	// password hashes, so they are behind the boot token like /autoinstall/.
+	mux.HandleFunc("/ipxe", s.requireBootToken(s.handleMatchboxIPXE))
+	mux.HandleFunc("/ignition", s.requireBootToken(s.matchboxTemplate("ignition", "application/json")))
+	mux.HandleFunc("/cloud", s.requireBootToken(s.matchboxTemplate("cloud", "text/plain; charset=utf-8")))
+	mux.HandleFunc("/generic", s.requireBootToken(s.matchboxTemplate("generic", "text/plain; charset=utf-8")))
+	mux.HandleFunc("/metadata", s.requireBootToken(s.handleMatchboxMetadata))
+	mux.HandleFunc("/assets/", securepath.Handler("/assets/", s.matchbox.AssetsDir(), s.rejectPath("Matchbox asset"), func(w http.ResponseWriter, r *http.Request, rel, fullPath string) {
+		http.ServeFile(w, r, fullPath)
+	}))
+}
+
+// matchboxGroup finds the group for the request's labels, answering 404
+// itself when there is none.
+func (s *Server) matchboxGroup(w http.ResponseWriter, r *http.Request) (*matchbox.Group, bool) {
+	labels := matchbox.Labels(r.URL.Query())
+	g, err := s.matchbox.Match(labels)
+	if err != nil {
+		log.Printf("Matchbox: No group matches %v (%s)", labels, r.RemoteAddr)
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return g, true
+}
+
+func (s *Server) matchboxProfile(w http.ResponseWriter, r *http.Request, g *matchbox.Group) (*matchbox.Profile, bool) {
+	p, err := s.matchbox.Profile(g.Profile)
+	if err != nil {
+		log.Printf("Matchbox: Group %s: %v", g.ID, err)
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return p, true
+}
+
+func (s *Server) handleMatchboxIPXE(w http.ResponseWriter, r *http.Request) {
+	g, ok := s.matchboxGroup(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+
+	switch {
+	case g.DenyPXE:
+		log.Printf("Matchbox: %s (%s) is not allowed to PXE boot; exiting to local boot", g.ID, r.RemoteAddr)
+		w.Write([]byte("#!ipxe\nexit\n"))
+		return
+	case g.IPXEContents != "":
+		script := g.IPXEContents
+		if !strings.HasPrefix(script, "#!ipxe") {
+			script = "#!ipxe\n" + script
+		}
+		w.Write([]byte(script))
+		return
+	case g.IPXEURL != "":
+		fmt.Fprintf(w, "#!ipxe\nchain %s\n", g.IPXEURL)
+		return
+	}
+
+	p, ok := s.matchboxProfile(w, r, g)
+	if !ok {
+		return
+	}
+	script, err := matchbox.IPXEScript(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Printf("Matchbox: Serving profile %s to group %s (%s)", p.ID, g.ID, r.RemoteAddr)
+	w.Write([]byte(script))
+}
+
+// matchboxTemplate serves the profile's ignition, cloud or generic template
+// rendered with the group's data.
+func (s *Server) matchboxTemplate(kind, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, ok := s.matchboxGroup(w, r)
+		if !ok {
+			return
+		}
+		p, ok := s.matchboxProfile(w, r, g)
+		if !ok {
+			return
+		}
+		id := map[string]string{"ignition": p.IgnitionID, "cloud": p.CloudID, "generic": p.GenericID}[kind]
+		if kind == "ignition" {
+			switch strings.ToLower(filepath.Ext(id)) {
+			case ".yaml", ".yml", ".bu":
+				http.Error(w, "Butane/Fuze configs are not transpiled; store the .ign output instead", http.StatusNotImplemented)
+				return
+			}
+		}
+		tmpl, err := s.matchbox.Template(kind, id)
+		if err != nil {
+			log.Printf("Matchbox: Profile %s: %v", p.ID, err)
+			http.NotFound(w, r)
+			return
+		}
+		out, err := matchbox.Render(id, tmpl, matchbox.TemplateData(g, r.URL))
+		if err != nil {
+			log.Printf("Matchbox: Rendering %s/%s for group %s: %v", kind, id, g.ID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if s.config.Storage != nil {
+			switch {
+			case kind == "ignition":
+				out = s.injectAccess(out, "ignition", id)
+			case kind == "cloud" && autoinstall.IsCloudInitUserData(out):
+				out = s.injectAccess(out, "autoinstall", id)
+			}
+		}
+		log.Printf("Matchbox: Serving %s %s to group %s (%s)", kind, id, g.ID, r.RemoteAddr)
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(out))
+	}
+}
+
+func (s *Server) handleMatchboxMetadata(w http.ResponseWriter, r *http.Request) {
+	g, ok := s.matchboxGroup(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(matchbox.Metadata(matchbox.TemplateData(g, r.URL))))
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bootimus/internal/matchbox"
+)
+
+func TestMatchboxRequiresBootToken(t *testing.T) {
+	s := &Server{config: &Config{BootToken: "secret"}, matchbox: matchbox.New(t.TempDir())}
+	mux := http.NewServeMux()
+	s.registerMatchbox(mux)
+
+	for _, path := range []string{"/ipxe", "/ignition", "/cloud", "/generic", "/metadata"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path+"?mac=52-54-00-12-34-56", nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s without a token: got %d, want 401", path, rec.Code)
+		}
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path+"?mac=52-54-00-12-34-56&token=wrong", nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s with a wrong token: got %d, want 401", path, rec.Code)
+		}
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path+"?mac=52-54-00-12-34-56&token=secret", nil))
+		if rec.Code == http.StatusUnauthorized {
+			t.Errorf("%s with the token: got 401", path)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/boot.ipxe", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/boot.ipxe: got %d, want 200", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "&token=${boot-token:uristring}\n") {
+		t.Errorf("/boot.ipxe does not pass the boot token on:\n%s", rec.Body.String())
+	}
+}
@@ -1,7 +1,11 @@
 package server
 
 import (
+	"bootimus/internal/bootmethod"
+	"bootimus/internal/branding"
+	"bootimus/internal/iscsi"
 	"bootimus/internal/models"
+	"bootimus/internal/policy"
 	"bootimus/internal/profiles"
 	"bootimus/internal/tools"
 	"fmt"
@@ -10,8 +14,12 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 )
 
+// MenuBuilder is the one iPXE menu generator. Everything a menu depends on
+// is gathered into it up front, by newMenuBuilder for client menus, and
+// Build renders it without going back to the server or storage.
 type MenuBuilder struct {
 	images          []models.Image
 	groups          []*models.ImageGroup
@@ -20,54 +28,164 @@ type MenuBuilder struct {
 	serverAddr      string
 	httpPort        int
 	nfsPort         int
+	iscsiPort       int // the iSCSI target's port, 0 when it isn't running
 	groupStack      []uint
 	enabledTools    []tools.EnabledTool
+	remoteMenus     []*models.RemoteMenu // chained to from the main menu
 	nextBootImageID uint
 	profileManager  *profiles.Manager
+	paramOverrides  []*models.BootParamOverride
+	ntpServer       string
+	bannerURL       string
+	bootToken       string
+	attestSession   string        // the client's attestation session, when it is trusted
+	failoverURLs    []string      // other servers to retry boot fetches from
+	menuPIN         bool          // a PIN is configured, so protected entries ask for it
+	pinGroups       map[uint]bool // groups whose images need the PIN, directly or through a parent
+	liteInitrd      bool          // the client boots initrd-lite where an image has one
+	arch            string        // the client's iPXE build architecture, when an image has kernels for several
+	policy          *policy.Decision
+	forceImage      *models.Image // booted without showing the menu, by policy or the client's settings
+	forceReason     string        // what forced it, shown as it boots
+	defaultImage    *models.Image // the client's default image
 }
 
-func (s *Server) generateIPXEMenuWithGroups(images []models.Image, macAddress string, nextBootImageID ...uint) string {
+// generateIPXEMenuWithGroups renders the menu clients see. While a menu
+// draft is open that is the published snapshot; draft renders the tables as
+// edited instead, for previews.
+func (s *Server) generateIPXEMenuWithGroups(images []models.Image, macAddress, clientIP, attestSession string, next nextBoot, overrides []*models.BootParamOverride, draft bool) string {
+	mb, err := s.newMenuBuilder(images, macAddress, clientIP, next, overrides, draft)
+	if err != nil {
+		// Without groups and the theme the images are still bootable as a
+		// flat list.
+		log.Printf("Menu for %s: falling back to a plain image list: %v", macAddress, err)
+		mb = s.baseMenuBuilder(models.VisibleImages(images, time.Now()), macAddress)
+	}
+	mb.attestSession = attestSession
+	return mb.Build()
+}
+
+// baseMenuBuilder is a menu of images with no groups, theme or per-client
+// settings.
+func (s *Server) baseMenuBuilder(images []models.Image, macAddress string) *MenuBuilder {
+	return &MenuBuilder{
+		images:         images,
+		macAddress:     macAddress,
+		serverAddr:     s.config.ServerAddr,
+		httpPort:       s.config.HTTPPort,
+		nfsPort:        s.config.NFSPort,
+		iscsiPort:      s.iscsiPort(),
+		profileManager: s.config.ProfileManager,
+		ntpServer:      s.ntpServerAddr(),
+		bootToken:      s.config.BootToken,
+		failoverURLs:   s.config.FailoverURLs,
+	}
+}
+
+func (s *Server) newMenuBuilder(images []models.Image, macAddress, clientIP string, next nextBoot, overrides []*models.BootParamOverride, draft bool) (*MenuBuilder, error) {
+	images = models.VisibleImages(images, time.Now())
+	decision := s.evaluatePolicy(macAddress, clientIP)
+	images = applyPolicy(images, decision)
 	groups, err := s.config.Storage.ListImageGroups()
 	if err != nil {
-		return s.generateIPXEMenu(images, macAddress)
+		return nil, err
 	}
+	// PIN protection follows the groups as they are now, not as last
+	// published, so protecting a group takes effect straight away.
+	pinGroups := protectedGroups(groups)
 
 	theme, err := s.config.Storage.GetMenuTheme()
 	if err != nil {
 		log.Printf("Warning: Failed to load menu theme: %v", err)
 	}
 
+	if !draft {
+		images, groups, theme = s.publishedLayout(images, groups, theme)
+	}
+
 	serverURL := fmt.Sprintf("http://%s:%d", s.config.ServerAddr, s.config.HTTPPort)
 	enabledTools := s.toolsManager.GetEnabledTools(serverURL)
 
-	var nbID uint
-	if len(nextBootImageID) > 0 {
-		nbID = nextBootImageID[0]
+	mb := s.baseMenuBuilder(images, macAddress)
+	mb.groups = groups
+	mb.theme = theme
+	mb.enabledTools = enabledTools
+	mb.remoteMenus = s.remoteMenusFor(macAddress)
+	mb.nextBootImageID = next.imageID
+	mb.paramOverrides = overrides
+	mb.menuPIN = s.config.MenuPIN != ""
+	mb.pinGroups = pinGroups
+	mb.policy = decision
+	if next.once {
+		for i := range images {
+			if images[i].ID == next.imageID {
+				mb.forceImage, mb.forceReason = &images[i], "One-shot boot"
+			}
+		}
 	}
-
-	mb := &MenuBuilder{
-		images:          images,
-		groups:          groups,
-		theme:           theme,
-		macAddress:      macAddress,
-		serverAddr:      s.config.ServerAddr,
-		httpPort:        s.config.HTTPPort,
-		nfsPort:         s.config.NFSPort,
-		enabledTools:    enabledTools,
-		nextBootImageID: nbID,
-		profileManager:  s.config.ProfileManager,
+	if decision != nil && decision.Force != "" && next.imageID == 0 {
+		for i := range images {
+			if images[i].Filename == decision.Force && images[i].Enabled {
+				mb.forceImage, mb.forceReason = &images[i], "Boot policy"
+			}
+		}
+		if mb.forceImage == nil {
+			decision.Trace = append(decision.Trace, fmt.Sprintf("force %s ignored: not an enabled image this client can boot", decision.Force))
+		}
+	}
+	if _, ok := s.branding.Path(branding.AssetBanner); ok {
+		mb.bannerURL = serverURL + branding.URL(branding.AssetBanner)
+	}
+	if client, err := s.config.Storage.GetClient(macAddress); err == nil {
+		mb.liteInitrd = client.LiteInitrd
+		for i := range images {
+			if client.DefaultImageID != nil && images[i].ID == *client.DefaultImageID && images[i].Enabled {
+				mb.defaultImage = &images[i]
+			}
+		}
+		// A next-boot action or a policy's force still wins.
+		if mb.defaultImage != nil && client.BootImmediately && next.imageID == 0 && mb.forceImage == nil {
+			mb.forceImage, mb.forceReason = mb.defaultImage, "Default image"
+		}
+	}
+	for _, img := range images {
+		if len(img.KernelArches) > 1 {
+			mb.arch = s.clientArch(macAddress)
+			break
+		}
 	}
 
-	return mb.Build()
+	return mb, nil
+}
+
+// clientArch is the iPXE build architecture mac last reported, or "".
+func (s *Server) clientArch(mac string) string {
+	inv, err := s.config.Storage.GetHardwareInventoryHistory(mac, 1)
+	if err != nil || len(inv) == 0 {
+		return ""
+	}
+	return models.NormaliseArch(inv[0].BuildArch)
 }
 
 func (mb *MenuBuilder) Build() string {
 	var sb strings.Builder
 
 	sb.WriteString("#!ipxe\n\n")
+	if mb.ntpServer != "" {
+		// Fixes iPXE's own clock for HTTPS; builds without the ntp command
+		// just carry on.
+		sb.WriteString(fmt.Sprintf("ntp %s || echo NTP sync failed\n\n", mb.ntpServer))
+	}
+	if mb.bannerURL != "" {
+		// Builds without framebuffer console support keep the text menu.
+		sb.WriteString(fmt.Sprintf("console --picture %s || echo Console banner not supported\n\n", mb.bannerURL))
+	}
+	sb.WriteString(mb.buildColours())
 	sb.WriteString(mb.buildMainMenu())
 	sb.WriteString(mb.buildGroupMenus())
 	sb.WriteString(mb.buildImageBootSections())
+	sb.WriteString(mb.buildRescueSections())
+	sb.WriteString(mb.buildRemoteMenuSections())
 	sb.WriteString(mb.buildFooter())
 
 	return sb.String()
@@ -87,6 +205,9 @@ func (mb *MenuBuilder) resolveDefaultItem(visibleGroups []*models.ImageGroup, un
 	if mb.nextBootImageID > 0 {
 		return fmt.Sprintf("iso%d", mb.nextBootImageID)
 	}
+	if mb.defaultImage != nil {
+		return fmt.Sprintf("iso%d", mb.defaultImage.ID)
+	}
 	if mb.theme != nil {
 		switch mb.theme.DefaultMenuItem {
 		case "local", "shell", "reboot":
@@ -109,6 +230,46 @@ func (mb *MenuBuilder) menuTitle() string {
 	return "Bootimus - Boot Menu"
 }
 
+// buildColours sets iPXE's colour pairs from the theme: 0 for plain text,
+// 1 and 3 for menu items and headings, 2 for the selected item.
+func (mb *MenuBuilder) buildColours() string {
+	t := mb.theme
+	if t == nil {
+		return ""
+	}
+	colour := func(name string) int {
+		n, _ := models.MenuColour(name)
+		return n
+	}
+	var pairs []string
+	if t.TextColour != "" || t.BackgroundColour != "" {
+		fg, bg := colour(t.TextColour), colour(t.BackgroundColour)
+		for _, pair := range []int{0, 1, 3} {
+			pairs = append(pairs, fmt.Sprintf("cpair --foreground %d --background %d %d", fg, bg, pair))
+		}
+	}
+	if t.SelectedTextColour != "" || t.SelectedBackgroundColour != "" {
+		pairs = append(pairs, fmt.Sprintf("cpair --foreground %d --background %d 2", colour(t.SelectedTextColour), colour(t.SelectedBackgroundColour)))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	// Builds without the cpair command keep their own colours.
+	return strings.Join(pairs, " && ") + " || echo Menu colours not supported\n\n"
+}
+
+// motdLines are the theme's message of the day, one menu heading per line.
+func (mb *MenuBuilder) motdLines() []string {
+	if mb.theme == nil || strings.TrimSpace(mb.theme.MOTD) == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(mb.theme.MOTD, "\r\n", "\n"), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return lines
+}
+
 func encodePathSegments(path string) string {
 	segments := strings.Split(filepath.ToSlash(path), "/")
 	for i, seg := range segments {
@@ -121,6 +282,10 @@ func (mb *MenuBuilder) buildMainMenu() string {
 	var sb strings.Builder
 
 	sb.WriteString(":start\n")
+	if mb.forceImage != nil {
+		// Only the first pass; a failed boot comes back to the menu.
+		sb.WriteString("isset ${policy_forced} || goto policy_force\n")
+	}
 	sb.WriteString(fmt.Sprintf("menu %s\n", mb.menuTitle()))
 
 	rootGroups := mb.getRootGroups()
@@ -133,11 +298,25 @@ func (mb *MenuBuilder) buildMainMenu() string {
 		}
 	}
 
+	if motd := mb.motdLines(); len(motd) > 0 {
+		for _, line := range motd {
+			sb.WriteString(fmt.Sprintf("item --gap -- %s\n", line))
+		}
+		sb.WriteString("item --gap\n")
+	}
+
 	if len(mb.enabledTools) > 0 {
 		sb.WriteString("item --gap -- Tools:\n")
 		sb.WriteString("item tools Tools >>\n")
 	}
 
+	if len(mb.remoteMenus) > 0 {
+		sb.WriteString("item --gap -- Remote Menus:\n")
+		for _, m := range mb.remoteMenus {
+			sb.WriteString(fmt.Sprintf("item remote%d %s >>\n", m.ID, m.Name))
+		}
+	}
+
 	if len(visibleGroups) > 0 {
 		sb.WriteString("item --gap -- Groups:\n")
 		for _, group := range visibleGroups {
@@ -148,16 +327,14 @@ func (mb *MenuBuilder) buildMainMenu() string {
 	if len(ungroupedImages) > 0 {
 		sb.WriteString("item --gap -- Images:\n")
 		for _, img := range ungroupedImages {
-			sizeStr := formatSize(img.Size)
-			extractedTag := ""
-			if img.Extracted {
-				extractedTag = " [kernel]"
-			}
-			sb.WriteString(fmt.Sprintf("item iso%d %s (%s)%s\n", img.ID, img.Name, sizeStr, extractedTag))
+			sb.WriteString(mb.imageItem(img))
 		}
 	}
 
 	sb.WriteString("item --gap -- Options:\n")
+	if len(mb.rescueImages()) > 0 {
+		sb.WriteString("item rescue Rescue >>\n")
+	}
 	sb.WriteString("item local Boot from Local Disk\n")
 	sb.WriteString("item shell Drop to iPXE shell\n")
 	sb.WriteString("item reboot Reboot\n")
@@ -175,9 +352,27 @@ func (mb *MenuBuilder) buildMainMenu() string {
 	}
 	sb.WriteString("goto ${selected}\n\n")
 
+	if mb.forceImage != nil {
+		sb.WriteString(":policy_force\n")
+		sb.WriteString("set policy_forced 1\n")
+		sb.WriteString(fmt.Sprintf("echo %s: booting %s\n", mb.forceReason, mb.forceImage.Name))
+		sb.WriteString(fmt.Sprintf("goto iso%d\n\n", mb.forceImage.ID))
+	}
+
 	return sb.String()
 }
 
+func (mb *MenuBuilder) imageItem(img models.Image) string {
+	tags := ""
+	if img.Extracted {
+		tags = " [kernel]"
+	}
+	if mb.needsPIN(&img) {
+		tags += " [PIN]"
+	}
+	return fmt.Sprintf("item iso%d %s (%s)%s\n", img.ID, img.Name, formatSize(img.Size), tags)
+}
+
 func (mb *MenuBuilder) buildGroupMenus() string {
 	var sb strings.Builder
 
@@ -210,12 +405,7 @@ func (mb *MenuBuilder) buildGroupMenus() string {
 		if len(groupImages) > 0 {
 			sb.WriteString("item --gap -- Images:\n")
 			for _, img := range groupImages {
-				sizeStr := formatSize(img.Size)
-				extractedTag := ""
-				if img.Extracted {
-					extractedTag = " [kernel]"
-				}
-				sb.WriteString(fmt.Sprintf("item iso%d %s (%s)%s\n", img.ID, img.Name, sizeStr, extractedTag))
+				sb.WriteString(mb.imageItem(img))
 			}
 		}
 
@@ -247,57 +437,140 @@ func (mb *MenuBuilder) buildImageBootSections() string {
 			continue
 		}
 
-		sb.WriteString(fmt.Sprintf(":iso%d\n", img.ID))
-		sb.WriteString(fmt.Sprintf("echo Booting %s...\n", img.Name))
+		label := fmt.Sprintf("iso%d", img.ID)
+		sb.WriteString(fmt.Sprintf(":%s\n", label))
+		if mb.needsPIN(&img) {
+			sb.WriteString(mb.buildPINPrompt(&img))
+		} else {
+			sb.WriteString(fmt.Sprintf("echo Booting %s...\n", img.Name))
+			sb.WriteString(mb.withFailover(label, func(baseURL string) string {
+				return mb.buildImageBootBody(&img, mb.sessionURL(&img, baseURL))
+			}))
+		}
+
+		if img.GroupID != nil {
+			sb.WriteString(fmt.Sprintf("goto group%d\n", *img.GroupID))
+		} else {
+			sb.WriteString("goto start\n")
+		}
+	}
 
-		encodedFilename := encodePathSegments(img.Filename)
-		cacheDir := encodePathSegments(strings.TrimSuffix(img.Filename, filepath.Ext(img.Filename)))
+	return sb.String()
+}
 
-		switch img.BootMethod {
-		case "nbd":
-			sb.WriteString("echo Using NBD (Network Block Device) mount...\n")
-			sb.WriteString(fmt.Sprintf("kernel http://%s:%d/bootenv/vmlinuz-lts\n", mb.serverAddr, mb.httpPort))
-			sb.WriteString(fmt.Sprintf("initrd http://%s:%d/bootenv/initramfs-bootimus\n", mb.serverAddr, mb.httpPort))
-			sb.WriteString(fmt.Sprintf("imgargs vmlinuz-lts init=/init iso=%s server=%s nbdport=10809 console=tty0 console=ttyS0\n", encodedFilename, mb.serverAddr))
-			sb.WriteString("boot || goto failed\n")
+func (mb *MenuBuilder) buildImageBootBody(img *models.Image, baseURL string) string {
+	var sb strings.Builder
 
-		case "nfs":
-			sb.WriteString("echo Using NFS root (streamed, low memory)...\n")
-			nfsPath := strings.TrimSuffix(img.Filename, filepath.Ext(img.Filename))
-			sb.WriteString(fmt.Sprintf("kernel http://%s:%d/boot/%s/vmlinuz initrd=initrd root=/dev/nfs boot=casper netboot=nfs nfsroot=%s:/%s/iso,vers=3,tcp,port=%d,mountport=%d,nolock ip=dhcp\n", mb.serverAddr, mb.httpPort, cacheDir, mb.serverAddr, nfsPath, mb.nfsPort, mb.nfsPort))
-			sb.WriteString(fmt.Sprintf("initrd http://%s:%d/boot/%s/initrd\n", mb.serverAddr, mb.httpPort, cacheDir))
-			sb.WriteString("boot || goto failed\n")
+	diskFilename := img.DiskFilename()
+	encodedFilename := encodePathSegments(diskFilename)
+	cacheDir := encodePathSegments(strings.TrimSuffix(diskFilename, filepath.Ext(diskFilename)))
+
+	if p, ok := bootmethod.Get(img.BootMethod); ok {
+		return p.Script(bootmethod.Stanza{
+			Image:      img,
+			BaseURL:    baseURL,
+			ServerAddr: mb.serverAddr,
+			MAC:        mb.macAddress,
+			Arch:       mb.arch,
+			ISOURL:     fmt.Sprintf("%s/isos/%s", baseURL, encodedFilename),
+			BootDir:    fmt.Sprintf("%s/boot/%s", baseURL, cacheDir),
+			RoutesURL:  baseURL + "/bootmethod/" + p.Name(),
+		})
+	}
+
+	switch img.BootMethod {
+	case "nbd":
+		sb.WriteString("echo Using NBD (Network Block Device) mount...\n")
+		sb.WriteString(fmt.Sprintf("kernel %s/bootenv/vmlinuz-lts\n", baseURL))
+		sb.WriteString(fmt.Sprintf("initrd %s/bootenv/initramfs-bootimus\n", baseURL))
+		sb.WriteString(fmt.Sprintf("imgargs vmlinuz-lts init=/init iso=%s server=%s nbdport=10809 console=tty0 console=ttyS0\n", encodedFilename, mb.serverAddr))
+		sb.WriteString("boot || goto failed\n")
 
-		case "kernel":
-			sb.WriteString("echo Loading kernel and initrd...\n")
-			if img.AutoInstallEnabled {
-				sb.WriteString("echo Auto-install enabled for this image\n")
-			}
+	case "nfs":
+		sb.WriteString("echo Using NFS root (streamed, low memory)...\n")
+		nfsPath := strings.TrimSuffix(diskFilename, filepath.Ext(diskFilename))
+		sb.WriteString(fmt.Sprintf("kernel %s/boot/%s/vmlinuz initrd=initrd root=/dev/nfs boot=casper netboot=nfs nfsroot=%s:/%s/iso,vers=3,tcp,port=%d,mountport=%d,nolock ip=dhcp\n", baseURL, cacheDir, mb.serverAddr, nfsPath, mb.nfsPort, mb.nfsPort))
+		sb.WriteString(fmt.Sprintf("initrd %s/boot/%s/%s\n", baseURL, cacheDir, mb.initrdFile(img)))
+		sb.WriteString("boot || goto failed\n")
 
-			sb.WriteString(mb.buildKernelBootSection(&img, encodedFilename, cacheDir))
+	case "remote":
+		sb.WriteString("echo Loading remote kernel and initrd...\n")
+		sb.WriteString(mb.buildRemoteBootSection(img, baseURL, encodedFilename, cacheDir))
 
-		default:
-			sb.WriteString(fmt.Sprintf("sanboot --no-describe --drive 0x80 http://%s:%d/isos/%s?mac=%s\n", mb.serverAddr, mb.httpPort, encodedFilename, mb.macAddress))
+	case "kernel":
+		sb.WriteString("echo Loading kernel and initrd...\n")
+		if img.AutoInstallEnabled {
+			sb.WriteString("echo Auto-install enabled for this image\n")
 		}
 
-		if img.GroupID != nil {
-			sb.WriteString(fmt.Sprintf("goto group%d\n", *img.GroupID))
-		} else {
-			sb.WriteString("goto start\n")
+		sb.WriteString(mb.buildKernelBootSection(img, baseURL, encodedFilename, cacheDir))
+
+	default:
+		if img.IsExternalISO() && !img.CacheRemote {
+			sb.WriteString(fmt.Sprintf("sanboot --no-describe --drive 0x80 %s\n", img.ISOURL))
+			break
+		}
+		if img.ISCSIEnabled && mb.iscsiPort != 0 {
+			// Without --no-describe iPXE leaves an iBFT behind, which is
+			// how Windows setup finds its media again after iPXE is gone.
+			sb.WriteString(fmt.Sprintf("sanboot --drive 0x80 iscsi:%s::%d:0:%s\n", mb.serverAddr, mb.iscsiPort, iscsi.TargetName(iscsi.DefaultPrefix, img.Filename)))
+			break
 		}
+		sb.WriteString(fmt.Sprintf("sanboot --no-describe --drive 0x80 %s/isos/%s?mac=%s\n", baseURL, encodedFilename, mb.macAddress))
 	}
 
 	return sb.String()
 }
 
-func (mb *MenuBuilder) buildKernelBootSection(img *models.Image, encodedFilename, cacheDir string) string {
-	var sb strings.Builder
+// initrdFile is the name of the initrd to load from an image's boot
+// directory: the slimmed one for clients set to use it, if it was built.
+func (mb *MenuBuilder) initrdFile(img *models.Image) string {
+	if mb.liteInitrd && img.LiteInitrdSize > 0 {
+		return "initrd-lite"
+	}
+	return "initrd"
+}
 
-	baseURL := fmt.Sprintf("http://%s:%d", mb.serverAddr, mb.httpPort)
+// kernelDir is where in an image's boot directory the client's kernel and
+// initrd are: a subdirectory for a multi-arch ISO whose primary kernel is
+// for another architecture, otherwise the directory itself. iPXE built for
+// BIOS reports i386 whatever the CPU, so i386 clients keep an x86_64
+// primary kernel.
+func (mb *MenuBuilder) kernelDir(img *models.Image, cacheDir string) string {
+	if len(img.KernelArches) < 2 || mb.arch == "" || mb.arch == img.KernelArches[0] {
+		return cacheDir
+	}
+	if mb.arch == "i386" && img.KernelArches[0] == "x86_64" {
+		return cacheDir
+	}
+	for _, arch := range img.KernelArches[1:] {
+		if arch == mb.arch {
+			return cacheDir + "/" + arch
+		}
+	}
+	return cacheDir
+}
+
+func (mb *MenuBuilder) baseURL() string {
+	return fmt.Sprintf("http://%s:%d", mb.serverAddr, mb.httpPort)
+}
+
+// sessionURL is baseURL as img's boot section uses it: under the client's
+// attestation session for private images, which only trusted clients may
+// download.
+func (mb *MenuBuilder) sessionURL(img *models.Image, baseURL string) string {
+	if mb.attestSession == "" || img.Public {
+		return baseURL
+	}
+	return baseURL + "/trusted/" + mb.attestSession
+}
+
+func (mb *MenuBuilder) buildKernelBootSection(img *models.Image, baseURL, encodedFilename, cacheDir string) string {
+	var sb strings.Builder
 
 	autoInstallParam := ""
-	if img.AutoInstallEnabled {
-		autoInstallParam = " autoinstall"
+	if args := mb.autoInstallArgs(img, baseURL); args != "" {
+		autoInstallParam = " " + args
 	}
 
 	bootParams := mb.resolveBootParams(img, baseURL, encodedFilename, cacheDir)
@@ -324,17 +597,87 @@ func (mb *MenuBuilder) buildKernelBootSection(img *models.Image, encodedFilename
 		sb.WriteString("boot || goto failed\n")
 
 	default:
+		initrd := mb.initrdFile(img)
+		if dir := mb.kernelDir(img, cacheDir); dir != cacheDir {
+			sb.WriteString(fmt.Sprintf("echo Using the %s kernel\n", mb.arch))
+			cacheDir, initrd = dir, "initrd"
+		}
 		sb.WriteString(fmt.Sprintf("kernel %s/boot/%s/vmlinuz%s%s\n", baseURL, cacheDir, autoInstallParam, bootParams))
-		sb.WriteString(fmt.Sprintf("initrd %s/boot/%s/initrd\n", baseURL, cacheDir))
+		sb.WriteString(fmt.Sprintf("initrd %s/boot/%s/%s\n", baseURL, cacheDir, initrd))
+		if img.FirstBootAgent {
+			sb.WriteString(fmt.Sprintf("initrd --name firstboot.cpio %s/firstboot/overlay.cpio?mac=%s\n", baseURL, mb.macAddress))
+		}
 		sb.WriteString("boot || goto failed\n")
 	}
 
 	return sb.String()
 }
 
+func (mb *MenuBuilder) buildRemoteBootSection(img *models.Image, baseURL, encodedFilename, cacheDir string) string {
+	var sb strings.Builder
+
+	kernelURL, initrdURL := remoteBootURLs(img, baseURL, mb.macAddress)
+
+	kernelLine := "kernel " + kernelURL
+	if initrdURL != "" {
+		kernelLine += " initrd=initrd"
+	}
+	if args := mb.autoInstallArgs(img, baseURL); args != "" {
+		kernelLine += " " + args
+	}
+	if params := mb.resolveBootParams(img, baseURL, encodedFilename, cacheDir); params != "" {
+		kernelLine += " " + params
+	}
+	sb.WriteString(kernelLine + "\n")
+	if initrdURL != "" {
+		sb.WriteString(fmt.Sprintf("initrd --name initrd %s\n", initrdURL))
+	}
+	sb.WriteString("boot || goto failed\n")
+
+	return sb.String()
+}
+
+// autoInstallArgs points the installer at the image's auto-install script,
+// in the form its script type is read in. Windows picks its answer file up
+// another way.
+func (mb *MenuBuilder) autoInstallArgs(img *models.Image, baseURL string) string {
+	if !img.AutoInstallEnabled {
+		return ""
+	}
+	if img.AutoInstallScript == "" {
+		return "autoinstall"
+	}
+	scriptPath := "/autoinstall/" + url.PathEscape(img.Filename)
+	scriptURL := fmt.Sprintf("%s%s?mac=%s", baseURL, scriptPath, mb.macAddress)
+	if mb.bootToken != "" {
+		scriptURL += "&token=" + url.QueryEscape(mb.bootToken)
+	}
+	pathURL := mb.autoInstallPathURL(img, baseURL)
+	if pathURL == "" {
+		pathURL = scriptURL
+	}
+	switch img.AutoInstallScriptType {
+	case "preseed":
+		return "auto=true priority=critical preseed/url=" + pathURL
+	case "kickstart":
+		return "inst.ks=" + pathURL
+	case "autoinstall":
+		// A NoCloud seed has files appended to it, so no query string.
+		return fmt.Sprintf("autoinstall ds=nocloud-net;s=%s%s/nocloud/%s/", authURL(baseURL, mb.bootToken), scriptPath, mb.macAddress)
+	case "autounattend":
+		return ""
+	default:
+		return "autoinstall=" + scriptURL
+	}
+}
+
 func (mb *MenuBuilder) resolveBootParams(img *models.Image, baseURL, encodedFilename, cacheDir string) string {
 	params := img.BootParams
 
+	if img.IsVirtual() {
+		return mb.substituteBootVars(mb.applyParamOverrides(params, img), img, baseURL, encodedFilename, cacheDir)
+	}
+
 	if params == "" && mb.profileManager != nil && img.Distro != "" {
 		hasSquashfs := img.SquashfsPath != ""
 		params = mb.profileManager.GetBootParams(img.Distro, hasSquashfs)
@@ -344,7 +687,44 @@ func (mb *MenuBuilder) resolveBootParams(img *models.Image, baseURL, encodedFile
 		params = fmt.Sprintf("iso-url=%s/isos/%s ip=dhcp", baseURL, encodedFilename)
 	}
 
+	params = mb.applyParamOverrides(params, img)
+
+	return mb.substituteBootVars(params, img, baseURL, encodedFilename, cacheDir)
+}
+
+// applyParamOverrides layers the client's overrides onto the image params:
+// every-image entries first, then image-specific ones, then try-once ones,
+// then arguments added by boot policy.
+func (mb *MenuBuilder) applyParamOverrides(params string, img *models.Image) string {
+	if img.Distro == "windows" || img.Distro == "windows7" {
+		return params
+	}
+	for _, once := range []bool{false, true} {
+		for _, specific := range []bool{false, true} {
+			for _, o := range mb.paramOverrides {
+				if o.Once != once || (o.ImageFilename != "") != specific {
+					continue
+				}
+				if specific && o.ImageFilename != img.Filename {
+					continue
+				}
+				if o.Replace {
+					params = o.Params
+				} else {
+					params = strings.TrimSpace(params + " " + o.Params)
+				}
+			}
+		}
+	}
+	if mb.policy != nil && len(mb.policy.Args) > 0 {
+		params = strings.TrimSpace(params + " " + strings.Join(mb.policy.Args, " "))
+	}
+	return params
+}
+
+func (mb *MenuBuilder) substituteBootVars(params string, img *models.Image, baseURL, encodedFilename, cacheDir string) string {
 	params = strings.ReplaceAll(params, "{{BASE_URL}}", baseURL)
+	params = strings.ReplaceAll(params, "{{AUTH_URL}}", authURL(baseURL, mb.bootToken))
 	params = strings.ReplaceAll(params, "{{CACHE_DIR}}", cacheDir)
 	params = strings.ReplaceAll(params, "{{FILENAME}}", encodedFilename)
 	params = strings.ReplaceAll(params, "{{MAC}}", mb.macAddress)
@@ -355,6 +735,78 @@ func (mb *MenuBuilder) resolveBootParams(img *models.Image, baseURL, encodedFile
 	return strings.TrimSpace(params)
 }
 
+func (mb *MenuBuilder) rescueImages() []models.Image {
+	var result []models.Image
+	for _, img := range mb.images {
+		if !img.Enabled || !img.RescueEnabled || img.BootMethod != "kernel" {
+			continue
+		}
+		if img.Distro == "windows" || img.Distro == "windows7" {
+			continue
+		}
+		result = append(result, img)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result
+}
+
+func (mb *MenuBuilder) buildRescueSections() string {
+	images := mb.rescueImages()
+	if len(images) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString(":rescue\n")
+	sb.WriteString(fmt.Sprintf("menu %s - Rescue\n", mb.menuTitle()))
+	for _, img := range images {
+		sb.WriteString(fmt.Sprintf("item rescue%d %s (rescue)\n", img.ID, img.Name))
+	}
+	sb.WriteString("item --gap --\n")
+	sb.WriteString("item start << Back to main menu\n")
+	sb.WriteString("choose selected || goto start\n")
+	sb.WriteString("goto ${selected}\n\n")
+
+	for _, img := range images {
+		diskFilename := img.DiskFilename()
+		encodedFilename := encodePathSegments(diskFilename)
+		cacheDir := encodePathSegments(strings.TrimSuffix(diskFilename, filepath.Ext(diskFilename)))
+
+		rescueParams := img.RescueParams
+		if rescueParams == "" {
+			rescueParams = defaultRescueParams
+		}
+
+		label := fmt.Sprintf("rescue%d", img.ID)
+		sb.WriteString(fmt.Sprintf(":%s\n", label))
+		sb.WriteString(fmt.Sprintf("echo Booting %s in rescue mode...\n", img.Name))
+		sb.WriteString(mb.withFailover(label, func(baseURL string) string {
+			baseURL = mb.sessionURL(&img, baseURL)
+			params := mb.resolveBootParams(&img, baseURL, encodedFilename, cacheDir) + " " +
+				mb.substituteBootVars(rescueParams, &img, baseURL, encodedFilename, cacheDir)
+			if strings.Contains(params, "initrd=") {
+				// The EFI stub loads only the initrds named on the command line.
+				params += " initrd=rescue.cpio"
+			}
+			overlayURL := fmt.Sprintf("%s/rescue/overlay.cpio?mac=%s", baseURL, mb.macAddress)
+			if mb.bootToken != "" {
+				overlayURL += "&token=" + url.QueryEscape(mb.bootToken)
+			}
+			return fmt.Sprintf("imgfetch --name checkin %s/rescue/checkin?mac=%s&ip=${ip}&stage=booting&image=%s && imgfree checkin || echo Rescue check-in failed, continuing\n", baseURL, mb.macAddress, url.QueryEscape(img.Filename)) +
+				fmt.Sprintf("kernel %s/boot/%s/vmlinuz %s\n", baseURL, cacheDir, params) +
+				fmt.Sprintf("initrd %s/boot/%s/%s\n", baseURL, cacheDir, mb.initrdFile(&img)) +
+				fmt.Sprintf("initrd --name rescue.cpio %s\n", overlayURL) +
+				"boot || goto failed\n"
+		}))
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
 func (mb *MenuBuilder) buildFooter() string {
 	var sb strings.Builder
 
@@ -385,8 +837,10 @@ func (mb *MenuBuilder) buildFooter() string {
 				sb.WriteString(fmt.Sprintf("chain %s || goto failed\n\n", t.KernelURL))
 			}
 		case "memdisk":
+			// memdisk only runs under BIOS.
+			sb.WriteString("iseq ${platform} efi && echo memdisk tools need BIOS boot && goto failed ||\n")
 			sb.WriteString(fmt.Sprintf("initrd %s\n", t.KernelURL))
-			sb.WriteString("chain memdisk raw || goto failed\n\n")
+			sb.WriteString(fmt.Sprintf("chain http://%s:%d/memdisk raw || goto failed\n\n", mb.serverAddr, mb.httpPort))
 		default:
 			sb.WriteString(fmt.Sprintf("kernel %s %s\n", t.KernelURL, t.BootParams))
 			if t.InitrdURL != "" {
@@ -407,11 +861,12 @@ shell
 :reboot
 reboot
 
-:failed
-echo Boot failed, returning to menu in 5 seconds...
-sleep 5
-goto start
 `)
+	sb.WriteString(":failed\n")
+	sb.WriteString("echo Boot failed, returning to menu in 5 seconds...\n")
+	sb.WriteString(fmt.Sprintf("imgfetch --name booterror http://%s:%d/api/boot-error?mac=%s&item=${selected:uristring}&errno=${errno} && imgfree booterror || echo Could not report the error\n", mb.serverAddr, mb.httpPort, mb.macAddress))
+	sb.WriteString("sleep 5\n")
+	sb.WriteString("goto start\n")
 	return sb.String()
 }
 
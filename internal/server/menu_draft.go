@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"bootimus/internal/models"
+)
+
+// publishedLayout swaps in the snapshot taken when the menu draft was
+// opened. Images added since stay hidden until the draft is published.
+func (s *Server) publishedLayout(images []models.Image, groups []*models.ImageGroup, theme *models.MenuTheme) ([]models.Image, []*models.ImageGroup, *models.MenuTheme) {
+	snap, err := s.config.Storage.GetMenuSnapshot()
+	if err != nil {
+		return images, groups, theme
+	}
+	snapGroups, snapImages, snapTheme, err := snap.Layout()
+	if err != nil {
+		log.Printf("Menu draft: unreadable snapshot, serving the draft: %v", err)
+		return images, groups, theme
+	}
+
+	layout := make(map[string]models.MenuImageLayout, len(snapImages))
+	for _, l := range snapImages {
+		layout[l.Filename] = l
+	}
+	published := make([]models.Image, 0, len(images))
+	for _, img := range images {
+		l, ok := layout[img.Filename]
+		if !ok {
+			continue
+		}
+		img.Name = l.Name
+		img.GroupID = l.GroupID
+		img.Order = l.Order
+		published = append(published, img)
+	}
+	if snapTheme == nil {
+		snapTheme = theme
+	}
+	return published, snapGroups, snapTheme
+}
+
+// PreviewMenu renders the menu mac would get from the draft, without the
+// side effects of a real boot (next-boot and one-shot params are left
+// alone).
+func (s *Server) PreviewMenu(mac string) (string, error) {
+	if s.config.Storage == nil {
+		return "", fmt.Errorf("menu preview requires a database")
+	}
+	mac = strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
+	images, err := s.config.Storage.GetImagesForClient(mac)
+	if err != nil {
+		return "", err
+	}
+	overrides, _ := s.config.Storage.ListBootParamOverrides(mac)
+	images = s.withholdUntrusted(mac, s.clientTrusted(mac), images)
+	script := s.generateIPXEMenuWithGroups(images, mac, "", "", nextBoot{}, overrides, true)
+	if s.config.NetworkWarning != "" {
+		header, rest, _ := strings.Cut(script, "\n")
+		script = header + "\n# WARNING: " + s.config.NetworkWarning + "\n" + rest
+	}
+	return script, nil
+}
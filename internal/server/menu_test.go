@@ -0,0 +1,272 @@
+package server
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bootimus/internal/bootmethod"
+	"bootimus/internal/models"
+	"bootimus/internal/profiles"
+	"bootimus/internal/storage"
+	"bootimus/internal/tools"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden menus in testdata")
+
+// goldenMenu compares script with testdata/menu/<name>.ipxe, or rewrites it
+// with -update.
+func goldenMenu(t *testing.T, name, script string) {
+	t.Helper()
+	path := filepath.Join("testdata", "menu", name+".ipxe")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test ./internal/server -update to create it)", err)
+	}
+	if string(want) != script {
+		t.Errorf("menu differs from %s (run with -update if the change is intended)\ngot:\n%s", path, script)
+	}
+}
+
+// chainProvider is a registered boot method that chains an iPXE script it
+// serves itself.
+type chainProvider struct{}
+
+func (chainProvider) Name() string                 { return "test-chain" }
+func (chainProvider) Description() string          { return "Chains a generated script" }
+func (chainProvider) Validate(*models.Image) error { return nil }
+func (chainProvider) Script(s bootmethod.Stanza) string {
+	return "chain " + s.RoutesURL + "/script.ipxe?mac=" + s.MAC + "&iso=" + s.ISOURL + " || goto failed\n"
+}
+
+func init() { bootmethod.Register(chainProvider{}) }
+
+func seededProfiles(t *testing.T) (*profiles.Manager, []*models.DistroProfile) {
+	t.Helper()
+	store, err := storage.NewSQLiteStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	pm := profiles.NewManager(store)
+	if err := pm.SeedProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	list, err := store.ListDistroProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pm, list
+}
+
+func testMenuBuilder(pm *profiles.Manager, images ...models.Image) *MenuBuilder {
+	return &MenuBuilder{
+		images:         images,
+		macAddress:     "52:54:00:12:34:56",
+		serverAddr:     "192.168.1.10",
+		httpPort:       8080,
+		nfsPort:        2049,
+		iscsiPort:      3260,
+		profileManager: pm,
+	}
+}
+
+func TestMenuGolden_Distros(t *testing.T) {
+	pm, list := seededProfiles(t)
+	for _, p := range list {
+		t.Run(p.ProfileID, func(t *testing.T) {
+			img := models.Image{
+				ID: 1, Name: p.DisplayName, Filename: p.ProfileID + "-1.0-amd64.iso", Size: 2 << 30,
+				Enabled: true, Extracted: true, BootMethod: "kernel", Distro: p.ProfileID,
+			}
+			goldenMenu(t, "distro-"+p.ProfileID, testMenuBuilder(pm, img).Build())
+		})
+	}
+}
+
+func TestMenuGolden_BootMethods(t *testing.T) {
+	pm, _ := seededProfiles(t)
+	tests := []struct {
+		name string
+		img  models.Image
+	}{
+		{"sanboot", models.Image{BootMethod: "sanboot"}},
+		{"sanboot-iscsi", models.Image{BootMethod: "sanboot", ISCSIEnabled: true}},
+		{"nbd", models.Image{BootMethod: "nbd", Extracted: true}},
+		{"nfs", models.Image{BootMethod: "nfs", Extracted: true, Distro: "ubuntu"}},
+		{"provider", models.Image{BootMethod: "test-chain"}},
+		{"kernel-squashfs", models.Image{BootMethod: "kernel", Extracted: true, Distro: "ubuntu", SquashfsPath: "casper/filesystem.squashfs"}},
+		{"kernel-multiarch", models.Image{BootMethod: "kernel", Extracted: true, Distro: "debian", KernelArches: models.StringSlice{"x86_64", "arm64"}}},
+		{"remote", models.Image{BootMethod: "remote", Filename: "netboot.remote", KernelURL: "https://example.com/vmlinuz", InitrdURL: "https://example.com/initrd", BootParams: "console=ttyS0 url={{BASE_URL}}/x"}},
+		{"remote-cached", models.Image{BootMethod: "remote", Filename: "netboot.remote", KernelURL: "https://example.com/vmlinuz", InitrdURL: "https://example.com/initrd", CacheRemote: true}},
+		{"external", models.Image{BootMethod: "sanboot", Filename: "ubuntu.url", ISOURL: "http://mirror.lab/isos/ubuntu-24.04-live-server-amd64.iso"}},
+		{"external-cached", models.Image{BootMethod: "sanboot", Filename: "ubuntu.url", ISOURL: "http://mirror.lab/isos/ubuntu-24.04-live-server-amd64.iso", CacheRemote: true}},
+		{"autoinstall-preseed", models.Image{BootMethod: "kernel", Extracted: true, Distro: "debian", AutoInstallEnabled: true, AutoInstallScript: "d-i", AutoInstallScriptType: "preseed"}},
+		{"autoinstall-kickstart", models.Image{BootMethod: "kernel", Extracted: true, Distro: "fedora", AutoInstallEnabled: true, AutoInstallScript: "ks", AutoInstallScriptType: "kickstart"}},
+		{"autoinstall-subiquity", models.Image{BootMethod: "kernel", Extracted: true, Distro: "ubuntu", AutoInstallEnabled: true, AutoInstallScript: "#cloud-config", AutoInstallScriptType: "autoinstall"}},
+		{"autoinstall-windows", models.Image{BootMethod: "kernel", Extracted: true, Distro: "windows", AutoInstallEnabled: true, AutoInstallScript: "<unattend/>", AutoInstallScriptType: "autounattend"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := tt.img
+			img.ID, img.Name, img.Size, img.Enabled = 7, "Test Image", 700<<20, true
+			if img.Filename == "" {
+				img.Filename = "test image.iso"
+			}
+			mb := testMenuBuilder(pm, img)
+			mb.arch = "arm64"
+			goldenMenu(t, "method-"+tt.name, mb.Build())
+		})
+	}
+}
+
+// autoInstallDistros covers each auto-install script type the menu knows.
+var autoInstallDistros = []struct {
+	distro, scriptType string
+}{
+	{"ubuntu", "autoinstall"},
+	{"debian", "preseed"},
+	{"rocky", "kickstart"},
+	{"arch", ""},
+	{"windows", "autounattend"},
+}
+
+func TestMenuGolden_AutoInstall(t *testing.T) {
+	pm, _ := seededProfiles(t)
+	for _, d := range autoInstallDistros {
+		for _, on := range []bool{false, true} {
+			name := d.distro + "-off"
+			if on {
+				name = d.distro + "-on"
+			}
+			t.Run(name, func(t *testing.T) {
+				img := models.Image{
+					ID: 3, Name: d.distro, Filename: d.distro + ".iso", Size: 1 << 30, Enabled: true,
+					Extracted: true, BootMethod: "kernel", Distro: d.distro,
+					AutoInstallEnabled: on, AutoInstallScript: "# script", AutoInstallScriptType: d.scriptType,
+				}
+				mb := testMenuBuilder(pm, img)
+				mb.bootToken = "s3cret"
+				goldenMenu(t, "autoinstall-"+name, mb.Build())
+			})
+		}
+	}
+}
+
+// An unregistered client's menu has no MAC to put in a per-MAC path, so
+// its installers fetch the script from /autoinstall/ instead.
+func TestMenuGolden_AutoInstallUnknownClient(t *testing.T) {
+	pm, _ := seededProfiles(t)
+	for _, d := range autoInstallDistros {
+		if d.scriptType != "preseed" && d.scriptType != "kickstart" {
+			continue
+		}
+		t.Run(d.distro, func(t *testing.T) {
+			img := models.Image{
+				ID: 3, Name: d.distro, Filename: d.distro + ".iso", Size: 1 << 30, Enabled: true,
+				Extracted: true, BootMethod: "kernel", Distro: d.distro,
+				AutoInstallEnabled: true, AutoInstallScript: "# script", AutoInstallScriptType: d.scriptType,
+			}
+			mb := testMenuBuilder(pm, img)
+			mb.macAddress = "unknown"
+			goldenMenu(t, "autoinstall-"+d.distro+"-unknown-client", mb.Build())
+		})
+	}
+}
+
+func TestMenuGolden_Layouts(t *testing.T) {
+	pm, _ := seededProfiles(t)
+	id := func(n uint) *uint { return &n }
+	linux := &models.ImageGroup{ID: 1, Name: "Linux", Enabled: true}
+	servers := &models.ImageGroup{ID: 2, Name: "Servers", Enabled: true, ParentID: id(1), Parent: linux}
+	hidden := &models.ImageGroup{ID: 3, Name: "Hidden", Enabled: false}
+	locked := &models.ImageGroup{ID: 4, Name: "Locked", Enabled: true, PINProtected: true}
+	groups := []*models.ImageGroup{linux, servers, hidden, locked}
+	images := []models.Image{
+		{ID: 1, Name: "Ubuntu Desktop", Filename: "ubuntu.iso", Size: 5 << 30, Enabled: true, Extracted: true, BootMethod: "kernel", Distro: "ubuntu", GroupID: id(1)},
+		{ID: 2, Name: "Debian Server", Filename: "debian.iso", Size: 700 << 20, Enabled: true, Extracted: true, BootMethod: "kernel", Distro: "debian", GroupID: id(2),
+			AutoInstallEnabled: true, AutoInstallScript: "d-i", AutoInstallScriptType: "preseed", RescueEnabled: true},
+		{ID: 3, Name: "Old Tool", Filename: "tool.iso", Size: 30 << 20, Enabled: true, BootMethod: "sanboot", GroupID: id(3)},
+		{ID: 4, Name: "Wipe Disk", Filename: "wipe.iso", Size: 200 << 20, Enabled: true, Extracted: true, BootMethod: "kernel", Distro: "debian", GroupID: id(4)},
+		{ID: 5, Name: "Windows 11", Filename: "win11.iso", Size: 6 << 30, Enabled: true, Extracted: true, BootMethod: "kernel", Distro: "windows"},
+		{ID: 6, Name: "Disabled", Filename: "off.iso", Enabled: false, BootMethod: "sanboot"},
+	}
+
+	tests := []struct {
+		name   string
+		groups bool
+		adjust func(mb *MenuBuilder)
+	}{
+		{"flat", false, nil},
+		{"groups", true, nil},
+		{"groups-theme", true, func(mb *MenuBuilder) {
+			mb.theme = &models.MenuTheme{Title: "Lab PXE", MenuTimeout: 0, DefaultMenuItem: "shell"}
+		}},
+		{"groups-colours-motd", true, func(mb *MenuBuilder) {
+			mb.theme = &models.MenuTheme{
+				Title: "Lab PXE", MenuTimeout: 10, TextColour: "white", BackgroundColour: "blue", SelectedBackgroundColour: "cyan",
+				MOTD: "Maintenance Friday 18:00\r\nAsk #infra for access\n",
+			}
+		}},
+		{"groups-next-boot", true, func(mb *MenuBuilder) {
+			mb.nextBootImageID = 2
+			mb.theme = &models.MenuTheme{Title: "Lab PXE", MenuTimeout: 0}
+		}},
+		{"flat-default-image", false, func(mb *MenuBuilder) {
+			mb.defaultImage = &mb.images[4]
+			mb.forceImage, mb.forceReason = mb.defaultImage, "Default image"
+		}},
+		{"groups-pin", true, func(mb *MenuBuilder) {
+			mb.menuPIN = true
+			mb.pinGroups = protectedGroups(mb.groups)
+		}},
+		{"flat-failover-token", false, func(mb *MenuBuilder) {
+			mb.bootToken = "s3cret"
+			mb.failoverURLs = []string{"http://192.168.1.11:8080"}
+			mb.ntpServer = "192.168.1.10"
+		}},
+		{"flat-tools", false, func(mb *MenuBuilder) {
+			mb.enabledTools = []tools.EnabledTool{
+				{Name: "netbootxyz", DisplayName: "netboot.xyz", KernelURL: "http://192.168.1.10:8080/tools/netbootxyz/netboot.xyz.efi", KernelURLBIOS: "http://192.168.1.10:8080/tools/netbootxyz/netboot.xyz.kpxe", BootMethod: "chain"},
+				{Name: "memtest", DisplayName: "Memtest86+", KernelURL: "http://192.168.1.10:8080/tools/memtest/memtest.img", BootMethod: "memdisk"},
+				{Name: "gparted", DisplayName: "GParted", KernelURL: "http://192.168.1.10:8080/tools/gparted/vmlinuz", InitrdURL: "http://192.168.1.10:8080/tools/gparted/initrd.img", BootParams: "boot=live", BootMethod: "kernel"},
+			}
+		}},
+		{"flat-remote-menus", false, func(mb *MenuBuilder) {
+			mb.remoteMenus = []*models.RemoteMenu{
+				{ID: 1, Name: "netboot.xyz (lab)", URL: "http://netboot.lab/menu.ipxe"},
+				{ID: 2, Name: "Site B", URL: "https://pxe.site-b.example.com/menu.ipxe?mac=${net0/mac}"},
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imgs := append([]models.Image(nil), images...)
+			if !tt.groups {
+				for i := range imgs {
+					imgs[i].GroupID = nil
+				}
+			}
+			mb := testMenuBuilder(pm, imgs...)
+			if tt.groups {
+				mb.groups = groups
+			}
+			if tt.adjust != nil {
+				tt.adjust(mb)
+			}
+			goldenMenu(t, "layout-"+tt.name, mb.Build())
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"bootimus/internal/models"
+)
+
+// Images and groups can be marked PIN-protected, e.g. wipe-and-reinstall
+// images. Their menu entries hold no boot commands: iPXE asks for the PIN
+// and fetches the entry's boot section from /menu/pin, which only hands
+// it out for the right PIN. It keeps casual console users from booting
+// them; it is no defence against anyone who can read the network.
+
+// wrongPINDelay slows down guessing.
+const wrongPINDelay = 2 * time.Second
+
+// protectedGroups returns the groups that are PIN-protected themselves or
+// sit under one that is.
+func protectedGroups(groups []*models.ImageGroup) map[uint]bool {
+	byID := make(map[uint]*models.ImageGroup, len(groups))
+	for _, g := range groups {
+		byID[g.ID] = g
+	}
+	protected := make(map[uint]bool)
+	for _, g := range groups {
+		for p, depth := g, 0; p != nil && depth < len(groups); depth++ {
+			if p.PINProtected {
+				protected[g.ID] = true
+				break
+			}
+			if p.ParentID == nil {
+				break
+			}
+			p = byID[*p.ParentID]
+		}
+	}
+	return protected
+}
+
+// needsPIN reports whether img's entry asks for the PIN. An image set as
+// the client's next boot does not, since an admin chose it.
+func (mb *MenuBuilder) needsPIN(img *models.Image) bool {
+	if !mb.menuPIN || img.ID == mb.nextBootImageID {
+		return false
+	}
+	return img.PINProtected || (img.GroupID != nil && mb.pinGroups[*img.GroupID])
+}
+
+// buildPINPrompt asks for the PIN with iPXE's login prompt, whose password
+// field is masked, and chains to the entry's boot section. The section's
+// closing goto takes the client back to the menu after a wrong PIN.
+func (mb *MenuBuilder) buildPINPrompt(img *models.Image) string {
+	back := "start"
+	if img.GroupID != nil {
+		back = fmt.Sprintf("group%d", *img.GroupID)
+	}
+	token := ""
+	if mb.bootToken != "" {
+		token = "&token=" + url.QueryEscape(mb.bootToken)
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("echo %s is PIN-protected. Enter the PIN as the password; the username is ignored.\n", img.Name))
+	sb.WriteString(fmt.Sprintf("login || goto %s\n", back))
+	sb.WriteString(fmt.Sprintf("chain --autofree %s/menu/pin?mac=%s&image=%d&pin=${password:uristring}%s || goto failed\n", mb.sessionURL(img, mb.baseURL()), mb.macAddress, img.ID, token))
+	return sb.String()
+}
+
+// handleMenuPIN checks the PIN for a protected entry and returns the
+// script that boots it. A wrong PIN returns a script that says so and
+// exits cleanly, back to the menu.
+func (s *Server) handleMenuPIN(w http.ResponseWriter, r *http.Request) {
+	mac := strings.ToLower(strings.ReplaceAll(r.URL.Query().Get("mac"), "-", ":"))
+	id, _ := strconv.ParseUint(r.URL.Query().Get("image"), 10, 64)
+	w.Header().Set("Content-Type", "text/plain")
+
+	pin := r.URL.Query().Get("pin")
+	if s.config.MenuPIN == "" || subtle.ConstantTimeCompare([]byte(pin), []byte(s.config.MenuPIN)) != 1 {
+		s.logAndBroadcast("Menu PIN: wrong PIN from MAC %s (IP: %s) for image %d", mac, r.RemoteAddr, id)
+		time.Sleep(wrongPINDelay)
+		fmt.Fprint(w, "#!ipxe\necho Wrong PIN\nsleep 3\nexit 0\n")
+		return
+	}
+	if s.config.Storage == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	images, err := s.config.Storage.GetImagesForClient(mac)
+	if err != nil {
+		http.Error(w, "Failed to load images", http.StatusInternalServerError)
+		return
+	}
+	session := s.trustedSession(r, mac)
+	images = s.withholdUntrusted(mac, session != "", images)
+	overrides, _ := s.config.Storage.ListBootParamOverrides(mac)
+	mb, err := s.newMenuBuilder(images, mac, hostOnly(r.RemoteAddr), nextBoot{}, overrides, false)
+	if err != nil {
+		http.Error(w, "Failed to build menu", http.StatusInternalServerError)
+		return
+	}
+	mb.attestSession = session
+	for i := range mb.images {
+		img := &mb.images[i]
+		if uint64(img.ID) != id || !img.Enabled {
+			continue
+		}
+		s.logAndBroadcast("Menu PIN: accepted from MAC %s for %s", mac, img.Name)
+		label := fmt.Sprintf("iso%d", img.ID)
+		var sb strings.Builder
+		sb.WriteString("#!ipxe\n")
+		sb.WriteString(fmt.Sprintf("echo Booting %s...\n", img.Name))
+		sb.WriteString(mb.withFailover(label, func(baseURL string) string {
+			return mb.buildImageBootBody(img, mb.sessionURL(img, baseURL))
+		}))
+		// The menu reports the failure once this script exits.
+		sb.WriteString("exit 0\n\n:failed\nexit 1\n")
+		fmt.Fprint(w, sb.String())
+		return
+	}
+	http.Error(w, "Image not found", http.StatusNotFound)
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"bootimus/internal/models"
+)
+
+// TFTP and HTTP bootloader requests carry only an IP address. The MAC comes
+// from the kernel's ARP table when the client is on a local segment, or
+// from the client's next /inventory or /menu.ipxe request otherwise.
+// Clients that loop on the wrong bootloader never get that far, so the ARP
+// lookup is what catches a BIOS/UEFI mismatch.
+
+// nbpClaimWindow is how long a fetch waits for the client's menu request
+// to name its MAC.
+const nbpClaimWindow = 10 * time.Minute
+
+type nbpFetch struct {
+	file string
+	via  string
+	at   time.Time
+}
+
+type nbpTracker struct {
+	mu      sync.Mutex
+	pending map[string]nbpFetch // by IP
+}
+
+func newNBPTracker() *nbpTracker {
+	return &nbpTracker{pending: make(map[string]nbpFetch)}
+}
+
+func hostOnly(remote string) string {
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
+
+// recordBootloader notes that remote fetched set/file over via.
+func (s *Server) recordBootloader(remote, set, file, via string) {
+	if s.config.Storage == nil {
+		return
+	}
+	ip := hostOnly(remote)
+	fetch := nbpFetch{file: set + "/" + file, via: via, at: time.Now()}
+	if mac := arpLookup(ip); mac != "" {
+		s.saveBootloader(mac, fetch)
+		return
+	}
+	s.nbp.mu.Lock()
+	for k, f := range s.nbp.pending {
+		if time.Since(f.at) > nbpClaimWindow {
+			delete(s.nbp.pending, k)
+		}
+	}
+	s.nbp.pending[ip] = fetch
+	s.nbp.mu.Unlock()
+}
+
+// claimBootloader attributes a bootloader fetched from remote's IP to mac.
+func (s *Server) claimBootloader(mac, remote string) {
+	ip := hostOnly(remote)
+	s.nbp.mu.Lock()
+	fetch, ok := s.nbp.pending[ip]
+	delete(s.nbp.pending, ip)
+	s.nbp.mu.Unlock()
+	if ok && time.Since(fetch.at) <= nbpClaimWindow {
+		s.saveBootloader(mac, fetch)
+	}
+}
+
+func (s *Server) saveBootloader(mac string, fetch nbpFetch) {
+	if _, err := s.config.Storage.GetClient(mac); err != nil {
+		return
+	}
+	at := fetch.at
+	c := &models.Client{LastBootloader: fetch.file, LastBootloaderVia: fetch.via, LastBootloaderAt: &at}
+	if err := s.config.Storage.UpdateClientBootloader(mac, c); err != nil {
+		log.Printf("Failed to record bootloader for %s: %v", mac, err)
+	}
+}
+
+// arpLookup returns the MAC the kernel's ARP table has for ip, if any.
+func arpLookup(ip string) string {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Scan() // header
+	for sc.Scan() {
+		// IP address, HW type, Flags, HW address, Mask, Device
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 || fields[0] != ip || fields[2] == "0x0" {
+			continue
+		}
+		if mac := strings.ToLower(fields[3]); mac != "00:00:00:00:00:00" {
+			return mac
+		}
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"bootimus/internal/autoinstall"
+	"bootimus/internal/models"
+)
+
+// handleNoCloud serves a cloud-init NoCloud seed per client, so VMs that
+// never PXE boot can be pointed at bootimus with an SMBIOS serial such as
+// "ds=nocloud;s=http://server:8080/nocloud/52:54:00:12:34:56/".
+//
+// user-data is the client's (or its group's) auto-install file when that is
+// cloud-init content, vendor-data carries the deployment-wide access config
+// and meta-data names the instance after the client.
+func (s *Server) handleNoCloud(w http.ResponseWriter, r *http.Request) {
+	if s.config.Storage == nil {
+		http.Error(w, "NoCloud seeds require database", http.StatusInternalServerError)
+		return
+	}
+	macPart, file, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/nocloud/"), "/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	hw, err := net.ParseMAC(macPart)
+	if err != nil {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	mac := hw.String()
+
+	clientIP := r.RemoteAddr
+	if i := strings.LastIndex(clientIP, ":"); i > 0 {
+		clientIP = clientIP[:i]
+	}
+
+	client, err := s.config.Storage.GetClient(mac)
+	if err != nil {
+		client = nil
+	}
+	hostname := "bootimus-" + strings.ReplaceAll(mac, ":", "")
+	if client != nil && client.Name != "" {
+		hostname = client.Name
+	}
+
+	var body string
+	switch file {
+	case "meta-data":
+		if client == nil {
+			// Register the VM the same way a PXE boot does, so it shows up
+			// as a discovered client that templates can be assigned to.
+			inv := &models.HardwareInventory{MACAddress: mac, IPAddress: r.RemoteAddr, Platform: "nocloud"}
+			if err := s.config.Storage.SaveHardwareInventory(inv); err != nil {
+				log.Printf("NoCloud: Failed to register %s: %v", mac, err)
+			}
+		}
+		body = fmt.Sprintf("instance-id: bootimus-%s\nlocal-hostname: %s\n", strings.ReplaceAll(mac, ":", ""), hostname)
+
+	case "user-data":
+		body = "#cloud-config\n{}\n"
+		if tmpl, src := s.noCloudUserData(client); tmpl != "" {
+			rendered, err := s.renderAutoInstall(tmpl, s.autoInstallVars(nil, client, mac, clientIP), client)
+			if err != nil {
+				log.Printf("NoCloud: Failed to render user-data for %s from %s: %v", mac, src, err)
+				http.Error(w, "User-data template failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			body = rendered
+			log.Printf("NoCloud: Serving user-data for %s from %s", mac, src)
+		}
+
+	case "vendor-data":
+		access, _ := s.config.Storage.GetAccessConfig()
+		body, err = autoinstall.VendorData(access, hostname)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	log.Printf("NoCloud: Served %s to %s (%s)", file, mac, r.RemoteAddr)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(body))
+}
+
+// noCloudUserData picks the client's auto-install file, then its group's,
+// skipping anything that is not cloud-init content (an installer answer
+// file would only confuse cloud-init).
+func (s *Server) noCloudUserData(client *models.Client) (string, string) {
+	if s.autoInstallLib == nil || client == nil {
+		return "", ""
+	}
+	candidates := []struct{ rel, src string }{{client.AutoInstallFile, "client:" + client.MACAddress}}
+	if client.ClientGroupID != nil {
+		if g, err := s.config.Storage.GetClientGroup(*client.ClientGroupID); err == nil {
+			candidates = append(candidates, struct{ rel, src string }{g.AutoInstallFile, "group:" + g.Name})
+		}
+	}
+	for _, c := range candidates {
+		if c.rel == "" {
+			continue
+		}
+		content, err := s.autoInstallLib.ReadPath(c.rel)
+		if err != nil || !autoinstall.IsCloudInitUserData(content) {
+			continue
+		}
+		return content, c.src
+	}
+	return "", ""
+}
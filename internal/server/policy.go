@@ -0,0 +1,67 @@
+package server
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"bootimus/internal/models"
+	"bootimus/internal/policy"
+)
+
+// policyInput gathers what boot policy rules can look at for a client. An
+// empty ip is filled in from the client's last hardware report.
+func (s *Server) policyInput(mac, ip string) policy.Input {
+	in := policy.Input{MAC: mac, IP: ip, Time: time.Now()}
+	if s.config.Storage == nil {
+		return in
+	}
+	if client, err := s.config.Storage.GetClient(mac); err == nil {
+		in.Tags = client.Tags
+		in.Firmware = client.Firmware
+		if client.ClientGroupID != nil {
+			if g, err := s.config.Storage.GetClientGroup(*client.ClientGroupID); err == nil {
+				in.Group = g.Name
+			}
+		}
+	}
+	if inv, err := s.config.Storage.GetHardwareInventoryHistory(mac, 1); err == nil && len(inv) > 0 {
+		if arch := inv[0].BuildArch; !strings.HasPrefix(arch, "${") {
+			in.Arch = arch
+		}
+		if in.IP == "" {
+			in.IP = hostOnly(inv[0].IPAddress)
+		}
+	}
+	return in
+}
+
+// evaluatePolicy runs the boot policy for a menu request. It returns nil
+// when there is no policy.
+func (s *Server) evaluatePolicy(mac, ip string) *policy.Decision {
+	if s.policy == nil {
+		return nil
+	}
+	p, err := s.policy.Policy()
+	if err != nil {
+		log.Printf("Policy: %s: %v (using the last valid policy)", s.policy.Path(), err)
+	}
+	if p == nil {
+		return nil
+	}
+	return p.Evaluate(s.policyInput(mac, ip))
+}
+
+// applyPolicy drops the images d does not permit, keeping one it forces.
+func applyPolicy(images []models.Image, d *policy.Decision) []models.Image {
+	if d == nil {
+		return images
+	}
+	kept := images[:0:0]
+	for _, img := range images {
+		if img.Filename == d.Force || d.Permits(img.Filename, img.Name) {
+			kept = append(kept, img)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"bootimus/internal/rangestats"
+)
+
+// A sanboot session starting within this long of the last read-ahead of
+// the same image does not trigger another.
+const prefetchEvery = 10 * time.Minute
+
+// recordRange notes a sanboot read of filename. A read of the first block
+// starts a session, so the regions earlier clients read are read ahead.
+func (s *Server) recordRange(filename string, size, offset, length int64) {
+	s.ranges.Record(filename, size, offset, length)
+	if offset < rangestats.BlockSize {
+		s.prefetchRanges(filename)
+	}
+}
+
+func (s *Server) prefetchRanges(filename string) {
+	s.prefetchMu.Lock()
+	if time.Since(s.prefetched[filename]) < prefetchEvery {
+		s.prefetchMu.Unlock()
+		return
+	}
+	s.prefetched[filename] = time.Now()
+	s.prefetchMu.Unlock()
+
+	rep, ok := s.ranges.Report(filename)
+	if !ok {
+		return
+	}
+	path, err := s.libraries.Join(filename)
+	if err != nil {
+		return
+	}
+	go func() {
+		n, err := rangestats.Prefetch(path, rep.Regions)
+		if err != nil {
+			log.Printf("Range stats: read-ahead of %s failed: %v", filename, err)
+			return
+		}
+		log.Printf("Range stats: read ahead %d MB of %s (%.0f%% of the image) for a sanboot client", n>>20, filename, rep.Fraction*100)
+	}()
+}
+
+// rangeStart returns where a single-range Range header starts in a file of
+// size bytes. Multi-range requests are not recorded.
+func rangeStart(header string, size int64) (int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, false
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, false
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, false
+		}
+		return max(size-n, 0), true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, false
+	}
+	return start, true
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"bootimus/internal/models"
+)
+
+const remoteImageExt = ".remote"
+
+var remoteFetchClient = &http.Client{Timeout: 30 * time.Minute}
+
+// remoteBootURLs returns the kernel and initrd URLs the menu should use for
+// a virtual image: the upstream URLs, or the caching proxy when enabled.
+func remoteBootURLs(img *models.Image, baseURL, mac string) (kernel, initrd string) {
+	if !img.CacheRemote {
+		return img.KernelURL, img.InitrdURL
+	}
+	base := url.PathEscape(strings.TrimSuffix(img.DiskFilename(), remoteImageExt))
+	kernel = fmt.Sprintf("%s/remote/%s/kernel?mac=%s", baseURL, base, mac)
+	if img.InitrdURL != "" {
+		initrd = fmt.Sprintf("%s/remote/%s/initrd?mac=%s", baseURL, base, mac)
+	}
+	return kernel, initrd
+}
+
+func (s *Server) remoteCacheDir(base string) string {
+	return filepath.Join(s.config.DataDir, "remote-cache", base)
+}
+
+// handleRemoteFile serves /remote/<image>/{kernel,initrd} for virtual images,
+// downloading the upstream file once and answering later requests from disk.
+// The cache file name carries a hash of the URL, so editing the URL simply
+// misses the cache.
+func (s *Server) handleRemoteFile(w http.ResponseWriter, r *http.Request) {
+	if s.config.Storage == nil {
+		http.Error(w, "Virtual images require database", http.StatusInternalServerError)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/remote/"), "/")
+	if len(parts) != 2 || parts[0] == "" || strings.Contains(parts[0], "..") {
+		http.NotFound(w, r)
+		return
+	}
+	base, kind := parts[0], parts[1]
+
+	img, err := s.config.Storage.GetImage(base + remoteImageExt)
+	if err != nil || !img.IsVirtual() || !img.CacheRemote {
+		http.NotFound(w, r)
+		return
+	}
+	var upstream string
+	switch kind {
+	case "kernel":
+		upstream = img.KernelURL
+	case "initrd":
+		upstream = img.InitrdURL
+	}
+	if upstream == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(upstream))
+	cachePath := filepath.Join(s.remoteCacheDir(base), kind+"-"+hex.EncodeToString(sum[:6]))
+	if _, err := os.Stat(cachePath); err != nil {
+		if err := s.fetchRemoteFile(upstream, cachePath); err != nil {
+			s.logAndBroadcast("Remote: failed to fetch %s for %s: %v", upstream, img.Name, err)
+			http.Error(w, "Upstream fetch failed", http.StatusBadGateway)
+			return
+		}
+	}
+
+	mac := strings.ToLower(strings.ReplaceAll(r.URL.Query().Get("mac"), "-", ":"))
+	if r.Header.Get("Range") == "" && kind == "kernel" {
+		s.logAndBroadcast("Remote: serving %s %s to MAC %s (IP: %s)", img.Name, kind, mac, r.RemoteAddr)
+		s.recordBootIfNew(mac, base+"/"+kind, r.RemoteAddr)
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeFile(w, r, cachePath)
+}
+
+func (s *Server) fetchRemoteFile(upstream, dest string) error {
+	s.remoteFetchMu.Lock()
+	defer s.remoteFetchMu.Unlock()
+	if _, err := os.Stat(dest); err == nil {
+		return nil
+	}
+	return s.downloadRemote(upstream, dest)
+}
+
+func (s *Server) downloadRemote(upstream, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	log.Printf("Remote: downloading %s", upstream)
+	// The download fills a cache other clients will read, so it is tied to
+	// the server rather than to the request that started it.
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, upstream, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := remoteFetchClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	tmp := dest + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	log.Printf("Remote: cached %s (%d bytes)", upstream, n)
+	return os.Rename(tmp, dest)
+}
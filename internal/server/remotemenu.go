@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bootimus/internal/models"
+	"bootimus/internal/remotemenu"
+)
+
+const remoteMenuCheckInterval = 5 * time.Minute
+
+// checkRemoteMenus re-checks every enabled remote menu, logging the ones
+// whose reachability changed.
+func (s *Server) checkRemoteMenus() {
+	menus, err := s.config.Storage.ListRemoteMenus()
+	if err != nil {
+		return
+	}
+	for _, m := range menus {
+		if !m.Enabled {
+			continue
+		}
+		was := m.Reachable
+		err := remotemenu.Check(context.Background(), http.DefaultClient, m.URL)
+		remotemenu.Record(m, err)
+		if was == nil || *was != *m.Reachable {
+			if err != nil {
+				log.Printf("Remote menu %q is unreachable: %v", m.Name, err)
+			} else {
+				log.Printf("Remote menu %q is reachable", m.Name)
+			}
+		}
+		if err := s.config.Storage.SaveRemoteMenu(m); err != nil {
+			log.Printf("Remote menu %q: failed to save check: %v", m.Name, err)
+		}
+	}
+}
+
+// remoteMenusFor returns the remote menus mac is offered.
+func (s *Server) remoteMenusFor(mac string) []*models.RemoteMenu {
+	menus, err := s.config.Storage.ListRemoteMenus()
+	if err != nil || len(menus) == 0 {
+		return nil
+	}
+	client, _ := s.config.Storage.GetClient(mac)
+	group := ""
+	if client != nil && client.ClientGroupID != nil {
+		if g, err := s.config.Storage.GetClientGroup(*client.ClientGroupID); err == nil {
+			group = g.Name
+		}
+	}
+	var visible []*models.RemoteMenu
+	for _, m := range menus {
+		if m.InMenus() && m.VisibleTo(client, group) {
+			visible = append(visible, m)
+		}
+	}
+	return visible
+}
+
+// buildRemoteMenuSections chains to each remote menu. When the remote
+// script exits, the client comes back to this menu.
+func (mb *MenuBuilder) buildRemoteMenuSections() string {
+	var sb strings.Builder
+	for _, m := range mb.remoteMenus {
+		sb.WriteString(fmt.Sprintf(":remote%d\n", m.ID))
+		sb.WriteString(fmt.Sprintf("echo Loading %s...\n", m.Name))
+		sb.WriteString(fmt.Sprintf("chain --autofree %s || goto failed\n", m.URL))
+		sb.WriteString("goto start\n\n")
+	}
+	return sb.String()
+}
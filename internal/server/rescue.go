@@ -0,0 +1,287 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bootimus/internal/initrd"
+)
+
+// Rescue boots announce themselves twice: once from iPXE right before the
+// kernel loads, and again from the live environment once its network is up.
+// The second check-in, the seeded SSH keys and sshd come from the rescue
+// script, which the live system gets in one of two ways:
+//
+//   - the overlay iPXE loads next to the image's initrd, whose systemd or
+//     dracut hook installs it as a unit in the live root before switch-root
+//   - SystemRescue's autorun, pointed at /rescue/<mac>/ by ar_source=
+//
+// bootimus.sshkeys= and bootimus.checkin= stay on the command line for
+// images with hooks of their own.
+const defaultRescueParams = "ip=dhcp bootimus.rescue=1 bootimus.sshkeys={{AUTH_URL}}/ssh/authorized_keys bootimus.checkin={{BASE_URL}}/rescue/checkin?mac={{MAC}} ar_source={{AUTH_URL}}/rescue/{{MAC}}/ ar_nowait ar_nodel"
+
+// Check-ins are unauthenticated, so the sessions kept are capped, and ones
+// not seen for a day are dropped.
+const (
+	maxRescueSessions = 256
+	rescueSessionTTL  = 24 * time.Hour
+)
+
+const rescueUnit = `[Unit]
+Description=Let bootimus rescue users in and check in
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+ExecStart=/usr/local/sbin/bootimus-rescue
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// rescueInitrdUnit runs the setup script in a systemd initrd, once the live
+// root is mounted at /sysroot. initrd.target pulls it in via a drop-in, as
+// a .wants symlink can't be shipped in the overlay.
+const rescueInitrdUnit = `[Unit]
+Description=Install the bootimus rescue script into the live system
+DefaultDependencies=no
+After=initrd-fs.target
+Before=initrd.target initrd-cleanup.service
+ConditionPathExists=/etc/initrd-release
+
+[Service]
+Type=oneshot
+ExecStart=/bin/sh /bootimus/rescue-setup.sh /sysroot
+`
+
+const rescueSetupScript = `#!/bin/sh
+# Installs the bootimus rescue script into the live system at $1 from the
+# initrd. Both the systemd unit and the dracut hook may call it.
+ROOT="${1:-/sysroot}"
+[ -d "$ROOT/etc/systemd/system" ] || exit 0
+[ -e "$ROOT/usr/local/sbin/bootimus-rescue" ] && exit 0
+mkdir -p "$ROOT/usr/local/sbin" "$ROOT/etc/systemd/system/multi-user.target.wants"
+cp /bootimus/rescue.sh "$ROOT/usr/local/sbin/bootimus-rescue"
+chmod 755 "$ROOT/usr/local/sbin/bootimus-rescue"
+cp /bootimus/bootimus-rescue.service "$ROOT/etc/systemd/system/bootimus-rescue.service"
+ln -sf /etc/systemd/system/bootimus-rescue.service "$ROOT/etc/systemd/system/multi-user.target.wants/bootimus-rescue.service"
+`
+
+// rescueDracutHook is sourced by dracut before it switches root.
+const rescueDracutHook = `sh /bootimus/rescue-setup.sh "$NEWROOT"
+`
+
+type RescueSession struct {
+	MAC       string    `json:"mac_address"`
+	IP        string    `json:"ip"`
+	Image     string    `json:"image"`
+	Stage     string    `json:"stage"`
+	StartedAt time.Time `json:"started_at"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type RescueSessions struct {
+	mu       sync.RWMutex
+	sessions map[string]*RescueSession
+}
+
+func NewRescueSessions() *RescueSessions {
+	return &RescueSessions{sessions: make(map[string]*RescueSession)}
+}
+
+func (rs *RescueSessions) CheckIn(mac, ip, image, stage string) *RescueSession {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	now := time.Now()
+	rs.expire(now)
+	sess, ok := rs.sessions[mac]
+	if !ok && len(rs.sessions) >= maxRescueSessions {
+		rs.evictOldest()
+	}
+	if !ok || (image != "" && sess.Image != image) {
+		sess = &RescueSession{MAC: mac, StartedAt: now}
+		rs.sessions[mac] = sess
+	}
+	if ip != "" {
+		sess.IP = ip
+	}
+	if image != "" {
+		sess.Image = image
+	}
+	if stage != "" {
+		sess.Stage = stage
+	}
+	sess.LastSeen = now
+	copy := *sess
+	return &copy
+}
+
+// expire drops sessions not seen within rescueSessionTTL. The caller holds
+// the lock.
+func (rs *RescueSessions) expire(now time.Time) {
+	for mac, sess := range rs.sessions {
+		if now.Sub(sess.LastSeen) > rescueSessionTTL {
+			delete(rs.sessions, mac)
+		}
+	}
+}
+
+// evictOldest makes room for a new session. The caller holds the lock.
+func (rs *RescueSessions) evictOldest() {
+	var oldest *RescueSession
+	for _, sess := range rs.sessions {
+		if oldest == nil || sess.LastSeen.Before(oldest.LastSeen) {
+			oldest = sess
+		}
+	}
+	if oldest != nil {
+		delete(rs.sessions, oldest.MAC)
+	}
+}
+
+func (rs *RescueSessions) Remove(mac string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	delete(rs.sessions, mac)
+}
+
+func (rs *RescueSessions) GetAll() []RescueSession {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	now := time.Now()
+	out := make([]RescueSession, 0, len(rs.sessions))
+	for _, s := range rs.sessions {
+		if now.Sub(s.LastSeen) <= rescueSessionTTL {
+			out = append(out, *s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+func (s *Server) handleRescueCheckin(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	mac := strings.ToLower(strings.ReplaceAll(q.Get("mac"), "-", ":"))
+	if _, err := net.ParseMAC(mac); err != nil {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+
+	ip := q.Get("ip")
+	if net.ParseIP(ip) == nil {
+		ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	stage := q.Get("stage")
+	if stage == "" {
+		stage = "live"
+	}
+
+	sess := s.rescueSessions.CheckIn(mac, ip, q.Get("image"), stage)
+	s.logAndBroadcast("Rescue: %s checked in from %s (image: %s, stage: %s)", mac, sess.IP, sess.Image, sess.Stage)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// rescueScript lets the seeded SSH keys in as root, starts sshd and checks
+// in from the live system.
+func (s *Server) rescueScript(mac string) string {
+	checkin := fmt.Sprintf("http://%s:%d/rescue/checkin?mac=%s&stage=live", s.config.ServerAddr, s.config.HTTPPort, url.QueryEscape(mac))
+
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\n# bootimus rescue: lets the seeded SSH keys in, starts sshd and tells\n# bootimus the live system is up.\n")
+	sb.WriteString("ROOT=\n")
+	sb.WriteString(s.seedKeysScript())
+	sb.WriteString(`if command -v systemctl >/dev/null 2>&1; then
+	systemctl start sshd.service 2>/dev/null || systemctl start ssh.service 2>/dev/null
+elif command -v rc-service >/dev/null 2>&1; then
+	rc-service sshd start
+fi
+`)
+	sb.WriteString("CHECKIN='" + checkin + "'\n")
+	sb.WriteString(`for i in $(seq 1 30); do
+	ip=$(ip -o -4 addr show scope global 2>/dev/null | awk '{split($4, a, "/"); print a[1]; exit}')
+	if command -v curl >/dev/null 2>&1; then
+		curl -fsS --max-time 10 "$CHECKIN&ip=$ip" >/dev/null && break
+	else
+		wget -q -T 10 -O /dev/null "$CHECKIN&ip=$ip" && break
+	fi
+	sleep 10
+done
+`)
+	return sb.String()
+}
+
+// handleRescueOverlay serves the rescue script and the initrd hooks that
+// install it, as a cpio archive for iPXE to load next to the initrd.
+func (s *Server) handleRescueOverlay(w http.ResponseWriter, r *http.Request) {
+	mac, ok := firstBootMAC(r)
+	if !ok {
+		http.Error(w, "Invalid MAC address", http.StatusBadRequest)
+		return
+	}
+	var buf bytes.Buffer
+	err := initrd.WriteArchive(&buf, []initrd.File{
+		{Name: "bootimus/rescue.sh", Mode: 0755, Data: []byte(s.rescueScript(mac))},
+		{Name: "bootimus/rescue-setup.sh", Mode: 0755, Data: []byte(rescueSetupScript)},
+		{Name: "bootimus/bootimus-rescue.service", Data: []byte(rescueUnit)},
+		{Name: "etc/systemd/system/bootimus-rescue-setup.service", Data: []byte(rescueInitrdUnit)},
+		{Name: "etc/systemd/system/initrd.target.d/bootimus-rescue.conf", Data: []byte("[Unit]\nWants=bootimus-rescue-setup.service\n")},
+		{Name: "lib/dracut/hooks/pre-pivot/90-bootimus-rescue.sh", Data: []byte(rescueDracutHook)},
+		{Name: "var/lib/dracut/hooks/pre-pivot/90-bootimus-rescue.sh", Data: []byte(rescueDracutHook)},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(buf.Bytes())
+}
+
+// handleRescueAutorun serves the rescue script as /rescue/<mac>/autorun for
+// SystemRescue's ar_source=.
+func (s *Server) handleRescueAutorun(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/rescue/")
+	macPart, name, _ := strings.Cut(rest, "/")
+	mac := strings.ToLower(strings.ReplaceAll(macPart, "-", ":"))
+	if _, err := net.ParseMAC(mac); err != nil || name != "autorun" {
+		http.NotFound(w, r)
+		return
+	}
+	log.Printf("Rescue: autorun script served to %s (%s)", mac, r.RemoteAddr)
+	w.Header().Set("Content-Type", "text/x-shellscript; charset=utf-8")
+	fmt.Fprint(w, s.rescueScript(mac))
+}
+
+func (s *Server) handleRescueSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data":    s.rescueSessions.GetAll(),
+		})
+	case http.MethodDelete:
+		mac := strings.ToLower(r.URL.Query().Get("mac"))
+		if mac == "" {
+			http.Error(w, "mac required", http.StatusBadRequest)
+			return
+		}
+		s.rescueSessions.Remove(mac)
+		log.Printf("Rescue: session for %s dismissed", mac)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
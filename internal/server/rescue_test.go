@@ -0,0 +1,49 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRescueSessionsExpire(t *testing.T) {
+	rs := NewRescueSessions()
+	rs.CheckIn("52:54:00:00:00:01", "", "", "booting")
+	rs.CheckIn("52:54:00:00:00:02", "", "", "booting")
+	rs.sessions["52:54:00:00:00:01"].LastSeen = time.Now().Add(-rescueSessionTTL - time.Minute)
+
+	if got := rs.GetAll(); len(got) != 1 || got[0].MAC != "52:54:00:00:00:02" {
+		t.Fatalf("GetAll returned %+v, want only the recent session", got)
+	}
+	rs.CheckIn("52:54:00:00:00:03", "", "", "booting")
+	if _, ok := rs.sessions["52:54:00:00:00:01"]; ok {
+		t.Error("expired session was not dropped on check-in")
+	}
+}
+
+func TestRescueSessionsCap(t *testing.T) {
+	rs := NewRescueSessions()
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < maxRescueSessions; i++ {
+		mac := fmt.Sprintf("52:54:00:00:%02x:%02x", i>>8, i&0xff)
+		rs.CheckIn(mac, "", "", "booting")
+		rs.sessions[mac].LastSeen = start.Add(time.Duration(i) * time.Second)
+	}
+
+	rs.CheckIn("52:54:00:ff:ff:ff", "", "", "booting")
+	if n := len(rs.sessions); n != maxRescueSessions {
+		t.Fatalf("got %d sessions, want %d", n, maxRescueSessions)
+	}
+	if _, ok := rs.sessions["52:54:00:00:00:00"]; ok {
+		t.Error("oldest session was not evicted")
+	}
+	if _, ok := rs.sessions["52:54:00:ff:ff:ff"]; !ok {
+		t.Error("new session was not added")
+	}
+
+	// A known client checking in again must not evict anyone.
+	rs.CheckIn("52:54:00:00:00:01", "", "", "live")
+	if n := len(rs.sessions); n != maxRescueSessions {
+		t.Errorf("got %d sessions after a repeat check-in, want %d", n, maxRescueSessions)
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,32 +11,56 @@ import (
 	"log"
 	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
-	"text/template"
 	"time"
+	"unsafe"
 
 	"bootimus/bootloaders"
+	"bootimus/internal/accesslog"
 	"bootimus/internal/admin"
+	"bootimus/internal/alerts"
+	"bootimus/internal/attest"
 	"bootimus/internal/auth"
 	"bootimus/internal/autoinstall"
+	"bootimus/internal/bootmethod"
+	"bootimus/internal/branding"
+	"bootimus/internal/ctxio"
+	"bootimus/internal/dns"
+	"bootimus/internal/hooks"
+	"bootimus/internal/installproxy"
+	"bootimus/internal/iscsi"
+	"bootimus/internal/library"
+	"bootimus/internal/logsink"
+	"bootimus/internal/matchbox"
+	"bootimus/internal/mdns"
 	"bootimus/internal/metrics"
 	"bootimus/internal/models"
 	"bootimus/internal/nbd"
 	"bootimus/internal/nfs"
+	"bootimus/internal/ntp"
+	"bootimus/internal/policy"
 	"bootimus/internal/profiles"
 	"bootimus/internal/proxydhcp"
+	"bootimus/internal/rangestats"
+	"bootimus/internal/ratelimit"
+	"bootimus/internal/redact"
 	"bootimus/internal/redfish"
 	"bootimus/internal/scheduler"
+	"bootimus/internal/securepath"
 	"bootimus/internal/smb"
+	"bootimus/internal/stats"
 	"bootimus/internal/storage"
+	"bootimus/internal/switchport"
+	"bootimus/internal/telemetry"
 	"bootimus/internal/tools"
 	"bootimus/internal/webhook"
 	"bootimus/internal/wol"
@@ -71,11 +96,26 @@ func panicRecoveryMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// ociTrackInterval is how often tracked registry tags are checked for new
+// digests.
+const ociTrackInterval = time.Hour
+
 type Config struct {
 	TFTPPort         int
 	TFTPSinglePort   bool
 	TFTPBlockSize    int
+	TFTPTimeout      time.Duration // per block, before retransmitting
+	TFTPRetries      int
+	TFTPRetryBackoff time.Duration // 0 keeps the library's random backoff
+	TFTPLargeFile    int64         // files this size or larger use TFTPLargeTimeout
+	TFTPLargeTimeout time.Duration
+	BootToken        string   // required by the menu and auto-install endpoints when set
+	MenuPIN          string   // asked for by PIN-protected menu entries; protection is off when empty
+	FailoverURLs     []string // base URLs of other servers menus retry boot fetches from
 	HTTPPort         int
+	HTTP2            bool          // accept cleartext HTTP/2 (prior knowledge) on HTTPPort
+	HTTP2MaxStreams  int           // concurrent requests per HTTP/2 connection; 0 is Go's default
+	HTTPIdleTimeout  time.Duration // how long idle keep-alive connections stay open
 	AdminPort        int
 	BootDir          string
 	DataDir          string
@@ -85,6 +125,8 @@ type Config struct {
 	Auth             *auth.Manager
 	NBDEnabled       bool
 	NBDPort          int
+	ISCSIEnabled     bool
+	ISCSIPort        int
 	NFSEnabled       bool
 	NFSPort          int
 	WOLBroadcastAddr string
@@ -95,8 +137,117 @@ type Config struct {
 	ProxyDHCPBootfileUEFI string
 	ProxyDHCPBootfileARM  string
 
+	// Authoritative DHCP on the proxyDHCP listener; see dhcp.go.
+	DHCPEnabled   bool
+	DHCPRange     string // start-end
+	DHCPNetmask   string
+	DHCPRouter    string
+	DHCPDNS       []string
+	DHCPLeaseTime time.Duration
+
 	WindowsSMBEnabled bool
 	WindowsSMBPort    int
+
+	// Ed25519 public keys (PEM/base64, inline or as file paths) trusted to
+	// sign bootloader update bundles. Updates are refused when empty.
+	BootloaderSigningKeys []string
+
+	// When set, private images are withheld from clients that are not
+	// trusted through TPM attestation.
+	RequireAttestation bool
+	// How long a passing attestation counts, and its session with it;
+	// 0 means an hour.
+	AttestationTTL time.Duration
+
+	// Matchbox-style profiles and groups; empty means <DataDir>/matchbox.
+	MatchboxDir string
+
+	// Commands run at hook points (see package hooks), keyed by point name.
+	Hooks       map[string]string
+	HookTimeout time.Duration
+
+	// Switches and VLANs for moving clients' ports around installs.
+	Switchport switchport.Config
+
+	// Advertise the boot menu and admin UI over mDNS/DNS-SD.
+	MDNSEnabled bool
+
+	// Embedded DNS responder for networks without DNS.
+	DNSEnabled  bool
+	DNSPort     int
+	DNSZone     string
+	DNSHosts    []string
+	DNSWildcard string
+
+	// SNTP responder serving this host's clock.
+	NTPEnabled bool
+	NTPPort    int
+
+	// Thresholds for boot storm and failure alerts.
+	Alerts alerts.Config
+
+	// External systems boot log entries are forwarded to.
+	LogSinks []logsink.Config
+
+	// Access log of the boot HTTP port: "log", "off" or a file of JSON
+	// lines. One successful range request in AccessLogRangeSample is
+	// recorded; AccessLogToSinks also sends entries to the log sinks.
+	AccessLog            string
+	AccessLogRangeSample int
+	AccessLogToSinks     bool
+
+	// Why boot clients probably can't reach ServerAddr, e.g. a container
+	// bridge address; shown in the server info and menu previews.
+	NetworkWarning string
+
+	// HTTPS and client certificate authentication for the admin listener.
+	AdminTLS AdminTLSConfig
+
+	// Per-IP request budget, failed-login lockouts and bans on the admin
+	// listener.
+	AdminRateLimit ratelimit.Config
+
+	// Boot policy rules (see package policy); empty means
+	// <DataDir>/policy.rules.
+	PolicyFile string
+
+	// ISO directories searched besides ISODir, e.g. a NAS archive.
+	Libraries []library.Library
+
+	// Web proxy for installers, per client subnet.
+	InstallProxy []installproxy.Setting
+
+	// ISO downloads run at once, and the bandwidth they share and each
+	// get, in bytes per second (0 for no cap).
+	MaxDownloads          int
+	DownloadRateLimit     int64
+	DownloadRateLimitEach int64
+
+	// Custom ISO catalogs listed beside the built-in one, and how often
+	// the catalog is refreshed (0 only refreshes on request).
+	CatalogSources []profiles.CatalogSource
+	CatalogRefresh time.Duration
+
+	// Send each month's usage report to TelemetryURL. Reports are written
+	// to <DataDir>/reports either way.
+	Telemetry    bool
+	TelemetryURL string
+
+	// Repair references to deleted records found by the daily check
+	// instead of only logging them.
+	FixOrphans bool
+
+	// How often each local ISO is rehashed and checked against its stored
+	// checksum; 0 turns the checks off.
+	VerifyInterval time.Duration
+
+	// Move sanboot images that keep failing to kernel boot, extracting
+	// them first, when a distro profile covers them.
+	AutoKernelBoot bool
+
+	// Delete boot.wim backups left by driver rebuilds once they are this
+	// old; 0 keeps them.
+	WimBackupMaxAge time.Duration
 }
 
 type Server struct {
@@ -104,19 +255,48 @@ type Server struct {
 	httpServer            *http.Server
 	adminServer           *http.Server
 	tftpServer            *tftp.Server
+	iscsiServer           *iscsi.Server
 	proxyDHCPServer       *proxydhcp.Server
+	mdnsServer            *mdns.Server
+	dnsServer             *dns.Server
+	ntpServer             *ntp.Server
+	alerts                *alerts.Detector
+	logSinks              *logsink.Set
+	accessLog             *accesslog.Logger
+	branding              *branding.Store
+	libraries             *library.Set
+	stats                 *stats.Recorder
+	ranges                *rangestats.Tracker
+	prefetchMu            sync.Mutex
+	prefetched            map[string]time.Time // last read-ahead of each image
 	webhookNotifier       *webhook.Notifier
 	scheduler             *scheduler.Scheduler
 	bootLogDedup          map[string]time.Time
 	bootLogDedupMu        sync.Mutex
 	wg                    sync.WaitGroup
+	ctx                   context.Context // cancelled by Shutdown; request contexts derive from it
+	cancel                context.CancelFunc
 	activeSessions        *ActiveSessions
+	rescueSessions        *RescueSessions
+	nbp                   *nbpTracker
 	logBroadcaster        *LogBroadcaster
 	activeBootloaderSet   string // name of active set folder, empty = built-in
 	activeBootloaderSetMu sync.RWMutex
 	toolsManager          *tools.Manager
 	smbManager            *smb.Manager
 	autoInstallLib        *autoinstall.Library
+	remoteFetchMu         sync.Mutex
+	externalFetches       sync.Map // cache path -> true while an external ISO downloads
+	attestNonces          *attest.Nonces
+	attestSessions        *attest.Sessions
+	matchbox              *matchbox.Store
+	hooks                 *hooks.Runner
+	switchport            *switchport.Manager
+	rateLimit             *ratelimit.Limiter
+	policy                *policy.File
+	installProxy          *installproxy.Set
+	catalog               *profiles.Catalog
+	usage                 *telemetry.Reporter
 }
 
 type ActiveSession struct {
@@ -147,9 +327,17 @@ var globalLogBuffer struct {
 
 var globalLogBroadcaster *LogBroadcaster
 
-type LogWriter struct{}
+// LogWriter keeps recent log lines for the admin UI and echoes them to
+// stdout, masking secrets first unless redaction is off.
+type LogWriter struct {
+	redact bool
+}
 
-func (lw *LogWriter) Write(p []byte) (n int, err error) {
+func (lw *LogWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if lw.redact {
+		p = []byte(redact.String(string(p)))
+	}
 	msg := string(bytes.TrimRight(p, "\n"))
 
 	globalLogBuffer.mu.Lock()
@@ -163,11 +351,14 @@ func (lw *LogWriter) Write(p []byte) (n int, err error) {
 		globalLogBroadcaster.Broadcast(msg)
 	}
 
-	return os.Stdout.Write(p)
+	if _, err := os.Stdout.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
-func InitGlobalLogger() {
-	log.SetOutput(&LogWriter{})
+func InitGlobalLogger(redactLogs bool) {
+	log.SetOutput(&LogWriter{redact: redactLogs})
 	log.SetFlags(log.Ldate | log.Ltime)
 }
 
@@ -249,6 +440,22 @@ func (s *Server) logAndBroadcast(format string, args ...interface{}) {
 	log.Print(msg)
 }
 
+// requestMAC returns the normalised ?mac= of a boot file request.
+func requestMAC(r *http.Request) string {
+	mac := r.URL.Query().Get("mac")
+	if mac == "" {
+		return "unknown"
+	}
+	return strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
+}
+
+// rejectPath logs a file request refused by securepath against its client.
+func (s *Server) rejectPath(what string) func(*http.Request, string, error) {
+	return func(r *http.Request, rel string, err error) {
+		s.logAndBroadcast("%s: Rejected path from MAC %s (IP: %s): %q: %v", what, requestMAC(r), r.RemoteAddr, rel, err)
+	}
+}
+
 type ISOImage struct {
 	Name      string
 	Filename  string
@@ -257,6 +464,18 @@ type ISOImage struct {
 	GroupPath string // relative directory path from isoDir, empty for root
 }
 
+// countingWriter counts the body bytes written through it.
+type countingWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
 type completionLogger struct {
 	http.ResponseWriter
 	filename       string
@@ -296,6 +515,12 @@ func New(cfg *Config) *Server {
 
 	globalLogBroadcaster = lb
 
+	bootloaders.SetUpdateDir(filepath.Join(cfg.DataDir, "bootloader-updates"))
+	if cfg.MatchboxDir == "" {
+		cfg.MatchboxDir = filepath.Join(cfg.DataDir, "matchbox")
+	}
+	cfg.FailoverURLs = normalizeFailoverURLs(cfg.FailoverURLs)
+
 	tm := tools.NewManager(cfg.Storage, cfg.DataDir)
 	if err := tm.SeedTools(); err != nil {
 		log.Printf("Tools: Failed to seed tools: %v", err)
@@ -306,12 +531,86 @@ func New(cfg *Config) *Server {
 		activeSessions: &ActiveSessions{
 			sessions: make(map[string]*ActiveSession),
 		},
+		rescueSessions:  NewRescueSessions(),
+		nbp:             newNBPTracker(),
 		logBroadcaster:  lb,
 		toolsManager:    tm,
 		bootLogDedup:    make(map[string]time.Time),
 		webhookNotifier: webhook.New(cfg.Storage),
+		attestNonces:    attest.NewNonces(),
+		attestSessions:  attest.NewSessions(),
+		matchbox:        matchbox.New(cfg.MatchboxDir),
+		hooks:           hooks.New(cfg.Hooks, cfg.HookTimeout),
+	}
+	if sp, err := switchport.New(cfg.Switchport); err != nil {
+		log.Printf("Switchport: Disabled: %v", err)
+	} else {
+		s.switchport = sp
+	}
+	if libs, err := library.New(cfg.ISODir, cfg.Libraries); err != nil {
+		log.Printf("Libraries: Using %s only: %v", cfg.ISODir, err)
+		s.libraries, _ = library.New(cfg.ISODir, nil)
+	} else {
+		s.libraries = libs
+	}
+	if c, err := profiles.NewCatalog(cfg.CatalogSources); err != nil {
+		log.Printf("ISO catalog: Built-in only: %v", err)
+		s.catalog, _ = profiles.NewCatalog(nil)
+	} else {
+		s.catalog = c
 	}
+	if s.catalog != nil && cfg.ProfileManager != nil {
+		s.catalog.DisableRemoteCheck = cfg.ProfileManager.DisableRemoteCheck
+	}
+	if ip, err := installproxy.New(cfg.InstallProxy); err != nil {
+		log.Printf("Install proxy: Disabled: %v", err)
+	} else {
+		s.installProxy = ip
+	}
+	if bs, err := branding.New(cfg.DataDir); err != nil {
+		log.Printf("Branding: Disabled: %v", err)
+	} else {
+		s.branding = bs
+	}
+	s.alerts = alerts.New(cfg.Alerts, s.raiseAlert)
+	rl := cfg.AdminRateLimit
+	rl.LoginPath = "/api/login"
+	if rl.BansFile == "" && cfg.DataDir != "" {
+		rl.BansFile = filepath.Join(cfg.DataDir, "admin-bans.json")
+	}
+	s.rateLimit = ratelimit.New(rl)
+	if policyFile := cfg.PolicyFile; policyFile != "" || cfg.DataDir != "" {
+		if policyFile == "" {
+			policyFile = filepath.Join(cfg.DataDir, "policy.rules")
+		}
+		s.policy = policy.NewFile(policyFile)
+	}
+	if len(cfg.LogSinks) > 0 {
+		sinks, err := logsink.New(cfg.LogSinks)
+		if err != nil {
+			log.Printf("Log sinks: %v", err)
+		}
+		s.logSinks = sinks
+	}
+	s.accessLog = s.newAccessLog()
 	s.scheduler = scheduler.New(cfg.Storage, s.executeScheduledTask)
+	s.stats = stats.New(cfg.Storage)
+	if cfg.Storage != nil && cfg.DataDir != "" {
+		if u, err := telemetry.New(cfg.Storage, telemetry.Config{
+			Dir:      filepath.Join(cfg.DataDir, "reports"),
+			Enabled:  cfg.Telemetry,
+			URL:      cfg.TelemetryURL,
+			Version:  Version,
+			Features: s.featuresInUse,
+		}); err != nil {
+			log.Printf("Usage reports: Disabled: %v", err)
+		} else {
+			s.usage = u
+		}
+	}
+	s.ranges = rangestats.New(filepath.Join(cfg.DataDir, "range-stats.json"))
+	s.prefetched = make(map[string]time.Time)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
 	s.loadBootloaderConfig()
 	return s
 }
@@ -416,7 +715,10 @@ func (s *Server) resolveBootloaderFile(filename string) string {
 	if setName == "" || s.config.BootDir == "" {
 		return ""
 	}
-	fullPath := filepath.Join(s.config.BootDir, setName, filename)
+	fullPath, err := securepath.Join(filepath.Join(s.config.BootDir, setName), filename)
+	if err != nil {
+		return ""
+	}
 	if _, err := os.Stat(fullPath); err == nil {
 		return fullPath
 	}
@@ -477,10 +779,30 @@ func (s *Server) Start() error {
 	log.Printf("Boot directory: %s", s.config.BootDir)
 	log.Printf("Data directory: %s", s.config.DataDir)
 	log.Printf("ISO directory: %s", s.config.ISODir)
+	for _, l := range s.libraries.All() {
+		if l.Name != library.DefaultName {
+			log.Printf("ISO library %s: %s (priority %d)", l.Name, l.Path, l.Priority)
+		}
+	}
 	log.Printf("TFTP Port: %d", s.config.TFTPPort)
 	log.Printf("HTTP Port: %d", s.config.HTTPPort)
+	if s.config.HTTP2 {
+		log.Printf("HTTP/2: cleartext (prior knowledge), %d streams per connection", s.config.HTTP2MaxStreams)
+	}
 	log.Printf("Admin Port: %d", s.config.AdminPort)
+	if s.config.AdminTLS.enabled() {
+		log.Printf("Admin TLS: %s", s.config.AdminTLS.CertFile)
+	}
 	log.Printf("Server Address: %s", s.config.ServerAddr)
+	if s.config.BootToken != "" {
+		log.Printf("Boot token required for the boot menu and auto-install endpoints")
+	}
+	for _, u := range s.config.FailoverURLs {
+		log.Printf("Failover URL: %s", u)
+	}
+	if n := s.logSinks.Len(); n > 0 {
+		log.Printf("Log sinks: Forwarding boot log to %d sink(s)", n)
+	}
 
 	if mgr, err := autoinstall.New(s.config.DataDir); err != nil {
 		log.Printf("Warning: could not initialise autoinstall manager: %v", err)
@@ -557,24 +879,57 @@ func (s *Server) Start() error {
 		}
 	}()
 
+	go func() {
+		time.Sleep(time.Minute)
+		ticker := time.NewTicker(orphanCheckInterval)
+		defer ticker.Stop()
+		for {
+			s.checkOrphans()
+			<-ticker.C
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(remoteMenuCheckInterval)
+		defer ticker.Stop()
+		for {
+			s.checkRemoteMenus()
+			<-ticker.C
+		}
+	}()
+
 	if s.config.NBDEnabled {
 		log.Printf("NBD Port: %d", s.config.NBDPort)
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
-			nbdServer := nbd.NewServer(s.config.ISODir, s.config.NBDPort)
+			nbdServer := nbd.NewServer(s.libraries.Roots(), s.config.NBDPort)
+			nbdServer.OnRead = s.recordRange
 			if err := nbdServer.Start(); err != nil {
 				log.Printf("NBD server error: %v", err)
 			}
 		}()
 	}
 
+	if s.config.ISCSIEnabled {
+		log.Printf("iSCSI Port: %d", s.config.ISCSIPort)
+		s.iscsiServer = iscsi.NewServer(s.config.ISCSIPort, s.iscsiTargets)
+		s.iscsiServer.OnRead = s.recordRange
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := s.iscsiServer.Start(); err != nil {
+				log.Printf("iSCSI target error: %v", err)
+			}
+		}()
+	}
+
 	if s.config.NFSEnabled {
 		log.Printf("NFS Port: %d", s.config.NFSPort)
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
-			nfsServer := nfs.NewServer(s.config.ISODir, s.config.NFSPort)
+			nfsServer := nfs.NewServer(s.libraries.Roots(), s.config.NFSPort)
 			if err := nfsServer.Start(); err != nil {
 				log.Printf("NFS server error: %v", err)
 			}
@@ -584,15 +939,64 @@ func (s *Server) Start() error {
 	if s.scheduler != nil {
 		s.scheduler.Start()
 	}
+	s.stats.Start()
+	s.ranges.Start()
 
-	if s.config.ProxyDHCPEnabled {
-		pd, err := proxydhcp.NewServer(proxydhcp.Config{
-			ServerIP:      net.ParseIP(s.config.ServerAddr),
-			BootfileBIOS:  s.config.ProxyDHCPBootfileBIOS,
-			BootfileUEFI:  s.config.ProxyDHCPBootfileUEFI,
-			BootfileARM64: s.config.ProxyDHCPBootfileARM,
-			Bootfiles:     s.proxyDHCPBootfiles,
+	if s.config.DNSEnabled {
+		ds, err := dns.NewServer(dns.Config{
+			Port:     s.config.DNSPort,
+			ServerIP: net.ParseIP(s.config.ServerAddr),
+			Zone:     s.config.DNSZone,
+			Hosts:    s.config.DNSHosts,
+			Wildcard: s.config.DNSWildcard,
 		})
+		if err != nil {
+			log.Printf("DNS: failed to construct server: %v", err)
+		} else if err := ds.Start(); err != nil {
+			log.Printf("DNS: failed to start: %v", err)
+		} else {
+			s.dnsServer = ds
+		}
+	}
+
+	if s.config.NTPEnabled {
+		ns := ntp.NewServer(s.config.NTPPort)
+		if err := ns.Start(); err != nil {
+			log.Printf("NTP: failed to start: %v", err)
+		} else {
+			s.ntpServer = ns
+		}
+	}
+
+	if s.config.ProxyDHCPEnabled || s.config.DHCPEnabled {
+		var dnsServers []net.IP
+		if s.dnsServer != nil {
+			dnsServers = []net.IP{net.ParseIP(s.config.ServerAddr)}
+		}
+		var ntpServers []net.IP
+		if s.ntpServer != nil {
+			ntpServers = []net.IP{net.ParseIP(s.config.ServerAddr)}
+		}
+		var pool *proxydhcp.Pool
+		var err error
+		if s.config.DHCPEnabled {
+			pool, err = s.dhcpPool()
+		}
+		var pd *proxydhcp.Server
+		if err == nil {
+			pd, err = proxydhcp.NewServer(proxydhcp.Config{
+				ServerIP:      net.ParseIP(s.config.ServerAddr),
+				BootfileBIOS:  s.config.ProxyDHCPBootfileBIOS,
+				BootfileUEFI:  s.config.ProxyDHCPBootfileUEFI,
+				BootfileARM64: s.config.ProxyDHCPBootfileARM,
+				Bootfiles:     s.proxyDHCPBootfiles,
+				DNSServers:    dnsServers,
+				NTPServers:    ntpServers,
+				DomainName:    s.config.DNSZone,
+				Pool:          pool,
+				WPAD:          s.wpadURL,
+			})
+		}
 		if err != nil {
 			log.Printf("proxyDHCP: failed to construct server: %v", err)
 		} else if err := pd.Start(); err != nil {
@@ -602,9 +1006,48 @@ func (s *Server) Start() error {
 		}
 	}
 
+	if s.config.MDNSEnabled {
+		s.startMDNS()
+	}
+
 	return nil
 }
 
+func (s *Server) startMDNS() {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "bootimus"
+	}
+	host = strings.SplitN(host, ".", 2)[0]
+	menuURL := fmt.Sprintf("http://%s:%d/menu.ipxe", s.config.ServerAddr, s.config.HTTPPort)
+	adminScheme := "http"
+	if s.config.AdminTLS.enabled() {
+		adminScheme = "https"
+	}
+	adminURL := fmt.Sprintf("%s://%s:%d/", adminScheme, s.config.ServerAddr, s.config.AdminPort)
+	md, err := mdns.NewServer(mdns.Config{
+		Host: host,
+		IP:   net.ParseIP(s.config.ServerAddr),
+		Services: []mdns.Service{
+			{
+				Instance: "Bootimus on " + host,
+				Type:     mdns.TypeBootimus,
+				Port:     s.config.HTTPPort,
+				TXT:      []string{"version=" + Version, "menu=" + menuURL, "admin=" + adminURL},
+			},
+			{Instance: "Bootimus Boot Menu (" + host + ")", Type: mdns.TypeHTTP, Port: s.config.HTTPPort, TXT: []string{"path=/menu.ipxe"}},
+			{Instance: "Bootimus Admin (" + host + ")", Type: mdns.TypeHTTP, Port: s.config.AdminPort, TXT: []string{"path=/"}},
+		},
+	})
+	if err != nil {
+		log.Printf("mDNS: failed to construct server: %v", err)
+	} else if err := md.Start(); err != nil {
+		log.Printf("mDNS: failed to start: %v", err)
+	} else {
+		s.mdnsServer = md
+	}
+}
+
 func (s *Server) preloadSMBShares(mgr *smb.Manager) {
 	if mgr == nil || s.config.Storage == nil {
 		return
@@ -616,11 +1059,11 @@ func (s *Server) preloadSMBShares(mgr *smb.Manager) {
 	}
 	added := 0
 	for _, img := range images {
-		if img.Distro != "windows" || !img.SMBInstallEnabled {
+		if img.Distro != "windows" || !img.SMBInstallEnabled || img.CloneOf != "" {
 			continue
 		}
 		isoBase := strings.TrimSuffix(img.Filename, filepath.Ext(img.Filename))
-		sharePath := filepath.Join(s.config.ISODir, isoBase, "iso")
+		sharePath := filepath.Join(s.libraries.Dir(img.Filename), isoBase, "iso")
 		if _, err := os.Stat(sharePath); err != nil {
 			continue
 		}
@@ -639,6 +1082,11 @@ func (s *Server) Wait() {
 func (s *Server) Shutdown() error {
 	log.Println("Initiating graceful shutdown...")
 
+	// Transfers still running when the listeners close would otherwise
+	// carry on copying to clients that are about to be cut off.
+	s.cancel()
+	s.libraries.Close()
+
 	if s.httpServer != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 		defer cancel()
@@ -664,6 +1112,11 @@ func (s *Server) Shutdown() error {
 		log.Println("TFTP server stopped")
 	}
 
+	if s.iscsiServer != nil {
+		s.iscsiServer.Stop()
+		log.Println("iSCSI target stopped")
+	}
+
 	if s.proxyDHCPServer != nil {
 		if err := s.proxyDHCPServer.Shutdown(); err != nil {
 			log.Printf("proxyDHCP server shutdown error: %v", err)
@@ -672,11 +1125,31 @@ func (s *Server) Shutdown() error {
 		}
 	}
 
+	if s.ntpServer != nil {
+		s.ntpServer.Shutdown()
+		log.Println("NTP server stopped")
+	}
+
+	if s.dnsServer != nil {
+		s.dnsServer.Shutdown()
+		log.Println("DNS server stopped")
+	}
+
+	if s.mdnsServer != nil {
+		s.mdnsServer.Shutdown()
+		log.Println("mDNS responder stopped")
+	}
+
 	if s.scheduler != nil {
 		s.scheduler.Stop()
 		log.Println("Scheduler stopped")
 	}
 
+	s.stats.Shutdown()
+	s.ranges.Shutdown()
+	s.logSinks.Close()
+	s.accessLog.Close()
+
 	if s.smbManager != nil {
 		s.smbManager.Stop()
 		log.Println("SMB server stopped")
@@ -688,45 +1161,20 @@ func (s *Server) Shutdown() error {
 }
 
 func (s *Server) scanISOs() ([]ISOImage, error) {
-	var isos []ISOImage
-
-	err := filepath.WalkDir(s.config.ISODir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		if !strings.HasSuffix(strings.ToLower(d.Name()), ".iso") {
-			return nil
-		}
-
-		info, err := d.Info()
-		if err != nil {
-			log.Printf("Warning: Failed to get info for %s: %v", path, err)
-			return nil
-		}
-
-		relPath, _ := filepath.Rel(s.config.ISODir, path)
-		groupPath := filepath.Dir(relPath)
-		if groupPath == "." {
-			groupPath = ""
-		}
-
-		displayName := strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
+	found, _, err := s.libraries.Scan("")
+	if err != nil {
+		return nil, err
+	}
 
+	isos := make([]ISOImage, 0, len(found))
+	for _, iso := range found {
 		isos = append(isos, ISOImage{
-			Name:      displayName,
-			Filename:  relPath,
-			Size:      info.Size(),
-			SizeStr:   formatBytes(info.Size()),
-			GroupPath: groupPath,
+			Name:      iso.Name(),
+			Filename:  iso.Path,
+			Size:      iso.Size,
+			SizeStr:   formatBytes(iso.Size),
+			GroupPath: iso.GroupPath(),
 		})
-
-		return nil
-	})
-	if err != nil {
-		return nil, err
 	}
 
 	sort.Slice(isos, func(i, j int) bool {
@@ -777,15 +1225,63 @@ func tftpRemote(rf io.ReaderFrom) string {
 	return "?"
 }
 
+// sendTFTP sends r, of size bytes, as the reply to a read request.
+func (s *Server) sendTFTP(rf io.ReaderFrom, filename string, r io.Reader, size int64) error {
+	if ot, ok := rf.(tftp.OutgoingTransfer); ok {
+		ot.SetSize(size)
+	}
+	if s.largeTFTPTimeout() && size >= s.config.TFTPLargeFile {
+		if setTFTPTimeout(rf, s.config.TFTPLargeTimeout) {
+			log.Printf("TFTP: Using %v timeout for %s (%d bytes)", s.config.TFTPLargeTimeout, filename, size)
+		}
+	}
+
+	n, err := rf.ReadFrom(ctxio.Reader(s.ctx, r))
+	if err != nil {
+		log.Printf("TFTP: Transfer error for %s: %v", filename, err)
+		return err
+	}
+	log.Printf("TFTP: Successfully sent %s (%d bytes)", filename, n)
+	s.stats.AddBytes(n)
+	return nil
+}
+
+func (s *Server) largeTFTPTimeout() bool {
+	return s.config.TFTPLargeFile > 0 && s.config.TFTPLargeTimeout > s.config.TFTPTimeout
+}
+
+// setTFTPTimeout changes the ack timeout of a transfer that has not started
+// sending. pin/tftp copies the server timeout into each transfer when the
+// request arrives, before the handler knows which file it is serving, and
+// has no setter, so this sets the unexported field. It reports false if the
+// library's transfer type no longer has one.
+func setTFTPTimeout(rf io.ReaderFrom, d time.Duration) bool {
+	v := reflect.ValueOf(rf)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	f := v.Elem().FieldByName("timeout")
+	if !f.IsValid() || f.Type() != reflect.TypeOf(d) {
+		return false
+	}
+	reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem().Set(reflect.ValueOf(d))
+	return true
+}
+
 func (s *Server) startTFTPServer() error {
 	log.Printf("Starting TFTP server on port %d...", s.config.TFTPPort)
 
 	server := tftp.NewServer(
 		func(filename string, rf io.ReaderFrom) error {
-			cleanPath := filepath.Clean(filename)
+			cleanPath := filepath.Clean(strings.ReplaceAll(filename, `\`, "/"))
 			if filepath.IsAbs(cleanPath) {
 				cleanPath = filepath.Base(cleanPath)
 			}
+			cleanPath, err := securepath.Clean(cleanPath)
+			if err != nil {
+				log.Printf("TFTP: Rejected path from %s: %q: %v", tftpRemote(rf), filename, err)
+				return fmt.Errorf("access denied: %s", filename)
+			}
 
 			remote := tftpRemote(rf)
 			start := time.Now()
@@ -805,7 +1301,7 @@ func (s *Server) startTFTPServer() error {
 
 # Auto-detect server IP and chain to dynamic menu
 dhcp
-chain http://%s:%d/inventory?mac=${net0/mac}&cpu=${cpuid/0}&memsize=${memsize}&platform=${platform}&buildarch=${buildarch}&product=${product}&manufacturer=${manufacturer}&serial=${serial}&asset=${asset}&uuid=${uuid}&nic_chip=${net0/chip} || chain http://%s:%d/menu.ipxe?mac=${net0/mac} || goto failed
+chain http://%s:%d/inventory?mac=${net0/mac}&cpu=${cpuid/0}&memsize=${memsize}&platform=${platform}&secureboot=${efi/SecureBoot:uint8}&buildarch=${buildarch}&product=${product}&manufacturer=${manufacturer}&serial=${serial}&asset=${asset}&uuid=${uuid}&nic_chip=${net0/chip}%s || chain http://%s:%d/menu.ipxe?mac=${net0/mac}%s%s || goto failed
 
 :failed
 echo Failed to load boot menu
@@ -814,22 +1310,10 @@ echo MAC: ${net0/mac}
 echo Press any key to retry...
 prompt
 goto dhcp
-`, serverAddr, s.config.HTTPPort, serverAddr, s.config.HTTPPort)
+`, serverAddr, s.config.HTTPPort, s.bootTokenParam(false), serverAddr, s.config.HTTPPort, s.bootTokenParam(false), s.menuFailoverChain("${net0/mac}", false))
 				data := []byte(script)
 				log.Printf("TFTP: Serving dynamic autoexec.ipxe (HTTP port: %d)", s.config.HTTPPort)
-
-				if rfs, ok := rf.(interface{ SetSize(int64) error }); ok {
-					rfs.SetSize(int64(len(data)))
-				}
-
-				n, err := rf.ReadFrom(bytes.NewReader(data))
-				if err != nil {
-					log.Printf("TFTP: Transfer error for %s: %v", filename, err)
-					return err
-				}
-
-				log.Printf("TFTP: Successfully sent %s (%d bytes)", filename, n)
-				return nil
+				return s.sendTFTP(rf, filename, bytes.NewReader(data), int64(len(data)))
 			}
 
 			if customPath := s.resolveBootloaderFile(cleanPath); customPath != "" {
@@ -837,43 +1321,21 @@ goto dhcp
 				if err == nil {
 					defer file.Close()
 					log.Printf("TFTP: Serving from set '%s': %s", s.GetActiveBootloaderSet(), cleanPath)
+					s.recordBootloader(tftpRemote(rf), s.GetActiveBootloaderSet(), cleanPath, "tftp")
 
 					fileInfo, err := file.Stat()
 					if err != nil {
 						return err
 					}
-
-					if rfs, ok := rf.(interface{ SetSize(int64) error }); ok {
-						rfs.SetSize(fileInfo.Size())
-					}
-
-					n, err := rf.ReadFrom(file)
-					if err != nil {
-						log.Printf("TFTP: Transfer error for %s: %v", filename, err)
-						return err
-					}
-
-					log.Printf("TFTP: Successfully sent %s (%d bytes)", filename, n)
-					return nil
+					return s.sendTFTP(rf, filename, file, fileInfo.Size())
 				}
 			}
 
 			data, resolvedSet, err := bootloaders.Resolve(s.GetActiveBootloaderSet(), cleanPath)
 			if err == nil {
 				log.Printf("TFTP: Serving embedded bootloader from set '%s': %s", resolvedSet, cleanPath)
-
-				if rfs, ok := rf.(interface{ SetSize(int64) error }); ok {
-					rfs.SetSize(int64(len(data)))
-				}
-
-				n, err := rf.ReadFrom(bytes.NewReader(data))
-				if err != nil {
-					log.Printf("TFTP: Transfer error for %s: %v", filename, err)
-					return err
-				}
-
-				log.Printf("TFTP: Successfully sent %s (%d bytes)", filename, n)
-				return nil
+				s.recordBootloader(tftpRemote(rf), resolvedSet, cleanPath, "tftp")
+				return s.sendTFTP(rf, filename, bytes.NewReader(data), int64(len(data)))
 			}
 
 			return fmt.Errorf("file not found: %s", filename)
@@ -881,7 +1343,17 @@ goto dhcp
 		nil,
 	)
 
-	server.SetTimeout(5 * time.Second)
+	if s.config.TFTPTimeout <= 0 {
+		s.config.TFTPTimeout = 5 * time.Second
+	}
+	server.SetTimeout(s.config.TFTPTimeout)
+	server.SetRetries(s.config.TFTPRetries)
+	if backoff := s.config.TFTPRetryBackoff; backoff > 0 {
+		server.SetBackoff(func(int) time.Duration { return backoff })
+	}
+	if s.largeTFTPTimeout() {
+		log.Printf("TFTP timeout: %v per block, %v for files of %d bytes or more", s.config.TFTPTimeout, s.config.TFTPLargeTimeout, s.config.TFTPLargeFile)
+	}
 	blockSize := s.config.TFTPBlockSize
 	if blockSize <= 0 {
 		blockSize = 1456
@@ -908,8 +1380,6 @@ func (s *Server) startHTTPServer() error {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("HTTP: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
-
 		cleanPath := strings.TrimPrefix(r.URL.Path, "/")
 		if cleanPath == "" {
 			http.Error(w, "Not found", http.StatusNotFound)
@@ -918,6 +1388,7 @@ func (s *Server) startHTTPServer() error {
 
 		if customPath := s.resolveBootloaderFile(cleanPath); customPath != "" {
 			log.Printf("HTTP: Serving from set '%s': %s", s.GetActiveBootloaderSet(), cleanPath)
+			s.recordBootloader(r.RemoteAddr, s.GetActiveBootloaderSet(), cleanPath, "http")
 			ext := filepath.Ext(cleanPath)
 			if ext == ".efi" || ext == ".img" || ext == ".iso" || ext == ".kpxe" || ext == ".usb" {
 				w.Header().Set("Content-Type", "application/octet-stream")
@@ -929,6 +1400,7 @@ func (s *Server) startHTTPServer() error {
 		data, resolvedSet, err := bootloaders.Resolve(s.GetActiveBootloaderSet(), cleanPath)
 		if err == nil {
 			log.Printf("HTTP: Serving embedded bootloader from set '%s': %s", resolvedSet, cleanPath)
+			s.recordBootloader(r.RemoteAddr, resolvedSet, cleanPath, "http")
 			w.Header().Set("Content-Type", "application/octet-stream")
 			w.Write(data)
 			return
@@ -937,41 +1409,51 @@ func (s *Server) startHTTPServer() error {
 		http.Error(w, "Not found", http.StatusNotFound)
 	})
 
-	mux.HandleFunc("/inventory", s.handleInventoryReport)
-	mux.HandleFunc("/menu.ipxe", s.handleIPXEMenu)
+	mux.HandleFunc("/inventory", s.requireBootToken(s.handleInventoryReport))
+	mux.HandleFunc("/menu.ipxe", s.asClient(s.requireBootToken(s.handleIPXEMenu)))
+	mux.HandleFunc("/menu/pin", s.requireBootToken(s.handleMenuPIN))
 
 	toolsDir := filepath.Join(s.config.DataDir, "tools")
 	mux.Handle("/tools/", http.StripPrefix("/tools/", http.FileServer(http.Dir(toolsDir))))
+	mux.HandleFunc("/memdisk", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeFile(w, r, s.toolsManager.MemdiskPath())
+	})
 
 	mux.HandleFunc("/autoexec.ipxe", s.handleAutoexec)
+	if s.branding != nil {
+		mux.Handle("/branding/", s.branding)
+	}
 
-	mux.HandleFunc("/isos/", func(w http.ResponseWriter, r *http.Request) {
-		filename := strings.TrimPrefix(r.URL.Path, "/isos/")
-		decodedFilename, err := url.PathUnescape(filename)
-		if err != nil {
-			log.Printf("ISO: Failed to decode filename %s: %v", filename, err)
-			http.Error(w, "Invalid filename", http.StatusBadRequest)
-			return
-		}
-
-		macAddress := r.URL.Query().Get("mac")
-		if macAddress == "" {
-			macAddress = "unknown"
-		} else {
-			macAddress = strings.ToLower(strings.ReplaceAll(macAddress, "-", ":"))
+	for _, p := range bootmethod.All() {
+		if rt, ok := p.(bootmethod.Router); ok {
+			prefix := "/bootmethod/" + p.Name()
+			mux.Handle(prefix+"/", http.StripPrefix(prefix, rt.Handler()))
 		}
+	}
 
-		fullPath := filepath.Join(s.config.ISODir, decodedFilename)
-
-		cleanPath := filepath.Clean(fullPath)
-		if !strings.HasPrefix(cleanPath, filepath.Clean(s.config.ISODir)) {
-			s.logAndBroadcast("ISO: Path traversal attempt from MAC %s (IP: %s): %s", macAddress, r.RemoteAddr, decodedFilename)
-			http.Error(w, "Forbidden", http.StatusForbidden)
+	mux.HandleFunc("/isos/", securepath.JoinHandler("/isos/", s.libraries.Join, s.rejectPath("ISO"), func(w http.ResponseWriter, r *http.Request, decodedFilename, fullPath string) {
+		macAddress := requestMAC(r)
+		if !s.allowImageFile(r, "iso", decodedFilename) {
+			http.Error(w, "Attestation required", http.StatusForbidden)
 			return
 		}
 
 		fileInfo, err := os.Stat(fullPath)
 		if err != nil {
+			if img, ok := s.externalImage(decodedFilename); ok {
+				s.serveExternalISO(w, r, img, macAddress)
+				return
+			}
+			if s.libraries.Exists(decodedFilename) {
+				if r.Header.Get("Range") == "" {
+					s.logAndBroadcast("ISO: Proxying %s from its remote library to MAC %s (IP: %s) while it is cached", decodedFilename, macAddress, r.RemoteAddr)
+				}
+				cw := &countingWriter{ResponseWriter: w}
+				s.libraries.ServeRemote(cw, r, decodedFilename)
+				s.stats.AddBytes(cw.written)
+				return
+			}
 			s.logAndBroadcast("ISO: File not found (MAC: %s, IP: %s): %s", macAddress, r.RemoteAddr, decodedFilename)
 			http.NotFound(w, r)
 			return
@@ -984,11 +1466,16 @@ func (s *Server) startHTTPServer() error {
 		}
 
 		rangeHeader := r.Header.Get("Range")
+		if rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-") {
+			s.libraries.Touch(decodedFilename)
+		}
+		if strings.HasPrefix(rangeHeader, "bytes=0-") {
+			// A sanboot client reading the start of the ISO.
+			s.recordBoot(macAddress, strings.TrimSuffix(decodedFilename, filepath.Ext(decodedFilename)), r.RemoteAddr)
+		}
 		if rangeHeader == "" {
 			s.logAndBroadcast("ISO Download: Client MAC %s (IP: %s) started downloading %s (%d MB)", macAddress, r.RemoteAddr, decodedFilename, fileInfo.Size()/1024/1024)
 			s.activeSessions.Add(r.RemoteAddr, decodedFilename, fileInfo.Size(), "downloading")
-		} else {
-			log.Printf("ISO: Range request from MAC %s (IP: %s) for %s - Range: %s", macAddress, r.RemoteAddr, decodedFilename, rangeHeader)
 		}
 
 		wrappedWriter := &completionLogger{
@@ -1002,34 +1489,20 @@ func (s *Server) startHTTPServer() error {
 
 		w.Header().Set("Content-Type", "application/octet-stream")
 		http.ServeFile(wrappedWriter, r, fullPath)
+		s.stats.AddBytes(wrappedWriter.written)
+		if start, ok := rangeStart(rangeHeader, fileInfo.Size()); ok {
+			s.recordRange(decodedFilename, fileInfo.Size(), start, wrappedWriter.written)
+		}
 
 		if rangeHeader == "" {
 			s.activeSessions.Remove(r.RemoteAddr)
 		}
-	})
-
-	mux.HandleFunc("/boot/", func(w http.ResponseWriter, r *http.Request) {
-		urlPath := strings.TrimPrefix(r.URL.Path, "/boot/")
-		decodedPath, err := url.PathUnescape(urlPath)
-		if err != nil {
-			log.Printf("Boot: Failed to decode path %s: %v", urlPath, err)
-			http.Error(w, "Invalid path", http.StatusBadRequest)
-			return
-		}
-
-		macAddress := r.URL.Query().Get("mac")
-		if macAddress == "" {
-			macAddress = "unknown"
-		} else {
-			macAddress = strings.ToLower(strings.ReplaceAll(macAddress, "-", ":"))
-		}
-
-		fullPath := filepath.Join(s.config.ISODir, decodedPath)
+	}))
 
-		cleanPath := filepath.Clean(fullPath)
-		if !strings.HasPrefix(cleanPath, filepath.Clean(s.config.ISODir)) {
-			s.logAndBroadcast("Boot: Path traversal attempt from MAC %s (IP: %s): %s", macAddress, r.RemoteAddr, decodedPath)
-			http.Error(w, "Forbidden", http.StatusForbidden)
+	mux.HandleFunc("/boot/", securepath.JoinHandler("/boot/", s.libraries.Join, s.rejectPath("Boot"), func(w http.ResponseWriter, r *http.Request, decodedPath, fullPath string) {
+		macAddress := requestMAC(r)
+		if !s.allowImageFile(r, "boot", decodedPath) {
+			http.Error(w, "Attestation required", http.StatusForbidden)
 			return
 		}
 
@@ -1052,8 +1525,10 @@ func (s *Server) startHTTPServer() error {
 			metrics.HTTPBootRequests.Inc()
 		}
 		w.Header().Set("Content-Type", "application/octet-stream")
-		http.ServeFile(w, r, fullPath)
-	})
+		cw := &countingWriter{ResponseWriter: w}
+		http.ServeFile(cw, r, fullPath)
+		s.stats.AddBytes(cw.written)
+	}))
 
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -1062,30 +1537,63 @@ func (s *Server) startHTTPServer() error {
 
 	mux.HandleFunc("/api/isos", s.handleListISOs)
 
-	mux.HandleFunc("/autoinstall/", s.handleAutoInstallScript)
+	mux.HandleFunc("/autoinstall/", s.requireBootToken(s.handleAutoInstallScript))
+	s.registerAutoInstallPaths(mux)
+	mux.HandleFunc("/wpad.dat", s.handleProxyPAC)
+	mux.HandleFunc("/proxy.pac", s.handleProxyPAC)
+	mux.HandleFunc("/ssh/authorized_keys", s.requireBootToken(s.handleAuthorizedKeys))
+	mux.HandleFunc("/nocloud/", s.requireBootToken(s.handleNoCloud))
+	s.registerMatchbox(mux)
+	mux.HandleFunc("/rescue/checkin", s.handleRescueCheckin)
+	mux.HandleFunc("/rescue/overlay.cpio", s.requireBootToken(s.handleRescueOverlay))
+	mux.HandleFunc("/rescue/", s.requireBootToken(s.handleRescueAutorun))
+	mux.HandleFunc("/callback/boot-complete", s.handleBootComplete)
+	mux.HandleFunc("/api/boot-error", s.handleBootError)
+	mux.HandleFunc("/console/upload", s.requireBootToken(s.handleConsoleUpload))
+	mux.HandleFunc("/console/agent.sh", s.requireBootToken(s.handleConsoleAgent))
+	mux.HandleFunc("/firstboot/install.sh", s.handleFirstBootInstall)
+	mux.HandleFunc("/firstboot/overlay.cpio", s.handleFirstBootOverlay)
+	mux.HandleFunc("/firstboot/register", s.handleFirstBootRegister)
+	mux.HandleFunc("/remote/", s.handleRemoteFile)
+	mux.HandleFunc("/tasks/", s.handleDiskTask)
+	mux.HandleFunc("/api/capture", s.handleCaptureUpload)
+	mux.HandleFunc("/api/attest", s.handleAttest)
+	mux.HandleFunc("/api/attest/nonce", s.handleAttestNonce)
+	mux.HandleFunc("/attest/enroll.sh", s.handleAttestScript)
+	mux.HandleFunc("/trusted/", s.handleTrusted(mux))
 
 	mux.HandleFunc("/files/", s.handleCustomFile)
 
 	mux.HandleFunc("/bootenv/", func(w http.ResponseWriter, r *http.Request) {
 		urlPath := strings.TrimPrefix(r.URL.Path, "/bootenv/")
 		filePath := path.Join("bootenv", urlPath)
-		log.Printf("HTTP: Bootenv request - %s (always embedded)", urlPath)
 
 		data, _, err := bootloaders.Resolve(bootloaders.DefaultSet, filePath)
 		if err != nil {
-			log.Printf("HTTP: Error reading embedded bootenv file %s: %v", filePath, err)
 			http.Error(w, "Not found", http.StatusNotFound)
 			return
 		}
-		log.Printf("HTTP: Successfully read embedded bootenv file %s (%d bytes)", filePath, len(data))
 		w.Header().Set("Content-Type", "application/octet-stream")
 		w.Write(data)
 	})
 
 	addr := fmt.Sprintf(":%d", s.config.HTTPPort)
+	// Installers fetch many files in a row (casper's squashfs and config,
+	// wimboot's BCD, SDI and WIMs), so connections are kept open for the
+	// next request rather than set up again each time. HTTP/2 is cleartext
+	// only, for clients that speak it with prior knowledge; everything
+	// else, iPXE included, stays on HTTP/1.1 keep-alive.
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(s.config.HTTP2)
 	s.httpServer = &http.Server{
-		Addr:    addr,
-		Handler: mux,
+		Addr:              addr,
+		Handler:           s.accessLog.Wrap(mux),
+		Protocols:         &protocols,
+		HTTP2:             &http.HTTP2Config{MaxConcurrentStreams: s.config.HTTP2MaxStreams},
+		ReadHeaderTimeout: 30 * time.Second,
+		IdleTimeout:       s.config.HTTPIdleTimeout,
+		BaseContext:       func(net.Listener) context.Context { return s.ctx },
 	}
 
 	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -1098,6 +1606,17 @@ func (s *Server) startHTTPServer() error {
 func (s *Server) startAdminServer() error {
 	log.Printf("Starting Admin server on port %d...", s.config.AdminPort)
 
+	var tlsConfig *tls.Config
+	if s.config.AdminTLS.enabled() {
+		var err error
+		if tlsConfig, err = s.config.AdminTLS.serverConfig(); err != nil {
+			return err
+		}
+		if s.config.AdminTLS.ClientCerts.CAFile != "" && s.config.Auth != nil {
+			s.config.Auth.UseClientCerts(s.config.AdminTLS.ClientCerts)
+		}
+	}
+
 	mux := http.NewServeMux()
 
 	s.setupAdminInterface(mux)
@@ -1110,13 +1629,28 @@ func (s *Server) startAdminServer() error {
 	mux.Handle("/metrics", promhttp.Handler())
 	go s.refreshMetricsGauges()
 
+	// gRPC clients speak HTTP/2, without TLS too.
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+
 	addr := fmt.Sprintf(":%d", s.config.AdminPort)
 	s.adminServer = &http.Server{
-		Addr:    addr,
-		Handler: panicRecoveryMiddleware(mux),
+		Addr:        addr,
+		Handler:     panicRecoveryMiddleware(s.rateLimit.Middleware(mux)),
+		TLSConfig:   tlsConfig,
+		Protocols:   protocols,
+		BaseContext: func(net.Listener) context.Context { return s.ctx },
 	}
 
-	if err := s.adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	var err error
+	if tlsConfig != nil {
+		err = s.adminServer.ListenAndServeTLS("", "")
+	} else {
+		err = s.adminServer.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("Admin server failed: %w", err)
 	}
 
@@ -1131,6 +1665,83 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 		adminHandler.SchedulerReload = s.scheduler.Reload
 		adminHandler.SchedulerRunNow = s.scheduler.RunNow
 	}
+	adminHandler.Matchbox = s.matchbox
+	adminHandler.Switchport = s.switchport
+	adminHandler.MenuPreview = s.PreviewMenu
+	adminHandler.SubscribeLogs = func() (<-chan string, func()) {
+		ch := s.logBroadcaster.Subscribe()
+		return ch, func() { s.logBroadcaster.Unsubscribe(ch) }
+	}
+	adminHandler.Branding = s.branding
+	adminHandler.Stats = s.stats
+	adminHandler.Usage = s.usage
+	adminHandler.Libraries = s.libraries
+	adminHandler.RateLimit = s.rateLimit
+	adminHandler.BootPolicy = s.policy
+	adminHandler.Ranges = s.ranges
+	adminHandler.MediaScript = s.bootMediaScript
+	adminHandler.ISCSITarget = s.iscsiTargetName
+	adminHandler.Catalog = s.catalog
+	adminHandler.Downloads = admin.NewDownloadQueue(s.config.MaxDownloads, s.config.DownloadRateLimit, s.config.DownloadRateLimitEach)
+	adminHandler.DisabledBootMethods = map[string]string{}
+	if !s.config.NBDEnabled {
+		adminHandler.DisabledBootMethods["nbd"] = "--nbd-enabled"
+	}
+	if !s.config.NFSEnabled {
+		adminHandler.DisabledBootMethods["nfs"] = "--nfs-enabled"
+	}
+	adminHandler.Context = s.ctx
+	adminHandler.NetworkWarning = s.config.NetworkWarning
+	if s.config.DHCPEnabled {
+		adminHandler.DHCPRange = s.config.DHCPRange
+	}
+	adminHandler.Transfers = func() []admin.ActiveTransfer {
+		var out []admin.ActiveTransfer
+		for _, a := range s.activeSessions.GetAll() {
+			out = append(out, admin.ActiveTransfer{IP: a.IP, Filename: a.Filename, Activity: a.Activity, StartedAt: a.StartedAt, BytesRead: a.BytesRead, TotalBytes: a.TotalBytes})
+		}
+		return out
+	}
+	adminHandler.PolicyInput = s.policyInput
+	adminHandler.IntegrityAlert = func(filename, reason string) {
+		s.raiseAlert(alerts.Alert{
+			Kind:    alerts.KindISOIntegrity,
+			Image:   filename,
+			Message: fmt.Sprintf("ISO %s failed its integrity check: %s", filename, reason),
+		})
+	}
+	if s.config.Storage != nil {
+		go adminHandler.TrackOCITags(ociTrackInterval)
+		if s.config.VerifyInterval > 0 {
+			go adminHandler.VerifyISOs(s.config.VerifyInterval)
+		}
+		if s.config.AutoKernelBoot {
+			go adminHandler.ConvertFailingSanboot()
+		}
+		if s.config.WimBackupMaxAge > 0 {
+			go adminHandler.PurgeWimBackups(s.config.WimBackupMaxAge)
+		}
+		go adminHandler.ResumeExtractions()
+	}
+	if s.usage != nil {
+		go s.usage.Run(s.ctx)
+	}
+	if s.catalog != nil && s.config.CatalogRefresh > 0 {
+		go s.catalog.Run(s.ctx, s.config.CatalogRefresh)
+	}
+	for _, k := range s.config.BootloaderSigningKeys {
+		data := []byte(k)
+		if raw, err := os.ReadFile(k); err == nil {
+			data = raw
+		}
+		key, err := bootloaders.ParsePublicKey(data)
+		if err != nil {
+			log.Printf("Ignoring bootloader signing key %q: %v", k, err)
+			continue
+		}
+		adminHandler.BootloaderKeys = append(adminHandler.BootloaderKeys, key)
+		log.Printf("Trusting bootloader signing key %s", bootloaders.KeyID(key))
+	}
 
 	staticFS, err := fs.Sub(web.Static, "static")
 	if err != nil {
@@ -1148,6 +1759,9 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 	}
 
 	mux.Handle("/", http.FileServer(http.FS(staticFS)))
+	if s.branding != nil {
+		mux.Handle("/branding/", s.branding)
+	}
 
 	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/", http.StatusFound)
@@ -1173,9 +1787,17 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 
 	mux.HandleFunc("/api/server-info", adminWrap(adminHandler.GetServerInfo))
 	mux.HandleFunc("/api/stats", adminWrap(adminHandler.GetStats))
+	mux.HandleFunc("/api/stats/timeseries", adminWrap(adminHandler.StatsTimeseries))
+	mux.HandleFunc("/api/stats/usage", adminWrap(adminHandler.UsageReport))
 	mux.HandleFunc("/api/logs", adminWrap(adminHandler.GetBootLogs))
 	mux.HandleFunc("/api/scan", adminWrap(adminHandler.ScanImages))
+	mux.HandleFunc("/api/libraries", adminWrap(adminHandler.ListLibraries))
 	mux.HandleFunc("/api/images/upload", adminWrap(adminHandler.UploadImage))
+	mux.HandleFunc("/api/images/clone", adminWrap(adminHandler.CloneImage))
+	mux.HandleFunc("/api/images/virtual", adminWrap(adminHandler.CreateVirtualImage))
+	mux.HandleFunc("/api/images/external", adminWrap(adminHandler.CreateExternalImage))
+	mux.HandleFunc("/api/images/oci", adminWrap(adminHandler.PullOCIImage))
+	mux.HandleFunc("/api/images/oci/sync", adminWrap(adminHandler.SyncOCIImage))
 	mux.HandleFunc("/api/assign-images", adminWrap(adminHandler.AssignImages))
 
 	mux.HandleFunc("/api/clients", adminWrap(func(w http.ResponseWriter, r *http.Request) {
@@ -1219,7 +1841,46 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 
 	mux.HandleFunc("/api/clients/wake", adminWrap(adminHandler.WakeClient))
 	mux.HandleFunc("/api/clients/next-boot", adminWrap(adminHandler.SetNextBootImage))
+	mux.HandleFunc("/api/remote-menus", adminWrap(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			adminHandler.ListRemoteMenus(w, r)
+		case http.MethodPost, http.MethodPut:
+			adminHandler.SaveRemoteMenu(w, r)
+		case http.MethodDelete:
+			adminHandler.DeleteRemoteMenu(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/remote-menus/check", adminWrap(adminHandler.CheckRemoteMenu))
+	mux.HandleFunc("/api/clients/boot-params", adminWrap(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			adminHandler.ListBootParamOverrides(w, r)
+		case http.MethodPost, http.MethodPut:
+			adminHandler.SaveBootParamOverride(w, r)
+		case http.MethodDelete:
+			adminHandler.DeleteBootParamOverride(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/clients/console", adminWrap(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			adminHandler.ListConsoleCaptures(w, r)
+		case http.MethodDelete:
+			adminHandler.DeleteConsoleCapture(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/clients/console/file", adminWrap(adminHandler.GetConsoleCaptureFile))
+	mux.HandleFunc("/api/clients/boot-params/try", adminWrap(adminHandler.TryBootParams))
 	mux.HandleFunc("/api/clients/promote", adminWrap(adminHandler.PromoteClient))
+	mux.HandleFunc("/api/clients/attestation", adminWrap(adminHandler.ClientAttestation))
+	mux.HandleFunc("/api/clients/switchport", adminWrap(adminHandler.ClientSwitchport))
 	mux.HandleFunc("/api/clients/inventory", adminWrap(adminHandler.GetClientInventory))
 	mux.HandleFunc("/api/clients/inventory/history", adminWrap(adminHandler.GetClientInventoryHistory))
 
@@ -1228,6 +1889,10 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 	mux.HandleFunc("/api/bootloaders/upload", adminWrap(adminHandler.UploadBootloader))
 	mux.HandleFunc("/api/bootloaders/delete", adminWrap(adminHandler.DeleteBootloader))
 	mux.HandleFunc("/api/bootloaders/select", adminWrap(adminHandler.SelectBootloader))
+	mux.HandleFunc("/api/bootloaders/update", adminWrap(adminHandler.BootloaderUpdate))
+	mux.HandleFunc("/api/bootloaders/served", adminWrap(adminHandler.ServedBootloaders))
+
+	mux.HandleFunc("/api/matchbox", adminWrap(adminHandler.ListMatchbox))
 
 	mux.HandleFunc("/api/tools", adminWrap(adminHandler.ListTools))
 	mux.HandleFunc("/api/tools/toggle", adminWrap(adminHandler.ToggleTool))
@@ -1238,6 +1903,7 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 	mux.HandleFunc("/api/tools/custom", adminWrap(adminHandler.CreateCustomTool))
 	mux.HandleFunc("/api/tools/custom/delete", adminWrap(adminHandler.DeleteCustomTool))
 	mux.HandleFunc("/api/tools/update", adminWrap(adminHandler.UpdateTools))
+	mux.HandleFunc("/api/loaders/memdisk", adminWrap(adminHandler.MemdiskLoader))
 
 	mux.HandleFunc("/api/images/extract", adminWrap(adminHandler.ExtractImage))
 	mux.HandleFunc("/api/images/extract-progress", adminWrap(adminHandler.ExtractProgress))
@@ -1255,11 +1921,28 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 	mux.HandleFunc("/api/profiles/delete", adminWrap(adminHandler.DeleteDistroProfile))
 	mux.HandleFunc("/api/profiles/update", adminWrap(adminHandler.UpdateDistroProfiles))
 	mux.HandleFunc("/api/iso-catalog", adminWrap(adminHandler.GetISOCatalog))
+	mux.HandleFunc("/api/catalog", adminWrap(adminHandler.GetISOCatalog))
+	mux.HandleFunc("/api/catalog/refresh", adminWrap(adminHandler.RefreshISOCatalog))
+	mux.HandleFunc("/api/capabilities", adminWrap(adminHandler.GetCapabilities))
 	mux.HandleFunc("/api/images/boot-method", adminWrap(adminHandler.SetBootMethod))
 
 	mux.HandleFunc("/api/active-sessions", adminWrap(s.handleActiveSessions))
+	mux.HandleFunc("/api/activity", adminWrap(adminHandler.Activity))
+	mux.HandleFunc("/api/dhcp/leases", adminWrap(adminHandler.DHCPLeases))
+	mux.HandleFunc("/api/rescue-sessions", adminWrap(s.handleRescueSessions))
 
 	mux.HandleFunc("/api/logs/stream", adminWrap(s.handleLogsStream))
+	// Every service the gRPC server has, reflection included, so grpcurl
+	// can list them.
+	grpcServer := adminHandler.GRPC()
+	for name := range grpcServer.GetServiceInfo() {
+		mux.HandleFunc("/"+name+"/", adminWrap(grpcServer.ServeHTTP))
+	}
+	if gateway, err := adminHandler.Gateway(s.ctx); err != nil {
+		log.Printf("Admin: gRPC gateway not available: %v", err)
+	} else {
+		mux.HandleFunc("/api/v1/", adminWrap(gateway.ServeHTTP))
+	}
 	mux.HandleFunc("/api/logs/buffer", adminWrap(s.handleLogsBuffer))
 
 	mux.HandleFunc("/api/users", adminWrap(func(w http.ResponseWriter, r *http.Request) {
@@ -1279,10 +1962,27 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 	mux.HandleFunc("/api/users/reset-password", adminWrap(adminHandler.ResetUserPassword))
 
 	mux.HandleFunc("/api/images/download", adminWrap(adminHandler.DownloadISO))
+	mux.HandleFunc("/api/image-specs/apply", adminWrap(adminHandler.ApplyImageSpecs))
+	mux.HandleFunc("/api/replicate", adminWrap(adminHandler.Replicate))
+	mux.HandleFunc("/api/replicate/file", adminWrap(adminHandler.ReplicateFile))
+	mux.HandleFunc("/api/replicate/image", adminWrap(adminHandler.ReplicateImage))
+	mux.HandleFunc("/api/security/bans", adminWrap(adminHandler.Bans))
+	mux.HandleFunc("/api/policy", adminWrap(adminHandler.Policy))
+	mux.HandleFunc("/api/policy/test", adminWrap(adminHandler.TestPolicy))
+	mux.HandleFunc("/api/images/ranges", adminWrap(adminHandler.ImageRanges))
+	mux.HandleFunc("/api/images/ranges/prefetch", adminWrap(adminHandler.PrefetchImageRanges))
+	mux.HandleFunc("/api/images/kernel-suggestions", adminWrap(adminHandler.KernelBootSuggestions))
 	mux.HandleFunc("/api/downloads", adminWrap(adminHandler.ListDownloads))
 	mux.HandleFunc("/api/downloads/progress", adminWrap(adminHandler.GetDownloadProgress))
+	mux.HandleFunc("/api/downloads/queue", adminWrap(adminHandler.DownloadQueueStatus))
+	mux.HandleFunc("/api/uploads", adminWrap(adminHandler.ListUploads))
 
 	mux.HandleFunc("/api/images/netboot/download", adminWrap(adminHandler.DownloadNetboot))
+	mux.HandleFunc("/api/images/netboot/status", adminWrap(adminHandler.NetbootStatus))
+	mux.HandleFunc("/api/images/initrd-lite", adminWrap(adminHandler.LiteInitrd))
+	mux.HandleFunc("/api/netboot/sources", adminWrap(adminHandler.ListNetbootSources))
+	mux.HandleFunc("/api/jobs", adminWrap(adminHandler.ListJobs))
+	mux.HandleFunc("/api/jobs/", adminWrap(adminHandler.GetJob))
 
 	mux.HandleFunc("/api/images/autoinstall", adminWrap(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -1295,6 +1995,9 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 		}
 	}))
 
+	mux.HandleFunc("/api/images/autoinstall/preview", adminWrap(s.handleAutoInstallPreview))
+	mux.HandleFunc("/api/autoinstall/generate", adminWrap(adminHandler.GenerateAutoInstall))
+
 	mux.HandleFunc("/api/files", adminWrap(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -1322,6 +2025,8 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 	mux.HandleFunc("/api/drivers/upload", adminWrap(adminHandler.UploadDriverPack))
 	mux.HandleFunc("/api/drivers/delete", adminWrap(adminHandler.DeleteDriverPack))
 	mux.HandleFunc("/api/drivers/rebuild", adminWrap(adminHandler.RebuildImageBootWim))
+	mux.HandleFunc("/api/drivers/backups", adminWrap(adminHandler.WimBackups))
+	mux.HandleFunc("/api/drivers/backups/restore", adminWrap(adminHandler.RestoreWimBackup))
 
 	mux.HandleFunc("/api/groups", adminWrap(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -1351,6 +2056,17 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 	}))
 	mux.HandleFunc("/api/webhook/test", adminWrap(adminHandler.TestWebhook))
 
+	mux.HandleFunc("/api/access", adminWrap(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			adminHandler.GetAccessConfig(w, r)
+		case http.MethodPut, http.MethodPost:
+			adminHandler.UpdateAccessConfig(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
 	mux.HandleFunc("/api/client-groups", adminWrap(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -1386,7 +2102,7 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 	mux.HandleFunc("/api/clients/power", adminWrap(adminHandler.PowerClient))
 	mux.HandleFunc("/api/clients/power/status", adminWrap(adminHandler.PowerStatusClient))
 
-	mux.HandleFunc("/api/theme", adminWrap(func(w http.ResponseWriter, r *http.Request) {
+	menuSettings := adminWrap(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
 			adminHandler.GetMenuTheme(w, r)
@@ -1395,10 +2111,48 @@ func (s *Server) setupAdminInterface(mux *http.ServeMux) {
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
-	}))
+	})
+	mux.HandleFunc("/api/settings/menu", menuSettings)
+	mux.HandleFunc("/api/theme", menuSettings) // the original path, kept for existing scripts
+
+	mux.HandleFunc("/api/menu/draft", adminWrap(adminHandler.MenuDraft))
+	mux.HandleFunc("/api/menu/draft/publish", adminWrap(adminHandler.PublishMenuDraft))
+	mux.HandleFunc("/api/menu/draft/preview", adminWrap(adminHandler.PreviewMenuDraft))
+	mux.HandleFunc("/api/revisions", adminWrap(adminHandler.Revisions))
+	mux.HandleFunc("/api/revisions/revert", adminWrap(adminHandler.RevertRevision))
+	mux.HandleFunc("/api/branding", adminWrap(adminHandler.BrandingAssets))
+	mux.HandleFunc("/api/maintenance/orphans", adminWrap(adminHandler.Orphans))
+	mux.HandleFunc("/api/maintenance/integrity", adminWrap(adminHandler.Integrity))
+	mux.HandleFunc("/api/images/verify", adminWrap(adminHandler.Integrity))
 
 	mux.HandleFunc("/api/usb", adminWrap(adminHandler.ListUSBImages))
 	mux.HandleFunc("/api/usb/download", adminWrap(adminHandler.DownloadUSBImage))
+	mux.HandleFunc("/api/bootstrap-media", adminWrap(adminHandler.BootstrapMedia))
+
+	mux.HandleFunc("/api/disk-tasks", adminWrap(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			adminHandler.ListDiskTasks(w, r)
+		case http.MethodPost:
+			adminHandler.CreateDiskTask(w, r)
+		case http.MethodDelete:
+			adminHandler.CancelDiskTask(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/disk-images", adminWrap(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			adminHandler.ListDiskImages(w, r)
+		case http.MethodPut:
+			adminHandler.UpdateDiskImage(w, r)
+		case http.MethodDelete:
+			adminHandler.DeleteDiskImage(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
 
 	mux.HandleFunc("/api/images/files", adminWrap(adminHandler.ListImageFiles))
 	mux.HandleFunc("/api/images/files/delete", adminWrap(adminHandler.DeleteImageFile))
@@ -1471,8 +2225,8 @@ func (s *Server) handleAutoexec(w http.ResponseWriter, r *http.Request) {
 
 	script := fmt.Sprintf(`#!ipxe
 dhcp
-chain http://%s:%d/inventory?mac=%s&cpu=${cpuid/0}&memsize=${memsize}&platform=${platform}&buildarch=${buildarch}&product=${product}&manufacturer=${manufacturer}&serial=${serial}&asset=${asset}&uuid=${uuid}&nic_chip=${net0/chip} || chain http://%s:%d/menu.ipxe?mac=%s
-`, s.config.ServerAddr, s.config.HTTPPort, macAddress, s.config.ServerAddr, s.config.HTTPPort, macAddress)
+chain http://%s:%d/inventory?mac=%s&cpu=${cpuid/0}&memsize=${memsize}&platform=${platform}&secureboot=${efi/SecureBoot:uint8}&buildarch=${buildarch}&product=${product}&manufacturer=${manufacturer}&serial=${serial}&asset=${asset}&uuid=${uuid}&nic_chip=${net0/chip}%s || chain http://%s:%d/menu.ipxe?mac=%s%s%s
+`, s.config.ServerAddr, s.config.HTTPPort, macAddress, s.bootTokenParam(false), s.config.ServerAddr, s.config.HTTPPort, macAddress, s.bootTokenParam(false), s.menuFailoverChain(macAddress, false))
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(script))
@@ -1579,7 +2333,7 @@ func resolveRedfishForClient(c *models.Client, g *models.ClientGroup) (host stri
 	host = c.IPMIHost
 	port = c.IPMIPort
 	user = c.IPMIUsername
-	pass = c.IPMIPassword
+	pass = string(c.IPMIPassword)
 	insecure = c.IPMIInsecure
 	if g != nil {
 		if port == 0 {
@@ -1589,7 +2343,7 @@ func resolveRedfishForClient(c *models.Client, g *models.ClientGroup) (host stri
 			user = g.IPMIUsername
 		}
 		if pass == "" {
-			pass = g.IPMIPassword
+			pass = string(g.IPMIPassword)
 		}
 		if !insecure {
 			insecure = g.IPMIInsecure
@@ -1618,14 +2372,19 @@ func (s *Server) refreshMetricsGauges() {
 }
 
 func (s *Server) recordBootIfNew(mac, path, remoteAddr string) {
-	if s.config.Storage == nil || mac == "" || mac == "unknown" {
-		return
-	}
 	slash := strings.Index(path, "/")
 	if slash <= 0 {
 		return
 	}
-	imageDir := path[:slash]
+	s.recordBoot(mac, path[:slash], remoteAddr)
+}
+
+// recordBoot logs a boot of the image whose files live under imageDir,
+// unless the same client started it within the last 30 seconds.
+func (s *Server) recordBoot(mac, imageDir, remoteAddr string) {
+	if s.config.Storage == nil || mac == "" || mac == "unknown" {
+		return
+	}
 
 	key := mac + "|" + imageDir
 	s.bootLogDedupMu.Lock()
@@ -1645,37 +2404,49 @@ func (s *Server) recordBootIfNew(mac, path, remoteAddr string) {
 	s.bootLogDedupMu.Unlock()
 
 	imageName := imageDir
+	var bootImage *models.Image
 	if images, err := s.config.Storage.ListImages(); err == nil {
 		for _, img := range images {
-			if strings.TrimSuffix(img.Filename, filepath.Ext(img.Filename)) == imageDir {
+			if img.CloneOf == "" && strings.TrimSuffix(img.Filename, filepath.Ext(img.Filename)) == imageDir {
 				imageName = img.Name
+				bootImage = img
 				break
 			}
 		}
 	}
 	metrics.BootAttempts.WithLabelValues(imageName).Inc()
+	s.alerts.BootStarted(imageName)
 	go func() {
-		if err := s.config.Storage.LogBootAttempt(mac, imageName, remoteAddr, true, ""); err != nil {
-			log.Printf("Boot log: failed to write for %s: %v", mac, err)
-		}
+		s.logBootAttempt(mac, imageName, remoteAddr, true, "")
 		s.config.Storage.UpdateClientBootStats(mac)
 		s.config.Storage.UpdateImageBootStats(imageName)
 	}()
 	clientName := ""
-	if c, err := s.config.Storage.GetClient(mac); err == nil {
-		clientName = c.Name
+	client, err := s.config.Storage.GetClient(mac)
+	if err == nil {
+		clientName = client.Name
+	} else {
+		client = nil
 	}
 	ip := remoteAddr
 	if i := strings.LastIndex(ip, ":"); i > 0 {
 		ip = ip[:i]
 	}
-	s.webhookNotifier.Fire(webhook.Event{
+	ev := webhook.Event{
 		Event:      webhook.EventBootStarted,
 		MAC:        mac,
 		ClientName: clientName,
 		Image:      imageName,
 		IP:         ip,
-	})
+	}
+	s.webhookNotifier.Fire(ev)
+	s.hooks.Go(hooks.PostBootSelect, ev)
+
+	if client != nil && bootImage != nil && s.switchport.Enabled() {
+		if _, _, _, err := s.resolveAutoInstallScript(bootImage, client); err == nil {
+			go s.moveSwitchport(client, switchport.StateProvisioning, "installing "+imageName)
+		}
+	}
 }
 
 func (s *Server) handleInventoryReport(w http.ResponseWriter, r *http.Request) {
@@ -1683,7 +2454,7 @@ func (s *Server) handleInventoryReport(w http.ResponseWriter, r *http.Request) {
 
 	mac := strings.ToLower(strings.ReplaceAll(r.FormValue("mac"), "-", ":"))
 	if mac == "" || mac == "${net0/mac}" {
-		http.Redirect(w, r, fmt.Sprintf("/menu.ipxe?mac=unknown"), http.StatusTemporaryRedirect)
+		http.Redirect(w, r, "/menu.ipxe?mac=unknown"+s.bootTokenParam(true), http.StatusTemporaryRedirect)
 		return
 	}
 
@@ -1703,6 +2474,7 @@ func (s *Server) handleInventoryReport(w http.ResponseWriter, r *http.Request) {
 		Memory:       memBytes,
 		Platform:     r.FormValue("platform"),
 		BuildArch:    r.FormValue("buildarch"),
+		SecureBoot:   parseSecureBoot(r.FormValue("secureboot")),
 		Product:      r.FormValue("product"),
 		Manufacturer: r.FormValue("manufacturer"),
 		Serial:       r.FormValue("serial"),
@@ -1716,6 +2488,7 @@ func (s *Server) handleInventoryReport(w http.ResponseWriter, r *http.Request) {
 		if _, err := s.config.Storage.GetClient(mac); err != nil {
 			isNewClient = true
 		}
+		s.claimBootloader(mac, r.RemoteAddr)
 	}
 
 	if s.config.Storage != nil {
@@ -1724,6 +2497,13 @@ func (s *Server) handleInventoryReport(w http.ResponseWriter, r *http.Request) {
 		} else {
 			log.Printf("Inventory: Saved hardware info for %s (product: %s, manufacturer: %s, memory: %d)", mac, inv.Product, inv.Manufacturer, inv.Memory)
 		}
+		if inv.Platform != "" && !strings.HasPrefix(inv.Platform, "${") {
+			now := time.Now()
+			c := &models.Client{Firmware: inv.Platform, SecureBoot: inv.SecureBoot, PlatformAt: &now}
+			if err := s.config.Storage.UpdateClientPlatform(mac, c); err != nil {
+				log.Printf("Inventory: Failed to record platform for %s: %v", mac, err)
+			}
+		}
 	}
 
 	clientName := ""
@@ -1735,6 +2515,7 @@ func (s *Server) handleInventoryReport(w http.ResponseWriter, r *http.Request) {
 		ip = ip[:i]
 	}
 	if isNewClient {
+		s.alerts.UnknownClient(mac)
 		s.webhookNotifier.Fire(webhook.Event{
 			Event:      webhook.EventClientDiscovered,
 			MAC:        mac,
@@ -1754,11 +2535,22 @@ func (s *Server) handleInventoryReport(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	script := fmt.Sprintf("#!ipxe\nchain http://%s:%d/menu.ipxe?mac=%s\n", s.config.ServerAddr, s.config.HTTPPort, mac)
+	script := fmt.Sprintf("#!ipxe\nchain http://%s:%d/menu.ipxe?mac=%s%s\n", s.config.ServerAddr, s.config.HTTPPort, mac, s.bootTokenParam(true))
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(script))
 }
 
+// parseSecureBoot reads iPXE's ${efi/SecureBoot:uint8}, which is empty
+// on BIOS and on firmware or iPXE builds that do not expose it.
+func parseSecureBoot(v string) *bool {
+	n, err := strconv.ParseUint(strings.TrimSpace(v), 0, 8)
+	if err != nil {
+		return nil
+	}
+	on := n != 0
+	return &on
+}
+
 func (s *Server) handleIPXEMenu(w http.ResponseWriter, r *http.Request) {
 	macAddress := r.URL.Query().Get("mac")
 	if macAddress == "" {
@@ -1768,22 +2560,45 @@ func (s *Server) handleIPXEMenu(w http.ResponseWriter, r *http.Request) {
 	macAddress = strings.ToLower(strings.ReplaceAll(macAddress, "-", ":"))
 
 	s.logAndBroadcast("Client Connected: MAC %s (IP: %s) requesting boot menu", macAddress, r.RemoteAddr)
+	if macAddress != "unknown" && s.config.Storage != nil {
+		s.claimBootloader(macAddress, r.RemoteAddr)
+	}
 
-	var nextBootImageID uint
-	if s.config.Storage != nil {
-		client, err := s.config.Storage.GetClient(macAddress)
-		if err == nil && client.NextBootImage != "" {
-			img, imgErr := s.config.Storage.GetImage(client.NextBootImage)
-			if imgErr == nil && img.Enabled {
-				s.logAndBroadcast("Client %s: next boot action set - pre-selecting %s", macAddress, img.Name)
-				nextBootImageID = img.ID
-				s.config.Storage.ClearNextBootImage(macAddress)
-			} else {
-				s.config.Storage.ClearNextBootImage(macAddress)
+	if macAddress != "unknown" && s.hooks.Enabled(hooks.PreMenu) {
+		ev := webhook.Event{MAC: macAddress, IP: r.RemoteAddr}
+		if i := strings.LastIndex(ev.IP, ":"); i > 0 {
+			ev.IP = ev.IP[:i]
+		}
+		if s.config.Storage != nil {
+			if c, err := s.config.Storage.GetClient(macAddress); err == nil {
+				ev.ClientName = c.Name
+			}
+		}
+		s.hooks.Run(hooks.PreMenu, ev)
+	}
+
+	if s.config.Storage != nil && r.URL.Query().Get("skip_task") == "" {
+		if task, err := s.config.Storage.GetPendingDiskTask(macAddress); err == nil {
+			script, err := s.diskTaskBootScript(task)
+			if err == nil {
+				s.logAndBroadcast("Client %s: booting into pending %s task #%d", macAddress, task.Kind, task.ID)
+				w.Header().Set("Content-Type", "text/plain")
+				w.Write([]byte(script))
+				return
 			}
+			log.Printf("Disk task %d: cannot boot: %v", task.ID, err)
 		}
 	}
 
+	if script, ok := s.localBootScript(macAddress); ok {
+		s.logAndBroadcast("Client %s: booting from local disk after its one-shot boot", macAddress)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(script))
+		return
+	}
+
+	next, overrides := s.pendingBoot(macAddress, true)
+
 	var images []models.Image
 	var err error
 
@@ -1799,171 +2614,81 @@ func (s *Server) handleIPXEMenu(w http.ResponseWriter, r *http.Request) {
 		images = convertISOsToImages(isos)
 	}
 
-	menu := s.generateIPXEMenuWithGroups(images, macAddress, nextBootImageID)
+	session := s.trustedSession(r, macAddress)
+	images = s.withholdUntrusted(macAddress, session != "", images)
+	menu := s.generateIPXEMenuWithGroups(images, macAddress, hostOnly(r.RemoteAddr), session, next, overrides, false)
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(menu))
 }
 
-func (s *Server) generateIPXEMenu(images []models.Image, macAddress string) string {
-	tmpl := `#!ipxe
-
-:start
-menu Bootimus - Boot Menu
-item --gap -- Available Images:
-{{range $index, $img := .Images}}
-item iso{{$index}} {{$img.Name}} ({{$img.SizeStr}}){{if $img.Extracted}} [kernel]{{end}}
-{{end}}
-item --gap -- Options:
-item shell Drop to iPXE shell
-item reboot Reboot
-choose --default iso0 --timeout 30000 selected || goto start
-goto ${selected}
-
-{{range $index, $img := .Images}}
-:iso{{$index}}
-echo Booting {{$img.Name}}...
-{{if eq $img.BootMethod "kernel"}}
-echo Loading kernel and initrd...
-{{if $img.AutoInstallEnabled}}
-echo Auto-install enabled for this image
-{{end}}
-{{if eq $img.Distro "windows"}}
-echo Loading Windows boot files via wimboot...
-kernel http://{{$.ServerAddr}}:{{$.HTTPPort}}/wimboot
-initrd http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/boot.wim boot.wim
-{{if $img.InstallWimPath}}initrd --name {{$img.InstallBasename}} http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/{{$img.InstallBasename}}
-{{end}}boot || goto failed
-{{else if eq $img.Distro "arch"}}
-kernel http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/vmlinuz {{$img.AutoInstallParam}}{{$img.BootParams}}archiso_http_srv=http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/iso/ ip=dhcp
-{{else if eq $img.Distro "nixos"}}
-kernel http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/vmlinuz {{$img.AutoInstallParam}}{{$img.BootParams}} ip=dhcp
-{{else if or (eq $img.Distro "fedora") (eq $img.Distro "centos")}}
-kernel http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/vmlinuz {{$img.AutoInstallParam}}root=live:http://{{$.ServerAddr}}:{{$.HTTPPort}}/isos/{{$img.EncodedFilename}} rd.live.image inst.repo=http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/iso/ inst.stage2=http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/iso/ rd.neednet=1 ip=dhcp
-{{else if eq $img.Distro "debian"}}
-kernel http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/vmlinuz {{$img.AutoInstallParam}}{{$img.BootParams}} initrd=initrd ip=dhcp priority=critical
-{{else if eq $img.Distro "ubuntu"}}
-{{if $img.NetbootAvailable}}
-kernel http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/vmlinuz {{$img.AutoInstallParam}}{{$img.BootParams}} initrd=initrd ip=dhcp
-{{else}}
-{{if $img.SquashfsPath}}
-kernel http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/vmlinuz {{$img.AutoInstallParam}}{{$img.BootParams}} initrd=initrd ip=dhcp fetch=http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/{{$img.SquashfsPath}}
-{{else}}
-kernel http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/vmlinuz {{$img.AutoInstallParam}}{{$img.BootParams}} initrd=initrd ip=dhcp url=http://{{$.ServerAddr}}:{{$.HTTPPort}}/isos/{{$img.EncodedFilename}}
-{{end}}
-{{end}}
-{{else if eq $img.Distro "freebsd"}}
-kernel http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/vmlinuz vfs.root.mountfrom=cd9660:/dev/md0 kernelname=/boot/kernel/kernel
-{{else}}
-kernel http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/vmlinuz {{$img.AutoInstallParam}}{{$img.BootParams}}iso-url=http://{{$.ServerAddr}}:{{$.HTTPPort}}/isos/{{$img.EncodedFilename}} ip=dhcp
-{{end}}
-{{if ne $img.Distro "windows"}}
-initrd http://{{$.ServerAddr}}:{{$.HTTPPort}}/boot/{{$img.CacheDir}}/initrd
-{{end}}
-boot || goto failed
-{{else}}
-sanboot --no-describe --drive 0x80 http://{{$.ServerAddr}}:{{$.HTTPPort}}/isos/{{$img.EncodedFilename}}?mac={{$.MAC}}
-{{end}}
-goto start
-{{end}}
+// nextBoot is the image a client's next menu pre-selects; with once it
+// boots without the menu, and the client boots locally afterwards.
+type nextBoot struct {
+	imageID uint
+	once    bool
+}
 
-:failed
-echo Boot failed! Press any key to return to menu...
-prompt
-goto start
-
-:shell
-echo Type 'exit' to return to menu
-shell
-goto start
-
-:reboot
-reboot
-`
-
-	t, _ := template.New("menu").Parse(tmpl)
-
-	type ImageData struct {
-		Name               string
-		Filename           string
-		EncodedFilename    string
-		SizeStr            string
-		BootMethod         string
-		Extracted          bool
-		BootParams         string
-		CacheDir           string
-		Distro             string
-		AutoInstallEnabled bool
-		AutoInstallURL     string
-		AutoInstallParam   string
-		SquashfsPath       string
-		NetbootAvailable   bool
-		InstallWimPath     string
-		InstallBasename    string
-	}
-
-	imageData := make([]ImageData, len(images))
-	for i, img := range images {
-		cacheDir := strings.TrimSuffix(img.Filename, filepath.Ext(img.Filename))
-
-		autoInstallURL := ""
-		autoInstallParam := ""
-		if img.AutoInstallEnabled && img.AutoInstallScript != "" {
-			autoInstallURL = fmt.Sprintf("http://%s:%d/autoinstall/%s?mac=${net0/mac}", s.config.ServerAddr, s.config.HTTPPort, url.PathEscape(img.Filename))
-
-			switch img.AutoInstallScriptType {
-			case "preseed":
-				autoInstallParam = fmt.Sprintf("auto=true priority=critical url=%s ", autoInstallURL)
-			case "kickstart":
-				autoInstallParam = fmt.Sprintf("inst.ks=%s ", autoInstallURL)
-			case "autoinstall":
-				autoInstallParam = fmt.Sprintf("autoinstall ds=nocloud-net;s=%s/ ", autoInstallURL)
-			case "autounattend":
-				autoInstallParam = ""
-			default:
-				autoInstallParam = fmt.Sprintf("autoinstall=%s ", autoInstallURL)
+// pendingBoot returns the image a next-boot action or try-once override
+// pre-selects for a client, and its boot param overrides. With consume the
+// one-shot entries are cleared, as they are once a client has its menu, and
+// a one-shot boot switches the client to local boot.
+func (s *Server) pendingBoot(macAddress string, consume bool) (nextBoot, []*models.BootParamOverride) {
+	var next nextBoot
+	if s.config.Storage == nil {
+		return next, nil
+	}
+	client, err := s.config.Storage.GetClient(macAddress)
+	if err == nil && client.NextBootImage != "" {
+		img, imgErr := s.config.Storage.GetImage(client.NextBootImage)
+		if imgErr == nil && img.Enabled {
+			if consume && client.NextBootOnce {
+				s.logAndBroadcast("Client %s: one-shot boot of %s; local disk from now on", macAddress, img.Name)
+			} else if consume {
+				s.logAndBroadcast("Client %s: next boot action set - pre-selecting %s", macAddress, img.Name)
 			}
+			next = nextBoot{imageID: img.ID, once: client.NextBootOnce}
 		}
-
-		installBasename := "install.wim"
-		if img.InstallWimPath != "" && strings.Contains(strings.ToLower(img.InstallWimPath), ".esd") {
-			installBasename = "install.esd"
+		if consume {
+			s.config.Storage.ClearNextBootImage(macAddress)
+			if next.once {
+				if err := s.config.Storage.SetClientLocalBoot(macAddress, true); err != nil {
+					log.Printf("Client %s: failed to switch to local boot: %v", macAddress, err)
+				}
+			}
 		}
+	}
 
-		imageData[i] = ImageData{
-			Name:               img.Name,
-			Filename:           img.Filename,
-			EncodedFilename:    url.PathEscape(img.Filename),
-			SizeStr:            formatBytes(img.Size),
-			BootMethod:         img.BootMethod,
-			Extracted:          img.Extracted,
-			BootParams:         img.BootParams,
-			CacheDir:           url.PathEscape(cacheDir),
-			Distro:             img.Distro,
-			AutoInstallEnabled: img.AutoInstallEnabled,
-			AutoInstallURL:     autoInstallURL,
-			AutoInstallParam:   autoInstallParam,
-			SquashfsPath:       img.SquashfsPath,
-			NetbootAvailable:   img.NetbootAvailable,
-			InstallWimPath:     img.InstallWimPath,
-			InstallBasename:    installBasename,
+	overrides, _ := s.config.Storage.ListBootParamOverrides(macAddress)
+	for _, o := range overrides {
+		if !o.Once {
+			continue
+		}
+		if consume {
+			s.logAndBroadcast("Client %s: trying boot params once: %s", macAddress, o.Params)
+		}
+		if next.imageID == 0 && o.ImageFilename != "" {
+			if img, err := s.config.Storage.GetImage(o.ImageFilename); err == nil && img.Enabled {
+				next.imageID = img.ID
+			}
 		}
 	}
-
-	data := struct {
-		Images     []ImageData
-		ServerAddr string
-		HTTPPort   int
-		MAC        string
-	}{
-		Images:     imageData,
-		ServerAddr: s.config.ServerAddr,
-		HTTPPort:   s.config.HTTPPort,
-		MAC:        macAddress,
+	if consume {
+		s.config.Storage.ClearOnceBootParamOverrides(macAddress)
 	}
+	return next, overrides
+}
 
-	var buf bytes.Buffer
-	t.Execute(&buf, data)
-	return buf.String()
+// localBootScript is what a client switched to local boot gets instead of
+// its menu.
+func (s *Server) localBootScript(macAddress string) (string, bool) {
+	if s.config.Storage == nil {
+		return "", false
+	}
+	client, err := s.config.Storage.GetClient(macAddress)
+	if err != nil || !client.LocalBoot {
+		return "", false
+	}
+	return "#!ipxe\necho Booting from local disk...\nexit\n", true
 }
 
 func (s *Server) handleListISOs(w http.ResponseWriter, r *http.Request) {
@@ -1985,6 +2710,7 @@ func (s *Server) handleListISOs(w http.ResponseWriter, r *http.Request) {
 		isos, _ := s.scanISOs()
 		images = convertISOsToImages(isos)
 	}
+	images = s.withholdUntrusted(macAddress, s.trustedSession(r, macAddress) != "", images)
 
 	w.Header().Set("Content-Type", "text/plain")
 	fmt.Fprintf(w, "Available ISO images:\n")
@@ -2000,19 +2726,13 @@ func (s *Server) handleCustomFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	decodedFilename, err := url.PathUnescape(filename)
-	if err != nil {
-		log.Printf("CustomFile: Failed to decode filename %s: %v", filename, err)
-		http.Error(w, "Invalid filename", http.StatusBadRequest)
-		return
-	}
-
-	cleanFilename := filepath.Clean(decodedFilename)
-	if cleanFilename == "." || cleanFilename == ".." || strings.Contains(cleanFilename, "..") {
-		log.Printf("CustomFile: Path traversal attempt: %s from %s", decodedFilename, r.RemoteAddr)
+	cleanFilename, err := securepath.Clean(filename)
+	if err != nil || cleanFilename == "." {
+		log.Printf("CustomFile: Rejected path %q from %s", filename, r.RemoteAddr)
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
+	cleanFilename = filepath.ToSlash(cleanFilename)
 
 	var file *models.CustomFile
 	if s.config.Storage != nil {
@@ -2028,22 +2748,21 @@ func (s *Server) handleCustomFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var fullPath string
+	var root string
 	if file.Public {
-		fullPath = filepath.Join(s.config.DataDir, "files", cleanFilename)
+		root = filepath.Join(s.config.DataDir, "files")
 	} else if file.ImageID != nil && file.Image != nil {
 		imageName := strings.TrimSuffix(file.Image.Filename, filepath.Ext(file.Image.Filename))
-		fullPath = filepath.Join(s.config.ISODir, imageName, "files", cleanFilename)
+		root = filepath.Join(s.libraries.Dir(file.Image.Filename), imageName, "files")
 	} else {
 		log.Printf("CustomFile: Invalid file configuration for %s", cleanFilename)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	cleanPath := filepath.Clean(fullPath)
-	dataDir := filepath.Clean(s.config.DataDir)
-	if !strings.HasPrefix(cleanPath, dataDir) {
-		log.Printf("CustomFile: Path traversal attempt: %s from %s", cleanFilename, r.RemoteAddr)
+	fullPath, err := securepath.Join(root, cleanFilename)
+	if err != nil {
+		log.Printf("CustomFile: Rejected path %s from %s: %v", cleanFilename, r.RemoteAddr, err)
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
@@ -2095,13 +2814,40 @@ func (s *Server) handleAutoInstallScript(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	mac := r.URL.Query().Get("mac")
+	// Subiquity takes its autoinstall file as a NoCloud seed,
+	// <file>/nocloud/<mac>/, and appends user-data or meta-data itself.
+	if file, seed, ok := strings.Cut(path, "/nocloud/"); ok {
+		seedMAC, part, _ := strings.Cut(seed, "/")
+		if part == "meta-data" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprintf(w, "instance-id: bootimus-%s\n", strings.ReplaceAll(seedMAC, ":", ""))
+			return
+		}
+		if part != "user-data" {
+			http.NotFound(w, r)
+			return
+		}
+		path, mac = file, seedMAC
+	}
+
+	if seedMAC, part, ok := strings.Cut(path, "/"); ok && validMAC(seedMAC) {
+		s.handleNoCloudByMAC(w, r, seedMAC, part)
+		return
+	}
+
 	image, err := s.config.Storage.GetImage(path)
 	if err != nil || image == nil {
 		http.Error(w, "Image not found", http.StatusNotFound)
 		return
 	}
+	s.serveAutoInstall(w, r, image, mac, "")
+}
 
-	mac := strings.ToLower(strings.ReplaceAll(r.URL.Query().Get("mac"), "-", ":"))
+// serveAutoInstall renders the image's auto-install script for the client
+// with the given MAC. A non-empty wantType refuses scripts of other types.
+func (s *Server) serveAutoInstall(w http.ResponseWriter, r *http.Request, image *models.Image, mac, wantType string) {
+	mac = strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
 	var client *models.Client
 	if mac != "" {
 		if c, err := s.config.Storage.GetClient(mac); err == nil {
@@ -2114,32 +2860,20 @@ func (s *Server) handleAutoInstallScript(w http.ResponseWriter, r *http.Request)
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
-
-	clientName := ""
-	if client != nil {
-		clientName = client.Name
+	if wantType != "" && scriptType != wantType {
+		http.Error(w, fmt.Sprintf("%s has a %s script, not %s", image.Name, scriptType, wantType), http.StatusNotFound)
+		return
 	}
+
 	clientIP := r.RemoteAddr
 	if i := strings.LastIndex(clientIP, ":"); i > 0 {
 		clientIP = clientIP[:i]
 	}
-	substitutions := map[string]string{
-		"{{MAC}}":            mac,
-		"{{CLIENT_NAME}}":    clientName,
-		"{{HOSTNAME}}":       clientName,
-		"{{IP}}":             clientIP,
-		"{{SERVER_ADDR}}":    s.config.ServerAddr,
-		"{{IMAGE_NAME}}":     image.Name,
-		"{{IMAGE_FILENAME}}": image.Filename,
-	}
-	for k, v := range substitutions {
-		script = strings.ReplaceAll(script, k, v)
-	}
-
-	if image.Distro == "arch" {
-		if files, _ := s.config.Storage.ListCustomFilesByImage(image.ID); len(files) > 0 {
-			script = s.injectArchFileDownloads(script, files)
-		}
+	script, err = s.buildAutoInstall(script, scriptType, image, client, s.autoInstallVars(image, client, mac, clientIP))
+	if err != nil {
+		log.Printf("Auto-install: failed to render %s for %s (source: %s): %v", image.Filename, mac, source, err)
+		http.Error(w, "Auto-install template failed: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	contentType := "text/plain; charset=utf-8"
@@ -2159,6 +2893,147 @@ func (s *Server) handleAutoInstallScript(w http.ResponseWriter, r *http.Request)
 		image.Filename, source, scriptType, len(script))
 }
 
+func (s *Server) autoInstallVars(image *models.Image, client *models.Client, mac, clientIP string) map[string]string {
+	clientName := ""
+	if client != nil {
+		clientName = client.Name
+	}
+	vars := map[string]string{
+		"{{MAC}}":            mac,
+		"{{CLIENT_NAME}}":    clientName,
+		"{{HOSTNAME}}":       clientName,
+		"{{IP}}":             clientIP,
+		"{{SERVER_ADDR}}":    s.config.ServerAddr,
+		"{{IMAGE_NAME}}":     "",
+		"{{IMAGE_FILENAME}}": "",
+		"{{NTP_SERVER}}":     s.ntpServerAddr(),
+		"{{FIRSTBOOT_URL}}":  fmt.Sprintf("http://%s:%d/firstboot/install.sh?mac=%s", s.config.ServerAddr, s.config.HTTPPort, mac),
+		"{{CALLBACK_URL}}":   fmt.Sprintf("http://%s:%d/callback/boot-complete?mac=%s", s.config.ServerAddr, s.config.HTTPPort, mac),
+		"{{CONSOLE_AGENT}}":  fmt.Sprintf("http://%s:%d/console/agent.sh?mac=%s%s", s.config.ServerAddr, s.config.HTTPPort, mac, s.bootTokenParam(true)),
+		"{{BASE_URL}}":       fmt.Sprintf("http://%s:%d", s.config.ServerAddr, s.config.HTTPPort),
+	}
+	for k, v := range s.installProxyVars(net.ParseIP(clientIP)) {
+		vars[k] = v
+	}
+	if image != nil {
+		vars["{{IMAGE_NAME}}"] = image.Name
+		vars["{{IMAGE_FILENAME}}"] = image.Filename
+		for k, v := range imageSecretVars(image) {
+			vars[k] = v
+		}
+	}
+	if access, err := s.config.Storage.GetAccessConfig(); err == nil {
+		for k, v := range autoinstall.SeedVars(access) {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+// renderAutoInstall runs a script written as a Go template, then
+// substitutes placeholders.
+// buildAutoInstall turns a resolved script into what the client receives:
+// rendered, with Arch's file downloads, and with the seeded SSH keys and
+// default user added unless the script places them itself. Previews use it
+// too, so they show exactly what is served.
+func (s *Server) buildAutoInstall(script, scriptType string, image *models.Image, client *models.Client, vars map[string]string) (string, error) {
+	seeded := autoinstall.UsesSeedVars(script)
+	script, err := s.renderAutoInstall(script, vars, client)
+	if err != nil {
+		return "", err
+	}
+	if image.Distro == "arch" {
+		if files, _ := s.config.Storage.ListCustomFilesByImage(image.ID); len(files) > 0 {
+			script = s.injectArchFileDownloads(script, files)
+		}
+	}
+	if !seeded {
+		script = s.injectAccess(script, scriptType, image.Filename)
+	}
+	return script, nil
+}
+
+func (s *Server) renderAutoInstall(script string, vars map[string]string, client *models.Client) (string, error) {
+	if autoinstall.IsTemplate(script) {
+		group := ""
+		if client != nil && client.ClientGroupID != nil {
+			if g, err := s.config.Storage.GetClientGroup(*client.ClientGroupID); err == nil {
+				group = g.Name
+			}
+		}
+		var err error
+		if script, err = autoinstall.RenderTemplate(script, autoinstall.NewTemplateData(vars, client, group)); err != nil {
+			return "", err
+		}
+	}
+	for k, v := range vars {
+		script = strings.ReplaceAll(script, k, v)
+	}
+	return script, nil
+}
+
+// imageSecretVars are the secrets a generator stored with the image, by
+// placeholder. Values going into an XML answer file are escaped, and the
+// admin password is also given in Windows' obfuscated forms.
+func imageSecretVars(image *models.Image) map[string]string {
+	if image.AutoInstallSecrets == "" {
+		return nil
+	}
+	var vars map[string]string
+	if err := json.Unmarshal([]byte(image.AutoInstallSecrets), &vars); err != nil {
+		log.Printf("Auto-install: unreadable secrets for %s: %v", image.Filename, err)
+		return nil
+	}
+	if image.AutoInstallScriptType == "autounattend" {
+		return autoinstall.UnattendSecretVars(vars)
+	}
+	return vars
+}
+
+// injectAccess adds the seeded SSH keys and default user to a rendered
+// script that didn't place them itself.
+func (s *Server) injectAccess(script, scriptType, name string) string {
+	access, err := s.config.Storage.GetAccessConfig()
+	if err != nil {
+		return script
+	}
+	out, err := autoinstall.InjectAccess(script, scriptType, access)
+	if err != nil {
+		log.Printf("Auto-install: could not add SSH keys to %s: %v", name, err)
+		return script
+	}
+	return out
+}
+
+// ntpServerAddr is the address clients should sync time from, or empty when
+// the NTP responder isn't running.
+func (s *Server) ntpServerAddr() string {
+	if s.ntpServer == nil {
+		return ""
+	}
+	return s.config.ServerAddr
+}
+
+// handleAuthorizedKeys lets live and rescue environments pull the seeded keys
+// at boot (e.g. from an initrd hook) without a full auto-install render.
+func (s *Server) handleAuthorizedKeys(w http.ResponseWriter, r *http.Request) {
+	if s.config.Storage == nil {
+		http.Error(w, "SSH key seeding requires database", http.StatusInternalServerError)
+		return
+	}
+	access, err := s.config.Storage.GetAccessConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	keys := autoinstall.AuthorizedKeys(access)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, k := range keys {
+		fmt.Fprintln(w, k)
+	}
+	log.Printf("Served %d seeded SSH key(s) to %s", len(keys), r.RemoteAddr)
+}
+
 func (s *Server) resolveAutoInstallScript(image *models.Image, client *models.Client) (string, string, string, error) {
 	if s.autoInstallLib != nil {
 		tryFile := func(rel, src string) (string, string, string, error) {
@@ -2219,8 +3094,8 @@ func (s *Server) injectArchFileDownloads(script string, files []*models.CustomFi
 		return script
 	}
 
-	serverIP := GetOutboundIP()
-	serverPort := "8080"
+	serverIP := s.config.ServerAddr
+	serverPort := strconv.Itoa(s.config.HTTPPort)
 
 	var downloadCommands strings.Builder
 	downloadCommands.WriteString("\n\n# Download custom files from Bootimus\n")
@@ -2263,14 +3138,3 @@ func convertISOsToImages(isos []ISOImage) []models.Image {
 	}
 	return images
 }
-
-func GetOutboundIP() string {
-	conn, err := net.Dial("udp", "8.8.8.8:80")
-	if err != nil {
-		return "127.0.0.1"
-	}
-	defer conn.Close()
-
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	return localAddr.IP.String()
-}
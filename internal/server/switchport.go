@@ -0,0 +1,24 @@
+package server
+
+import (
+	"log"
+
+	"bootimus/internal/models"
+)
+
+// moveSwitchport moves client's access port to the VLAN for state and saves
+// the outcome. Failures are logged and shown on the client, never fatal to
+// the boot or install they came from.
+func (s *Server) moveSwitchport(client *models.Client, state, reason string) {
+	if client.SwitchName == "" || client.SwitchPort == "" {
+		return
+	}
+	if err := s.switchport.Apply(client, state, reason); err != nil {
+		s.logAndBroadcast("Switchport: Failed to move %s to %s VLAN: %v", client.MACAddress, state, err)
+	} else {
+		s.logAndBroadcast("Switchport: %s %s (%s) moved to %s VLAN %d", client.SwitchName, client.SwitchPort, client.MACAddress, state, client.SwitchVLAN)
+	}
+	if err := s.config.Storage.UpdateClientSwitchState(client.MACAddress, client); err != nil {
+		log.Printf("Switchport: Failed to save state for %s: %v", client.MACAddress, err)
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+// featuresInUse names the optional features this instance has turned on,
+// for usage reports.
+func (s *Server) featuresInUse() []string {
+	c := s.config
+	var out []string
+	for name, on := range map[string]bool{
+		"proxy_dhcp":     c.ProxyDHCPEnabled,
+		"dhcp":           c.DHCPEnabled,
+		"nbd":            c.NBDEnabled,
+		"iscsi":          c.ISCSIEnabled,
+		"nfs":            c.NFSEnabled,
+		"windows_smb":    c.WindowsSMBEnabled,
+		"mdns":           c.MDNSEnabled,
+		"dns":            c.DNSEnabled,
+		"ntp":            c.NTPEnabled,
+		"http2":          c.HTTP2,
+		"boot_token":     c.BootToken != "",
+		"menu_pin":       c.MenuPIN != "",
+		"failover":       len(c.FailoverURLs) > 0,
+		"attestation":    c.RequireAttestation,
+		"hooks":          len(c.Hooks) > 0,
+		"switchport":     len(c.Switchport.Switches) > 0,
+		"log_sinks":      len(c.LogSinks) > 0,
+		"admin_tls":      c.AdminTLS.enabled(),
+		"libraries":      len(c.Libraries) > 0,
+		"install_proxy":  len(c.InstallProxy) > 0,
+		"catalog_source": len(c.CatalogSources) > 0,
+	} {
+		if on {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,266 @@
+// Package stats rolls boot activity up into hourly and daily buckets so the
+// dashboard can graph long ranges without scanning the boot log.
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PeriodHour = "hour"
+	PeriodDay  = "day"
+
+	// Hourly buckets are dropped after this; daily ones are kept.
+	HourlyRetention = 14 * 24 * time.Hour
+	// MaxRange is the longest range a series may cover, and how far back
+	// daily buckets are backfilled from the boot log.
+	MaxRange = 400 * 24 * time.Hour
+
+	interval = 5 * time.Minute
+)
+
+type Recorder struct {
+	store storage.Storage
+	bytes atomic.Int64
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func New(store storage.Storage) *Recorder {
+	return &Recorder{store: store, done: make(chan struct{})}
+}
+
+// AddBytes counts bytes served to clients. They are written to the current
+// buckets on the next rollup.
+func (r *Recorder) AddBytes(n int64) {
+	if r != nil && n > 0 {
+		r.bytes.Add(n)
+	}
+}
+
+// Start backfills missing buckets from the boot log, then rolls up every
+// few minutes until Shutdown.
+func (r *Recorder) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := r.backfill(time.Now()); err != nil {
+			log.Printf("Stats: Backfill failed: %v", err)
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := r.Run(time.Now()); err != nil {
+				log.Printf("Stats: Rollup failed: %v", err)
+			}
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (r *Recorder) Shutdown() {
+	close(r.done)
+	r.wg.Wait()
+	if err := r.flushBytes(time.Now()); err != nil {
+		log.Printf("Stats: Failed to save bytes served: %v", err)
+	}
+}
+
+// Run brings the current and previous hour and day up to date and drops
+// expired hourly buckets.
+func (r *Recorder) Run(now time.Time) error {
+	if err := r.flushBytes(now); err != nil {
+		return err
+	}
+	for _, period := range []string{PeriodHour, PeriodDay} {
+		cur := Truncate(period, now)
+		for _, start := range []time.Time{prev(period, cur), cur} {
+			if err := r.rollup(period, start); err != nil {
+				return err
+			}
+		}
+	}
+	return r.store.DeleteStatsRollupsBefore(PeriodHour, Truncate(PeriodHour, now.Add(-HourlyRetention)).UTC())
+}
+
+func (r *Recorder) flushBytes(now time.Time) error {
+	n := r.bytes.Swap(0)
+	if n == 0 {
+		return nil
+	}
+	for _, period := range []string{PeriodHour, PeriodDay} {
+		if err := r.store.AddStatsBytes(period, Truncate(period, now).UTC(), n); err != nil {
+			r.bytes.Add(n)
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) rollup(period string, start time.Time) error {
+	sum, err := r.summarize(period, start)
+	if err != nil {
+		return err
+	}
+	return r.store.SaveStatsRollup(sum)
+}
+
+func (r *Recorder) summarize(period string, start time.Time) (*models.StatsRollup, error) {
+	// Boot log times are stored in local time; SQLite compares them as text.
+	sum, err := r.store.SummarizeBootLogs(start.Local(), next(period, start).Local())
+	if err != nil {
+		return nil, err
+	}
+	sum.Period = period
+	sum.Start = start.UTC()
+	return sum, nil
+}
+
+// backfill rolls up buckets that have boot log entries but no rollup yet,
+// as far back as the retention of each period.
+func (r *Recorder) backfill(now time.Time) error {
+	earliest, err := r.store.EarliestBootLog()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for period, keep := range map[string]time.Duration{PeriodHour: HourlyRetention, PeriodDay: MaxRange} {
+		from := Truncate(period, now.Add(-keep))
+		if e := Truncate(period, earliest); e.After(from) {
+			from = e
+		}
+		to := Truncate(period, now)
+		existing, err := r.store.ListStatsRollups(period, from.UTC(), to.UTC())
+		if err != nil {
+			return err
+		}
+		have := make(map[int64]bool, len(existing))
+		for _, e := range existing {
+			have[e.Start.Unix()] = true
+		}
+		n := 0
+		for start := from; start.Before(to); start = next(period, start) {
+			if have[start.Unix()] {
+				continue
+			}
+			if err := r.rollup(period, start); err != nil {
+				return err
+			}
+			n++
+		}
+		if n > 0 {
+			log.Printf("Stats: Backfilled %d %s rollups from the boot log", n, period)
+		}
+	}
+	return nil
+}
+
+// Series returns one point per bucket from the one holding since to the
+// one holding now, oldest first, with empty buckets as zeroes. The current
+// bucket is summarised live rather than read from its last rollup.
+func (r *Recorder) Series(period string, since, now time.Time) ([]models.StatsRollup, error) {
+	from, cur := Truncate(period, since), Truncate(period, now)
+	to := next(period, cur)
+	rollups, err := r.store.ListStatsRollups(period, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	byStart := make(map[int64]*models.StatsRollup, len(rollups))
+	for _, ru := range rollups {
+		byStart[ru.Start.Unix()] = ru
+	}
+
+	var points []models.StatsRollup
+	for start := from; start.Before(to); start = next(period, start) {
+		p := models.StatsRollup{Period: period, Start: start.UTC()}
+		if ru, ok := byStart[start.Unix()]; ok {
+			p = *ru
+		}
+		if start.Equal(cur) {
+			live, err := r.summarize(period, start)
+			if err != nil {
+				return nil, err
+			}
+			p.Boots, p.FailedBoots, p.ActiveClients = live.Boots, live.FailedBoots, live.ActiveClients
+			p.BytesServed += r.bytes.Load()
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// ParseRange reads a range such as 24h, 7d or 12w.
+func ParseRange(s string) (time.Duration, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid range %q", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid range %q", s)
+	}
+	var unit time.Duration
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	case "w":
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("invalid range %q: use h, d or w", s)
+	}
+	d := time.Duration(n) * unit
+	if d > MaxRange {
+		return 0, fmt.Errorf("range %q is longer than %d days", s, int(MaxRange/(24*time.Hour)))
+	}
+	return d, nil
+}
+
+// PeriodFor picks hourly buckets for ranges up to a week, daily beyond.
+func PeriodFor(d time.Duration) string {
+	if d <= 7*24*time.Hour {
+		return PeriodHour
+	}
+	return PeriodDay
+}
+
+// Truncate returns the UTC start of the bucket holding t.
+func Truncate(period string, t time.Time) time.Time {
+	t = t.UTC()
+	if period == PeriodDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+func next(period string, t time.Time) time.Time {
+	if period == PeriodDay {
+		return t.AddDate(0, 0, 1)
+	}
+	return t.Add(time.Hour)
+}
+
+func prev(period string, t time.Time) time.Time {
+	if period == PeriodDay {
+		return t.AddDate(0, 0, -1)
+	}
+	return t.Add(-time.Hour)
+}
@@ -0,0 +1,107 @@
+package stats
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// newStore returns a store plus a second handle on its database, for
+// writing boot logs at chosen times.
+func newStore(t *testing.T) (*storage.SQLiteStore, *gorm.DB) {
+	t.Helper()
+	dir := t.TempDir()
+	st, err := storage.NewSQLiteStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "bootimus.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st, db
+}
+
+func bootAt(t *testing.T, db *gorm.DB, at time.Time, mac string, ok bool) {
+	t.Helper()
+	if err := db.Create(&models.BootLog{CreatedAt: at.Local(), MACAddress: mac, ImageName: "x", Success: ok}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollupAndSeries(t *testing.T) {
+	st, db := newStore(t)
+	now := time.Date(2026, 3, 10, 14, 30, 0, 0, time.UTC)
+	bootAt(t, db, now.Add(-10*time.Minute), "aa", true)
+	bootAt(t, db, now.Add(-5*time.Minute), "aa", false)
+	bootAt(t, db, now.Add(-2*time.Hour), "bb", true)
+	bootAt(t, db, now.Add(-3*24*time.Hour), "cc", true)
+
+	r := New(st)
+	if err := r.backfill(now); err != nil {
+		t.Fatal(err)
+	}
+	r.AddBytes(1000)
+	if err := r.Run(now); err != nil {
+		t.Fatal(err)
+	}
+
+	days, err := r.Series(PeriodDay, now.Add(-7*24*time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 8 {
+		t.Fatalf("got %d daily points, want 8", len(days))
+	}
+	today := days[len(days)-1]
+	if today.Boots != 3 || today.FailedBoots != 1 || today.ActiveClients != 2 || today.BytesServed != 1000 {
+		t.Errorf("today = %+v", today)
+	}
+	if d := days[len(days)-4]; d.Boots != 1 || d.ActiveClients != 1 {
+		t.Errorf("three days ago = %+v", d)
+	}
+
+	hours, err := r.Series(PeriodHour, now.Add(-3*time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var boots []int64
+	for _, h := range hours {
+		boots = append(boots, h.Boots)
+	}
+	if len(boots) != 4 || boots[1] != 1 || boots[3] != 2 {
+		t.Errorf("hourly boots = %v, want [0 1 0 2]", boots)
+	}
+
+	// A second rollup must not double the bytes already written.
+	if err := r.Run(now); err != nil {
+		t.Fatal(err)
+	}
+	days, _ = r.Series(PeriodDay, now, now)
+	if days[0].BytesServed != 1000 {
+		t.Errorf("bytes after second rollup = %d, want 1000", days[0].BytesServed)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	for in, want := range map[string]time.Duration{"24h": 24 * time.Hour, "30d": 30 * 24 * time.Hour, "2w": 14 * 24 * time.Hour} {
+		got, err := ParseRange(in)
+		if err != nil || got != want {
+			t.Errorf("ParseRange(%q) = %v, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"", "d", "0d", "-1d", "5y", "1000d"} {
+		if _, err := ParseRange(in); err == nil {
+			t.Errorf("ParseRange(%q) accepted", in)
+		}
+	}
+}
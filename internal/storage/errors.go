@@ -0,0 +1,7 @@
+package storage
+
+import "errors"
+
+// ErrConflict is returned by versioned updates when the record was changed
+// by someone else after the caller read it.
+var ErrConflict = errors.New("record was modified by another request")
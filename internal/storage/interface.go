@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"context"
 	"io"
+	"time"
 
 	"bootimus/internal/models"
 )
@@ -14,17 +16,30 @@ type Storage interface {
 	AutoMigrate() error
 	Close() error
 	Snapshotter
+	Transaction(fn func(tx Storage) error) error
+	// WithContext returns a view of the store whose queries are abandoned
+	// when ctx is done, for calls made on behalf of a request.
+	WithContext(ctx context.Context) Storage
 
 	ListClients() ([]*models.Client, error)
 	GetClient(mac string) (*models.Client, error)
 	CreateClient(client *models.Client) error
 	UpdateClient(mac string, client *models.Client) error
+	UpdateClientVersion(mac string, client *models.Client, version int) error
+	UpdateClientAttestation(mac string, client *models.Client) error
+	UpdateClientSwitchState(mac string, client *models.Client) error
+	UpdateClientBootloader(mac string, client *models.Client) error
+	UpdateClientPlatform(mac string, client *models.Client) error
+	UpdateClientRegistration(mac string, client *models.Client) error
 	DeleteClient(mac string) error
+	UndeleteClient(mac string) error
 
 	ListImages() ([]*models.Image, error)
 	GetImage(filename string) (*models.Image, error)
 	CreateImage(image *models.Image) error
 	UpdateImage(filename string, image *models.Image) error
+	UpdateImageVersion(filename string, image *models.Image, version int) error
+	ListImageVariants(filename string) ([]*models.Image, error)
 	DeleteImage(filename string) error
 	SyncImages(isoFiles []models.SyncFile) error
 
@@ -32,7 +47,9 @@ type Storage interface {
 	GetClientImages(mac string) ([]string, error)
 	GetImagesForClient(macAddress string) ([]models.Image, error)
 	SetNextBootImage(mac string, imageFilename string) error
+	SetOneShotBoot(mac string, imageFilename string) error // boots the image once, then the local disk
 	ClearNextBootImage(mac string) error
+	SetClientLocalBoot(mac string, local bool) error
 
 	EnsureAdminUser() (username, password string, created bool, err error)
 	ResetAdminPassword() (string, error)
@@ -79,10 +96,39 @@ type Storage interface {
 
 	GetMenuTheme() (*models.MenuTheme, error)
 	UpdateMenuTheme(theme *models.MenuTheme) error
+	GetMenuSnapshot() (*models.MenuSnapshot, error)
+	CreateMenuSnapshot(snap *models.MenuSnapshot) error
+	DeleteMenuSnapshot() error
+	RestoreMenuSnapshot(snap *models.MenuSnapshot) error
+
+	CreateRevision(rev *models.Revision) error
+	ListRevisions(entityType, key string, limit int) ([]*models.Revision, error)
+	DeleteRevision(id uint) error
+
+	SummarizeBootLogs(from, to time.Time) (*models.StatsRollup, error)
+	BootMethodStats(since time.Time) ([]models.BootMethodStat, error)
+	BootDistroStats(from, to time.Time) ([]models.BootDistroStat, error)
+	SaveStatsRollup(rollup *models.StatsRollup) error
+	AddStatsBytes(period string, start time.Time, n int64) error
+	ListStatsRollups(period string, from, to time.Time) ([]*models.StatsRollup, error)
+	DeleteStatsRollupsBefore(period string, before time.Time) error
+	EarliestBootLog() (time.Time, error)
+
+	SaveJob(job *models.Job) error
+	ListJobs(limit int) ([]*models.Job, error)
+	DeleteJobsBefore(id uint64) error
+
+	FindOrphans() ([]Orphans, error)
+	FixOrphans(kinds []string) (map[string]int64, error)
+
+	RewriteSecrets() (int, error)
 
 	GetWebhookConfig() (*models.WebhookConfig, error)
 	UpdateWebhookConfig(cfg *models.WebhookConfig) error
 
+	GetAccessConfig() (*models.AccessConfig, error)
+	UpdateAccessConfig(cfg *models.AccessConfig) error
+
 	ListScheduledTasks() ([]*models.ScheduledTask, error)
 	ListScheduledTasksByGroup(groupID uint) ([]*models.ScheduledTask, error)
 	GetScheduledTask(id uint) (*models.ScheduledTask, error)
@@ -106,10 +152,50 @@ type Storage interface {
 	UpdateImageBootStats(imageName string) error
 	GetBootLogs(limit int) ([]models.BootLog, error)
 	GetBootLogsByMAC(macAddress string, limit int) ([]models.BootLog, error)
+	// CompleteBootLog marks the client's latest successful boot that
+	// hasn't completed yet as a finished install.
+	CompleteBootLog(macAddress, hostname, installedOS string) (*models.BootLog, error)
 
 	SaveHardwareInventory(inventory *models.HardwareInventory) error
 	GetLatestHardwareInventory(mac string) (*models.HardwareInventory, error)
 	GetHardwareInventoryHistory(mac string, limit int) ([]models.HardwareInventory, error)
 
+	ListDiskImages() ([]*models.DiskImage, error)
+	GetDiskImage(id uint) (*models.DiskImage, error)
+	CreateDiskImage(img *models.DiskImage) error
+	UpdateDiskImage(img *models.DiskImage) error
+	DeleteDiskImage(id uint) error
+
+	ListDiskTasks(limit int) ([]*models.DiskTask, error)
+	GetDiskTask(id uint) (*models.DiskTask, error)
+	GetPendingDiskTask(mac string) (*models.DiskTask, error)
+	CreateDiskTask(task *models.DiskTask) error
+	UpdateDiskTask(task *models.DiskTask) error
+	DeleteDiskTask(id uint) error
+
+	ListBootParamOverrides(mac string) ([]*models.BootParamOverride, error)
+	SaveBootParamOverride(o *models.BootParamOverride) error
+	DeleteBootParamOverride(id uint) error
+	ClearOnceBootParamOverrides(mac string) error
+
+	ListRemoteMenus() ([]*models.RemoteMenu, error)
+	GetRemoteMenu(id uint) (*models.RemoteMenu, error)
+	SaveRemoteMenu(m *models.RemoteMenu) error // creates it when ID is 0
+	DeleteRemoteMenu(id uint) error
+
+	ListDHCPLeases() ([]*models.DHCPLease, error)
+	SaveDHCPLease(l *models.DHCPLease) error // replaces the MAC's lease
+	DeleteDHCPLease(mac string) error
+
+	ListExtractionStates() ([]*models.ExtractionState, error)
+	SaveExtractionState(st *models.ExtractionState) error // replaces the file's state
+	DeleteExtractionState(filename string) error
+
+	ListConsoleCaptures(mac string, limit int) ([]*models.ConsoleCapture, error)
+	GetConsoleCapture(id uint) (*models.ConsoleCapture, error)
+	FindSerialCapture(mac string, bootLogID *uint, source string) (*models.ConsoleCapture, error)
+	SaveConsoleCapture(c *models.ConsoleCapture) error
+	DeleteConsoleCapture(id uint) error
+
 	GetStats() (map[string]int64, error)
 }
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of dangling reference FindOrphans looks for.
+const (
+	OrphanBootLogClients = "boot_log_clients" // boot logs whose client is deleted
+	OrphanBootLogImages  = "boot_log_images"  // boot logs whose image is deleted
+	OrphanClientImages   = "client_images"    // image assignments to a deleted image or a client that is gone for good
+	OrphanImageGroups    = "image_groups"     // images in a deleted group
+	OrphanClientGroups   = "client_groups"    // clients in a deleted client group
+)
+
+var OrphanKinds = []string{OrphanBootLogClients, OrphanBootLogImages, OrphanClientImages, OrphanImageGroups, OrphanClientGroups}
+
+// orphanSampleSize caps the IDs listed per kind in a report.
+const orphanSampleSize = 20
+
+type Orphans struct {
+	Kind   string `json:"kind"`
+	Count  int64  `json:"count"`
+	Sample []uint `json:"sample,omitempty"` // row IDs, or image IDs for client_images
+	Fix    string `json:"fix"`
+}
+
+type orphanQuery struct {
+	table string
+	id    string // column listed in samples
+	where string
+	fix   func(tx *gorm.DB, where string) error
+	desc  string
+}
+
+func nullColumn(table, column string) func(*gorm.DB, string) error {
+	return func(tx *gorm.DB, where string) error {
+		return tx.Exec(fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s", table, column, where)).Error
+	}
+}
+
+// Soft-deleted clients keep their image assignments so they can be brought
+// back; only assignments of clients with no row at all are orphans.
+var orphanQueries = map[string]orphanQuery{
+	OrphanBootLogClients: {
+		table: "boot_logs", id: "id",
+		where: "client_id IS NOT NULL AND client_id NOT IN (SELECT id FROM clients WHERE deleted_at IS NULL)",
+		fix:   nullColumn("boot_logs", "client_id"),
+		desc:  "Unlink from the client; the MAC address is kept",
+	},
+	OrphanBootLogImages: {
+		table: "boot_logs", id: "id",
+		where: "image_id IS NOT NULL AND image_id NOT IN (SELECT id FROM images WHERE deleted_at IS NULL)",
+		fix:   nullColumn("boot_logs", "image_id"),
+		desc:  "Unlink from the image; the image name is kept",
+	},
+	OrphanClientImages: {
+		table: "client_images", id: "image_id",
+		where: "image_id NOT IN (SELECT id FROM images WHERE deleted_at IS NULL) OR client_id NOT IN (SELECT id FROM clients)",
+		fix: func(tx *gorm.DB, where string) error {
+			return tx.Exec("DELETE FROM client_images WHERE " + where).Error
+		},
+		desc: "Delete the assignment",
+	},
+	OrphanImageGroups: {
+		table: "images", id: "id",
+		where: "deleted_at IS NULL AND group_id IS NOT NULL AND group_id NOT IN (SELECT id FROM image_groups WHERE deleted_at IS NULL)",
+		fix:   nullColumn("images", "group_id"),
+		desc:  "Move the image to the top level of the menu",
+	},
+	OrphanClientGroups: {
+		table: "clients", id: "id",
+		where: "deleted_at IS NULL AND client_group_id IS NOT NULL AND client_group_id NOT IN (SELECT id FROM client_groups WHERE deleted_at IS NULL)",
+		fix:   nullColumn("clients", "client_group_id"),
+		desc:  "Remove the client from the group",
+	},
+}
+
+func findOrphans(db *gorm.DB) ([]Orphans, error) {
+	out := make([]Orphans, 0, len(OrphanKinds))
+	for _, kind := range OrphanKinds {
+		q := orphanQueries[kind]
+		o := Orphans{Kind: kind, Fix: q.desc}
+		if err := db.Table(q.table).Where(q.where).Count(&o.Count).Error; err != nil {
+			return nil, fmt.Errorf("%s: %w", kind, err)
+		}
+		if o.Count > 0 {
+			if err := db.Table(q.table).Where(q.where).Order(q.id).Limit(orphanSampleSize).Pluck(q.id, &o.Sample).Error; err != nil {
+				return nil, fmt.Errorf("%s: %w", kind, err)
+			}
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+// fixOrphans applies the fix for each kind, all or nothing, and returns how
+// many rows each touched.
+func fixOrphans(db *gorm.DB, kinds []string) (map[string]int64, error) {
+	fixed := make(map[string]int64, len(kinds))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, kind := range kinds {
+			q, ok := orphanQueries[kind]
+			if !ok {
+				return fmt.Errorf("unknown orphan kind %q", kind)
+			}
+			var n int64
+			if err := tx.Table(q.table).Where(q.where).Count(&n).Error; err != nil {
+				return err
+			}
+			if n == 0 {
+				continue
+			}
+			if err := q.fix(tx, q.where); err != nil {
+				return fmt.Errorf("%s: %w", kind, err)
+			}
+			fixed[kind] = n
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fixed, nil
+}
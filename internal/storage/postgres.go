@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -95,6 +96,18 @@ func (s *PostgresStore) AutoMigrate() error {
 		&models.DistroProfile{},
 		&models.WebhookConfig{},
 		&models.ScheduledTask{},
+		&models.AccessConfig{},
+		&models.DiskImage{},
+		&models.DiskTask{},
+		&models.BootParamOverride{},
+		&models.DHCPLease{},
+		&models.ExtractionState{},
+		&models.ConsoleCapture{},
+		&models.MenuSnapshot{},
+		&models.Revision{},
+		&models.StatsRollup{},
+		&models.RemoteMenu{},
+		&models.Job{},
 	); err != nil {
 		return err
 	}
@@ -156,6 +169,85 @@ func (s *PostgresStore) migrateCustomFileUniqueIndex() error {
 	return nil
 }
 
+func (s *PostgresStore) CreateRevision(rev *models.Revision) error {
+	return createRevision(s.db, rev)
+}
+
+func (s *PostgresStore) ListRevisions(entityType, key string, limit int) ([]*models.Revision, error) {
+	return listRevisions(s.db, entityType, key, limit)
+}
+
+func (s *PostgresStore) DeleteRevision(id uint) error {
+	return s.db.Delete(&models.Revision{}, id).Error
+}
+
+func (s *PostgresStore) SummarizeBootLogs(from, to time.Time) (*models.StatsRollup, error) {
+	return summarizeBootLogs(s.db, from, to)
+}
+
+func (s *PostgresStore) BootMethodStats(since time.Time) ([]models.BootMethodStat, error) {
+	return bootMethodStats(s.db, since)
+}
+
+func (s *PostgresStore) BootDistroStats(from, to time.Time) ([]models.BootDistroStat, error) {
+	return bootDistroStats(s.db, from, to)
+}
+
+func (s *PostgresStore) SaveJob(job *models.Job) error {
+	return s.db.Save(job).Error
+}
+
+func (s *PostgresStore) ListJobs(limit int) ([]*models.Job, error) {
+	var jobs []*models.Job
+	err := s.db.Order("id DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+func (s *PostgresStore) DeleteJobsBefore(id uint64) error {
+	return s.db.Where("id < ?", id).Delete(&models.Job{}).Error
+}
+
+func (s *PostgresStore) SaveStatsRollup(rollup *models.StatsRollup) error {
+	return saveStatsRollup(s.db, rollup)
+}
+
+func (s *PostgresStore) AddStatsBytes(period string, start time.Time, n int64) error {
+	return addStatsBytes(s.db, period, start, n)
+}
+
+func (s *PostgresStore) ListStatsRollups(period string, from, to time.Time) ([]*models.StatsRollup, error) {
+	var rollups []*models.StatsRollup
+	if err := s.db.Where("period = ? AND start >= ? AND start < ?", period, from, to).
+		Order("start ASC").Find(&rollups).Error; err != nil {
+		return nil, err
+	}
+	return rollups, nil
+}
+
+func (s *PostgresStore) DeleteStatsRollupsBefore(period string, before time.Time) error {
+	return s.db.Where("period = ? AND start < ?", period, before).Delete(&models.StatsRollup{}).Error
+}
+
+func (s *PostgresStore) EarliestBootLog() (time.Time, error) {
+	var first models.BootLog
+	if err := s.db.Order("created_at ASC").First(&first).Error; err != nil {
+		return time.Time{}, err
+	}
+	return first.CreatedAt, nil
+}
+
+func (s *PostgresStore) FindOrphans() ([]Orphans, error) {
+	return findOrphans(s.db)
+}
+
+func (s *PostgresStore) FixOrphans(kinds []string) (map[string]int64, error) {
+	return fixOrphans(s.db, kinds)
+}
+
+func (s *PostgresStore) RewriteSecrets() (int, error) {
+	return rewriteSecrets(s.db)
+}
+
 func (s *PostgresStore) ListBootTools() ([]*models.BootTool, error) {
 	var tools []*models.BootTool
 	if err := s.db.Order("\"order\" ASC, name ASC").Find(&tools).Error; err != nil {
@@ -229,16 +321,72 @@ func (s *PostgresStore) CreateClient(client *models.Client) error {
 }
 
 func (s *PostgresStore) UpdateClient(mac string, client *models.Client) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Client{}).Where("mac_address = ?", mac).
+			Select(clientUpdateFields).Updates(client).Error; err != nil {
+			return err
+		}
+		return bumpVersion(tx, &models.Client{}, "mac_address = ?", mac, &client.Version)
+	})
+}
+
+func (s *PostgresStore) UpdateClientVersion(mac string, client *models.Client, version int) error {
+	client.Version = version + 1
+	res := s.db.Model(&models.Client{}).Where("mac_address = ? AND version = ?", mac, version).
+		Select(append(clientUpdateFields, "Version")).Updates(client)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		client.Version = version
+		return versionConflict(s.db, &models.Client{}, "mac_address = ?", mac)
+	}
+	return nil
+}
+
+func (s *PostgresStore) UpdateClientAttestation(mac string, client *models.Client) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Select(clientAttestFields).Updates(client).Error
+}
+
+func (s *PostgresStore) UpdateClientSwitchState(mac string, client *models.Client) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Select(clientSwitchFields).Updates(client).Error
+}
+
+func (s *PostgresStore) UpdateClientBootloader(mac string, client *models.Client) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Select(clientBootloaderFields).Updates(client).Error
+}
+
+func (s *PostgresStore) UpdateClientPlatform(mac string, client *models.Client) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Select(clientPlatformFields).Updates(client).Error
+}
+
+func (s *PostgresStore) UpdateClientRegistration(mac string, client *models.Client) error {
 	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
-		Select("Name", "Description", "Enabled", "ShowPublicImages", "BootloaderSet", "Static", "ClientGroupID",
-			"IPMIHost", "IPMIPort", "IPMIUsername", "IPMIPassword", "IPMIInsecure", "UpdatedAt").
-		Updates(client).Error
+		Select(clientRegistrationFields).Updates(client).Error
 }
 
 func (s *PostgresStore) DeleteClient(mac string) error {
 	return s.db.Where("mac_address = ?", mac).Delete(&models.Client{}).Error
 }
 
+// UndeleteClient brings back a deleted client, keeping its ID so image
+// assignments and boot logs still point at it.
+func (s *PostgresStore) UndeleteClient(mac string) error {
+	res := s.db.Unscoped().Model(&models.Client{}).Where("mac_address = ? AND deleted_at IS NOT NULL", mac).
+		Update("deleted_at", nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 func (s *PostgresStore) ListImages() ([]*models.Image, error) {
 	var images []*models.Image
 	if err := s.db.Preload("Group").Find(&images).Error; err != nil {
@@ -260,21 +408,67 @@ func (s *PostgresStore) CreateImage(image *models.Image) error {
 }
 
 func (s *PostgresStore) UpdateImage(filename string, image *models.Image) error {
-	return s.db.Model(&models.Image{}).Where("filename = ?", filename).Save(image).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Image{}).Where("filename = ?", filename).Omit("Version").Save(image).Error; err != nil {
+			return err
+		}
+		if err := syncImageVariants(tx, image); err != nil {
+			return err
+		}
+		return bumpVersion(tx, &models.Image{}, "id = ?", image.ID, &image.Version)
+	})
 }
 
-func (s *PostgresStore) DeleteImage(filename string) error {
-	var image models.Image
-	if err := s.db.Where("filename = ?", filename).First(&image).Error; err != nil {
-		return err
+func (s *PostgresStore) UpdateImageVersion(filename string, image *models.Image, version int) error {
+	image.Version = version + 1
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Image{}).Where("filename = ? AND version = ?", filename, version).
+			Select("*").Omit("ID", "CreatedAt", "DeletedAt", "Group").Updates(image)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			image.Version = version
+			return versionConflict(tx, &models.Image{}, "filename = ?", filename)
+		}
+		return syncImageVariants(tx, image)
+	})
+}
+
+func (s *PostgresStore) ListImageVariants(filename string) ([]*models.Image, error) {
+	var images []*models.Image
+	if err := s.db.Where("clone_of = ?", filename).Order("name ASC").Find(&images).Error; err != nil {
+		return nil, err
 	}
-	s.db.Exec("DELETE FROM client_images WHERE image_id = ?", image.ID)
-	s.db.Unscoped().Where("image_id = ?", image.ID).Delete(&models.CustomFile{})
-	s.db.Unscoped().Where("image_id = ?", image.ID).Delete(&models.BootLog{})
-	return s.db.Unscoped().Delete(&image).Error
+	return images, nil
+}
+
+func (s *PostgresStore) DeleteImage(filename string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var image models.Image
+		if err := tx.Where("filename = ?", filename).First(&image).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM client_images WHERE image_id = ?", image.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("image_id = ?", image.ID).Delete(&models.CustomFile{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("image_id = ?", image.ID).Delete(&models.BootLog{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&image).Error
+	})
 }
 
 func (s *PostgresStore) SyncImages(isoFiles []models.SyncFile) error {
+	return s.Transaction(func(tx Storage) error {
+		return tx.(*PostgresStore).syncImages(isoFiles)
+	})
+}
+
+func (s *PostgresStore) syncImages(isoFiles []models.SyncFile) error {
 	groupCache := make(map[string]*uint)
 
 	for _, iso := range isoFiles {
@@ -386,12 +580,22 @@ func (s *PostgresStore) AssignImagesToClient(mac string, imageFilenames []string
 
 func (s *PostgresStore) SetNextBootImage(mac string, imageFilename string) error {
 	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
-		Update("next_boot_image", imageFilename).Error
+		Updates(map[string]interface{}{"next_boot_image": imageFilename, "next_boot_once": false}).Error
+}
+
+func (s *PostgresStore) SetOneShotBoot(mac string, imageFilename string) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Updates(map[string]interface{}{"next_boot_image": imageFilename, "next_boot_once": true, "local_boot": false}).Error
 }
 
 func (s *PostgresStore) ClearNextBootImage(mac string) error {
 	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
-		Update("next_boot_image", "").Error
+		Updates(map[string]interface{}{"next_boot_image": "", "next_boot_once": false}).Error
+}
+
+func (s *PostgresStore) SetClientLocalBoot(mac string, local bool) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Update("local_boot", local).Error
 }
 
 func (s *PostgresStore) GetClientImages(mac string) ([]string, error) {
@@ -448,6 +652,7 @@ func (s *PostgresStore) GetImagesForClient(macAddress string) ([]models.Image, e
 			}
 		}
 
+		assigned = models.VisibleImages(assigned, time.Now())
 		if len(assigned) > 0 {
 			return assigned, nil
 		}
@@ -461,7 +666,7 @@ func (s *PostgresStore) GetImagesForClient(macAddress string) ([]models.Image, e
 	if err := s.db.Where("enabled = ? AND public = ?", true, true).Find(&images).Error; err != nil {
 		return nil, err
 	}
-	return images, nil
+	return models.VisibleImages(images, time.Now()), nil
 }
 
 func (s *PostgresStore) EnsureAdminUser() (username, password string, created bool, err error) {
@@ -722,6 +927,7 @@ func (s *PostgresStore) LogBootAttempt(macAddress, imageName, ipAddress string,
 	var image models.Image
 	if err := s.db.Where("name = ?", imageName).First(&image).Error; err == nil {
 		bootLog.ImageID = &image.ID
+		bootLog.BootMethod = image.BootMethod
 	}
 
 	return s.db.Create(&bootLog).Error
@@ -769,6 +975,25 @@ func (s *PostgresStore) GetBootLogsByMAC(macAddress string, limit int) ([]models
 	}
 	return logs, nil
 }
+func (s *PostgresStore) CompleteBootLog(macAddress, hostname, installedOS string) (*models.BootLog, error) {
+	var bootLog models.BootLog
+	if err := s.db.Where("mac_address = ? AND success = ? AND completed_at IS NULL", macAddress, true).
+		Order("created_at DESC").First(&bootLog).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	bootLog.CompletedAt = &now
+	bootLog.InstalledHostname = hostname
+	bootLog.InstalledOS = installedOS
+	if err := s.db.Model(&bootLog).Updates(map[string]interface{}{
+		"completed_at":       now,
+		"installed_hostname": hostname,
+		"installed_os":       installedOS,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &bootLog, nil
+}
 
 func (s *PostgresStore) SaveHardwareInventory(inv *models.HardwareInventory) error {
 	if inv.MACAddress != "" {
@@ -859,6 +1084,27 @@ func (s *PostgresStore) UpdateMenuTheme(theme *models.MenuTheme) error {
 	return s.db.Save(theme).Error
 }
 
+func (s *PostgresStore) GetMenuSnapshot() (*models.MenuSnapshot, error) {
+	var snap models.MenuSnapshot
+	if err := s.db.First(&snap, 1).Error; err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+func (s *PostgresStore) CreateMenuSnapshot(snap *models.MenuSnapshot) error {
+	snap.ID = 1
+	return s.db.Create(snap).Error
+}
+
+func (s *PostgresStore) DeleteMenuSnapshot() error {
+	return s.db.Delete(&models.MenuSnapshot{}, 1).Error
+}
+
+func (s *PostgresStore) RestoreMenuSnapshot(snap *models.MenuSnapshot) error {
+	return restoreMenuSnapshot(s.db, snap)
+}
+
 func (s *PostgresStore) ListScheduledTasks() ([]*models.ScheduledTask, error) {
 	var tasks []*models.ScheduledTask
 	if err := s.db.Preload("ClientGroup").Order("name ASC").Find(&tasks).Error; err != nil {
@@ -910,7 +1156,7 @@ func (s *PostgresStore) RecordScheduledTaskRun(id uint, status, errorMsg string)
 func (s *PostgresStore) GetWebhookConfig() (*models.WebhookConfig, error) {
 	var cfg models.WebhookConfig
 	if err := s.db.First(&cfg, 1).Error; err != nil {
-		return &models.WebhookConfig{ID: 1, OnBootStarted: true, OnClientDiscovered: true}, nil
+		return &models.WebhookConfig{ID: 1, OnBootStarted: true, OnClientDiscovered: true, OnAlert: true}, nil
 	}
 	return &cfg, nil
 }
@@ -920,6 +1166,19 @@ func (s *PostgresStore) UpdateWebhookConfig(cfg *models.WebhookConfig) error {
 	return s.db.Save(cfg).Error
 }
 
+func (s *PostgresStore) GetAccessConfig() (*models.AccessConfig, error) {
+	var cfg models.AccessConfig
+	if err := s.db.First(&cfg, 1).Error; err != nil {
+		return &models.AccessConfig{ID: 1, DefaultShell: "/bin/bash", DefaultSudo: true}, nil
+	}
+	return &cfg, nil
+}
+
+func (s *PostgresStore) UpdateAccessConfig(cfg *models.AccessConfig) error {
+	cfg.ID = 1
+	return s.db.Save(cfg).Error
+}
+
 func (s *PostgresStore) ListClientGroups() ([]*models.ClientGroup, error) {
 	var groups []*models.ClientGroup
 	if err := s.db.Order("name ASC").Find(&groups).Error; err != nil {
@@ -989,3 +1248,208 @@ func (s *PostgresStore) SetClientGroup(mac string, groupID *uint) error {
 	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
 		Update("client_group_id", groupID).Error
 }
+
+func (s *PostgresStore) ListDiskImages() ([]*models.DiskImage, error) {
+	var images []*models.DiskImage
+	if err := s.db.Order("created_at DESC").Find(&images).Error; err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (s *PostgresStore) GetDiskImage(id uint) (*models.DiskImage, error) {
+	var img models.DiskImage
+	if err := s.db.First(&img, id).Error; err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
+func (s *PostgresStore) CreateDiskImage(img *models.DiskImage) error {
+	return s.db.Create(img).Error
+}
+
+func (s *PostgresStore) UpdateDiskImage(img *models.DiskImage) error {
+	return s.db.Save(img).Error
+}
+
+func (s *PostgresStore) DeleteDiskImage(id uint) error {
+	return s.db.Delete(&models.DiskImage{}, id).Error
+}
+
+func (s *PostgresStore) ListDiskTasks(limit int) ([]*models.DiskTask, error) {
+	var tasks []*models.DiskTask
+	if err := s.db.Preload("DiskImage").Order("created_at DESC").Limit(limit).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (s *PostgresStore) GetDiskTask(id uint) (*models.DiskTask, error) {
+	var task models.DiskTask
+	if err := s.db.Preload("DiskImage").First(&task, id).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (s *PostgresStore) GetPendingDiskTask(mac string) (*models.DiskTask, error) {
+	var task models.DiskTask
+	if err := s.db.Preload("DiskImage").Where("mac_address = ? AND status = ?", mac, "pending").
+		Order("created_at ASC").First(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (s *PostgresStore) CreateDiskTask(task *models.DiskTask) error {
+	return s.db.Create(task).Error
+}
+
+func (s *PostgresStore) UpdateDiskTask(task *models.DiskTask) error {
+	return s.db.Omit("DiskImage").Save(task).Error
+}
+
+func (s *PostgresStore) DeleteDiskTask(id uint) error {
+	return s.db.Delete(&models.DiskTask{}, id).Error
+}
+
+func (s *PostgresStore) ListBootParamOverrides(mac string) ([]*models.BootParamOverride, error) {
+	var overrides []*models.BootParamOverride
+	q := s.db.Order("mac_address, image_filename, once")
+	if mac != "" {
+		q = q.Where("mac_address = ?", mac)
+	}
+	err := q.Find(&overrides).Error
+	return overrides, err
+}
+
+func (s *PostgresStore) SaveBootParamOverride(o *models.BootParamOverride) error {
+	var existing models.BootParamOverride
+	err := s.db.Where("mac_address = ? AND image_filename = ? AND once = ?", o.MACAddress, o.ImageFilename, o.Once).First(&existing).Error
+	if err == nil {
+		o.ID = existing.ID
+		o.CreatedAt = existing.CreatedAt
+	}
+	return s.db.Save(o).Error
+}
+
+func (s *PostgresStore) DeleteBootParamOverride(id uint) error {
+	return s.db.Delete(&models.BootParamOverride{}, id).Error
+}
+
+func (s *PostgresStore) ClearOnceBootParamOverrides(mac string) error {
+	return s.db.Where("mac_address = ? AND once = ?", mac, true).Delete(&models.BootParamOverride{}).Error
+}
+
+func (s *PostgresStore) ListRemoteMenus() ([]*models.RemoteMenu, error) {
+	var menus []*models.RemoteMenu
+	err := s.db.Order("\"order\" ASC, name ASC").Find(&menus).Error
+	return menus, err
+}
+
+func (s *PostgresStore) GetRemoteMenu(id uint) (*models.RemoteMenu, error) {
+	var m models.RemoteMenu
+	if err := s.db.First(&m, id).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (s *PostgresStore) SaveRemoteMenu(m *models.RemoteMenu) error {
+	return s.db.Save(m).Error
+}
+
+func (s *PostgresStore) DeleteRemoteMenu(id uint) error {
+	return s.db.Delete(&models.RemoteMenu{}, id).Error
+}
+
+func (s *PostgresStore) ListDHCPLeases() ([]*models.DHCPLease, error) {
+	var leases []*models.DHCPLease
+	err := s.db.Order("ip_address").Find(&leases).Error
+	return leases, err
+}
+
+func (s *PostgresStore) SaveDHCPLease(l *models.DHCPLease) error {
+	var existing models.DHCPLease
+	if err := s.db.Where("mac_address = ?", l.MACAddress).First(&existing).Error; err == nil {
+		l.ID = existing.ID
+		l.CreatedAt = existing.CreatedAt
+	}
+	return s.db.Save(l).Error
+}
+
+func (s *PostgresStore) DeleteDHCPLease(mac string) error {
+	return s.db.Where("mac_address = ?", mac).Delete(&models.DHCPLease{}).Error
+}
+
+func (s *PostgresStore) ListExtractionStates() ([]*models.ExtractionState, error) {
+	var states []*models.ExtractionState
+	err := s.db.Order("created_at").Find(&states).Error
+	return states, err
+}
+
+func (s *PostgresStore) SaveExtractionState(st *models.ExtractionState) error {
+	var existing models.ExtractionState
+	if err := s.db.Where("filename = ?", st.Filename).First(&existing).Error; err == nil {
+		st.ID = existing.ID
+		st.CreatedAt = existing.CreatedAt
+	}
+	return s.db.Save(st).Error
+}
+
+func (s *PostgresStore) DeleteExtractionState(filename string) error {
+	return s.db.Where("filename = ?", filename).Delete(&models.ExtractionState{}).Error
+}
+
+func (s *PostgresStore) ListConsoleCaptures(mac string, limit int) ([]*models.ConsoleCapture, error) {
+	var captures []*models.ConsoleCapture
+	q := s.db.Order("updated_at DESC").Limit(limit)
+	if mac != "" {
+		q = q.Where("mac_address = ?", mac)
+	}
+	err := q.Find(&captures).Error
+	return captures, err
+}
+
+func (s *PostgresStore) GetConsoleCapture(id uint) (*models.ConsoleCapture, error) {
+	var c models.ConsoleCapture
+	if err := s.db.First(&c, id).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *PostgresStore) FindSerialCapture(mac string, bootLogID *uint, source string) (*models.ConsoleCapture, error) {
+	var c models.ConsoleCapture
+	q := s.db.Where("mac_address = ? AND kind = ? AND source = ?", mac, "serial", source)
+	if bootLogID != nil {
+		q = q.Where("boot_log_id = ?", *bootLogID)
+	} else {
+		q = q.Where("boot_log_id IS NULL")
+	}
+	if err := q.Order("id DESC").First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *PostgresStore) SaveConsoleCapture(c *models.ConsoleCapture) error {
+	return s.db.Save(c).Error
+}
+
+func (s *PostgresStore) DeleteConsoleCapture(id uint) error {
+	return s.db.Delete(&models.ConsoleCapture{}, id).Error
+}
+
+// Transaction runs fn against a store bound to a single database
+// transaction; returning an error rolls every step back.
+func (s *PostgresStore) WithContext(ctx context.Context) Storage {
+	return &PostgresStore{db: s.db.WithContext(ctx), cfg: s.cfg}
+}
+
+func (s *PostgresStore) Transaction(fn func(tx Storage) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&PostgresStore{db: tx, cfg: s.cfg})
+	})
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"database/sql"
+
+	"bootimus/internal/models"
+	"bootimus/internal/secrets"
+
+	"gorm.io/gorm"
+)
+
+// secretFields are the models.Secret fields, by table model.
+var secretFields = []struct {
+	model interface{}
+	field string
+}{
+	{&models.Client{}, "IPMIPassword"},
+	{&models.ClientGroup{}, "IPMIPassword"},
+	{&models.Image{}, "OCIPassword"},
+	{&models.Image{}, "AutoInstallSecrets"},
+	{&models.AccessConfig{}, "DefaultPasswordHash"},
+	{&models.WebhookConfig{}, "URL"},
+	{&models.DiskTask{}, "Token"},
+	{&models.Revision{}, "Data"},
+}
+
+// rewriteSecrets re-encrypts every secret column with the current default
+// keyring, soft-deleted rows included. Plaintext left from before a key was
+// configured gets encrypted, and values under an old key move to the
+// primary one.
+func rewriteSecrets(db *gorm.DB) (int, error) {
+	rewritten := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, sf := range secretFields {
+			stmt := &gorm.Statement{DB: tx}
+			if err := stmt.Parse(sf.model); err != nil {
+				return err
+			}
+			column := stmt.Schema.LookUpField(sf.field).DBName
+			type row struct {
+				ID    uint
+				Value sql.NullString
+			}
+			var rows []row
+			if err := tx.Unscoped().Model(sf.model).Select("id, " + column + " AS value").Scan(&rows).Error; err != nil {
+				return err
+			}
+			for _, r := range rows {
+				if r.Value.String == "" {
+					continue
+				}
+				plain, err := secrets.Decrypt(r.Value.String)
+				if err != nil {
+					return err
+				}
+				enc, err := secrets.Encrypt(plain)
+				if err != nil {
+					return err
+				}
+				if enc == r.Value.String {
+					continue
+				}
+				if err := tx.Unscoped().Model(sf.model).Where("id = ?", r.ID).UpdateColumn(column, enc).Error; err != nil {
+					return err
+				}
+				rewritten++
+			}
+		}
+		return nil
+	})
+	return rewritten, err
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -34,7 +35,7 @@ func NewSQLiteStore(dataDir string) (*SQLiteStore, error) {
 }
 
 func (s *SQLiteStore) AutoMigrate() error {
-	if err := s.db.AutoMigrate(&models.User{}, &models.ClientGroup{}, &models.Client{}, &models.ImageGroup{}, &models.Image{}, &models.BootLog{}, &models.CustomFile{}, &models.DriverPack{}, &models.MenuTheme{}, &models.BootTool{}, &models.HardwareInventory{}, &models.DistroProfile{}, &models.WebhookConfig{}, &models.ScheduledTask{}); err != nil {
+	if err := s.db.AutoMigrate(&models.User{}, &models.ClientGroup{}, &models.Client{}, &models.ImageGroup{}, &models.Image{}, &models.BootLog{}, &models.CustomFile{}, &models.DriverPack{}, &models.MenuTheme{}, &models.BootTool{}, &models.HardwareInventory{}, &models.DistroProfile{}, &models.WebhookConfig{}, &models.ScheduledTask{}, &models.AccessConfig{}, &models.DiskImage{}, &models.DiskTask{}, &models.BootParamOverride{}, &models.DHCPLease{}, &models.ExtractionState{}, &models.ConsoleCapture{}, &models.MenuSnapshot{}, &models.Revision{}, &models.StatsRollup{}, &models.RemoteMenu{}, &models.Job{}); err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
@@ -76,17 +77,89 @@ func (s *SQLiteStore) CreateClient(client *models.Client) error {
 	return s.db.Create(client).Error
 }
 
+var clientUpdateFields = []string{"Name", "Description", "Tags", "Enabled", "ShowPublicImages", "BootloaderSet", "LiteInitrd", "Static", "ClientGroupID",
+	"DefaultImageID", "BootImmediately", "LocalBoot", "IPMIHost", "IPMIPort", "IPMIUsername", "IPMIPassword", "IPMIInsecure", "SwitchName", "SwitchPort", "ReservedIP", "AutoInstallVars", "UpdatedAt"}
+
+// clientAttestFields are written only by the attestation flow, never by
+// regular client edits.
+var clientAttestFields = []string{"Trusted", "AttestStatus", "AttestApproved", "AttestEKHash", "AttestAKHash",
+	"AttestPCRs", "AttestError", "AttestedAt", "AttestFailures", "AttestLastFailure", "AttestFailedAt"}
+
+var clientSwitchFields = []string{"SwitchState", "SwitchVLAN", "SwitchError"}
+
+var clientBootloaderFields = []string{"LastBootloader", "LastBootloaderVia", "LastBootloaderAt"}
+
+var clientPlatformFields = []string{"Firmware", "SecureBoot", "PlatformAt"}
+
+var clientRegistrationFields = []string{"InstalledHostname", "InstalledIPs", "InstalledOS", "DiskSerials", "RegisteredAt"}
+
 func (s *SQLiteStore) UpdateClient(mac string, client *models.Client) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Client{}).Where("mac_address = ?", mac).
+			Select(clientUpdateFields).Updates(client).Error; err != nil {
+			return err
+		}
+		return bumpVersion(tx, &models.Client{}, "mac_address = ?", mac, &client.Version)
+	})
+}
+
+func (s *SQLiteStore) UpdateClientVersion(mac string, client *models.Client, version int) error {
+	client.Version = version + 1
+	res := s.db.Model(&models.Client{}).Where("mac_address = ? AND version = ?", mac, version).
+		Select(append(clientUpdateFields, "Version")).Updates(client)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		client.Version = version
+		return versionConflict(s.db, &models.Client{}, "mac_address = ?", mac)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) UpdateClientAttestation(mac string, client *models.Client) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Select(clientAttestFields).Updates(client).Error
+}
+
+func (s *SQLiteStore) UpdateClientSwitchState(mac string, client *models.Client) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Select(clientSwitchFields).Updates(client).Error
+}
+
+func (s *SQLiteStore) UpdateClientBootloader(mac string, client *models.Client) error {
 	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
-		Select("Name", "Description", "Enabled", "ShowPublicImages", "BootloaderSet", "Static", "ClientGroupID",
-			"IPMIHost", "IPMIPort", "IPMIUsername", "IPMIPassword", "IPMIInsecure", "UpdatedAt").
-		Updates(client).Error
+		Select(clientBootloaderFields).Updates(client).Error
+}
+
+func (s *SQLiteStore) UpdateClientPlatform(mac string, client *models.Client) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Select(clientPlatformFields).Updates(client).Error
+}
+
+func (s *SQLiteStore) UpdateClientRegistration(mac string, client *models.Client) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Select(clientRegistrationFields).Updates(client).Error
 }
 
 func (s *SQLiteStore) DeleteClient(mac string) error {
 	return s.db.Where("mac_address = ?", mac).Delete(&models.Client{}).Error
 }
 
+// UndeleteClient brings back a deleted client, keeping its ID so image
+// assignments and boot logs still point at it.
+func (s *SQLiteStore) UndeleteClient(mac string) error {
+	res := s.db.Unscoped().Model(&models.Client{}).Where("mac_address = ? AND deleted_at IS NOT NULL", mac).
+		Update("deleted_at", nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 func (s *SQLiteStore) ListImages() ([]*models.Image, error) {
 	var images []*models.Image
 	if err := s.db.Preload("Group").Find(&images).Error; err != nil {
@@ -108,18 +181,58 @@ func (s *SQLiteStore) CreateImage(image *models.Image) error {
 }
 
 func (s *SQLiteStore) UpdateImage(filename string, image *models.Image) error {
-	return s.db.Model(&models.Image{}).Where("filename = ?", filename).Save(image).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Image{}).Where("filename = ?", filename).Omit("Version").Save(image).Error; err != nil {
+			return err
+		}
+		if err := syncImageVariants(tx, image); err != nil {
+			return err
+		}
+		return bumpVersion(tx, &models.Image{}, "id = ?", image.ID, &image.Version)
+	})
 }
 
-func (s *SQLiteStore) DeleteImage(filename string) error {
-	var image models.Image
-	if err := s.db.Where("filename = ?", filename).First(&image).Error; err != nil {
-		return err
+func (s *SQLiteStore) UpdateImageVersion(filename string, image *models.Image, version int) error {
+	image.Version = version + 1
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Image{}).Where("filename = ? AND version = ?", filename, version).
+			Select("*").Omit("ID", "CreatedAt", "DeletedAt", "Group").Updates(image)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			image.Version = version
+			return versionConflict(tx, &models.Image{}, "filename = ?", filename)
+		}
+		return syncImageVariants(tx, image)
+	})
+}
+
+func (s *SQLiteStore) ListImageVariants(filename string) ([]*models.Image, error) {
+	var images []*models.Image
+	if err := s.db.Where("clone_of = ?", filename).Order("name ASC").Find(&images).Error; err != nil {
+		return nil, err
 	}
-	s.db.Exec("DELETE FROM client_images WHERE image_id = ?", image.ID)
-	s.db.Unscoped().Where("image_id = ?", image.ID).Delete(&models.CustomFile{})
-	s.db.Unscoped().Where("image_id = ?", image.ID).Delete(&models.BootLog{})
-	return s.db.Unscoped().Delete(&image).Error
+	return images, nil
+}
+
+func (s *SQLiteStore) DeleteImage(filename string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var image models.Image
+		if err := tx.Where("filename = ?", filename).First(&image).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM client_images WHERE image_id = ?", image.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("image_id = ?", image.ID).Delete(&models.CustomFile{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("image_id = ?", image.ID).Delete(&models.BootLog{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&image).Error
+	})
 }
 
 func (s *SQLiteStore) AssignImagesToClient(mac string, imageFilenames []string) error {
@@ -136,12 +249,22 @@ func (s *SQLiteStore) AssignImagesToClient(mac string, imageFilenames []string)
 
 func (s *SQLiteStore) SetNextBootImage(mac string, imageFilename string) error {
 	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
-		Update("next_boot_image", imageFilename).Error
+		Updates(map[string]interface{}{"next_boot_image": imageFilename, "next_boot_once": false}).Error
+}
+
+func (s *SQLiteStore) SetOneShotBoot(mac string, imageFilename string) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Updates(map[string]interface{}{"next_boot_image": imageFilename, "next_boot_once": true, "local_boot": false}).Error
 }
 
 func (s *SQLiteStore) ClearNextBootImage(mac string) error {
 	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
-		Update("next_boot_image", "").Error
+		Updates(map[string]interface{}{"next_boot_image": "", "next_boot_once": false}).Error
+}
+
+func (s *SQLiteStore) SetClientLocalBoot(mac string, local bool) error {
+	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
+		Update("local_boot", local).Error
 }
 
 func (s *SQLiteStore) GetClientImages(mac string) ([]string, error) {
@@ -242,6 +365,25 @@ func (s *SQLiteStore) GetBootLogsByMAC(macAddress string, limit int) ([]models.B
 	}
 	return logs, nil
 }
+func (s *SQLiteStore) CompleteBootLog(macAddress, hostname, installedOS string) (*models.BootLog, error) {
+	var bootLog models.BootLog
+	if err := s.db.Where("mac_address = ? AND success = ? AND completed_at IS NULL", macAddress, true).
+		Order("created_at DESC").First(&bootLog).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	bootLog.CompletedAt = &now
+	bootLog.InstalledHostname = hostname
+	bootLog.InstalledOS = installedOS
+	if err := s.db.Model(&bootLog).Updates(map[string]interface{}{
+		"completed_at":       now,
+		"installed_hostname": hostname,
+		"installed_os":       installedOS,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &bootLog, nil
+}
 
 func (s *SQLiteStore) EnsureAdminUser() (username, password string, created bool, err error) {
 	var admin models.User
@@ -533,6 +675,7 @@ func (s *SQLiteStore) GetImagesForClient(macAddress string) ([]models.Image, err
 			}
 		}
 
+		assigned = models.VisibleImages(assigned, time.Now())
 		if len(assigned) > 0 {
 			return assigned, nil
 		}
@@ -546,7 +689,7 @@ func (s *SQLiteStore) GetImagesForClient(macAddress string) ([]models.Image, err
 	if err := s.db.Where("enabled = ? AND public = ?", true, true).Find(&images).Error; err != nil {
 		return nil, err
 	}
-	return images, nil
+	return models.VisibleImages(images, time.Now()), nil
 }
 
 func (s *SQLiteStore) LogBootAttempt(macAddress, imageName, ipAddress string, success bool, errorMsg string) error {
@@ -566,6 +709,7 @@ func (s *SQLiteStore) LogBootAttempt(macAddress, imageName, ipAddress string, su
 	var image models.Image
 	if err := s.db.Where("name = ?", imageName).First(&image).Error; err == nil {
 		bootLog.ImageID = &image.ID
+		bootLog.BootMethod = image.BootMethod
 	}
 
 	return s.db.Create(&bootLog).Error
@@ -590,6 +734,12 @@ func (s *SQLiteStore) UpdateImageBootStats(imageName string) error {
 }
 
 func (s *SQLiteStore) SyncImages(isoFiles []models.SyncFile) error {
+	return s.Transaction(func(tx Storage) error {
+		return tx.(*SQLiteStore).syncImages(isoFiles)
+	})
+}
+
+func (s *SQLiteStore) syncImages(isoFiles []models.SyncFile) error {
 	groupCache := make(map[string]*uint) // groupPath -> groupID
 
 	for _, iso := range isoFiles {
@@ -708,6 +858,106 @@ func (s *SQLiteStore) UpdateMenuTheme(theme *models.MenuTheme) error {
 	return s.db.Save(theme).Error
 }
 
+func (s *SQLiteStore) GetMenuSnapshot() (*models.MenuSnapshot, error) {
+	var snap models.MenuSnapshot
+	if err := s.db.First(&snap, 1).Error; err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+func (s *SQLiteStore) CreateMenuSnapshot(snap *models.MenuSnapshot) error {
+	snap.ID = 1
+	return s.db.Create(snap).Error
+}
+
+func (s *SQLiteStore) DeleteMenuSnapshot() error {
+	return s.db.Delete(&models.MenuSnapshot{}, 1).Error
+}
+
+func (s *SQLiteStore) RestoreMenuSnapshot(snap *models.MenuSnapshot) error {
+	return restoreMenuSnapshot(s.db, snap)
+}
+
+func (s *SQLiteStore) CreateRevision(rev *models.Revision) error {
+	return createRevision(s.db, rev)
+}
+
+func (s *SQLiteStore) ListRevisions(entityType, key string, limit int) ([]*models.Revision, error) {
+	return listRevisions(s.db, entityType, key, limit)
+}
+
+func (s *SQLiteStore) DeleteRevision(id uint) error {
+	return s.db.Delete(&models.Revision{}, id).Error
+}
+
+func (s *SQLiteStore) SummarizeBootLogs(from, to time.Time) (*models.StatsRollup, error) {
+	return summarizeBootLogs(s.db, from, to)
+}
+
+func (s *SQLiteStore) BootMethodStats(since time.Time) ([]models.BootMethodStat, error) {
+	return bootMethodStats(s.db, since)
+}
+
+func (s *SQLiteStore) BootDistroStats(from, to time.Time) ([]models.BootDistroStat, error) {
+	return bootDistroStats(s.db, from, to)
+}
+
+func (s *SQLiteStore) SaveJob(job *models.Job) error {
+	return s.db.Save(job).Error
+}
+
+func (s *SQLiteStore) ListJobs(limit int) ([]*models.Job, error) {
+	var jobs []*models.Job
+	err := s.db.Order("id DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+func (s *SQLiteStore) DeleteJobsBefore(id uint64) error {
+	return s.db.Where("id < ?", id).Delete(&models.Job{}).Error
+}
+
+func (s *SQLiteStore) SaveStatsRollup(rollup *models.StatsRollup) error {
+	return saveStatsRollup(s.db, rollup)
+}
+
+func (s *SQLiteStore) AddStatsBytes(period string, start time.Time, n int64) error {
+	return addStatsBytes(s.db, period, start, n)
+}
+
+func (s *SQLiteStore) ListStatsRollups(period string, from, to time.Time) ([]*models.StatsRollup, error) {
+	var rollups []*models.StatsRollup
+	if err := s.db.Where("period = ? AND start >= ? AND start < ?", period, from, to).
+		Order("start ASC").Find(&rollups).Error; err != nil {
+		return nil, err
+	}
+	return rollups, nil
+}
+
+func (s *SQLiteStore) DeleteStatsRollupsBefore(period string, before time.Time) error {
+	return s.db.Where("period = ? AND start < ?", period, before).Delete(&models.StatsRollup{}).Error
+}
+
+func (s *SQLiteStore) EarliestBootLog() (time.Time, error) {
+	var first models.BootLog
+	if err := s.db.Order("created_at ASC").First(&first).Error; err != nil {
+		return time.Time{}, err
+	}
+	return first.CreatedAt, nil
+}
+
+func (s *SQLiteStore) FindOrphans() ([]Orphans, error) {
+	return findOrphans(s.db)
+}
+
+func (s *SQLiteStore) FixOrphans(kinds []string) (map[string]int64, error) {
+	return fixOrphans(s.db, kinds)
+}
+
+func (s *SQLiteStore) RewriteSecrets() (int, error) {
+	return rewriteSecrets(s.db)
+}
+
 func (s *SQLiteStore) ListBootTools() ([]*models.BootTool, error) {
 	var tools []*models.BootTool
 	if err := s.db.Order("`order` ASC, name ASC").Find(&tools).Error; err != nil {
@@ -842,7 +1092,7 @@ func (s *SQLiteStore) RecordScheduledTaskRun(id uint, status, errorMsg string) e
 func (s *SQLiteStore) GetWebhookConfig() (*models.WebhookConfig, error) {
 	var cfg models.WebhookConfig
 	if err := s.db.First(&cfg, 1).Error; err != nil {
-		return &models.WebhookConfig{ID: 1, OnBootStarted: true, OnClientDiscovered: true}, nil
+		return &models.WebhookConfig{ID: 1, OnBootStarted: true, OnClientDiscovered: true, OnAlert: true}, nil
 	}
 	return &cfg, nil
 }
@@ -852,6 +1102,19 @@ func (s *SQLiteStore) UpdateWebhookConfig(cfg *models.WebhookConfig) error {
 	return s.db.Save(cfg).Error
 }
 
+func (s *SQLiteStore) GetAccessConfig() (*models.AccessConfig, error) {
+	var cfg models.AccessConfig
+	if err := s.db.First(&cfg, 1).Error; err != nil {
+		return &models.AccessConfig{ID: 1, DefaultShell: "/bin/bash", DefaultSudo: true}, nil
+	}
+	return &cfg, nil
+}
+
+func (s *SQLiteStore) UpdateAccessConfig(cfg *models.AccessConfig) error {
+	cfg.ID = 1
+	return s.db.Save(cfg).Error
+}
+
 func (s *SQLiteStore) ListClientGroups() ([]*models.ClientGroup, error) {
 	var groups []*models.ClientGroup
 	if err := s.db.Order("name ASC").Find(&groups).Error; err != nil {
@@ -921,3 +1184,208 @@ func (s *SQLiteStore) SetClientGroup(mac string, groupID *uint) error {
 	return s.db.Model(&models.Client{}).Where("mac_address = ?", mac).
 		Update("client_group_id", groupID).Error
 }
+
+func (s *SQLiteStore) ListDiskImages() ([]*models.DiskImage, error) {
+	var images []*models.DiskImage
+	if err := s.db.Order("created_at DESC").Find(&images).Error; err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (s *SQLiteStore) GetDiskImage(id uint) (*models.DiskImage, error) {
+	var img models.DiskImage
+	if err := s.db.First(&img, id).Error; err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
+func (s *SQLiteStore) CreateDiskImage(img *models.DiskImage) error {
+	return s.db.Create(img).Error
+}
+
+func (s *SQLiteStore) UpdateDiskImage(img *models.DiskImage) error {
+	return s.db.Save(img).Error
+}
+
+func (s *SQLiteStore) DeleteDiskImage(id uint) error {
+	return s.db.Delete(&models.DiskImage{}, id).Error
+}
+
+func (s *SQLiteStore) ListDiskTasks(limit int) ([]*models.DiskTask, error) {
+	var tasks []*models.DiskTask
+	if err := s.db.Preload("DiskImage").Order("created_at DESC").Limit(limit).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (s *SQLiteStore) GetDiskTask(id uint) (*models.DiskTask, error) {
+	var task models.DiskTask
+	if err := s.db.Preload("DiskImage").First(&task, id).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (s *SQLiteStore) GetPendingDiskTask(mac string) (*models.DiskTask, error) {
+	var task models.DiskTask
+	if err := s.db.Preload("DiskImage").Where("mac_address = ? AND status = ?", mac, "pending").
+		Order("created_at ASC").First(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (s *SQLiteStore) CreateDiskTask(task *models.DiskTask) error {
+	return s.db.Create(task).Error
+}
+
+func (s *SQLiteStore) UpdateDiskTask(task *models.DiskTask) error {
+	return s.db.Omit("DiskImage").Save(task).Error
+}
+
+func (s *SQLiteStore) DeleteDiskTask(id uint) error {
+	return s.db.Delete(&models.DiskTask{}, id).Error
+}
+
+func (s *SQLiteStore) ListBootParamOverrides(mac string) ([]*models.BootParamOverride, error) {
+	var overrides []*models.BootParamOverride
+	q := s.db.Order("mac_address, image_filename, once")
+	if mac != "" {
+		q = q.Where("mac_address = ?", mac)
+	}
+	err := q.Find(&overrides).Error
+	return overrides, err
+}
+
+func (s *SQLiteStore) SaveBootParamOverride(o *models.BootParamOverride) error {
+	var existing models.BootParamOverride
+	err := s.db.Where("mac_address = ? AND image_filename = ? AND once = ?", o.MACAddress, o.ImageFilename, o.Once).First(&existing).Error
+	if err == nil {
+		o.ID = existing.ID
+		o.CreatedAt = existing.CreatedAt
+	}
+	return s.db.Save(o).Error
+}
+
+func (s *SQLiteStore) DeleteBootParamOverride(id uint) error {
+	return s.db.Delete(&models.BootParamOverride{}, id).Error
+}
+
+func (s *SQLiteStore) ClearOnceBootParamOverrides(mac string) error {
+	return s.db.Where("mac_address = ? AND once = ?", mac, true).Delete(&models.BootParamOverride{}).Error
+}
+
+func (s *SQLiteStore) ListRemoteMenus() ([]*models.RemoteMenu, error) {
+	var menus []*models.RemoteMenu
+	err := s.db.Order("`order` ASC, name ASC").Find(&menus).Error
+	return menus, err
+}
+
+func (s *SQLiteStore) GetRemoteMenu(id uint) (*models.RemoteMenu, error) {
+	var m models.RemoteMenu
+	if err := s.db.First(&m, id).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (s *SQLiteStore) SaveRemoteMenu(m *models.RemoteMenu) error {
+	return s.db.Save(m).Error
+}
+
+func (s *SQLiteStore) DeleteRemoteMenu(id uint) error {
+	return s.db.Delete(&models.RemoteMenu{}, id).Error
+}
+
+func (s *SQLiteStore) ListDHCPLeases() ([]*models.DHCPLease, error) {
+	var leases []*models.DHCPLease
+	err := s.db.Order("ip_address").Find(&leases).Error
+	return leases, err
+}
+
+func (s *SQLiteStore) SaveDHCPLease(l *models.DHCPLease) error {
+	var existing models.DHCPLease
+	if err := s.db.Where("mac_address = ?", l.MACAddress).First(&existing).Error; err == nil {
+		l.ID = existing.ID
+		l.CreatedAt = existing.CreatedAt
+	}
+	return s.db.Save(l).Error
+}
+
+func (s *SQLiteStore) DeleteDHCPLease(mac string) error {
+	return s.db.Where("mac_address = ?", mac).Delete(&models.DHCPLease{}).Error
+}
+
+func (s *SQLiteStore) ListExtractionStates() ([]*models.ExtractionState, error) {
+	var states []*models.ExtractionState
+	err := s.db.Order("created_at").Find(&states).Error
+	return states, err
+}
+
+func (s *SQLiteStore) SaveExtractionState(st *models.ExtractionState) error {
+	var existing models.ExtractionState
+	if err := s.db.Where("filename = ?", st.Filename).First(&existing).Error; err == nil {
+		st.ID = existing.ID
+		st.CreatedAt = existing.CreatedAt
+	}
+	return s.db.Save(st).Error
+}
+
+func (s *SQLiteStore) DeleteExtractionState(filename string) error {
+	return s.db.Where("filename = ?", filename).Delete(&models.ExtractionState{}).Error
+}
+
+func (s *SQLiteStore) ListConsoleCaptures(mac string, limit int) ([]*models.ConsoleCapture, error) {
+	var captures []*models.ConsoleCapture
+	q := s.db.Order("updated_at DESC").Limit(limit)
+	if mac != "" {
+		q = q.Where("mac_address = ?", mac)
+	}
+	err := q.Find(&captures).Error
+	return captures, err
+}
+
+func (s *SQLiteStore) GetConsoleCapture(id uint) (*models.ConsoleCapture, error) {
+	var c models.ConsoleCapture
+	if err := s.db.First(&c, id).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *SQLiteStore) FindSerialCapture(mac string, bootLogID *uint, source string) (*models.ConsoleCapture, error) {
+	var c models.ConsoleCapture
+	q := s.db.Where("mac_address = ? AND kind = ? AND source = ?", mac, "serial", source)
+	if bootLogID != nil {
+		q = q.Where("boot_log_id = ?", *bootLogID)
+	} else {
+		q = q.Where("boot_log_id IS NULL")
+	}
+	if err := q.Order("id DESC").First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *SQLiteStore) SaveConsoleCapture(c *models.ConsoleCapture) error {
+	return s.db.Save(c).Error
+}
+
+func (s *SQLiteStore) DeleteConsoleCapture(id uint) error {
+	return s.db.Delete(&models.ConsoleCapture{}, id).Error
+}
+
+// Transaction runs fn against a store bound to a single database
+// transaction; returning an error rolls every step back.
+func (s *SQLiteStore) WithContext(ctx context.Context) Storage {
+	return &SQLiteStore{db: s.db.WithContext(ctx)}
+}
+
+func (s *SQLiteStore) Transaction(fn func(tx Storage) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&SQLiteStore{db: tx})
+	})
+}
@@ -1,6 +1,14 @@
 package storage
 
-import "crypto/rand"
+import (
+	"crypto/rand"
+	"time"
+
+	"bootimus/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
 
 func generateRandomPassword(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*"
@@ -18,3 +26,205 @@ func randInt(max int) int {
 	}
 	return int(b[0]) % max
 }
+
+func bumpVersion(tx *gorm.DB, model interface{}, where string, key interface{}, version *int) error {
+	if err := tx.Model(model).Where(where, key).UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+		return err
+	}
+	var current int
+	if err := tx.Model(model).Where(where, key).Select("version").Scan(&current).Error; err != nil {
+		return err
+	}
+	*version = current
+	return nil
+}
+
+// versionConflict tells a failed versioned update apart from a missing row.
+func versionConflict(db *gorm.DB, model interface{}, where string, key interface{}) error {
+	var count int64
+	if err := db.Model(model).Where(where, key).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return ErrConflict
+}
+
+// variantDiskFields are the columns a variant shares with its source image
+// because they describe what gets booted rather than the menu entry.
+var variantDiskFields = []string{
+	"size", "extracted", "distro", "boot_method", "kernel_path", "initrd_path",
+	"squashfs_path", "extraction_error", "extracted_at", "sanboot_compatible",
+	"sanboot_hint", "netboot_required", "netboot_available", "netboot_url",
+	"netboot_checksum", "netboot_fetched_at",
+	"install_wim_path", "smb_install_enabled", "smb_patch_fingerprint",
+	"kernel_url", "initrd_url", "cache_remote", "sha256", "verified_at", "integrity_error",
+	"lite_initrd_size",
+}
+
+func syncImageVariants(tx *gorm.DB, image *models.Image) error {
+	if image.CloneOf != "" || image.Filename == "" {
+		return nil
+	}
+	return tx.Model(&models.Image{}).Where("clone_of = ?", image.Filename).
+		Select(variantDiskFields).Updates(image).Error
+}
+
+// restoreMenuSnapshot puts groups, image placement and the theme back the
+// way the snapshot recorded them and drops the snapshot, all or nothing.
+// Groups created since are removed; images added since keep their place
+// unless their group went with them.
+func restoreMenuSnapshot(db *gorm.DB, snap *models.MenuSnapshot) error {
+	groups, images, theme, err := snap.Layout()
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		ids := make([]uint, 0, len(groups))
+		for _, g := range groups {
+			ids = append(ids, g.ID)
+		}
+		removed := tx.Unscoped()
+		if len(ids) > 0 {
+			removed = removed.Where("id NOT IN ?", ids)
+		} else {
+			removed = removed.Where("1 = 1")
+		}
+		if err := removed.Delete(&models.ImageGroup{}).Error; err != nil {
+			return err
+		}
+		for _, g := range groups {
+			g.Parent = nil
+			if err := tx.Unscoped().Save(g).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, img := range images {
+			if err := tx.Model(&models.Image{}).Where("filename = ?", img.Filename).Updates(map[string]interface{}{
+				"name":     img.Name,
+				"group_id": img.GroupID,
+				"order":    img.Order,
+				"version":  gorm.Expr("version + 1"),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		orphans := tx.Model(&models.Image{}).Where("group_id IS NOT NULL")
+		if len(ids) > 0 {
+			orphans = orphans.Where("group_id NOT IN ?", ids)
+		}
+		if err := orphans.Update("group_id", nil).Error; err != nil {
+			return err
+		}
+
+		if theme != nil {
+			theme.ID = 1
+			if err := tx.Save(theme).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&models.MenuSnapshot{}, 1).Error
+	})
+}
+
+// revisionsKept is how many revisions each image or client keeps; older ones
+// are dropped as new ones are recorded.
+const revisionsKept = 20
+
+func createRevision(db *gorm.DB, rev *models.Revision) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rev).Error; err != nil {
+			return err
+		}
+		var keep []uint
+		if err := tx.Model(&models.Revision{}).
+			Where("entity_type = ? AND entity_key = ?", rev.EntityType, rev.EntityKey).
+			Order("id DESC").Limit(revisionsKept).Pluck("id", &keep).Error; err != nil {
+			return err
+		}
+		return tx.Where("entity_type = ? AND entity_key = ? AND id NOT IN ?", rev.EntityType, rev.EntityKey, keep).
+			Delete(&models.Revision{}).Error
+	})
+}
+
+// listRevisions returns the newest revisions first. An empty entity type or
+// key matches any.
+func listRevisions(db *gorm.DB, entityType, key string, limit int) ([]*models.Revision, error) {
+	q := db.Order("id DESC")
+	if entityType != "" {
+		q = q.Where("entity_type = ?", entityType)
+	}
+	if key != "" {
+		q = q.Where("entity_key = ?", key)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	var revs []*models.Revision
+	if err := q.Find(&revs).Error; err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
+
+// summarizeBootLogs counts boots, failures and distinct clients in
+// [from, to). Only the counts are filled in.
+func summarizeBootLogs(db *gorm.DB, from, to time.Time) (*models.StatsRollup, error) {
+	var r models.StatsRollup
+	q := func() *gorm.DB {
+		return db.Model(&models.BootLog{}).Where("created_at >= ? AND created_at < ?", from, to)
+	}
+	if err := q().Count(&r.Boots).Error; err != nil {
+		return nil, err
+	}
+	if err := q().Where("success = ?", false).Count(&r.FailedBoots).Error; err != nil {
+		return nil, err
+	}
+	if err := q().Where("mac_address <> ''").Distinct("mac_address").Count(&r.ActiveClients).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// bootMethodStats counts boots and failures per image and boot method
+// since the given time.
+func bootMethodStats(db *gorm.DB, since time.Time) ([]models.BootMethodStat, error) {
+	var out []models.BootMethodStat
+	err := db.Model(&models.BootLog{}).
+		Select("image_name, boot_method, COUNT(*) AS boots, SUM(CASE WHEN success THEN 0 ELSE 1 END) AS failures").
+		Where("created_at >= ? AND image_name <> ''", since).
+		Group("image_name, boot_method").
+		Scan(&out).Error
+	return out, err
+}
+
+// bootDistroStats counts boots and failures in [from, to) per distro of the
+// booted image and boot method.
+func bootDistroStats(db *gorm.DB, from, to time.Time) ([]models.BootDistroStat, error) {
+	var out []models.BootDistroStat
+	err := db.Table("boot_logs").
+		Select("COALESCE(images.distro, '') AS distro, boot_logs.boot_method, COUNT(*) AS boots, SUM(CASE WHEN boot_logs.success THEN 0 ELSE 1 END) AS failures").
+		Joins("LEFT JOIN images ON images.id = boot_logs.image_id").
+		Where("boot_logs.created_at >= ? AND boot_logs.created_at < ?", from, to).
+		Group("COALESCE(images.distro, ''), boot_logs.boot_method").
+		Scan(&out).Error
+	return out, err
+}
+
+// saveStatsRollup writes a bucket's counts, leaving its bytes alone since
+// those are added as they are served.
+func saveStatsRollup(db *gorm.DB, r *models.StatsRollup) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "period"}, {Name: "start"}},
+		DoUpdates: clause.AssignmentColumns([]string{"boots", "failed_boots", "active_clients"}),
+	}).Create(r).Error
+}
+
+func addStatsBytes(db *gorm.DB, period string, start time.Time, n int64) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "period"}, {Name: "start"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"bytes_served": gorm.Expr("stats_rollups.bytes_served + ?", n)}),
+	}).Create(&models.StatsRollup{Period: period, Start: start, BytesServed: n}).Error
+}
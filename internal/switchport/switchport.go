@@ -0,0 +1,214 @@
+// Package switchport moves a client's switch port between the provisioning
+// and production VLANs as it goes through an install. Drivers either talk
+// RESTCONF (OpenConfig VLAN model) to the switch or hand the change to a
+// webhook, e.g. a small Netmiko service, for switches without an API.
+package switchport
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"bootimus/internal/models"
+)
+
+const (
+	StateProvisioning = "provisioning"
+	StateProduction   = "production"
+
+	defaultTimeout = 15 * time.Second
+)
+
+type Switch struct {
+	Name     string `mapstructure:"name" json:"name"`
+	Driver   string `mapstructure:"driver" json:"driver"` // restconf or webhook
+	URL      string `mapstructure:"url" json:"url"`
+	Username string `mapstructure:"username" json:"-"`
+	Password string `mapstructure:"password" json:"-"`
+	Insecure bool   `mapstructure:"insecure" json:"insecure,omitempty"`
+}
+
+type Config struct {
+	Switches         []Switch      `mapstructure:"switches"`
+	ProvisioningVLAN int           `mapstructure:"provisioning_vlan"`
+	ProductionVLAN   int           `mapstructure:"production_vlan"`
+	Timeout          time.Duration `mapstructure:"timeout"`
+}
+
+// Request describes one port change; webhook drivers receive it as JSON.
+type Request struct {
+	Action string `json:"action"` // always "set_access_vlan"
+	Switch string `json:"switch"`
+	Port   string `json:"port"`
+	VLAN   int    `json:"vlan"`
+	State  string `json:"state"`
+	MAC    string `json:"mac"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type Manager struct {
+	cfg      Config
+	switches map[string]Switch
+	client   *http.Client
+	insecure *http.Client
+}
+
+// New returns nil when no switches are configured; a nil Manager does
+// nothing.
+func New(cfg Config) (*Manager, error) {
+	if len(cfg.Switches) == 0 {
+		return nil, nil
+	}
+	if cfg.ProvisioningVLAN <= 0 || cfg.ProductionVLAN <= 0 {
+		return nil, fmt.Errorf("switchport: provisioning_vlan and production_vlan must both be set")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	m := &Manager{
+		cfg:      cfg,
+		switches: make(map[string]Switch, len(cfg.Switches)),
+		client:   &http.Client{Timeout: cfg.Timeout},
+		insecure: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		},
+	}
+	for _, sw := range cfg.Switches {
+		switch sw.Driver {
+		case "restconf", "webhook":
+		default:
+			return nil, fmt.Errorf("switchport: switch %q: unknown driver %q", sw.Name, sw.Driver)
+		}
+		if sw.Name == "" || sw.URL == "" {
+			return nil, fmt.Errorf("switchport: every switch needs a name and url")
+		}
+		m.switches[sw.Name] = sw
+	}
+	return m, nil
+}
+
+func (m *Manager) Enabled() bool {
+	return m != nil
+}
+
+// Switches lists the configured switches without credentials.
+func (m *Manager) Switches() []Switch {
+	if m == nil {
+		return nil
+	}
+	return m.cfg.Switches
+}
+
+// VLAN returns the VLAN a state maps to.
+func (m *Manager) VLAN(state string) (int, error) {
+	switch state {
+	case StateProvisioning:
+		return m.cfg.ProvisioningVLAN, nil
+	case StateProduction:
+		return m.cfg.ProductionVLAN, nil
+	}
+	return 0, fmt.Errorf("unknown state %q", state)
+}
+
+// Move puts the port into the VLAN for state.
+func (m *Manager) Move(switchName, port, mac, state, reason string) (int, error) {
+	if m == nil {
+		return 0, fmt.Errorf("switch port automation is not configured")
+	}
+	sw, ok := m.switches[switchName]
+	if !ok {
+		return 0, fmt.Errorf("unknown switch %q", switchName)
+	}
+	if port == "" {
+		return 0, fmt.Errorf("no switch port set")
+	}
+	vlan, err := m.VLAN(state)
+	if err != nil {
+		return 0, err
+	}
+	req := Request{Action: "set_access_vlan", Switch: sw.Name, Port: port, VLAN: vlan, State: state, MAC: mac, Reason: reason}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.Timeout)
+	defer cancel()
+	if sw.Driver == "restconf" {
+		err = m.restconf(ctx, sw, req)
+	} else {
+		err = m.webhook(ctx, sw, req)
+	}
+	if err != nil {
+		return vlan, fmt.Errorf("%s %s: %w", sw.Name, port, err)
+	}
+	log.Printf("Switchport: %s %s (%s) moved to VLAN %d (%s: %s)", sw.Name, port, mac, vlan, state, reason)
+	return vlan, nil
+}
+
+// Apply moves client's port to state and records the outcome on client's
+// switch fields; the caller saves them. Clients without a port are skipped.
+func (m *Manager) Apply(client *models.Client, state, reason string) error {
+	if m == nil || client.SwitchName == "" || client.SwitchPort == "" {
+		return nil
+	}
+	vlan, err := m.Move(client.SwitchName, client.SwitchPort, client.MACAddress, state, reason)
+	client.SwitchVLAN = vlan
+	if err != nil {
+		client.SwitchState = "failed"
+		client.SwitchError = err.Error()
+		return err
+	}
+	client.SwitchState = state
+	client.SwitchError = ""
+	return nil
+}
+
+// restconf sets the access VLAN with the OpenConfig model, which most
+// RESTCONF-capable switches implement.
+func (m *Manager) restconf(ctx context.Context, sw Switch, req Request) error {
+	endpoint := fmt.Sprintf("%s/data/openconfig-interfaces:interfaces/interface=%s/openconfig-if-ethernet:ethernet/openconfig-vlan:switched-vlan/config",
+		strings.TrimSuffix(sw.URL, "/"), url.PathEscape(req.Port))
+	body, _ := json.Marshal(map[string]interface{}{
+		"openconfig-vlan:config": map[string]interface{}{
+			"interface-mode": "ACCESS",
+			"access-vlan":    req.VLAN,
+		},
+	})
+	return m.send(ctx, sw, http.MethodPatch, endpoint, "application/yang-data+json", body)
+}
+
+func (m *Manager) webhook(ctx context.Context, sw Switch, req Request) error {
+	body, _ := json.Marshal(req)
+	return m.send(ctx, sw, http.MethodPost, sw.URL, "application/json", body)
+}
+
+func (m *Manager) send(ctx context.Context, sw Switch, method, endpoint, contentType string, body []byte) error {
+	httpReq, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("Accept", contentType)
+	httpReq.Header.Set("User-Agent", "bootimus-switchport/1")
+	if sw.Username != "" {
+		httpReq.SetBasicAuth(sw.Username, sw.Password)
+	}
+	client := m.client
+	if sw.Insecure {
+		client = m.insecure
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned HTTP %d", method, endpoint, resp.StatusCode)
+	}
+	return nil
+}
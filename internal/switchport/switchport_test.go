@@ -0,0 +1,67 @@
+package switchport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bootimus/internal/models"
+)
+
+func TestApply(t *testing.T) {
+	var gotPath, gotMethod string
+	var gotBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.EscapedPath()
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	m, err := New(Config{
+		ProvisioningVLAN: 200,
+		ProductionVLAN:   10,
+		Switches: []Switch{
+			{Name: "rc", Driver: "restconf", URL: srv.URL + "/restconf"},
+			{Name: "wh", Driver: "webhook", URL: srv.URL + "/hook"},
+			{Name: "bad", Driver: "webhook", URL: srv.URL + "/hook?fail=1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &models.Client{MACAddress: "00:11:22:33:44:55", SwitchName: "rc", SwitchPort: "Ethernet1/12"}
+	if err := m.Apply(c, StateProvisioning, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if gotMethod != http.MethodPatch || gotPath != "/restconf/data/openconfig-interfaces:interfaces/interface=Ethernet1%2F12/openconfig-if-ethernet:ethernet/openconfig-vlan:switched-vlan/config" {
+		t.Errorf("restconf request = %s %s", gotMethod, gotPath)
+	}
+	if cfg, _ := gotBody["openconfig-vlan:config"].(map[string]interface{}); cfg["access-vlan"] != float64(200) {
+		t.Errorf("restconf body = %v", gotBody)
+	}
+	if c.SwitchState != StateProvisioning || c.SwitchVLAN != 200 {
+		t.Errorf("client state = %s/%d", c.SwitchState, c.SwitchVLAN)
+	}
+
+	c.SwitchName = "wh"
+	if err := m.Apply(c, StateProduction, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if gotMethod != http.MethodPost || gotBody["vlan"] != float64(10) || gotBody["port"] != "Ethernet1/12" {
+		t.Errorf("webhook request = %s %v", gotMethod, gotBody)
+	}
+
+	c.SwitchName = "bad"
+	if err := m.Apply(c, StateProduction, "test"); err == nil || c.SwitchState != "failed" || c.SwitchError == "" {
+		t.Errorf("failed move: err %v, state %q", err, c.SwitchState)
+	}
+
+	if err := (*Manager)(nil).Apply(c, StateProduction, "test"); err != nil {
+		t.Errorf("nil manager: %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+// Package telemetry writes a usage report for every month to the data
+// directory, for capacity planning. Reports only hold totals: no MAC or IP
+// addresses, hostnames or image names. When enabled, and only then, each
+// finished month's report is also sent to a collection URL.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"bootimus/internal/stats"
+	"bootimus/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+const checkInterval = time.Hour
+
+type Config struct {
+	Dir     string // where reports are written
+	Enabled bool   // send finished months' reports to URL
+	URL     string
+	Version string
+	// Features names the optional features in use, e.g. "proxy_dhcp".
+	Features func() []string
+}
+
+type Reporter struct {
+	cfg    Config
+	store  storage.Storage
+	client *http.Client
+}
+
+// Report is one month of activity. Partial is set while the month is still
+// running.
+type Report struct {
+	Month          string           `json:"month"` // YYYY-MM, UTC
+	GeneratedAt    time.Time        `json:"generated_at"`
+	Partial        bool             `json:"partial,omitempty"`
+	Version        string           `json:"version"`
+	Boots          int64            `json:"boots"`
+	FailedBoots    int64            `json:"failed_boots"`
+	ActiveClients  int64            `json:"active_clients"`
+	BytesServed    int64            `json:"bytes_served"`
+	PeakDay        string           `json:"peak_day,omitempty"`
+	PeakDayBoots   int64            `json:"peak_day_boots"`
+	BootsByDistro  map[string]int64 `json:"boots_by_distro"`
+	BootsByMethod  map[string]int64 `json:"boots_by_method"`
+	Images         int              `json:"images"`
+	ImagesByDistro map[string]int   `json:"images_by_distro"`
+	Clients        int              `json:"clients"`
+	Features       []string         `json:"features"`
+}
+
+func New(store storage.Storage, cfg Config) (*Reporter, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("no report directory")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if cfg.Enabled && cfg.URL == "" {
+		log.Printf("Telemetry: No URL set, reports are only written locally")
+		cfg.Enabled = false
+	}
+	return &Reporter{cfg: cfg, store: store, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// MonthStart returns the UTC start of the month holding t.
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Generate builds the report for the month starting at month, as of now.
+func (r *Reporter) Generate(month time.Time, now time.Time) (*Report, error) {
+	from := MonthStart(month)
+	to := from.AddDate(0, 1, 0)
+	rep := &Report{
+		Month:          from.Format("2006-01"),
+		GeneratedAt:    now.UTC(),
+		Partial:        now.Before(to),
+		Version:        r.cfg.Version,
+		BootsByDistro:  map[string]int64{},
+		BootsByMethod:  map[string]int64{},
+		ImagesByDistro: map[string]int{},
+		Features:       []string{},
+	}
+
+	sum, err := r.store.SummarizeBootLogs(from, to)
+	if err != nil {
+		return nil, err
+	}
+	rep.Boots, rep.FailedBoots, rep.ActiveClients = sum.Boots, sum.FailedBoots, sum.ActiveClients
+
+	byDistro, err := r.store.BootDistroStats(from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range byDistro {
+		rep.BootsByDistro[orUnknown(s.Distro)] += s.Boots
+		rep.BootsByMethod[orUnknown(s.BootMethod)] += s.Boots
+	}
+
+	// Daily rollups are UTC days, so a UTC month is made of whole ones.
+	days, err := r.store.ListStatsRollups(stats.PeriodDay, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range days {
+		rep.BytesServed += d.BytesServed
+		if d.Boots > rep.PeakDayBoots {
+			rep.PeakDay, rep.PeakDayBoots = d.Start.Format("2006-01-02"), d.Boots
+		}
+	}
+
+	images, err := r.store.ListImages()
+	if err != nil {
+		return nil, err
+	}
+	rep.Images = len(images)
+	for _, img := range images {
+		rep.ImagesByDistro[orUnknown(img.Distro)]++
+	}
+	clients, err := r.store.ListClients()
+	if err != nil {
+		return nil, err
+	}
+	rep.Clients = len(clients)
+
+	if r.cfg.Features != nil {
+		rep.Features = append(rep.Features, r.cfg.Features()...)
+		sort.Strings(rep.Features)
+	}
+	return rep, nil
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// Run writes the report of each finished month once it is over, and sends
+// it when enabled, checking hourly until ctx is done.
+func (r *Reporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.check(ctx, time.Now()); err != nil {
+			log.Printf("Telemetry: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check handles last month: nothing before the first logged boot is
+// reported, so a new install doesn't start with an empty report.
+func (r *Reporter) check(ctx context.Context, now time.Time) error {
+	month := MonthStart(now).AddDate(0, -1, 0)
+	first, err := r.store.EarliestBootLog()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !first.Before(month.AddDate(0, 1, 0)) {
+		return nil
+	}
+
+	jsonPath := r.path(month, ".json")
+	if _, err := os.Stat(jsonPath); os.IsNotExist(err) {
+		rep, err := r.Generate(month, now)
+		if err != nil {
+			return fmt.Errorf("report for %s: %w", month.Format("2006-01"), err)
+		}
+		if err := r.write(rep); err != nil {
+			return err
+		}
+		log.Printf("Telemetry: Wrote usage report %s", jsonPath)
+	}
+
+	if !r.cfg.Enabled {
+		return nil
+	}
+	sentPath := r.path(month, ".sent")
+	if _, err := os.Stat(sentPath); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return err
+	}
+	if err := r.send(ctx, data); err != nil {
+		return fmt.Errorf("sending %s: %w", month.Format("2006-01"), err)
+	}
+	return os.WriteFile(sentPath, []byte(now.UTC().Format(time.RFC3339)+"\n"), 0644)
+}
+
+func (r *Reporter) path(month time.Time, ext string) string {
+	return filepath.Join(r.cfg.Dir, "usage-"+month.Format("2006-01")+ext)
+}
+
+// write saves the report as JSON and as Markdown for reading.
+func (r *Reporter) write(rep *Report) error {
+	month, _ := time.Parse("2006-01", rep.Month)
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.path(month, ".md"), []byte(rep.Markdown()), 0644); err != nil {
+		return err
+	}
+	// The JSON goes last as its presence marks the month as done.
+	return os.WriteFile(r.path(month, ".json"), data, 0644)
+}
+
+func (r *Reporter) send(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bootimus/"+r.cfg.Version)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned HTTP %d", r.cfg.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// Reports lists the months with a written report, newest first.
+func (r *Reporter) Reports() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(r.cfg.Dir, "usage-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	months := []string{}
+	for _, m := range matches {
+		months = append(months, strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), "usage-"), ".json"))
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(months)))
+	return months, nil
+}
+
+// Markdown renders the report for people.
+func (rep *Report) Markdown() string {
+	var b strings.Builder
+	title := "Bootimus usage, " + rep.Month
+	if rep.Partial {
+		title += " (so far)"
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "Generated %s by Bootimus %s.\n\n", rep.GeneratedAt.Format("2006-01-02 15:04 MST"), rep.Version)
+	fmt.Fprintf(&b, "| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Boots | %d |\n", rep.Boots)
+	fmt.Fprintf(&b, "| Failed boots | %d |\n", rep.FailedBoots)
+	fmt.Fprintf(&b, "| Clients booted | %d |\n", rep.ActiveClients)
+	fmt.Fprintf(&b, "| Data served | %s |\n", formatBytes(rep.BytesServed))
+	if rep.PeakDay != "" {
+		fmt.Fprintf(&b, "| Busiest day | %s (%d boots) |\n", rep.PeakDay, rep.PeakDayBoots)
+	}
+	fmt.Fprintf(&b, "| Images | %d |\n", rep.Images)
+	fmt.Fprintf(&b, "| Known clients | %d |\n", rep.Clients)
+
+	writeCounts(&b, "Boots by distro", rep.BootsByDistro)
+	writeCounts(&b, "Boots by method", rep.BootsByMethod)
+	images := make(map[string]int64, len(rep.ImagesByDistro))
+	for k, v := range rep.ImagesByDistro {
+		images[k] = int64(v)
+	}
+	writeCounts(&b, "Images by distro", images)
+
+	if len(rep.Features) > 0 {
+		fmt.Fprintf(&b, "\n## Features in use\n\n%s\n", strings.Join(rep.Features, ", "))
+	}
+	return b.String()
+}
+
+func writeCounts(b *strings.Builder, heading string, counts map[string]int64) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	fmt.Fprintf(b, "\n## %s\n\n| | |\n|---|---|\n", heading)
+	for _, k := range keys {
+		fmt.Fprintf(b, "| %s | %d |\n", k, counts[k])
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
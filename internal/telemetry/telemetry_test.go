@@ -0,0 +1,86 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bootimus/internal/models"
+	"bootimus/internal/storage"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMonthlyReport(t *testing.T) {
+	dir := t.TempDir()
+	st, err := storage.NewSQLiteStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "bootimus.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := &models.Image{Name: "Ubuntu", Filename: "ubuntu.iso", Distro: "ubuntu"}
+	if err := st.CreateImage(img); err != nil {
+		t.Fatal(err)
+	}
+	sept := time.Date(2026, 9, 12, 10, 0, 0, 0, time.UTC)
+	for i, l := range []models.BootLog{
+		{CreatedAt: sept, MACAddress: "aa", ImageID: &img.ID, ImageName: "Ubuntu", BootMethod: "kernel", Success: true},
+		{CreatedAt: sept, MACAddress: "bb", ImageID: &img.ID, ImageName: "Ubuntu", BootMethod: "kernel", Success: false},
+		{CreatedAt: sept, MACAddress: "bb", ImageName: "gone", BootMethod: "sanboot", Success: true},
+		{CreatedAt: sept.AddDate(0, 1, 0), MACAddress: "cc", ImageID: &img.ID, ImageName: "Ubuntu", Success: true},
+	} {
+		l.CreatedAt = l.CreatedAt.Local()
+		if err := db.Create(&l).Error; err != nil {
+			t.Fatalf("log %d: %v", i, err)
+		}
+	}
+
+	var got Report
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	reports := filepath.Join(dir, "reports")
+	r, err := New(st, Config{Dir: reports, Enabled: true, URL: srv.URL, Features: func() []string { return []string{"nbd"} }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	if err := r.check(t.Context(), now); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Month != "2026-09" || got.Partial || got.Boots != 3 || got.FailedBoots != 1 || got.ActiveClients != 2 {
+		t.Errorf("sent %+v", got)
+	}
+	if got.BootsByDistro["ubuntu"] != 2 || got.BootsByDistro["unknown"] != 1 || got.BootsByMethod["kernel"] != 2 {
+		t.Errorf("breakdown %v %v", got.BootsByDistro, got.BootsByMethod)
+	}
+	for _, ext := range []string{".json", ".md", ".sent"} {
+		if _, err := os.Stat(filepath.Join(reports, "usage-2026-09"+ext)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Already written and sent, so nothing is sent again.
+	got = Report{}
+	if err := r.check(t.Context(), now); err != nil {
+		t.Fatal(err)
+	}
+	if got.Month != "" {
+		t.Error("report sent twice")
+	}
+}
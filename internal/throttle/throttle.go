@@ -0,0 +1,138 @@
+// Package throttle caps the bandwidth of transfers. A Limiter is a token
+// bucket of bytes shared by every reader it wraps, so one limiter caps the
+// total of several downloads.
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readChunk keeps reads small so a slow limit is met smoothly rather than
+// in bursts of a whole buffer.
+const readChunk = 64 << 10
+
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+}
+
+// New returns a limiter of bytesPerSec, or nil, which limits nothing, when
+// it is not positive.
+func New(bytesPerSec int64) *Limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &Limiter{rate: float64(bytesPerSec), tokens: float64(bytesPerSec), last: time.Now()}
+}
+
+// Rate is the limit in bytes per second, 0 for none.
+func (l *Limiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+	return int64(l.rate)
+}
+
+// Wait blocks until n more bytes fit in the limit. Up to a second's worth
+// may pass at once after the limiter has been idle.
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type reader struct {
+	ctx    context.Context
+	r      io.Reader
+	limits []*Limiter
+}
+
+// Reader reads from r within every one of limits; nil limiters are
+// skipped.
+func Reader(ctx context.Context, r io.Reader, limits ...*Limiter) io.Reader {
+	var active []*Limiter
+	for _, l := range limits {
+		if l != nil {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, limits: active}
+}
+
+func (t *reader) Read(p []byte) (int, error) {
+	if len(p) > readChunk {
+		p = p[:readChunk]
+	}
+	n, err := t.r.Read(p)
+	for _, l := range t.limits {
+		if werr := l.Wait(t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// ParseRate reads a rate such as "20MB", "512K", "1.5GiB/s" or a plain
+// number of bytes per second. Units are powers of 1024. "" and "0" mean
+// no limit.
+func ParseRate(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(v, "/S")
+	v = strings.TrimSuffix(v, "B")
+	v = strings.TrimSuffix(v, "I")
+	if v == "" {
+		return 0, nil
+	}
+	mult := 1.0
+	switch v[len(v)-1] {
+	case 'K':
+		mult = 1 << 10
+	case 'M':
+		mult = 1 << 20
+	case 'G':
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid rate %q (want e.g. 20MB or 512K)", s)
+	}
+	return int64(n * mult), nil
+}
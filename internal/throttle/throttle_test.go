@@ -0,0 +1,53 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"1000", 1000},
+		{"512K", 512 << 10},
+		{"20MB", 20 << 20},
+		{"1.5GiB/s", 3 << 29},
+		{"10 mb", 10 << 20},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRate(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"fast", "-5M", "M"} {
+		if _, err := ParseRate(bad); err == nil {
+			t.Errorf("ParseRate(%q) accepted", bad)
+		}
+	}
+}
+
+func TestReaderLimits(t *testing.T) {
+	// A second's worth passes at once, so 3s of data at 100KB/s takes
+	// about two seconds.
+	l := New(100 << 10)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, Reader(context.Background(), bytes.NewReader(make([]byte, 300<<10)), l, nil))
+	if err != nil || n != 300<<10 {
+		t.Fatalf("copied %d, %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 1800*time.Millisecond || elapsed > 3*time.Second {
+		t.Errorf("took %v, want about 2s", elapsed)
+	}
+
+	if r := bytes.NewReader(nil); Reader(context.Background(), r, nil) != io.Reader(r) {
+		t.Error("nil limiter wrapped the reader")
+	}
+}
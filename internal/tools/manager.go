@@ -474,6 +474,9 @@ func (m *Manager) GetEnabledTools(serverURL string) []EnabledTool {
 		if bm == "" {
 			bm = "kernel"
 		}
+		if bm == "memdisk" && !m.HasMemdisk() {
+			continue
+		}
 		et := EnabledTool{
 			Name:        tool.Name,
 			DisplayName: tool.DisplayName,
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Memdisk is syslinux's BIOS disk-image loader. Tools with boot_method
+// "memdisk" chain it from /memdisk, so it has to be installed before they
+// can be enabled. It is not bundled because syslinux is GPL; it is
+// downloaded from the syslinux release tarball or uploaded by the admin.
+
+const DefaultMemdiskURL = "https://mirrors.edge.kernel.org/pub/linux/utils/boot/syslinux/syslinux-6.03.tar.gz"
+
+const maxMemdiskSize = 1 << 20
+
+type MemdiskStatus struct {
+	Present bool      `json:"present"`
+	Size    int64     `json:"size,omitempty"`
+	SHA256  string    `json:"sha256,omitempty"`
+	Updated time.Time `json:"updated,omitempty"`
+	Source  string    `json:"default_url"`
+}
+
+func (m *Manager) MemdiskPath() string {
+	return filepath.Join(m.dataDir, "loaders", "memdisk")
+}
+
+func (m *Manager) HasMemdisk() bool {
+	st, err := os.Stat(m.MemdiskPath())
+	return err == nil && st.Mode().IsRegular() && st.Size() > 0
+}
+
+func (m *Manager) MemdiskStatus() MemdiskStatus {
+	status := MemdiskStatus{Source: DefaultMemdiskURL}
+	f, err := os.Open(m.MemdiskPath())
+	if err != nil {
+		return status
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return status
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return status
+	}
+	status.Present = true
+	status.Size = st.Size()
+	status.SHA256 = hex.EncodeToString(h.Sum(nil))
+	status.Updated = st.ModTime()
+	return status
+}
+
+// InstallMemdisk replaces the loader with r, which is either the memdisk
+// binary itself or a syslinux .tar.gz containing bios/memdisk/memdisk.
+func (m *Manager) InstallMemdisk(r io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(r, 64<<20))
+	if err != nil {
+		return err
+	}
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		if data, err = memdiskFromTarball(data); err != nil {
+			return err
+		}
+	}
+	if len(data) == 0 || len(data) > maxMemdiskSize {
+		return fmt.Errorf("memdisk must be between 1 byte and %d KB, got %d bytes", maxMemdiskSize>>10, len(data))
+	}
+
+	dest := m.MemdiskPath()
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp := dest + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	log.Printf("Tools: Installed memdisk loader (%d bytes)", len(data))
+	return nil
+}
+
+func memdiskFromTarball(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("tarball has no bios/memdisk/memdisk")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg && strings.HasSuffix(hdr.Name, "bios/memdisk/memdisk") {
+			return io.ReadAll(io.LimitReader(tr, maxMemdiskSize+1))
+		}
+	}
+}
+
+// FetchMemdisk downloads the loader from rawURL, or DefaultMemdiskURL if
+// it is empty.
+func (m *Manager) FetchMemdisk(rawURL string) error {
+	if rawURL == "" {
+		rawURL = DefaultMemdiskURL
+	}
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Bootimus PXE Server")
+	client := &http.Client{Timeout: 10 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	log.Printf("Tools: Downloading memdisk loader from %s", rawURL)
+	return m.InstallMemdisk(resp.Body)
+}
+
+func (m *Manager) DeleteMemdisk() error {
+	if err := os.Remove(m.MemdiskPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// BootMethod returns how a tool boots, defaulting to "kernel".
+func (m *Manager) BootMethod(name string) string {
+	var bm string
+	if def := GetDefinition(name); def != nil {
+		bm = def.BootMethod
+	} else if tool, err := m.store.GetBootTool(name); err == nil {
+		bm = tool.BootMethod
+	}
+	if bm == "" {
+		bm = "kernel"
+	}
+	return bm
+}
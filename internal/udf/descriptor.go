@@ -1,6 +1,7 @@
 package udf
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -21,6 +22,27 @@ const (
 	DescriptorFileEntry               = 0x105
 )
 
+// Minimum lengths of the fixed parts of each descriptor. The constructors
+// refuse anything shorter, and anything whose own length fields point past
+// the end of b, so a corrupt image yields an error rather than a panic.
+const (
+	tagLen                      = 16
+	anchorVolumePointerLen      = 32
+	primaryVolumeDescriptorLen  = 490
+	partitionDescriptorLen      = 356
+	logicalVolumeDescriptorLen  = 440
+	fileSetDescriptorLen        = 464
+	fileIdentifierDescriptorLen = 38
+	fileEntryLen                = 176
+)
+
+func checkLen(b []byte, n int, what string) error {
+	if len(b) < n {
+		return fmt.Errorf("%s needs %d bytes, have %d", what, n, len(b))
+	}
+	return nil
+}
+
 type Descriptor struct {
 	TagIdentifier       uint16
 	DescriptorVersion   uint16
@@ -32,7 +54,10 @@ type Descriptor struct {
 	data                []byte
 }
 
-func NewDescriptor(b []byte) *Descriptor {
+func NewDescriptor(b []byte) (*Descriptor, error) {
+	if err := checkLen(b, tagLen, "descriptor tag"); err != nil {
+		return nil, err
+	}
 	d := &Descriptor{
 		data: b,
 	}
@@ -43,18 +68,18 @@ func NewDescriptor(b []byte) *Descriptor {
 	d.DescriptorCRC = readU16LE(b[8:])
 	d.DescriptorCRCLength = readU16LE(b[10:])
 	d.TagLocation = readU32LE(b[12:])
-	return d
+	return d, nil
 }
 
-func (d *Descriptor) PrimaryVolumeDescriptor() *PrimaryVolumeDescriptor {
+func (d *Descriptor) PrimaryVolumeDescriptor() (*PrimaryVolumeDescriptor, error) {
 	return NewPrimaryVolumeDescriptor(d.data)
 }
 
-func (d *Descriptor) PartitionDescriptor() *PartitionDescriptor {
+func (d *Descriptor) PartitionDescriptor() (*PartitionDescriptor, error) {
 	return NewPartitionDescriptor(d.data)
 }
 
-func (d *Descriptor) LogicalVolumeDescriptor() *LogicalVolumeDescriptor {
+func (d *Descriptor) LogicalVolumeDescriptor() (*LogicalVolumeDescriptor, error) {
 	return NewLogicalVolumeDescriptor(d.data)
 }
 
@@ -64,12 +89,16 @@ type AnchorVolumeDescriptorPointer struct {
 	ReserveVolumeDescriptorSeq Extent
 }
 
-func NewAnchorVolumeDescriptorPointer(b []byte) *AnchorVolumeDescriptorPointer {
+func NewAnchorVolumeDescriptorPointer(b []byte) (*AnchorVolumeDescriptorPointer, error) {
+	if err := checkLen(b, anchorVolumePointerLen, "anchor volume descriptor pointer"); err != nil {
+		return nil, err
+	}
 	ad := &AnchorVolumeDescriptorPointer{}
-	ad.Descriptor = *NewDescriptor(b)
+	d, _ := NewDescriptor(b)
+	ad.Descriptor = *d
 	ad.MainVolumeDescriptorSeq = NewExtent(b[16:])
 	ad.ReserveVolumeDescriptorSeq = NewExtent(b[24:])
-	return ad
+	return ad, nil
 }
 
 type PrimaryVolumeDescriptor struct {
@@ -94,9 +123,13 @@ type PrimaryVolumeDescriptor struct {
 	Flags                                       uint16
 }
 
-func NewPrimaryVolumeDescriptor(b []byte) *PrimaryVolumeDescriptor {
+func NewPrimaryVolumeDescriptor(b []byte) (*PrimaryVolumeDescriptor, error) {
+	if err := checkLen(b, primaryVolumeDescriptorLen, "primary volume descriptor"); err != nil {
+		return nil, err
+	}
 	pvd := &PrimaryVolumeDescriptor{}
-	pvd.Descriptor = *NewDescriptor(b)
+	d, _ := NewDescriptor(b)
+	pvd.Descriptor = *d
 	pvd.VolumeDescriptorSequenceNumber = readU32LE(b[16:])
 	pvd.PrimaryVolumeDescriptorNumber = readU32LE(b[20:])
 	pvd.VolumeIdentifier = readDString(b[24:], 32)
@@ -115,7 +148,7 @@ func NewPrimaryVolumeDescriptor(b []byte) *PrimaryVolumeDescriptor {
 	pvd.ImplementationUse = b[420:484]
 	pvd.PredecessorVolumeDescriptorSequenceLocation = readU32LE(b[484:])
 	pvd.Flags = readU16LE(b[488:])
-	return pvd
+	return pvd, nil
 }
 
 type PartitionDescriptor struct {
@@ -132,9 +165,13 @@ type PartitionDescriptor struct {
 	ImplementationUse              []byte
 }
 
-func NewPartitionDescriptor(b []byte) *PartitionDescriptor {
+func NewPartitionDescriptor(b []byte) (*PartitionDescriptor, error) {
+	if err := checkLen(b, partitionDescriptorLen, "partition descriptor"); err != nil {
+		return nil, err
+	}
 	pd := &PartitionDescriptor{}
-	pd.Descriptor = *NewDescriptor(b)
+	d, _ := NewDescriptor(b)
+	pd.Descriptor = *d
 	pd.VolumeDescriptorSequenceNumber = readU32LE(b[16:])
 	pd.PartitionFlags = readU16LE(b[20:])
 	pd.PartitionNumber = readU16LE(b[22:])
@@ -145,7 +182,7 @@ func NewPartitionDescriptor(b []byte) *PartitionDescriptor {
 	pd.PartitionLength = readU32LE(b[192:])
 	pd.ImplementationIdentifier = NewEntityID(b[196:])
 	pd.ImplementationUse = b[228:356]
-	return pd
+	return pd, nil
 }
 
 type LogicalVolumeDescriptor struct {
@@ -162,9 +199,13 @@ type LogicalVolumeDescriptor struct {
 	IntegritySequenceExtent        Extent
 }
 
-func NewLogicalVolumeDescriptor(b []byte) *LogicalVolumeDescriptor {
+func NewLogicalVolumeDescriptor(b []byte) (*LogicalVolumeDescriptor, error) {
+	if err := checkLen(b, logicalVolumeDescriptorLen, "logical volume descriptor"); err != nil {
+		return nil, err
+	}
 	lvd := &LogicalVolumeDescriptor{}
-	lvd.Descriptor = *NewDescriptor(b)
+	d, _ := NewDescriptor(b)
+	lvd.Descriptor = *d
 	lvd.VolumeDescriptorSequenceNumber = readU32LE(b[16:])
 	lvd.LogicalVolumeIdentifier = readDString(b[84:], 128)
 	lvd.LogicalBlockSize = readU32LE(b[212:])
@@ -175,7 +216,7 @@ func NewLogicalVolumeDescriptor(b []byte) *LogicalVolumeDescriptor {
 	lvd.ImplementationIdentifier = NewEntityID(b[272:])
 	lvd.ImplementationUse = b[304:432]
 	lvd.IntegritySequenceExtent = NewExtent(b[432:])
-	return lvd
+	return lvd, nil
 }
 
 type FileSetDescriptor struct {
@@ -196,9 +237,13 @@ type FileSetDescriptor struct {
 	NextExtent              ExtentLong
 }
 
-func NewFileSetDescriptor(b []byte) *FileSetDescriptor {
+func NewFileSetDescriptor(b []byte) (*FileSetDescriptor, error) {
+	if err := checkLen(b, fileSetDescriptorLen, "file set descriptor"); err != nil {
+		return nil, err
+	}
 	fsd := &FileSetDescriptor{}
-	fsd.Descriptor = *NewDescriptor(b)
+	d, _ := NewDescriptor(b)
+	fsd.Descriptor = *d
 	fsd.RecordingDateTime = readTimestamp(b[16:])
 	fsd.InterchangeLevel = readU16LE(b[28:])
 	fsd.MaximumInterchangeLevel = readU16LE(b[30:])
@@ -213,7 +258,7 @@ func NewFileSetDescriptor(b []byte) *FileSetDescriptor {
 	fsd.RootDirectoryICB = NewExtentLong(b[400:])
 	fsd.DomainIdentifier = NewEntityID(b[416:])
 	fsd.NextExtent = NewExtentLong(b[448:])
-	return fsd
+	return fsd, nil
 }
 
 type FileIdentifierDescriptor struct {
@@ -232,18 +277,28 @@ func (fid *FileIdentifierDescriptor) Len() uint64 {
 	return 4 * ((l + 3) / 4)
 }
 
-func NewFileIdentifierDescriptor(b []byte) *FileIdentifierDescriptor {
+func NewFileIdentifierDescriptor(b []byte) (*FileIdentifierDescriptor, error) {
+	if err := checkLen(b, fileIdentifierDescriptorLen, "file identifier descriptor"); err != nil {
+		return nil, err
+	}
 	fid := &FileIdentifierDescriptor{}
-	fid.Descriptor = *NewDescriptor(b)
+	d, _ := NewDescriptor(b)
+	fid.Descriptor = *d
 	fid.FileVersionNumber = readU16LE(b[16:])
 	fid.FileCharacteristics = readU8(b[18:])
 	fid.LengthOfFileIdentifier = readU8(b[19:])
 	fid.ICB = NewExtentLong(b[20:])
 	fid.LengthOfImplementationUse = readU16LE(b[36:])
-	fid.ImplementationUse = NewEntityID(b[38:])
-	identStart := 38 + fid.LengthOfImplementationUse
-	fid.FileIdentifier = readDCharacters(b[identStart : fid.LengthOfFileIdentifier+uint8(identStart)])
-	return fid
+	identStart := fileIdentifierDescriptorLen + int(fid.LengthOfImplementationUse)
+	identEnd := identStart + int(fid.LengthOfFileIdentifier)
+	if len(b) < identEnd {
+		return nil, fmt.Errorf("file identifier descriptor runs to byte %d, have %d", identEnd, len(b))
+	}
+	if fid.LengthOfImplementationUse >= 32 {
+		fid.ImplementationUse = NewEntityID(b[38:])
+	}
+	fid.FileIdentifier = readDCharacters(b[identStart:identEnd])
+	return fid, nil
 }
 
 type FileEntry struct {
@@ -271,9 +326,13 @@ type FileEntry struct {
 	AllocationDescriptors         []Extent
 }
 
-func NewFileEntry(b []byte) *FileEntry {
+func NewFileEntry(b []byte) (*FileEntry, error) {
+	if err := checkLen(b, fileEntryLen, "file entry"); err != nil {
+		return nil, err
+	}
 	fe := &FileEntry{}
-	fe.Descriptor = *NewDescriptor(b)
+	d, _ := NewDescriptor(b)
+	fe.Descriptor = *d
 	fe.ICBTag = NewICBTag(b[16:])
 	fe.Uid = readU32LE(b[36:])
 	fe.Gid = readU32LE(b[40:])
@@ -293,15 +352,19 @@ func NewFileEntry(b []byte) *FileEntry {
 	fe.UniqueID = readU64LE(b[160:])
 	fe.LengthOfExtendedAttributes = readU32LE(b[168:])
 	fe.LengthOfAllocationDescriptors = readU32LE(b[172:])
-	allocDescStart := 176 + fe.LengthOfExtendedAttributes
-	fe.ExtendedAttributes = b[176:allocDescStart]
+	allocDescStart := uint64(fileEntryLen) + uint64(fe.LengthOfExtendedAttributes)
+	allocDescEnd := allocDescStart + uint64(fe.LengthOfAllocationDescriptors)
+	if uint64(len(b)) < allocDescEnd {
+		return nil, fmt.Errorf("file entry runs to byte %d, have %d", allocDescEnd, len(b))
+	}
+	fe.ExtendedAttributes = b[fileEntryLen:allocDescStart]
 
 	numDescriptors := fe.LengthOfAllocationDescriptors / 8
 	fe.AllocationDescriptors = make([]Extent, numDescriptors)
 	for i := range fe.AllocationDescriptors {
-		offset := allocDescStart + uint32(i)*8
+		offset := allocDescStart + uint64(i)*8
 		fe.AllocationDescriptors[i] = NewExtent(b[offset:])
 	}
 
-	return fe
+	return fe, nil
 }
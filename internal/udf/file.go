@@ -50,9 +50,13 @@ func (f *File) FileEntry() *FileEntry {
 		f.fileEntryPosition = f.fid.ICB.Location
 		feData, err := f.reader.ReadSector(f.reader.PartitionStart() + f.fileEntryPosition)
 		if err != nil {
-			return &FileEntry{}
+			return &FileEntry{ICBTag: &ICBTag{}}
 		}
-		f.fe = NewFileEntry(feData)
+		fe, err := NewFileEntry(feData)
+		if err != nil {
+			return &FileEntry{ICBTag: &ICBTag{}}
+		}
+		f.fe = fe
 	}
 	return f.fe
 }
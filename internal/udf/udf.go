@@ -7,6 +7,16 @@ import (
 
 const SectorSize = 2048
 
+const (
+	// maxVolumeDescriptorSectors bounds the walk of the main volume
+	// descriptor sequence when the anchor gives no usable length for it.
+	maxVolumeDescriptorSectors = 256
+
+	// maxDirectorySize caps the directory data read in one go; real
+	// directories are far smaller, and the length comes from the image.
+	maxDirectorySize = 64 << 20
+)
+
 type Reader struct {
 	r        io.ReaderAt
 	isInited bool
@@ -55,6 +65,15 @@ func (u *Reader) PartitionStart() uint64 {
 	return uint64(u.pd.PartitionStartingLocation)
 }
 
+// VolumeIdentifier is the primary volume's label, once the reader has
+// been initialised by Root.
+func (u *Reader) VolumeIdentifier() string {
+	if u.pvd == nil {
+		return ""
+	}
+	return u.pvd.VolumeIdentifier
+}
+
 func (u *Reader) init() error {
 	if u.isInited {
 		return nil
@@ -65,29 +84,43 @@ func (u *Reader) init() error {
 		return fmt.Errorf("failed to read anchor descriptor: %w", err)
 	}
 
-	anchorDesc := NewAnchorVolumeDescriptorPointer(anchorData)
+	anchorDesc, err := NewAnchorVolumeDescriptorPointer(anchorData)
+	if err != nil {
+		return err
+	}
 	if anchorDesc.Descriptor.TagIdentifier != DescriptorAnchorVolumePointer {
 		return fmt.Errorf("invalid anchor descriptor tag: 0x%x", anchorDesc.Descriptor.TagIdentifier)
 	}
 
-	for sector := uint64(anchorDesc.MainVolumeDescriptorSeq.Location); ; sector++ {
+	seq := anchorDesc.MainVolumeDescriptorSeq
+	count := uint64(seq.Length) / SectorSize
+	if count == 0 || count > maxVolumeDescriptorSectors {
+		count = maxVolumeDescriptorSectors
+	}
+	for sector := uint64(seq.Location); sector < uint64(seq.Location)+count; sector++ {
 		descData, err := u.ReadSector(sector)
 		if err != nil {
 			return fmt.Errorf("failed to read volume descriptor at sector %d: %w", sector, err)
 		}
 
-		desc := NewDescriptor(descData)
+		desc, err := NewDescriptor(descData)
+		if err != nil {
+			return err
+		}
 		if desc.TagIdentifier == DescriptorTerminating {
 			break
 		}
 
 		switch desc.TagIdentifier {
 		case DescriptorPrimaryVolume:
-			u.pvd = desc.PrimaryVolumeDescriptor()
+			u.pvd, err = desc.PrimaryVolumeDescriptor()
 		case DescriptorPartition:
-			u.pd = desc.PartitionDescriptor()
+			u.pd, err = desc.PartitionDescriptor()
 		case DescriptorLogicalVolume:
-			u.lvd = desc.LogicalVolumeDescriptor()
+			u.lvd, err = desc.LogicalVolumeDescriptor()
+		}
+		if err != nil {
+			return fmt.Errorf("volume descriptor at sector %d: %w", sector, err)
 		}
 	}
 
@@ -102,14 +135,18 @@ func (u *Reader) init() error {
 	if err != nil {
 		return fmt.Errorf("failed to read file set descriptor: %w", err)
 	}
-	u.fsd = NewFileSetDescriptor(fsdData)
+	if u.fsd, err = NewFileSetDescriptor(fsdData); err != nil {
+		return err
+	}
 
 	rootFESector := partitionStart + u.fsd.RootDirectoryICB.Location
 	rootFEData, err := u.ReadSector(rootFESector)
 	if err != nil {
 		return fmt.Errorf("failed to read root file entry: %w", err)
 	}
-	u.rootFE = NewFileEntry(rootFEData)
+	if u.rootFE, err = NewFileEntry(rootFEData); err != nil {
+		return fmt.Errorf("root directory: %w", err)
+	}
 
 	u.isInited = true
 	return nil
@@ -131,6 +168,9 @@ func (u *Reader) ReadDir(fe *FileEntry) ([]*File, error) {
 	ps := u.PartitionStart()
 	adPos := fe.AllocationDescriptors[0]
 	fdLen := uint64(adPos.Length)
+	if fdLen > maxDirectorySize {
+		return nil, fmt.Errorf("directory of %d bytes is too large", fdLen)
+	}
 
 	sectorsNeeded := (fdLen + SectorSize - 1) / SectorSize
 	fdBuf, err := u.ReadSectors(ps+uint64(adPos.Location), sectorsNeeded)
@@ -141,8 +181,11 @@ func (u *Reader) ReadDir(fe *FileEntry) ([]*File, error) {
 	var files []*File
 	fdOff := uint64(0)
 
-	for uint32(fdOff) < adPos.Length {
-		fid := NewFileIdentifierDescriptor(fdBuf[fdOff:])
+	for fdOff < fdLen {
+		fid, err := NewFileIdentifierDescriptor(fdBuf[fdOff:fdLen])
+		if err != nil {
+			return nil, fmt.Errorf("directory entry at offset %d: %w", fdOff, err)
+		}
 		if fid.FileIdentifier != "" {
 			files = append(files, &File{
 				reader: u,
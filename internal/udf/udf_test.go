@@ -0,0 +1,235 @@
+package udf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+const (
+	testPartition = 270
+	testContent   = "hello, world\n"
+)
+
+// testSectors are the sectors of testImage that hold metadata rather than
+// file data: the anchor, the volume descriptor sequence, and the file set
+// descriptor, file entries and directory inside the partition.
+var testSectors = []int{256, 257, 258, 259, 260, testPartition, testPartition + 1, testPartition + 2, testPartition + 3}
+
+func putTag(b []byte, id uint16) {
+	binary.LittleEndian.PutUint16(b[0:], id)
+	binary.LittleEndian.PutUint16(b[2:], 2)
+}
+
+// testImage is a minimal UDF volume with one file, /HELLO.TXT.
+func testImage() []byte {
+	img := make([]byte, (testPartition+5)*SectorSize)
+	sector := func(n int) []byte { return img[n*SectorSize : (n+1)*SectorSize] }
+	le32 := binary.LittleEndian.PutUint32
+	le16 := binary.LittleEndian.PutUint16
+
+	avdp := sector(256)
+	putTag(avdp, DescriptorAnchorVolumePointer)
+	le32(avdp[16:], 4*SectorSize)
+	le32(avdp[20:], 257)
+
+	pvd := sector(257)
+	putTag(pvd, DescriptorPrimaryVolume)
+	copy(pvd[24:], "\x08TESTVOL")
+	pvd[24+31] = 8
+
+	pd := sector(258)
+	putTag(pd, DescriptorPartition)
+	le32(pd[188:], testPartition)
+	le32(pd[192:], 5)
+
+	lvd := sector(259)
+	putTag(lvd, DescriptorLogicalVolume)
+	le32(lvd[212:], SectorSize)
+	le32(lvd[248:], SectorSize)
+
+	putTag(sector(260), DescriptorTerminating)
+
+	fsd := sector(testPartition)
+	putTag(fsd, DescriptorFileSet)
+	le32(fsd[400:], SectorSize)
+	le32(fsd[404:], 1)
+
+	dir := sector(testPartition + 2)
+	parent := dir[:40]
+	putTag(parent, DescriptorIdentifier)
+	parent[18] = 0x0a
+	le32(parent[20:], SectorSize)
+	le32(parent[24:], 1)
+	name := "\x08HELLO.TXT"
+	hello := dir[40:]
+	putTag(hello, DescriptorIdentifier)
+	hello[19] = byte(len(name))
+	le32(hello[20:], SectorSize)
+	le32(hello[24:], 3)
+	copy(hello[38:], name)
+	dirLen := 40 + 4*((38+len(name)+3)/4)
+
+	root := sector(testPartition + 1)
+	putTag(root, DescriptorFileEntry)
+	root[16+11] = 4
+	binary.LittleEndian.PutUint64(root[56:], uint64(dirLen))
+	le32(root[172:], 8)
+	le32(root[176:], uint32(dirLen))
+	le32(root[180:], 2)
+
+	file := sector(testPartition + 3)
+	putTag(file, DescriptorFileEntry)
+	file[16+11] = 5
+	le16(file[44:], 0x1084)
+	binary.LittleEndian.PutUint64(file[56:], uint64(len(testContent)))
+	le32(file[172:], 8)
+	le32(file[176:], uint32(len(testContent)))
+	le32(file[180:], 4)
+
+	copy(sector(testPartition+4), testContent)
+	return img
+}
+
+func TestReaderRoot(t *testing.T) {
+	u := NewReader(bytes.NewReader(testImage()))
+	files, err := u.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "HELLO.TXT" {
+		t.Fatalf("root = %v, want [HELLO.TXT]", files)
+	}
+	r, err := files[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(r)
+	if string(got) != testContent {
+		t.Errorf("content = %q, want %q", got, testContent)
+	}
+}
+
+func TestMalformedDescriptors(t *testing.T) {
+	fid := func(lenIU uint16, lenFI uint8, size int) []byte {
+		b := make([]byte, size)
+		putTag(b, DescriptorIdentifier)
+		b[19] = lenFI
+		binary.LittleEndian.PutUint16(b[36:], lenIU)
+		return b
+	}
+	fe := func(lenEA, lenAD uint32) []byte {
+		b := make([]byte, SectorSize)
+		putTag(b, DescriptorFileEntry)
+		binary.LittleEndian.PutUint32(b[168:], lenEA)
+		binary.LittleEndian.PutUint32(b[172:], lenAD)
+		return b
+	}
+	tests := []struct {
+		name  string
+		parse func() error
+	}{
+		{"short tag", func() error { _, err := NewDescriptor(make([]byte, 15)); return err }},
+		{"short anchor", func() error { _, err := NewAnchorVolumeDescriptorPointer(make([]byte, 24)); return err }},
+		{"short primary volume", func() error { _, err := NewPrimaryVolumeDescriptor(make([]byte, 400)); return err }},
+		{"short partition", func() error { _, err := NewPartitionDescriptor(make([]byte, 200)); return err }},
+		{"short logical volume", func() error { _, err := NewLogicalVolumeDescriptor(make([]byte, 432)); return err }},
+		{"short file set", func() error { _, err := NewFileSetDescriptor(make([]byte, 450)); return err }},
+		{"short file identifier", func() error { _, err := NewFileIdentifierDescriptor(make([]byte, 30)); return err }},
+		{"identifier past end", func() error { _, err := NewFileIdentifierDescriptor(fid(0, 20, 50)); return err }},
+		{"implementation use past end", func() error { _, err := NewFileIdentifierDescriptor(fid(300, 200, 512)); return err }},
+		{"short file entry", func() error { _, err := NewFileEntry(make([]byte, 100)); return err }},
+		{"extended attributes past end", func() error { _, err := NewFileEntry(fe(0xffffffff, 8)); return err }},
+		{"allocation descriptors past end", func() error { _, err := NewFileEntry(fe(0, 4096)); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.parse(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// FuzzDescriptor feeds one sector to every descriptor constructor; none may
+// panic whatever the bytes.
+func FuzzDescriptor(f *testing.F) {
+	img := testImage()
+	for _, n := range testSectors {
+		f.Add(img[n*SectorSize : (n+1)*SectorSize])
+	}
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		NewDescriptor(b)
+		NewAnchorVolumeDescriptorPointer(b)
+		NewPrimaryVolumeDescriptor(b)
+		NewPartitionDescriptor(b)
+		NewLogicalVolumeDescriptor(b)
+		NewFileSetDescriptor(b)
+		NewFileIdentifierDescriptor(b)
+		NewFileEntry(b)
+	})
+}
+
+// overlay is an image with one sector replaced.
+type overlay struct {
+	base   []byte
+	sector int64
+	data   []byte
+}
+
+func (o *overlay) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(o.base)) {
+		return 0, io.EOF
+	}
+	n := copy(p, o.base[off:])
+	start := o.sector * SectorSize
+	for i := 0; i < n; i++ {
+		if pos := off + int64(i); pos >= start && pos < start+SectorSize {
+			p[i] = o.data[pos-start]
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// FuzzReader replaces one metadata sector of testImage and walks the
+// volume; a corrupt image may fail to read but must not panic.
+func FuzzReader(f *testing.F) {
+	img := testImage()
+	for i, n := range testSectors {
+		f.Add(uint8(i), img[n*SectorSize:(n+1)*SectorSize])
+	}
+	f.Fuzz(func(t *testing.T, which uint8, b []byte) {
+		data := make([]byte, SectorSize)
+		copy(data, b)
+		u := NewReader(&overlay{base: img, sector: int64(testSectors[int(which)%len(testSectors)]), data: data})
+		files, err := u.Root()
+		if err != nil {
+			return
+		}
+		walk(files, 0)
+	})
+}
+
+func walk(files []*File, depth int) {
+	for _, f := range files {
+		_ = f.Name()
+		_ = f.Mode()
+		_ = f.ModTime()
+		if f.IsDir() {
+			if depth < 4 {
+				if children, err := f.ReadDir(); err == nil {
+					walk(children, depth+1)
+				}
+			}
+			continue
+		}
+		if r, err := f.Open(); err == nil {
+			io.Copy(io.Discard, io.LimitReader(r, 1<<20))
+		}
+	}
+}
@@ -16,6 +16,7 @@ const (
 	EventBootStarted      = "boot.started"
 	EventClientDiscovered = "client.discovered"
 	EventInventoryUpdated = "client.inventory_updated"
+	EventAlert            = "alert"
 )
 
 type Event struct {
@@ -54,7 +55,7 @@ func (n *Notifier) Fire(ev Event) {
 	if ev.Timestamp.IsZero() {
 		ev.Timestamp = time.Now().UTC()
 	}
-	go n.deliver(cfg.URL, ev)
+	go n.deliver(string(cfg.URL), ev)
 }
 
 func eventEnabled(cfg *models.WebhookConfig, event string) bool {
@@ -65,6 +66,8 @@ func eventEnabled(cfg *models.WebhookConfig, event string) bool {
 		return cfg.OnClientDiscovered
 	case EventInventoryUpdated:
 		return cfg.OnInventoryUpdated
+	case EventAlert:
+		return cfg.OnAlert
 	}
 	return false
 }
@@ -107,9 +107,34 @@ function logout() {
     document.getElementById('login-form').reset();
 }
 
+// With a client certificate the browser is already identified, so try
+// signing in with it before asking for a password.
+async function certificateLogin() {
+    try {
+        const res = await fetch(`${API_BASE}/login`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ auth_method: 'certificate' })
+        });
+        const data = await res.json();
+        if (!data.success) return false;
+        setToken(data.data.token);
+        localStorage.setItem('bootimus_username', data.data.username);
+        localStorage.setItem('bootimus_is_admin', data.data.is_admin);
+        return true;
+    } catch {
+        return false;
+    }
+}
+
 async function checkAuth() {
     const token = getToken();
     if (!token) {
+        if (location.protocol === 'https:' && await certificateLogin()) {
+            showApp();
+            initApp();
+            return;
+        }
         showLoginScreen();
         return;
     }
@@ -131,6 +156,23 @@ async function checkAuth() {
 let clients = [];
 let images = [];
 let currentClient = null;
+
+// Images and clients carry a version the server bumps on every update.
+// Sending the one the UI loaded makes the server answer 409 instead of
+// overwriting a change someone else made since.
+function imageVersion(filename) {
+    const img = (images || []).find(i => i.filename === filename);
+    return img ? img.version : undefined;
+}
+
+function clientVersion(mac) {
+    const c = (clients || []).find(c => c.mac_address === mac);
+    return c ? c.version : undefined;
+}
+
+function conflictNote(n) {
+    return n ? ` (${n} changed by someone else; reload and try again)` : '';
+}
 let imageSortColumn = 'name';
 let imageSortDirection = 'asc';
 let imageGroupedView = (() => {
@@ -313,7 +355,9 @@ function initApp() {
 
     loadCurrentUser();
     loadStats();
+    loadActivityChart();
     loadServerInfo();
+    loadLibraries();
     loadProfileCache();
     loadClients();
     loadImages();
@@ -321,6 +365,7 @@ function initApp() {
     loadLogs();
     loadUsers();
     loadActiveSessions();
+    loadActivity();
 
     // Refresh every 30 seconds
     setInterval(() => {
@@ -342,6 +387,7 @@ function initApp() {
 
     // Refresh active sessions more frequently (every 3 seconds)
     setInterval(loadActiveSessions, 3000);
+    setInterval(loadActivity, 10000);
 }
 
 // Initialize
@@ -378,7 +424,7 @@ function setupTabs() {
             if (item.dataset.tab === 'bootloaders') loadBootloaders();
             if (item.dataset.tab === 'profiles') loadProfiles();
             if (item.dataset.tab === 'autoinstall') loadAutoInstallFiles();
-            if (item.dataset.tab === 'boot-menu') loadTheme();
+            if (item.dataset.tab === 'boot-menu') { loadTheme(); loadMenuDraft(); loadRemoteMenus(); loadBranding(); }
             if (item.dataset.tab === 'settings') { loadUSBImages(); loadWebhookConfig(); }
             if (item.dataset.tab === 'api-reference') showAPIReference();
         });
@@ -408,6 +454,41 @@ async function loadStats() {
     }
 }
 
+// Boot activity chart, drawn from the hourly/daily rollups
+async function loadActivityChart() {
+    const container = document.getElementById('activity-chart');
+    const summary = document.getElementById('activity-summary');
+    const range = document.getElementById('activity-range').value;
+    try {
+        const res = await authFetch(`${API_BASE}/stats/timeseries?range=${range}`);
+        const data = await res.json();
+        if (!data.success) {
+            container.textContent = data.error || 'Failed to load boot activity';
+            return;
+        }
+        const points = data.data.points || [];
+        const max = Math.max(1, ...points.map(p => p.boots));
+        const width = 100 / Math.max(points.length, 1);
+        const label = p => data.data.period === 'hour'
+            ? new Date(p.start).toLocaleString([], { month: 'short', day: 'numeric', hour: '2-digit', minute: '2-digit' })
+            : new Date(p.start).toLocaleDateString();
+        const bars = points.map((p, i) => {
+            const okHeight = ((p.boots - p.failed_boots) / max) * 100;
+            const failHeight = (p.failed_boots / max) * 100;
+            const title = `${label(p)}: ${p.boots} boots, ${p.failed_boots} failed, ${p.active_clients} clients, ${formatBytes(p.bytes_served)}`;
+            return `<g><title>${escapeHtml(title)}</title>
+                <rect x="${i * width + width * 0.1}" y="${100 - okHeight - failHeight}" width="${width * 0.8}" height="${okHeight}" fill="var(--accent)"></rect>
+                <rect x="${i * width + width * 0.1}" y="${100 - failHeight}" width="${width * 0.8}" height="${failHeight}" fill="var(--danger)"></rect>
+            </g>`;
+        }).join('');
+        container.innerHTML = `<svg viewBox="0 0 100 100" preserveAspectRatio="none" style="width: 100%; height: 160px; display: block;">${bars}</svg>`;
+        const total = key => points.reduce((sum, p) => sum + p[key], 0);
+        summary.textContent = `${total('boots')} boots, ${total('failed_boots')} failed, ${formatBytes(total('bytes_served'))} served — per ${data.data.period}, peak ${max} boots`;
+    } catch (err) {
+        container.textContent = 'Failed to load boot activity';
+    }
+}
+
 // Active Sessions
 async function loadActiveSessions() {
     try {
@@ -462,6 +543,45 @@ function renderActiveSessions(sessions) {
     content.innerHTML = html || '<p style="color: var(--text-secondary);">No active sessions</p>';
 }
 
+// Jobs, disk tasks, queued next boots and scheduled runs; transfers are in
+// the active sessions panel.
+async function loadActivity() {
+    try {
+        const res = await authFetch(`${API_BASE}/activity`);
+        const data = await res.json();
+        if (!data.success) return;
+        const a = data.data;
+        const rows = [];
+        a.jobs.forEach(j => rows.push(['Job', `${escapeHtml(j.kind)} ${escapeHtml(j.target || '')}`, 'running']));
+        a.disk_tasks.forEach(t => {
+            const pct = t.bytes_total > 0 ? ` ${Math.round(t.bytes_done / t.bytes_total * 100)}%` : '';
+            rows.push(['Disk ' + escapeHtml(t.kind), escapeHtml(t.mac_address), escapeHtml(t.status) + pct]);
+        });
+        a.next_boots.forEach(b => rows.push([b.once ? 'One-shot boot' : 'Next boot', `${escapeHtml(b.name || b.mac)} &rarr; ${escapeHtml(b.image)}`, b.once ? 'on next boot, then local disk' : 'on next boot']));
+        a.scheduled.forEach(r => rows.push([
+            'Scheduled',
+            `${escapeHtml(r.name)}: ${escapeHtml(r.action)}${r.param ? ' ' + escapeHtml(r.param) : ''} &rarr; ${escapeHtml(r.group || 'no group')}`,
+            new Date(r.at).toLocaleString(),
+        ]));
+
+        const panel = document.getElementById('activity-panel');
+        if (rows.length === 0) {
+            panel.style.display = 'none';
+            return;
+        }
+        panel.style.display = 'block';
+        document.getElementById('activity-content').innerHTML = `
+            <table>
+                <tbody>
+                    ${rows.map(r => `<tr><td>${r[0]}</td><td>${r[1]}</td><td>${r[2]}</td></tr>`).join('')}
+                </tbody>
+            </table>
+        `;
+    } catch (err) {
+        console.error('Failed to load activity:', err);
+    }
+}
+
 function formatBytes(bytes) {
     if (!bytes || bytes === 0) return '0 B';
     const k = 1024;
@@ -521,6 +641,28 @@ async function powerClient(action) {
     }
 }
 
+async function moveSwitchport(state) {
+    const form = document.getElementById('edit-client-form');
+    const mac = form.querySelector('[name="mac_address"]').value;
+    const result = document.getElementById('switchport-client-result');
+    if (!mac) return;
+    result.textContent = `Moving to ${state}…`;
+    result.style.color = 'var(--text-secondary)';
+    try {
+        const res = await authFetch(`${API_BASE}/clients/switchport?mac=${encodeURIComponent(mac)}`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ state }),
+        });
+        const data = await res.json();
+        result.textContent = (data.success ? '✓ ' : '✗ ') + (data.message || data.error || '');
+        result.style.color = data.success ? 'var(--teal, green)' : 'var(--danger)';
+    } catch (err) {
+        result.textContent = '✗ ' + err.message;
+        result.style.color = 'var(--danger)';
+    }
+}
+
 async function powerStatusClient() {
     const form = document.getElementById('edit-client-form');
     const mac = form.querySelector('[name="mac_address"]').value;
@@ -699,6 +841,7 @@ async function loadWebhookConfig() {
         document.getElementById('webhook-on-boot-started').checked = !!c.on_boot_started;
         document.getElementById('webhook-on-client-discovered').checked = !!c.on_client_discovered;
         document.getElementById('webhook-on-inventory-updated').checked = !!c.on_inventory_updated;
+        document.getElementById('webhook-on-alert').checked = !!c.on_alert;
     } catch (err) {
         console.error('Failed to load webhook config:', err);
     }
@@ -712,6 +855,7 @@ async function saveWebhookConfig(e) {
         on_boot_started: document.getElementById('webhook-on-boot-started').checked,
         on_client_discovered: document.getElementById('webhook-on-client-discovered').checked,
         on_inventory_updated: document.getElementById('webhook-on-inventory-updated').checked,
+        on_alert: document.getElementById('webhook-on-alert').checked,
     };
     try {
         const res = await authFetch(`${API_BASE}/webhook`, {
@@ -766,6 +910,57 @@ async function downloadBackup() {
     }
 }
 
+const orphanLabels = {
+    boot_log_clients: 'Boot logs of deleted clients',
+    boot_log_images: 'Boot logs of deleted images',
+    client_images: 'Assignments of deleted images',
+    image_groups: 'Images in deleted groups',
+    client_groups: 'Clients in deleted groups',
+};
+
+async function checkOrphans() {
+    const el = document.getElementById('orphans-result');
+    const btn = document.getElementById('fix-orphans-btn');
+    el.textContent = 'Checking...';
+    try {
+        const res = await authFetch(`${API_BASE}/maintenance/orphans`);
+        const data = await res.json();
+        if (!data.success) {
+            el.textContent = '';
+            showAlert(data.error || 'Check failed', 'error');
+            return;
+        }
+        const found = data.data.filter(o => o.count > 0);
+        btn.disabled = found.length === 0;
+        if (found.length === 0) {
+            el.innerHTML = '<span style="color: var(--success);">No orphaned records found.</span>';
+            return;
+        }
+        el.innerHTML = '<table class="table"><thead><tr><th>Problem</th><th>Count</th><th>Fix</th></tr></thead><tbody>' +
+            found.map(o => `<tr><td>${escapeHtml(orphanLabels[o.kind] || o.kind)}</td><td>${o.count}</td><td>${escapeHtml(o.fix)}</td></tr>`).join('') +
+            '</tbody></table>';
+    } catch (err) {
+        el.textContent = '';
+        showAlert('Check failed: ' + err.message, 'error');
+    }
+}
+
+async function fixOrphans() {
+    if (!confirm('Fix all orphaned records? This cannot be undone.')) return;
+    try {
+        const res = await authFetch(`${API_BASE}/maintenance/orphans`, { method: 'POST' });
+        const data = await res.json();
+        if (!data.success) {
+            showAlert(data.error || 'Fix failed', 'error');
+            return;
+        }
+        showNotification(data.message, 'success');
+        checkOrphans();
+    } catch (err) {
+        showAlert('Fix failed: ' + err.message, 'error');
+    }
+}
+
 function downloadISOFromProperties() {
     const filename = document.getElementById('image-props-filename').value;
     if (!filename) return;
@@ -896,7 +1091,10 @@ function renderServerInfo(info) {
         ? envEntries.map(([key, value]) => `<div class="info-item"><span class="info-label">${key}</span><span class="info-value">${value}</span></div>`).join('')
         : `<p style="color: var(--text-muted); padding: 16px 0; font-size: 13px;">${t('server.env.empty')}</p>`;
 
+    const warnings = (info.warnings || []).map(w => `<p class="alert alert-error">${escapeHtml(w)}</p>`).join('');
+
     container.innerHTML = `
+        ${warnings}
         <div class="si-section">
             <h3 class="si-heading">${t('server.section.running_status')}</h3>
             <div class="rs-grid">${statusCards}</div>
@@ -1001,20 +1199,21 @@ function updateClientsBulkUI() {
 async function bulkSetClientsEnabled(enabled) {
     const macs = Array.from(selectedClientMacs);
     if (!macs.length) return;
-    let success = 0, fail = 0;
+    let success = 0, fail = 0, conflicts = 0;
     for (const mac of macs) {
         try {
             const res = await authFetch(`${API_BASE}/clients?mac=${encodeURIComponent(mac)}`, {
                 method: 'PUT',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ enabled }),
+                body: JSON.stringify({ enabled, version: clientVersion(mac) }),
             });
+            if (res.status === 409) conflicts++;
             const data = await res.json();
             if (data.success) success++; else fail++;
         } catch (e) { fail++; }
     }
     const verb = enabled ? 'Enabled' : 'Disabled';
-    showAlert(`${verb} ${success}${fail ? ', ' + fail + ' failed' : ''}`, fail ? 'error' : 'success');
+    showAlert(`${verb} ${success}${fail ? ', ' + fail + ' failed' : ''}${conflictNote(conflicts)}`, fail ? 'error' : 'success');
     selectedClientMacs.clear();
     await loadClients();
     loadStats();
@@ -1077,20 +1276,21 @@ async function confirmBulkAssignClientGroup() {
         return;
     }
     const raw = document.getElementById('bulk-assign-client-group-select').value;
-    const body = JSON.stringify({ client_group_id: raw === '' ? null : parseInt(raw, 10) });
-    let success = 0, fail = 0;
+    const groupId = raw === '' ? null : parseInt(raw, 10);
+    let success = 0, fail = 0, conflicts = 0;
     for (const mac of macs) {
         try {
             const res = await authFetch(`${API_BASE}/clients?mac=${encodeURIComponent(mac)}`, {
                 method: 'PUT',
                 headers: { 'Content-Type': 'application/json' },
-                body,
+                body: JSON.stringify({ client_group_id: groupId, version: clientVersion(mac) }),
             });
+            if (res.status === 409) conflicts++;
             const data = await res.json();
             if (data.success) success++; else fail++;
         } catch (e) { fail++; }
     }
-    showAlert(`Assigned ${success}${fail ? ', ' + fail + ' failed' : ''}`, fail ? 'error' : 'success');
+    showAlert(`Assigned ${success}${fail ? ', ' + fail + ' failed' : ''}${conflictNote(conflicts)}`, fail ? 'error' : 'success');
     selectedClientMacs.clear();
     closeModal('bulk-assign-client-group-modal');
     await loadClients();
@@ -1143,11 +1343,16 @@ function clientRowHTML(client, includeGroupCell) {
                     <tr class="row-clickable" onclick="editClient('${client.mac_address}')">
                         <td class="col-check" onclick="event.stopPropagation()"><input type="checkbox" ${selectedClientMacs.has(client.mac_address) ? 'checked' : ''} onchange="toggleClientSelection('${client.mac_address}', this.checked)"></td>
                         <td><code>${client.mac_address}</code></td>
-                        <td>${client.name || '-'}</td>
+                        <td>${client.name || '-'}${(client.tags || []).length ? '<br>' + client.tags.map(t => '<span class="badge badge-info">' + escapeHtml(t) + '</span>').join(' ') : ''}</td>
                         <td>
                             <span class="badge ${client.static ? 'badge-success' : 'badge-info'}">
                                 ${client.static ? 'Static' : 'Discovered'}
                             </span>
+                            ${client.trusted ? '<span class="badge badge-success" title="PCRs match the approved TPM baseline">Trusted</span>' :
+                                client.attest_status === 'pending' ? '<span class="badge badge-info" title="TPM enrollment awaiting approval">TPM pending</span>' :
+                                client.attest_status === 'failed' ? '<span class="badge badge-danger" title="' + escapeHtml(client.attest_error || '') + '">Attestation failed</span>' : ''}
+                            ${client.attest_failures ? '<span class="badge badge-warning" title="' + escapeHtml(client.attest_last_failure || '') + '">' + client.attest_failures + ' rejected quote' + (client.attest_failures === 1 ? '' : 's') + '</span>' : ''}
+                            ${client.registered_at ? '<br><small style="color: var(--text-secondary);" title="' + escapeHtml([(client.installed_ips || []).join(', '), client.installed_os, (client.disk_serials || []).length ? 'Disks: ' + client.disk_serials.join(', ') : ''].filter(Boolean).join('\n')).replace(/"/g, '&quot;') + '">Registered as ' + escapeHtml(client.installed_hostname || '?') + ' ' + new Date(client.registered_at).toLocaleString() + '</small>' : ''}
                         </td>
                         <td class="col-dot">
                             <span class="status-dot ${client.enabled ? 'on' : 'off'}" title="${client.enabled ? 'Enabled' : 'Disabled'}"></span>
@@ -1157,6 +1362,9 @@ function clientRowHTML(client, includeGroupCell) {
                                 '<span class="badge badge-info">' + escapeHtml(client.bootloader_set) + '</span>' :
                                 '<span style="color: var(--text-secondary);">Default</span>'
                             }
+                            ${client.last_bootloader ? '<br><small style="color: var(--text-secondary);" title="Last fetched over ' + escapeHtml(client.last_bootloader_via || '') + (client.last_bootloader_at ? ' at ' + new Date(client.last_bootloader_at).toLocaleString() : '') + '">' + escapeHtml(client.last_bootloader) + '</small>' : ''}
+                            ${client.firmware ? '<br><small style="color: var(--text-secondary);">' + (client.firmware === 'efi' ? 'UEFI' : 'BIOS') + (client.secure_boot ? ', Secure Boot' : '') + '</small>' : ''}
+                            ${(client.secure_boot_warnings || []).length > 0 ? ' <span class="badge badge-warning" title="' + escapeHtml(client.secure_boot_warnings.join('\n')).replace(/"/g, '&quot;') + '">Unsigned boot path</span>' : ''}
                         </td>${groupCell}
                         <td>
                             ${(client.images || []).length > 0 ?
@@ -1167,10 +1375,12 @@ function clientRowHTML(client, includeGroupCell) {
                         <td>${client.boot_count || 0}</td>
                         <td>
                             ${client.last_boot ? new Date(client.last_boot).toLocaleString() : 'Never'}
-                            ${client.next_boot_image ? '<br><span class="badge badge-info" title="' + escapeHtml(client.next_boot_image) + '">Next: ' + escapeHtml(client.next_boot_image) + '</span>' : ''}
+                            ${client.next_boot_image ? '<br><span class="badge badge-info" title="' + escapeHtml(client.next_boot_image) + '">' + (client.next_boot_once ? 'Once: ' : 'Next: ') + escapeHtml(client.next_boot_image) + '</span>' : ''}
+                            ${client.local_boot ? '<br><span class="badge badge-warning">Local disk</span>' : ''}
                         </td>
                         <td onclick="event.stopPropagation()">
                             ${!client.static ? '<button class="btn btn-success btn-sm" onclick="promoteClient(\'' + client.mac_address + '\')">Make Static</button>' : ''}
+                            ${client.attest_status === 'pending' ? '<button class="btn btn-success btn-sm" onclick="approveAttestation(\'' + client.mac_address + '\')">Approve TPM</button>' : ''}
                             <button class="btn btn-success btn-sm" onclick="wakeClient('${client.mac_address}')">Wake</button>
                             <button class="btn btn-primary btn-sm" onclick="showNextBoot('${client.mac_address}')">Next Boot</button>
                         </td>
@@ -1310,8 +1520,15 @@ async function editClient(mac) {
             form.querySelector('[name="mac_address"]').value = currentClient.mac_address || mac || '';
             form.querySelector('[name="name"]').value = currentClient.name || '';
             form.querySelector('[name="description"]').value = currentClient.description || '';
+            form.querySelector('[name="tags"]').value = (currentClient.tags || []).join(', ');
             form.querySelector('[name="enabled"]').checked = currentClient.enabled || false;
             form.querySelector('[name="show_public_images"]').checked = currentClient.show_public_images !== false;
+            form.querySelector('[name="lite_initrd"]').checked = !!currentClient.lite_initrd;
+            form.querySelector('[name="reserved_ip"]').value = currentClient.reserved_ip || '';
+            form.querySelector('[name="auto_install_vars"]').value = Object.entries(currentClient.auto_install_vars || {})
+                .sort(([a], [b]) => a.localeCompare(b)).map(([k, v]) => `${k}=${v}`).join('\n');
+            form.querySelector('[name="boot_immediately"]').checked = !!currentClient.boot_immediately;
+            form.querySelector('[name="local_boot"]').checked = !!currentClient.local_boot;
 
             // BMC / Redfish
             form.querySelector('[name="ipmi_host"]').value = currentClient.ipmi_host || '';
@@ -1322,6 +1539,17 @@ async function editClient(mac) {
             const powerResult = document.getElementById('power-client-result');
             if (powerResult) powerResult.textContent = '';
 
+            // Switch port
+            form.querySelector('[name="switch_name"]').value = currentClient.switch_name || '';
+            form.querySelector('[name="switch_port"]').value = currentClient.switch_port || '';
+            const switchResult = document.getElementById('switchport-client-result');
+            if (switchResult) {
+                switchResult.textContent = currentClient.switch_state
+                    ? `Last move: ${currentClient.switch_state}${currentClient.switch_vlan ? ' (VLAN ' + currentClient.switch_vlan + ')' : ''}${currentClient.switch_error ? ' — ' + currentClient.switch_error : ''}`
+                    : '';
+                switchResult.style.color = currentClient.switch_state === 'failed' ? 'var(--danger)' : 'var(--text-secondary)';
+            }
+
             // Populate bootloader set dropdown
             try {
                 const blRes = await authFetch(`${API_BASE}/bootloaders`);
@@ -1356,6 +1584,12 @@ async function editClient(mac) {
 
             await populateAutoInstallFileDropdown('edit-client-autoinstall-select', currentClient.auto_install_file);
 
+            const defaultSelect = document.getElementById('edit-client-default-image-select');
+            defaultSelect.innerHTML = '<option value="">(menu default)</option>' + images.map(img => {
+                const selected = currentClient.default_image_id === img.id ? 'selected' : '';
+                return `<option value="${img.id}" ${selected}>${escapeHtml(img.name)}</option>`;
+            }).join('');
+
             // Populate images select using allowed_images (persisted filename list)
             const select = document.getElementById('edit-images-select');
             const allowedImages = currentClient.allowed_images || [];
@@ -1513,6 +1747,72 @@ async function showInventoryHistory() {
     }
 }
 
+// Change history
+let changeHistoryView = null;
+
+async function showChangeHistory(type, key) {
+    changeHistoryView = { type, key: key || '' };
+    const title = key ? `History — ${key}` : `Recent ${type} changes`;
+    document.getElementById('change-history-title').textContent = title;
+    await loadChangeHistory();
+    openModal('change-history-modal');
+}
+
+function formatRevisionValue(v) {
+    if (v === undefined || v === null || v === '') return '—';
+    return typeof v === 'object' ? JSON.stringify(v) : String(v);
+}
+
+async function loadChangeHistory() {
+    const { type, key } = changeHistoryView;
+    const container = document.getElementById('change-history-list');
+    try {
+        const res = await authFetch(`${API_BASE}/revisions?type=${type}&key=${encodeURIComponent(key)}`);
+        const data = await res.json();
+        if (!data.success || !data.data || data.data.length === 0) {
+            container.innerHTML = '<p style="color: var(--text-secondary); padding: 20px;">No recorded changes.</p>';
+            return;
+        }
+        const latest = new Set();
+        container.innerHTML = `<div class="table-scroll" style="max-height: 500px;"><table>
+            <thead><tr><th>Time</th>${key ? '' : '<th>Record</th>'}<th>Change</th><th></th></tr></thead>
+            <tbody>${data.data.map(rev => {
+                const first = !latest.has(rev.entity_key);
+                latest.add(rev.entity_key);
+                const changes = rev.action === 'delete'
+                    ? '<em>Deleted</em>'
+                    : (rev.changes.length ? rev.changes.map(c => `<div><code>${escapeHtml(c.field)}</code>: ${escapeHtml(formatRevisionValue(c.from))} → ${escapeHtml(formatRevisionValue(c.to))}</div>`).join('') : '<span style="color: var(--text-secondary);">No visible change</span>');
+                const undo = first ? `<button class="btn btn-sm" onclick="revertChange('${escapeHtml(rev.entity_key).replace(/'/g, '&#39;')}')">Undo</button>` : '';
+                return `<tr>
+                    <td style="white-space: nowrap;">${new Date(rev.created_at).toLocaleString()}</td>
+                    ${key ? '' : `<td>${escapeHtml(rev.entity_key)}</td>`}
+                    <td>${changes}</td>
+                    <td>${undo}</td>
+                </tr>`;
+            }).join('')}</tbody></table></div>`;
+    } catch (err) {
+        container.innerHTML = '<p style="color: var(--text-secondary); padding: 20px;">Failed to load change history.</p>';
+    }
+}
+
+async function revertChange(key) {
+    const { type } = changeHistoryView;
+    if (!confirm(`Undo the last change to ${type} ${key}?`)) return;
+    try {
+        const res = await authFetch(`${API_BASE}/revisions/revert?type=${type}&key=${encodeURIComponent(key)}`, { method: 'POST' });
+        const data = await res.json();
+        if (data.success) {
+            showNotification(data.message, 'success');
+            loadChangeHistory();
+            if (type === 'image') loadImages(); else loadClients();
+        } else {
+            showNotification(data.error || 'Failed to undo change', 'error');
+        }
+    } catch (err) {
+        showNotification('Failed to undo change', 'error');
+    }
+}
+
 function deleteFromEditClient() {
     const form = document.getElementById('edit-client-form');
     const mac = form.querySelector('[name="mac_address"]').value;
@@ -1569,6 +1869,21 @@ async function promoteClient(mac) {
     }
 }
 
+async function approveAttestation(mac) {
+    try {
+        const res = await authFetch(`${API_BASE}/clients/attestation?mac=${encodeURIComponent(mac)}`, { method: 'POST' });
+        const data = await res.json();
+        if (data.success) {
+            showNotification('TPM enrollment approved', 'success');
+            loadClients();
+        } else {
+            showNotification(data.error || 'Failed to approve enrollment', 'error');
+        }
+    } catch (err) {
+        showNotification('Failed to approve enrollment', 'error');
+    }
+}
+
 async function showNextBoot(mac) {
     const client = clients.find(c => c.mac_address === mac);
     document.getElementById('next-boot-mac').value = mac + (client && client.name ? ' (' + client.name + ')' : '');
@@ -1579,6 +1894,8 @@ async function showNextBoot(mac) {
         `<option value="${img.filename}">${img.name}</option>`
     ).join('');
 
+    document.getElementById('next-boot-once').checked = !!(client && client.next_boot_once);
+
     const currentDiv = document.getElementById('next-boot-current');
     if (client && client.next_boot_image) {
         const img = images.find(i => i.filename === client.next_boot_image);
@@ -1598,7 +1915,7 @@ async function saveNextBoot() {
         const res = await authFetch(`${API_BASE}/clients/next-boot`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ mac_address: mac, image_filename: imageFilename })
+            body: JSON.stringify({ mac_address: mac, image_filename: imageFilename, once: document.getElementById('next-boot-once').checked })
         });
         const data = await res.json();
         if (data.success) {
@@ -1620,7 +1937,7 @@ async function saveNextBootAndWake() {
         const res = await authFetch(`${API_BASE}/clients/next-boot`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ mac_address: mac, image_filename: imageFilename })
+            body: JSON.stringify({ mac_address: mac, image_filename: imageFilename, once: document.getElementById('next-boot-once').checked })
         });
         const data = await res.json();
         if (data.success) {
@@ -1666,15 +1983,19 @@ async function clearNextBoot() {
 // Images
 async function loadImages() {
     try {
-        const [imagesRes, filesRes, groupsRes] = await Promise.all([
+        const [imagesRes, filesRes, groupsRes, rangesRes, suggestRes] = await Promise.all([
             authFetch(`${API_BASE}/images`),
             authFetch(`${API_BASE}/files`),
             authFetch(`${API_BASE}/groups`),
+            authFetch(`${API_BASE}/images/ranges`).catch(() => null),
+            authFetch(`${API_BASE}/images/kernel-suggestions`).catch(() => null),
         ]);
 
         const imagesData = await imagesRes.json();
         const filesData = await filesRes.json();
         const groupsData = await groupsRes.json();
+        const rangesData = rangesRes && rangesRes.ok ? await rangesRes.json() : null;
+        const suggestData = suggestRes && suggestRes.ok ? await suggestRes.json() : null;
 
         if (imagesData.success) {
             images = imagesData.data || [];
@@ -1687,6 +2008,26 @@ async function loadImages() {
                 });
             }
 
+            // What sanboot clients read of each ISO, for the extract hint.
+            if (rangesData && rangesData.success) {
+                const hints = {};
+                (rangesData.data || []).forEach(r => { if (r.recommendation) hints[r.filename] = r.recommendation; });
+                images.forEach(img => { img.range_hint = hints[img.filename] || ''; });
+            }
+
+            // Sanboot images that keep failing.
+            if (suggestData && suggestData.success) {
+                const failing = {};
+                (suggestData.data || []).forEach(s => {
+                    if (!s.flagged) return;
+                    const next = s.action === 'switch' ? 'Switch it to kernel boot.' :
+                        s.action === 'extract' ? `Extract it (profile ${s.profile}) and boot by kernel.` :
+                        'No distro profile covers it.';
+                    failing[s.filename] = s.reasons.join('; ') + '. ' + next;
+                });
+                images.forEach(img => { img.sanboot_failing = failing[img.filename] || ''; });
+            }
+
             // Cache groups so the tree view can build the parent hierarchy.
             if (groupsData && groupsData.success) {
                 groups = groupsData.data || [];
@@ -1756,12 +2097,31 @@ function getSortedImages() {
 
         if (aVal < bVal) return imageSortDirection === 'asc' ? -1 : 1;
         if (aVal > bVal) return imageSortDirection === 'asc' ? 1 : -1;
+        if (imageSortColumn === 'distro') {
+            // Within a distro, keep variants together with the newest release first.
+            return (a.variant || '').localeCompare(b.variant || '') ||
+                compareVersions(b.release_version, a.release_version);
+        }
         return 0;
     });
 
     return sorted;
 }
 
+function compareVersions(a, b) {
+    const as = (a || '').split('.'), bs = (b || '').split('.');
+    for (let i = 0; i < Math.max(as.length, bs.length); i++) {
+        const x = as[i] || '', y = bs[i] || '';
+        const xn = parseInt(x, 10), yn = parseInt(y, 10);
+        if (String(xn) === x && String(yn) === y) {
+            if (xn !== yn) return xn < yn ? -1 : 1;
+        } else if (x !== y) {
+            return x < y ? -1 : 1;
+        }
+    }
+    return 0;
+}
+
 const UNGROUPED_KEY = '__ungrouped__';
 
 function toggleImageGrouping() {
@@ -1875,6 +2235,12 @@ function getPreferredBootMethod(distro) {
 function computeImageHealth(img) {
     const preferred = getPreferredBootMethod(img.distro);
 
+    if (img.integrity_error) {
+        return { reason: `ISO integrity check failed: ${img.integrity_error}` };
+    }
+    if (img.iso_check_error) {
+        return { reason: `External ISO unreachable: ${img.iso_check_error}` };
+    }
     if (img.netboot_required && !img.netboot_available) {
         return { reason: 'Netboot files required' };
     }
@@ -1941,20 +2307,21 @@ function updateImagesBulkUI() {
 async function bulkSetImagesEnabled(enabled) {
     const filenames = Array.from(selectedImageFilenames);
     if (!filenames.length) return;
-    let success = 0, fail = 0;
+    let success = 0, fail = 0, conflicts = 0;
     for (const filename of filenames) {
         try {
             const res = await authFetch(`${API_BASE}/images?filename=${encodeURIComponent(filename)}`, {
                 method: 'PUT',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ enabled }),
+                body: JSON.stringify({ enabled, version: imageVersion(filename) }),
             });
+            if (res.status === 409) conflicts++;
             const data = await res.json();
             if (data.success) success++; else fail++;
         } catch (e) { fail++; }
     }
     const verb = enabled ? 'Enabled' : 'Disabled';
-    showAlert(`${verb} ${success}${fail ? ', ' + fail + ' failed' : ''}`, fail ? 'error' : 'success');
+    showAlert(`${verb} ${success}${fail ? ', ' + fail + ' failed' : ''}${conflictNote(conflicts)}`, fail ? 'error' : 'success');
     selectedImageFilenames.clear();
     await loadImages();
     loadStats();
@@ -1982,20 +2349,21 @@ async function confirmBulkAssignGroup() {
         return;
     }
     const raw = document.getElementById('bulk-assign-group-select').value;
-    const body = JSON.stringify({ group_id: raw === '' ? null : parseInt(raw, 10) });
-    let success = 0, fail = 0;
+    const groupId = raw === '' ? null : parseInt(raw, 10);
+    let success = 0, fail = 0, conflicts = 0;
     for (const filename of filenames) {
         try {
             const res = await authFetch(`${API_BASE}/images?filename=${encodeURIComponent(filename)}`, {
                 method: 'PUT',
                 headers: { 'Content-Type': 'application/json' },
-                body,
+                body: JSON.stringify({ group_id: groupId, version: imageVersion(filename) }),
             });
+            if (res.status === 409) conflicts++;
             const data = await res.json();
             if (data.success) success++; else fail++;
         } catch (e) { fail++; }
     }
-    showAlert(`Assigned ${success}${fail ? ', ' + fail + ' failed' : ''}`, fail ? 'error' : 'success');
+    showAlert(`Assigned ${success}${fail ? ', ' + fail + ' failed' : ''}${conflictNote(conflicts)}`, fail ? 'error' : 'success');
     selectedImageFilenames.clear();
     closeModal('bulk-assign-group-modal');
     await loadImages();
@@ -2087,6 +2455,14 @@ function dismissPendingUpload(filename) {
     renderImagesTable();
 }
 
+// Tooltip with what the ISO says about itself, read when it was added.
+function isoInfoTitle(img) {
+    const lines = [];
+    if (img.volume_label) lines.push('Volume: ' + img.volume_label);
+    if (img.release_info) lines.push('Release: ' + img.release_info);
+    return lines.length ? ` title="${escapeHtml(lines.join('\n')).replace(/"/g, '&quot;')}"` : '';
+}
+
 function imageRowHTML(img, includeGroupCell, depth = 0) {
     const groupCell = includeGroupCell ? `
                         <td>
@@ -2104,8 +2480,8 @@ function imageRowHTML(img, includeGroupCell, depth = 0) {
                     <tr class="${rowClass}"${rowTitle} onclick="showImagePropertiesModal('${img.filename}')">
                         <td class="col-check" onclick="event.stopPropagation()"><input type="checkbox" ${checked} onchange="toggleImageSelection('${img.filename}', this.checked)"></td>
                         <td class="col-logo">${distroLogoHTML(img.distro)}</td>
-                        <td${namePadStyle}>${img.name}</td>
-                        <td><code>${img.filename}</code></td>
+                        <td${namePadStyle}>${img.name}${img.kernel_arches && img.kernel_arches.length > 1 ? ' <span style="color: var(--text-secondary); font-size: 11px;" title="Kernels extracted for each architecture">' + escapeHtml(img.kernel_arches.join(' + ')) + '</span>' : img.arch ? ' <span style="color: var(--text-secondary); font-size: 11px;">' + escapeHtml(img.arch) + '</span>' : ''}${img.newer_release ? ' <span class="badge badge-warning" title="Newer release in the catalogue: ' + escapeHtml(img.newer_release).replace(/"/g, '&quot;') + '">Update</span>' : ''}</td>
+                        <td><code${isoInfoTitle(img)}>${img.filename}</code></td>
                         <td>${formatBytes(img.size)}</td>
                         <td>
                             ${img.extracted ?
@@ -2133,6 +2509,14 @@ function imageRowHTML(img, includeGroupCell, depth = 0) {
                                 ' <span title="'+escapeHtml(img.sanboot_hint)+'" style="color: #ff9800; cursor: help;">⚠</span>' :
                                 ''
                             }
+                            ${img.sanboot_failing && img.boot_method === 'sanboot' ?
+                                ' <span title="'+escapeHtml(img.sanboot_failing)+'" style="color: #f44336; cursor: help;">↯</span>' :
+                                ''
+                            }
+                            ${img.range_hint && img.boot_method === 'sanboot' ?
+                                ' <span title="'+escapeHtml(img.range_hint)+'" style="color: #ff9800; cursor: help;">◔</span>' :
+                                ''
+                            }
                             ${img.extracted && img.boot_method === 'sanboot' ?
                                 ' <button class="btn btn-sm" onclick="setBootMethod(\''+img.filename+'\', \'kernel\')">→ Kernel</button>' :
                                 ''
@@ -2292,13 +2676,16 @@ async function toggleImage(filename, currentState) {
         const res = await authFetch(`${API_BASE}/images?filename=${encodeURIComponent(filename)}`, {
             method: 'PUT',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ enabled: !currentState })
+            body: JSON.stringify({ enabled: !currentState, version: imageVersion(filename) })
         });
 
         const data = await res.json();
         if (data.success) {
             loadImages();
             loadStats();
+        } else if (res.status === 409) {
+            showAlert(data.error, 'error');
+            loadImages();
         }
     } catch (err) {
         showAlert('Failed to update image', 'error');
@@ -2310,12 +2697,15 @@ async function togglePublic(filename, currentState) {
         const res = await authFetch(`${API_BASE}/images?filename=${encodeURIComponent(filename)}`, {
             method: 'PUT',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ public: !currentState })
+            body: JSON.stringify({ public: !currentState, version: imageVersion(filename) })
         });
 
         const data = await res.json();
         if (data.success) {
             loadImages();
+        } else if (res.status === 409) {
+            showAlert(data.error, 'error');
+            loadImages();
         }
     } catch (err) {
         showAlert('Failed to update image', 'error');
@@ -2341,15 +2731,17 @@ async function deleteImage(filename, name) {
     }
 }
 
-async function scanImages() {
+async function scanImages(library) {
     try {
-        const res = await authFetch(`${API_BASE}/scan`, { method: 'POST' });
+        const query = library ? `?library=${encodeURIComponent(library)}` : '';
+        const res = await authFetch(`${API_BASE}/scan${query}`, { method: 'POST' });
         const data = await res.json();
 
         if (data.success) {
             showAlert(data.message, 'success');
             loadImages();
             loadStats();
+            loadLibraries();
         } else {
             showAlert(data.error || 'Scan failed', 'error');
         }
@@ -2358,6 +2750,34 @@ async function scanImages() {
     }
 }
 
+async function loadLibraries() {
+    const el = document.getElementById('libraries-list');
+    try {
+        const res = await authFetch(`${API_BASE}/libraries`);
+        const data = await res.json();
+        if (!data.success) {
+            el.textContent = data.error || 'Failed to load libraries';
+            return;
+        }
+        el.innerHTML = '<table class="table"><thead><tr><th>Library</th><th>Path</th><th>Priority</th><th>Images</th><th>Free</th><th></th></tr></thead><tbody>' +
+            data.data.map(lib => {
+                const free = lib.available
+                    ? `${formatBytes(lib.free)} of ${formatBytes(lib.total)}`
+                    : `<span style="color: var(--danger);">${escapeHtml(lib.error || 'Unavailable')}</span>`;
+                return `<tr>
+                    <td>${escapeHtml(lib.name)}</td>
+                    <td><code>${escapeHtml(lib.path)}</code>${lib.url ? `<br><small>cache of ${escapeHtml(lib.url)}${lib.cache_gb ? `, up to ${lib.cache_gb} GB` : ''}</small>` : ''}</td>
+                    <td>${lib.priority}</td>
+                    <td>${lib.images} (${formatBytes(lib.image_bytes)})</td>
+                    <td>${free}</td>
+                    <td><button class="btn btn-sm" type="button" onclick="scanImages('${escapeHtml(lib.name)}')"${lib.available ? '' : ' disabled'}>Scan</button></td>
+                </tr>`;
+            }).join('') + '</tbody></table>';
+    } catch (err) {
+        el.textContent = 'Failed to load libraries';
+    }
+}
+
 async function redetectFromProperties() {
     const filename = document.getElementById('image-props-filename').value;
     try {
@@ -2403,6 +2823,7 @@ function updateImagePropsProgress(filename) {
     const fill = document.getElementById('image-props-progress-fill');
     const text = document.getElementById('image-props-progress-text');
     const percent = document.getElementById('image-props-progress-percent');
+    const cancelBtn = document.getElementById('image-props-cancel-btn');
     const p = extractionProgress[filename];
 
     const actionBtns = ['image-props-extract-btn', 'image-props-patch-smb-btn', 'image-props-netboot-btn', 'image-props-download-btn', 'image-props-delete-btn'];
@@ -2412,6 +2833,7 @@ function updateImagePropsProgress(filename) {
         fill.style.width = p.progress + '%';
         text.textContent = p.status;
         if (percent) percent.textContent = Math.round(p.progress) + '%';
+        if (cancelBtn) cancelBtn.style.display = p.jobId ? '' : 'none';
         actionBtns.forEach(id => { const b = document.getElementById(id); if (b) b.disabled = true; });
     } else {
         container.style.display = 'none';
@@ -2446,49 +2868,76 @@ async function extractImage(filename, name) {
     extractionProgress[filename] = { progress: 0, status: 'Starting extraction...' };
     syncImagesProgress(filename);
 
+    const finish = (type, message) => {
+        delete extractionProgress[filename];
+        syncImagesProgress(filename);
+        showAlert(message, type);
+    };
+
+    let jobId;
+    try {
+        const res = await authFetch(`${API_BASE}/images/extract?filename=${encodeURIComponent(filename)}`, { method: 'POST' });
+        const data = await res.json();
+        if (!data.success) {
+            finish('error', data.error || 'Extraction failed');
+            return;
+        }
+        jobId = data.data.job_id;
+        extractionProgress[filename].jobId = jobId;
+    } catch (err) {
+        finish('error', 'Failed to extract image');
+        return;
+    }
+
+    // The extraction runs as a job on the server; follow it until it ends.
     const poll = setInterval(async () => {
         try {
-            const r = await authFetch(`${API_BASE}/images/extract-progress?filename=${encodeURIComponent(filename)}`);
+            const r = await authFetch(`${API_BASE}/jobs/${jobId}`);
             const d = await r.json();
             if (!d.success || !d.data) return;
-            const p = d.data;
-            if (p.status === 'running' || p.status === 'done') {
-                extractionProgress[filename] = {
-                    progress: Math.max(1, Math.round(p.percent || 0)),
-                    status: p.stage || t('props.action.extracting')
-                };
+            const job = d.data;
+            if (job.status === 'running') {
+                let status = job.stage || t('props.action.extracting');
+                if (job.files) status += ` (${job.files} files, ${formatBytes(job.bytes || 0)})`;
+                extractionProgress[filename] = { progress: Math.max(1, job.progress || 0), status, jobId };
                 syncImagesProgress(filename);
+                return;
+            }
+            clearInterval(poll);
+            if (job.status === 'done') {
+                extractionProgress[filename] = { progress: 100, status: 'Complete!' };
+                syncImagesProgress(filename);
+                setTimeout(async () => {
+                    delete extractionProgress[filename];
+                    await loadImages();
+                    refreshImagePropsIfOpenFor(filename);
+                    showAlert('Extraction successful', 'success');
+                }, 800);
+            } else if (job.status === 'cancelled') {
+                finish('info', 'Extraction cancelled');
+            } else {
+                finish('error', job.error || 'Extraction failed');
             }
         } catch (e) { /* ignore poll errors */ }
     }, 500);
+}
 
+async function cancelExtraction(filename) {
+    const p = extractionProgress[filename];
+    if (!p || !p.jobId) return;
     try {
-        const res = await authFetch(`${API_BASE}/images/extract?filename=${encodeURIComponent(filename)}`, { method: 'POST' });
+        const res = await authFetch(`${API_BASE}/jobs/${p.jobId}/cancel`, { method: 'POST' });
         const data = await res.json();
-        clearInterval(poll);
-
-        if (data.success) {
-            extractionProgress[filename] = { progress: 100, status: 'Complete!' };
-            syncImagesProgress(filename);
-            setTimeout(async () => {
-                delete extractionProgress[filename];
-                await loadImages();
-                refreshImagePropsIfOpenFor(filename);
-                showAlert(data.message || 'Extraction successful', 'success');
-            }, 800);
-        } else {
-            delete extractionProgress[filename];
-            syncImagesProgress(filename);
-            showAlert(data.error || 'Extraction failed', 'error');
-        }
+        if (!data.success) showAlert(data.error || 'Failed to cancel extraction', 'error');
     } catch (err) {
-        clearInterval(poll);
-        delete extractionProgress[filename];
-        syncImagesProgress(filename);
-        showAlert('Failed to extract image', 'error');
+        showAlert('Failed to cancel extraction', 'error');
     }
 }
 
+function cancelExtractionFromProperties() {
+    cancelExtraction(document.getElementById('image-props-filename').value);
+}
+
 async function downloadNetboot(filename, name) {
     if (!confirm(`Download netboot files for ${name}?\n\nThis will download and extract the proper network boot files required for Debian/Ubuntu network installation.`)) return;
 
@@ -2567,10 +3016,47 @@ async function cycleBootMethod(filename, currentMethod) {
         'nbd': 'nfs',
         'nfs': 'sanboot'
     };
-    const nextMethod = cycle[currentMethod] || 'sanboot';
+    const caps = await loadCapabilities();
+    const off = new Set(((caps && caps.boot_methods) || []).filter(m => !m.available).map(m => m.name));
+    let nextMethod = cycle[currentMethod] || 'sanboot';
+    while (off.has(nextMethod)) nextMethod = cycle[nextMethod];
     await setBootMethod(filename, nextMethod);
 }
 
+let serverCapabilities = null;
+
+// loadCapabilities fetches what this server supports once, or null when it
+// can't be fetched, in which case nothing is greyed out.
+async function loadCapabilities() {
+    if (serverCapabilities) return serverCapabilities;
+    try {
+        const res = await authFetch(`${API_BASE}/capabilities`);
+        const data = await res.json();
+        if (data.success) serverCapabilities = data.data;
+    } catch (e) {
+        // leave every action enabled
+    }
+    return serverCapabilities;
+}
+
+function applyBootMethodCapabilities(caps) {
+    const select = document.getElementById('image-props-boot-method');
+    if (!select || !caps) return;
+    for (const m of caps.boot_methods || []) {
+        let opt = select.querySelector(`option[value="${m.name}"]`);
+        if (!opt) {
+            // A boot method added by a provider built into this server.
+            opt = document.createElement('option');
+            opt.value = m.name;
+            opt.textContent = m.used_for ? `${m.name} (${m.used_for})` : m.name;
+            select.appendChild(opt);
+        }
+        // The image's current method stays selectable so saving keeps it.
+        opt.disabled = !m.available && select.value !== m.name;
+        opt.title = m.available ? '' : m.reason;
+    }
+}
+
 // Boot Logs
 async function loadLogs() {
     try {
@@ -2617,6 +3103,7 @@ function renderLogsTable(logs) {
                             <span class="badge ${log.success ? 'badge-success' : 'badge-danger'}">
                                 ${log.success ? 'Success' : 'Failed'}
                             </span>
+                            ${log.completed_at ? `<span class="badge badge-info" title="${escapeHtml([log.installed_hostname, log.installed_os].filter(Boolean).join(' - ') || 'Install finished')} at ${new Date(log.completed_at).toLocaleString()}">Installed</span>` : ''}
                         </td>
                         <td>${log.error_msg || '-'}</td>
                     </tr>
@@ -2669,12 +3156,15 @@ function setupForms() {
         const mac = formData.get('mac_address');
 
         const groupIdRaw = formData.get('client_group_id');
+        const defaultImageRaw = formData.get('default_image_id');
         const ipmiPortRaw = formData.get('ipmi_port');
         const updates = {
             name: formData.get('name'),
             description: formData.get('description'),
+            tags: (formData.get('tags') || '').split(',').map(t => t.trim()).filter(Boolean),
             enabled: formData.get('enabled') === 'on',
             show_public_images: formData.get('show_public_images') === 'on',
+            lite_initrd: formData.get('lite_initrd') === 'on',
             bootloader_set: formData.get('bootloader_set') || '',
             client_group_id: groupIdRaw ? parseInt(groupIdRaw, 10) : null,
             ipmi_host: formData.get('ipmi_host') || '',
@@ -2683,39 +3173,35 @@ function setupForms() {
             ipmi_password: formData.get('ipmi_password') || '',
             ipmi_insecure: formData.get('ipmi_insecure') === 'on',
             auto_install_file: formData.get('auto_install_file') || '',
+            auto_install_vars: parseKeyValueLines(formData.get('auto_install_vars') || ''),
+            reserved_ip: (formData.get('reserved_ip') || '').trim(),
+            switch_name: (formData.get('switch_name') || '').trim(),
+            switch_port: (formData.get('switch_port') || '').trim(),
+            default_image_id: defaultImageRaw ? parseInt(defaultImageRaw, 10) : null,
+            boot_immediately: formData.get('boot_immediately') === 'on',
+            local_boot: formData.get('local_boot') === 'on',
+            // Saved with the client in one transaction.
+            image_filenames: Array.from(document.getElementById('edit-images-select').selectedOptions)
+                .map(opt => opt.value),
+            version: currentClient ? currentClient.version : undefined,
         };
         console.log('Updating client:', mac, updates);
 
         try {
-            // Update client
-            const res1 = await authFetch(`${API_BASE}/clients?mac=${encodeURIComponent(mac)}`, {
+            const res = await authFetch(`${API_BASE}/clients?mac=${encodeURIComponent(mac)}`, {
                 method: 'PUT',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify(updates)
             });
+            const data = await res.json();
 
-            // Update image assignments
-            const selectedFilenames = Array.from(document.getElementById('edit-images-select').selectedOptions)
-                .map(opt => opt.value);
-
-            const res2 = await authFetch(`${API_BASE}/assign-images`, {
-                method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({
-                    mac_address: mac,
-                    image_filenames: selectedFilenames
-                })
-            });
-
-            const data1 = await res1.json();
-            const data2 = await res2.json();
-
-            if (data1.success && data2.success) {
+            if (data.success) {
                 showAlert('Client updated successfully', 'success');
                 closeModal('edit-client-modal');
                 loadClients();
             } else {
-                showAlert(data1.error || data2.error || 'Failed to update client', 'error');
+                // On a 409 nothing was saved; the form keeps the admin's edits.
+                showAlert(data.error || 'Failed to update client', 'error');
             }
         } catch (err) {
             showAlert('Failed to update client', 'error');
@@ -2731,25 +3217,34 @@ function setupForms() {
 
 const API_REFERENCE = [
     { category: 'Authentication', endpoints: [
-        { method: 'POST',   path: '/api/login',                    desc: 'Body: <code>{username, password}</code>. Returns JWT token.', publicAccess: true },
+        { method: 'POST',   path: '/api/login',                    desc: 'Body: <code>{username, password}</code>, or <code>{auth_method: "certificate"}</code> over mTLS. Returns JWT token.', publicAccess: true },
         { method: 'GET',    path: '/api/auth-info',                desc: 'Available auth backends.', publicAccess: true },
         { method: 'GET',    path: '/logout',                       desc: 'Redirect to login page.' },
         { method: 'GET',    path: '/health',                       desc: 'Liveness probe.', publicAccess: true },
     ]},
     { category: 'Server / Stats', endpoints: [
-        { method: 'GET',    path: '/api/server-info',              desc: 'Version, uptime, paths, network info.' },
+        { method: 'GET',    path: '/api/server-info',              desc: 'Version, uptime, paths, network info, and <code>warnings</code> such as an unreachable container address.' },
         { method: 'GET',    path: '/api/stats',                    desc: 'Counts: clients, images, boots.' },
+        { method: 'GET',    path: '/api/stats/timeseries',         desc: 'Query: <code>?range=24h|7d|30d…</code>, optional <code>period=hour|day</code>. Boots, failures, active clients and bytes served per bucket.' },
+        { method: 'GET',    path: '/api/stats/usage',              desc: 'Query: optional <code>?month=YYYY-MM</code> (default: this month so far), <code>format=md</code> for Markdown. Boots by distro and method, data served, images and features in use, as in the monthly reports under <code>&lt;data-dir&gt;/reports</code>.' },
         { method: 'GET',    path: '/api/active-sessions',          desc: 'Currently active boot sessions.' },
+        { method: 'GET',    path: '/api/dhcp/leases',              desc: 'Leases and reservations of the built-in DHCP server (<code>--dhcp</code>). DELETE with <code>?mac=</code> releases a lease.' },
+        { method: 'GET',    path: '/api/activity',                 desc: 'Transfers, running jobs and disk tasks, queued next boots and scheduled runs (?hours=24).' },
         { method: 'GET',    path: '/metrics',                      desc: 'Prometheus metrics.' },
     ]},
     { category: 'Clients', endpoints: [
         { method: 'GET',    path: '/api/clients',                  desc: 'List all clients. Add <code>?mac={mac}</code> for one.' },
         { method: 'POST',   path: '/api/clients',                  desc: 'Create static client. Body: <code>{mac_address, name, ...}</code>' },
-        { method: 'PUT',    path: '/api/clients?mac={mac}',        desc: 'Partial update. Any model field accepted.' },
+        { method: 'PUT',    path: '/api/clients?mac={mac}',        desc: 'Partial update. Any model field accepted; <code>image_filenames</code> also replaces the assigned images. Send <code>version</code> (or <code>If-Match</code>) to get a 409 if someone else changed it first.' },
         { method: 'DELETE', path: '/api/clients?mac={mac}',        desc: 'Delete client.' },
         { method: 'POST',   path: '/api/clients/wake?mac={mac}',   desc: 'Send Wake-on-LAN packet.' },
-        { method: 'POST',   path: '/api/clients/next-boot?mac={mac}', desc: 'Body: <code>{filename}</code>. One-shot next-boot image.' },
+        { method: 'POST',   path: '/api/clients/next-boot', desc: 'Body: <code>{mac_address, image_filename, once}</code>. One-shot next-boot image; with <code>once</code> it boots without the menu and the client boots from local disk afterwards.' },
         { method: 'POST',   path: '/api/clients/promote?mac={mac}', desc: 'Promote discovered client to static.' },
+        { method: 'GET',    path: '/api/clients/attestation?mac={mac}', desc: 'TPM enrollment of a client: status, EK/AK hashes and PCR baseline.' },
+        { method: 'POST',   path: '/api/clients/attestation?mac={mac}', desc: 'Approve the pending TPM enrollment; the client becomes trusted.' },
+        { method: 'DELETE', path: '/api/clients/attestation?mac={mac}', desc: 'Clear the TPM enrollment so the client must enrol again.' },
+        { method: 'GET',    path: '/api/clients/switchport?mac={mac}', desc: 'Switch port of a client, its last VLAN move and the configured switches.' },
+        { method: 'POST',   path: '/api/clients/switchport?mac={mac}', desc: 'Body: <code>{state}</code> (<code>provisioning</code> or <code>production</code>). Moves the client\'s access port by hand.' },
         { method: 'GET',    path: '/api/clients/inventory?mac={mac}', desc: 'Latest hardware inventory.' },
         { method: 'GET',    path: '/api/clients/inventory/history?mac={mac}', desc: 'Historical inventory submissions.' },
         { method: 'POST',   path: '/api/clients/power?mac={mac}',  desc: 'IPMI/Redfish power control. Body: <code>{action}</code> (on/off/reset).' },
@@ -2769,23 +3264,49 @@ const API_REFERENCE = [
     ]},
     { category: 'Images', endpoints: [
         { method: 'GET',    path: '/api/images',                   desc: 'List all images. Add <code>?filename={fn}</code> for one.' },
-        { method: 'PUT',    path: '/api/images?filename={fn}',     desc: 'Partial update. Fields: name, description, enabled, public, group_id, order, boot_method, distro, boot_params, auto_install_file.' },
-        { method: 'DELETE', path: '/api/images?filename={fn}',     desc: 'Delete image. Add <code>&delete_file=true</code> to also remove the ISO.' },
+        { method: 'PUT',    path: '/api/images?filename={fn}',     desc: 'Partial update. Fields: name, description, enabled, public, group_id, order, boot_method, distro, boot_params, auto_install_file, iscsi_enabled, visible_from, visible_until, visible_windows. Send <code>version</code> (or <code>If-Match</code>) to get a 409 if someone else changed it first.' },
+        { method: 'DELETE', path: '/api/images?filename={fn}',     desc: 'Delete image. Add <code>&delete_file=true</code> to also remove the ISO. 409 while variants exist.' },
         { method: 'POST',   path: '/api/images/upload',            desc: 'Multipart: <code>file</code>, <code>public</code>, <code>description</code>.' },
-        { method: 'POST',   path: '/api/images/download',          desc: 'Body: <code>{url, filename, description}</code>. filename is optional. Async download.' },
-        { method: 'POST',   path: '/api/images/extract?filename={fn}', desc: 'Extract kernel/initrd from ISO.' },
+        { method: 'POST',   path: '/api/images/clone',             desc: 'Body: <code>{source, name, description, boot_params, auto_install_script, auto_install_script_type, auto_install_file}</code>. Creates a variant sharing the source ISO.' },
+        { method: 'POST',   path: '/api/images/virtual',           desc: 'Body: <code>{name, kernel_url, initrd_url, boot_params, distro, cache_remote}</code>. Image with no ISO that boots remote URLs.' },
+        { method: 'POST',   path: '/api/images/external',          desc: 'Body: <code>{name, url, cache_remote, public, group_id}</code>. Image whose ISO stays on another HTTP server; checked with HEAD.' },
+        { method: 'POST',   path: '/api/images/oci',               desc: 'Body: <code>{ref, name, description, distro, boot_params, username, password, cosign_key, track}</code>. Pulls an ISO or kernel/initrd bundle from a registry as a job.' },
+        { method: 'POST',   path: '/api/images/oci/sync?filename={fn}', desc: 'Re-pull a registry image if its tag moved (<code>force=true</code> to always).' },
+        { method: 'POST',   path: '/api/images/download',          desc: 'Body: <code>{url, filename, description, connections, sha256, md5, rate_limit}</code>. filename is optional; connections (default 4, max 16) sets parallel range segments; sha256/md5 are checked when the download finishes; rate_limit (e.g. <code>5MB</code>) caps this download\'s bandwidth. Queued while <code>--max-downloads</code> others run.' },
+        { method: 'POST',   path: '/api/image-specs/apply',        desc: 'Body: image specs as YAML or JSON, or empty to apply <code>data/image-specs/</code>. Downloads, verifies, extracts and configures each image; returns one job per spec.' },
+        { method: 'POST',   path: '/api/replicate',                desc: 'Body: <code>{peer, token, filename}</code>. Pushes an image (ISO, extracted files, settings, auto-install file) to another bootimus server in a <code>replicate</code> job.' },
+        { method: 'GET',    path: '/api/replicate/file',           desc: 'Receiving end: <code>?filename=&amp;path=&amp;sha256=</code> reports how much of a pushed file is here. PUT appends a chunk at <code>&amp;offset=</code>; POST verifies it against <code>&amp;sha256=</code> and moves it into place.' },
+        { method: 'POST',   path: '/api/replicate/image',          desc: 'Receiving end: creates or updates the image record for a pushed ISO.' },
+        { method: 'GET',    path: '/api/images/ranges',            desc: 'Which byte ranges of each ISO sanboot clients (HTTP and NBD) have read: <code>?filename=</code> for one image. Includes the merged regions, the fraction of the ISO read and, when clients read little of it, a recommendation to extract it. DELETE <code>?filename=</code> clears them.' },
+        { method: 'POST',   path: '/api/images/ranges/prefetch?filename={fn}', desc: 'Reads the regions sanboot clients use of the ISO into the page cache in a <code>prefetch</code> job. This also happens by itself when a sanboot session starts.' },
+        { method: 'GET',    path: '/api/images/kernel-suggestions', desc: 'Sanboot images that could boot by kernel: already extracted (<code>action: switch</code>) or matching a distro profile (<code>action: extract</code>), with their sanboot boots and failures over the last 30 days. Images that fail often are <code>flagged</code> and listed first.' },
+        { method: 'GET',    path: '/api/policy',                   desc: 'Returns the boot policy rules (<code>{path, source, error}</code>). PUT with the rules as the body replaces them; rules that do not parse are refused with the line at fault.' },
+        { method: 'POST',   path: '/api/policy/test',              desc: 'Body: <code>{mac, ip, time, arch, tags, source}</code>. Evaluates the boot policy (or the rules in <code>source</code>) for a client and returns the decision with a per-rule trace.' },
+        { method: 'GET',    path: '/api/security/bans',            desc: 'Lists IPs banned from the admin port and IPs locked out after failed logins. POST <code>{ip, duration, reason}</code> bans an IP (no duration: permanently); DELETE <code>?ip=</code> lifts a ban or lockout.' },
+        { method: 'POST',   path: '/api/images/extract?filename={fn}', desc: 'Extract kernel/initrd from ISO in the background. Returns <code>202</code> with the <code>job_id</code> to follow.' },
         { method: 'GET',    path: '/api/images/extract-progress?filename={fn}', desc: 'Extraction progress.' },
         { method: 'POST',   path: '/api/images/redetect?filename={fn}', desc: 'Re-run distro detection and boot-param resolution.' },
         { method: 'POST',   path: '/api/images/patch-smb?filename={fn}', desc: 'Patch boot.wim for Windows SMB install.' },
         { method: 'POST',   path: '/api/images/boot-method?filename={fn}', desc: 'Body: <code>{method}</code> (sanboot/kernel/nbd/nfs).' },
         { method: 'POST',   path: '/api/images/netboot/download?filename={fn}', desc: 'Fetch netboot kernel/initrd from distro mirror.' },
+        { method: 'GET',    path: '/api/images/netboot/status?filename={fn}', desc: 'Installed netboot checksum vs upstream. <code>stale</code> when a newer tarball is published.' },
+        { method: 'POST',   path: '/api/images/initrd-lite?filename={fn}', desc: 'Body (optional): <code>{strip, level}</code>. Repacks the extracted initrd without the listed firmware classes, recompressed with zstd, in an <code>initrd-lite</code> job. DELETE removes it.' },
+        { method: 'GET',    path: '/api/netboot/sources',           desc: 'Official netboot tarballs per distro/release.' },
+        { method: 'GET',    path: '/api/jobs',                      desc: 'Recent jobs, kept across restarts. Filter with <code>?kind=</code>, <code>?target=</code>, <code>?status=</code>.' },
+        { method: 'GET',    path: '/api/jobs/{id}/log',             desc: 'Plain-text job log. <code>?since=N</code> skips already-read lines.' },
+        { method: 'GET',    path: '/api/jobs/{id}',                 desc: 'Job status. Extractions add <code>stage</code>, <code>files</code>, <code>bytes</code> and <code>total_bytes</code>.' },
+        { method: 'POST',   path: '/api/jobs/{id}/cancel',          desc: 'Cancel a running job that reports <code>cancellable</code>.' },
+        { method: 'POST',   path: '/api/jobs/{id}/retry',           desc: 'Run a failed, cancelled or interrupted job that reports <code>retryable</code> again.' },
         { method: 'GET',    path: '/api/images/autoinstall?filename={fn}', desc: 'Get auto-install script for image.' },
         { method: 'POST',   path: '/api/images/autoinstall?filename={fn}', desc: 'Body: <code>{script, type, enabled}</code>' },
         { method: 'POST',   path: '/api/assign-images',            desc: 'Body: <code>{mac_address, image_filenames[]}</code>' },
-        { method: 'POST',   path: '/api/scan',                     desc: 'Scan filesystem for new ISOs.' },
+        { method: 'POST',   path: '/api/scan',                     desc: 'Scan filesystem for new ISOs. Query (optional): <code>?library=</code> to scan one library.' },
+        { method: 'GET',    path: '/api/libraries',                desc: 'ISO libraries in search order with image count and free space.' },
         { method: 'GET',    path: '/api/isos',                     desc: 'List ISO files on disk.' },
         { method: 'GET',    path: '/api/downloads',                desc: 'List active downloads.' },
         { method: 'GET',    path: '/api/downloads/progress?filename={fn}', desc: 'Download progress.' },
+        { method: 'GET',    path: '/api/downloads/queue',          desc: 'Download workers running and their maximum, downloads waiting, and the shared and per-download bandwidth caps (bytes/s, 0 for none).' },
+        { method: 'GET',    path: '/api/uploads',                  desc: 'In-flight ISO uploads as received by the server (bytes, rate, ETA). <code>?filename=</code> for one.' },
     ]},
     { category: 'Image Groups', endpoints: [
         { method: 'GET',    path: '/api/groups',                   desc: 'List image groups.' },
@@ -2799,7 +3320,13 @@ const API_REFERENCE = [
         { method: 'POST',   path: '/api/bootloaders/upload',       desc: 'Multipart: <code>set</code>, <code>files[]</code>.' },
         { method: 'DELETE', path: '/api/bootloaders/delete?set={name}', desc: 'Delete whole set, or add <code>&name={file}</code> for one file.' },
         { method: 'POST',   path: '/api/bootloaders/select',       desc: 'Body: <code>{set}</code>. Set active set.' },
+        { method: 'GET',    path: '/api/bootloaders/served',       desc: 'NBPs of the active set as served to clients: source (custom/update/embedded), SHA-256, size.' },
+        { method: 'GET',    path: '/api/bootloaders/update',       desc: 'Installed signed bootloader update and trusted key IDs.' },
+        { method: 'POST',   path: '/api/bootloaders/update',       desc: 'Multipart: <code>bundle</code> (.tar.gz), <code>signature</code> (Ed25519, detached). Verified before install.' },
+        { method: 'DELETE', path: '/api/bootloaders/update',       desc: 'Remove the update and serve the embedded bootloaders again.' },
+        { method: 'GET',    path: '/api/matchbox',                 desc: 'Matchbox profiles and groups (including Tinkerbell hardware) served on /ipxe, /ignition, /cloud, /generic and /metadata, with load errors.' },
         { method: 'GET',    path: '/api/usb',                      desc: 'List bundled USB boot images.' },
+        { method: 'GET',    path: '/api/bootstrap-media?format={usb|iso|efi|arm64}', desc: 'Streams a ready-to-dd USB image, a UEFI-bootable CD image, or an iPXE <code>.efi</code> for an existing EFI partition, whose embedded script boots straight into this server\'s menu without DHCP boot options. The boot token and failover URLs are written in.' },
     ]},
     { category: 'Tools', endpoints: [
         { method: 'GET',    path: '/api/tools',                    desc: 'List tools.' },
@@ -2811,13 +3338,19 @@ const API_REFERENCE = [
         { method: 'POST',   path: '/api/tools/custom',             desc: 'Body: <code>{name, display_name, ...}</code>. Create custom tool.' },
         { method: 'DELETE', path: '/api/tools/custom/delete?name={tool}', desc: 'Delete custom tool.' },
         { method: 'POST',   path: '/api/tools/update',             desc: 'Refresh tools catalog from remote.' },
+        { method: 'GET',    path: '/api/loaders/memdisk',          desc: 'Memdisk loader status: present, size, sha256.' },
+        { method: 'POST',   path: '/api/loaders/memdisk',          desc: 'Body: <code>{url}</code> (optional) or multipart <code>file</code>. Install the memdisk loader.' },
+        { method: 'DELETE', path: '/api/loaders/memdisk',          desc: 'Remove the memdisk loader.' },
     ]},
     { category: 'Distro Profiles', endpoints: [
         { method: 'GET',    path: '/api/profiles',                 desc: 'List distro profiles.' },
         { method: 'POST',   path: '/api/profiles/save',            desc: 'Create/update custom profile.' },
         { method: 'DELETE', path: '/api/profiles/delete?id={profile_id}', desc: 'Delete custom profile.' },
         { method: 'POST',   path: '/api/profiles/update',          desc: 'Refresh built-in profiles from remote.' },
+        { method: 'GET',    path: '/api/capabilities',             desc: 'Distro layouts extraction recognises, filesystems, boot methods whose server is on, and which external tools (wimlib-imagex, bsdtar, 7z, ...) are installed, with why not.' },
         { method: 'GET',    path: '/api/iso-catalog',              desc: 'Curated catalog of popular distros + mirror URLs (drives the Get Images modal).' },
+        { method: 'GET',    path: '/api/catalog',                  desc: 'Same as <code>/api/iso-catalog</code>, with custom sources and release sha256s after a refresh.' },
+        { method: 'POST',   path: '/api/catalog/refresh',          desc: 'Fetch the latest catalog, the custom <code>catalog.sources</code> and release checksum files now.' },
     ]},
     { category: 'Auto-Install Files', endpoints: [
         { method: 'GET',    path: '/api/autoinstall-files',        desc: 'List files.' },
@@ -2838,6 +3371,9 @@ const API_REFERENCE = [
         { method: 'POST',   path: '/api/drivers/upload',           desc: 'Multipart: <code>file</code>, <code>image_id</code>.' },
         { method: 'DELETE', path: '/api/drivers/delete?id={id}',   desc: 'Delete pack.' },
         { method: 'POST',   path: '/api/drivers/rebuild?image_id={id}', desc: 'Rebuild boot.wim with driver packs.' },
+        { method: 'GET',    path: '/api/drivers/backups',          desc: 'Pristine boot.wim copies kept by driver rebuilds, with size and age.' },
+        { method: 'DELETE', path: '/api/drivers/backups?older_than_days={n}', desc: 'Delete backups older than <code>n</code> days; the injected boot.wim becomes the new baseline.' },
+        { method: 'POST',   path: '/api/drivers/backups/restore?imageId={id}', desc: 'Restore the pristine boot.wim, undoing driver injection, and drop the backup.' },
     ]},
     { category: 'Webhooks', endpoints: [
         { method: 'GET',    path: '/api/webhook',                  desc: 'Get webhook config.' },
@@ -2859,9 +3395,28 @@ const API_REFERENCE = [
         { method: 'POST',   path: '/api/users/reset-password?id={id}', desc: 'Body: <code>{new_password}</code>' },
     ]},
     { category: 'Settings', endpoints: [
-        { method: 'GET',    path: '/api/theme',                    desc: 'Menu theme + defaults.' },
-        { method: 'PUT',    path: '/api/theme',                    desc: 'Body: <code>{title, menu_timeout, default_menu_item}</code>' },
+        { method: 'GET',    path: '/api/settings/menu',            desc: 'Boot menu settings. <code>/api/theme</code> is the same endpoint.' },
+        { method: 'PUT',    path: '/api/settings/menu',            desc: 'Body: <code>{title, menu_timeout, default_menu_item, text_colour, background_colour, selected_text_colour, selected_background_colour, motd}</code>. Colours are iPXE basic colour names.' },
+        { method: 'GET',    path: '/api/menu/draft',               desc: 'Whether a menu draft is open and what it changes.' },
+        { method: 'POST',   path: '/api/menu/draft',               desc: 'Open a draft; clients keep the current menu until publish.' },
+        { method: 'DELETE', path: '/api/menu/draft',               desc: 'Discard the draft and restore the published menu.' },
+        { method: 'POST',   path: '/api/menu/draft/publish',       desc: 'Publish the draft to all clients.' },
+        { method: 'GET',    path: '/api/menu/draft/preview',       desc: 'Query: <code>?mac=</code>. The iPXE menu that MAC would get from the draft.' },
+        { method: 'GET',    path: '/api/remote-menus',             desc: 'Menu entries that chain to iPXE scripts on other servers, with their last check.' },
+        { method: 'POST',   path: '/api/remote-menus',             desc: 'Body: <code>{name, url, client_groups, client_tags, client_macs, enabled, hide_unreachable}</code>. Add one; <code>PUT ?id=</code> updates. Checked on save.' },
+        { method: 'DELETE', path: '/api/remote-menus',             desc: 'Query: <code>?id=</code>. Remove a remote menu.' },
+        { method: 'POST',   path: '/api/remote-menus/check',       desc: 'Query: <code>?id=</code>. Check a remote menu is reachable now.' },
+        { method: 'GET',    path: '/api/revisions',                desc: 'Query: <code>?type=image|client&key=</code>. Recorded edits and deletes, newest first, with the fields each changed.' },
+        { method: 'POST',   path: '/api/revisions/revert',         desc: 'Query: <code>?type=image|client&key=</code>. Undo the latest change to that record.' },
+        { method: 'GET',    path: '/api/branding',                 desc: 'Custom logo, console banner and stylesheet currently set.' },
+        { method: 'POST',   path: '/api/branding',                 desc: 'Multipart: <code>logo</code>, <code>banner</code> (PNG), <code>css</code> and/or a <code>bundle</code> zip.' },
+        { method: 'DELETE', path: '/api/branding',                 desc: 'Query: <code>?asset=logo|banner|css|all</code>.' },
         { method: 'GET',    path: '/api/backup/export',            desc: 'Export full DB backup as JSON.' },
+        { method: 'GET',    path: '/api/maintenance/orphans',      desc: 'Boot logs, assignments and group memberships pointing at deleted records, by kind.' },
+        { method: 'POST',   path: '/api/maintenance/orphans',      desc: 'Body (optional): <code>{kinds: [...]}</code>. Fix orphaned records; all kinds by default.' },
+        { method: 'GET',    path: '/api/maintenance/integrity',    desc: 'Result of the last checksum verification of every ISO, with a summary by status.' },
+        { method: 'POST',   path: '/api/maintenance/integrity',    desc: 'Query: <code>filename</code> (optional). Rehash one or every local ISO now in a job.' },
+        { method: 'POST',   path: '/api/images/verify',            desc: 'Same as <code>POST /api/maintenance/integrity</code>: re-check one (<code>?filename=</code>) or every ISO against its stored sha256/md5.' },
     ]},
     { category: 'Logs', endpoints: [
         { method: 'GET',    path: '/api/logs',                     desc: 'Boot log entries.' },
@@ -2869,7 +3424,7 @@ const API_REFERENCE = [
         { method: 'GET',    path: '/api/logs/buffer',              desc: 'Recent in-memory log buffer.' },
     ]},
     { category: 'Public Boot Endpoints (no auth)', endpoints: [
-        { method: 'GET',    path: '/menu.ipxe',                    desc: 'Generated iPXE menu script.', publicAccess: true },
+        { method: 'GET',    path: '/menu.ipxe',                    desc: 'Generated iPXE menu script. With an admin token and an <code>X-Bootimus-As-MAC</code> header (or <code>?as_mac=</code>), returns what that client would get, with the boot policy trace as comments; <code>X-Bootimus-As-IP</code> / <code>?as_ip=</code> sets its address. Nothing pending is consumed.', publicAccess: true },
         { method: 'GET',    path: '/autoexec.ipxe',                desc: 'iPXE autoexec for chainloaded bootloader.', publicAccess: true },
         { method: 'POST',   path: '/inventory',                    desc: 'iPXE-submitted hardware inventory.', publicAccess: true },
         { method: 'GET',    path: '/isos/{filename}',              desc: 'Direct ISO download.', publicAccess: true },
@@ -3009,12 +3564,17 @@ function renderAPIReference(filter) {
 // Theme
 async function loadTheme() {
     try {
-        const res = await authFetch(`${API_BASE}/theme`);
+        const res = await authFetch(`${API_BASE}/settings/menu`);
         const data = await res.json();
         if (data.success) {
             document.getElementById('theme-title').value = data.data.title || '';
             document.getElementById('theme-timeout').value = data.data.menu_timeout != null ? data.data.menu_timeout : 30;
             document.getElementById('theme-default-item').value = data.data.default_menu_item || '';
+            document.getElementById('theme-text-colour').value = data.data.text_colour || '';
+            document.getElementById('theme-background-colour').value = data.data.background_colour || '';
+            document.getElementById('theme-selected-text-colour').value = data.data.selected_text_colour || '';
+            document.getElementById('theme-selected-background-colour').value = data.data.selected_background_colour || '';
+            document.getElementById('theme-motd').value = data.data.motd || '';
         }
     } catch (err) {
         console.error('Failed to load theme:', err);
@@ -3027,9 +3587,14 @@ async function saveTheme(e) {
         title: document.getElementById('theme-title').value,
         menu_timeout: parseInt(document.getElementById('theme-timeout').value) || 0,
         default_menu_item: document.getElementById('theme-default-item').value,
+        text_colour: document.getElementById('theme-text-colour').value,
+        background_colour: document.getElementById('theme-background-colour').value,
+        selected_text_colour: document.getElementById('theme-selected-text-colour').value,
+        selected_background_colour: document.getElementById('theme-selected-background-colour').value,
+        motd: document.getElementById('theme-motd').value,
     };
     try {
-        const res = await authFetch(`${API_BASE}/theme`, {
+        const res = await authFetch(`${API_BASE}/settings/menu`, {
             method: 'PUT',
             headers: {'Content-Type': 'application/json'},
             body: JSON.stringify(theme),
@@ -3045,6 +3610,270 @@ async function saveTheme(e) {
     }
 }
 
+// Menu draft
+async function loadMenuDraft() {
+    try {
+        const res = await authFetch(`${API_BASE}/menu/draft`);
+        const data = await res.json();
+        if (!data.success) return;
+        const active = data.data.active;
+        const changes = data.data.changes || [];
+        document.getElementById('menu-draft-status').textContent = active
+            ? `Draft open since ${new Date(data.data.created_at).toLocaleString()} — ${changes.length} change${changes.length === 1 ? '' : 's'}.`
+            : 'No draft open.';
+        document.getElementById('menu-draft-changes').innerHTML = changes.map(c => `<li>${escapeHtml(c)}</li>`).join('');
+        document.getElementById('menu-draft-open').style.display = active ? 'none' : '';
+        document.getElementById('menu-draft-publish').style.display = active ? '' : 'none';
+        document.getElementById('menu-draft-discard').style.display = active ? '' : 'none';
+    } catch (err) {
+        console.error('Failed to load menu draft:', err);
+    }
+}
+
+let remoteMenus = [];
+
+async function loadRemoteMenus() {
+    const el = document.getElementById('remote-menus-list');
+    try {
+        const res = await authFetch(`${API_BASE}/remote-menus`);
+        const data = await res.json();
+        if (!data.success) {
+            el.innerHTML = `<p class="alert alert-error">${escapeHtml(data.error || 'Failed to load remote menus')}</p>`;
+            return;
+        }
+        remoteMenus = data.data || [];
+        if (remoteMenus.length === 0) {
+            el.innerHTML = '<p style="color: var(--text-secondary);">No remote menus.</p>';
+            return;
+        }
+        let html = '<table><thead><tr><th>Name</th><th>URL</th><th>Shown to</th><th>Status</th><th></th></tr></thead><tbody>';
+        for (const m of remoteMenus) {
+            const scope = [...(m.client_groups || []).map(g => 'group ' + g), ...(m.client_tags || []).map(t => 'tag ' + t), ...(m.client_macs || [])];
+            let status = '<span class="badge badge-disabled">Unchecked</span>';
+            if (m.reachable === true) status = '<span class="badge badge-success">Reachable</span>';
+            if (m.reachable === false) status = `<span class="badge badge-danger" title="${escapeHtml(m.check_error || '')}">Unreachable</span>`;
+            if (!m.enabled) status += ' <span class="badge badge-disabled">Disabled</span>';
+            html += `<tr>
+                <td>${escapeHtml(m.name)}</td>
+                <td><code>${escapeHtml(m.url)}</code></td>
+                <td>${scope.length ? escapeHtml(scope.join(', ')) : 'Everyone'}</td>
+                <td>${status}</td>
+                <td style="white-space: nowrap;">
+                    <button class="btn btn-sm" onclick="checkRemoteMenu(${m.id})">Check</button>
+                    <button class="btn btn-sm" onclick="editRemoteMenu(${m.id})">Edit</button>
+                    <button class="btn btn-sm btn-danger" onclick="deleteRemoteMenu(${m.id})">Delete</button>
+                </td>
+            </tr>`;
+        }
+        el.innerHTML = html + '</tbody></table>';
+    } catch (err) {
+        el.innerHTML = `<p class="alert alert-error">Failed to load remote menus: ${escapeHtml(err.message)}</p>`;
+    }
+}
+
+function parseKeyValueLines(text) {
+    const vars = {};
+    for (const line of text.split('\n')) {
+        const i = line.indexOf('=');
+        if (i > 0) vars[line.slice(0, i).trim()] = line.slice(i + 1).trim();
+    }
+    return vars;
+}
+
+function splitList(value) {
+    return value.split(',').map(v => v.trim()).filter(v => v);
+}
+
+function resetRemoteMenuForm() {
+    document.getElementById('remote-menu-form').reset();
+    document.getElementById('remote-menu-id').value = '';
+}
+
+function editRemoteMenu(id) {
+    const m = remoteMenus.find(m => m.id === id);
+    if (!m) return;
+    document.getElementById('remote-menu-id').value = m.id;
+    document.getElementById('remote-menu-name').value = m.name;
+    document.getElementById('remote-menu-url').value = m.url;
+    document.getElementById('remote-menu-groups').value = (m.client_groups || []).join(', ');
+    document.getElementById('remote-menu-tags').value = (m.client_tags || []).join(', ');
+    document.getElementById('remote-menu-macs').value = (m.client_macs || []).join(', ');
+    document.getElementById('remote-menu-enabled').checked = m.enabled;
+    document.getElementById('remote-menu-hide').checked = m.hide_unreachable;
+}
+
+async function saveRemoteMenu(e) {
+    e.preventDefault();
+    const id = document.getElementById('remote-menu-id').value;
+    const body = {
+        name: document.getElementById('remote-menu-name').value,
+        url: document.getElementById('remote-menu-url').value,
+        client_groups: splitList(document.getElementById('remote-menu-groups').value),
+        client_tags: splitList(document.getElementById('remote-menu-tags').value),
+        client_macs: splitList(document.getElementById('remote-menu-macs').value),
+        enabled: document.getElementById('remote-menu-enabled').checked,
+        hide_unreachable: document.getElementById('remote-menu-hide').checked,
+    };
+    try {
+        const res = await authFetch(`${API_BASE}/remote-menus${id ? '?id=' + id : ''}`, {
+            method: id ? 'PUT' : 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(body),
+        });
+        const data = await res.json();
+        if (data.success) {
+            showAlert(data.message, data.data.reachable ? 'success' : 'warning');
+            resetRemoteMenuForm();
+        } else {
+            showAlert(data.error || 'Failed to save remote menu', 'error');
+        }
+    } catch (err) {
+        showAlert('Failed to save remote menu', 'error');
+    }
+    loadRemoteMenus();
+}
+
+async function checkRemoteMenu(id) {
+    try {
+        const res = await authFetch(`${API_BASE}/remote-menus/check?id=${id}`, { method: 'POST' });
+        const data = await res.json();
+        if (!data.success) {
+            showAlert(data.error || 'Check failed', 'error');
+        } else if (data.data.reachable) {
+            showAlert(`${data.data.name} is reachable`, 'success');
+        } else {
+            showAlert(`${data.data.name} is unreachable: ${data.data.check_error}`, 'error');
+        }
+    } catch (err) {
+        showAlert('Check failed', 'error');
+    }
+    loadRemoteMenus();
+}
+
+async function deleteRemoteMenu(id) {
+    if (!confirm('Delete this remote menu?')) return;
+    try {
+        const res = await authFetch(`${API_BASE}/remote-menus?id=${id}`, { method: 'DELETE' });
+        const data = await res.json();
+        showAlert(data.success ? data.message : (data.error || 'Failed to delete remote menu'), data.success ? 'success' : 'error');
+    } catch (err) {
+        showAlert('Failed to delete remote menu', 'error');
+    }
+    loadRemoteMenus();
+}
+
+async function menuDraftAction(path, method, failure) {
+    try {
+        const res = await authFetch(`${API_BASE}${path}`, { method });
+        const data = await res.json();
+        if (data.success) {
+            showAlert(data.message, 'success');
+        } else {
+            showAlert(data.error || failure, 'error');
+        }
+    } catch (err) {
+        showAlert(failure, 'error');
+    }
+    loadMenuDraft();
+}
+
+function openMenuDraft() {
+    menuDraftAction('/menu/draft', 'POST', 'Failed to open menu draft');
+}
+
+function publishMenuDraft() {
+    if (!confirm('Publish the draft menu to all clients?')) return;
+    menuDraftAction('/menu/draft/publish', 'POST', 'Failed to publish menu draft');
+}
+
+function discardMenuDraft() {
+    if (!confirm('Discard the draft and put the menu back as published?')) return;
+    menuDraftAction('/menu/draft', 'DELETE', 'Failed to discard menu draft');
+}
+
+async function previewMenuDraft() {
+    const mac = document.getElementById('menu-draft-mac').value.trim();
+    if (!mac) {
+        showAlert('Enter a MAC address to preview', 'error');
+        return;
+    }
+    const out = document.getElementById('menu-draft-preview');
+    try {
+        const res = await authFetch(`${API_BASE}/menu/draft/preview?mac=${encodeURIComponent(mac)}`);
+        if (!res.ok) {
+            const data = await res.json();
+            showAlert(data.error || 'Failed to preview menu', 'error');
+            return;
+        }
+        out.textContent = await res.text();
+        out.style.display = '';
+    } catch (err) {
+        showAlert('Failed to preview menu', 'error');
+    }
+}
+
+// Branding
+async function loadBranding() {
+    const container = document.getElementById('branding-assets');
+    try {
+        const res = await authFetch(`${API_BASE}/branding`);
+        const data = await res.json();
+        const assets = (data.success && data.data) || [];
+        if (assets.length === 0) {
+            container.innerHTML = '<p style="color: var(--text-secondary); margin: 0;">Using the default Bootimus look.</p>';
+            return;
+        }
+        const labels = { logo: 'Logo', banner: 'Console banner', css: 'Stylesheet' };
+        container.innerHTML = assets.map(a => `
+            <div style="display: flex; align-items: center; gap: 10px; margin-bottom: 6px;">
+                <strong style="min-width: 120px;">${labels[a.asset] || escapeHtml(a.asset)}</strong>
+                <a href="${a.url}" target="_blank" rel="noopener">${escapeHtml(a.url)}</a>
+                <span style="color: var(--text-secondary);">${formatBytes(a.size)}</span>
+                <button type="button" class="btn btn-sm btn-danger" onclick="removeBranding('${a.asset}')">Remove</button>
+            </div>`).join('');
+    } catch (err) {
+        console.error('Failed to load branding:', err);
+    }
+}
+
+async function uploadBranding(e) {
+    e.preventDefault();
+    const form = e.target;
+    const body = new FormData();
+    for (const input of form.querySelectorAll('input[type="file"]')) {
+        if (input.files.length > 0) body.append(input.name, input.files[0]);
+    }
+    if ([...body.keys()].length === 0) {
+        showAlert('Choose a file to upload', 'error');
+        return;
+    }
+    try {
+        const res = await authFetch(`${API_BASE}/branding`, { method: 'POST', body });
+        const data = await res.json();
+        if (data.success) {
+            showAlert('Branding updated; reload the page to see a new logo or stylesheet', 'success');
+            form.reset();
+        } else {
+            showAlert(data.error || 'Failed to upload branding', 'error');
+        }
+    } catch (err) {
+        showAlert('Failed to upload branding', 'error');
+    }
+    loadBranding();
+}
+
+async function removeBranding(asset) {
+    if (!confirm(asset === 'all' ? 'Remove all branding?' : `Remove the ${asset}?`)) return;
+    try {
+        const res = await authFetch(`${API_BASE}/branding?asset=${asset}`, { method: 'DELETE' });
+        const data = await res.json();
+        showAlert(data.success ? data.message : (data.error || 'Failed to remove branding'), data.success ? 'success' : 'error');
+    } catch (err) {
+        showAlert('Failed to remove branding', 'error');
+    }
+    loadBranding();
+}
+
 // Tools
 // Distro Profiles
 async function loadProfiles() {
@@ -3175,7 +4004,75 @@ async function updateToolsFromRemote() {
     }
 }
 
+async function loadMemdisk() {
+    const el = document.getElementById('memdisk-status');
+    try {
+        const res = await authFetch(`${API_BASE}/loaders/memdisk`);
+        const data = await res.json();
+        if (!data.success) {
+            el.textContent = data.error || 'Failed to load memdisk status';
+            return;
+        }
+        const st = data.data;
+        document.getElementById('memdisk-delete-btn').disabled = !st.present;
+        const opt = document.getElementById('memdisk-boot-option');
+        opt.disabled = !st.present;
+        opt.textContent = st.present ? 'Memdisk' : 'Memdisk (install the loader first)';
+        el.innerHTML = st.present
+            ? `<span style="color: var(--success);">Installed</span> &middot; ${formatBytes(st.size)} &middot; sha256 <code>${escapeHtml(st.sha256.slice(0, 16))}</code> &middot; updated ${new Date(st.updated).toLocaleString()}`
+            : `<span style="color: var(--danger);">Not installed</span> &middot; memdisk tools are hidden from the boot menu`;
+    } catch (err) {
+        el.textContent = 'Failed to load memdisk status: ' + err.message;
+    }
+}
+
+async function installMemdisk(body, headers) {
+    const el = document.getElementById('memdisk-status');
+    el.textContent = 'Installing...';
+    try {
+        const res = await authFetch(`${API_BASE}/loaders/memdisk`, { method: 'POST', headers, body });
+        const data = await res.json();
+        if (!data.success) {
+            showAlert(data.error || 'Install failed', 'error');
+        } else {
+            showNotification(data.message, 'success');
+        }
+    } catch (err) {
+        showAlert('Install failed: ' + err.message, 'error');
+    }
+    loadMemdisk();
+}
+
+function downloadMemdisk() {
+    installMemdisk(JSON.stringify({}), { 'Content-Type': 'application/json' });
+}
+
+function uploadMemdisk(input) {
+    if (!input.files.length) return;
+    const form = new FormData();
+    form.append('file', input.files[0]);
+    input.value = '';
+    installMemdisk(form);
+}
+
+async function deleteMemdisk() {
+    if (!confirm('Remove the memdisk loader? Memdisk tools will disappear from the boot menu.')) return;
+    try {
+        const res = await authFetch(`${API_BASE}/loaders/memdisk`, { method: 'DELETE' });
+        const data = await res.json();
+        if (!data.success) {
+            showAlert(data.error || 'Remove failed', 'error');
+        } else {
+            showNotification(data.message, 'success');
+        }
+    } catch (err) {
+        showAlert('Remove failed: ' + err.message, 'error');
+    }
+    loadMemdisk();
+}
+
 async function loadTools() {
+    loadMemdisk();
     try {
         const res = await authFetch(`${API_BASE}/tools`);
         const data = await res.json();
@@ -3612,17 +4509,31 @@ async function deleteBootloaderFile(setName, fileName) {
 }
 
 // USB Images
+async function downloadUSBImage(name) {
+    await downloadAdminFile(`${API_BASE}/usb/download?name=${encodeURIComponent(name)}`, name);
+}
+
+// Boot media with this server's address written in, named by the server.
+async function downloadBootMedia(format) {
+    await downloadAdminFile(`${API_BASE}/bootstrap-media?format=${format}`, 'bootimus.' + (format === 'usb' || format === 'iso' ? format : 'efi'));
+}
+
 // Pulls the file via authFetch (Bearer token) and triggers a download.
 // Plain <a href> can't carry the auth header so direct navigation 401s.
-async function downloadUSBImage(name) {
+async function downloadAdminFile(path, name) {
     try {
-        const res = await authFetch(`${API_BASE}/usb/download?name=${encodeURIComponent(name)}`);
-        if (!res.ok) throw new Error('HTTP ' + res.status);
+        const res = await authFetch(path);
+        if (!res.ok) {
+            const data = await res.json().catch(() => null);
+            throw new Error(data && data.error ? data.error : 'HTTP ' + res.status);
+        }
         const blob = await res.blob();
         const url = URL.createObjectURL(blob);
         const a = document.createElement('a');
         a.href = url;
-        a.download = name;
+        const disposition = res.headers.get('Content-Disposition') || '';
+        const match = disposition.match(/filename="([^"]+)"/);
+        a.download = match ? match[1] : name;
         document.body.appendChild(a);
         a.click();
         a.remove();
@@ -3655,11 +4566,22 @@ async function loadUSBImages() {
                 <td><button type="button" class="btn btn-sm btn-primary" onclick="downloadUSBImage('${escapeHtml(img.name).replace(/'/g, "\\'")}')">Download</button></td>
             </tr>`;
         }
+        html += `<tr>
+                <td>bootimus.usb, preconfigured for this server</td>
+                <td>—</td>
+                <td>UEFI</td>
+                <td>
+                    <button type="button" class="btn btn-sm btn-primary" onclick="downloadBootMedia('usb')">Download</button>
+                    <button type="button" class="btn btn-sm" onclick="downloadBootMedia('iso')" title="The same, as a CD image for optical drives and virtual media">.iso</button>
+                    <button type="button" class="btn btn-sm" onclick="downloadBootMedia('efi')" title="iPXE for an existing EFI partition, as EFI/BOOT/BOOTX64.EFI">x86_64 .efi</button>
+                    <button type="button" class="btn btn-sm" onclick="downloadBootMedia('arm64')" title="iPXE for an existing EFI partition, as EFI/BOOT/BOOTAA64.EFI">arm64 .efi</button>
+                </td>
+            </tr>`;
         html += '</tbody></table></div>';
         html += `<div style="margin-top: 15px; padding: 15px; background: var(--bg-secondary); border-radius: 8px; color: var(--text-secondary); font-size: 13px;">
             <strong style="color: var(--accent);">Writing to USB:</strong><br>
             <code style="color: var(--text-primary);">sudo dd if=bootimus.usb of=/dev/sdX bs=4M status=progress</code><br><br>
-            Replace <code>/dev/sdX</code> with your USB device. The USB boots iPXE which uses DHCP to find bootimus automatically.
+            Replace <code>/dev/sdX</code> with your USB device. The stock image uses DHCP to find bootimus. The preconfigured one goes straight to this server, so it also works where DHCP does not name a boot server.
         </div>`;
         container.innerHTML = html;
     } catch (err) {
@@ -3731,10 +4653,12 @@ function setupUpload() {
         renderImagesTable();
 
         const xhr = new XMLHttpRequest();
+        const stopPolling = pollUploadProgress(filename);
+        xhr.addEventListener('loadend', stopPolling);
         xhr.upload.addEventListener('progress', (event) => {
             if (!event.lengthComputable) return;
             const op = pendingUploads.get(filename);
-            if (!op) return;
+            if (!op || op.serverProgress) return;
             op.progress = (event.loaded / event.total) * 100;
             op.status = `${op.progress.toFixed(1)}% · ${formatBytes(event.loaded)}/${formatBytes(event.total)}`;
             updateUploadRowDOM(filename);
@@ -3784,10 +4708,36 @@ function setupUpload() {
         xhr.open('POST', `${API_BASE}/images/upload`);
         const token = getToken();
         if (token) xhr.setRequestHeader('Authorization', 'Bearer ' + token);
+        xhr.setRequestHeader('X-Upload-Size', String(file.size));
         xhr.send(formData);
     });
 }
 
+// The browser's upload events count bytes handed to the network stack, which
+// runs far ahead of the server on multi-GB files; poll what has actually
+// been written instead and fall back to the browser's figure until then.
+function pollUploadProgress(filename) {
+    const timer = setInterval(async () => {
+        try {
+            const res = await authFetch('/api/uploads?filename=' + encodeURIComponent(filename));
+            if (!res.ok) return;
+            const data = await res.json();
+            const op = pendingUploads.get(filename);
+            if (!data.success || !op || op.error) return;
+            const p = data.data;
+            op.serverProgress = true;
+            op.progress = Math.min(p.percentage, 100);
+            let status = `${op.progress.toFixed(1)}% · ${formatBytes(p.downloaded_bytes)}/${formatBytes(p.total_bytes)}`;
+            if (p.speed) status += ` · ${p.speed}`;
+            if (p.eta_seconds) status += ` · ${Math.ceil(p.eta_seconds / 60)} min left`;
+            if (p.status === 'completed') status = 'Processing…';
+            op.status = status;
+            updateUploadRowDOM(filename);
+        } catch (_) { /* keep the browser's estimate */ }
+    }, 2000);
+    return () => clearInterval(timer);
+}
+
 function showUploadModal() {
     document.getElementById('upload-form').reset();
     document.getElementById('file-name').textContent = '';
@@ -4165,7 +5115,8 @@ document.getElementById('add-group-form').addEventListener('submit', async funct
         description: formData.get('description'),
         parent_id: formData.get('parent_id') ? parseInt(formData.get('parent_id')) : null,
         order: parseInt(formData.get('order')) || 0,
-        enabled: formData.get('enabled') === 'on'
+        enabled: formData.get('enabled') === 'on',
+        pin_protected: formData.get('pin_protected') === 'on'
     };
 
     try {
@@ -4199,7 +5150,8 @@ document.getElementById('edit-group-form').addEventListener('submit', async func
         description: formData.get('description'),
         parent_id: formData.get('parent_id') ? parseInt(formData.get('parent_id')) : null,
         order: parseInt(formData.get('order')) || 0,
-        enabled: formData.get('enabled') === 'on'
+        enabled: formData.get('enabled') === 'on',
+        pin_protected: formData.get('pin_protected') === 'on'
     };
 
     try {
@@ -4257,27 +5209,32 @@ function renderGetImagesList(filter) {
     if (!container) return;
     const q = (filter || '').trim().toLowerCase();
     let html = '';
-    for (const distro of (isoCatalog.distros || [])) {
+    if (isoCatalog.refreshed_at) {
+        html += `<p style="color: var(--text-secondary); font-size: 12px; margin: 0 0 8px;">Refreshed ${escapeHtml(new Date(isoCatalog.refreshed_at).toLocaleString())}${(isoCatalog.errors || []).length ? ' &middot; <span style="color: var(--warning);" title="' + escapeHtml(isoCatalog.errors.join('\n')) + '">' + isoCatalog.errors.length + ' source(s) failed</span>' : ''}</p>`;
+    }
+    const distros = isoCatalog.distros || [];
+    for (let di = 0; di < distros.length; di++) {
+        const distro = distros[di];
         const matching = (distro.releases || []).filter(r =>
             !q || distro.name.toLowerCase().includes(q) || r.label.toLowerCase().includes(q) || distro.id.toLowerCase().includes(q)
         );
         if (matching.length === 0) continue;
-        html += `<div class="get-iso-distro"><h3>${escapeHtml(distro.name)}</h3>`;
+        html += `<div class="get-iso-distro"><h3>${escapeHtml(distro.name)}${distro.source ? ' <span style="color: var(--text-secondary); font-size: 11px; font-weight: normal;">' + escapeHtml(distro.source) + '</span>' : ''}</h3>`;
         for (let ri = 0; ri < matching.length; ri++) {
             const r = matching[ri];
             const realIdx = distro.releases.indexOf(r);
-            const rowKey = `${distro.id}-${realIdx}`;
-            const defaultBase = (distro.mirrors[0] && distro.mirrors[0].base) || '';
-            const defaultURL = defaultBase.replace(/\/+$/, '') + r.path;
-            const mirrorOpts = (distro.mirrors || []).map(m =>
+            const rowKey = `${di}-${realIdx}`;
+            const mirrors = distro.mirrors || [];
+            const defaultURL = r.url || ((mirrors[0] && mirrors[0].base) || '').replace(/\/+$/, '') + r.path;
+            const mirrorOpts = mirrors.map(m =>
                 `<option value="${escapeHtml(m.base)}">${escapeHtml(m.region)}</option>`
             ).join('');
             html += `<div class="get-iso-row">
-                <div class="get-iso-label" title="${escapeHtml(r.label)}">${escapeHtml(r.label)}</div>
+                <div class="get-iso-label" title="${escapeHtml(r.label)}${r.sha256 ? '\nSHA-256 ' + escapeHtml(r.sha256) : ''}">${escapeHtml(r.label)}</div>
                 <select class="get-iso-mirror-sel" data-row="${escapeHtml(rowKey)}" data-path="${escapeHtml(r.path)}" onchange="updateGetISORowURL(this)">${mirrorOpts}</select>
                 <input type="text" id="get-iso-url-${escapeHtml(rowKey)}" class="get-iso-url" value="${escapeHtml(defaultURL)}">
                 <div id="get-iso-actions-${escapeHtml(rowKey)}" class="get-iso-actions">
-                    <button type="button" class="btn btn-sm" onclick="downloadFromGetISO('${escapeHtml(rowKey)}', '${escapeHtml(distro.name)}', '${escapeHtml(r.label)}')">
+                    <button type="button" class="btn btn-sm" onclick="downloadFromGetISO('${escapeHtml(rowKey)}', '${escapeHtml(distro.name)}', '${escapeHtml(r.label)}', '${escapeHtml(r.sha256 || '')}')">
                         <svg width="14" height="14" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M21 15v4a2 2 0 0 1-2 2H5a2 2 0 0 1-2-2v-4"/><polyline points="7 10 12 15 17 10"/><line x1="12" y1="15" x2="12" y2="3"/></svg>
                         Download
                     </button>
@@ -4290,6 +5247,22 @@ function renderGetImagesList(filter) {
     container.innerHTML = html;
 }
 
+async function refreshISOCatalog() {
+    try {
+        const res = await authFetch(`${API_BASE}/catalog/refresh`, { method: 'POST' });
+        const data = await res.json();
+        if (data.success) {
+            isoCatalog = data.data;
+            showAlert(data.message, (isoCatalog.errors || []).length ? 'warning' : 'success');
+            renderGetImagesList(document.getElementById('get-iso-filter').value);
+        } else {
+            showAlert(data.error || 'Failed to refresh ISO catalog', 'error');
+        }
+    } catch (e) {
+        showAlert('Failed to refresh ISO catalog', 'error');
+    }
+}
+
 function updateGetISORowURL(select) {
     const rowKey = select.dataset.row;
     const path = select.dataset.path;
@@ -4304,7 +5277,7 @@ function jsAttrEscape(s) {
     return String(s).replace(/\\/g, '\\\\').replace(/'/g, "\\'");
 }
 
-async function downloadFromGetISO(rowKey, distroName, releaseLabel) {
+async function downloadFromGetISO(rowKey, distroName, releaseLabel, sha256) {
     const input = document.getElementById('get-iso-url-' + rowKey);
     if (!input) return;
     const url = input.value.trim();
@@ -4317,7 +5290,8 @@ async function downloadFromGetISO(rowKey, distroName, releaseLabel) {
         const res = await authFetch(`${API_BASE}/images/download`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ url, description: `${distroName} ${releaseLabel}` }),
+            // The catalog checksum only holds while the URL names the catalog's file.
+            body: JSON.stringify({ url, description: `${distroName} ${releaseLabel}`, sha256: url.split('/').pop() === input.defaultValue.split('/').pop() ? (sha256 || '') : '' }),
         });
         const data = await res.json();
         if (data.success && data.data && data.data.filename) {
@@ -4369,7 +5343,7 @@ function startGetISOProgressPolling(rowKey, filename, distroName, releaseLabel)
                 const fill = document.getElementById('get-iso-bar-fill-' + rowKey);
                 const status = document.getElementById('get-iso-status-' + rowKey);
                 if (fill) fill.style.width = pct + '%';
-                if (status) status.textContent = `${pct}% · ${p.speed || ''}`;
+                if (status) status.textContent = p.status === 'queued' ? 'Queued…' : `${pct}% · ${p.speed || ''}`;
 
                 if (p.status === 'completed') {
                     stop();
@@ -4433,7 +5407,8 @@ document.getElementById('download-form').addEventListener('submit', function(e)
 
     const downloadData = {
         url: formData.get('url'),
-        description: formData.get('description')
+        description: formData.get('description'),
+        sha256: formData.get('sha256').trim()
     };
 
     // Disable submit button
@@ -4478,7 +5453,7 @@ function checkDownloadProgress(filename) {
                 const progressText = document.getElementById('download-progress-text');
 
                 progressBar.style.width = progress.percentage.toFixed(1) + '%';
-                progressText.textContent = progress.percentage.toFixed(1) + '% - ' + (progress.speed || '0 B/s');
+                progressText.textContent = progress.status === 'queued' ? 'Queued, waiting for another download to finish' : progress.percentage.toFixed(1) + '% - ' + (progress.speed || '0 B/s');
 
                 if (progress.status === 'completed') {
                     clearInterval(downloadProgressInterval);
@@ -5021,6 +5996,7 @@ function showEditGroupModal(groupId) {
     form.elements.description.value = group.description || '';
     form.elements.order.value = group.order;
     form.elements.enabled.checked = group.enabled;
+    form.elements.pin_protected.checked = !!group.pin_protected;
 
     const parentSelect = document.getElementById('edit-group-parent-select');
     parentSelect.innerHTML = '<option value="">None (Root Level)</option>';
@@ -5108,6 +6084,14 @@ function getDefaultBootParams(img) {
     }
 }
 
+// ISO timestamp to the browser-local "YYYY-MM-DDTHH:MM" a datetime-local input takes.
+function toDatetimeLocal(iso) {
+    if (!iso) return '';
+    const d = new Date(iso);
+    d.setMinutes(d.getMinutes() - d.getTimezoneOffset());
+    return d.toISOString().slice(0, 16);
+}
+
 async function showImagePropertiesModal(filename, opts) {
     opts = opts || {};
     const img = images.find(i => i.filename === filename);
@@ -5119,6 +6103,7 @@ async function showImagePropertiesModal(filename, opts) {
 
     document.getElementById('image-props-name').textContent = img.name;
     document.getElementById('image-props-filename').value = img.filename;
+    document.getElementById('image-props-version').value = img.version || '';
     const logoEl = document.getElementById('image-props-logo');
     logoEl.src = distroLogoSrc(img.distro);
     logoEl.alt = img.distro || '';
@@ -5127,6 +6112,9 @@ async function showImagePropertiesModal(filename, opts) {
     document.getElementById('image-props-description').value = img.description || '';
     document.getElementById('image-props-order').value = img.order || 0;
     document.getElementById('image-props-boot-method').value = img.boot_method || 'sanboot';
+    applyBootMethodCapabilities(await loadCapabilities());
+    // Set again now that any provider's methods are among the options.
+    document.getElementById('image-props-boot-method').value = img.boot_method || 'sanboot';
 
     // Populate distro profile dropdown
     const distroSelect = document.getElementById('image-props-distro');
@@ -5146,6 +6134,15 @@ async function showImagePropertiesModal(filename, opts) {
     document.getElementById('image-props-redetect-btn').style.display = img.extracted ? '' : 'none';
     document.getElementById('image-props-enabled').checked = img.enabled;
     document.getElementById('image-props-public').checked = img.public;
+    document.getElementById('image-props-pin-protected').checked = !!img.pin_protected;
+    document.getElementById('image-props-first-boot-agent').checked = !!img.first_boot_agent;
+    document.getElementById('image-props-iscsi').checked = !!img.iscsi_enabled;
+    const iscsiTarget = document.getElementById('image-props-iscsi-target');
+    iscsiTarget.textContent = img.iscsi_target || '';
+    iscsiTarget.style.display = img.iscsi_target ? '' : 'none';
+    document.getElementById('image-props-visible-from').value = toDatetimeLocal(img.visible_from);
+    document.getElementById('image-props-visible-until').value = toDatetimeLocal(img.visible_until);
+    document.getElementById('image-props-visible-windows').value = img.visible_windows || '';
 
     applyBootParamsWindowsLock(distroSelect.value);
     distroSelect.onchange = () => applyBootParamsWindowsLock(distroSelect.value);
@@ -5192,6 +6189,13 @@ async function showImagePropertiesModal(filename, opts) {
     patchSmbBtn.style.display = smbEligible ? 'inline-block' : 'none';
     patchSmbBtn.textContent = img.smb_install_enabled ? t('props.action.re_patch_smb') : t('props.action.patch_smb');
 
+    const liteBtn = document.getElementById('image-props-lite-initrd-btn');
+    const liteEligible = img.extracted && !img.clone_of && ['kernel', 'nfs'].includes(img.boot_method) && !(img.distro || '').startsWith('windows');
+    liteBtn.style.display = liteEligible ? 'inline-block' : 'none';
+    liteBtn.textContent = img.lite_initrd_size
+        ? `Rebuild lite initrd (${Math.round(img.lite_initrd_size / 1048576)} MB)`
+        : 'Build lite initrd';
+
     // Stash state used by the live warnings so onChange handlers can re-evaluate.
     _imagePropsState = {
         img: img,
@@ -5300,6 +6304,27 @@ function deleteFromProperties() {
     deleteImage(filename, name);
 }
 
+async function buildLiteInitrdFromProperties() {
+    const filename = document.getElementById('image-props-filename').value;
+    try {
+        const res = await authFetch(`${API_BASE}/images/initrd-lite?filename=${encodeURIComponent(filename)}`, { method: 'POST' });
+        const data = await res.json();
+        if (!data.success) throw new Error(data.error);
+        showNotification(data.message, 'info');
+        let job;
+        do {
+            await new Promise(r => setTimeout(r, 2000));
+            job = (await (await authFetch(`${API_BASE}/jobs/${data.data.job_id}`)).json()).data;
+        } while (job && job.status === 'running');
+        if (job && job.status === 'failed') throw new Error(job.error);
+        showNotification('Lite initrd built', 'success');
+        await loadImages();
+        refreshImagePropsIfOpenFor(filename);
+    } catch (err) {
+        showNotification('Lite initrd failed: ' + err.message, 'error');
+    }
+}
+
 function downloadNetbootFromProperties() {
     const filename = document.getElementById('image-props-filename').value;
     const name = document.getElementById('image-props-display-name').value;
@@ -5490,6 +6515,12 @@ async function saveImageProperties(opts) {
     const bootParams = document.getElementById('image-props-boot-params').value;
     const enabled = document.getElementById('image-props-enabled').checked;
     const isPublic = document.getElementById('image-props-public').checked;
+    const pinProtected = document.getElementById('image-props-pin-protected').checked;
+    const firstBootAgent = document.getElementById('image-props-first-boot-agent').checked;
+    const iscsiEnabled = document.getElementById('image-props-iscsi').checked;
+    const visibleFrom = document.getElementById('image-props-visible-from').value;
+    const visibleUntil = document.getElementById('image-props-visible-until').value;
+    const visibleWindows = document.getElementById('image-props-visible-windows').value.trim();
 
     // Auto-install: presence of a selected file = enabled. No separate
     // checkbox / script type / inline script from the image panel — those
@@ -5509,7 +6540,15 @@ async function saveImageProperties(opts) {
         boot_params: bootParams,
         enabled: enabled,
         public: isPublic,
+        pin_protected: pinProtected,
+        first_boot_agent: firstBootAgent,
+        iscsi_enabled: iscsiEnabled,
         auto_install_file: autoInstallFile,
+        visible_from: visibleFrom ? new Date(visibleFrom).toISOString() : null,
+        visible_until: visibleUntil ? new Date(visibleUntil).toISOString() : null,
+        visible_windows: visibleWindows,
+        // The version the form was loaded from, not the list's latest.
+        version: parseInt(document.getElementById('image-props-version').value, 10) || undefined,
     };
 
     try {
@@ -5522,11 +6561,19 @@ async function saveImageProperties(opts) {
 
         const data = await res.json();
 
+        if (res.status === 409) {
+            // Nothing was saved; the form keeps the admin's edits.
+            showNotification(data.error, 'error');
+            return false;
+        }
         if (!data.success) {
             showNotification('Failed to update image: ' + (data.error || 'Unknown error'), 'error');
             return false;
         }
 
+        if (data.data && data.data.version) {
+            document.getElementById('image-props-version').value = data.data.version;
+        }
         showNotification('Image properties updated', 'success');
         await loadImages();
         loadStats();
@@ -128,6 +128,13 @@ const TRANSLATIONS = {
         'props.field.boot_params_hint': 'Leave empty for distro defaults.',
         'props.field.placeholders_label': 'Placeholders:',
         'props.field.public': 'Public (available to all clients)',
+        'props.field.pin_protected': 'Require menu PIN',
+        'props.field.first_boot_agent': 'Load first-boot agent',
+        'props.field.iscsi': 'Publish over iSCSI',
+        'props.field.visible_from': 'Visible from',
+        'props.field.visible_until': 'Visible until',
+        'props.field.visible_windows': 'Visible during',
+        'props.field.visible_windows_hint': 'Leave empty to always show. Times are the server\'s local time, e.g.',
         'props.field.default_autoinstall': 'Default Auto-Install File',
         'props.field.autoinstall_none': '(None — manual install)',
         'props.field.autoinstall_hint': "Only files matching this image's distro are shown. Manage files in the Auto-Install section.",
@@ -293,6 +300,13 @@ const TRANSLATIONS = {
         'props.field.boot_params_hint': 'Leer lassen für Distro-Standardwerte.',
         'props.field.placeholders_label': 'Platzhalter:',
         'props.field.public': 'Öffentlich (für alle Clients verfügbar)',
+        'props.field.pin_protected': 'Menü-PIN verlangen',
+        'props.field.first_boot_agent': 'First-Boot-Agent laden',
+        'props.field.iscsi': 'Über iSCSI veröffentlichen',
+        'props.field.visible_from': 'Sichtbar ab',
+        'props.field.visible_until': 'Sichtbar bis',
+        'props.field.visible_windows': 'Sichtbar während',
+        'props.field.visible_windows_hint': 'Leer lassen, um immer anzuzeigen. Zeiten in lokaler Serverzeit, z. B.',
         'props.field.default_autoinstall': 'Standard-Auto-Installations-Datei',
         'props.field.autoinstall_none': '(Keine — manuelle Installation)',
         'props.field.autoinstall_hint': 'Nur Dateien, die zur Distro dieses Abbilds passen, werden angezeigt. Dateien werden im Bereich „Auto-Installation“ verwaltet.',
@@ -458,6 +472,13 @@ const TRANSLATIONS = {
         'props.field.boot_params_hint': 'Laissez vide pour utiliser les valeurs par défaut de la distribution.',
         'props.field.placeholders_label': 'Espaces réservés :',
         'props.field.public': 'Public (disponible pour tous les clients)',
+        'props.field.pin_protected': 'Exiger le code PIN du menu',
+        'props.field.first_boot_agent': 'Charger l’agent de premier démarrage',
+        'props.field.iscsi': 'Publier via iSCSI',
+        'props.field.visible_from': 'Visible à partir de',
+        'props.field.visible_until': 'Visible jusqu\'au',
+        'props.field.visible_windows': 'Visible pendant',
+        'props.field.visible_windows_hint': 'Laissez vide pour toujours afficher. Heures locales du serveur, par ex.',
         'props.field.default_autoinstall': "Fichier d'installation auto par défaut",
         'props.field.autoinstall_none': '(Aucun — installation manuelle)',
         'props.field.autoinstall_hint': "Seuls les fichiers correspondant à la distribution de cette image sont affichés. Gérez les fichiers dans la section Installation auto.",
@@ -627,6 +648,13 @@ const TRANSLATIONS = {
         'props.field.boot_params_hint': 'Оставьте пустым, чтобы использовать значения дистрибутива по умолчанию.',
         'props.field.placeholders_label': 'Подстановки:',
         'props.field.public': 'Общий (доступен всем клиентам)',
+        'props.field.pin_protected': 'Требовать PIN меню',
+        'props.field.first_boot_agent': 'Загружать агент первой загрузки',
+        'props.field.iscsi': 'Публиковать по iSCSI',
+        'props.field.visible_from': 'Видим с',
+        'props.field.visible_until': 'Видим до',
+        'props.field.visible_windows': 'Видим в периоды',
+        'props.field.visible_windows_hint': 'Оставьте пустым, чтобы показывать всегда. Время сервера, например',
         'props.field.default_autoinstall': 'Файл автоустановки по умолчанию',
         'props.field.autoinstall_none': '(Нет — установка вручную)',
         'props.field.autoinstall_hint': 'Показаны только файлы, соответствующие дистрибутиву этого образа. Файлы управляются в разделе «Автоустановка».',
@@ -792,6 +820,13 @@ const TRANSLATIONS = {
         'props.field.boot_params_hint': '留空以使用发行版默认值。',
         'props.field.placeholders_label': '占位符:',
         'props.field.public': '公开(所有客户端可见)',
+        'props.field.pin_protected': '需要菜单 PIN',
+        'props.field.first_boot_agent': '加载首次启动代理',
+        'props.field.iscsi': '通过 iSCSI 发布',
+        'props.field.visible_from': '可见开始',
+        'props.field.visible_until': '可见截止',
+        'props.field.visible_windows': '可见时段',
+        'props.field.visible_windows_hint': '留空则始终显示。使用服务器本地时间,例如',
         'props.field.default_autoinstall': '默认自动安装文件',
         'props.field.autoinstall_none': '(无 — 手动安装)',
         'props.field.autoinstall_hint': '仅显示与该镜像发行版匹配的文件。文件请在「自动安装」中管理。',